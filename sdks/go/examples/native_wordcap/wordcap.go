@@ -29,8 +29,8 @@ import (
 	"os"
 	"strings"
 
-	"github.com/apache/beam/sdks/v2/go/examples/native_wordcap/nativepubsubio"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/pubsubio"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/options/gcpopts"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/util/pubsubx"
@@ -68,7 +68,7 @@ func main() {
 	p := beam.NewPipeline()
 	s := p.Root()
 
-	col := nativepubsubio.Read(ctx, s, project, *input, sub.ID())
+	col := pubsubio.NativeRead(s, project, pubsubio.ReadOptions{Subscription: sub.ID()})
 	str := beam.ParDo(s, func(b []byte) string {
 		return (string)(b)
 	}, col)
@@ -162,6 +162,7 @@ type MultiEdge struct {
 	External         *ExternalTransform      // Current External Transforms API
 	Payload          *Payload                // Legacy External Transforms API
 	WindowFn         *window.Fn              // WindowInto
+	ErrorHandler     *ErrorHandler           // ParDo
 
 	Input  []*Inbound
 	Output []*Outbound
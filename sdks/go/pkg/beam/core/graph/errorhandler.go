@@ -0,0 +1,83 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graph
+
+import (
+	"reflect"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+)
+
+// ErrorHandler configures a ParDo to route elements that fail processing to
+// a dead-letter output, rather than failing the bundle. It is serialized as
+// part of the edge, so all fields must be JSON-serializable.
+type ErrorHandler struct {
+	// Counters enables the failed element counter.
+	Counters bool `json:"counters,omitempty"`
+	// MaxFailureRate is the fraction of failed elements in a bundle above
+	// which the bundle fails anyway. Zero disables the cap.
+	MaxFailureRate float64 `json:"maxFailureRate,omitempty"`
+	// MinElements is the number of elements a bundle must have processed
+	// before MaxFailureRate is enforced.
+	MinElements int64 `json:"minElements,omitempty"`
+}
+
+// FailedElement is the element type of a dead-letter output. It holds a
+// main input element that failed processing along with the cause.
+type FailedElement struct {
+	// Transform is the name of the DoFn that failed.
+	Transform string
+	// Element is the failed element, encoded with the main input coder.
+	Element []byte
+	// Error is the error returned, or the value panicked, by the DoFn.
+	Error string
+	// Stack is the stack trace at the point of failure, if the DoFn panicked.
+	Stack string
+}
+
+// FailedElementType is the reflect.Type of FailedElement.
+var FailedElementType = reflect.TypeOf((*FailedElement)(nil)).Elem()
+
+// AddErrorHandler attaches the error handler to the given ParDo edge and
+// appends the dead-letter output as its last output. The edge must not be
+// splittable, since a failed restriction cannot be retried from the
+// dead-letter output, and must not have a grouped main input.
+func AddErrorHandler(g *Graph, edge *MultiEdge, h *ErrorHandler) error {
+	if edge.Op != ParDo {
+		return errors.Errorf("error handlers are only supported on ParDo, got %v", edge.Op)
+	}
+	if edge.DoFn.IsSplittable() {
+		return errors.Errorf("error handlers are not supported on splittable DoFn %v", edge.DoFn.Name())
+	}
+	if h.MaxFailureRate < 0 || h.MaxFailureRate > 1 {
+		return errors.Errorf("invalid maximum failure rate %v for DoFn %v, must be in [0, 1]", h.MaxFailureRate, edge.DoFn.Name())
+	}
+	main := edge.Input[0].From
+	if typex.IsCoGBK(main.Type()) {
+		return errors.Errorf("error handlers are not supported on DoFn %v with grouped main input %v", edge.DoFn.Name(), main.Type())
+	}
+
+	var in []*Node
+	for _, i := range edge.Input {
+		in = append(in, i.From)
+	}
+	t := typex.New(FailedElementType)
+	n := g.NewNode(t, inputWindow(in), inputBounded(in))
+	edge.Output = append(edge.Output, &Outbound{To: n, Type: t})
+	edge.ErrorHandler = h
+	return nil
+}
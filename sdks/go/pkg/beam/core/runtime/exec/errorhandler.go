@@ -0,0 +1,158 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/metrics"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+)
+
+// DeadLetter routes main input elements that fail processing in a ParDo to
+// a dead-letter output, instead of failing the bundle. Elements emitted by
+// the DoFn before it failed are not retracted.
+type DeadLetter struct {
+	Handler *graph.ErrorHandler
+	Enc     ElementEncoder // Encoder for the main input elements.
+	Out     Node
+
+	processed, failed int64
+	failedCounter     *metrics.Counter
+	b                 bytes.Buffer
+	ret               FullValue
+}
+
+// startBundle resets the per-bundle failure rate and propagates to the
+// dead-letter output.
+func (d *DeadLetter) startBundle(ctx context.Context, id string, data DataContext) error {
+	d.processed, d.failed = 0, 0
+	return d.Out.StartBundle(ctx, id, data)
+}
+
+// finishBundle propagates to the dead-letter output.
+func (d *DeadLetter) finishBundle(ctx context.Context) error {
+	d.b = bytes.Buffer{}
+	d.ret = FullValue{}
+	return d.Out.FinishBundle(ctx)
+}
+
+// outputs wraps the outputs of the DoFn, so that failures of downstream nodes
+// are told apart from failures of the DoFn itself.
+func (d *DeadLetter) outputs(out []Node) []Node {
+	ret := make([]Node, len(out))
+	for i, n := range out {
+		ret[i] = &deadLetterOutput{Node: n}
+	}
+	return ret
+}
+
+// invoke calls fn for the given element, and routes it to the dead-letter
+// output if fn returns an error or panics. Failures of downstream nodes, which
+// surface as panics out of emitters, are propagated as-is.
+func (d *DeadLetter) invoke(ctx context.Context, name string, elm *FullValue, fn func() (*FullValue, error)) (*FullValue, error) {
+	d.processed++
+
+	val, stack, err := callRecover(fn)
+	if err == nil {
+		return val, nil
+	}
+	if e, ok := err.(*downstreamError); ok {
+		return nil, e.err
+	}
+	d.failed++
+	if d.Handler.Counters {
+		if d.failedCounter == nil {
+			d.failedCounter = metrics.NewCounter(name, "failed_elements")
+		}
+		d.failedCounter.Inc(ctx, 1)
+	}
+	if max := d.Handler.MaxFailureRate; max > 0 && d.processed >= d.Handler.MinElements {
+		if rate := float64(d.failed) / float64(d.processed); rate > max {
+			return nil, errors.Wrapf(err, "failure rate %.3f of %v elements exceeds maximum %v", rate, d.processed, max)
+		}
+	}
+
+	d.b.Reset()
+	if encErr := d.Enc.Encode(elm, &d.b); encErr != nil {
+		return nil, errors.Wrapf(encErr, "encoding failed element for dead-letter output, DoFn failed with: %v", err)
+	}
+	d.ret = FullValue{
+		Elm: graph.FailedElement{
+			Transform: name,
+			Element:   append([]byte(nil), d.b.Bytes()...),
+			Error:     err.Error(),
+			Stack:     stack,
+		},
+		Timestamp: elm.Timestamp,
+		Windows:   elm.Windows,
+		Pane:      elm.Pane,
+	}
+	return nil, d.Out.ProcessElement(ctx, &d.ret)
+}
+
+// callRecover calls fn and converts a panic to an error, along with the stack
+// at the point of the panic.
+func callRecover(fn func() (*FullValue, error)) (val *FullValue, stack string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch e := r.(type) {
+			case *doFnError:
+				panic(e)
+			case *downstreamError:
+				err = e
+				return
+			}
+			err = fmt.Errorf("panic: %v", r)
+			stack = string(debug.Stack())
+		}
+	}()
+	val, err = fn()
+	return val, "", err
+}
+
+// downstreamError is the failure of a node downstream of a DoFn with a
+// dead-letter output.
+type downstreamError struct {
+	err error
+}
+
+func (e *downstreamError) Error() string {
+	return e.err.Error()
+}
+
+// deadLetterOutput is an output of a DoFn with a dead-letter output. It marks
+// the errors and panics of the downstream node, which emitters raise as panics
+// through the DoFn.
+type deadLetterOutput struct {
+	Node
+}
+
+// ProcessElement processes the element with the downstream node, and marks
+// its failure as a downstreamError.
+func (o *deadLetterOutput) ProcessElement(ctx context.Context, elm *FullValue, values ...ReStream) error {
+	err := callNoPanic(ctx, func(ctx context.Context) error {
+		return o.Node.ProcessElement(ctx, elm, values...)
+	})
+	if err != nil {
+		return &downstreamError{err: err}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/coder"
+)
+
+func failOddFn(n int64, emit func(int64)) error {
+	switch {
+	case n%4 == 1:
+		return errors.New("odd")
+	case n%4 == 3:
+		panic("very odd")
+	}
+	emit(n)
+	return nil
+}
+
+func runDeadLetterPardo(t *testing.T, h *graph.ErrorHandler, in ...any) (*CaptureNode, *CaptureNode, error) {
+	t.Helper()
+	fn, err := graph.NewDoFn(failOddFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	out := &CaptureNode{UID: 1}
+	dead := &CaptureNode{UID: 2}
+	pardo := &ParDo{UID: 3, Fn: fn, Out: []Node{out}, DeadLetter: &DeadLetter{
+		Handler: h,
+		Enc:     MakeElementEncoder(coder.NewVarInt()),
+		Out:     dead,
+	}}
+	n := &FixedRoot{UID: 4, Elements: makeInput(in...), Out: pardo}
+
+	p, err := NewPlan("a", []Unit{n, pardo, out, dead})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	err = p.Execute(context.Background(), "1", DataContext{})
+	p.Down(context.Background())
+	return out, dead, err
+}
+
+func TestParDo_DeadLetter(t *testing.T) {
+	out, dead, err := runDeadLetterPardo(t, &graph.ErrorHandler{Counters: true}, int64(0), int64(1), int64(2), int64(3), int64(4))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if want := makeValues(int64(0), int64(2), int64(4)); !equalList(out.Elements, want) {
+		t.Errorf("main output = %v, want %v", extractValues(out.Elements...), extractValues(want...))
+	}
+	if got, want := len(dead.Elements), 2; got != want {
+		t.Fatalf("len(dead-letter output) = %v, want %v", got, want)
+	}
+	for i, want := range []struct {
+		elm   int64
+		err   string
+		stack bool
+	}{{1, "odd", false}, {3, "panic: very odd", true}} {
+		got := dead.Elements[i].Elm.(graph.FailedElement)
+		dec, err := MakeElementDecoder(coder.NewVarInt()).Decode(bytes.NewReader(got.Element))
+		if err != nil {
+			t.Fatalf("failed to decode dead-letter element %v: %v", i, err)
+		}
+		if dec.Elm != want.elm {
+			t.Errorf("dead-letter element %v = %v, want %v", i, dec.Elm, want.elm)
+		}
+		if got.Error != want.err {
+			t.Errorf("dead-letter error %v = %q, want %q", i, got.Error, want.err)
+		}
+		if (got.Stack != "") != want.stack {
+			t.Errorf("dead-letter stack %v = %q, want present: %v", i, got.Stack, want.stack)
+		}
+		if !strings.HasSuffix(got.Transform, "failOddFn") {
+			t.Errorf("dead-letter transform %v = %q, want failOddFn", i, got.Transform)
+		}
+	}
+}
+
+func TestParDo_DeadLetterMaxFailureRate(t *testing.T) {
+	h := &graph.ErrorHandler{MaxFailureRate: 0.25, MinElements: 4}
+	if _, _, err := runDeadLetterPardo(t, h, int64(0), int64(2), int64(4), int64(1)); err != nil {
+		t.Errorf("execute failed with failure rate at the maximum: %v", err)
+	}
+	if _, _, err := runDeadLetterPardo(t, h, int64(1), int64(0), int64(2), int64(4)); err != nil {
+		t.Errorf("execute failed before the minimum elements: %v", err)
+	}
+	_, _, err := runDeadLetterPardo(t, h, int64(0), int64(2), int64(1), int64(3))
+	if err == nil || !strings.Contains(err.Error(), "exceeds maximum") {
+		t.Errorf("execute = %v, want failure rate error", err)
+	}
+}
+
+// failingWriteDataManager is a DataManager whose writes fail.
+type failingWriteDataManager struct {
+	TestDataManager
+}
+
+func (dm *failingWriteDataManager) OpenWrite(ctx context.Context, id StreamID) (io.WriteCloser, error) {
+	return failingWriter{}, nil
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("data channel closed")
+}
+
+func (failingWriter) Close() error {
+	return nil
+}
+
+func TestParDo_DeadLetterDownstreamFailure(t *testing.T) {
+	fn, err := graph.NewDoFn(failOddFn)
+	if err != nil {
+		t.Fatalf("invalid function: %v", err)
+	}
+	out := &DataSink{UID: 1, SID: StreamID{PtransformID: "sink"}, Coder: coder.NewW(coder.NewVarInt(), coder.NewGlobalWindow())}
+	dead := &CaptureNode{UID: 2}
+	pardo := &ParDo{UID: 3, Fn: fn, Out: []Node{out}, DeadLetter: &DeadLetter{
+		Handler: &graph.ErrorHandler{},
+		Enc:     MakeElementEncoder(coder.NewVarInt()),
+		Out:     dead,
+	}}
+	n := &FixedRoot{UID: 4, Elements: makeInput(int64(0), int64(2)), Out: pardo}
+
+	p, err := NewPlan("a", []Unit{n, pardo, out, dead})
+	if err != nil {
+		t.Fatalf("failed to construct plan: %v", err)
+	}
+	err = p.Execute(context.Background(), "1", DataContext{Data: &failingWriteDataManager{}})
+	p.Down(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "data channel closed") {
+		t.Errorf("execute = %v, want the data sink failure", err)
+	}
+	if len(dead.Elements) != 0 {
+		t.Errorf("dead-letter output = %v, want the data sink failure to not be dead-lettered", dead.Elements)
+	}
+}
@@ -44,6 +44,7 @@ type ParDo struct {
 	UState       UserStateAdapter
 	TimerTracker *userTimerAdapter
 	Out          []Node
+	DeadLetter   *DeadLetter
 
 	PID      string
 	emitters []ReusableEmitter
@@ -106,7 +107,11 @@ func (n *ParDo) Up(ctx context.Context) error {
 		return n.fail(err)
 	}
 
-	emitters, err := makeEmitters(n.Fn.ProcessElementFn(), n.Out)
+	out := n.Out
+	if n.DeadLetter != nil {
+		out = n.DeadLetter.outputs(out)
+	}
+	emitters, err := makeEmitters(n.Fn.ProcessElementFn(), out)
 	if err != nil {
 		return n.fail(err)
 	}
@@ -136,6 +141,11 @@ func (n *ParDo) StartBundle(ctx context.Context, id string, data DataContext) er
 	if err := MultiStartBundle(n.ctx, id, data, n.Out...); err != nil {
 		return n.fail(err)
 	}
+	if n.DeadLetter != nil {
+		if err := n.DeadLetter.startBundle(n.ctx, id, data); err != nil {
+			return n.fail(err)
+		}
+	}
 
 	// TODO(BEAM-3303): what to set for StartBundle/FinishBundle window and emitter timestamp?
 
@@ -191,7 +201,15 @@ func (n *ParDo) processMainInput(mainIn *MainInput) error {
 // each individual window by exploding the windows first.
 func (n *ParDo) processSingleWindow(mainIn *MainInput) (sdf.ProcessContinuation, error) {
 	elm := &mainIn.Key
-	val, err := n.invokeProcessFn(n.ctx, elm.Pane, elm.Windows, elm.Timestamp, mainIn)
+	var val *FullValue
+	var err error
+	if n.DeadLetter != nil {
+		val, err = n.DeadLetter.invoke(n.ctx, n.Fn.Name(), elm, func() (*FullValue, error) {
+			return n.invokeProcessFn(n.ctx, elm.Pane, elm.Windows, elm.Timestamp, mainIn)
+		})
+	} else {
+		val, err = n.invokeProcessFn(n.ctx, elm.Pane, elm.Windows, elm.Timestamp, mainIn)
+	}
 	if err != nil {
 		return nil, n.fail(err)
 	}
@@ -264,6 +282,11 @@ func (n *ParDo) FinishBundle(_ context.Context) error {
 	if err := MultiFinishBundle(n.ctx, n.Out...); err != nil {
 		return n.fail(err)
 	}
+	if n.DeadLetter != nil {
+		if err := n.DeadLetter.finishBundle(n.ctx); err != nil {
+			return n.fail(err)
+		}
+	}
 	return nil
 }
 
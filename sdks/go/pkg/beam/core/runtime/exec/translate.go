@@ -531,6 +531,20 @@ func (b *builder) makeLink(from string, id linkID) (Node, error) {
 
 					input := unmarshalKeyedValues(transform.GetInputs())
 
+					h, err := graphx.DecodeErrorHandler(tp.GetEdge())
+					if err != nil {
+						return nil, err
+					}
+					if h != nil {
+						// The dead-letter output is always the last output.
+						ec, _, err := b.makeCoderForPCollection(input[0])
+						if err != nil {
+							return nil, err
+						}
+						n.Out = out[:len(out)-1]
+						n.DeadLetter = &DeadLetter{Handler: h, Enc: MakeElementEncoder(ec), Out: out[len(out)-1]}
+					}
+
 					if len(userState) > 0 {
 						stateIDToCoder := make(map[string]*coder.Coder)
 						stateIDToKeyCoder := make(map[string]*coder.Coder)
@@ -52,6 +52,9 @@ func callNoPanic(ctx context.Context, fn func(context.Context) error) (err error
 			// Check if the panic value is from a failed DoFn, and return it without a panic trace.
 			if e, ok := r.(*doFnError); ok {
 				err = e
+			} else if e, ok := r.(*downstreamError); ok {
+				// The downstream failure already has its own trace.
+				err = e.err
 			} else {
 				// Top level error is the panic itself, but also include the stack trace as the original error.
 				// Higher levels can then add appropriate context without getting pushed down by the stack trace.
@@ -62,6 +62,14 @@ func EncodeMultiEdge(edge *graph.MultiEdge) (*v1pb.MultiEdge, error) {
 	if edge.WindowFn != nil {
 		ret.WindowFn = encodeWindowFn(edge.WindowFn)
 	}
+	if edge.ErrorHandler != nil {
+		data, err := jsonx.Marshal(edge.ErrorHandler)
+		if err != nil {
+			wrapped := errors.Wrap(err, "bad error handler")
+			return nil, errors.WithContextf(wrapped, "encoding userfn %v", edge)
+		}
+		ret.ErrorHandler = string(data)
+	}
 
 	for _, in := range edge.Input {
 		kind, err := encodeInputKind(in.Kind)
@@ -134,6 +142,20 @@ func DecodeMultiEdge(edge *v1pb.MultiEdge) (graph.Opcode, *graph.Fn, *window.Fn,
 	return opcode, u, wfn, inbound, outbound, nil
 }
 
+// DecodeErrorHandler returns the error handler of the wire representation of
+// the edge, if present. It returns nil if the edge has no error handler.
+func DecodeErrorHandler(edge *v1pb.MultiEdge) (*graph.ErrorHandler, error) {
+	if edge.GetErrorHandler() == "" {
+		return nil, nil
+	}
+	var h graph.ErrorHandler
+	if err := jsonx.Unmarshal(&h, []byte(edge.GetErrorHandler())); err != nil {
+		wrapped := errors.Wrap(err, "bad error handler")
+		return nil, errors.WithContextf(wrapped, "decoding userfn %v", edge)
+	}
+	return &h, nil
+}
+
 func encodeCustomCoder(c *coder.CustomCoder) (*v1pb.CustomCoder, error) {
 	t, err := encodeType(c.Type)
 	if err != nil {
@@ -0,0 +1,80 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beam
+
+import (
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph"
+)
+
+func init() {
+	RegisterType(graph.FailedElementType)
+}
+
+// FailedElement is the element type of the dead-letter PCollection of a ParDo
+// with an ErrorHandler. It holds the failed main input element, encoded with
+// the coder of the main input PCollection, along with the error and, if the
+// DoFn panicked, the stack trace. The element keeps the timestamp and windows
+// of the failed input element.
+type FailedElement = graph.FailedElement
+
+// ErrorHandler is a ParDo option that routes the main input elements for
+// which the DoFn returns an error or panics to a dead-letter PCollection,
+// instead of failing the bundle. Use WithErrorHandler to construct one.
+//
+// The dead-letter PCollection is of type FailedElement and is returned as the
+// last output of the ParDo, so a DoFn with a single output must use ParDo2:
+//
+//	words, failed := beam.ParDo2(s, parseFn, lines, beam.WithErrorHandler())
+//
+// Elements emitted by the DoFn before it failed are not retracted. Error
+// handlers are not supported for splittable DoFns or grouped main inputs.
+type ErrorHandler struct {
+	h graph.ErrorHandler
+}
+
+func (h ErrorHandler) private() {}
+
+// ErrorHandlerOption configures an ErrorHandler.
+type ErrorHandlerOption func(*graph.ErrorHandler)
+
+// WithErrorHandler returns a ParDo option that routes failed elements to a
+// dead-letter PCollection, configured by the given options.
+func WithErrorHandler(opts ...ErrorHandlerOption) ErrorHandler {
+	var h ErrorHandler
+	for _, opt := range opts {
+		opt(&h.h)
+	}
+	return h
+}
+
+// WithFailureCounters enables a "failed_elements" counter, namespaced by the
+// DoFn name, that counts the elements routed to the dead-letter PCollection.
+func WithFailureCounters() ErrorHandlerOption {
+	return func(h *graph.ErrorHandler) {
+		h.Counters = true
+	}
+}
+
+// WithMaxFailureRate caps the fraction of failed elements in a bundle. Once a
+// bundle has processed at least minElements elements, a failure that takes the
+// fraction of failed elements above rate fails the bundle as if there was no
+// error handler. The rate must be in [0, 1], where zero disables the cap.
+func WithMaxFailureRate(rate float64, minElements int64) ErrorHandlerOption {
+	return func(h *graph.ErrorHandler) {
+		h.MaxFailureRate = rate
+		h.MinElements = minElements
+	}
+}
@@ -0,0 +1,67 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package beam_test
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+)
+
+func init() {
+	register.Function1x2(parseIntFn)
+	register.Function1x1(failedErrorFn)
+}
+
+func parseIntFn(s string) (int, error) {
+	if s == "boom" {
+		panic("boom")
+	}
+	return strconv.Atoi(s)
+}
+
+func failedErrorFn(f beam.FailedElement) string {
+	return fmt.Sprintf("%s: %s", f.Error, f.Element)
+}
+
+func TestParDoWithErrorHandler(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	lines := beam.Create(s, "1", "two", "3", "boom")
+	nums, failed := beam.ParDo2(s, parseIntFn, lines, beam.WithErrorHandler(beam.WithFailureCounters()))
+	passert.Equals(s, nums, 1, 3)
+	passert.Count(s, failed, "failed", 2)
+	passert.Equals(s, beam.ParDo(s, failedErrorFn, failed),
+		"strconv.Atoi: parsing \"two\": invalid syntax: \x03two",
+		"panic: boom: \x04boom")
+	ptest.RunAndValidate(t, p)
+}
+
+func TestParDoWithErrorHandler_Invalid(t *testing.T) {
+	_, s := beam.NewPipelineWithRoot()
+	lines := beam.Create(s, "1")
+	if _, err := beam.TryParDo(s, parseIntFn, lines, beam.WithErrorHandler(beam.WithMaxFailureRate(2, 0))); err == nil {
+		t.Errorf("TryParDo with failure rate 2 succeeded, want error")
+	}
+	grouped := beam.GroupByKey(s, beam.AddFixedKey(s, lines))
+	if _, err := beam.TryParDo(s, func(int, func(*string) bool) {}, grouped, beam.WithErrorHandler()); err == nil {
+		t.Errorf("TryParDo with grouped input succeeded, want error")
+	}
+}
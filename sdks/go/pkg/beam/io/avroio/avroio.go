@@ -19,28 +19,46 @@ package avroio
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"reflect"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/fileio"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
-	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
 	"github.com/linkedin/goavro/v2"
 )
 
 func init() {
-	register.DoFn3x1[context.Context, fileio.ReadableFile, func(beam.X), error]((*avroReadFn)(nil))
-	register.DoFn3x1[context.Context, int, func(*string) bool, error]((*writeAvroFn)(nil))
+	register.DoFn3x1[context.Context, fileio.FileRange, func(beam.X), error](&avroReadFn{})
 	register.Emitter1[beam.X]()
-	register.Iter1[string]()
+	beam.RegisterType(reflect.TypeOf((*avroSink)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*typedSink)(nil)).Elem())
 }
 
-// Read reads a set of files and returns lines as a PCollection<elem>
-// based on the internal avro schema of the file.
-// A type - reflect.TypeOf( YourType{} ) -  with
-// JSON tags can be defined or if you wish to return the raw JSON string,
-// use - reflect.TypeOf("") -
+// blockSize is the desired size of the ranges of a file that are read in
+// parallel.
+var blockSize = fileio.DefaultBlockSize
+
+// Read reads a set of Avro object container files and returns their records
+// as a PCollection<T>, where T is the type t. If t is string, the records are
+// returned as JSON strings. Otherwise, the records are decoded directly into
+// values of t, with the struct fields matched to the fields of the records by
+// name, as described in SchemaFromType, or else case-insensitively.
+//
+// Records may be decoded into fields of the Go types of SchemaFromType, and
+// into fields of type any as goavro native values. Avro ints and longs may
+// also be decoded into other integer and floating point types, and integral
+// floats and doubles into integers. Decimals are decoded exactly into
+// *big.Rat, and may also be decoded into float64, which rounds them to the
+// nearest float64. Unions of null and another type may be decoded into
+// pointers, or into the other type, in which case null is the zero value. As
+// with encoding/json, a union may also be decoded into a struct with a field
+// named after the type of the union value, such as `json:"double"`.
+//
+// Files larger than 64 MB are split into ranges that are read in parallel,
+// each reading the blocks whose sync markers start in the range.
 func Read(s beam.Scope, glob string, t reflect.Type) beam.PCollection {
 	s = s.Scope("avroio.Read")
 	filesystem.ValidateScheme(glob)
@@ -50,9 +68,10 @@ func Read(s beam.Scope, glob string, t reflect.Type) beam.PCollection {
 func read(s beam.Scope, t reflect.Type, col beam.PCollection) beam.PCollection {
 	matches := fileio.MatchAll(s, col, fileio.MatchEmptyAllow())
 	files := fileio.ReadMatches(s, matches, fileio.ReadUncompressed())
+	ranges := fileio.SplitFiles(s, files, blockSize)
 	return beam.ParDo(s,
 		&avroReadFn{Type: beam.EncodedType{T: t}},
-		files,
+		ranges,
 		beam.TypeDefinition{Var: beam.XType, T: t},
 	)
 }
@@ -60,118 +79,214 @@ func read(s beam.Scope, t reflect.Type, col beam.PCollection) beam.PCollection {
 type avroReadFn struct {
 	// Avro schema type
 	Type beam.EncodedType
+
+	conv *converter
 }
 
-func (f *avroReadFn) ProcessElement(ctx context.Context, file fileio.ReadableFile, emit func(beam.X)) (err error) {
-	log.Infof(ctx, "Reading AVRO from %v", file.Metadata.Path)
+func (f *avroReadFn) Setup() {
+	f.conv = newConverter()
+}
 
-	fd, err := file.Open(ctx)
+// ProcessElement reads the blocks of the range, which are the blocks whose
+// sync markers start at or after the start of the range and before its end.
+func (f *avroReadFn) ProcessElement(ctx context.Context, r fileio.FileRange, emit func(beam.X)) error {
+	fd, err := r.File.Open(ctx)
 	if err != nil {
-		return
+		return err
 	}
 	defer fd.Close()
 
-	ar, err := goavro.NewOCFReader(fd)
+	or, err := newOCFReader(fd)
+	if err != nil {
+		return fmt.Errorf("reading %v: %w", r.File.Metadata.Path, err)
+	}
+	codec, err := goavro.NewCodec(or.header.Schema)
 	if err != nil {
-		log.Errorf(ctx, "error reading avro: %v", err)
-		return
+		return fmt.Errorf("reading %v: %w", r.File.Metadata.Path, err)
+	}
+	schema, err := parseSchema(or.header.Schema)
+	if err != nil {
+		return fmt.Errorf("reading %v: %w", r.File.Metadata.Path, err)
 	}
 
-	for ar.Scan() {
-		val := reflect.New(f.Type.T).Interface()
-		var i any
-		i, err = ar.Read()
-		if err != nil {
-			log.Errorf(ctx, "error reading avro row: %v", err)
-			continue
+	if err := or.seekBlock(r.Start); err == io.EOF {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("reading %v: %w", r.File.Metadata.Path, err)
+	}
+	for or.offset < r.End {
+		block, err := or.next()
+		if err == io.EOF {
+			return nil
 		}
-
-		// marshal interface to bytes
-		var b []byte
-		b, err = json.Marshal(i)
 		if err != nil {
-			log.Errorf(ctx, "error unmarshalling avro data: %v", err)
-			return
+			return fmt.Errorf("reading %v: %w", r.File.Metadata.Path, err)
 		}
-
-		switch reflect.New(f.Type.T).Interface().(type) {
-		case *string:
-			emit(string(b))
-		default:
-			if err = json.Unmarshal(b, val); err != nil {
-				log.Errorf(ctx, "error unmashalling avro to type: %v", err)
-				return
+		buf := block.Data
+		for i := int64(0); i < block.Count; i++ {
+			var native any
+			native, buf, err = codec.NativeFromBinary(buf)
+			if err != nil {
+				return fmt.Errorf("decoding record of %v: %w", r.File.Metadata.Path, err)
+			}
+			v, err := f.decode(schema, native)
+			if err != nil {
+				return fmt.Errorf("decoding record of %v: %w", r.File.Metadata.Path, err)
 			}
-			emit(reflect.ValueOf(val).Elem().Interface())
+			emit(v)
 		}
 	}
+	return nil
+}
 
-	return ar.Err()
+// decode converts a native record to the type.
+func (f *avroReadFn) decode(schema *avroType, native any) (any, error) {
+	if f.Type.T.Kind() == reflect.String {
+		b, err := json.Marshal(native)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}
+	v := reflect.New(f.Type.T).Elem()
+	if err := f.conv.fromNative(schema, native, v); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
 }
 
 // Write writes a PCollection<string> to an AVRO file.
 // Write expects a JSON string with a matching AVRO schema.
 // the process will fail if the schema does not match the JSON
 // provided
-func Write(s beam.Scope, filename, schema string, col beam.PCollection) {
+//
+// Write returns a PCollection<string> with the names of the written files.
+// It accepts a variadic number of fileio.WriteOptionFn that can be used to
+// configure how the files are written. By default, a single file is written
+// per window, as described in fileio.WriteFiles.
+func Write(s beam.Scope, filename, schema string, col beam.PCollection, opts ...fileio.WriteOptionFn) beam.PCollection {
 	s = s.Scope("avroio.Write")
-	filesystem.ValidateScheme(filename)
-	pre := beam.AddFixedKey(s, col)
-	post := beam.GroupByKey(s, pre)
-	beam.ParDo0(s, &writeAvroFn{Schema: schema, Filename: filename}, post)
-}
 
-type writeAvroFn struct {
-	Schema   string `json:"schema"`
-	Filename string `json:"filename"`
+	opts = append([]fileio.WriteOptionFn{fileio.WriteNumShards(1)}, opts...)
+	return fileio.WriteFiles(s, filename, &avroSink{Schema: schema}, col, opts...)
 }
 
-func (w *writeAvroFn) ProcessElement(ctx context.Context, _ int, lines func(*string) bool) (err error) {
-	log.Infof(ctx, "writing AVRO to %s", w.Filename)
-	fs, err := filesystem.New(ctx, w.Filename)
-	if err != nil {
-		return
-	}
-	defer fs.Close()
+// WriteTyped writes a PCollection<T> of structs to Avro files, with the
+// schema of SchemaFromType, and returns a PCollection<string> of the names of
+// the written files. The records are written directly, without a JSON
+// encoding, so types such as bytes, decimals and timestamps are preserved.
+// WriteTyped accepts a variadic number of fileio.WriteOptionFn that can be
+// used to configure how the files are written. By default, a single file is
+// written per window, as described in fileio.WriteFiles.
+func WriteTyped(s beam.Scope, filename string, col beam.PCollection, opts ...fileio.WriteOptionFn) beam.PCollection {
+	s = s.Scope("avroio.WriteTyped")
 
-	fd, err := fs.OpenWrite(ctx, w.Filename)
+	t := beam.ValidateNonCompositeType(col).Type()
+	schema, err := SchemaFromType(t)
 	if err != nil {
-		return
+		panic(fmt.Sprintf("avroio.WriteTyped: %v", err))
 	}
+	opts = append([]fileio.WriteOptionFn{fileio.WriteNumShards(1)}, opts...)
+	return fileio.WriteFiles(s, filename, &typedSink{Schema: schema}, col, opts...)
+}
 
-	defer fd.Close()
+// recordsPerBlock is the number of records written to each block of a file.
+const recordsPerBlock = 1000
 
-	codec, err := goavro.NewCodec(w.Schema)
+// ocfWriter writes goavro native records to an object container file in
+// blocks of recordsPerBlock records.
+type ocfWriter struct {
+	codec   *goavro.Codec
+	ocfw    *goavro.OCFWriter
+	pending []any
+}
+
+func (o *ocfWriter) open(w io.Writer, schema string) (err error) {
+	o.codec, err = goavro.NewCodec(schema)
 	if err != nil {
-		log.Errorf(ctx, "error creating avro codec: %v", err)
-		return
+		return fmt.Errorf("creating avro codec: %w", err)
 	}
-
-	ocfw, err := goavro.NewOCFWriter(goavro.OCFConfig{
-		Codec:           codec,
+	o.ocfw, err = goavro.NewOCFWriter(goavro.OCFConfig{
+		Codec:           o.codec,
 		CompressionName: goavro.CompressionSnappyLabel,
-		Schema:          w.Schema,
-		W:               fd,
+		Schema:          schema,
+		W:               w,
 	})
+	if err != nil {
+		return fmt.Errorf("creating avro writer: %w", err)
+	}
+	o.pending = o.pending[:0]
+	return nil
+}
+
+func (o *ocfWriter) append(native any) error {
+	o.pending = append(o.pending, native)
+	if len(o.pending) < recordsPerBlock {
+		return nil
+	}
+	return o.flush()
+}
+
+func (o *ocfWriter) flush() error {
+	if len(o.pending) == 0 {
+		return nil
+	}
+	if err := o.ocfw.Append(o.pending); err != nil {
+		return fmt.Errorf("writing avro: %w", err)
+	}
+	o.pending = o.pending[:0]
+	return nil
+}
+
+// avroSink writes JSON strings to an AVRO file with the schema.
+type avroSink struct {
+	Schema string `json:"schema"`
+
+	w ocfWriter
+}
+
+func (a *avroSink) Open(_ context.Context, w io.Writer) error {
+	return a.w.open(w, a.Schema)
+}
 
+func (a *avroSink) Write(_ context.Context, elm any) error {
+	native, _, err := a.w.codec.NativeFromTextual([]byte(elm.(string)))
 	if err != nil {
-		log.Errorf(ctx, "error creating avro writer: %v", err)
-		return
+		return fmt.Errorf("reading native avro: %w", err)
 	}
+	return a.w.append(native)
+}
 
-	var j string
-	for lines(&j) {
-		native, _, err := codec.NativeFromTextual([]byte(j))
-		if err != nil {
-			log.Errorf(ctx, "error reading native avro: %v", err)
-			return err
-		}
+func (a *avroSink) Flush(_ context.Context) error {
+	return a.w.flush()
+}
 
-		if err := ocfw.Append([]any{native}); err != nil {
-			log.Errorf(ctx, "error writing avro: %v", err)
-			return err
-		}
+// typedSink writes structs to an Avro file with the schema of their type.
+type typedSink struct {
+	Schema string `json:"schema"`
+
+	w      ocfWriter
+	conv   *converter
+	record *avroType
+}
+
+func (a *typedSink) Open(_ context.Context, w io.Writer) error {
+	var err error
+	if a.record, err = parseSchema(a.Schema); err != nil {
+		return err
 	}
+	a.conv = newConverter()
+	return a.w.open(w, a.Schema)
+}
+
+func (a *typedSink) Write(_ context.Context, elm any) error {
+	native, err := a.conv.toNative(a.record, reflect.ValueOf(elm))
+	if err != nil {
+		return fmt.Errorf("converting %T to avro: %w", elm, err)
+	}
+	return a.w.append(native)
+}
 
-	return
+func (a *typedSink) Flush(_ context.Context) error {
+	return a.w.flush()
 }
@@ -19,15 +19,21 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"io"
+	"math/big"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/fileio"
 	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/local"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+	"github.com/google/go-cmp/cmp"
 
 	"github.com/linkedin/goavro/v2"
 )
@@ -41,7 +47,10 @@ func init() {
 	beam.RegisterType(reflect.TypeOf((*NullableFloat64)(nil)).Elem())
 	beam.RegisterType(reflect.TypeOf((*NullableString)(nil)).Elem())
 	beam.RegisterType(reflect.TypeOf((*NullableTweet)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*event)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*numbered)(nil)).Elem())
 	register.Function2x0(toJSONString)
+	register.Function1x1(numberedID)
 }
 
 func toJSONString(user TwitterUser, emit func(string)) {
@@ -189,3 +198,224 @@ func TestWrite(t *testing.T) {
 		t.Fatalf("User.User=%v, want %v", got, want)
 	}
 }
+
+type location struct {
+	Lat float64 `avro:"lat"`
+	Lng float64 `avro:"lng"`
+}
+
+type event struct {
+	ID      int64            `beam:"id"`
+	Kind    int32            `avro:"kind"`
+	Score   float32          `json:"score,omitempty"`
+	Payload []byte           `avro:"payload"`
+	Note    *string          `avro:"note"`
+	Tags    []string         `avro:"tags"`
+	Counts  map[string]int64 `avro:"counts"`
+	Where   location         `avro:"where"`
+	From    *location        `avro:"from"`
+	Ignored string           `avro:"-"`
+	private string
+}
+
+func note(s string) *string {
+	return &s
+}
+
+func TestSchemaFromType(t *testing.T) {
+	type inner struct {
+		N int
+	}
+	type record struct {
+		Amount *big.Rat  `avro:"amount,precision=10,scale=2"`
+		At     time.Time `avro:"at"`
+		Small  uint16    `beam:"small"`
+		A, B   inner
+		C      *inner
+		Skip   bool `avro:"-"`
+	}
+	got, err := SchemaFromType(reflect.TypeOf(record{}))
+	if err != nil {
+		t.Fatalf("SchemaFromType() failed: %v", err)
+	}
+	want := `{"fields":[` +
+		`{"name":"amount","type":{"logicalType":"decimal","precision":10,"scale":2,"type":"bytes"}},` +
+		`{"name":"at","type":{"logicalType":"timestamp-micros","type":"long"}},` +
+		`{"name":"small","type":"int"},` +
+		`{"name":"A","type":{"fields":[{"name":"N","type":"long"}],"name":"inner","type":"record"}},` +
+		`{"name":"B","type":"inner"},` +
+		`{"default":null,"name":"C","type":["null","inner"]}` +
+		`],"name":"record2","type":"record"}`
+	if got != want {
+		t.Errorf("SchemaFromType() = %v, want %v", got, want)
+	}
+	if _, err := goavro.NewCodec(got); err != nil {
+		t.Errorf("SchemaFromType() returned invalid schema: %v", err)
+	}
+
+	for _, typ := range []reflect.Type{
+		reflect.TypeOf(0),
+		reflect.TypeOf(struct{ C chan int }{}),
+		reflect.TypeOf(struct{ M map[int]string }{}),
+		reflect.TypeOf(struct {
+			S string `avro:"not-valid"`
+		}{}),
+	} {
+		if got, err := SchemaFromType(typ); err == nil {
+			t.Errorf("SchemaFromType(%v) = %v, want error", typ, got)
+		}
+	}
+}
+
+func TestConverter_LogicalTypes(t *testing.T) {
+	type record struct {
+		Amount *big.Rat   `avro:"amount,scale=2"`
+		At     time.Time  `avro:"at"`
+		Maybe  *time.Time `avro:"maybe"`
+		Any    any        `avro:"any"`
+	}
+	schema := `{"type":"record","name":"record","fields":[` +
+		`{"name":"amount","type":{"type":"bytes","logicalType":"decimal","precision":38,"scale":2}},` +
+		`{"name":"at","type":{"type":"long","logicalType":"timestamp-micros"}},` +
+		`{"name":"maybe","type":["null",{"type":"long","logicalType":"timestamp-micros"}]},` +
+		`{"name":"any","type":"string"}]}`
+	at, err := parseSchema(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)
+	in := record{Amount: big.NewRat(1234, 100), At: now, Maybe: &now, Any: "x"}
+	c := newConverter()
+	native, err := c.toNative(at, reflect.ValueOf(in))
+	if err != nil {
+		t.Fatalf("toNative() failed: %v", err)
+	}
+	b, err := codec.BinaryFromNative(nil, native)
+	if err != nil {
+		t.Fatalf("BinaryFromNative() failed: %v", err)
+	}
+	native, _, err = codec.NativeFromBinary(b)
+	if err != nil {
+		t.Fatalf("NativeFromBinary() failed: %v", err)
+	}
+	var got record
+	if err := c.fromNative(at, native, reflect.ValueOf(&got).Elem()); err != nil {
+		t.Fatalf("fromNative() failed: %v", err)
+	}
+	if got.Amount.Cmp(in.Amount) != 0 || !got.At.Equal(now) || !got.Maybe.Equal(now) || got.Any != "x" {
+		t.Errorf("fromNative() = %+v, want %+v", got, in)
+	}
+}
+
+func TestWriteTyped(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "events.avro")
+	in := []any{
+		event{
+			ID: 1, Kind: 2, Score: 0.5, Payload: []byte{0, 1}, Note: note("n"),
+			Tags: []string{"a", "b"}, Counts: map[string]int64{"x": 1},
+			Where: location{Lat: 1, Lng: 2}, From: &location{Lat: 3, Lng: 4},
+		},
+		event{ID: 2, Payload: []byte{}, Tags: []string{}, Counts: map[string]int64{}},
+	}
+
+	p, s := beam.NewPipelineWithRoot()
+	WriteTyped(s, out, beam.Create(s, in...))
+	ptest.RunAndValidate(t, p)
+
+	p, s = beam.NewPipelineWithRoot()
+	passert.Equals(s, Read(s, out, reflect.TypeOf(event{})), in...)
+	ptest.RunAndValidate(t, p)
+}
+
+type numbered struct {
+	ID   int    `avro:"id"`
+	Name string `avro:"name"`
+}
+
+func numberedID(n numbered) int {
+	return n.ID
+}
+
+func TestRead_Splits(t *testing.T) {
+	defer func(size int64) { blockSize = size }(blockSize)
+	out := filepath.Join(t.TempDir(), "numbered.avro")
+	const n = 2500
+	var in []any
+	sum := 0
+	for i := 0; i < n; i++ {
+		in = append(in, numbered{ID: i, Name: "name"})
+		sum += i
+	}
+
+	p, s := beam.NewPipelineWithRoot()
+	WriteTyped(s, out, beam.Create(s, in...))
+	ptest.RunAndValidate(t, p)
+
+	// Split the file into ranges of several sizes, including ranges that
+	// contain no block starts.
+	for _, size := range []int64{10, 1000, 5000} {
+		blockSize = size
+		p, s = beam.NewPipelineWithRoot()
+		ids := beam.ParDo(s, numberedID, Read(s, out, reflect.TypeOf(numbered{})))
+		passert.Sum(s, ids, "ids", n, sum)
+		ptest.RunAndValidate(t, p)
+	}
+}
+
+func TestOCFReader_SeekBlock(t *testing.T) {
+	codec, err := goavro.NewCodec(`"long"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, err := goavro.NewOCFWriter(goavro.OCFConfig{W: &buf, Codec: codec, CompressionName: goavro.CompressionDeflateLabel})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < 5; i++ {
+		if err := w.Append([]any{i, i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := buf.Bytes()
+
+	for split := int64(0); split <= int64(len(data)); split++ {
+		var got []any
+		for _, r := range []fileio.FileRange{{Start: 0, End: split}, {Start: split, End: int64(len(data))}} {
+			or, err := newOCFReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := or.seekBlock(r.Start); err != nil {
+				if errors.Is(err, io.EOF) {
+					continue
+				}
+				t.Fatal(err)
+			}
+			for or.offset < r.End {
+				block, err := or.next()
+				if err != nil {
+					if errors.Is(err, io.EOF) {
+						break
+					}
+					t.Fatalf("next() failed: %v", err)
+				}
+				b := block.Data
+				for i := int64(0); i < block.Count; i++ {
+					var v any
+					v, b, _ = codec.NativeFromBinary(b)
+					got = append(got, v)
+				}
+			}
+		}
+		want := []any{int64(0), int64(0), int64(1), int64(1), int64(2), int64(2), int64(3), int64(3), int64(4), int64(4)}
+		if !cmp.Equal(got, want) {
+			t.Errorf("split at %v read %v, want %v", split, got, want)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avroio
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// converter converts between Go values and goavro native values, which are
+// map[string]any for records and maps, []any for arrays, and
+// map[string]any{branch: value} for non-null union values.
+type converter struct {
+	// fields caches the struct field indexes of the fields of records.
+	fields map[recordType][]int
+}
+
+type recordType struct {
+	avro *avroType
+	t    reflect.Type
+}
+
+func newConverter() *converter {
+	return &converter{fields: make(map[recordType][]int)}
+}
+
+// fieldIndexes returns the index of the struct field of each field of the
+// record, or -1 if the struct has no such field. Fields are matched by the
+// names of fieldName, or else case-insensitively.
+func (c *converter) fieldIndexes(at *avroType, t reflect.Type) []int {
+	key := recordType{at, t}
+	if idx, ok := c.fields[key]; ok {
+		return idx
+	}
+	idx := make([]int, len(at.Fields))
+	for i, f := range at.Fields {
+		idx[i] = structField(t, f.Name)
+	}
+	c.fields[key] = idx
+	return idx
+}
+
+// structField returns the index of the struct field with the avro name, or
+// -1 if there is none.
+func structField(t reflect.Type, name string) int {
+	fold := -1
+	for i := 0; i < t.NumField(); i++ {
+		fname, ok := fieldName(t.Field(i))
+		if !ok {
+			continue
+		}
+		if fname == name {
+			return i
+		}
+		if fold < 0 && strings.EqualFold(fname, name) {
+			fold = i
+		}
+	}
+	return fold
+}
+
+// toNative converts the Go value to the goavro native value of the type.
+func (c *converter) toNative(at *avroType, v reflect.Value) (any, error) {
+	if at.Type == "union" {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return nil, nil
+		}
+		var branch *avroType
+		for _, b := range at.Branches {
+			if b.Type != "null" {
+				if branch != nil {
+					return nil, fmt.Errorf("unsupported union with several non-null branches")
+				}
+				branch = b
+			}
+		}
+		if branch == nil {
+			return nil, fmt.Errorf("non-null value %v for null union", v.Type())
+		}
+		n, err := c.toNative(branch, v)
+		if err != nil {
+			return nil, err
+		}
+		return goavro.Union(branch.unionName(), n), nil
+	}
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil, fmt.Errorf("nil %v for non-nullable avro type %v", v.Type(), at.Type)
+	}
+	if v.Type() != ratType && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		return c.toNative(at, v.Elem())
+	}
+
+	switch at.Type {
+	case "record":
+		if v.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("cannot convert %v to avro record", v.Type())
+		}
+		m := make(map[string]any, len(at.Fields))
+		for i, idx := range c.fieldIndexes(at, v.Type()) {
+			f := at.Fields[i]
+			if idx < 0 {
+				return nil, fmt.Errorf("%v has no field for avro field %v", v.Type(), f.Name)
+			}
+			n, err := c.toNative(f.Type, v.Field(idx))
+			if err != nil {
+				return nil, fmt.Errorf("field %v: %w", f.Name, err)
+			}
+			m[f.Name] = n
+		}
+		return m, nil
+	case "array":
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return nil, fmt.Errorf("cannot convert %v to avro array", v.Type())
+		}
+		s := make([]any, v.Len())
+		for i := range s {
+			n, err := c.toNative(at.Items, v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			s[i] = n
+		}
+		return s, nil
+	case "map":
+		if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("cannot convert %v to avro map", v.Type())
+		}
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			n, err := c.toNative(at.Values, iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			m[iter.Key().String()] = n
+		}
+		return m, nil
+	}
+
+	switch k := v.Kind(); {
+	case v.Type() == timeType || v.Type() == ratType:
+		return v.Interface(), nil
+	case k == reflect.Bool:
+		return v.Bool(), nil
+	case k >= reflect.Int && k <= reflect.Int64:
+		if at.Type == "int" {
+			return int32(v.Int()), nil
+		}
+		return v.Int(), nil
+	case k >= reflect.Uint && k <= reflect.Uint64:
+		if at.Type == "int" {
+			return int32(v.Uint()), nil
+		}
+		return int64(v.Uint()), nil
+	case k == reflect.Float32 || k == reflect.Float64:
+		if at.Type == "float" {
+			return float32(v.Float()), nil
+		}
+		return v.Float(), nil
+	case k == reflect.String:
+		return v.String(), nil
+	case v.Type().ConvertibleTo(byteType):
+		return v.Convert(byteType).Bytes(), nil
+	}
+	return nil, fmt.Errorf("cannot convert %v to avro %v", v.Type(), at.Type)
+}
+
+// fromNative sets the Go value v to the goavro native value of the type.
+func (c *converter) fromNative(at *avroType, n any, v reflect.Value) error {
+	if n == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.Kind() == reflect.Interface {
+		v.Set(reflect.ValueOf(n))
+		return nil
+	}
+
+	if at.Type == "union" {
+		m, ok := n.(map[string]any)
+		if !ok || len(m) != 1 {
+			return fmt.Errorf("invalid avro union value %v", n)
+		}
+		for name, bn := range m {
+			b, err := at.branch(name)
+			if err != nil {
+				return err
+			}
+			// Structs with a field named after the branch hold the value in that
+			// field, as in the JSON encoding of unions.
+			if v.Kind() == reflect.Struct && v.Type() != timeType && b.Type != "record" {
+				if i := structField(v.Type(), name); i >= 0 {
+					return c.fromNative(b, bn, v.Field(i))
+				}
+			}
+			return c.fromNative(b, bn, v)
+		}
+	}
+
+	if nt := reflect.TypeOf(n); nt.AssignableTo(v.Type()) && nt != byteType {
+		v.Set(reflect.ValueOf(n))
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		p := reflect.New(v.Type().Elem())
+		if err := c.fromNative(at, n, p.Elem()); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+
+	switch at.Type {
+	case "record":
+		m, ok := n.(map[string]any)
+		if !ok || v.Kind() != reflect.Struct {
+			return fmt.Errorf("cannot convert avro record to %v", v.Type())
+		}
+		for i, idx := range c.fieldIndexes(at, v.Type()) {
+			if idx < 0 {
+				continue
+			}
+			f := at.Fields[i]
+			if err := c.fromNative(f.Type, m[f.Name], v.Field(idx)); err != nil {
+				return fmt.Errorf("field %v: %w", f.Name, err)
+			}
+		}
+		return nil
+	case "array":
+		s, ok := n.([]any)
+		if !ok || v.Kind() != reflect.Slice {
+			return fmt.Errorf("cannot convert avro array to %v", v.Type())
+		}
+		sv := reflect.MakeSlice(v.Type(), len(s), len(s))
+		for i, e := range s {
+			if err := c.fromNative(at.Items, e, sv.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(sv)
+		return nil
+	case "map":
+		m, ok := n.(map[string]any)
+		if !ok || v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("cannot convert avro map to %v", v.Type())
+		}
+		mv := reflect.MakeMapWithSize(v.Type(), len(m))
+		for k, e := range m {
+			ev := reflect.New(v.Type().Elem()).Elem()
+			if err := c.fromNative(at.Values, e, ev); err != nil {
+				return err
+			}
+			mv.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), ev)
+		}
+		v.Set(mv)
+		return nil
+	}
+	return setPrimitive(n, v)
+}
+
+// setPrimitive sets v to a goavro native primitive value.
+func setPrimitive(n any, v reflect.Value) error {
+	k := v.Kind()
+	switch n := n.(type) {
+	case bool:
+		if k == reflect.Bool {
+			v.SetBool(n)
+			return nil
+		}
+	case int32, int64, time.Duration:
+		i := reflect.ValueOf(n).Int()
+		switch {
+		case k >= reflect.Int && k <= reflect.Int64:
+			if v.OverflowInt(i) {
+				return fmt.Errorf("avro value %v overflows %v", i, v.Type())
+			}
+			v.SetInt(i)
+			return nil
+		case k >= reflect.Uint && k <= reflect.Uint64:
+			if i < 0 || v.OverflowUint(uint64(i)) {
+				return fmt.Errorf("avro value %v overflows %v", i, v.Type())
+			}
+			v.SetUint(uint64(i))
+			return nil
+		case k == reflect.Float32 || k == reflect.Float64:
+			v.SetFloat(float64(i))
+			return nil
+		}
+	case float32, float64:
+		f := reflect.ValueOf(n).Float()
+		switch {
+		case k == reflect.Float32 || k == reflect.Float64:
+			v.SetFloat(f)
+			return nil
+		case k >= reflect.Int && k <= reflect.Int64:
+			// As with encoding/json, integral values may be decoded into
+			// integers.
+			if f != math.Trunc(f) || v.OverflowInt(int64(f)) {
+				return fmt.Errorf("avro value %v overflows %v", f, v.Type())
+			}
+			v.SetInt(int64(f))
+			return nil
+		}
+	case string:
+		if k == reflect.String {
+			v.SetString(n)
+			return nil
+		}
+		if v.Type().ConvertibleTo(byteType) && k == reflect.Slice {
+			v.Set(reflect.ValueOf([]byte(n)).Convert(v.Type()))
+			return nil
+		}
+	case []byte:
+		if k == reflect.String {
+			v.SetString(string(n))
+			return nil
+		}
+		if k == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			v.Set(reflect.ValueOf(append([]byte{}, n...)).Convert(v.Type()))
+			return nil
+		}
+		if k == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8 && v.Len() == len(n) {
+			reflect.Copy(v, reflect.ValueOf(n))
+			return nil
+		}
+	case *big.Rat:
+		if k == reflect.Float32 || k == reflect.Float64 {
+			f, _ := n.Float64()
+			v.SetFloat(f)
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot convert avro value of type %T to %v", n, v.Type())
+}
@@ -0,0 +1,244 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avroio
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	ocfMagic    = "Obj\x01"
+	ocfSyncSize = 16
+)
+
+// ocfHeader is the header of an Avro object container file.
+type ocfHeader struct {
+	Schema string
+	Codec  string
+	Sync   [ocfSyncSize]byte
+	// Size is the size in bytes of the header, which is the offset of the
+	// first block.
+	Size int64
+}
+
+// ocfBlock is a block of records of an object container file.
+type ocfBlock struct {
+	Count int64
+	// Data is the uncompressed records of the block.
+	Data []byte
+}
+
+// ocfReader reads the header and blocks of an object container file, and
+// keeps track of the offset in the file.
+type ocfReader struct {
+	r      *bufio.Reader
+	offset int64
+	header ocfHeader
+}
+
+func newOCFReader(r io.Reader) (*ocfReader, error) {
+	or := &ocfReader{r: bufio.NewReader(r)}
+	if err := or.readHeader(); err != nil {
+		return nil, fmt.Errorf("reading avro header: %w", err)
+	}
+	return or, nil
+}
+
+func (r *ocfReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.offset++
+	}
+	return b, err
+}
+
+func (r *ocfReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+// readLong reads a zig-zag encoded long.
+func (r *ocfReader) readLong() (int64, error) {
+	return binary.ReadVarint(r)
+}
+
+func (r *ocfReader) readBytes() ([]byte, error) {
+	n, err := r.readLong()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("invalid length %v at offset %v", n, r.offset)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return b, nil
+}
+
+func (r *ocfReader) readHeader() error {
+	magic := make([]byte, len(ocfMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != ocfMagic {
+		return errors.New("not an avro object container file")
+	}
+	metadata := make(map[string][]byte)
+	for {
+		count, err := r.readLong()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if count == 0 {
+			break
+		}
+		if count < 0 {
+			// A negative count is followed by the size of the map block.
+			count = -count
+			if _, err := r.readLong(); err != nil {
+				return unexpectedEOF(err)
+			}
+		}
+		for i := int64(0); i < count; i++ {
+			key, err := r.readBytes()
+			if err != nil {
+				return err
+			}
+			value, err := r.readBytes()
+			if err != nil {
+				return err
+			}
+			metadata[string(key)] = value
+		}
+	}
+	if _, err := io.ReadFull(r, r.header.Sync[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	schema, ok := metadata["avro.schema"]
+	if !ok {
+		return errors.New("missing avro.schema")
+	}
+	r.header.Schema = string(schema)
+	r.header.Codec = string(metadata["avro.codec"])
+	r.header.Size = r.offset
+	return nil
+}
+
+// seekBlock advances to the start of the first block at or after the offset,
+// which is the end of the first sync marker that ends at or after the offset.
+// It returns io.EOF if there is no such block.
+func (r *ocfReader) seekBlock(offset int64) error {
+	if offset <= r.header.Size {
+		return nil
+	}
+	if skip := offset - ocfSyncSize - r.offset; skip > 0 {
+		n, err := r.r.Discard(int(skip))
+		r.offset += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	for {
+		b, err := r.r.Peek(ocfSyncSize)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(b, r.header.Sync[:]) {
+			r.r.Discard(ocfSyncSize)
+			r.offset += ocfSyncSize
+			return nil
+		}
+		r.r.Discard(1)
+		r.offset++
+	}
+}
+
+// next reads the block at the offset. It returns io.EOF if there are no more
+// blocks.
+func (r *ocfReader) next() (ocfBlock, error) {
+	start := r.offset
+	count, err := r.readLong()
+	if err != nil {
+		return ocfBlock{}, err
+	}
+	data, err := r.readBytes()
+	if err != nil {
+		return ocfBlock{}, fmt.Errorf("reading avro block at offset %v: %w", start, err)
+	}
+	var sync [ocfSyncSize]byte
+	if _, err := io.ReadFull(r, sync[:]); err != nil {
+		return ocfBlock{}, fmt.Errorf("reading avro block at offset %v: %w", start, unexpectedEOF(err))
+	}
+	if sync != r.header.Sync {
+		return ocfBlock{}, fmt.Errorf("invalid sync marker of avro block at offset %v", start)
+	}
+	data, err = decompressBlock(r.header.Codec, data)
+	if err != nil {
+		return ocfBlock{}, fmt.Errorf("decompressing avro block at offset %v: %w", start, err)
+	}
+	return ocfBlock{Count: count, Data: data}, nil
+}
+
+// decompressBlock decompresses the data of a block with the avro.codec of the
+// file.
+func decompressBlock(codec string, data []byte) ([]byte, error) {
+	switch codec {
+	case "", "null":
+		return data, nil
+	case "deflate":
+		return io.ReadAll(flate.NewReader(bytes.NewReader(data)))
+	case "snappy":
+		// Snappy blocks are followed by the big-endian CRC32 of the
+		// uncompressed data.
+		if len(data) < 4 {
+			return nil, errors.New("truncated snappy block")
+		}
+		out, err := snappy.Decode(nil, data[:len(data)-4])
+		if err != nil {
+			return nil, err
+		}
+		if crc32.ChecksumIEEE(out) != binary.BigEndian.Uint32(data[len(data)-4:]) {
+			return nil, errors.New("invalid snappy block checksum")
+		}
+		return out, nil
+	case "zstandard":
+		dec, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer dec.Close()
+		return dec.DecodeAll(data, nil)
+	default:
+		return nil, fmt.Errorf("unsupported avro.codec %q", codec)
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
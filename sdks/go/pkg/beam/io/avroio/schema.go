@@ -0,0 +1,395 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avroio
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	ratType  = reflect.TypeOf((*big.Rat)(nil))
+	byteType = reflect.TypeOf([]byte(nil))
+)
+
+// Default precision and scale of decimals, which are those of BigQuery
+// NUMERIC columns.
+const (
+	defaultPrecision = 38
+	defaultScale     = 9
+)
+
+// avroType is a parsed Avro schema.
+type avroType struct {
+	// Type is a primitive type, or one of record, enum, fixed, array, map and
+	// union.
+	Type string
+	// Name is the full name of records, enums and fixed types.
+	Name        string
+	LogicalType string
+
+	Fields   []avroField // records
+	Items    *avroType   // arrays
+	Values   *avroType   // maps
+	Branches []*avroType // unions
+}
+
+type avroField struct {
+	Name string
+	Type *avroType
+}
+
+var primitiveTypes = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// parseSchema parses an Avro schema in JSON.
+func parseSchema(schema string) (*avroType, error) {
+	var v any
+	if err := json.Unmarshal([]byte(schema), &v); err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %w", err)
+	}
+	p := &schemaParser{named: make(map[string]*avroType)}
+	return p.parse(v, "")
+}
+
+type schemaParser struct {
+	named map[string]*avroType
+}
+
+func (p *schemaParser) parse(v any, namespace string) (*avroType, error) {
+	switch v := v.(type) {
+	case string:
+		if primitiveTypes[v] {
+			return &avroType{Type: v}, nil
+		}
+		if t, ok := p.named[v]; ok {
+			return t, nil
+		}
+		if t, ok := p.named[namespace+"."+v]; ok {
+			return t, nil
+		}
+		return nil, fmt.Errorf("unknown avro type %q", v)
+	case []any:
+		t := &avroType{Type: "union"}
+		for _, b := range v {
+			bt, err := p.parse(b, namespace)
+			if err != nil {
+				return nil, err
+			}
+			t.Branches = append(t.Branches, bt)
+		}
+		return t, nil
+	case map[string]any:
+		return p.parseObject(v, namespace)
+	default:
+		return nil, fmt.Errorf("invalid avro type %v", v)
+	}
+}
+
+func (p *schemaParser) parseObject(v map[string]any, namespace string) (*avroType, error) {
+	logicalType, _ := v["logicalType"].(string)
+	typ, ok := v["type"].(string)
+	if !ok {
+		// A nested type, such as {"type": {"type": "array", ...}}.
+		return p.parse(v["type"], namespace)
+	}
+
+	switch typ {
+	case "record", "error", "enum", "fixed":
+		name, _ := v["name"].(string)
+		if ns, ok := v["namespace"].(string); ok && !strings.Contains(name, ".") {
+			namespace = ns
+		}
+		if !strings.Contains(name, ".") && namespace != "" {
+			name = namespace + "." + name
+		}
+		t := &avroType{Type: typ, Name: name, LogicalType: logicalType}
+		if typ == "error" {
+			t.Type = "record"
+		}
+		p.named[name] = t
+		if t.Type != "record" {
+			return t, nil
+		}
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			namespace = name[:i]
+		}
+		fields, _ := v["fields"].([]any)
+		for _, f := range fields {
+			fm, ok := f.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("invalid field %v of record %v", f, name)
+			}
+			fname, _ := fm["name"].(string)
+			ft, err := p.parse(fm["type"], namespace)
+			if err != nil {
+				return nil, fmt.Errorf("field %v of record %v: %w", fname, name, err)
+			}
+			t.Fields = append(t.Fields, avroField{Name: fname, Type: ft})
+		}
+		return t, nil
+	case "array":
+		items, err := p.parse(v["items"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &avroType{Type: typ, Items: items}, nil
+	case "map":
+		values, err := p.parse(v["values"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &avroType{Type: typ, Values: values}, nil
+	default:
+		t, err := p.parse(typ, namespace)
+		if err != nil {
+			return nil, err
+		}
+		if logicalType == "" {
+			return t, nil
+		}
+		lt := *t
+		lt.LogicalType = logicalType
+		return &lt, nil
+	}
+}
+
+// unionName returns the name of a union branch of the type in goavro native
+// values, such as "long.timestamp-micros".
+func (t *avroType) unionName() string {
+	switch t.Type {
+	case "record", "enum", "fixed":
+		return t.Name
+	}
+	switch name := t.Type + "." + t.LogicalType; name {
+	case "long.timestamp-millis", "long.timestamp-micros", "int.time-millis", "long.time-micros", "int.date", "bytes.decimal":
+		return name
+	}
+	return t.Type
+}
+
+// branch returns the union branch with the goavro name. Nullable types, with
+// a single branch other than null, are returned for any name.
+func (t *avroType) branch(name string) (*avroType, error) {
+	var nonNull []*avroType
+	for _, b := range t.Branches {
+		if b.unionName() == name {
+			return b, nil
+		}
+		if b.Type != "null" {
+			nonNull = append(nonNull, b)
+		}
+	}
+	if len(nonNull) == 1 {
+		return nonNull[0], nil
+	}
+	return nil, fmt.Errorf("unknown union branch %q", name)
+}
+
+// fieldName returns the Avro field name of a struct field, which is the
+// first name given by its avro, beam or json tags, or else its name.
+func fieldName(sf reflect.StructField) (string, bool) {
+	if !sf.IsExported() {
+		return "", false
+	}
+	for _, key := range []string{"avro", "beam", "json"} {
+		tag, ok := sf.Tag.Lookup(key)
+		if !ok {
+			continue
+		}
+		if tag == "-" {
+			return "", false
+		}
+		if name, _, _ := strings.Cut(tag, ","); name != "" {
+			return name, true
+		}
+	}
+	return sf.Name, true
+}
+
+// fieldOption returns the value of an option of the avro tag of a struct
+// field, such as 10 for precision in `avro:"price,precision=10"`.
+func fieldOption(sf reflect.StructField, option string) (string, bool) {
+	opts := strings.Split(sf.Tag.Get("avro"), ",")
+	for _, opt := range opts[1:] {
+		if k, v, ok := strings.Cut(opt, "="); ok && k == option {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+var validName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SchemaFromType returns the Avro schema of records of the struct type t, as
+// written by WriteTyped.
+//
+// Fields are named by their avro tags, or else their beam tags as for Beam
+// schemas, or else their json tags, or else their names. Fields tagged with
+// "-" and unexported fields are skipped. Go types map to Avro types as
+// follows:
+//
+//	bool                               boolean
+//	int8, int16, int32, uint8, uint16  int
+//	int, int64, uint32                 long
+//	float32, float64                   float, double
+//	string, []byte                     string, bytes
+//	time.Time                          long with logicalType timestamp-micros
+//	*big.Rat                           bytes with logicalType decimal
+//	pointers                           union of null and the pointed type
+//	slices, map[string]T, structs      array, map, record
+//
+// Decimals have a precision of 38 and a scale of 9 by default, which can be
+// set with options of the avro tag, such as `avro:"price,precision=10,scale=2"`.
+func SchemaFromType(t reflect.Type) (string, error) {
+	if t.Kind() != reflect.Struct {
+		return "", fmt.Errorf("avroio: type must be a struct, got %v", t)
+	}
+	g := &schemaGen{records: make(map[reflect.Type]string), names: make(map[string]bool)}
+	// Type names are reserved, since goavro can't refer to records with such
+	// names.
+	for name := range primitiveTypes {
+		g.names[name] = true
+	}
+	for _, name := range []string{"record", "error", "enum", "fixed", "array", "map", "union"} {
+		g.names[name] = true
+	}
+	s, err := g.schema(t, reflect.StructField{})
+	if err != nil {
+		return "", fmt.Errorf("avroio: %v: %w", t, err)
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+type schemaGen struct {
+	// records are the names of the records of the struct types that have been
+	// defined, which are referred to by name afterwards.
+	records map[reflect.Type]string
+	names   map[string]bool
+}
+
+// schema returns the JSON value of the schema of t, for the struct field sf.
+func (g *schemaGen) schema(t reflect.Type, sf reflect.StructField) (any, error) {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "long", "logicalType": "timestamp-micros"}, nil
+	case t == ratType:
+		precision, scale := defaultPrecision, defaultScale
+		for opt, p := range map[string]*int{"precision": &precision, "scale": &scale} {
+			if v, ok := fieldOption(sf, opt); ok {
+				n, err := strconv.Atoi(v)
+				if err != nil {
+					return nil, fmt.Errorf("invalid %v %q of field %v", opt, v, sf.Name)
+				}
+				*p = n
+			}
+		}
+		return map[string]any{"type": "bytes", "logicalType": "decimal", "precision": precision, "scale": scale}, nil
+	case t == byteType:
+		return "bytes", nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean", nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return "int", nil
+	case reflect.Int, reflect.Int64, reflect.Uint32:
+		return "long", nil
+	case reflect.Float32:
+		return "float", nil
+	case reflect.Float64:
+		return "double", nil
+	case reflect.String:
+		return "string", nil
+	case reflect.Ptr:
+		elem, err := g.schema(t.Elem(), sf)
+		if err != nil {
+			return nil, err
+		}
+		return []any{"null", elem}, nil
+	case reflect.Slice:
+		items, err := g.schema(t.Elem(), sf)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %v", t.Key())
+		}
+		values, err := g.schema(t.Elem(), sf)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "map", "values": values}, nil
+	case reflect.Struct:
+		return g.record(t)
+	default:
+		return nil, fmt.Errorf("unsupported type %v", t)
+	}
+}
+
+func (g *schemaGen) record(t reflect.Type) (any, error) {
+	if name, ok := g.records[t]; ok {
+		return name, nil
+	}
+	base := validName.FindString(t.Name())
+	if base == "" {
+		base = "Record"
+	}
+	name := base
+	for i := 2; g.names[name]; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	g.names[name] = true
+	g.records[t] = name
+
+	fields := []any{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fname, ok := fieldName(sf)
+		if !ok {
+			continue
+		}
+		if !validName.MatchString(fname) {
+			return nil, fmt.Errorf("invalid avro name %q of field %v", fname, sf.Name)
+		}
+		ft, err := g.schema(sf.Type, sf)
+		if err != nil {
+			return nil, fmt.Errorf("field %v: %w", sf.Name, err)
+		}
+		field := map[string]any{"name": fname, "type": ft}
+		if _, ok := ft.([]any); ok {
+			field["default"] = nil
+		}
+		fields = append(fields, field)
+	}
+	return map[string]any{"type": "record", "name": name, "fields": fields}, nil
+}
@@ -0,0 +1,409 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigqueryio
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"cloud.google.com/go/civil"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/linkedin/goavro/v2"
+)
+
+// rowDecoder decodes the rows of a ReadRows response into values of the
+// element type.
+type rowDecoder interface {
+	decode(resp *storagepb.ReadRowsResponse) ([]any, error)
+}
+
+func newRowDecoder(format DataFormat, schema []byte, t reflect.Type) (rowDecoder, error) {
+	switch format {
+	case Arrow:
+		return &arrowDecoder{schema: schema, t: t}, nil
+	case Avro:
+		codec, err := goavro.NewCodec(string(schema))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid Avro schema")
+		}
+		var s any
+		if err := json.Unmarshal(schema, &s); err != nil {
+			return nil, errors.Wrap(err, "invalid Avro schema")
+		}
+		return &avroDecoder{codec: codec, schema: s, t: t}, nil
+	default:
+		return nil, errors.Errorf("unsupported data format: %v", format)
+	}
+}
+
+// avroDecoder decodes Avro binary rows, using the schema to unwrap nullable
+// unions and to convert BigQuery specific logical types.
+type avroDecoder struct {
+	codec  *goavro.Codec
+	schema any
+	t      reflect.Type
+}
+
+func (d *avroDecoder) decode(resp *storagepb.ReadRowsResponse) ([]any, error) {
+	buf := resp.GetAvroRows().GetSerializedBinaryRows()
+	vals := make([]any, 0, resp.GetRowCount())
+	for len(buf) > 0 {
+		native, rest, err := d.codec.NativeFromBinary(buf)
+		if err != nil {
+			return nil, err
+		}
+		buf = rest
+
+		row, err := normalizeAvro(d.schema, native)
+		if err != nil {
+			return nil, err
+		}
+		val, err := loadRow(d.t, row)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, val)
+	}
+	return vals, nil
+}
+
+// normalizeAvro converts a value decoded by goavro into the value types
+// shared by both data formats.
+func normalizeAvro(schema, v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch s := schema.(type) {
+	case []any:
+		// BigQuery only uses unions for nullable columns, i.e. ["null", T].
+		u, ok := v.(map[string]any)
+		if !ok || len(u) != 1 {
+			return nil, errors.Errorf("invalid union value %v", v)
+		}
+		for _, branch := range s {
+			if branch == "null" {
+				continue
+			}
+			for _, val := range u {
+				return normalizeAvro(branch, val)
+			}
+		}
+		return nil, errors.Errorf("union %v has no non-null branch", s)
+	case map[string]any:
+		switch s["logicalType"] {
+		case "date":
+			if t, ok := v.(time.Time); ok {
+				return civil.DateOf(t), nil
+			}
+		case "time-micros":
+			if d, ok := v.(time.Duration); ok {
+				return civil.TimeOf(time.Time{}.Add(d)), nil
+			}
+		case "datetime":
+			if str, ok := v.(string); ok {
+				return civil.ParseDateTime(str)
+			}
+		}
+		switch s["type"] {
+		case "record":
+			fields, _ := s["fields"].([]any)
+			rec, ok := v.(map[string]any)
+			if !ok {
+				return nil, errors.Errorf("invalid record value %v", v)
+			}
+			row := make(map[string]any, len(rec))
+			for _, f := range fields {
+				field, _ := f.(map[string]any)
+				name, _ := field["name"].(string)
+				val, err := normalizeAvro(field["type"], rec[name])
+				if err != nil {
+					return nil, errors.Wrapf(err, "field %v", name)
+				}
+				row[name] = val
+			}
+			return row, nil
+		case "array":
+			items, ok := v.([]any)
+			if !ok {
+				return nil, errors.Errorf("invalid array value %v", v)
+			}
+			list := make([]any, len(items))
+			for i, item := range items {
+				val, err := normalizeAvro(s["items"], item)
+				if err != nil {
+					return nil, err
+				}
+				list[i] = val
+			}
+			return list, nil
+		}
+		if t, ok := s["type"]; ok {
+			return normalizeAvro(t, v)
+		}
+	}
+	return v, nil
+}
+
+// arrowDecoder decodes serialized Arrow record batches, prefixed with the
+// serialized schema of the read session.
+type arrowDecoder struct {
+	schema []byte
+	t      reflect.Type
+}
+
+func (d *arrowDecoder) decode(resp *storagepb.ReadRowsResponse) ([]any, error) {
+	var buf bytes.Buffer
+	buf.Write(d.schema)
+	buf.Write(resp.GetArrowRecordBatch().GetSerializedRecordBatch())
+
+	r, err := ipc.NewReader(&buf)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Release()
+
+	vals := make([]any, 0, resp.GetRowCount())
+	for r.Next() {
+		rec := r.Record()
+		fields := rec.Schema().Fields()
+		for i := 0; i < int(rec.NumRows()); i++ {
+			row := make(map[string]any, len(fields))
+			for j, field := range fields {
+				val, err := arrowValue(rec.Column(j), i)
+				if err != nil {
+					return nil, errors.Wrapf(err, "field %v", field.Name)
+				}
+				row[field.Name] = val
+			}
+			val, err := loadRow(d.t, row)
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, val)
+		}
+	}
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+// arrowValue returns the i'th value of the given column as one of the value
+// types shared by both data formats.
+func arrowValue(col arrow.Array, i int) (any, error) {
+	if col.IsNull(i) {
+		return nil, nil
+	}
+	switch c := col.(type) {
+	case *array.Boolean:
+		return c.Value(i), nil
+	case *array.Int64:
+		return c.Value(i), nil
+	case *array.Float64:
+		return c.Value(i), nil
+	case *array.String:
+		return c.Value(i), nil
+	case *array.Binary:
+		return bytes.Clone(c.Value(i)), nil
+	case *array.Date32:
+		return civil.DateOf(c.Value(i).ToTime()), nil
+	case *array.Time64:
+		unit := c.DataType().(*arrow.Time64Type).Unit
+		return civil.TimeOf(c.Value(i).ToTime(unit)), nil
+	case *array.Timestamp:
+		typ := c.DataType().(*arrow.TimestampType)
+		ts := c.Value(i).ToTime(typ.Unit)
+		if typ.TimeZone == "" {
+			// Timestamps without a time zone hold DATETIME columns.
+			return civil.DateTimeOf(ts), nil
+		}
+		return ts, nil
+	case *array.Decimal128:
+		scale := c.DataType().(*arrow.Decimal128Type).Scale
+		return decimalRat(c.Value(i).BigInt(), scale), nil
+	case *array.Decimal256:
+		scale := c.DataType().(*arrow.Decimal256Type).Scale
+		return decimalRat(c.Value(i).BigInt(), scale), nil
+	case *array.Struct:
+		fields := c.DataType().(*arrow.StructType).Fields()
+		row := make(map[string]any, len(fields))
+		for j, field := range fields {
+			val, err := arrowValue(c.Field(j), i)
+			if err != nil {
+				return nil, errors.Wrapf(err, "field %v", field.Name)
+			}
+			row[field.Name] = val
+		}
+		return row, nil
+	case *array.List:
+		start, end := c.ValueOffsets(i)
+		list := make([]any, 0, end-start)
+		for j := start; j < end; j++ {
+			val, err := arrowValue(c.ListValues(), int(j))
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, val)
+		}
+		return list, nil
+	default:
+		return nil, errors.Errorf("unsupported Arrow type %v", col.DataType())
+	}
+}
+
+func decimalRat(unscaled *big.Int, scale int32) *big.Rat {
+	denom := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	return new(big.Rat).SetFrac(unscaled, denom)
+}
+
+// loadRow creates a value of type t from a decoded row.
+func loadRow(t reflect.Type, row any) (any, error) {
+	v := reflect.New(t).Elem()
+	if err := assignValue(v, row); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+var (
+	bigRatType = reflect.TypeOf((*big.Rat)(nil))
+	timeType   = reflect.TypeOf(time.Time{})
+)
+
+// assignValue sets dst to the decoded value v. Columns map to struct fields
+// by their bigquery tag or field name, and BigQuery's Null* types are set as
+// valid when the column is not NULL.
+func assignValue(dst reflect.Value, v any) error {
+	if v == nil {
+		return nil
+	}
+	t := dst.Type()
+	switch {
+	case t == bigRatType || t == timeType:
+	case t.Kind() == reflect.Ptr:
+		ptr := reflect.New(t.Elem())
+		if err := assignValue(ptr.Elem(), v); err != nil {
+			return err
+		}
+		dst.Set(ptr)
+		return nil
+	case isNullType(t):
+		if err := assignValue(dst.Field(0), v); err != nil {
+			return err
+		}
+		dst.FieldByName("Valid").SetBool(true)
+		return nil
+	case t.Kind() == reflect.Struct:
+		row, ok := v.(map[string]any)
+		if !ok {
+			break
+		}
+		// Column names are case-insensitive, as they are when reading with
+		// queries.
+		fields := make(map[string]int, t.NumField())
+		for name, idx := range fieldIndices(t) {
+			fields[strings.ToLower(name)] = idx
+		}
+		for column, value := range row {
+			idx, ok := fields[strings.ToLower(column)]
+			if !ok {
+				continue
+			}
+			if err := assignValue(dst.Field(idx), value); err != nil {
+				return errors.Wrapf(err, "field %v", t.Field(idx).Name)
+			}
+		}
+		return nil
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8:
+		list, ok := v.([]any)
+		if !ok {
+			break
+		}
+		s := reflect.MakeSlice(t, len(list), len(list))
+		for i, item := range list {
+			if err := assignValue(s.Index(i), item); err != nil {
+				return err
+			}
+		}
+		dst.Set(s)
+		return nil
+	}
+
+	src := reflect.ValueOf(v)
+	switch {
+	case src.Type().AssignableTo(t):
+		dst.Set(src)
+	case isNumber(src.Kind()) && isNumber(t.Kind()),
+		src.Kind() == reflect.String && t.Kind() == reflect.String:
+		dst.Set(src.Convert(t))
+	default:
+		return errors.Errorf("cannot assign value of type %T to %v", v, t)
+	}
+	return nil
+}
+
+// isNullType returns true for the nullable wrapper types of the bigquery
+// package, such as bigquery.NullInt64.
+func isNullType(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t.PkgPath() != "cloud.google.com/go/bigquery" {
+		return false
+	}
+	_, ok := t.FieldByName("Valid")
+	return strings.HasPrefix(t.Name(), "Null") && ok && t.NumField() == 2
+}
+
+func isNumber(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// fieldIndices maps column names to the indices of the exported fields of t,
+// following the same bigquery tag rules as schema inference.
+func fieldIndices(t reflect.Type) map[string]int {
+	indices := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup(bigQueryTag); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		indices[name] = i
+	}
+	return indices
+}
@@ -0,0 +1,258 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigqueryio
+
+import (
+	"math/big"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"cloud.google.com/go/bigquery/storage/managedwriter/adapt"
+	"cloud.google.com/go/civil"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+var (
+	civilDateType     = reflect.TypeOf(civil.Date{})
+	civilTimeType     = reflect.TypeOf(civil.Time{})
+	civilDateTimeType = reflect.TypeOf(civil.DateTime{})
+)
+
+// unixEpoch is the date from which DATE columns count days.
+var unixEpoch = civil.Date{Year: 1970, Month: time.January, Day: 1}
+
+// rowEncoder encodes values of the element type as protocol buffer messages
+// matching a table schema, as expected by the Storage Write API.
+type rowEncoder struct {
+	schema     *storagepb.TableSchema
+	md         protoreflect.MessageDescriptor
+	descriptor *descriptorpb.DescriptorProto
+}
+
+func newRowEncoder(schema *storagepb.TableSchema) (*rowEncoder, error) {
+	d, err := adapt.StorageSchemaToProto2Descriptor(schema, "root")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to convert table schema")
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, errors.Errorf("table schema converted to %T, want a message descriptor", d)
+	}
+	dp, err := adapt.NormalizeDescriptor(md)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to normalize table schema descriptor")
+	}
+	return &rowEncoder{schema: schema, md: md, descriptor: dp}, nil
+}
+
+// encode serializes the given struct value. Struct fields map to columns by
+// their bigquery tag or field name, ignoring case, and every non-ignored
+// field must have a matching column.
+func (e *rowEncoder) encode(v reflect.Value) ([]byte, error) {
+	msg := dynamicpb.NewMessage(e.md)
+	if err := setMessage(msg, e.schema.GetFields(), v); err != nil {
+		return nil, err
+	}
+	return proto.Marshal(msg)
+}
+
+func setMessage(msg protoreflect.Message, fields []*storagepb.TableFieldSchema, v reflect.Value) error {
+	v = reflect.Indirect(v)
+	if v.Kind() != reflect.Struct {
+		return errors.Errorf("cannot encode %v as a record", v.Type())
+	}
+
+	columns := make(map[string]int, len(fields))
+	for i, f := range fields {
+		columns[strings.ToLower(f.GetName())] = i
+	}
+	for name, idx := range fieldIndices(v.Type()) {
+		i, ok := columns[strings.ToLower(name)]
+		if !ok {
+			return errors.Errorf("column %v is not in the table schema", name)
+		}
+		field := fields[i]
+		fd := msg.Descriptor().Fields().ByNumber(protoreflect.FieldNumber(i + 1))
+		if err := setField(msg, fd, field, v.Field(idx)); err != nil {
+			return errors.Wrapf(err, "column %v", field.GetName())
+		}
+	}
+	return nil
+}
+
+func setField(msg protoreflect.Message, fd protoreflect.FieldDescriptor, field *storagepb.TableFieldSchema, v reflect.Value) error {
+	v, ok := unwrapNull(v)
+	if !ok {
+		return nil
+	}
+
+	if field.GetMode() == storagepb.TableFieldSchema_REPEATED {
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return errors.Errorf("repeated column requires a slice, got %v", v.Type())
+		}
+		list := msg.Mutable(fd).List()
+		for i := 0; i < v.Len(); i++ {
+			if field.GetType() == storagepb.TableFieldSchema_STRUCT {
+				elem := list.NewElement()
+				if err := setMessage(elem.Message(), field.GetFields(), v.Index(i)); err != nil {
+					return err
+				}
+				list.Append(elem)
+				continue
+			}
+			val, err := scalarValue(field.GetType(), v.Index(i))
+			if err != nil {
+				return err
+			}
+			list.Append(val)
+		}
+		return nil
+	}
+
+	if field.GetType() == storagepb.TableFieldSchema_STRUCT {
+		return setMessage(msg.Mutable(fd).Message(), field.GetFields(), v)
+	}
+	val, err := scalarValue(field.GetType(), v)
+	if err != nil {
+		return err
+	}
+	msg.Set(fd, val)
+	return nil
+}
+
+// unwrapNull dereferences pointers and unwraps the bigquery Null* types. It
+// returns false if the value is NULL.
+func unwrapNull(v reflect.Value) (reflect.Value, bool) {
+	switch {
+	case v.Type() == bigRatType:
+		return v, !v.IsNil()
+	case v.Kind() == reflect.Ptr:
+		if v.IsNil() {
+			return v, false
+		}
+		return unwrapNull(v.Elem())
+	case isNullType(v.Type()):
+		if !v.FieldByName("Valid").Bool() {
+			return v, false
+		}
+		return v.Field(0), true
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8:
+		return v, !v.IsNil()
+	}
+	return v, true
+}
+
+// scalarValue converts v to the protocol buffer representation of a column
+// of the given type.
+func scalarValue(typ storagepb.TableFieldSchema_Type, v reflect.Value) (protoreflect.Value, error) {
+	v, _ = unwrapNull(v)
+	switch typ {
+	case storagepb.TableFieldSchema_INT64:
+		switch {
+		case v.CanInt():
+			return protoreflect.ValueOfInt64(v.Int()), nil
+		case v.CanUint():
+			return protoreflect.ValueOfInt64(int64(v.Uint())), nil
+		}
+	case storagepb.TableFieldSchema_DOUBLE:
+		if v.CanFloat() {
+			return protoreflect.ValueOfFloat64(v.Float()), nil
+		}
+	case storagepb.TableFieldSchema_BOOL:
+		if v.Kind() == reflect.Bool {
+			return protoreflect.ValueOfBool(v.Bool()), nil
+		}
+	case storagepb.TableFieldSchema_STRING, storagepb.TableFieldSchema_GEOGRAPHY, storagepb.TableFieldSchema_JSON:
+		if v.Kind() == reflect.String {
+			return protoreflect.ValueOfString(v.String()), nil
+		}
+	case storagepb.TableFieldSchema_BYTES:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return protoreflect.ValueOfBytes(v.Bytes()), nil
+		}
+	case storagepb.TableFieldSchema_TIMESTAMP:
+		if v.Type() == timeType {
+			return protoreflect.ValueOfInt64(v.Interface().(time.Time).UnixMicro()), nil
+		}
+	case storagepb.TableFieldSchema_DATE:
+		if v.Type() == civilDateType {
+			return protoreflect.ValueOfInt32(int32(v.Interface().(civil.Date).DaysSince(unixEpoch))), nil
+		}
+	case storagepb.TableFieldSchema_TIME:
+		if v.Type() == civilTimeType {
+			return protoreflect.ValueOfInt64(packTime(v.Interface().(civil.Time))), nil
+		}
+	case storagepb.TableFieldSchema_DATETIME:
+		if v.Type() == civilDateTimeType {
+			return protoreflect.ValueOfInt64(packDateTime(v.Interface().(civil.DateTime))), nil
+		}
+	case storagepb.TableFieldSchema_NUMERIC:
+		if v.Type() == bigRatType {
+			return protoreflect.ValueOfBytes(packDecimal(v.Interface().(*big.Rat), 9)), nil
+		}
+	case storagepb.TableFieldSchema_BIGNUMERIC:
+		if v.Type() == bigRatType {
+			return protoreflect.ValueOfBytes(packDecimal(v.Interface().(*big.Rat), 38)), nil
+		}
+	default:
+		return protoreflect.Value{}, errors.Errorf("unsupported column type %v", typ)
+	}
+	return protoreflect.Value{}, errors.Errorf("cannot encode %v as %v", v.Type(), typ)
+}
+
+// packTime encodes a TIME value as bit fields: hour, minute, second and
+// microseconds.
+func packTime(t civil.Time) int64 {
+	secs := int64(t.Hour)<<12 | int64(t.Minute)<<6 | int64(t.Second)
+	return secs<<20 | int64(t.Nanosecond/1000)
+}
+
+// packDateTime encodes a DATETIME value as bit fields: year, month, day,
+// hour, minute, second and microseconds.
+func packDateTime(dt civil.DateTime) int64 {
+	secs := int64(dt.Date.Year)<<26 | int64(dt.Date.Month)<<22 | int64(dt.Date.Day)<<17 |
+		int64(dt.Time.Hour)<<12 | int64(dt.Time.Minute)<<6 | int64(dt.Time.Second)
+	return secs<<20 | int64(dt.Time.Nanosecond/1000)
+}
+
+// packDecimal encodes r, scaled by 10^scale, as a little-endian two's
+// complement integer. Digits beyond the scale are truncated.
+func packDecimal(r *big.Rat, scale int64) []byte {
+	n := new(big.Int).Exp(big.NewInt(10), big.NewInt(scale), nil)
+	n.Mul(n, r.Num())
+	n.Quo(n, r.Denom())
+
+	var b []byte
+	if n.Sign() >= 0 {
+		b = n.Bytes()
+		if len(b) == 0 || b[0]&0x80 != 0 {
+			b = append([]byte{0}, b...)
+		}
+	} else {
+		size := n.BitLen()/8 + 1
+		m := new(big.Int).Lsh(big.NewInt(1), uint(8*size))
+		b = m.Add(m, n).Bytes()
+	}
+	slices.Reverse(b)
+	return b
+}
@@ -0,0 +1,409 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigqueryio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+
+	bqstorage "cloud.google.com/go/bigquery/storage/apiv1"
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/util/structx"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*createReadSessionFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*readStorageStreamFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*storageReadStream)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*storageStreamRestriction)(nil)).Elem())
+}
+
+// DataFormat is the wire format in which the Storage Read API returns rows.
+type DataFormat string
+
+const (
+	// Avro requests rows as Avro binary records.
+	Avro DataFormat = "AVRO"
+	// Arrow requests rows as Arrow record batches.
+	Arrow DataFormat = "ARROW"
+)
+
+// storageReadOptions represents additional options for reading a table with
+// the BigQuery Storage Read API.
+type storageReadOptions struct {
+	// SelectedFields are the columns to read. If empty, the columns are
+	// inferred from the element type.
+	SelectedFields []string `json:"selectedFields,omitempty"`
+	// RowRestriction is a SQL filter evaluated by the server.
+	RowRestriction string `json:"rowRestriction,omitempty"`
+	// DataFormat is the format in which rows are transferred.
+	DataFormat DataFormat `json:"dataFormat"`
+	// MaxStreams bounds the number of streams in the read session. If zero,
+	// the server picks a value based on the size of the table.
+	MaxStreams int `json:"maxStreams,omitempty"`
+}
+
+// newStorageReadOptions creates a new instance of storageReadOptions.
+// Avro is set as the default data format.
+func newStorageReadOptions() storageReadOptions {
+	return storageReadOptions{DataFormat: Avro}
+}
+
+// StorageReadOption represents a function that sets options for reading a
+// table with the BigQuery Storage Read API.
+type StorageReadOption func(*storageReadOptions) error
+
+// WithSelectedFields restricts the read to the given columns. Nested columns
+// may be selected with dot notation, e.g. "address.city". By default the
+// columns are inferred from the element type.
+func WithSelectedFields(fields ...string) StorageReadOption {
+	return func(o *storageReadOptions) error {
+		if len(fields) == 0 {
+			return errors.New("selected fields must not be empty")
+		}
+		o.SelectedFields = fields
+		return nil
+	}
+}
+
+// WithRowRestriction sets a SQL filter, e.g. "num_rows > 10 AND state = 'WA'",
+// that the server applies before returning rows.
+func WithRowRestriction(restriction string) StorageReadOption {
+	return func(o *storageReadOptions) error {
+		o.RowRestriction = restriction
+		return nil
+	}
+}
+
+// WithDataFormat sets the format in which rows are transferred from the
+// server. Avro is used by default.
+func WithDataFormat(format DataFormat) StorageReadOption {
+	return func(o *storageReadOptions) error {
+		switch format {
+		case Avro, Arrow:
+			o.DataFormat = format
+			return nil
+		default:
+			return errors.Errorf("unsupported data format: %v", format)
+		}
+	}
+}
+
+// WithMaxStreams bounds the number of streams the read session is split into
+// up front. Streams may still be split further while the pipeline runs.
+func WithMaxStreams(n int) StorageReadOption {
+	return func(o *storageReadOptions) error {
+		if n < 0 {
+			return errors.Errorf("max streams must be non-negative, got %v", n)
+		}
+		o.MaxStreams = n
+		return nil
+	}
+}
+
+// ReadStorage reads rows from the given table with the BigQuery Storage Read
+// API. The table must have a schema compatible with the given type, t, and
+// ReadStorage returns a PCollection<t>. Unlike Read, the table is read in
+// parallel: a read session is created at pipeline execution time and each of
+// its streams is read as a splittable restriction, so large tables are spread
+// across workers and can be rebalanced while they are read.
+//
+// The columns to read default to the fields of t and are pushed down to the
+// server, as is the optional row restriction:
+//
+//	rows := bigqueryio.ReadStorage(s, project, "project:dataset.table", reflect.TypeOf(Row{}),
+//		bigqueryio.WithRowRestriction("state = 'WA'"),
+//		bigqueryio.WithDataFormat(bigqueryio.Arrow))
+func ReadStorage(s beam.Scope, project, table string, t reflect.Type, options ...StorageReadOption) beam.PCollection {
+	mustInferSchema(t)
+	qn := mustParseTable(table)
+
+	s = s.Scope("bigquery.ReadStorage")
+
+	opts := newStorageReadOptions()
+	for _, opt := range options {
+		if err := opt(&opts); err != nil {
+			panic(err)
+		}
+	}
+	if len(opts.SelectedFields) == 0 {
+		opts.SelectedFields = structx.InferFieldNames(t, bigQueryTag)
+		if len(opts.SelectedFields) == 0 {
+			panic(fmt.Sprintf("bigqueryio.ReadStorage: type %v has no columns to select", t))
+		}
+	}
+
+	imp := beam.Impulse(s)
+	streams := beam.ParDo(s, &createReadSessionFn{Project: project, Table: qn, Options: opts}, imp)
+	streams = beam.Reshuffle(s, streams)
+	return beam.ParDo(s, &readStorageStreamFn{Type: beam.EncodedType{T: t}}, streams, beam.TypeDefinition{Var: beam.XType, T: t})
+}
+
+// storageReadClient is the subset of the BigQuery Storage Read API client
+// used by the read transforms.
+type storageReadClient interface {
+	CreateReadSession(ctx context.Context, req *storagepb.CreateReadSessionRequest, opts ...gax.CallOption) (*storagepb.ReadSession, error)
+	ReadRows(ctx context.Context, req *storagepb.ReadRowsRequest, opts ...gax.CallOption) (storagepb.BigQueryRead_ReadRowsClient, error)
+	SplitReadStream(ctx context.Context, req *storagepb.SplitReadStreamRequest, opts ...gax.CallOption) (*storagepb.SplitReadStreamResponse, error)
+	Close() error
+}
+
+func newStorageReadClient(ctx context.Context) (storageReadClient, error) {
+	return bqstorage.NewBigQueryReadClient(ctx)
+}
+
+// storageReadStream is a single stream of a read session, together with the
+// session schema needed to decode its rows.
+type storageReadStream struct {
+	// Name is the resource name of the stream.
+	Name string `json:"name"`
+	// Format is the data format of the session.
+	Format DataFormat `json:"format"`
+	// Schema is the Avro schema JSON or the serialized Arrow schema of the session.
+	Schema []byte `json:"schema"`
+	// EstimatedRows is the estimated number of rows in the stream.
+	EstimatedRows int64 `json:"estimatedRows"`
+}
+
+type createReadSessionFn struct {
+	// Project is the project billed for the read.
+	Project string `json:"project"`
+	// Table is the qualified table identifier.
+	Table QualifiedTableName `json:"table"`
+	// Options specifies additional read options.
+	Options storageReadOptions `json:"options"`
+
+	newClient func(ctx context.Context) (storageReadClient, error)
+}
+
+func (f *createReadSessionFn) ProcessElement(ctx context.Context, _ []byte, emit func(storageReadStream)) error {
+	newClient := f.newClient
+	if newClient == nil {
+		newClient = newStorageReadClient
+	}
+	client, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	session, err := client.CreateReadSession(ctx, f.request())
+	if err != nil {
+		return errors.Wrapf(err, "failed to create read session for table %v", f.Table)
+	}
+
+	var schema []byte
+	switch f.Options.DataFormat {
+	case Arrow:
+		schema = session.GetArrowSchema().GetSerializedSchema()
+	default:
+		schema = []byte(session.GetAvroSchema().GetSchema())
+	}
+
+	streams := session.GetStreams()
+	log.Infof(ctx, "Created read session %v with %d streams for table %v", session.GetName(), len(streams), f.Table)
+	for _, stream := range streams {
+		emit(storageReadStream{
+			Name:          stream.GetName(),
+			Format:        f.Options.DataFormat,
+			Schema:        schema,
+			EstimatedRows: session.GetEstimatedRowCount() / int64(len(streams)),
+		})
+	}
+	return nil
+}
+
+func (f *createReadSessionFn) request() *storagepb.CreateReadSessionRequest {
+	format := storagepb.DataFormat_AVRO
+	if f.Options.DataFormat == Arrow {
+		format = storagepb.DataFormat_ARROW
+	}
+	return &storagepb.CreateReadSessionRequest{
+		Parent: fmt.Sprintf("projects/%v", f.Project),
+		ReadSession: &storagepb.ReadSession{
+			Table:      fmt.Sprintf("projects/%v/datasets/%v/tables/%v", f.Table.Project, f.Table.Dataset, f.Table.Table),
+			DataFormat: format,
+			ReadOptions: &storagepb.ReadSession_TableReadOptions{
+				SelectedFields: f.Options.SelectedFields,
+				RowRestriction: f.Options.RowRestriction,
+			},
+		},
+		MaxStreamCount: int32(f.Options.MaxStreams),
+	}
+}
+
+type readStorageStreamFn struct {
+	// Type is the encoded schema type.
+	Type beam.EncodedType `json:"type"`
+
+	newClient func(ctx context.Context) (storageReadClient, error)
+	client    storageReadClient
+}
+
+func (f *readStorageStreamFn) Setup(ctx context.Context) error {
+	if f.client != nil {
+		return nil
+	}
+	newClient := f.newClient
+	if newClient == nil {
+		newClient = newStorageReadClient
+	}
+	client, err := newClient(ctx)
+	if err != nil {
+		return err
+	}
+	f.client = client
+	return nil
+}
+
+func (f *readStorageStreamFn) Teardown() error {
+	if f.client == nil {
+		return nil
+	}
+	err := f.client.Close()
+	f.client = nil
+	return err
+}
+
+func (f *readStorageStreamFn) CreateInitialRestriction(stream storageReadStream) storageStreamRestriction {
+	return storageStreamRestriction{Stream: stream.Name}
+}
+
+func (f *readStorageStreamFn) SplitRestriction(_ storageReadStream, rest storageStreamRestriction) []storageStreamRestriction {
+	return []storageStreamRestriction{rest}
+}
+
+func (f *readStorageStreamFn) RestrictionSize(stream storageReadStream, rest storageStreamRestriction) float64 {
+	if remaining := stream.EstimatedRows - rest.Offset; remaining > 0 {
+		return float64(remaining)
+	}
+	return 1
+}
+
+func (f *readStorageStreamFn) CreateTracker(rest storageStreamRestriction) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(newStorageStreamTracker(rest, f.splitStream))
+}
+
+// splitStream asks the server to split the named stream at the given fraction
+// of its rows.
+func (f *readStorageStreamFn) splitStream(name string, fraction float64) (string, string, error) {
+	resp, err := f.client.SplitReadStream(context.Background(), &storagepb.SplitReadStreamRequest{
+		Name:     name,
+		Fraction: fraction,
+	})
+	if status.Code(err) == codes.OutOfRange {
+		// The fraction is before the rows read so far, so the stream isn't split.
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return resp.GetPrimaryStream().GetName(), resp.GetRemainderStream().GetName(), nil
+}
+
+func (f *readStorageStreamFn) ProcessElement(ctx context.Context, rt *sdf.LockRTracker, stream storageReadStream, emit func(beam.X)) error {
+	dec, err := newRowDecoder(stream.Format, stream.Schema, f.Type.T)
+	if err != nil {
+		return err
+	}
+
+	rest := rt.GetRestriction().(storageStreamRestriction)
+	name, offset := rest.Stream, rest.Offset
+	for {
+		// A dynamic split replaces the stream of the restriction with the
+		// primary stream of the split, which is then read from the current
+		// offset onwards.
+		next, done, err := f.readRows(ctx, rt, dec, name, offset, emit)
+		if err != nil || done {
+			return err
+		}
+		name, offset = rt.GetRestriction().(storageStreamRestriction).Stream, next
+	}
+}
+
+// readRows reads the named stream from offset until it is exhausted, the
+// tracker stops claiming rows, or the restriction moves to another stream. It
+// returns the offset of the next unread row and whether processing is done.
+func (f *readStorageStreamFn) readRows(ctx context.Context, rt *sdf.LockRTracker, dec rowDecoder, name string, offset int64, emit func(beam.X)) (int64, bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	rows, err := f.client.ReadRows(ctx, &storagepb.ReadRowsRequest{ReadStream: name, Offset: offset})
+	if err != nil {
+		return offset, true, errors.Wrapf(err, "failed to read stream %v", name)
+	}
+	for {
+		resp, err := rows.Recv()
+		if err == io.EOF {
+			rt.TryClaim(streamEnd)
+			return offset, true, rt.GetError()
+		}
+		if err != nil {
+			return offset, true, errors.Wrapf(err, "failed to read stream %v at offset %d", name, offset)
+		}
+
+		vals, err := dec.decode(resp)
+		if err != nil {
+			return offset, true, errors.Wrapf(err, "failed to decode rows of stream %v at offset %d", name, offset)
+		}
+		progress := resp.GetStats().GetProgress()
+		start, end := progress.GetAtResponseStart(), progress.GetAtResponseEnd()
+		for i, val := range vals {
+			// The row is claimed and counted in the progress at once, so that
+			// a concurrent split is never asked for before a claimed row.
+			claimed, moved := claimRow(rt, name, offset, start+(end-start)*float64(i+1)/float64(len(vals)))
+			if moved {
+				return offset, false, nil
+			}
+			if !claimed {
+				return offset, true, rt.GetError()
+			}
+			emit(val)
+			offset++
+		}
+		if _, moved := claimRow(rt, name, -1, end); moved {
+			return offset, false, nil
+		}
+	}
+}
+
+// claimRow claims the row at offset of the named stream, unless offset is
+// negative, and sets the progress of the tracker if it does. It returns
+// whether the row was claimed, and whether the restriction moved to another
+// stream after a split, in which case the row must be read from that stream.
+func claimRow(rt *sdf.LockRTracker, name string, offset int64, progress float64) (claimed, moved bool) {
+	rt.Mu.Lock()
+	defer rt.Mu.Unlock()
+
+	tracker := rt.Rt.(*storageStreamTracker)
+	if tracker.rest.Stream != name {
+		return false, true
+	}
+	if offset >= 0 && !tracker.TryClaim(offset) {
+		return false, false
+	}
+	tracker.progress = progress
+	return true, false
+}
@@ -0,0 +1,738 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigqueryio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"cloud.google.com/go/civil"
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/decimal128"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/linkedin/goavro/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type storageAddress struct {
+	City string `bigquery:"city"`
+	Zip  int64  `bigquery:"zip"`
+}
+
+type storageRow struct {
+	ID       int64                 `bigquery:"id"`
+	Name     bigquery.NullString   `bigquery:"name"`
+	Score    float64               `bigquery:"score"`
+	Created  time.Time             `bigquery:"created"`
+	Day      civil.Date            `bigquery:"day"`
+	Updated  bigquery.NullDateTime `bigquery:"updated"`
+	Price    *big.Rat              `bigquery:"price"`
+	Tags     []string              `bigquery:"tags"`
+	Address  storageAddress        `bigquery:"address"`
+	Previous *storageAddress       `bigquery:"previous"`
+	Ignored  string                `bigquery:"-"`
+}
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*storageRow)(nil)).Elem())
+}
+
+var (
+	storageCreated = time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	storageUpdated = civil.DateTime{Date: civil.Date{Year: 2024, Month: 3, Day: 2}, Time: civil.Time{Hour: 8}}
+
+	storageRows = []storageRow{
+		{
+			ID:      1,
+			Name:    bigquery.NullString{StringVal: "alice", Valid: true},
+			Score:   1.5,
+			Created: storageCreated,
+			Day:     civil.Date{Year: 2024, Month: 3, Day: 1},
+			Updated: bigquery.NullDateTime{DateTime: storageUpdated, Valid: true},
+			Price:   big.NewRat(1234, 100),
+			Tags:    []string{"a", "b"},
+			Address: storageAddress{City: "Seattle", Zip: 98101},
+		},
+		{
+			ID:       2,
+			Score:    2.5,
+			Created:  storageCreated.Add(time.Hour),
+			Day:      civil.Date{Year: 2024, Month: 3, Day: 2},
+			Price:    big.NewRat(5, 1),
+			Tags:     []string{},
+			Address:  storageAddress{City: "Portland", Zip: 97201},
+			Previous: &storageAddress{City: "Seattle", Zip: 98101},
+		},
+	}
+)
+
+var ratComparer = cmp.Comparer(func(a, b *big.Rat) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Cmp(b) == 0
+})
+
+const storageAvroSchema = `{
+	"type": "record",
+	"name": "__root__",
+	"fields": [
+		{"name": "id", "type": ["null", "long"]},
+		{"name": "name", "type": ["null", "string"]},
+		{"name": "score", "type": ["null", "double"]},
+		{"name": "created", "type": ["null", {"type": "long", "logicalType": "timestamp-micros"}]},
+		{"name": "day", "type": ["null", {"type": "int", "logicalType": "date"}]},
+		{"name": "updated", "type": ["null", {"type": "string", "logicalType": "datetime"}]},
+		{"name": "price", "type": ["null", {"type": "bytes", "logicalType": "decimal", "precision": 38, "scale": 9}]},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "address", "type": ["null", {"type": "record", "name": "address", "namespace": "__root__", "fields": [
+			{"name": "city", "type": ["null", "string"]},
+			{"name": "zip", "type": ["null", "long"]}
+		]}]},
+		{"name": "previous", "type": ["null", {"type": "record", "name": "previous", "namespace": "__root__", "fields": [
+			{"name": "city", "type": ["null", "string"]},
+			{"name": "zip", "type": ["null", "long"]}
+		]}]}
+	]
+}`
+
+func avroAddress(a *storageAddress) any {
+	if a == nil {
+		return nil
+	}
+	return goavro.Union("__root__.address", map[string]any{
+		"city": goavro.Union("string", a.City),
+		"zip":  goavro.Union("long", a.Zip),
+	})
+}
+
+// encodeAvroRows encodes rows the way the Storage Read API serializes them.
+func encodeAvroRows(t *testing.T, rows []storageRow) []byte {
+	t.Helper()
+	codec, err := goavro.NewCodec(storageAvroSchema)
+	if err != nil {
+		t.Fatalf("goavro.NewCodec() failed: %v", err)
+	}
+	var buf []byte
+	for _, r := range rows {
+		var name, updated any
+		if r.Name.Valid {
+			name = goavro.Union("string", r.Name.StringVal)
+		}
+		if r.Updated.Valid {
+			updated = goavro.Union("string", r.Updated.DateTime.String())
+		}
+		tags := make([]any, len(r.Tags))
+		for i, tag := range r.Tags {
+			tags[i] = tag
+		}
+		previous := avroAddress(r.Previous)
+		if previous != nil {
+			previous = goavro.Union("__root__.previous", previous.(map[string]any)["__root__.address"])
+		}
+		native := map[string]any{
+			"id":       goavro.Union("long", r.ID),
+			"name":     name,
+			"score":    goavro.Union("double", r.Score),
+			"created":  goavro.Union("long.timestamp-micros", r.Created),
+			"day":      goavro.Union("int.date", r.Day.In(time.UTC)),
+			"updated":  updated,
+			"price":    goavro.Union("bytes.decimal", r.Price),
+			"tags":     tags,
+			"address":  avroAddress(&r.Address),
+			"previous": previous,
+		}
+		buf, err = codec.BinaryFromNative(buf, native)
+		if err != nil {
+			t.Fatalf("BinaryFromNative(%v) failed: %v", native, err)
+		}
+	}
+	return buf
+}
+
+var storageArrowAddress = arrow.StructOf(
+	arrow.Field{Name: "city", Type: arrow.BinaryTypes.String, Nullable: true},
+	arrow.Field{Name: "zip", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+)
+
+var storageArrowSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "id", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+	{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+	{Name: "score", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+	{Name: "created", Type: &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}, Nullable: true},
+	{Name: "day", Type: arrow.FixedWidthTypes.Date32, Nullable: true},
+	{Name: "updated", Type: &arrow.TimestampType{Unit: arrow.Microsecond}, Nullable: true},
+	{Name: "price", Type: &arrow.Decimal128Type{Precision: 38, Scale: 9}, Nullable: true},
+	{Name: "tags", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+	{Name: "address", Type: storageArrowAddress, Nullable: true},
+	{Name: "previous", Type: storageArrowAddress, Nullable: true},
+}, nil)
+
+func appendArrowAddress(b *array.StructBuilder, a *storageAddress) {
+	if a == nil {
+		b.AppendNull()
+		return
+	}
+	b.Append(true)
+	b.FieldBuilder(0).(*array.StringBuilder).Append(a.City)
+	b.FieldBuilder(1).(*array.Int64Builder).Append(a.Zip)
+}
+
+// encodeArrowRows returns the serialized schema and the serialized record
+// batch holding rows, the way the Storage Read API sends them.
+func encodeArrowRows(t *testing.T, rows []storageRow) ([]byte, []byte) {
+	t.Helper()
+	b := array.NewRecordBuilder(memory.DefaultAllocator, storageArrowSchema)
+	defer b.Release()
+	for _, r := range rows {
+		b.Field(0).(*array.Int64Builder).Append(r.ID)
+		if r.Name.Valid {
+			b.Field(1).(*array.StringBuilder).Append(r.Name.StringVal)
+		} else {
+			b.Field(1).AppendNull()
+		}
+		b.Field(2).(*array.Float64Builder).Append(r.Score)
+		b.Field(3).(*array.TimestampBuilder).Append(arrow.Timestamp(r.Created.UnixMicro()))
+		b.Field(4).(*array.Date32Builder).Append(arrow.Date32FromTime(r.Day.In(time.UTC)))
+		if r.Updated.Valid {
+			b.Field(5).(*array.TimestampBuilder).Append(arrow.Timestamp(r.Updated.DateTime.In(time.UTC).UnixMicro()))
+		} else {
+			b.Field(5).AppendNull()
+		}
+		unscaled := new(big.Int).Mul(r.Price.Num(), big.NewInt(1_000_000_000))
+		unscaled.Quo(unscaled, r.Price.Denom())
+		b.Field(6).(*array.Decimal128Builder).Append(decimal128.FromBigInt(unscaled))
+		tags := b.Field(7).(*array.ListBuilder)
+		tags.Append(true)
+		for _, tag := range r.Tags {
+			tags.ValueBuilder().(*array.StringBuilder).Append(tag)
+		}
+		appendArrowAddress(b.Field(8).(*array.StructBuilder), &r.Address)
+		appendArrowAddress(b.Field(9).(*array.StructBuilder), r.Previous)
+	}
+	rec := b.NewRecord()
+	defer rec.Release()
+
+	var schema bytes.Buffer
+	w := ipc.NewWriter(&schema, ipc.WithSchema(storageArrowSchema))
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to write schema: %v", err)
+	}
+	var full bytes.Buffer
+	w = ipc.NewWriter(&full, ipc.WithSchema(storageArrowSchema))
+	if err := w.Write(rec); err != nil {
+		t.Fatalf("failed to write record: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to write record: %v", err)
+	}
+	// Strip the end-of-stream marker written after the schema.
+	n := schema.Len() - 8
+	return schema.Bytes()[:n], full.Bytes()[n:]
+}
+
+func TestAvroDecoder(t *testing.T) {
+	dec, err := newRowDecoder(Avro, []byte(storageAvroSchema), reflect.TypeOf(storageRow{}))
+	if err != nil {
+		t.Fatalf("newRowDecoder() failed: %v", err)
+	}
+	resp := &storagepb.ReadRowsResponse{
+		Rows:     &storagepb.ReadRowsResponse_AvroRows{AvroRows: &storagepb.AvroRows{SerializedBinaryRows: encodeAvroRows(t, storageRows)}},
+		RowCount: int64(len(storageRows)),
+	}
+	got, err := dec.decode(resp)
+	if err != nil {
+		t.Fatalf("decode() failed: %v", err)
+	}
+	want := []any{storageRows[0], storageRows[1]}
+	if diff := cmp.Diff(want, got, ratComparer); diff != "" {
+		t.Errorf("decode() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestArrowDecoder(t *testing.T) {
+	schema, batch := encodeArrowRows(t, storageRows)
+	dec, err := newRowDecoder(Arrow, schema, reflect.TypeOf(storageRow{}))
+	if err != nil {
+		t.Fatalf("newRowDecoder() failed: %v", err)
+	}
+	resp := &storagepb.ReadRowsResponse{
+		Rows:     &storagepb.ReadRowsResponse_ArrowRecordBatch{ArrowRecordBatch: &storagepb.ArrowRecordBatch{SerializedRecordBatch: batch}},
+		RowCount: int64(len(storageRows)),
+	}
+	got, err := dec.decode(resp)
+	if err != nil {
+		t.Fatalf("decode() failed: %v", err)
+	}
+	want := []any{storageRows[0], storageRows[1]}
+	if diff := cmp.Diff(want, got, ratComparer); diff != "" {
+		t.Errorf("decode() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadRow_untaggedFields(t *testing.T) {
+	type row struct {
+		ID      int64
+		Name    string
+		Ignored string `bigquery:"-"`
+	}
+	got, err := loadRow(reflect.TypeOf(row{}), map[string]any{"id": int64(1), "NAME": "a", "ignored": "b"})
+	if err != nil {
+		t.Fatalf("loadRow() failed: %v", err)
+	}
+	if want := (row{ID: 1, Name: "a"}); got != want {
+		t.Errorf("loadRow() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAssignValue_incompatibleType(t *testing.T) {
+	type row struct {
+		ID string `bigquery:"id"`
+	}
+	if _, err := loadRow(reflect.TypeOf(row{}), map[string]any{"id": int64(1)}); err == nil {
+		t.Error("loadRow() succeeded, want error assigning int64 to string")
+	}
+}
+
+func TestStorageReadOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     StorageReadOption
+		want    storageReadOptions
+		wantErr bool
+	}{
+		{
+			name: "selected fields",
+			opt:  WithSelectedFields("a", "b.c"),
+			want: storageReadOptions{DataFormat: Avro, SelectedFields: []string{"a", "b.c"}},
+		},
+		{
+			name:    "no selected fields",
+			opt:     WithSelectedFields(),
+			wantErr: true,
+		},
+		{
+			name: "row restriction",
+			opt:  WithRowRestriction("a > 1"),
+			want: storageReadOptions{DataFormat: Avro, RowRestriction: "a > 1"},
+		},
+		{
+			name: "arrow format",
+			opt:  WithDataFormat(Arrow),
+			want: storageReadOptions{DataFormat: Arrow},
+		},
+		{
+			name:    "unknown format",
+			opt:     WithDataFormat("CSV"),
+			wantErr: true,
+		},
+		{
+			name: "max streams",
+			opt:  WithMaxStreams(4),
+			want: storageReadOptions{DataFormat: Avro, MaxStreams: 4},
+		},
+		{
+			name:    "negative max streams",
+			opt:     WithMaxStreams(-1),
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := newStorageReadOptions()
+			err := test.opt(&got)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("option error = %v, wantErr %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("options mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestStorageStreamTracker_TryClaim(t *testing.T) {
+	tracker := newStorageStreamTracker(storageStreamRestriction{Stream: "s", Offset: 5}, nil)
+	if tracker.TryClaim(int64(4)) {
+		t.Error("TryClaim(4) = true before the restriction offset, want false")
+	}
+	if tracker.GetError() == nil {
+		t.Error("GetError() = nil after claiming an offset out of order")
+	}
+
+	tracker = newStorageStreamTracker(storageStreamRestriction{Stream: "s", Offset: 5}, nil)
+	for _, offset := range []int64{5, 6, 7} {
+		if !tracker.TryClaim(offset) {
+			t.Fatalf("TryClaim(%d) = false, want true", offset)
+		}
+	}
+	if tracker.IsDone() {
+		t.Error("IsDone() = true before the end of the stream")
+	}
+	if tracker.TryClaim(streamEnd) {
+		t.Error("TryClaim(streamEnd) = true, want false")
+	}
+	if !tracker.IsDone() || tracker.GetError() != nil {
+		t.Errorf("IsDone(), GetError() = %v, %v after the end of the stream, want true, nil", tracker.IsDone(), tracker.GetError())
+	}
+}
+
+func TestStorageStreamTracker_checkpoint(t *testing.T) {
+	tracker := newStorageStreamTracker(storageStreamRestriction{Stream: "s"}, nil)
+	tracker.TryClaim(int64(0))
+	tracker.TryClaim(int64(1))
+
+	primary, residual, err := tracker.TrySplit(0)
+	if err != nil {
+		t.Fatalf("TrySplit(0) failed: %v", err)
+	}
+	if want := (storageStreamRestriction{Stream: "s"}); primary != want {
+		t.Errorf("TrySplit(0) primary = %v, want %v", primary, want)
+	}
+	if want := (storageStreamRestriction{Stream: "s", Offset: 2}); residual != want {
+		t.Errorf("TrySplit(0) residual = %v, want %v", residual, want)
+	}
+	if !tracker.IsDone() || tracker.TryClaim(int64(2)) {
+		t.Error("tracker kept claiming after a checkpoint")
+	}
+}
+
+func TestStorageStreamTracker_dynamicSplit(t *testing.T) {
+	var gotName string
+	var gotFraction float64
+	split := func(name string, fraction float64) (string, string, error) {
+		gotName, gotFraction = name, fraction
+		return name + "/primary", name + "/remainder", nil
+	}
+	tracker := newStorageStreamTracker(storageStreamRestriction{Stream: "s"}, split)
+	tracker.TryClaim(int64(0))
+	tracker.progress = 0.5
+
+	primary, residual, err := tracker.TrySplit(0.5)
+	if err != nil {
+		t.Fatalf("TrySplit(0.5) failed: %v", err)
+	}
+	if gotName != "s" || gotFraction != 0.75 {
+		t.Errorf("split(%v, %v), want split(s, 0.75)", gotName, gotFraction)
+	}
+	if want := (storageStreamRestriction{Stream: "s/primary"}); primary != want {
+		t.Errorf("TrySplit(0.5) primary = %v, want %v", primary, want)
+	}
+	if want := (storageStreamRestriction{Stream: "s/remainder"}); residual != want {
+		t.Errorf("TrySplit(0.5) residual = %v, want %v", residual, want)
+	}
+	if !tracker.TryClaim(int64(1)) {
+		t.Error("TryClaim(1) = false after a dynamic split, want true")
+	}
+}
+
+func TestStorageStreamTracker_dynamicSplitRejected(t *testing.T) {
+	tests := []struct {
+		name  string
+		split func(string, float64) (string, string, error)
+	}{
+		{"unsupported", nil},
+		{"too small", func(string, float64) (string, string, error) { return "", "", nil }},
+		{"failed", func(string, float64) (string, string, error) { return "", "", errors.New("unavailable") }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rest := storageStreamRestriction{Stream: "s"}
+			tracker := newStorageStreamTracker(rest, test.split)
+			primary, residual, err := tracker.TrySplit(0.5)
+			if err != nil || primary != rest || residual != nil {
+				t.Errorf("TrySplit(0.5) = %v, %v, %v, want %v, nil, nil", primary, residual, err, rest)
+			}
+		})
+	}
+}
+
+// fakeReadRows serves the responses of a stream.
+type fakeReadRows struct {
+	grpc.ClientStream
+	resps []*storagepb.ReadRowsResponse
+	// onRecv is called before each response is returned.
+	onRecv func(i int)
+	i      int
+}
+
+func (r *fakeReadRows) Recv() (*storagepb.ReadRowsResponse, error) {
+	if r.i >= len(r.resps) {
+		return nil, io.EOF
+	}
+	if r.onRecv != nil {
+		r.onRecv(r.i)
+	}
+	resp := r.resps[r.i]
+	r.i++
+	return resp, nil
+}
+
+// fakeStorageClient serves streams of Avro encoded rows, one row per response.
+type fakeStorageClient struct {
+	t       *testing.T
+	streams map[string][]storageRow
+	session *storagepb.ReadSession
+
+	sessionReq *storagepb.CreateReadSessionRequest
+	reads      []*storagepb.ReadRowsRequest
+	splits     []*storagepb.SplitReadStreamRequest
+	splitErr   error
+	onRecv     func(name string, i int)
+}
+
+func (c *fakeStorageClient) CreateReadSession(_ context.Context, req *storagepb.CreateReadSessionRequest, _ ...gax.CallOption) (*storagepb.ReadSession, error) {
+	c.sessionReq = req
+	return c.session, nil
+}
+
+func (c *fakeStorageClient) ReadRows(_ context.Context, req *storagepb.ReadRowsRequest, _ ...gax.CallOption) (storagepb.BigQueryRead_ReadRowsClient, error) {
+	c.reads = append(c.reads, req)
+	rows, ok := c.streams[req.GetReadStream()]
+	if !ok {
+		return nil, fmt.Errorf("stream %v not found", req.GetReadStream())
+	}
+	var resps []*storagepb.ReadRowsResponse
+	for i := req.GetOffset(); i < int64(len(rows)); i++ {
+		resps = append(resps, &storagepb.ReadRowsResponse{
+			Rows:     &storagepb.ReadRowsResponse_AvroRows{AvroRows: &storagepb.AvroRows{SerializedBinaryRows: encodeAvroRows(c.t, rows[i:i+1])}},
+			RowCount: 1,
+			Stats: &storagepb.StreamStats{Progress: &storagepb.StreamStats_Progress{
+				AtResponseStart: float64(i) / float64(len(rows)),
+				AtResponseEnd:   float64(i+1) / float64(len(rows)),
+			}},
+		})
+	}
+	name := req.GetReadStream()
+	return &fakeReadRows{resps: resps, onRecv: func(i int) {
+		if c.onRecv != nil {
+			c.onRecv(name, i)
+		}
+	}}, nil
+}
+
+func (c *fakeStorageClient) SplitReadStream(_ context.Context, req *storagepb.SplitReadStreamRequest, _ ...gax.CallOption) (*storagepb.SplitReadStreamResponse, error) {
+	c.splits = append(c.splits, req)
+	if c.splitErr != nil {
+		return nil, c.splitErr
+	}
+	rows := c.streams[req.GetName()]
+	at := int(req.GetFraction() * float64(len(rows)))
+	primary, remainder := req.GetName()+"-p", req.GetName()+"-r"
+	c.streams[primary] = rows[:at]
+	c.streams[remainder] = rows[at:]
+	return &storagepb.SplitReadStreamResponse{
+		PrimaryStream:   &storagepb.ReadStream{Name: primary},
+		RemainderStream: &storagepb.ReadStream{Name: remainder},
+	}, nil
+}
+
+func (c *fakeStorageClient) Close() error {
+	return nil
+}
+
+func TestCreateReadSessionFn(t *testing.T) {
+	client := &fakeStorageClient{
+		session: &storagepb.ReadSession{
+			Name:              "session",
+			Schema:            &storagepb.ReadSession_AvroSchema{AvroSchema: &storagepb.AvroSchema{Schema: storageAvroSchema}},
+			Streams:           []*storagepb.ReadStream{{Name: "s0"}, {Name: "s1"}},
+			EstimatedRowCount: 10,
+		},
+	}
+	fn := &createReadSessionFn{
+		Project: "billing",
+		Table:   QualifiedTableName{Project: "p", Dataset: "d", Table: "t"},
+		Options: storageReadOptions{
+			SelectedFields: []string{"id", "name"},
+			RowRestriction: "id > 1",
+			DataFormat:     Avro,
+			MaxStreams:     2,
+		},
+		newClient: func(context.Context) (storageReadClient, error) { return client, nil },
+	}
+
+	var got []storageReadStream
+	if err := fn.ProcessElement(context.Background(), nil, func(s storageReadStream) { got = append(got, s) }); err != nil {
+		t.Fatalf("ProcessElement() failed: %v", err)
+	}
+
+	wantReq := &storagepb.CreateReadSessionRequest{
+		Parent: "projects/billing",
+		ReadSession: &storagepb.ReadSession{
+			Table:      "projects/p/datasets/d/tables/t",
+			DataFormat: storagepb.DataFormat_AVRO,
+			ReadOptions: &storagepb.ReadSession_TableReadOptions{
+				SelectedFields: []string{"id", "name"},
+				RowRestriction: "id > 1",
+			},
+		},
+		MaxStreamCount: 2,
+	}
+	if got, want := client.sessionReq.String(), wantReq.String(); got != want {
+		t.Errorf("CreateReadSession() request = %v, want %v", got, want)
+	}
+	want := []storageReadStream{
+		{Name: "s0", Format: Avro, Schema: []byte(storageAvroSchema), EstimatedRows: 5},
+		{Name: "s1", Format: Avro, Schema: []byte(storageAvroSchema), EstimatedRows: 5},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ProcessElement() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func newTestReadStorageStreamFn(client *fakeStorageClient) *readStorageStreamFn {
+	fn := &readStorageStreamFn{
+		Type:      beam.EncodedType{T: reflect.TypeOf(storageRow{})},
+		newClient: func(context.Context) (storageReadClient, error) { return client, nil },
+	}
+	if err := fn.Setup(context.Background()); err != nil {
+		client.t.Fatalf("Setup() failed: %v", err)
+	}
+	return fn
+}
+
+func readStorageRows(t *testing.T, fn *readStorageStreamFn, rt *sdf.LockRTracker) []storageRow {
+	t.Helper()
+	stream := storageReadStream{Name: "s", Format: Avro, Schema: []byte(storageAvroSchema)}
+	var got []storageRow
+	if err := fn.ProcessElement(context.Background(), rt, stream, func(x beam.X) { got = append(got, x.(storageRow)) }); err != nil {
+		t.Fatalf("ProcessElement() failed: %v", err)
+	}
+	return got
+}
+
+func TestReadStorageStreamFn(t *testing.T) {
+	client := &fakeStorageClient{t: t, streams: map[string][]storageRow{"s": storageRows}}
+	fn := newTestReadStorageStreamFn(client)
+
+	rt := fn.CreateTracker(fn.CreateInitialRestriction(storageReadStream{Name: "s"}))
+	got := readStorageRows(t, fn, rt)
+	if diff := cmp.Diff(storageRows, got, ratComparer); diff != "" {
+		t.Errorf("ProcessElement() mismatch (-want +got):\n%s", diff)
+	}
+	if !rt.IsDone() {
+		t.Error("restriction is not done after reading the whole stream")
+	}
+}
+
+func TestReadStorageStreamFn_resumeAtOffset(t *testing.T) {
+	client := &fakeStorageClient{t: t, streams: map[string][]storageRow{"s": storageRows}}
+	fn := newTestReadStorageStreamFn(client)
+
+	rt := fn.CreateTracker(storageStreamRestriction{Stream: "s", Offset: 1})
+	got := readStorageRows(t, fn, rt)
+	if diff := cmp.Diff(storageRows[1:], got, ratComparer); diff != "" {
+		t.Errorf("ProcessElement() mismatch (-want +got):\n%s", diff)
+	}
+	if len(client.reads) != 1 || client.reads[0].GetOffset() != 1 {
+		t.Errorf("ReadRows() requests = %v, want a single read at offset 1", client.reads)
+	}
+}
+
+func TestReadStorageStreamFn_dynamicSplit(t *testing.T) {
+	var rows []storageRow
+	for i := 0; i < 4; i++ {
+		rows = append(rows, storageRow{ID: int64(i), Tags: []string{}, Price: big.NewRat(int64(i), 1), Created: storageCreated, Day: civil.DateOf(storageCreated)})
+	}
+	client := &fakeStorageClient{t: t, streams: map[string][]storageRow{"s": rows}}
+	fn := newTestReadStorageStreamFn(client)
+	rt := fn.CreateTracker(fn.CreateInitialRestriction(storageReadStream{Name: "s"}))
+
+	var residual any
+	client.onRecv = func(name string, i int) {
+		if name != "s" || i != 1 {
+			return
+		}
+		// After the first row, and before the second row is emitted, split a
+		// third of the remaining rows into the primary.
+		var err error
+		_, residual, err = rt.TrySplit(1.0 / 3)
+		if err != nil {
+			t.Errorf("TrySplit() failed: %v", err)
+		}
+	}
+	got := readStorageRows(t, fn, rt)
+
+	if want := (storageStreamRestriction{Stream: "s-r"}); residual != want {
+		t.Fatalf("TrySplit() residual = %v, want %v", residual, want)
+	}
+	if diff := cmp.Diff(rows[:2], got, ratComparer); diff != "" {
+		t.Errorf("ProcessElement() of the primary mismatch (-want +got):\n%s", diff)
+	}
+
+	rt = fn.CreateTracker(residual.(storageStreamRestriction))
+	got = readStorageRows(t, fn, rt)
+	if diff := cmp.Diff(rows[2:], got, ratComparer); diff != "" {
+		t.Errorf("ProcessElement() of the residual mismatch (-want +got):\n%s", diff)
+	}
+
+	var streams []string
+	for _, req := range client.reads {
+		streams = append(streams, fmt.Sprintf("%v@%d", req.GetReadStream(), req.GetOffset()))
+	}
+	if got, want := strings.Join(streams, ","), "s@0,s-p@1,s-r@0"; got != want {
+		t.Errorf("ReadRows() requests = %v, want %v", got, want)
+	}
+	if len(client.splits) != 1 || client.splits[0].GetFraction() != 0.5 {
+		t.Errorf("SplitReadStream() requests = %v, want a split at 0.5 after the first row", client.splits)
+	}
+}
+
+func TestReadStorageStreamFn_splitOutOfRange(t *testing.T) {
+	client := &fakeStorageClient{
+		t:        t,
+		streams:  map[string][]storageRow{"s": storageRows},
+		splitErr: status.Error(codes.OutOfRange, "fraction is before the current offset"),
+	}
+	fn := newTestReadStorageStreamFn(client)
+	rt := fn.CreateTracker(fn.CreateInitialRestriction(storageReadStream{Name: "s"}))
+
+	var residual any
+	client.onRecv = func(name string, i int) {
+		if i == 1 {
+			_, residual, _ = rt.TrySplit(0.5)
+		}
+	}
+	got := readStorageRows(t, fn, rt)
+	if residual != nil {
+		t.Errorf("TrySplit() residual = %v, want nil", residual)
+	}
+	if diff := cmp.Diff(storageRows, got, ratComparer); diff != "" {
+		t.Errorf("ProcessElement() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,140 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigqueryio
+
+import (
+	"context"
+	"math"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+)
+
+// streamEnd is the position claimed once a stream has been read to completion.
+const streamEnd int64 = math.MaxInt64
+
+// storageStreamRestriction is the part of a read stream that remains to be
+// read, starting at the row at Offset.
+type storageStreamRestriction struct {
+	// Stream is the resource name of the stream.
+	Stream string `json:"stream"`
+	// Offset is the offset of the first row to read.
+	Offset int64 `json:"offset"`
+}
+
+// storageStreamTracker tracks the rows claimed from a read stream. Positions
+// are row offsets within the stream, and streamEnd marks that the stream has
+// been exhausted.
+//
+// Checkpoints resume the same stream at the next unclaimed offset. Dynamic
+// splits are delegated to the server, which splits the stream into a primary
+// stream that replaces the current one and a remainder stream that becomes
+// the residual.
+type storageStreamTracker struct {
+	rest     storageStreamRestriction
+	claimed  int64
+	progress float64
+	split    func(name string, fraction float64) (primary, remainder string, err error)
+	stopped  bool
+	err      error
+}
+
+func newStorageStreamTracker(rest storageStreamRestriction, split func(string, float64) (string, string, error)) *storageStreamTracker {
+	return &storageStreamTracker{rest: rest, claimed: rest.Offset, split: split}
+}
+
+// TryClaim claims the row at the given offset. Offsets must be claimed in
+// increasing order. Claiming streamEnd stops the tracker and returns false.
+func (t *storageStreamTracker) TryClaim(pos any) bool {
+	if t.stopped {
+		return false
+	}
+	offset, ok := pos.(int64)
+	if !ok {
+		t.err = errors.Errorf("invalid position type %T, want int64", pos)
+		return false
+	}
+	if offset == streamEnd {
+		t.stopped = true
+		return false
+	}
+	if offset < t.claimed {
+		t.err = errors.Errorf("cannot claim offset %d, the next offset to claim is %d", offset, t.claimed)
+		return false
+	}
+	t.claimed = offset + 1
+	return true
+}
+
+// GetError returns the error that made the tracker stop, if any.
+func (t *storageStreamTracker) GetError() error {
+	return t.err
+}
+
+// TrySplit checkpoints the restriction when fraction is 0, leaving the rest of
+// the stream from the next unclaimed offset as the residual. Otherwise it
+// asks the server to split the stream at the corresponding fraction of its
+// remaining rows, and returns no residual if the stream cannot be split.
+func (t *storageStreamTracker) TrySplit(fraction float64) (any, any, error) {
+	if t.stopped || t.err != nil {
+		return t.rest, nil, nil
+	}
+	if fraction == 0 {
+		residual := storageStreamRestriction{Stream: t.rest.Stream, Offset: t.claimed}
+		t.stopped = true
+		return t.rest, residual, nil
+	}
+	if t.split == nil {
+		return t.rest, nil, nil
+	}
+
+	at := t.progress + fraction*(1-t.progress)
+	primary, remainder, err := t.split(t.rest.Stream, at)
+	if err != nil {
+		// Dynamic splits are best effort; failing one should not fail the bundle.
+		log.Warnf(context.Background(), "Failed to split stream %v at %v: %v", t.rest.Stream, at, err)
+		return t.rest, nil, nil
+	}
+	if primary == "" || remainder == "" {
+		return t.rest, nil, nil
+	}
+	t.rest.Stream = primary
+	return t.rest, storageStreamRestriction{Stream: remainder}, nil
+}
+
+// GetProgress reports the fraction of the stream read so far, as reported by
+// the server.
+func (t *storageStreamTracker) GetProgress() (done, remaining float64) {
+	if t.stopped {
+		return 1, 0
+	}
+	return t.progress, 1 - t.progress
+}
+
+// IsDone returns true once the stream has been exhausted or checkpointed.
+func (t *storageStreamTracker) IsDone() bool {
+	return t.stopped
+}
+
+// GetRestriction returns the restriction being tracked.
+func (t *storageStreamTracker) GetRestriction() any {
+	return t.rest
+}
+
+// IsBounded returns true, since every read stream has a finite number of rows.
+func (t *storageStreamTracker) IsBounded() bool {
+	return true
+}
@@ -0,0 +1,650 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigqueryio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+	"cloud.google.com/go/bigquery/storage/managedwriter/adapt"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*addTableFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*shardRowsFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*storageShard)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*writeStorageFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*commitStreamsFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*StorageWriteFailure)(nil)).Elem())
+}
+
+// WriteMode determines the delivery guarantee of WriteStorage.
+type WriteMode string
+
+const (
+	// ExactlyOnce appends the rows of each shard of a table to a pending
+	// stream, which is committed by a later stage once the runner has durably
+	// completed the bundle. The streams of failed bundles are never committed.
+	ExactlyOnce WriteMode = "EXACTLY_ONCE"
+	// AtLeastOnce appends rows to the default stream of each table, where they
+	// are visible immediately. Retried bundles may write rows more than once.
+	AtLeastOnce WriteMode = "AT_LEAST_ONCE"
+)
+
+// storageWriteOptions represents additional options for writing with the
+// BigQuery Storage Write API.
+type storageWriteOptions struct {
+	// Mode is the delivery guarantee of the write.
+	Mode WriteMode `json:"mode"`
+	// BatchSize is the maximum number of rows sent in a single append.
+	BatchSize int `json:"batchSize"`
+	// NumStreams is the number of shards of the rows of each table, in each
+	// window, that are written in parallel.
+	NumStreams int `json:"numStreams"`
+	// CreateDisposition specifies the circumstances under which destination
+	// tables will be created.
+	CreateDisposition bigquery.TableCreateDisposition `json:"createDisposition"`
+	// AllowFieldAddition adds columns of the element type that are missing
+	// from a destination table to its schema.
+	AllowFieldAddition bool `json:"allowFieldAddition"`
+}
+
+// newStorageWriteOptions creates a new instance of storageWriteOptions.
+// Exactly-once delivery and "CreateIfNeeded" are set as the defaults.
+func newStorageWriteOptions() storageWriteOptions {
+	return storageWriteOptions{
+		Mode:              ExactlyOnce,
+		BatchSize:         500,
+		NumStreams:        16,
+		CreateDisposition: bigquery.CreateIfNeeded,
+	}
+}
+
+// StorageWriteOption represents a function that sets options for writing
+// with the BigQuery Storage Write API.
+type StorageWriteOption func(*storageWriteOptions) error
+
+// WithWriteMode sets the delivery guarantee of the write. ExactlyOnce is used
+// by default.
+func WithWriteMode(mode WriteMode) StorageWriteOption {
+	return func(o *storageWriteOptions) error {
+		switch mode {
+		case ExactlyOnce, AtLeastOnce:
+			o.Mode = mode
+			return nil
+		default:
+			return errors.Errorf("unsupported write mode: %v", mode)
+		}
+	}
+}
+
+// WithAppendBatchSize sets the maximum number of rows sent in a single append
+// request.
+func WithAppendBatchSize(n int) StorageWriteOption {
+	return func(o *storageWriteOptions) error {
+		if n <= 0 {
+			return errors.Errorf("append batch size must be positive, got %v", n)
+		}
+		o.BatchSize = n
+		return nil
+	}
+}
+
+// WithNumStreams sets the number of shards of the rows of each table, in each
+// window, that are written in parallel, each with a stream of its own. 16
+// shards are used by default.
+func WithNumStreams(n int) StorageWriteOption {
+	return func(o *storageWriteOptions) error {
+		if n <= 0 {
+			return errors.Errorf("number of streams must be positive, got %v", n)
+		}
+		o.NumStreams = n
+		return nil
+	}
+}
+
+// WithStorageCreateDisposition specifies the circumstances under which
+// destination tables will be created. Tables are created from the schema
+// inferred from the element type.
+func WithStorageCreateDisposition(cd bigquery.TableCreateDisposition) StorageWriteOption {
+	return func(o *storageWriteOptions) error {
+		o.CreateDisposition = cd
+		return nil
+	}
+}
+
+// WithFieldAddition allows the write to add top-level columns of the element
+// type that are missing from a destination table to its schema. Otherwise
+// such rows are sent to the failed rows output.
+func WithFieldAddition() StorageWriteOption {
+	return func(o *storageWriteOptions) error {
+		o.AllowFieldAddition = true
+		return nil
+	}
+}
+
+// StorageWriteFailure is a row that could not be written, together with the
+// reason it was rejected.
+type StorageWriteFailure struct {
+	// Table is the destination table, formatted as "<project>:<dataset>.<table>".
+	Table string `json:"table"`
+	// Row is the JSON encoding of the element.
+	Row string `json:"row"`
+	// Error describes why the row was rejected.
+	Error string `json:"error"`
+}
+
+// WriteStorage writes the elements of the given PCollection<T> to bigquery
+// with the Storage Write API. T is required to be the schema type. Rows are
+// converted to protocol buffers following the current schema of the table,
+// including schema changes reported while writing.
+//
+// The rows are grouped by table, in each window, into the number of shards
+// set with WithNumStreams, so unbounded PCollections must be windowed. Each
+// shard is written with a stream of its own.
+//
+// WriteStorage returns a PCollection<StorageWriteFailure> holding the rows
+// that could not be converted or were rejected by the server, which are
+// output in the window of their shard, at its end. Any other failure fails
+// the bundle.
+func WriteStorage(s beam.Scope, project, table string, col beam.PCollection, options ...StorageWriteOption) beam.PCollection {
+	mustParseTable(table)
+
+	s = s.Scope("bigquery.WriteStorage")
+
+	keyed := beam.ParDo(s, &addTableFn{Table: table}, col)
+	return writeStorage(s, project, col.Type().Type(), keyed, options...)
+}
+
+// WriteStorageDynamic writes the values of the given PCollection<KV<string,T>>
+// to the tables named by their keys, formatted as "<project>:<dataset>.<table>".
+// T is required to be the schema type. It otherwise behaves like WriteStorage.
+func WriteStorageDynamic(s beam.Scope, project string, col beam.PCollection, options ...StorageWriteOption) beam.PCollection {
+	if !typex.IsKV(col.Type()) || col.Type().Components()[0].Type() != reflectx.String {
+		panic(fmt.Sprintf("bigqueryio.WriteStorageDynamic: input must be a KV<string,T>, got %v", col.Type()))
+	}
+
+	s = s.Scope("bigquery.WriteStorageDynamic")
+
+	return writeStorage(s, project, col.Type().Components()[1].Type(), col, options...)
+}
+
+func writeStorage(s beam.Scope, project string, t reflect.Type, col beam.PCollection, options ...StorageWriteOption) beam.PCollection {
+	mustInferSchema(t)
+
+	opts := newStorageWriteOptions()
+	for _, opt := range options {
+		if err := opt(&opts); err != nil {
+			panic(err)
+		}
+	}
+	sharded := beam.ParDo(s, &shardRowsFn{NumStreams: opts.NumStreams}, col)
+	failures, streams := beam.ParDo2(s, &writeStorageFn{Project: project, Type: beam.EncodedType{T: t}, Options: opts}, beam.GroupByKey(s, sharded))
+	if opts.Mode == ExactlyOnce {
+		// The reshuffle persists the names of the pending streams, so that
+		// they are only committed once the bundles that wrote them completed.
+		beam.ParDo0(s, &commitStreamsFn{Project: project}, beam.Reshuffle(s, streams))
+	}
+	return failures
+}
+
+type addTableFn struct {
+	// Table is the destination table.
+	Table string `json:"table"`
+}
+
+func (f *addTableFn) ProcessElement(row beam.X) (string, beam.X) {
+	return f.Table, row
+}
+
+// storageShard is a shard of the rows written to a table.
+type storageShard struct {
+	Table string `json:"table"`
+	Shard int    `json:"shard"`
+}
+
+// shardRowsFn keys the rows by a random shard of their table.
+type shardRowsFn struct {
+	// NumStreams is the number of shards of each table.
+	NumStreams int `json:"numStreams"`
+}
+
+func (f *shardRowsFn) ProcessElement(table string, row beam.X) (storageShard, beam.X) {
+	return storageShard{Table: table, Shard: rand.Intn(f.NumStreams)}, row
+}
+
+type writeStorageFn struct {
+	// Project is the project.
+	Project string `json:"project"`
+	// Type is the encoded schema type.
+	Type beam.EncodedType `json:"type"`
+	// Options specifies additional write options.
+	Options storageWriteOptions `json:"options"`
+
+	// clientOptions are the options of the Storage Write API client, and
+	// tableClientOptions those of the BigQuery API client.
+	clientOptions      []option.ClientOption
+	tableClientOptions []option.ClientOption
+	client             *managedwriter.Client
+	writers            map[string]*storageTableWriter
+}
+
+// storageTableWriter appends the rows of a bundle to a single table.
+type storageTableWriter struct {
+	table  QualifiedTableName
+	stream *managedwriter.ManagedStream
+	enc    *rowEncoder
+	// schemaChanged is set when enc changed since the last append.
+	schemaChanged bool
+	rows          []any
+}
+
+func (f *writeStorageFn) Setup(ctx context.Context) error {
+	client, err := managedwriter.NewClient(ctx, f.Project, f.clientOptions...)
+	if err != nil {
+		return err
+	}
+	f.client = client
+	return nil
+}
+
+func (f *writeStorageFn) StartBundle(_ context.Context, _ func(StorageWriteFailure), _ func(string)) error {
+	// Close the streams left open by a failed bundle, which are never committed.
+	err := f.closeWriters()
+	f.writers = make(map[string]*storageTableWriter)
+	return err
+}
+
+// ProcessElement appends the rows of a shard of a table in batches. In
+// exactly-once mode, it appends them to a pending stream of their own, which
+// it finalizes and emits the name of to be committed.
+func (f *writeStorageFn) ProcessElement(ctx context.Context, key storageShard, rows func(*beam.X) bool, emit func(StorageWriteFailure), emitStream func(string)) error {
+	w, ok := f.writers[key.Table]
+	if !ok {
+		qn, err := NewQualifiedTableName(key.Table)
+		if err != nil {
+			return err
+		}
+		if w, err = f.openWriter(ctx, qn); err != nil {
+			return errors.Wrapf(err, "failed to open write stream for table %v", qn)
+		}
+		f.writers[key.Table] = w
+	}
+
+	var row beam.X
+	for rows(&row) {
+		w.rows = append(w.rows, row)
+		if len(w.rows) >= f.Options.BatchSize {
+			if err := f.flush(ctx, w, emit); err != nil {
+				return err
+			}
+		}
+	}
+	if err := f.flush(ctx, w, emit); err != nil {
+		return err
+	}
+	if f.Options.Mode != ExactlyOnce {
+		return nil
+	}
+
+	if _, err := w.stream.Finalize(ctx); err != nil {
+		return errors.Wrapf(err, "failed to finalize write stream %v", w.stream.StreamName())
+	}
+	emitStream(w.stream.StreamName())
+	delete(f.writers, key.Table)
+	return closeWriter(w)
+}
+
+// FinishBundle closes the streams of the bundle.
+func (f *writeStorageFn) FinishBundle(_ context.Context, _ func(StorageWriteFailure), _ func(string)) error {
+	return f.closeWriters()
+}
+
+func (f *writeStorageFn) Teardown() error {
+	err := f.closeWriters()
+	if f.client == nil {
+		return err
+	}
+	if cerr := f.client.Close(); err == nil {
+		err = cerr
+	}
+	f.client = nil
+	return err
+}
+
+// closeWriters closes the streams of all writers, and returns the first
+// error.
+func (f *writeStorageFn) closeWriters() error {
+	var err error
+	for _, w := range f.writers {
+		if cerr := closeWriter(w); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	f.writers = nil
+	return err
+}
+
+// closeWriter closes the stream of a writer.
+func closeWriter(w *storageTableWriter) error {
+	// Closing a healthy stream reports io.EOF.
+	if err := w.stream.Close(); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// commitStreamsFn commits the finalized pending streams written in
+// exactly-once mode. Each bundle commits the streams of each table
+// atomically.
+type commitStreamsFn struct {
+	// Project is the project.
+	Project string `json:"project"`
+
+	clientOptions []option.ClientOption
+	client        *managedwriter.Client
+	streams       []string
+}
+
+func (f *commitStreamsFn) Setup(ctx context.Context) error {
+	client, err := managedwriter.NewClient(ctx, f.Project, f.clientOptions...)
+	if err != nil {
+		return err
+	}
+	f.client = client
+	return nil
+}
+
+func (f *commitStreamsFn) StartBundle() {
+	f.streams = nil
+}
+
+func (f *commitStreamsFn) ProcessElement(stream string) {
+	f.streams = append(f.streams, stream)
+}
+
+func (f *commitStreamsFn) FinishBundle(ctx context.Context) error {
+	streams := f.streams
+	f.streams = nil
+	return commitStreams(ctx, f.client, streams)
+}
+
+func (f *commitStreamsFn) Teardown() error {
+	if f.client == nil {
+		return nil
+	}
+	err := f.client.Close()
+	f.client = nil
+	return err
+}
+
+// commitStreams atomically commits the finalized pending streams of each
+// table. Streams that are already committed, such as by a retried bundle, are
+// skipped.
+func commitStreams(ctx context.Context, client *managedwriter.Client, streams []string) error {
+	byTable := make(map[string][]string)
+	for _, stream := range streams {
+		parent := managedwriter.TableParentFromStreamName(stream)
+		byTable[parent] = append(byTable[parent], stream)
+	}
+	for parent, names := range byTable {
+		if err := commitTableStreams(ctx, client, parent, names); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commitTableStreams atomically commits the finalized pending streams of a
+// table. As nothing is committed if any stream fails, the commit is retried
+// without the streams that were already committed.
+func commitTableStreams(ctx context.Context, client *managedwriter.Client, parent string, names []string) error {
+	for len(names) > 0 {
+		resp, err := client.BatchCommitWriteStreams(ctx, &storagepb.BatchCommitWriteStreamsRequest{
+			Parent:       parent,
+			WriteStreams: names,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to commit write streams of table %v", parent)
+		}
+		if len(resp.GetStreamErrors()) == 0 {
+			return nil
+		}
+
+		committed := make(map[string]bool)
+		for _, e := range resp.GetStreamErrors() {
+			if e.GetCode() != storagepb.StorageError_STREAM_ALREADY_COMMITTED {
+				return errors.Errorf("failed to commit write streams of table %v: %v", parent, e.GetErrorMessage())
+			}
+			committed[e.GetEntity()] = true
+		}
+		remaining := names[:0:0]
+		for _, name := range names {
+			if !committed[name] {
+				remaining = append(remaining, name)
+			}
+		}
+		if len(remaining) == len(names) {
+			return errors.Errorf("failed to commit write streams of table %v: unexpected stream errors %v", parent, resp.GetStreamErrors())
+		}
+		names = remaining
+	}
+	return nil
+}
+
+// openWriter opens a stream to the given table, creating the table or adding
+// missing columns to it as configured.
+func (f *writeStorageFn) openWriter(ctx context.Context, table QualifiedTableName) (*storageTableWriter, error) {
+	parent := managedwriter.TableParentFromParts(table.Project, table.Dataset, table.Table)
+	schema, err := f.tableSchema(ctx, parent)
+	if status.Code(err) == codes.NotFound {
+		if f.Options.CreateDisposition == bigquery.CreateNever {
+			return nil, fmt.Errorf("table does not exist and create disposition is 'CreateNever': %v", err)
+		}
+		if err := f.createTable(ctx, table); err != nil {
+			return nil, err
+		}
+		schema, err = f.tableSchema(ctx, parent)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if f.Options.AllowFieldAddition {
+		if schema, err = f.addMissingColumns(ctx, table, schema); err != nil {
+			return nil, err
+		}
+	}
+
+	enc, err := newRowEncoder(schema)
+	if err != nil {
+		return nil, err
+	}
+	opts := []managedwriter.WriterOption{
+		managedwriter.WithDestinationTable(parent),
+		managedwriter.WithSchemaDescriptor(enc.descriptor),
+	}
+	if f.Options.Mode == ExactlyOnce {
+		opts = append(opts, managedwriter.WithType(managedwriter.PendingStream))
+	} else {
+		opts = append(opts, managedwriter.WithType(managedwriter.DefaultStream), managedwriter.EnableWriteRetries(true))
+	}
+	stream, err := f.client.NewManagedStream(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &storageTableWriter{table: table, stream: stream, enc: enc}, nil
+}
+
+// tableSchema returns the schema of the table as seen by the Storage Write API.
+func (f *writeStorageFn) tableSchema(ctx context.Context, parent string) (*storagepb.TableSchema, error) {
+	stream, err := f.client.GetWriteStream(ctx, &storagepb.GetWriteStreamRequest{
+		Name: fmt.Sprintf("%v/streams/_default", parent),
+		View: storagepb.WriteStreamView_FULL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stream.GetTableSchema(), nil
+}
+
+func (f *writeStorageFn) createTable(ctx context.Context, table QualifiedTableName) error {
+	client, err := bigquery.NewClient(ctx, f.Project, f.tableClientOptions...)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	schema := mustInferSchema(f.Type.T)
+	err = client.DatasetInProject(table.Project, table.Dataset).Table(table.Table).Create(ctx, &bigquery.TableMetadata{Schema: schema})
+	if err != nil && !isAlreadyExists(err) {
+		return errors.Wrapf(err, "failed to create table %v", table)
+	}
+	return nil
+}
+
+// addMissingColumns adds the top-level columns of the element type that are
+// not in the given schema to the table, as nullable columns.
+func (f *writeStorageFn) addMissingColumns(ctx context.Context, table QualifiedTableName, schema *storagepb.TableSchema) (*storagepb.TableSchema, error) {
+	existing := make(map[string]bool)
+	for _, field := range schema.GetFields() {
+		existing[strings.ToLower(field.GetName())] = true
+	}
+	var missing bigquery.Schema
+	for _, field := range mustInferSchema(f.Type.T) {
+		if !existing[strings.ToLower(field.Name)] {
+			added := *field
+			added.Required = false
+			missing = append(missing, &added)
+		}
+	}
+	if len(missing) == 0 {
+		return schema, nil
+	}
+
+	client, err := bigquery.NewClient(ctx, f.Project, f.tableClientOptions...)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	t := client.DatasetInProject(table.Project, table.Dataset).Table(table.Table)
+	md, err := t.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	md, err = t.Update(ctx, bigquery.TableMetadataToUpdate{Schema: append(md.Schema, missing...)}, md.ETag)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to add columns to table %v", table)
+	}
+	log.Infof(ctx, "Added %d columns to table %v", len(missing), table)
+	return adapt.BQSchemaToStorageTableSchema(md.Schema)
+}
+
+// flush appends the buffered rows of the writer. Rows that cannot be
+// converted or that the server rejects are emitted as failures, and the
+// remaining rows are appended again.
+func (f *writeStorageFn) flush(ctx context.Context, w *storageTableWriter, emit func(StorageWriteFailure)) error {
+	rows := w.rows
+	w.rows = nil
+
+	var data [][]byte
+	var kept []any
+	for _, row := range rows {
+		b, err := w.enc.encode(reflect.ValueOf(row))
+		if err != nil {
+			emit(newStorageWriteFailure(w.table, row, err))
+			continue
+		}
+		data = append(data, b)
+		kept = append(kept, row)
+	}
+
+	for len(data) > 0 {
+		var opts []managedwriter.AppendOption
+		if w.schemaChanged {
+			opts = append(opts, managedwriter.UpdateSchemaDescriptor(w.enc.descriptor))
+			w.schemaChanged = false
+		}
+		result, err := w.stream.AppendRows(ctx, data, opts...)
+		if err != nil {
+			return errors.Wrapf(err, "failed to append rows to table %v", w.table)
+		}
+		resp, err := result.FullResponse(ctx)
+		if rowErrs := resp.GetRowErrors(); len(rowErrs) > 0 {
+			// The whole request is rejected if any row is invalid.
+			rejected := make(map[int64]string)
+			for _, e := range rowErrs {
+				rejected[e.GetIndex()] = e.GetMessage()
+			}
+			var retryData [][]byte
+			var retryRows []any
+			for i, row := range kept {
+				if msg, ok := rejected[int64(i)]; ok {
+					emit(newStorageWriteFailure(w.table, row, errors.New(msg)))
+					continue
+				}
+				retryData = append(retryData, data[i])
+				retryRows = append(retryRows, row)
+			}
+			data, kept = retryData, retryRows
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to append rows to table %v", w.table)
+		}
+
+		if schema := resp.GetUpdatedSchema(); schema != nil {
+			enc, err := newRowEncoder(schema)
+			if err != nil {
+				return err
+			}
+			w.enc, w.schemaChanged = enc, true
+		}
+		return nil
+	}
+	return nil
+}
+
+func newStorageWriteFailure(table QualifiedTableName, row any, err error) StorageWriteFailure {
+	b, jsonErr := json.Marshal(row)
+	if jsonErr != nil {
+		b = []byte(fmt.Sprintf("%+v", row))
+	}
+	return StorageWriteFailure{Table: table.String(), Row: string(b), Error: err.Error()}
+}
+
+func isAlreadyExists(err error) bool {
+	e, ok := err.(*googleapi.Error)
+	return ok && e.Code == http.StatusConflict
+}
@@ -0,0 +1,720 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigqueryio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"cloud.google.com/go/bigquery/storage/managedwriter/adapt"
+	"cloud.google.com/go/civil"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/option"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	statuspb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type storageWriteRow struct {
+	ID      int64                `bigquery:"id"`
+	Name    string               `bigquery:"name"`
+	Score   bigquery.NullFloat64 `bigquery:"score"`
+	Created time.Time            `bigquery:"created"`
+	Day     civil.Date           `bigquery:"day"`
+	Price   *big.Rat             `bigquery:"price,nullable"`
+	Tags    []string             `bigquery:"tags"`
+	Address storageAddress       `bigquery:"address"`
+}
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*storageWriteRow)(nil)).Elem())
+}
+
+func storageWriteSchema(t *testing.T) *storagepb.TableSchema {
+	t.Helper()
+	schema, err := bigquery.InferSchema(storageWriteRow{})
+	if err != nil {
+		t.Fatalf("InferSchema() failed: %v", err)
+	}
+	ts, err := adapt.BQSchemaToStorageTableSchema(schema)
+	if err != nil {
+		t.Fatalf("BQSchemaToStorageTableSchema() failed: %v", err)
+	}
+	return ts
+}
+
+type fakeWriteStream struct {
+	parent    string
+	rows      []map[string]any
+	finalized bool
+	committed bool
+}
+
+// fakeWriteServer is an in-memory BigQuery Storage Write API. Rows whose name
+// column equals reject are rejected with row errors.
+type fakeWriteServer struct {
+	storagepb.UnimplementedBigQueryWriteServer
+
+	mu        sync.Mutex
+	schemas   map[string]*storagepb.TableSchema
+	streams   map[string]*fakeWriteStream
+	committed map[string][]map[string]any
+	reject    string
+	// updatedSchema is reported in the response of the next append.
+	updatedSchema *storagepb.TableSchema
+	// writerSchemas records the number of fields of each writer schema received.
+	writerSchemas []int
+}
+
+func newFakeWriteServer(t *testing.T) (*fakeWriteServer, []option.ClientOption) {
+	t.Helper()
+	fake := &fakeWriteServer{
+		schemas:   make(map[string]*storagepb.TableSchema),
+		streams:   make(map[string]*fakeWriteStream),
+		committed: make(map[string][]map[string]any),
+	}
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	storagepb.RegisterBigQueryWriteServer(srv, fake)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	return fake, []option.ClientOption{
+		option.WithEndpoint(lis.Addr().String()),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	}
+}
+
+func (s *fakeWriteServer) addTable(table string, schema *storagepb.TableSchema) string {
+	qn, _ := NewQualifiedTableName(table)
+	parent := fmt.Sprintf("projects/%v/datasets/%v/tables/%v", qn.Project, qn.Dataset, qn.Table)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schemas[parent] = schema
+	s.streams[parent+"/streams/_default"] = &fakeWriteStream{parent: parent}
+	return parent
+}
+
+func (s *fakeWriteServer) rows(parent string) []map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.committed[parent]
+}
+
+func (s *fakeWriteServer) GetWriteStream(_ context.Context, req *storagepb.GetWriteStreamRequest) (*storagepb.WriteStream, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stream, ok := s.streams[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "stream %v not found", req.GetName())
+	}
+	return &storagepb.WriteStream{Name: req.GetName(), Type: storagepb.WriteStream_COMMITTED, TableSchema: s.schemas[stream.parent]}, nil
+}
+
+func (s *fakeWriteServer) CreateWriteStream(_ context.Context, req *storagepb.CreateWriteStreamRequest) (*storagepb.WriteStream, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schema, ok := s.schemas[req.GetParent()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "table %v not found", req.GetParent())
+	}
+	name := fmt.Sprintf("%v/streams/s%d", req.GetParent(), len(s.streams))
+	s.streams[name] = &fakeWriteStream{parent: req.GetParent()}
+	return &storagepb.WriteStream{Name: name, Type: req.GetWriteStream().GetType(), TableSchema: schema}, nil
+}
+
+func (s *fakeWriteServer) AppendRows(srv storagepb.BigQueryWrite_AppendRowsServer) error {
+	var name string
+	var md *dynamicpb.Message
+	for {
+		req, err := srv.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if req.GetWriteStream() != "" {
+			name = req.GetWriteStream()
+		}
+		if dp := req.GetProtoRows().GetWriterSchema().GetProtoDescriptor(); dp != nil {
+			fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+				Name:        proto.String("fake.proto"),
+				Syntax:      proto.String("proto2"),
+				MessageType: []*descriptorpb.DescriptorProto{dp},
+			}, nil)
+			if err != nil {
+				return err
+			}
+			md = dynamicpb.NewMessage(fd.Messages().Get(0))
+			s.mu.Lock()
+			s.writerSchemas = append(s.writerSchemas, len(dp.GetField()))
+			s.mu.Unlock()
+		}
+
+		var rows []map[string]any
+		var rowErrs []*storagepb.RowError
+		for i, b := range req.GetProtoRows().GetRows().GetSerializedRows() {
+			msg := md.New()
+			if err := proto.Unmarshal(b, msg.Interface()); err != nil {
+				return err
+			}
+			j, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg.Interface())
+			if err != nil {
+				return err
+			}
+			var row map[string]any
+			if err := json.Unmarshal(j, &row); err != nil {
+				return err
+			}
+			if s.reject != "" && row["name"] == s.reject {
+				rowErrs = append(rowErrs, &storagepb.RowError{Index: int64(i), Code: storagepb.RowError_FIELDS_ERROR, Message: "rejected"})
+			}
+			rows = append(rows, row)
+		}
+
+		resp := &storagepb.AppendRowsResponse{WriteStream: name}
+		s.mu.Lock()
+		if len(rowErrs) > 0 {
+			resp.Response = &storagepb.AppendRowsResponse_Error{Error: &statuspb.Status{Code: int32(code.Code_INVALID_ARGUMENT), Message: "row errors"}}
+			resp.RowErrors = rowErrs
+		} else {
+			stream := s.streams[name]
+			if strings.HasSuffix(name, "/_default") {
+				s.committed[stream.parent] = append(s.committed[stream.parent], rows...)
+			} else {
+				stream.rows = append(stream.rows, rows...)
+			}
+			resp.Response = &storagepb.AppendRowsResponse_AppendResult_{AppendResult: &storagepb.AppendRowsResponse_AppendResult{}}
+			resp.UpdatedSchema, s.updatedSchema = s.updatedSchema, nil
+		}
+		s.mu.Unlock()
+		if err := srv.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *fakeWriteServer) FinalizeWriteStream(_ context.Context, req *storagepb.FinalizeWriteStreamRequest) (*storagepb.FinalizeWriteStreamResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stream, ok := s.streams[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "stream %v not found", req.GetName())
+	}
+	stream.finalized = true
+	return &storagepb.FinalizeWriteStreamResponse{RowCount: int64(len(stream.rows))}, nil
+}
+
+func (s *fakeWriteServer) BatchCommitWriteStreams(_ context.Context, req *storagepb.BatchCommitWriteStreamsRequest) (*storagepb.BatchCommitWriteStreamsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []*storagepb.StorageError
+	for _, name := range req.GetWriteStreams() {
+		if stream, ok := s.streams[name]; !ok || !stream.finalized {
+			errs = append(errs, &storagepb.StorageError{Code: storagepb.StorageError_STREAM_NOT_FOUND, Entity: name, ErrorMessage: "not finalized"})
+		} else if stream.committed {
+			errs = append(errs, &storagepb.StorageError{Code: storagepb.StorageError_STREAM_ALREADY_COMMITTED, Entity: name, ErrorMessage: "already committed"})
+		}
+	}
+	if len(errs) > 0 {
+		return &storagepb.BatchCommitWriteStreamsResponse{StreamErrors: errs}, nil
+	}
+	for _, name := range req.GetWriteStreams() {
+		stream := s.streams[name]
+		stream.committed = true
+		s.committed[req.GetParent()] = append(s.committed[req.GetParent()], stream.rows...)
+	}
+	return &storagepb.BatchCommitWriteStreamsResponse{CommitTime: timestamppb.Now()}, nil
+}
+
+var storageWriteRows = []storageWriteRow{
+	{
+		ID:      1,
+		Name:    "alice",
+		Score:   bigquery.NullFloat64{Float64: 1.5, Valid: true},
+		Created: storageCreated,
+		Day:     civil.Date{Year: 2024, Month: 3, Day: 1},
+		Price:   big.NewRat(1234, 100),
+		Tags:    []string{"a", "b"},
+		Address: storageAddress{City: "Seattle", Zip: 98101},
+	},
+	{
+		ID:      2,
+		Name:    "bob",
+		Created: storageCreated,
+		Day:     civil.Date{Year: 2024, Month: 3, Day: 2},
+		Address: storageAddress{City: "Portland", Zip: 97201},
+	},
+	{
+		ID:      3,
+		Name:    "carol",
+		Created: storageCreated,
+		Day:     civil.Date{Year: 2024, Month: 3, Day: 3},
+		Address: storageAddress{City: "Tacoma", Zip: 98402},
+	},
+}
+
+// writeBundle writes the rows as a single shard of a bundle and returns the
+// failed rows and the pending streams to commit.
+func writeBundle(t *testing.T, fn *writeStorageFn, table string, rows []storageWriteRow) ([]StorageWriteFailure, []string) {
+	t.Helper()
+	ctx := context.Background()
+	var failures []StorageWriteFailure
+	var streams []string
+	emit := func(f StorageWriteFailure) { failures = append(failures, f) }
+	emitStream := func(s string) { streams = append(streams, s) }
+
+	if err := fn.StartBundle(ctx, emit, emitStream); err != nil {
+		t.Fatalf("StartBundle() failed: %v", err)
+	}
+	if err := fn.ProcessElement(ctx, storageShard{Table: table}, rowIter(rows), emit, emitStream); err != nil {
+		t.Fatalf("ProcessElement() failed: %v", err)
+	}
+	if err := fn.FinishBundle(ctx, emit, emitStream); err != nil {
+		t.Fatalf("FinishBundle() failed: %v", err)
+	}
+	return failures, streams
+}
+
+// rowIter returns an iterator over the rows of a shard.
+func rowIter(rows []storageWriteRow) func(*beam.X) bool {
+	return func(row *beam.X) bool {
+		if len(rows) == 0 {
+			return false
+		}
+		*row, rows = rows[0], rows[1:]
+		return true
+	}
+}
+
+// commitBundle commits the streams as a single bundle.
+func commitBundle(t *testing.T, clientOptions []option.ClientOption, streams []string) {
+	t.Helper()
+	ctx := context.Background()
+	fn := &commitStreamsFn{Project: "p", clientOptions: clientOptions}
+	if err := fn.Setup(ctx); err != nil {
+		t.Fatalf("Setup() failed: %v", err)
+	}
+	defer fn.Teardown()
+
+	fn.StartBundle()
+	for _, stream := range streams {
+		fn.ProcessElement(stream)
+	}
+	if err := fn.FinishBundle(ctx); err != nil {
+		t.Fatalf("FinishBundle() failed: %v", err)
+	}
+}
+
+func newTestWriteStorageFn(t *testing.T, clientOptions []option.ClientOption, options ...StorageWriteOption) *writeStorageFn {
+	t.Helper()
+	opts := newStorageWriteOptions()
+	for _, opt := range options {
+		if err := opt(&opts); err != nil {
+			t.Fatalf("invalid option: %v", err)
+		}
+	}
+	fn := &writeStorageFn{
+		Project:       "p",
+		Type:          beam.EncodedType{T: reflect.TypeOf(storageWriteRow{})},
+		Options:       opts,
+		clientOptions: clientOptions,
+	}
+	if err := fn.Setup(context.Background()); err != nil {
+		t.Fatalf("Setup() failed: %v", err)
+	}
+	t.Cleanup(func() { fn.Teardown() })
+	return fn
+}
+
+func committedIDs(rows []map[string]any) []string {
+	var ids []string
+	for _, row := range rows {
+		ids = append(ids, fmt.Sprint(row["id"]))
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestWriteStorageFn_exactlyOnce(t *testing.T) {
+	fake, clientOptions := newFakeWriteServer(t)
+	parent := fake.addTable("p:d.t", storageWriteSchema(t))
+	fn := newTestWriteStorageFn(t, clientOptions, WithAppendBatchSize(2))
+
+	failures, streams := writeBundle(t, fn, "p:d.t", storageWriteRows)
+	if len(failures) > 0 {
+		t.Fatalf("write failed for rows %v", failures)
+	}
+	if len(streams) != 1 {
+		t.Fatalf("write emitted streams %v, want a single stream", streams)
+	}
+	if got := fake.rows(parent); len(got) != 0 {
+		t.Fatalf("rows committed before the streams were committed: %v", got)
+	}
+
+	commitBundle(t, clientOptions, streams)
+	got := fake.rows(parent)
+	if diff := cmp.Diff([]string{"1", "2", "3"}, committedIDs(got)); diff != "" {
+		t.Errorf("committed rows mismatch (-want +got):\n%s", diff)
+	}
+	want := map[string]any{
+		"id":      "1",
+		"name":    "alice",
+		"score":   1.5,
+		"created": fmt.Sprint(storageCreated.UnixMicro()),
+		"day":     float64(19783),
+		"price":   "AHWF3wI=",
+		"tags":    []any{"a", "b"},
+		"address": map[string]any{"city": "Seattle", "zip": "98101"},
+	}
+	for _, row := range got {
+		if row["id"] == "1" {
+			if diff := cmp.Diff(want, row); diff != "" {
+				t.Errorf("encoded row mismatch (-want +got):\n%s", diff)
+			}
+		}
+	}
+}
+
+func TestWriteStorageFn_atLeastOnce(t *testing.T) {
+	fake, clientOptions := newFakeWriteServer(t)
+	parent := fake.addTable("p:d.t", storageWriteSchema(t))
+	fn := newTestWriteStorageFn(t, clientOptions, WithWriteMode(AtLeastOnce))
+
+	failures, streams := writeBundle(t, fn, "p:d.t", storageWriteRows)
+	if len(failures) > 0 {
+		t.Fatalf("write failed for rows %v", failures)
+	}
+	if len(streams) != 0 {
+		t.Errorf("write emitted streams %v to commit, want none", streams)
+	}
+	if diff := cmp.Diff([]string{"1", "2", "3"}, committedIDs(fake.rows(parent))); diff != "" {
+		t.Errorf("committed rows mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteStorageFn_rowErrors(t *testing.T) {
+	fake, clientOptions := newFakeWriteServer(t)
+	parent := fake.addTable("p:d.t", storageWriteSchema(t))
+	fake.reject = "bob"
+	fn := newTestWriteStorageFn(t, clientOptions, WithWriteMode(AtLeastOnce))
+
+	failures, _ := writeBundle(t, fn, "p:d.t", storageWriteRows)
+	if len(failures) != 1 {
+		t.Fatalf("got failures %v, want a single failure", failures)
+	}
+	if got := failures[0]; got.Table != "p:d.t" || got.Error != "rejected" || !strings.Contains(got.Row, `"Name":"bob"`) {
+		t.Errorf("got failure %+v, want the rejected row of bob", got)
+	}
+	if diff := cmp.Diff([]string{"1", "3"}, committedIDs(fake.rows(parent))); diff != "" {
+		t.Errorf("committed rows mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteStorageFn_missingColumn(t *testing.T) {
+	fake, clientOptions := newFakeWriteServer(t)
+	schema := storageWriteSchema(t)
+	schema.Fields = schema.Fields[:len(schema.Fields)-1]
+	parent := fake.addTable("p:d.t", schema)
+	fn := newTestWriteStorageFn(t, clientOptions, WithWriteMode(AtLeastOnce))
+
+	failures, _ := writeBundle(t, fn, "p:d.t", storageWriteRows)
+	if len(failures) != len(storageWriteRows) {
+		t.Fatalf("got %d failures, want %d", len(failures), len(storageWriteRows))
+	}
+	if got := failures[0].Error; !strings.Contains(got, "address is not in the table schema") {
+		t.Errorf("got failure error %q, want missing column error", got)
+	}
+	if got := fake.rows(parent); len(got) != 0 {
+		t.Errorf("committed rows %v, want none", got)
+	}
+}
+
+func TestWriteStorageFn_schemaUpdate(t *testing.T) {
+	fake, clientOptions := newFakeWriteServer(t)
+	schema := storageWriteSchema(t)
+	parent := fake.addTable("p:d.t", schema)
+	updated := proto.Clone(schema).(*storagepb.TableSchema)
+	updated.Fields = append(updated.Fields, &storagepb.TableFieldSchema{Name: "extra", Type: storagepb.TableFieldSchema_STRING, Mode: storagepb.TableFieldSchema_NULLABLE})
+	fake.updatedSchema = updated
+	fn := newTestWriteStorageFn(t, clientOptions, WithWriteMode(AtLeastOnce), WithAppendBatchSize(1))
+
+	if failures, _ := writeBundle(t, fn, "p:d.t", storageWriteRows[:2]); len(failures) > 0 {
+		t.Fatalf("write failed for rows %v", failures)
+	}
+	n := len(schema.GetFields())
+	if diff := cmp.Diff([]int{n, n + 1}, fake.writerSchemas); diff != "" {
+		t.Errorf("writer schema field counts mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"1", "2"}, committedIDs(fake.rows(parent))); diff != "" {
+		t.Errorf("committed rows mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteStorageFn_dynamicDestinations(t *testing.T) {
+	fake, clientOptions := newFakeWriteServer(t)
+	parentA := fake.addTable("p:d.a", storageWriteSchema(t))
+	parentB := fake.addTable("p:d.b", storageWriteSchema(t))
+	fn := newTestWriteStorageFn(t, clientOptions)
+
+	ctx := context.Background()
+	var streams []string
+	emit := func(f StorageWriteFailure) { t.Errorf("write failed for row %v", f) }
+	emitStream := func(s string) { streams = append(streams, s) }
+	fn.StartBundle(ctx, emit, emitStream)
+	shards := []struct {
+		table string
+		rows  []storageWriteRow
+	}{
+		{"p:d.a", storageWriteRows[:1]},
+		{"p:d.b", storageWriteRows[1:2]},
+		{"p:d.a", storageWriteRows[2:]},
+	}
+	for i, shard := range shards {
+		if err := fn.ProcessElement(ctx, storageShard{Table: shard.table, Shard: i}, rowIter(shard.rows), emit, emitStream); err != nil {
+			t.Fatalf("ProcessElement() failed: %v", err)
+		}
+	}
+	if len(streams) != len(shards) {
+		t.Errorf("ProcessElement() emitted streams %v, want one per shard", streams)
+	}
+	if err := fn.FinishBundle(ctx, emit, emitStream); err != nil {
+		t.Fatalf("FinishBundle() failed: %v", err)
+	}
+	commitBundle(t, clientOptions, streams)
+
+	if diff := cmp.Diff([]string{"1", "3"}, committedIDs(fake.rows(parentA))); diff != "" {
+		t.Errorf("rows committed to table a mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"2"}, committedIDs(fake.rows(parentB))); diff != "" {
+		t.Errorf("rows committed to table b mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteStorageFn_tableNotFound(t *testing.T) {
+	_, clientOptions := newFakeWriteServer(t)
+	fn := newTestWriteStorageFn(t, clientOptions, WithStorageCreateDisposition(bigquery.CreateNever))
+
+	fn.StartBundle(context.Background(), func(StorageWriteFailure) {}, func(string) {})
+	err := fn.ProcessElement(context.Background(), storageShard{Table: "p:d.missing"}, rowIter(storageWriteRows[:1]), func(StorageWriteFailure) {}, func(string) {})
+	if err == nil || !strings.Contains(err.Error(), "CreateNever") {
+		t.Errorf("ProcessElement() error = %v, want table not found error", err)
+	}
+}
+
+func TestWriteStorageFn_retriedCommit(t *testing.T) {
+	fake, clientOptions := newFakeWriteServer(t)
+	parentA := fake.addTable("p:d.a", storageWriteSchema(t))
+	parentB := fake.addTable("p:d.b", storageWriteSchema(t))
+	fn := newTestWriteStorageFn(t, clientOptions)
+
+	_, streamsA := writeBundle(t, fn, "p:d.a", storageWriteRows[:2])
+	_, streamsB := writeBundle(t, fn, "p:d.b", storageWriteRows[2:])
+	// The commit of the first stream succeeds but its bundle fails, so the
+	// retried bundle commits it again along with the second stream.
+	commitBundle(t, clientOptions, streamsA)
+	commitBundle(t, clientOptions, append(streamsA, streamsB...))
+
+	if diff := cmp.Diff([]string{"1", "2"}, committedIDs(fake.rows(parentA))); diff != "" {
+		t.Errorf("rows committed to table a mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"3"}, committedIDs(fake.rows(parentB))); diff != "" {
+		t.Errorf("rows committed to table b mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteStorageFn_failedBundle(t *testing.T) {
+	fake, clientOptions := newFakeWriteServer(t)
+	parent := fake.addTable("p:d.t", storageWriteSchema(t))
+	fn := newTestWriteStorageFn(t, clientOptions, WithAppendBatchSize(1))
+
+	// The first bundle fails after appending its rows, before finalizing
+	// its stream.
+	ctx, cancel := context.WithCancel(context.Background())
+	emit := func(f StorageWriteFailure) { t.Errorf("write failed for row %v", f) }
+	emitStream := func(s string) { t.Errorf("failed bundle emitted stream %v", s) }
+	next := rowIter(storageWriteRows[:2])
+	rows := func(row *beam.X) bool {
+		if next(row) {
+			return true
+		}
+		cancel()
+		return false
+	}
+	fn.StartBundle(ctx, emit, emitStream)
+	if err := fn.ProcessElement(ctx, storageShard{Table: "p:d.t"}, rows, emit, emitStream); err == nil {
+		t.Fatal("ProcessElement() succeeded, want error of the failed bundle")
+	}
+
+	_, streams := writeBundle(t, fn, "p:d.t", storageWriteRows[2:])
+	if len(fn.writers) != 0 {
+		t.Errorf("writers of the failed bundle are still open: %v", fn.writers)
+	}
+	commitBundle(t, clientOptions, streams)
+	if diff := cmp.Diff([]string{"3"}, committedIDs(fake.rows(parent))); diff != "" {
+		t.Errorf("committed rows mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// newFakeTableServer starts a fake of the table endpoints of the BigQuery API
+// that creates and updates the tables of the fake write server, and returns
+// the client options to use it. Existing tables have the given schema.
+func newFakeTableServer(t *testing.T, fake *fakeWriteServer, existing bigquery.Schema) []option.ClientOption {
+	t.Helper()
+	fields, err := existing.ToJSONFields()
+	if err != nil {
+		t.Fatalf("failed to encode schema: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var table map[string]any
+		switch {
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/projects/p/datasets/d/tables"):
+			if err := json.NewDecoder(r.Body).Decode(&table); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			ref := table["tableReference"].(map[string]any)
+			fake.addTable(fmt.Sprintf("%v:%v.%v", ref["projectId"], ref["datasetId"], ref["tableId"]), storageWriteSchema(t))
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/projects/p/datasets/d/tables/t"):
+			table = map[string]any{"schema": map[string]any{"fields": json.RawMessage(fields)}, "etag": "etag"}
+		case r.Method == http.MethodPatch && strings.HasSuffix(r.URL.Path, "/projects/p/datasets/d/tables/t"):
+			if err := json.NewDecoder(r.Body).Decode(&table); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			fake.addTable("p:d.t", storageWriteSchema(t))
+		default:
+			http.Error(w, "unexpected request "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(table)
+	}))
+	t.Cleanup(server.Close)
+	return []option.ClientOption{option.WithEndpoint(server.URL), option.WithoutAuthentication()}
+}
+
+func TestWriteStorageFn_createTable(t *testing.T) {
+	fake, clientOptions := newFakeWriteServer(t)
+	fn := newTestWriteStorageFn(t, clientOptions, WithWriteMode(AtLeastOnce))
+	fn.tableClientOptions = newFakeTableServer(t, fake, nil)
+
+	if failures, _ := writeBundle(t, fn, "p:d.t", storageWriteRows); len(failures) > 0 {
+		t.Fatalf("write failed for rows %v", failures)
+	}
+	if diff := cmp.Diff([]string{"1", "2", "3"}, committedIDs(fake.rows("projects/p/datasets/d/tables/t"))); diff != "" {
+		t.Errorf("committed rows mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteStorageFn_fieldAddition(t *testing.T) {
+	fake, clientOptions := newFakeWriteServer(t)
+	schema := storageWriteSchema(t)
+	schema.Fields = schema.Fields[:len(schema.Fields)-1]
+	parent := fake.addTable("p:d.t", schema)
+	existing := mustInferSchema(reflect.TypeOf(storageWriteRow{}))
+	fn := newTestWriteStorageFn(t, clientOptions, WithWriteMode(AtLeastOnce), WithFieldAddition())
+	fn.tableClientOptions = newFakeTableServer(t, fake, existing[:len(existing)-1])
+
+	if failures, _ := writeBundle(t, fn, "p:d.t", storageWriteRows); len(failures) > 0 {
+		t.Fatalf("write failed for rows %v", failures)
+	}
+	if diff := cmp.Diff([]string{"1", "2", "3"}, committedIDs(fake.rows(parent))); diff != "" {
+		t.Errorf("committed rows mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPackCivilTypes(t *testing.T) {
+	if got, want := packTime(civil.Time{Hour: 12, Minute: 34, Second: 56, Nanosecond: 789000000}), int64(53880818184); got != want {
+		t.Errorf("packTime() = %v, want %v", got, want)
+	}
+	dt := civil.DateTime{Date: civil.Date{Year: 2024, Month: 3, Day: 1}, Time: civil.Time{Hour: 12, Minute: 30, Second: 15, Nanosecond: 250000}}
+	if got, want := packDateTime(dt), int64(142439723362681082); got != want {
+		t.Errorf("packDateTime() = %v, want %v", got, want)
+	}
+}
+
+func TestPackDecimal(t *testing.T) {
+	tests := []struct {
+		r     *big.Rat
+		scale int64
+		want  []byte
+	}{
+		{big.NewRat(0, 1), 0, []byte{0}},
+		{big.NewRat(1234, 100), 9, []byte{0, 117, 133, 223, 2}},
+		{big.NewRat(128, 1), 0, []byte{128, 0}},
+		{big.NewRat(-1, 1), 0, []byte{255}},
+		{big.NewRat(-129, 1), 0, []byte{127, 255}},
+	}
+	for _, test := range tests {
+		if got := packDecimal(test.r, test.scale); !slices.Equal(got, test.want) {
+			t.Errorf("packDecimal(%v, %v) = %v, want %v", test.r, test.scale, got, test.want)
+		}
+	}
+}
+
+func TestStorageWriteOptions(t *testing.T) {
+	for _, opt := range []StorageWriteOption{WithWriteMode("ONCE"), WithAppendBatchSize(0), WithNumStreams(0)} {
+		o := newStorageWriteOptions()
+		if err := opt(&o); err == nil {
+			t.Errorf("option succeeded, want error")
+		}
+	}
+}
+
+func TestWriteStorage_construction(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	rows := beam.CreateList(s, storageWriteRows)
+	failures := WriteStorage(s, "p", "p:d.t", rows)
+	if got, want := failures.Type().Type(), reflect.TypeOf(StorageWriteFailure{}); got != want {
+		t.Errorf("WriteStorage() output type = %v, want %v", got, want)
+	}
+
+	keyed := beam.ParDo(s, func(r storageWriteRow) (string, storageWriteRow) { return "p:d.t", r }, rows)
+	WriteStorageDynamic(s, "p", keyed, WithWriteMode(AtLeastOnce))
+	if _, _, err := p.Build(); err != nil {
+		t.Errorf("Build() failed: %v", err)
+	}
+}
@@ -0,0 +1,810 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtableio
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+	btpb "cloud.google.com/go/bigtable/apiv2/bigtablepb"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"google.golang.org/api/option"
+	gtransport "google.golang.org/api/transport/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	bigtableEndpoint         = "bigtable.googleapis.com:443"
+	defaultHeartbeatDuration = 1 * time.Second
+	// maxReadTime is how long the change stream is read before the
+	// restriction is checkpointed.
+	maxReadTime = 10 * time.Second
+)
+
+func init() {
+	register.DoFn5x2[
+		context.Context, *watermarkEstimator, *sdf.LockRTracker, []byte,
+		func(beam.EventTime, ChangeStreamMutation), sdf.ProcessContinuation, error,
+	](&changeStreamFn{})
+	register.Emitter2[beam.EventTime, ChangeStreamMutation]()
+}
+
+// ChangeStreamMutation is a change to a row read from the change stream of
+// a table.
+type ChangeStreamMutation struct {
+	RowKey string
+	// Type is "USER" for changes made by users and "GARBAGE_COLLECTION" for
+	// changes made by garbage collection.
+	Type string
+	// SourceClusterID is the cluster the change was made in, for changes
+	// made by users.
+	SourceClusterID string
+	CommitTimestamp bigtable.Timestamp
+	// TieBreaker orders changes to the same row with the same commit
+	// timestamp made in different clusters. The change with the highest
+	// TieBreaker is the one that is kept.
+	TieBreaker int32
+	Entries    []ChangeStreamEntry
+	// Token is the continuation token of the change stream after the change.
+	Token string
+	// EstimatedLowWatermark is the estimated low watermark of the partition
+	// of the change stream after the change.
+	EstimatedLowWatermark bigtable.Timestamp
+}
+
+// ChangeStreamEntry is a single mutation of a ChangeStreamMutation.
+type ChangeStreamEntry struct {
+	// Type is the type of the mutation, which is one of "SetCell",
+	// "DeleteFromColumn", "DeleteFromFamily", "DeleteFromRow", "AddToCell" or
+	// "MergeToCell".
+	Type   string
+	Family string
+	Column string
+	// Timestamp is the timestamp of the cell of SetCell, AddToCell and
+	// MergeToCell mutations.
+	Timestamp bigtable.Timestamp
+	// Value is the value of SetCell mutations, or the input of AddToCell
+	// and MergeToCell mutations with integers encoded as 8-byte big-endian.
+	Value []byte
+	// StartTimestamp and EndTimestamp are the range [StartTimestamp,
+	// EndTimestamp) of cells deleted by DeleteFromColumn mutations. An
+	// EndTimestamp of 0 means the range is unbounded.
+	StartTimestamp bigtable.Timestamp
+	EndTimestamp   bigtable.Timestamp
+}
+
+// ChangeStreamOption represents options for reading the change stream of a
+// table.
+type ChangeStreamOption struct {
+	StartTime         time.Time
+	EndTime           time.Time
+	HeartbeatDuration time.Duration
+	AppProfile        string
+}
+
+// ChangeStreamOptionFn is a function that configures a ChangeStreamOption.
+type ChangeStreamOptionFn func(option *ChangeStreamOption) error
+
+// WithChangeStreamStartTime configures the ChangeStreamOption to start
+// reading changes committed at or after the provided time.
+func WithChangeStreamStartTime(startTime time.Time) ChangeStreamOptionFn {
+	return func(o *ChangeStreamOption) error {
+		if startTime.IsZero() {
+			return errors.New("start time must be set")
+		}
+		o.StartTime = startTime
+		return nil
+	}
+}
+
+// WithChangeStreamEndTime configures the ChangeStreamOption to stop reading
+// at the provided time, which makes the read end once all changes committed
+// before it have been read.
+func WithChangeStreamEndTime(endTime time.Time) ChangeStreamOptionFn {
+	return func(o *ChangeStreamOption) error {
+		if endTime.IsZero() {
+			return errors.New("end time must be set")
+		}
+		o.EndTime = endTime
+		return nil
+	}
+}
+
+// WithChangeStreamHeartbeatDuration configures the ChangeStreamOption to
+// use the provided interval of heartbeats sent by the server when there are
+// no changes, which advance the watermark.
+func WithChangeStreamHeartbeatDuration(heartbeatDuration time.Duration) ChangeStreamOptionFn {
+	return func(o *ChangeStreamOption) error {
+		if heartbeatDuration <= 0 {
+			return errors.New("heartbeat duration must be greater than 0")
+		}
+		o.HeartbeatDuration = heartbeatDuration
+		return nil
+	}
+}
+
+// WithChangeStreamAppProfile configures the ChangeStreamOption to use the
+// provided app profile, which must use single-cluster routing.
+func WithChangeStreamAppProfile(appProfile string) ChangeStreamOptionFn {
+	return func(o *ChangeStreamOption) error {
+		o.AppProfile = appProfile
+		return nil
+	}
+}
+
+// ReadChangeStream reads the change stream of a bigtable table and returns
+// an unbounded PCollection<ChangeStreamMutation>. The change stream must be
+// enabled on the table.
+//
+// The ReadChangeStream transform takes a variadic number of
+// ChangeStreamOptionFn which can set the ChangeStreamOption fields:
+//   - StartTime: the time to start reading changes from. Defaults to the
+//     time the pipeline starts
+//   - EndTime: the time to stop reading changes at. Defaults to none, which
+//     means the read never ends
+//   - HeartbeatDuration: the interval of heartbeats sent when there are no
+//     changes. Defaults to 1 second
+//   - AppProfile: the app profile to read with. Defaults to the default app
+//     profile of the instance
+//
+// The partitions of the change stream are read concurrently and followed as
+// they are split and merged. The restrictions of the read hold the
+// continuation tokens of their partitions, so that the change stream resumes
+// after the last change read when the read is checkpointed or retried, and
+// can be split between their partitions to read them in parallel. A merged
+// partition is read once the continuation tokens of all partitions it is
+// merged from have been received. If some of them were split off into other
+// restrictions, it is read from its watermark instead by the restriction that
+// read the partition at the start of its range, which may emit changes that
+// were read before the merge again.
+//
+// The event time of each change is its commit timestamp and the watermark is
+// the minimum of the low watermarks of the partitions of a restriction.
+func ReadChangeStream(s beam.Scope, project, instanceID, table string, opts ...ChangeStreamOptionFn) beam.PCollection {
+	s = s.Scope("bigtable.ReadChangeStream")
+
+	option := &ChangeStreamOption{
+		HeartbeatDuration: defaultHeartbeatDuration,
+	}
+	for _, opt := range opts {
+		if err := opt(option); err != nil {
+			panic(fmt.Sprintf("bigtableio.ReadChangeStream: invalid option: %v", err))
+		}
+	}
+
+	fn := &changeStreamFn{
+		Project:           project,
+		InstanceID:        instanceID,
+		TableName:         table,
+		AppProfile:        option.AppProfile,
+		HeartbeatDuration: option.HeartbeatDuration,
+	}
+	if !option.StartTime.IsZero() {
+		fn.StartTime = option.StartTime.UnixMicro()
+	}
+	if !option.EndTime.IsZero() {
+		fn.EndTime = option.EndTime.UnixMicro()
+	}
+
+	imp := beam.Impulse(s)
+	return beam.ParDo(s, fn, imp)
+}
+
+type changeStreamFn struct {
+	// Project is the project
+	Project string `json:"project"`
+	// InstanceID is the bigtable instanceID
+	InstanceID string `json:"instanceId"`
+	// TableName is the table identifier.
+	TableName string `json:"tableName"`
+	// AppProfile is the app profile to read with.
+	AppProfile string `json:"appProfile"`
+	// StartTime and EndTime are the times in microseconds to read between.
+	StartTime int64 `json:"startTime"`
+	EndTime   int64 `json:"endTime"`
+	// HeartbeatDuration is the interval of heartbeats.
+	HeartbeatDuration time.Duration `json:"heartbeatDuration"`
+	// conn is the connection to bigtable
+	conn *grpc.ClientConn `json:"-"`
+	// client is the bigtable data API client
+	client btpb.BigtableClient `json:"-"`
+}
+
+func (fn *changeStreamFn) Setup(ctx context.Context) error {
+	var err error
+	if addr := os.Getenv("BIGTABLE_EMULATOR_HOST"); addr != "" {
+		fn.conn, err = grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	} else {
+		fn.conn, err = gtransport.Dial(ctx, option.WithEndpoint(bigtableEndpoint), option.WithScopes(bigtable.Scope))
+	}
+	if err != nil {
+		return fmt.Errorf("could not create data operations client: %v", err)
+	}
+	fn.client = btpb.NewBigtableClient(fn.conn)
+	return nil
+}
+
+func (fn *changeStreamFn) Teardown() error {
+	if fn.conn == nil {
+		return nil
+	}
+	if err := fn.conn.Close(); err != nil {
+		return fmt.Errorf("could not close data operations client: %v", err)
+	}
+	return nil
+}
+
+func (fn *changeStreamFn) CreateInitialRestriction(_ []byte) changeStreamRestriction {
+	startTime := fn.StartTime
+	if startTime == 0 {
+		startTime = time.Now().UnixMicro()
+	}
+	return changeStreamRestriction{StartTime: startTime}
+}
+
+func (fn *changeStreamFn) SplitRestriction(_ []byte, rest changeStreamRestriction) []changeStreamRestriction {
+	return []changeStreamRestriction{rest}
+}
+
+func (fn *changeStreamFn) CreateTracker(rest changeStreamRestriction) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(newChangeStreamTracker(rest))
+}
+
+// RestrictionSize returns the size of each restriction as its number of
+// partitions, or 1 before the partitions have been listed.
+func (fn *changeStreamFn) RestrictionSize(_ []byte, rest changeStreamRestriction) float64 {
+	if !rest.Initialized {
+		return 1
+	}
+	return float64(len(rest.Partitions) + len(rest.Pending))
+}
+
+func (fn *changeStreamFn) InitialWatermarkEstimatorState(_ beam.EventTime, _ changeStreamRestriction, _ []byte) int64 {
+	return math.MinInt64
+}
+
+func (fn *changeStreamFn) CreateWatermarkEstimator(state int64) *watermarkEstimator {
+	return &watermarkEstimator{state: state}
+}
+
+func (fn *changeStreamFn) WatermarkEstimatorState(we *watermarkEstimator) int64 {
+	return we.state
+}
+
+// streamRecord is a record read from a partition of the change stream.
+// Exactly one of its fields other than partition is set.
+type streamRecord struct {
+	partition keyRange
+	mutation  *ChangeStreamMutation
+	heartbeat *btpb.ReadChangeStreamResponse_Heartbeat
+	close     *btpb.ReadChangeStreamResponse_CloseStream
+	err       error
+	// done is set by the last record read from the partition.
+	done bool
+}
+
+func (fn *changeStreamFn) ProcessElement(
+	ctx context.Context,
+	we *watermarkEstimator,
+	rt *sdf.LockRTracker,
+	_ []byte,
+	emit func(beam.EventTime, ChangeStreamMutation),
+) (sdf.ProcessContinuation, error) {
+	rest := rt.GetRestriction().(changeStreamRestriction)
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-goog-request-params", fn.requestParams())
+
+	if !rest.Initialized {
+		partitions, err := fn.initialPartitions(ctx, rest.StartTime)
+		if err != nil {
+			return sdf.StopProcessing(), err
+		}
+		if !rt.TryClaim(listedPartitions(partitions)) {
+			return sdf.StopProcessing(), rt.GetError()
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, maxReadTime)
+	defer cancel()
+
+	// reading holds the functions cancelling the reads of the partitions
+	// being read, by their ranges.
+	records := make(chan streamRecord)
+	reading := make(map[keyRange]context.CancelFunc)
+	running := 0
+	readPartitions := func() {
+		for _, p := range rt.GetRestriction().(changeStreamRestriction).Partitions {
+			if _, ok := reading[p.Range]; ok {
+				continue
+			}
+			pctx, pcancel := context.WithCancel(ctx)
+			reading[p.Range] = pcancel
+			running++
+			go fn.readPartition(pctx, p, records)
+		}
+	}
+	readPartitions()
+
+	var err error
+	stopped := false
+	for running > 0 {
+		r := <-records
+		if r.done {
+			running--
+			if pcancel, ok := reading[r.partition]; ok {
+				pcancel()
+				delete(reading, r.partition)
+			}
+		}
+		if stopped {
+			continue
+		}
+		if r.err != nil {
+			err = r.err
+			stopped = true
+			cancel()
+			continue
+		}
+
+		var pos any
+		switch {
+		case r.mutation != nil:
+			pos = partitionProgress{
+				Range:     r.partition,
+				Token:     streamToken{Range: r.partition, Token: r.mutation.Token},
+				Watermark: int64(r.mutation.EstimatedLowWatermark),
+			}
+		case r.heartbeat != nil:
+			pos = partitionProgress{
+				Range:     r.partition,
+				Token:     newStreamToken(r.heartbeat.GetContinuationToken()),
+				Watermark: int64(timestamp(r.heartbeat.GetEstimatedLowWatermark())),
+			}
+		case r.close != nil:
+			partitions, tokens, closeErr := continuations(r.close)
+			if closeErr != nil {
+				err = fmt.Errorf("change stream partition %v closed: %v", r.partition, closeErr)
+				stopped = true
+				cancel()
+				continue
+			}
+			pos = partitionClosed{Range: r.partition, Partitions: partitions, Tokens: tokens}
+		default:
+			continue
+		}
+
+		if !rt.TryClaim(pos) {
+			if rt.IsDone() {
+				err = rt.GetError()
+				stopped = true
+				cancel()
+			} else if pcancel, ok := reading[r.partition]; ok {
+				// The partition was split off into a residual.
+				pcancel()
+			}
+			continue
+		}
+		if r.mutation != nil {
+			emit(beam.EventTime(r.mutation.CommitTimestamp.Time().UnixMilli()), *r.mutation)
+		}
+		if r.close != nil {
+			readPartitions()
+		}
+		if wm, ok := rt.GetRestriction().(changeStreamRestriction).watermark(); ok {
+			we.advance(bigtable.Timestamp(wm).Time())
+		}
+	}
+
+	if err != nil {
+		return sdf.StopProcessing(), err
+	}
+	if stopped || rt.IsDone() {
+		return sdf.StopProcessing(), nil
+	}
+	return sdf.ResumeProcessingIn(0), nil
+}
+
+// requestParams returns the routing parameters of requests to the table.
+func (fn *changeStreamFn) requestParams() string {
+	params := url.Values{"table_name": {fn.tableName()}}
+	if fn.AppProfile != "" {
+		params.Set("app_profile_id", fn.AppProfile)
+	}
+	return params.Encode()
+}
+
+// tableName returns the fully qualified name of the table.
+func (fn *changeStreamFn) tableName() string {
+	return fmt.Sprintf("projects/%s/instances/%s/tables/%s", fn.Project, fn.InstanceID, fn.TableName)
+}
+
+// initialPartitions lists the partitions to start reading the change stream
+// of the table from.
+func (fn *changeStreamFn) initialPartitions(ctx context.Context, startTime int64) ([]streamPartition, error) {
+	stream, err := fn.client.GenerateInitialChangeStreamPartitions(ctx, &btpb.GenerateInitialChangeStreamPartitionsRequest{
+		TableName:    fn.tableName(),
+		AppProfileId: fn.AppProfile,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list change stream partitions: %v", err)
+	}
+
+	var partitions []streamPartition
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return partitions, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not list change stream partitions: %v", err)
+		}
+		partitions = append(partitions, streamPartition{
+			Range:     newKeyRange(resp.GetPartition().GetRowRange()),
+			Watermark: startTime,
+		})
+	}
+}
+
+// readPartition reads the partition of the change stream until the stream
+// is closed or the context is done, and sends the records read to the
+// channel. A partition without continuation tokens is read from its
+// watermark. The last record sent is marked as done.
+func (fn *changeStreamFn) readPartition(ctx context.Context, p streamPartition, records chan<- streamRecord) {
+	record := streamRecord{partition: p.Range}
+	defer func() {
+		record.done = true
+		records <- record
+	}()
+
+	req := &btpb.ReadChangeStreamRequest{
+		TableName:         fn.tableName(),
+		AppProfileId:      fn.AppProfile,
+		Partition:         &btpb.StreamPartition{RowRange: p.Range.rowRangeProto()},
+		HeartbeatDuration: durationpb.New(fn.HeartbeatDuration),
+	}
+	if len(p.Tokens) > 0 {
+		tokens := make([]*btpb.StreamContinuationToken, len(p.Tokens))
+		for i, t := range p.Tokens {
+			tokens[i] = &btpb.StreamContinuationToken{
+				Partition: &btpb.StreamPartition{RowRange: t.Range.rowRangeProto()},
+				Token:     t.Token,
+			}
+		}
+		req.StartFrom = &btpb.ReadChangeStreamRequest_ContinuationTokens{
+			ContinuationTokens: &btpb.StreamContinuationTokens{Tokens: tokens},
+		}
+	} else {
+		req.StartFrom = &btpb.ReadChangeStreamRequest_StartTime{
+			StartTime: timestamppb.New(time.UnixMicro(p.Watermark)),
+		}
+	}
+	if fn.EndTime != 0 {
+		req.EndTime = timestamppb.New(time.UnixMicro(fn.EndTime))
+	}
+
+	stream, err := fn.client.ReadChangeStream(ctx, req)
+	if err != nil {
+		if ctx.Err() == nil {
+			record.err = fmt.Errorf("could not read change stream: %v", err)
+		}
+		return
+	}
+
+	var mutation *ChangeStreamMutation
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				record.err = fmt.Errorf("could not read change stream: %v", err)
+			}
+			return
+		}
+
+		switch r := resp.GetStreamRecord().(type) {
+		case *btpb.ReadChangeStreamResponse_DataChange_:
+			if mutation == nil {
+				mutation = newChangeStreamMutation(r.DataChange)
+			} else if r.DataChange.GetType() != btpb.ReadChangeStreamResponse_DataChange_CONTINUATION {
+				record.err = fmt.Errorf("change to row %q is incomplete", mutation.RowKey)
+				return
+			}
+			if err := mutation.addChunks(r.DataChange.GetChunks()); err != nil {
+				record.err = err
+				return
+			}
+			if !r.DataChange.GetDone() {
+				continue
+			}
+			mutation.Token = r.DataChange.GetToken()
+			mutation.EstimatedLowWatermark = timestamp(r.DataChange.GetEstimatedLowWatermark())
+			records <- streamRecord{partition: p.Range, mutation: mutation}
+			mutation = nil
+		case *btpb.ReadChangeStreamResponse_Heartbeat_:
+			records <- streamRecord{partition: p.Range, heartbeat: r.Heartbeat}
+		case *btpb.ReadChangeStreamResponse_CloseStream_:
+			record.close = r.CloseStream
+			return
+		}
+	}
+}
+
+// partitionIndex returns the index of the partition with the range, or -1
+// if there is none.
+func partitionIndex(partitions []streamPartition, r keyRange) int {
+	for i, p := range partitions {
+		if p.Range == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// continuations returns the partitions that a closed partition continues in
+// and the continuation tokens to resume them from.
+func continuations(cs *btpb.ReadChangeStreamResponse_CloseStream) ([]keyRange, []streamToken, error) {
+	if code := codes.Code(cs.GetStatus().GetCode()); code != codes.OK && code != codes.OutOfRange {
+		return nil, nil, fmt.Errorf("%v: %v", code, cs.GetStatus().GetMessage())
+	}
+	if len(cs.GetNewPartitions()) != len(cs.GetContinuationTokens()) {
+		return nil, nil, fmt.Errorf("got %d new partitions for %d continuation tokens", len(cs.GetNewPartitions()), len(cs.GetContinuationTokens()))
+	}
+
+	partitions := make([]keyRange, len(cs.GetNewPartitions()))
+	tokens := make([]streamToken, len(cs.GetContinuationTokens()))
+	for i, np := range cs.GetNewPartitions() {
+		partitions[i] = newKeyRange(np.GetRowRange())
+		tokens[i] = newStreamToken(cs.GetContinuationTokens()[i])
+	}
+	return partitions, tokens, nil
+}
+
+// closePartition removes the closed partition at index i and adds the new
+// partitions it continues in, which are either split from the partition or
+// merged with other partitions. A merged partition is pending until the
+// continuation tokens of all partitions it is merged from are received.
+func (r *changeStreamRestriction) closePartition(i int, partitions []keyRange, tokens []streamToken) {
+	closed := r.Partitions[i]
+	r.Partitions = append(r.Partitions[:i:i], r.Partitions[i+1:]...)
+
+	for j, rng := range partitions {
+		k := partitionIndex(r.Pending, rng)
+		if k < 0 {
+			r.Pending = append(r.Pending, streamPartition{Range: rng, Watermark: closed.Watermark})
+			k = len(r.Pending) - 1
+		}
+		pending := &r.Pending[k]
+		pending.Tokens = append(pending.Tokens, tokens[j])
+		pending.Watermark = min(pending.Watermark, closed.Watermark)
+	}
+	r.startPending()
+}
+
+// startPending moves the pending partitions whose continuation tokens have
+// all been received to the partitions being read. The partitions that a
+// pending partition is merged from may have been split off into other
+// restrictions though, whose tokens are never received. Such a partition is
+// read from its watermark without tokens by the restriction holding the token
+// at the start of its range, and dropped by the others, so that it is read
+// once.
+func (r *changeStreamRestriction) startPending() {
+	var pending []streamPartition
+	for _, p := range r.Pending {
+		switch {
+		case p.isCovered():
+			r.Partitions = append(r.Partitions, p)
+		case r.canCover(p):
+			pending = append(pending, p)
+		case slices.ContainsFunc(p.Tokens, func(t streamToken) bool { return t.Range.Start == p.Range.Start }):
+			r.Partitions = append(r.Partitions, streamPartition{Range: p.Range, Watermark: p.Watermark})
+		}
+	}
+	r.Pending = pending
+}
+
+// canCover reports whether the ranges of the tokens of the pending partition
+// and of the partitions being read cover its range, so that the tokens of all
+// partitions it is merged from can still be received.
+func (r *changeStreamRestriction) canCover(p streamPartition) bool {
+	var ranges []keyRange
+	for _, t := range p.Tokens {
+		ranges = append(ranges, t.Range)
+	}
+	for _, rp := range r.Partitions {
+		if rng := p.Range.intersect(rp.Range); !rng.isEmpty() {
+			ranges = append(ranges, rng)
+		}
+	}
+	return covers(p.Range, ranges)
+}
+
+// newKeyRange converts a row range of a change stream partition to a
+// keyRange.
+func newKeyRange(r *btpb.RowRange) keyRange {
+	var ret keyRange
+	switch k := r.GetStartKey().(type) {
+	case *btpb.RowRange_StartKeyClosed:
+		ret.Start = string(k.StartKeyClosed)
+	case *btpb.RowRange_StartKeyOpen:
+		ret.Start = string(k.StartKeyOpen) + "\x00"
+	}
+	switch k := r.GetEndKey().(type) {
+	case *btpb.RowRange_EndKeyOpen:
+		ret.End = string(k.EndKeyOpen)
+	case *btpb.RowRange_EndKeyClosed:
+		ret.End = string(k.EndKeyClosed) + "\x00"
+	}
+	return ret
+}
+
+// rowRangeProto returns the range as the row range of a change stream
+// partition.
+func (r keyRange) rowRangeProto() *btpb.RowRange {
+	ret := &btpb.RowRange{}
+	if r.Start != "" {
+		ret.StartKey = &btpb.RowRange_StartKeyClosed{StartKeyClosed: []byte(r.Start)}
+	}
+	if r.End != "" {
+		ret.EndKey = &btpb.RowRange_EndKeyOpen{EndKeyOpen: []byte(r.End)}
+	}
+	return ret
+}
+
+func newStreamToken(t *btpb.StreamContinuationToken) streamToken {
+	return streamToken{
+		Range: newKeyRange(t.GetPartition().GetRowRange()),
+		Token: t.GetToken(),
+	}
+}
+
+// newChangeStreamMutation returns a ChangeStreamMutation without entries for
+// the first message of a change.
+func newChangeStreamMutation(dc *btpb.ReadChangeStreamResponse_DataChange) *ChangeStreamMutation {
+	return &ChangeStreamMutation{
+		RowKey:          string(dc.GetRowKey()),
+		Type:            dc.GetType().String(),
+		SourceClusterID: dc.GetSourceClusterId(),
+		CommitTimestamp: timestamp(dc.GetCommitTimestamp()),
+		TieBreaker:      dc.GetTiebreaker(),
+	}
+}
+
+// addChunks adds the mutations of the chunks to the entries. Chunks holding
+// the continuation of a SetCell value are appended to the value of the last
+// entry.
+func (m *ChangeStreamMutation) addChunks(chunks []*btpb.ReadChangeStreamResponse_MutationChunk) error {
+	for _, c := range chunks {
+		if c.GetChunkInfo().GetChunkedValueOffset() > 0 {
+			if len(m.Entries) == 0 {
+				return fmt.Errorf("change to row %q continues a missing value", m.RowKey)
+			}
+			last := &m.Entries[len(m.Entries)-1]
+			last.Value = append(last.Value, c.GetMutation().GetSetCell().GetValue()...)
+			continue
+		}
+
+		entry, err := newChangeStreamEntry(c.GetMutation())
+		if err != nil {
+			return fmt.Errorf("change to row %q: %v", m.RowKey, err)
+		}
+		m.Entries = append(m.Entries, entry)
+	}
+	return nil
+}
+
+func newChangeStreamEntry(mut *btpb.Mutation) (ChangeStreamEntry, error) {
+	switch m := mut.GetMutation().(type) {
+	case *btpb.Mutation_SetCell_:
+		return ChangeStreamEntry{
+			Type:      "SetCell",
+			Family:    m.SetCell.GetFamilyName(),
+			Column:    string(m.SetCell.GetColumnQualifier()),
+			Timestamp: bigtable.Timestamp(m.SetCell.GetTimestampMicros()),
+			Value:     append([]byte(nil), m.SetCell.GetValue()...),
+		}, nil
+	case *btpb.Mutation_DeleteFromColumn_:
+		return ChangeStreamEntry{
+			Type:           "DeleteFromColumn",
+			Family:         m.DeleteFromColumn.GetFamilyName(),
+			Column:         string(m.DeleteFromColumn.GetColumnQualifier()),
+			StartTimestamp: bigtable.Timestamp(m.DeleteFromColumn.GetTimeRange().GetStartTimestampMicros()),
+			EndTimestamp:   bigtable.Timestamp(m.DeleteFromColumn.GetTimeRange().GetEndTimestampMicros()),
+		}, nil
+	case *btpb.Mutation_DeleteFromFamily_:
+		return ChangeStreamEntry{
+			Type:   "DeleteFromFamily",
+			Family: m.DeleteFromFamily.GetFamilyName(),
+		}, nil
+	case *btpb.Mutation_DeleteFromRow_:
+		return ChangeStreamEntry{Type: "DeleteFromRow"}, nil
+	case *btpb.Mutation_AddToCell_:
+		return ChangeStreamEntry{
+			Type:      "AddToCell",
+			Family:    m.AddToCell.GetFamilyName(),
+			Column:    string(m.AddToCell.GetColumnQualifier().GetRawValue()),
+			Timestamp: bigtable.Timestamp(m.AddToCell.GetTimestamp().GetRawTimestampMicros()),
+			Value:     valueBytes(m.AddToCell.GetInput()),
+		}, nil
+	case *btpb.Mutation_MergeToCell_:
+		return ChangeStreamEntry{
+			Type:      "MergeToCell",
+			Family:    m.MergeToCell.GetFamilyName(),
+			Column:    string(m.MergeToCell.GetColumnQualifier().GetRawValue()),
+			Timestamp: bigtable.Timestamp(m.MergeToCell.GetTimestamp().GetRawTimestampMicros()),
+			Value:     valueBytes(m.MergeToCell.GetInput()),
+		}, nil
+	}
+	return ChangeStreamEntry{}, fmt.Errorf("unsupported mutation type %T", mut.GetMutation())
+}
+
+// valueBytes returns the raw bytes of the value, with integers encoded as
+// 8-byte big-endian.
+func valueBytes(v *btpb.Value) []byte {
+	switch k := v.GetKind().(type) {
+	case *btpb.Value_RawValue:
+		return k.RawValue
+	case *btpb.Value_BytesValue:
+		return k.BytesValue
+	case *btpb.Value_StringValue:
+		return []byte(k.StringValue)
+	case *btpb.Value_IntValue:
+		return binary.BigEndian.AppendUint64(nil, uint64(k.IntValue))
+	}
+	return nil
+}
+
+// timestamp converts the timestamp to a bigtable.Timestamp.
+func timestamp(ts *timestamppb.Timestamp) bigtable.Timestamp {
+	if ts == nil {
+		return 0
+	}
+	return bigtable.Time(ts.AsTime())
+}
+
+// watermarkEstimator is a manual watermark estimator, which is advanced to
+// the low watermark of the change stream. The watermark never moves
+// backwards.
+type watermarkEstimator struct {
+	state int64
+}
+
+// CurrentWatermark returns the current watermark of the estimator.
+func (e *watermarkEstimator) CurrentWatermark() time.Time {
+	return time.UnixMilli(e.state)
+}
+
+func (e *watermarkEstimator) advance(t time.Time) {
+	if ms := t.UnixMilli(); ms > e.state {
+		e.state = ms
+	}
+}
@@ -0,0 +1,608 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtableio
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	btpb "cloud.google.com/go/bigtable/apiv2/bigtablepb"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/google/go-cmp/cmp"
+	statuspb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fakeChangeStreamServer stands in for the change stream of a table. The
+// records of each read are looked up by the partition read and the
+// continuation tokens it is resumed from.
+type fakeChangeStreamServer struct {
+	btpb.UnimplementedBigtableServer
+
+	partitions []keyRange
+	records    map[string][]*btpb.ReadChangeStreamResponse
+
+	mu    sync.Mutex
+	reads []*btpb.ReadChangeStreamRequest
+}
+
+// readKey returns the key of the records of a read of the partition resumed
+// from the tokens.
+func readKey(partition keyRange, tokens ...string) string {
+	sort.Strings(tokens)
+	return fmt.Sprintf("%q-%q:%s", partition.Start, partition.End, strings.Join(tokens, ","))
+}
+
+func (s *fakeChangeStreamServer) GenerateInitialChangeStreamPartitions(
+	_ *btpb.GenerateInitialChangeStreamPartitionsRequest,
+	stream btpb.Bigtable_GenerateInitialChangeStreamPartitionsServer,
+) error {
+	for _, p := range s.partitions {
+		resp := &btpb.GenerateInitialChangeStreamPartitionsResponse{
+			Partition: &btpb.StreamPartition{RowRange: p.rowRangeProto()},
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fakeChangeStreamServer) ReadChangeStream(
+	req *btpb.ReadChangeStreamRequest,
+	stream btpb.Bigtable_ReadChangeStreamServer,
+) error {
+	s.mu.Lock()
+	s.reads = append(s.reads, req)
+	s.mu.Unlock()
+
+	var tokens []string
+	for _, t := range req.GetContinuationTokens().GetTokens() {
+		tokens = append(tokens, t.GetToken())
+	}
+	for _, resp := range s.records[readKey(newKeyRange(req.GetPartition().GetRowRange()), tokens...)] {
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newTestChangeStreamFn(t *testing.T, srv *fakeChangeStreamServer) *changeStreamFn {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	gs := grpc.NewServer()
+	btpb.RegisterBigtableServer(gs, srv)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+	t.Setenv("BIGTABLE_EMULATOR_HOST", lis.Addr().String())
+
+	fn := &changeStreamFn{
+		Project:           "project",
+		InstanceID:        "instance",
+		TableName:         "table",
+		StartTime:         1000,
+		HeartbeatDuration: defaultHeartbeatDuration,
+	}
+	if err := fn.Setup(context.Background()); err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	t.Cleanup(func() {
+		if err := fn.Teardown(); err != nil {
+			t.Errorf("Teardown() error = %v", err)
+		}
+	})
+	return fn
+}
+
+func processChangeStream(
+	t *testing.T,
+	fn *changeStreamFn,
+	rest changeStreamRestriction,
+	we *watermarkEstimator,
+) (changeStreamRestriction, bool, []ChangeStreamMutation) {
+	t.Helper()
+
+	rt := fn.CreateTracker(rest)
+
+	var mutations []ChangeStreamMutation
+	pc, err := fn.ProcessElement(context.Background(), we, rt, nil, func(_ beam.EventTime, m ChangeStreamMutation) {
+		mutations = append(mutations, m)
+	})
+	if err != nil {
+		t.Fatalf("ProcessElement() error = %v", err)
+	}
+	sort.Slice(mutations, func(i, j int) bool {
+		return mutations[i].RowKey < mutations[j].RowKey
+	})
+
+	return rt.GetRestriction().(changeStreamRestriction), pc.ShouldResume(), mutations
+}
+
+func dataChange(rowKey string, commitMicros int64, token string, done bool, chunks ...*btpb.ReadChangeStreamResponse_MutationChunk) *btpb.ReadChangeStreamResponse {
+	return &btpb.ReadChangeStreamResponse{
+		StreamRecord: &btpb.ReadChangeStreamResponse_DataChange_{
+			DataChange: &btpb.ReadChangeStreamResponse_DataChange{
+				Type:                  btpb.ReadChangeStreamResponse_DataChange_USER,
+				SourceClusterId:       "cluster",
+				RowKey:                []byte(rowKey),
+				CommitTimestamp:       timestamppb.New(time.UnixMicro(commitMicros)),
+				Chunks:                chunks,
+				Done:                  done,
+				Token:                 token,
+				EstimatedLowWatermark: timestamppb.New(time.UnixMicro(commitMicros)),
+			},
+		},
+	}
+}
+
+func setCell(family, column string, value string) *btpb.ReadChangeStreamResponse_MutationChunk {
+	return &btpb.ReadChangeStreamResponse_MutationChunk{
+		Mutation: &btpb.Mutation{Mutation: &btpb.Mutation_SetCell_{SetCell: &btpb.Mutation_SetCell{
+			FamilyName:      family,
+			ColumnQualifier: []byte(column),
+			TimestampMicros: 2000,
+			Value:           []byte(value),
+		}}},
+	}
+}
+
+func heartbeat(partition keyRange, token string, watermarkMicros int64) *btpb.ReadChangeStreamResponse {
+	return &btpb.ReadChangeStreamResponse{
+		StreamRecord: &btpb.ReadChangeStreamResponse_Heartbeat_{
+			Heartbeat: &btpb.ReadChangeStreamResponse_Heartbeat{
+				ContinuationToken: &btpb.StreamContinuationToken{
+					Partition: &btpb.StreamPartition{RowRange: partition.rowRangeProto()},
+					Token:     token,
+				},
+				EstimatedLowWatermark: timestamppb.New(time.UnixMicro(watermarkMicros)),
+			},
+		},
+	}
+}
+
+// continuation is a continuation token of a closed partition and the new
+// partition it continues in.
+type continuation struct {
+	tokenRange keyRange
+	token      string
+	partition  keyRange
+}
+
+func closeStream(code codes.Code, continuations ...continuation) *btpb.ReadChangeStreamResponse {
+	cs := &btpb.ReadChangeStreamResponse_CloseStream{Status: &statuspb.Status{Code: int32(code)}}
+	for _, c := range continuations {
+		cs.ContinuationTokens = append(cs.ContinuationTokens, &btpb.StreamContinuationToken{
+			Partition: &btpb.StreamPartition{RowRange: c.tokenRange.rowRangeProto()},
+			Token:     c.token,
+		})
+		cs.NewPartitions = append(cs.NewPartitions, &btpb.StreamPartition{RowRange: c.partition.rowRangeProto()})
+	}
+	return &btpb.ReadChangeStreamResponse{
+		StreamRecord: &btpb.ReadChangeStreamResponse_CloseStream_{CloseStream: cs},
+	}
+}
+
+func Test_changeStreamFn_ProcessElement(t *testing.T) {
+	left, right := keyRange{End: "m"}, keyRange{Start: "m"}
+	srv := &fakeChangeStreamServer{
+		partitions: []keyRange{left, right},
+		records: map[string][]*btpb.ReadChangeStreamResponse{
+			readKey(left): {
+				dataChange("a", 3000, "left-1", false, setCell("cf", "col", "val")),
+				{
+					StreamRecord: &btpb.ReadChangeStreamResponse_DataChange_{
+						DataChange: &btpb.ReadChangeStreamResponse_DataChange{
+							Type: btpb.ReadChangeStreamResponse_DataChange_CONTINUATION,
+							Chunks: []*btpb.ReadChangeStreamResponse_MutationChunk{{
+								ChunkInfo: &btpb.ReadChangeStreamResponse_MutationChunk_ChunkInfo{
+									ChunkedValueSize:   6,
+									ChunkedValueOffset: 3,
+									LastChunk:          true,
+								},
+								Mutation: &btpb.Mutation{Mutation: &btpb.Mutation_SetCell_{SetCell: &btpb.Mutation_SetCell{
+									Value: []byte("ue2"),
+								}}},
+							}},
+							Done:                  true,
+							Token:                 "left-1",
+							EstimatedLowWatermark: timestamppb.New(time.UnixMicro(3000)),
+						},
+					},
+				},
+			},
+			readKey(right): {
+				dataChange("n", 4000, "right-1", true, &btpb.ReadChangeStreamResponse_MutationChunk{
+					Mutation: &btpb.Mutation{Mutation: &btpb.Mutation_DeleteFromRow_{
+						DeleteFromRow: &btpb.Mutation_DeleteFromRow{},
+					}},
+				}),
+				heartbeat(right, "right-2", 5000),
+			},
+		},
+	}
+	fn := newTestChangeStreamFn(t, srv)
+
+	rest := fn.CreateInitialRestriction(nil)
+	we := fn.CreateWatermarkEstimator(fn.InitialWatermarkEstimatorState(0, rest, nil))
+
+	got, resume, mutations := processChangeStream(t, fn, rest, we)
+
+	want := []ChangeStreamMutation{
+		{
+			RowKey:          "a",
+			Type:            "USER",
+			SourceClusterID: "cluster",
+			CommitTimestamp: 3000,
+			Entries: []ChangeStreamEntry{
+				{Type: "SetCell", Family: "cf", Column: "col", Timestamp: 2000, Value: []byte("value2")},
+			},
+			Token:                 "left-1",
+			EstimatedLowWatermark: 3000,
+		},
+		{
+			RowKey:                "n",
+			Type:                  "USER",
+			SourceClusterID:       "cluster",
+			CommitTimestamp:       4000,
+			Entries:               []ChangeStreamEntry{{Type: "DeleteFromRow"}},
+			Token:                 "right-1",
+			EstimatedLowWatermark: 4000,
+		},
+	}
+	if diff := cmp.Diff(want, mutations); diff != "" {
+		t.Errorf("ProcessElement() emitted mutations mismatch (-want +got):\n%s", diff)
+	}
+
+	if !resume {
+		t.Error("ProcessElement() stopped processing, want resumption")
+	}
+
+	wantRest := changeStreamRestriction{
+		StartTime:   1000,
+		Initialized: true,
+		Partitions: []streamPartition{
+			{Range: left, Tokens: []streamToken{{Range: left, Token: "left-1"}}, Watermark: 3000},
+			{Range: right, Tokens: []streamToken{{Range: right, Token: "right-2"}}, Watermark: 5000},
+		},
+	}
+	if diff := cmp.Diff(wantRest, got, cmp.AllowUnexported(changeStreamRestriction{}, streamPartition{}, streamToken{}, keyRange{})); diff != "" {
+		t.Errorf("restriction mismatch (-want +got):\n%s", diff)
+	}
+
+	if got, want := we.CurrentWatermark(), time.UnixMilli(3); !got.Equal(want) {
+		t.Errorf("watermark = %v, want %v", got, want)
+	}
+
+	for _, req := range srv.reads {
+		if got, want := req.GetStartTime().AsTime(), time.UnixMicro(1000); !got.Equal(want) {
+			t.Errorf("read StartTime = %v, want %v", got, want)
+		}
+	}
+}
+
+func Test_changeStreamFn_ProcessElement_splitAndMerge(t *testing.T) {
+	all, left, right := keyRange{}, keyRange{End: "m"}, keyRange{Start: "m"}
+	srv := &fakeChangeStreamServer{
+		records: map[string][]*btpb.ReadChangeStreamResponse{
+			readKey(all, "all-1"): {
+				closeStream(codes.OutOfRange, continuation{left, "left-1", left}, continuation{right, "right-1", right}),
+			},
+			readKey(left, "left-1"): {
+				dataChange("a", 3000, "left-2", true, setCell("cf", "col", "a")),
+				closeStream(codes.OutOfRange, continuation{left, "left-3", all}),
+			},
+			readKey(right, "right-1"): {
+				dataChange("n", 4000, "right-2", true, setCell("cf", "col", "n")),
+				closeStream(codes.OutOfRange, continuation{right, "right-3", all}),
+			},
+			readKey(all, "left-3", "right-3"): {
+				dataChange("b", 5000, "all-2", true, setCell("cf", "col", "b")),
+				closeStream(codes.OK),
+			},
+		},
+	}
+	fn := newTestChangeStreamFn(t, srv)
+
+	rest := changeStreamRestriction{
+		StartTime:   1000,
+		Initialized: true,
+		Partitions:  []streamPartition{{Range: all, Tokens: []streamToken{{Range: all, Token: "all-1"}}, Watermark: 2000}},
+	}
+	we := fn.CreateWatermarkEstimator(fn.InitialWatermarkEstimatorState(0, rest, nil))
+
+	got, resume, mutations := processChangeStream(t, fn, rest, we)
+
+	var keys []string
+	for _, m := range mutations {
+		keys = append(keys, m.RowKey)
+	}
+	if diff := cmp.Diff([]string{"a", "b", "n"}, keys); diff != "" {
+		t.Errorf("ProcessElement() emitted row keys mismatch (-want +got):\n%s", diff)
+	}
+
+	if resume {
+		t.Error("ProcessElement() resumed processing, want it stopped at the end of the change stream")
+	}
+	if !got.isDone() {
+		t.Errorf("restriction = %+v, want it done", got)
+	}
+	if got, want := len(srv.reads), 4; got != want {
+		t.Errorf("got %d reads, want %d", got, want)
+	}
+}
+
+func Test_changeStreamFn_ProcessElement_error(t *testing.T) {
+	all := keyRange{}
+	srv := &fakeChangeStreamServer{
+		partitions: []keyRange{all},
+		records: map[string][]*btpb.ReadChangeStreamResponse{
+			readKey(all): {closeStream(codes.NotFound)},
+		},
+	}
+	fn := newTestChangeStreamFn(t, srv)
+
+	rest := fn.CreateInitialRestriction(nil)
+	we := fn.CreateWatermarkEstimator(fn.InitialWatermarkEstimatorState(0, rest, nil))
+
+	_, err := fn.ProcessElement(context.Background(), we, fn.CreateTracker(rest), nil, func(beam.EventTime, ChangeStreamMutation) {})
+	if err == nil {
+		t.Error("ProcessElement() succeeded, want error for a partition closed with NotFound")
+	}
+}
+
+func Test_streamPartition_isCovered(t *testing.T) {
+	tests := []struct {
+		name      string
+		partition streamPartition
+		want      bool
+	}{
+		{
+			name: "no tokens",
+			partition: streamPartition{
+				Range: keyRange{Start: "a", End: "c"},
+			},
+			want: false,
+		},
+		{
+			name: "covering tokens",
+			partition: streamPartition{
+				Range: keyRange{Start: "a", End: "c"},
+				Tokens: []streamToken{
+					{Range: keyRange{Start: "b", End: "c"}},
+					{Range: keyRange{Start: "a", End: "b"}},
+				},
+			},
+			want: true,
+		},
+		{
+			name: "gap between tokens",
+			partition: streamPartition{
+				Range: keyRange{Start: "a"},
+				Tokens: []streamToken{
+					{Range: keyRange{Start: "a", End: "b"}},
+					{Range: keyRange{Start: "c"}},
+				},
+			},
+			want: false,
+		},
+		{
+			name: "unbounded token",
+			partition: streamPartition{
+				Range: keyRange{},
+				Tokens: []streamToken{
+					{Range: keyRange{End: "b"}},
+					{Range: keyRange{Start: "b"}},
+				},
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.partition.isCovered(); got != tt.want {
+				t.Errorf("isCovered() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_changeStreamFn_ProcessElement_mergeSplitOff(t *testing.T) {
+	all, left := keyRange{}, keyRange{End: "m"}
+	srv := &fakeChangeStreamServer{
+		records: map[string][]*btpb.ReadChangeStreamResponse{
+			readKey(left, "left-1"): {
+				closeStream(codes.OutOfRange, continuation{left, "left-2", all}),
+			},
+			readKey(all): {
+				dataChange("b", 5000, "all-1", true, setCell("cf", "col", "b")),
+			},
+		},
+	}
+	fn := newTestChangeStreamFn(t, srv)
+
+	// The right partition that merges into all was split off, so all is read
+	// from the watermark of left.
+	rest := changeStreamRestriction{
+		StartTime:   1000,
+		Initialized: true,
+		Partitions:  []streamPartition{{Range: left, Tokens: []streamToken{{Range: left, Token: "left-1"}}, Watermark: 2000}},
+	}
+	we := fn.CreateWatermarkEstimator(fn.InitialWatermarkEstimatorState(0, rest, nil))
+
+	got, _, mutations := processChangeStream(t, fn, rest, we)
+
+	if len(mutations) != 1 || mutations[0].RowKey != "b" {
+		t.Errorf("ProcessElement() emitted %+v, want the change to b", mutations)
+	}
+	wantRest := changeStreamRestriction{
+		StartTime:   1000,
+		Initialized: true,
+		Partitions:  []streamPartition{{Range: all, Tokens: []streamToken{{Range: all, Token: "all-1"}}, Watermark: 5000}},
+	}
+	if diff := cmp.Diff(wantRest, got, cmp.AllowUnexported(changeStreamRestriction{}, streamPartition{}, streamToken{}, keyRange{})); diff != "" {
+		t.Errorf("restriction mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := len(srv.reads), 2; got != want {
+		t.Fatalf("got %d reads, want %d", got, want)
+	}
+	if got, want := srv.reads[1].GetStartTime().AsTime(), time.UnixMicro(2000); !got.Equal(want) {
+		t.Errorf("merged partition read StartTime = %v, want %v", got, want)
+	}
+}
+
+func Test_changeStreamTracker_TrySplit(t *testing.T) {
+	rest := changeStreamRestriction{
+		StartTime:   1000,
+		Initialized: true,
+		Partitions:  []streamPartition{{Range: keyRange{}, Watermark: 1000}},
+	}
+	rt := newChangeStreamTracker(rest)
+
+	if !rt.TryClaim(partitionProgress{Range: keyRange{}, Token: streamToken{Token: "token"}, Watermark: 2000}) {
+		t.Fatal("TryClaim() = false, want true")
+	}
+	next := rest.clone()
+	next.Partitions[0].Tokens = []streamToken{{Token: "token"}}
+	next.Partitions[0].Watermark = 2000
+
+	primary, residual, err := rt.TrySplit(0)
+	if err != nil {
+		t.Fatalf("TrySplit() error = %v", err)
+	}
+	opts := cmp.AllowUnexported(changeStreamRestriction{}, streamPartition{}, streamToken{}, keyRange{})
+	if diff := cmp.Diff(changeStreamRestriction{StartTime: 1000, Initialized: true}, primary, opts); diff != "" {
+		t.Errorf("TrySplit() primary mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(next, residual, opts); diff != "" {
+		t.Errorf("TrySplit() residual mismatch (-want +got):\n%s", diff)
+	}
+	if !rt.IsDone() {
+		t.Error("IsDone() = false after checkpointing, want true")
+	}
+	if rt.TryClaim(partitionProgress{Range: keyRange{}, Token: streamToken{Token: "token2"}}) {
+		t.Error("TryClaim() = true after checkpointing, want false")
+	}
+}
+
+func Test_changeStreamTracker_TrySplit_fraction(t *testing.T) {
+	a, b, c := keyRange{End: "h"}, keyRange{Start: "h", End: "p"}, keyRange{Start: "p"}
+	rest := changeStreamRestriction{
+		StartTime:   1000,
+		Initialized: true,
+		Partitions:  []streamPartition{{Range: a}, {Range: b}, {Range: c}},
+	}
+	rt := newChangeStreamTracker(rest)
+
+	primary, residual, err := rt.TrySplit(0.5)
+	if err != nil {
+		t.Fatalf("TrySplit() error = %v", err)
+	}
+	opts := cmp.AllowUnexported(changeStreamRestriction{}, streamPartition{}, streamToken{}, keyRange{})
+	wantPrimary := changeStreamRestriction{StartTime: 1000, Initialized: true, Partitions: []streamPartition{{Range: a}}}
+	if diff := cmp.Diff(wantPrimary, primary, opts); diff != "" {
+		t.Errorf("TrySplit() primary mismatch (-want +got):\n%s", diff)
+	}
+	wantResidual := changeStreamRestriction{StartTime: 1000, Initialized: true, Partitions: []streamPartition{{Range: b}, {Range: c}}}
+	if diff := cmp.Diff(wantResidual, residual, opts); diff != "" {
+		t.Errorf("TrySplit() residual mismatch (-want +got):\n%s", diff)
+	}
+	if rt.TryClaim(partitionProgress{Range: b, Token: streamToken{Range: b, Token: "b-1"}}) {
+		t.Error("TryClaim() of a split off partition = true, want false")
+	}
+	if rt.IsDone() {
+		t.Error("IsDone() = true after a split off partition, want false")
+	}
+
+	// A single partition can't be split.
+	if _, residual, _ := rt.TrySplit(0.5); residual != nil {
+		t.Errorf("TrySplit() of a single partition residual = %+v, want nil", residual)
+	}
+}
+
+func Test_changeStreamTracker_mergeSplitOff(t *testing.T) {
+	all, left, right := keyRange{}, keyRange{End: "m"}, keyRange{Start: "m"}
+	rest := changeStreamRestriction{
+		StartTime:   1000,
+		Initialized: true,
+		Partitions:  []streamPartition{{Range: left, Watermark: 3000}, {Range: right, Watermark: 2000}},
+	}
+	opts := cmp.AllowUnexported(changeStreamRestriction{}, streamPartition{}, streamToken{}, keyRange{})
+
+	// The tokens of both partitions are received by the same restriction.
+	rt := newChangeStreamTracker(rest.clone())
+	if !rt.TryClaim(partitionClosed{Range: left, Partitions: []keyRange{all}, Tokens: []streamToken{{Range: left, Token: "left"}}}) {
+		t.Fatal("TryClaim() = false, want true")
+	}
+	if got := rt.GetRestriction().(changeStreamRestriction); len(got.Pending) != 1 {
+		t.Errorf("restriction = %+v, want the merged partition pending", got)
+	}
+	if !rt.TryClaim(partitionClosed{Range: right, Partitions: []keyRange{all}, Tokens: []streamToken{{Range: right, Token: "right"}}}) {
+		t.Fatal("TryClaim() = false, want true")
+	}
+	want := changeStreamRestriction{
+		StartTime:   1000,
+		Initialized: true,
+		Partitions: []streamPartition{{
+			Range:     all,
+			Tokens:    []streamToken{{Range: left, Token: "left"}, {Range: right, Token: "right"}},
+			Watermark: 2000,
+		}},
+	}
+	if diff := cmp.Diff(want, rt.GetRestriction(), opts); diff != "" {
+		t.Errorf("restriction mismatch (-want +got):\n%s", diff)
+	}
+
+	// The partitions are split into different restrictions. The restriction
+	// of the left partition reads the merged partition from its watermark, and
+	// the other one drops it.
+	rt = newChangeStreamTracker(rest.clone())
+	primary, residual, err := rt.TrySplit(0.5)
+	if err != nil || residual == nil {
+		t.Fatalf("TrySplit() = (%+v, %+v, %v), want a split", primary, residual, err)
+	}
+	if !rt.TryClaim(partitionClosed{Range: left, Partitions: []keyRange{all}, Tokens: []streamToken{{Range: left, Token: "left"}}}) {
+		t.Fatal("TryClaim() = false, want true")
+	}
+	want = changeStreamRestriction{
+		StartTime:   1000,
+		Initialized: true,
+		Partitions:  []streamPartition{{Range: all, Watermark: 3000}},
+	}
+	if diff := cmp.Diff(want, rt.GetRestriction(), opts); diff != "" {
+		t.Errorf("primary restriction mismatch (-want +got):\n%s", diff)
+	}
+
+	rt = newChangeStreamTracker(residual.(changeStreamRestriction))
+	if rt.TryClaim(partitionClosed{Range: right, Partitions: []keyRange{all}, Tokens: []streamToken{{Range: right, Token: "right"}}}) {
+		t.Error("TryClaim() = true, want false for the last partition")
+	}
+	if !rt.IsDone() {
+		t.Errorf("residual restriction = %+v, want it done", rt.GetRestriction())
+	}
+}
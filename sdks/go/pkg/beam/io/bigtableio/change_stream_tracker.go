@@ -0,0 +1,285 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtableio
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*changeStreamTracker)(nil)))
+}
+
+// changeStreamRestriction represents the position in the change stream of a
+// table to read from. It holds partitions of the change stream, each of which
+// is resumed from its continuation tokens if set, or started at its watermark
+// otherwise.
+type changeStreamRestriction struct {
+	// StartTime is the time in microseconds to start reading the initial
+	// partitions from.
+	StartTime int64
+	// Initialized is whether the initial partitions have been listed.
+	Initialized bool
+	// Partitions are the partitions being read.
+	Partitions []streamPartition
+	// Pending are the partitions resulting from a merge, which are read once
+	// the continuation tokens of all merged partitions have been received.
+	Pending []streamPartition
+}
+
+// streamPartition is a partition of a change stream.
+type streamPartition struct {
+	Range keyRange
+	// Tokens are the continuation tokens to resume reading the partition
+	// from, whose ranges cover the range of the partition.
+	Tokens []streamToken
+	// Watermark is the low watermark of the partition in microseconds.
+	Watermark int64
+}
+
+// streamToken is a continuation token of a change stream partition.
+type streamToken struct {
+	Range keyRange
+	Token string
+}
+
+// isCovered reports whether the ranges of the tokens of the partition cover
+// its range.
+func (p streamPartition) isCovered() bool {
+	ranges := make([]keyRange, len(p.Tokens))
+	for i, t := range p.Tokens {
+		ranges[i] = t.Range
+	}
+	return covers(p.Range, ranges)
+}
+
+// covers reports whether the ranges cover the range r.
+func covers(r keyRange, ranges []keyRange) bool {
+	ranges = append([]keyRange(nil), ranges...)
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Start < ranges[j].Start
+	})
+
+	next := r.Start
+	for _, rng := range ranges {
+		if rng.Start > next {
+			return false
+		}
+		if rng.End == "" {
+			return true
+		}
+		if rng.End > next {
+			next = rng.End
+		}
+	}
+	return r.End != "" && next >= r.End
+}
+
+// isDone reports whether all partitions of the change stream have been read
+// to the end.
+func (r changeStreamRestriction) isDone() bool {
+	return r.Initialized && len(r.Partitions) == 0 && len(r.Pending) == 0
+}
+
+// watermark returns the low watermark of the change stream in microseconds,
+// which is the minimum of the watermarks of its partitions.
+func (r changeStreamRestriction) watermark() (int64, bool) {
+	var ret int64
+	ok := false
+	for _, partitions := range [][]streamPartition{r.Partitions, r.Pending} {
+		for _, p := range partitions {
+			if !ok || p.Watermark < ret {
+				ret = p.Watermark
+				ok = true
+			}
+		}
+	}
+	return ret, ok
+}
+
+// clone returns a deep copy of the restriction.
+func (r changeStreamRestriction) clone() changeStreamRestriction {
+	clonePartitions := func(partitions []streamPartition) []streamPartition {
+		if partitions == nil {
+			return nil
+		}
+		ret := make([]streamPartition, len(partitions))
+		for i, p := range partitions {
+			ret[i] = p
+			ret[i].Tokens = append([]streamToken(nil), p.Tokens...)
+		}
+		return ret
+	}
+
+	r.Partitions = clonePartitions(r.Partitions)
+	r.Pending = clonePartitions(r.Pending)
+	return r
+}
+
+// listedPartitions is a position that initializes a restriction with the
+// initial partitions of the change stream.
+type listedPartitions []streamPartition
+
+// partitionProgress is a position that advances a partition to the
+// continuation token and low watermark of a record read from it.
+type partitionProgress struct {
+	Range     keyRange
+	Token     streamToken
+	Watermark int64
+}
+
+// partitionClosed is a position that removes a closed partition and adds the
+// partitions it continues in, which are resumed from the continuation tokens.
+type partitionClosed struct {
+	Range      keyRange
+	Partitions []keyRange
+	Tokens     []streamToken
+}
+
+// changeStreamTracker is a tracker of a changeStreamRestriction. The
+// restriction is unbounded, and can be split between its partitions or
+// checkpointed.
+type changeStreamTracker struct {
+	rest    changeStreamRestriction
+	stopped bool
+	err     error
+}
+
+// newChangeStreamTracker creates a new changeStreamTracker tracking the
+// provided changeStreamRestriction.
+func newChangeStreamTracker(rest changeStreamRestriction) *changeStreamTracker {
+	return &changeStreamTracker{rest: rest}
+}
+
+// TryClaim accepts a listedPartitions, partitionProgress or partitionClosed
+// position. The position is claimed if the tracker has not been stopped and
+// its partition is still part of the restriction, which is not the case after
+// the partition has been split off into a residual. A restriction without
+// partitions left to read stops the tracker.
+func (rt *changeStreamTracker) TryClaim(pos any) bool {
+	if rt.IsDone() {
+		return false
+	}
+
+	switch p := pos.(type) {
+	case listedPartitions:
+		if rt.rest.Initialized {
+			rt.err = errors.New("cannot list the partitions of an initialized restriction")
+			return false
+		}
+		rt.rest.Initialized = true
+		rt.rest.Partitions = p
+	case partitionProgress:
+		i := partitionIndex(rt.rest.Partitions, p.Range)
+		if i < 0 {
+			return false
+		}
+		partition := &rt.rest.Partitions[i]
+		partition.Tokens = []streamToken{p.Token}
+		partition.Watermark = max(partition.Watermark, p.Watermark)
+	case partitionClosed:
+		i := partitionIndex(rt.rest.Partitions, p.Range)
+		if i < 0 {
+			return false
+		}
+		rt.rest.closePartition(i, p.Partitions, p.Tokens)
+	default:
+		rt.err = fmt.Errorf("invalid pos type: %T", pos)
+		return false
+	}
+
+	if rt.rest.isDone() {
+		rt.stopped = true
+		return false
+	}
+	return true
+}
+
+// GetError returns the error associated with the tracker, if any.
+func (rt *changeStreamTracker) GetError() error {
+	return rt.err
+}
+
+// TrySplit checkpoints the tracker if the fraction is 0, by stopping it and
+// returning a residual that resumes the partitions at their last claimed
+// positions. Otherwise, it splits the partitions between the primary and the
+// residual by the fraction, keeping at least one partition in each. The
+// pending partitions stay in the primary.
+func (rt *changeStreamTracker) TrySplit(fraction float64) (primary, residual any, err error) {
+	if fraction < 0 || fraction > 1 {
+		return nil, nil, errors.New("fraction must be between 0 and 1")
+	}
+	if rt.IsDone() {
+		return rt.rest, nil, nil
+	}
+
+	if fraction == 0 {
+		residual = rt.rest
+		rt.rest = changeStreamRestriction{StartTime: rt.rest.StartTime, Initialized: true}
+		rt.stopped = true
+		return rt.rest, residual, nil
+	}
+
+	n := len(rt.rest.Partitions)
+	split := int(float64(n) * fraction)
+	if split < 1 {
+		split = 1
+	}
+	if !rt.rest.Initialized || split >= n {
+		return rt.rest, nil, nil
+	}
+
+	res := changeStreamRestriction{
+		StartTime:   rt.rest.StartTime,
+		Initialized: true,
+		Partitions:  append([]streamPartition(nil), rt.rest.Partitions[split:]...),
+	}
+	rt.rest.Partitions = rt.rest.Partitions[:split:split]
+	// Partitions merging into the pending partitions may have been split off.
+	rt.rest.startPending()
+	return rt.rest.clone(), res, nil
+}
+
+// GetProgress reports each partition as a unit of remaining work, as the
+// amount of changes is unknown.
+func (rt *changeStreamTracker) GetProgress() (done float64, remaining float64) {
+	if rt.IsDone() {
+		return 1, 0
+	}
+	return 0, float64(max(len(rt.rest.Partitions)+len(rt.rest.Pending), 1))
+}
+
+// IsDone returns true if the tracker has been stopped or all partitions of
+// the change stream have been read to the end.
+func (rt *changeStreamTracker) IsDone() bool {
+	return rt.stopped || rt.rest.isDone()
+}
+
+// GetRestriction returns a copy of the restriction the tracker is tracking.
+func (rt *changeStreamTracker) GetRestriction() any {
+	return rt.rest.clone()
+}
+
+// IsBounded returns whether the tracker is tracking a restriction with a
+// finite amount of work.
+func (*changeStreamTracker) IsBounded() bool {
+	return false
+}
@@ -0,0 +1,256 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtableio
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"cloud.google.com/go/bigtable"
+)
+
+// Filter represents a necessary serializable wrapper analogue to
+// bigtable.Filter, which can't be serialized as part of a pipeline. Filters
+// are created with the functions named after their bigtable counterparts,
+// e.g. ChainFilters(FamilyFilter("stats"), LatestNFilter(1)). The zero Filter
+// applies no filtering.
+type Filter struct {
+	spec *filterSpec
+}
+
+// filterSpec is the serialized form of a Filter.
+type filterSpec struct {
+	Kind        string             `json:"kind"`
+	Pattern     string             `json:"pattern,omitempty"`
+	N           int                `json:"n,omitempty"`
+	Family      string             `json:"family,omitempty"`
+	Start       []byte             `json:"start,omitempty"`
+	End         []byte             `json:"end,omitempty"`
+	StartTime   bigtable.Timestamp `json:"startTime,omitempty"`
+	EndTime     bigtable.Timestamp `json:"endTime,omitempty"`
+	Probability float64            `json:"probability,omitempty"`
+	Sub         []*filterSpec      `json:"sub,omitempty"`
+}
+
+func newFilter(spec filterSpec, sub ...Filter) Filter {
+	for _, f := range sub {
+		spec.Sub = append(spec.Sub, f.spec)
+	}
+	return Filter{spec: &spec}
+}
+
+// ChainFilters returns a filter that applies a sequence of filters,
+// analogue to bigtable.ChainFilters.
+func ChainFilters(sub ...Filter) Filter {
+	return newFilter(filterSpec{Kind: "chain"}, sub...)
+}
+
+// InterleaveFilters returns a filter that applies a set of filters in
+// parallel and interleaves the results, analogue to
+// bigtable.InterleaveFilters.
+func InterleaveFilters(sub ...Filter) Filter {
+	return newFilter(filterSpec{Kind: "interleave"}, sub...)
+}
+
+// ConditionFilter returns a filter that applies trueFilter if
+// predicateFilter matches any cell of a row and falseFilter otherwise,
+// analogue to bigtable.ConditionFilter. A zero trueFilter or falseFilter
+// matches no cells.
+func ConditionFilter(predicateFilter, trueFilter, falseFilter Filter) Filter {
+	return newFilter(filterSpec{Kind: "condition"}, predicateFilter, trueFilter, falseFilter)
+}
+
+// RowKeyFilter returns a filter that matches cells from rows whose key
+// matches the provided RE2 pattern, analogue to bigtable.RowKeyFilter.
+func RowKeyFilter(pattern string) Filter {
+	return newFilter(filterSpec{Kind: "rowKey", Pattern: pattern})
+}
+
+// FamilyFilter returns a filter that matches cells whose family name matches
+// the provided RE2 pattern, analogue to bigtable.FamilyFilter.
+func FamilyFilter(pattern string) Filter {
+	return newFilter(filterSpec{Kind: "family", Pattern: pattern})
+}
+
+// ColumnFilter returns a filter that matches cells whose column name matches
+// the provided RE2 pattern, analogue to bigtable.ColumnFilter.
+func ColumnFilter(pattern string) Filter {
+	return newFilter(filterSpec{Kind: "column", Pattern: pattern})
+}
+
+// ValueFilter returns a filter that matches cells whose value matches the
+// provided RE2 pattern, analogue to bigtable.ValueFilter.
+func ValueFilter(pattern string) Filter {
+	return newFilter(filterSpec{Kind: "value", Pattern: pattern})
+}
+
+// LatestNFilter returns a filter that matches the most recent n cells in each
+// column, analogue to bigtable.LatestNFilter.
+func LatestNFilter(n int) Filter {
+	return newFilter(filterSpec{Kind: "latestN", N: n})
+}
+
+// StripValueFilter returns a filter that replaces each value with the empty
+// string, analogue to bigtable.StripValueFilter.
+func StripValueFilter() Filter {
+	return newFilter(filterSpec{Kind: "stripValue"})
+}
+
+// TimestampRangeFilterMicros returns a filter that matches cells whose
+// timestamp is within the half-open interval [start, end), analogue to
+// bigtable.TimestampRangeFilterMicros. An end of 0 means no upper bound.
+func TimestampRangeFilterMicros(start, end bigtable.Timestamp) Filter {
+	return newFilter(filterSpec{Kind: "timestampRange", StartTime: start, EndTime: end})
+}
+
+// ColumnRangeFilter returns a filter that matches cells of the given family
+// whose column is within the half-open interval [start, end), analogue to
+// bigtable.ColumnRangeFilter. Empty bounds are unbounded.
+func ColumnRangeFilter(family, start, end string) Filter {
+	return newFilter(filterSpec{Kind: "columnRange", Family: family, Start: []byte(start), End: []byte(end)})
+}
+
+// ValueRangeFilter returns a filter that matches cells whose value is within
+// the half-open interval [start, end), analogue to bigtable.ValueRangeFilter.
+// Empty bounds are unbounded.
+func ValueRangeFilter(start, end []byte) Filter {
+	return newFilter(filterSpec{Kind: "valueRange", Start: start, End: end})
+}
+
+// CellsPerRowOffsetFilter returns a filter that skips the first n cells of
+// each row, analogue to bigtable.CellsPerRowOffsetFilter.
+func CellsPerRowOffsetFilter(n int) Filter {
+	return newFilter(filterSpec{Kind: "cellsPerRowOffset", N: n})
+}
+
+// CellsPerRowLimitFilter returns a filter that matches only the first n cells
+// of each row, analogue to bigtable.CellsPerRowLimitFilter.
+func CellsPerRowLimitFilter(n int) Filter {
+	return newFilter(filterSpec{Kind: "cellsPerRowLimit", N: n})
+}
+
+// RowSampleFilter returns a filter that matches a row with a probability of
+// p, analogue to bigtable.RowSampleFilter.
+func RowSampleFilter(p float64) Filter {
+	return newFilter(filterSpec{Kind: "rowSample", Probability: p})
+}
+
+// PassAllFilter returns a filter that matches everything, analogue to
+// bigtable.PassAllFilter.
+func PassAllFilter() Filter {
+	return newFilter(filterSpec{Kind: "passAll"})
+}
+
+// BlockAllFilter returns a filter that matches nothing, analogue to
+// bigtable.BlockAllFilter.
+func BlockAllFilter() Filter {
+	return newFilter(filterSpec{Kind: "blockAll"})
+}
+
+// IsZero reports whether f is the zero Filter, which applies no filtering.
+func (f Filter) IsZero() bool {
+	return f.spec == nil
+}
+
+// MarshalJSON encodes the filter.
+func (f Filter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.spec)
+}
+
+// UnmarshalJSON decodes a filter encoded by MarshalJSON.
+func (f *Filter) UnmarshalJSON(b []byte) error {
+	var spec *filterSpec
+	if err := json.Unmarshal(b, &spec); err != nil {
+		return err
+	}
+	f.spec = spec
+	return nil
+}
+
+// bigtableFilter converts the filter to a bigtable.Filter. It returns nil for
+// the zero Filter.
+func (f Filter) bigtableFilter() (bigtable.Filter, error) {
+	if f.spec == nil {
+		return nil, nil
+	}
+	return f.spec.bigtableFilter()
+}
+
+func (s *filterSpec) bigtableFilter() (bigtable.Filter, error) {
+	if s == nil {
+		return nil, nil
+	}
+	sub := make([]bigtable.Filter, len(s.Sub))
+	for i, spec := range s.Sub {
+		f, err := spec.bigtableFilter()
+		if err != nil {
+			return nil, err
+		}
+		sub[i] = f
+	}
+
+	switch s.Kind {
+	case "chain":
+		return bigtable.ChainFilters(nonNil(sub)...), nil
+	case "interleave":
+		return bigtable.InterleaveFilters(nonNil(sub)...), nil
+	case "condition":
+		if len(sub) != 3 || sub[0] == nil {
+			return nil, fmt.Errorf("condition filter requires a predicate filter")
+		}
+		return bigtable.ConditionFilter(sub[0], sub[1], sub[2]), nil
+	case "rowKey":
+		return bigtable.RowKeyFilter(s.Pattern), nil
+	case "family":
+		return bigtable.FamilyFilter(s.Pattern), nil
+	case "column":
+		return bigtable.ColumnFilter(s.Pattern), nil
+	case "value":
+		return bigtable.ValueFilter(s.Pattern), nil
+	case "latestN":
+		return bigtable.LatestNFilter(s.N), nil
+	case "stripValue":
+		return bigtable.StripValueFilter(), nil
+	case "timestampRange":
+		return bigtable.TimestampRangeFilterMicros(s.StartTime, s.EndTime), nil
+	case "columnRange":
+		return bigtable.ColumnRangeFilter(s.Family, string(s.Start), string(s.End)), nil
+	case "valueRange":
+		return bigtable.ValueRangeFilter(s.Start, s.End), nil
+	case "cellsPerRowOffset":
+		return bigtable.CellsPerRowOffsetFilter(s.N), nil
+	case "cellsPerRowLimit":
+		return bigtable.CellsPerRowLimitFilter(s.N), nil
+	case "rowSample":
+		return bigtable.RowSampleFilter(s.Probability), nil
+	case "passAll":
+		return bigtable.PassAllFilter(), nil
+	case "blockAll":
+		return bigtable.BlockAllFilter(), nil
+	}
+	return nil, fmt.Errorf("unknown filter kind %q", s.Kind)
+}
+
+// nonNil returns the filters that are not nil, i.e. not from zero Filters.
+func nonNil(filters []bigtable.Filter) []bigtable.Filter {
+	var ret []bigtable.Filter
+	for _, f := range filters {
+		if f != nil {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,101 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtableio
+
+import (
+	"encoding/json"
+	"testing"
+
+	"cloud.google.com/go/bigtable"
+)
+
+func TestFilter_JSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bigtable.Filter
+	}{
+		{
+			name: "zero filter",
+		},
+		{
+			name:   "chain",
+			filter: ChainFilters(FamilyFilter("stats"), LatestNFilter(1), Filter{}),
+			want:   bigtable.ChainFilters(bigtable.FamilyFilter("stats"), bigtable.LatestNFilter(1)),
+		},
+		{
+			name: "condition",
+			filter: ConditionFilter(
+				ColumnFilter("name"),
+				InterleaveFilters(StripValueFilter(), ValueRangeFilter([]byte("a"), []byte("b"))),
+				Filter{},
+			),
+			want: bigtable.ConditionFilter(
+				bigtable.ColumnFilter("name"),
+				bigtable.InterleaveFilters(bigtable.StripValueFilter(), bigtable.ValueRangeFilter([]byte("a"), []byte("b"))),
+				nil,
+			),
+		},
+		{
+			name:   "ranges",
+			filter: ChainFilters(TimestampRangeFilterMicros(1000, 2000), ColumnRangeFilter("info", "a", "")),
+			want:   bigtable.ChainFilters(bigtable.TimestampRangeFilterMicros(1000, 2000), bigtable.ColumnRangeFilter("info", "a", "")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(tt.filter)
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			var decoded Filter
+			if err := json.Unmarshal(b, &decoded); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if got, want := decoded.IsZero(), tt.filter.IsZero(); got != want {
+				t.Errorf("IsZero() = %v, want %v", got, want)
+			}
+
+			got, err := decoded.bigtableFilter()
+			if err != nil {
+				t.Fatalf("bigtableFilter() error = %v", err)
+			}
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("bigtableFilter() = %v, want nil", got)
+				}
+				return
+			}
+			if got == nil || got.String() != tt.want.String() {
+				t.Errorf("bigtableFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_invalid(t *testing.T) {
+	if _, err := ConditionFilter(Filter{}, PassAllFilter(), BlockAllFilter()).bigtableFilter(); err == nil {
+		t.Error("bigtableFilter() of a condition without a predicate succeeded, want error")
+	}
+
+	var f Filter
+	if err := json.Unmarshal([]byte(`{"kind":"unknown"}`), &f); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if _, err := f.bigtableFilter(); err == nil {
+		t.Error("bigtableFilter() of an unknown kind succeeded, want error")
+	}
+}
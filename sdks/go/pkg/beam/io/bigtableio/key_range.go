@@ -0,0 +1,272 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtableio
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"cloud.google.com/go/bigtable"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*keyRangeTracker)(nil)))
+}
+
+// keyRange is a range of row keys [Start, End). An empty End means the range
+// is unbounded.
+type keyRange struct {
+	Start string
+	End   string
+}
+
+// contains reports whether the key is in the range.
+func (r keyRange) contains(key string) bool {
+	return key >= r.Start && (r.End == "" || key < r.End)
+}
+
+// isEmpty reports whether the range contains no keys.
+func (r keyRange) isEmpty() bool {
+	return r.End != "" && r.Start >= r.End
+}
+
+// rowRange returns the range as a bigtable.RowRange.
+func (r keyRange) rowRange() bigtable.RowRange {
+	if r.End == "" {
+		return bigtable.InfiniteRange(r.Start)
+	}
+	return bigtable.NewRange(r.Start, r.End)
+}
+
+// intersect returns the intersection of the ranges.
+func (r keyRange) intersect(o keyRange) keyRange {
+	ret := r
+	if o.Start > ret.Start {
+		ret.Start = o.Start
+	}
+	if o.End != "" && (ret.End == "" || o.End < ret.End) {
+		ret.End = o.End
+	}
+	return ret
+}
+
+// splitAt splits the range at the given sorted keys, ignoring keys outside of
+// the range.
+func (r keyRange) splitAt(keys []string) []keyRange {
+	var ranges []keyRange
+	start := r.Start
+	for _, k := range keys {
+		if k <= start || (r.End != "" && k >= r.End) {
+			continue
+		}
+		ranges = append(ranges, keyRange{Start: start, End: k})
+		start = k
+	}
+	return append(ranges, keyRange{Start: start, End: r.End})
+}
+
+// endOfRange is claimed by a keyRangeTracker once all keys of the range have
+// been read.
+type endOfRange struct{}
+
+// keyRangeTracker is a tracker of a keyRange, claiming row keys in increasing
+// order. It supports dynamic splitting at keys interpolated between the last
+// claimed key and the end of the range.
+type keyRangeTracker struct {
+	rest       keyRange
+	claimed    string
+	hasClaimed bool
+	stopped    bool
+	err        error
+}
+
+// newKeyRangeTracker creates a new keyRangeTracker tracking the provided
+// keyRange.
+func newKeyRangeTracker(rest keyRange) *keyRangeTracker {
+	return &keyRangeTracker{rest: rest}
+}
+
+// TryClaim accepts a row key, which must be greater than any previously
+// claimed key, or endOfRange once the range has been read. The key is claimed
+// if it is within the range. Otherwise, or on endOfRange, the tracker is
+// stopped.
+func (rt *keyRangeTracker) TryClaim(pos any) bool {
+	if rt.stopped || rt.err != nil {
+		return false
+	}
+
+	switch key := pos.(type) {
+	case endOfRange:
+		rt.stopped = true
+		return false
+	case string:
+		if rt.hasClaimed && key <= rt.claimed {
+			rt.err = fmt.Errorf("cannot claim key %q after key %q", key, rt.claimed)
+			return false
+		}
+		if key < rt.rest.Start {
+			rt.err = fmt.Errorf("cannot claim key %q before the start of the range %q", key, rt.rest.Start)
+			return false
+		}
+		if !rt.rest.contains(key) {
+			rt.stopped = true
+			return false
+		}
+		rt.claimed = key
+		rt.hasClaimed = true
+		return true
+	default:
+		rt.err = fmt.Errorf("invalid pos type: %T", pos)
+		return false
+	}
+}
+
+// GetError returns the error associated with the tracker, if any.
+func (rt *keyRangeTracker) GetError() error {
+	return rt.err
+}
+
+// next returns the first key that has not been claimed.
+func (rt *keyRangeTracker) next() string {
+	if !rt.hasClaimed {
+		return rt.rest.Start
+	}
+	return rt.claimed + "\x00"
+}
+
+// TrySplit splits the unclaimed part of the range at the given fraction. A
+// fraction of 0 checkpoints the tracker at the first unclaimed key. Returns a
+// nil residual if the range cannot be split.
+func (rt *keyRangeTracker) TrySplit(fraction float64) (primary, residual any, err error) {
+	if fraction < 0 || fraction > 1 {
+		return nil, nil, errors.New("fraction must be between 0 and 1")
+	}
+	if rt.IsDone() {
+		return rt.rest, nil, nil
+	}
+
+	from := rt.next()
+	split := from
+	if fraction > 0 {
+		var ok bool
+		if split, ok = interpolateKey(from, rt.rest.End, fraction); !ok {
+			return rt.rest, nil, nil
+		}
+	}
+
+	if split == "" {
+		// An empty end key means unbounded, so an empty primary at the
+		// start of the key space can't be represented.
+		return rt.rest, nil, nil
+	}
+
+	res := keyRange{Start: split, End: rt.rest.End}
+	rt.rest.End = split
+	return rt.rest, res, nil
+}
+
+// GetProgress returns the fractions of the range before and after the first
+// unclaimed key.
+func (rt *keyRangeTracker) GetProgress() (done float64, remaining float64) {
+	if rt.IsDone() {
+		return 1, 0
+	}
+	done = keyFraction(rt.rest.Start, rt.rest.End, rt.next())
+	return done, 1 - done
+}
+
+// IsDone returns true if the tracker has been stopped or all keys of the
+// range have been claimed.
+func (rt *keyRangeTracker) IsDone() bool {
+	return rt.err == nil && (rt.stopped || keyRange{Start: rt.next(), End: rt.rest.End}.isEmpty())
+}
+
+// GetRestriction returns a copy of the restriction the tracker is tracking.
+func (rt *keyRangeTracker) GetRestriction() any {
+	return rt.rest
+}
+
+// IsBounded returns whether the tracker is tracking a restriction with a
+// finite amount of work.
+func (*keyRangeTracker) IsBounded() bool {
+	return true
+}
+
+// keyInts returns the start and end keys as integers of equal byte length,
+// such that keys compare in the same order as the integers. An empty end is
+// the integer following the largest key of that length.
+func keyInts(start, end string) (s, e *big.Int, n int) {
+	n = max(len(start), len(end)) + 1
+	s = keyInt(start, n)
+	if end == "" {
+		e = new(big.Int).Lsh(big.NewInt(1), uint(8*n))
+	} else {
+		e = keyInt(end, n)
+	}
+	return s, e, n
+}
+
+// keyInt returns the key, padded with zero bytes to n bytes, as a big-endian
+// integer.
+func keyInt(key string, n int) *big.Int {
+	b := make([]byte, n)
+	copy(b, key)
+	return new(big.Int).SetBytes(b)
+}
+
+// interpolateKey returns a key approximately the given fraction of the way
+// from start to end. It returns false if there is no key strictly between
+// start and end at that fraction.
+func interpolateKey(start, end string, fraction float64) (string, bool) {
+	s, e, n := keyInts(start, end)
+	diff := new(big.Int).Sub(e, s)
+	if diff.Sign() <= 0 {
+		return "", false
+	}
+
+	off, _ := new(big.Float).Mul(new(big.Float).SetInt(diff), big.NewFloat(fraction)).Int(nil)
+	k := new(big.Int).Add(s, off)
+	b := k.FillBytes(make([]byte, n))
+	for len(b) > 0 && b[len(b)-1] == 0 {
+		b = b[:len(b)-1]
+	}
+
+	key := string(b)
+	if key <= start || (end != "" && key >= end) {
+		return "", false
+	}
+	return key, true
+}
+
+// keyFraction returns the approximate fraction of the way from start to end
+// of the key.
+func keyFraction(start, end, key string) float64 {
+	s, e, n := keyInts(start, end)
+	if len(key) >= n {
+		key = key[:n]
+	}
+	k := keyInt(key, n)
+
+	diff := new(big.Int).Sub(e, s)
+	if diff.Sign() <= 0 {
+		return 1
+	}
+	f, _ := new(big.Rat).SetFrac(new(big.Int).Sub(k, s), diff).Float64()
+	return min(max(f, 0), 1)
+}
@@ -0,0 +1,201 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtableio
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_keyRange_splitAt(t *testing.T) {
+	tests := []struct {
+		name string
+		rng  keyRange
+		keys []string
+		want []keyRange
+	}{
+		{
+			name: "no keys",
+			rng:  keyRange{Start: "a", End: "z"},
+			want: []keyRange{{Start: "a", End: "z"}},
+		},
+		{
+			name: "keys within the range",
+			rng:  keyRange{Start: "b", End: "y"},
+			keys: []string{"", "a", "b", "c", "x", "y", "z"},
+			want: []keyRange{{Start: "b", End: "c"}, {Start: "c", End: "x"}, {Start: "x", End: "y"}},
+		},
+		{
+			name: "unbounded range",
+			rng:  keyRange{},
+			keys: []string{"c", "x", ""},
+			want: []keyRange{{End: "c"}, {Start: "c", End: "x"}, {Start: "x"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, tt.rng.splitAt(tt.keys), cmp.AllowUnexported(keyRange{})); diff != "" {
+				t.Errorf("splitAt() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_keyRangeTracker_TryClaim(t *testing.T) {
+	rt := newKeyRangeTracker(keyRange{Start: "b", End: "d"})
+
+	if !rt.TryClaim("b") {
+		t.Fatal("TryClaim(b) = false, want true")
+	}
+	if !rt.TryClaim("c") {
+		t.Fatal("TryClaim(c) = false, want true")
+	}
+	if rt.TryClaim("d") {
+		t.Fatal("TryClaim(d) = true past the end of the range, want false")
+	}
+	if !rt.IsDone() {
+		t.Error("IsDone() = false, want true")
+	}
+	if err := rt.GetError(); err != nil {
+		t.Errorf("GetError() = %v, want nil", err)
+	}
+}
+
+func Test_keyRangeTracker_TryClaim_error(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []any
+	}{
+		{name: "key before the start", keys: []any{"a"}},
+		{name: "decreasing keys", keys: []any{"c", "b"}},
+		{name: "invalid type", keys: []any{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := newKeyRangeTracker(keyRange{Start: "b", End: "d"})
+			for _, k := range tt.keys {
+				rt.TryClaim(k)
+			}
+			if rt.GetError() == nil {
+				t.Error("GetError() = nil, want error")
+			}
+		})
+	}
+}
+
+func Test_keyRangeTracker_endOfRange(t *testing.T) {
+	rt := newKeyRangeTracker(keyRange{Start: "b"})
+	rt.TryClaim("c")
+	if rt.IsDone() {
+		t.Fatal("IsDone() = true before claiming the end of an unbounded range, want false")
+	}
+	if rt.TryClaim(endOfRange{}) {
+		t.Error("TryClaim(endOfRange) = true, want false")
+	}
+	if !rt.IsDone() {
+		t.Error("IsDone() = false after claiming the end of the range, want true")
+	}
+}
+
+func Test_keyRangeTracker_TrySplit(t *testing.T) {
+	tests := []struct {
+		name         string
+		rng          keyRange
+		claimed      []string
+		fraction     float64
+		wantPrimary  keyRange
+		wantResidual any
+	}{
+		{
+			name:         "checkpoint",
+			rng:          keyRange{Start: "a", End: "z"},
+			claimed:      []string{"b"},
+			fraction:     0,
+			wantPrimary:  keyRange{Start: "a", End: "b\x00"},
+			wantResidual: keyRange{Start: "b\x00", End: "z"},
+		},
+		{
+			name:         "checkpoint before claiming",
+			rng:          keyRange{Start: "a", End: "z"},
+			fraction:     0,
+			wantPrimary:  keyRange{Start: "a", End: "a"},
+			wantResidual: keyRange{Start: "a", End: "z"},
+		},
+		{
+			name:         "checkpoint before claiming the start of the key space",
+			rng:          keyRange{},
+			fraction:     0,
+			wantPrimary:  keyRange{},
+			wantResidual: nil,
+		},
+		{
+			name:         "split half",
+			rng:          keyRange{Start: "a", End: "c"},
+			fraction:     0.5,
+			wantPrimary:  keyRange{Start: "a", End: "b"},
+			wantResidual: keyRange{Start: "b", End: "c"},
+		},
+		{
+			name:         "split unbounded range",
+			rng:          keyRange{Start: "\x80"},
+			fraction:     0.5,
+			wantPrimary:  keyRange{Start: "\x80", End: "\xc0"},
+			wantResidual: keyRange{Start: "\xc0"},
+		},
+		{
+			name:         "no key between",
+			rng:          keyRange{Start: "a", End: "a\x00"},
+			fraction:     0.5,
+			wantPrimary:  keyRange{Start: "a", End: "a\x00"},
+			wantResidual: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := newKeyRangeTracker(tt.rng)
+			for _, k := range tt.claimed {
+				if !rt.TryClaim(k) {
+					t.Fatalf("TryClaim(%q) = false, want true", k)
+				}
+			}
+
+			primary, residual, err := rt.TrySplit(tt.fraction)
+			if err != nil {
+				t.Fatalf("TrySplit() error = %v", err)
+			}
+
+			opts := cmp.AllowUnexported(keyRange{})
+			if diff := cmp.Diff(tt.wantPrimary, primary, opts); diff != "" {
+				t.Errorf("TrySplit() primary mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantResidual, residual, opts); diff != "" {
+				t.Errorf("TrySplit() residual mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_keyRangeTracker_GetProgress(t *testing.T) {
+	rt := newKeyRangeTracker(keyRange{Start: "a", End: "c"})
+	rt.TryClaim("b")
+
+	done, remaining := rt.GetProgress()
+	if math.Abs(done-0.5) > 0.01 || math.Abs(remaining-0.5) > 0.01 {
+		t.Errorf("GetProgress() = (%v, %v), want about (0.5, 0.5)", done, remaining)
+	}
+}
@@ -0,0 +1,498 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtableio
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/bigtable"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+)
+
+func init() {
+	register.DoFn3x1[context.Context, []byte, func(keyRange), error](&sampleRowKeysFn{})
+	register.Emitter1[keyRange]()
+	register.DoFn4x1[context.Context, *sdf.LockRTracker, keyRange, func(beam.X), error](&readFn{})
+	register.Emitter1[beam.X]()
+	beam.RegisterType(reflect.TypeOf((*keyRange)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*Row)(nil)).Elem())
+}
+
+// keyTag is the struct tag value of the field holding the row key.
+const keyTag = "__key__"
+
+// Row is a generic representation of a bigtable row, which is emitted by Read
+// when reading into the Row type.
+type Row struct {
+	Key string
+	// Families holds the column families of the row, sorted by name.
+	Families []Family
+}
+
+// Family holds the cells of a column family of a Row.
+type Family struct {
+	Name string
+	// Cells holds the cells of the family, sorted by column and in
+	// descending order of timestamp within each column.
+	Cells []Cell
+}
+
+// Cell is a cell of a Row.
+type Cell struct {
+	// Column is the column qualifier, without the family name.
+	Column    string
+	Timestamp bigtable.Timestamp
+	Value     []byte
+}
+
+// RowRange is a range of row keys [Start, End) to read. An empty End means
+// the range is unbounded.
+type RowRange struct {
+	Start string
+	End   string
+}
+
+// ReadOption represents options for reading from bigtable.
+type ReadOption struct {
+	RowRanges []RowRange
+	Filter    Filter
+}
+
+// ReadOptionFn is a function that configures a ReadOption.
+type ReadOptionFn func(option *ReadOption) error
+
+// WithRowRange configures the ReadOption to read the row keys in the range
+// [start, end). An empty end means the range is unbounded. The option can be
+// used multiple times to read several ranges.
+func WithRowRange(start, end string) ReadOptionFn {
+	return func(o *ReadOption) error {
+		if end != "" && start >= end {
+			return fmt.Errorf("start %q of row range must be before end %q", start, end)
+		}
+		o.RowRanges = append(o.RowRanges, RowRange{Start: start, End: end})
+		return nil
+	}
+}
+
+// WithPrefix configures the ReadOption to read the row keys starting with
+// the prefix, analogue to bigtable.PrefixRange. The option can be used
+// multiple times to read several prefixes.
+func WithPrefix(prefix string) ReadOptionFn {
+	return func(o *ReadOption) error {
+		o.RowRanges = append(o.RowRanges, RowRange{Start: prefix, End: prefixSuccessor(prefix)})
+		return nil
+	}
+}
+
+// WithFilter configures the ReadOption to apply the filter to the rows read.
+func WithFilter(filter Filter) ReadOptionFn {
+	return func(o *ReadOption) error {
+		if _, err := filter.bigtableFilter(); err != nil {
+			return err
+		}
+		o.Filter = filter
+		return nil
+	}
+}
+
+// Read reads the rows of a bigtable table into a PCollection of the given
+// type, which is either bigtableio.Row or a struct whose fields are tagged
+// with the columns to read:
+//
+//	type Entry struct {
+//		Key   string `bigtable:"__key__"`
+//		Name  string `bigtable:"info:name"`
+//		Count int64  `bigtable:"stats:count"`
+//	}
+//
+// The field tagged "__key__" receives the row key and must be a string or
+// []byte. Fields tagged "family:column" receive the value of the latest cell
+// of the column and must be one of string, []byte, int64 (decoded as an
+// 8-byte big-endian integer, as written by bigtable.ReadModifyWrite.Increment),
+// float64 (decoded from its 8-byte big-endian IEEE 754 representation) or bool
+// (decoded from a single byte). Untagged fields and columns without cells are
+// left at their zero value.
+//
+// The table is split into ranges at the row keys sampled by
+// bigtable.Table.SampleRowKeys, which are further split dynamically while
+// they are read. By default all rows of the table are read, which can be
+// limited with WithRowRange and WithPrefix, and filtered with WithFilter.
+func Read(s beam.Scope, project, instanceID, table string, t reflect.Type, opts ...ReadOptionFn) beam.PCollection {
+	s = s.Scope("bigtable.Read")
+
+	option := &ReadOption{}
+	for _, opt := range opts {
+		if err := opt(option); err != nil {
+			panic(fmt.Sprintf("bigtableio.Read: invalid option: %v", err))
+		}
+	}
+
+	if _, err := newRowDecoder(t); err != nil {
+		panic(fmt.Sprintf("bigtableio.Read: %v", err))
+	}
+
+	filter, err := json.Marshal(option.Filter)
+	if err != nil {
+		panic(fmt.Sprintf("bigtableio.Read: invalid filter: %v", err))
+	}
+
+	imp := beam.Impulse(s)
+	ranges := beam.ParDo(s, &sampleRowKeysFn{
+		Project:    project,
+		InstanceID: instanceID,
+		TableName:  table,
+		RowRanges:  mergeRowRanges(option.RowRanges),
+	}, imp)
+	ranges = beam.Reshuffle(s, ranges)
+
+	return beam.ParDo(s, &readFn{
+		Project:    project,
+		InstanceID: instanceID,
+		TableName:  table,
+		Filter:     string(filter),
+		Type:       beam.EncodedType{T: t},
+	}, ranges, beam.TypeDefinition{Var: beam.XType, T: t})
+}
+
+// prefixSuccessor returns the smallest key that is greater than all keys
+// starting with the prefix, or an empty key if there is none.
+func prefixSuccessor(prefix string) string {
+	n := len(prefix)
+	for n > 0 && prefix[n-1] == 0xff {
+		n--
+	}
+	if n == 0 {
+		return ""
+	}
+	return prefix[:n-1] + string([]byte{prefix[n-1] + 1})
+}
+
+// mergeRowRanges returns the row ranges sorted by start key, with overlapping
+// ranges merged so that no row is read twice. No row ranges means all rows.
+func mergeRowRanges(ranges []RowRange) []keyRange {
+	if len(ranges) == 0 {
+		return []keyRange{{}}
+	}
+
+	sorted := make([]keyRange, len(ranges))
+	for i, r := range ranges {
+		sorted[i] = keyRange(r)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+
+	merged := []keyRange{sorted[0]}
+	for _, r := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if last.End != "" && r.Start > last.End {
+			merged = append(merged, r)
+			continue
+		}
+		if last.End != "" && (r.End == "" || r.End > last.End) {
+			last.End = r.End
+		}
+	}
+	return merged
+}
+
+type sampleRowKeysFn struct {
+	// Project is the project
+	Project string `json:"project"`
+	// InstanceID is the bigtable instanceID
+	InstanceID string `json:"instanceId"`
+	// TableName is the qualified table identifier.
+	TableName string `json:"tableName"`
+	// RowRanges are the sorted, non-overlapping ranges to read.
+	RowRanges []keyRange `json:"rowRanges"`
+	// Client is the bigtable.Client
+	client *bigtable.Client `json:"-"`
+}
+
+func (f *sampleRowKeysFn) Setup(ctx context.Context) error {
+	var err error
+	f.client, err = bigtable.NewClient(ctx, f.Project, f.InstanceID)
+	if err != nil {
+		return fmt.Errorf("could not create data operations client: %v", err)
+	}
+	return nil
+}
+
+func (f *sampleRowKeysFn) Teardown() error {
+	if err := f.client.Close(); err != nil {
+		return fmt.Errorf("could not close data operations client: %v", err)
+	}
+	return nil
+}
+
+func (f *sampleRowKeysFn) ProcessElement(ctx context.Context, _ []byte, emit func(keyRange)) error {
+	keys, err := f.client.Open(f.TableName).SampleRowKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("could not sample row keys: %v", err)
+	}
+	sort.Strings(keys)
+
+	for _, r := range f.RowRanges {
+		for _, split := range r.splitAt(keys) {
+			emit(split)
+		}
+	}
+	return nil
+}
+
+type readFn struct {
+	// Project is the project
+	Project string `json:"project"`
+	// InstanceID is the bigtable instanceID
+	InstanceID string `json:"instanceId"`
+	// TableName is the qualified table identifier.
+	TableName string `json:"tableName"`
+	// Filter is the JSON encoded Filter applied to the rows.
+	Filter string `json:"filter"`
+	// Type is the encoded type of the emitted elements.
+	Type beam.EncodedType `json:"type"`
+	// Client is the bigtable.Client
+	client *bigtable.Client `json:"-"`
+	// Table is a bigtable.Table instance with an eventual open connection
+	table *bigtable.Table `json:"-"`
+	// opts holds the read options of the filter.
+	opts []bigtable.ReadOption `json:"-"`
+	// decoder decodes the rows into the emitted type.
+	decoder rowDecoder `json:"-"`
+}
+
+func (f *readFn) Setup(ctx context.Context) error {
+	var filter Filter
+	if err := json.Unmarshal([]byte(f.Filter), &filter); err != nil {
+		return fmt.Errorf("could not decode filter: %v", err)
+	}
+	bf, err := filter.bigtableFilter()
+	if err != nil {
+		return err
+	}
+	if bf != nil {
+		f.opts = []bigtable.ReadOption{bigtable.RowFilter(bf)}
+	}
+
+	if f.decoder, err = newRowDecoder(f.Type.T); err != nil {
+		return err
+	}
+
+	f.client, err = bigtable.NewClient(ctx, f.Project, f.InstanceID)
+	if err != nil {
+		return fmt.Errorf("could not create data operations client: %v", err)
+	}
+	f.table = f.client.Open(f.TableName)
+	return nil
+}
+
+func (f *readFn) Teardown() error {
+	if err := f.client.Close(); err != nil {
+		return fmt.Errorf("could not close data operations client: %v", err)
+	}
+	return nil
+}
+
+func (f *readFn) CreateInitialRestriction(rng keyRange) keyRange {
+	return rng
+}
+
+func (f *readFn) SplitRestriction(_ keyRange, rest keyRange) []keyRange {
+	return []keyRange{rest}
+}
+
+func (f *readFn) CreateTracker(rest keyRange) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(newKeyRangeTracker(rest))
+}
+
+func (f *readFn) RestrictionSize(_ keyRange, _ keyRange) float64 {
+	return 1
+}
+
+func (f *readFn) ProcessElement(ctx context.Context, rt *sdf.LockRTracker, _ keyRange, emit func(beam.X)) error {
+	rest := rt.GetRestriction().(keyRange)
+	if rest.isEmpty() {
+		rt.TryClaim(endOfRange{})
+		return nil
+	}
+
+	var decodeErr error
+	err := f.table.ReadRows(ctx, rest.rowRange(), func(row bigtable.Row) bool {
+		if !rt.TryClaim(row.Key()) {
+			return false
+		}
+		v, err := f.decoder(row)
+		if err != nil {
+			decodeErr = fmt.Errorf("could not decode row %q: %v", row.Key(), err)
+			return false
+		}
+		emit(v)
+		return true
+	}, f.opts...)
+	if err != nil {
+		return fmt.Errorf("could not read rows: %v", err)
+	}
+	if decodeErr != nil {
+		return decodeErr
+	}
+
+	rt.TryClaim(endOfRange{})
+	return nil
+}
+
+// rowDecoder decodes a bigtable.Row into the element type of a Read.
+type rowDecoder func(row bigtable.Row) (any, error)
+
+// newRowDecoder returns a rowDecoder for the type, which is either Row or a
+// struct with fields tagged as described in Read.
+func newRowDecoder(t reflect.Type) (rowDecoder, error) {
+	if t == reflect.TypeOf(Row{}) {
+		return func(row bigtable.Row) (any, error) {
+			return newRow(row), nil
+		}, nil
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("type must be bigtableio.Row or a struct but is: %v", t)
+	}
+
+	type column struct {
+		index int
+		name  string
+	}
+	var key []int
+	var columns []column
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("bigtable")
+		if !ok {
+			continue
+		}
+		if !field.IsExported() {
+			return nil, fmt.Errorf("field %v of type %v must be exported", field.Name, t)
+		}
+
+		if tag == keyTag {
+			if field.Type.Kind() != reflect.String && field.Type != reflect.TypeOf([]byte(nil)) {
+				return nil, fmt.Errorf("row key field %v of type %v must be a string or []byte", field.Name, t)
+			}
+			key = field.Index
+			continue
+		}
+
+		if family, _, ok := strings.Cut(tag, ":"); !ok || family == "" {
+			return nil, fmt.Errorf("tag %q of field %v of type %v must be of the form \"family:column\"", tag, field.Name, t)
+		}
+		if _, err := decodeValue(nil, field.Type); err != nil {
+			return nil, fmt.Errorf("field %v of type %v: %v", field.Name, t, err)
+		}
+		columns = append(columns, column{index: i, name: tag})
+	}
+
+	return func(row bigtable.Row) (any, error) {
+		v := reflect.New(t).Elem()
+		if key != nil {
+			setBytes(v.FieldByIndex(key), []byte(row.Key()))
+		}
+		for _, c := range columns {
+			family, _, _ := strings.Cut(c.name, ":")
+			for _, item := range row[family] {
+				if item.Column != c.name {
+					continue
+				}
+				field := v.Field(c.index)
+				value, err := decodeValue(item.Value, field.Type())
+				if err != nil {
+					return nil, fmt.Errorf("column %q: %v", c.name, err)
+				}
+				field.Set(value)
+				break
+			}
+		}
+		return v.Interface(), nil
+	}, nil
+}
+
+// decodeValue decodes a cell value into a value of the type. A nil value
+// only checks whether the type is supported and returns its zero value.
+func decodeValue(b []byte, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	switch {
+	case t.Kind() == reflect.String, t == reflect.TypeOf([]byte(nil)):
+		setBytes(v, b)
+	case t.Kind() == reflect.Int64:
+		if b != nil && len(b) != 8 {
+			return v, fmt.Errorf("int64 value must be 8 bytes but is %d bytes", len(b))
+		}
+		if b != nil {
+			v.SetInt(int64(binary.BigEndian.Uint64(b)))
+		}
+	case t.Kind() == reflect.Float64:
+		if b != nil && len(b) != 8 {
+			return v, fmt.Errorf("float64 value must be 8 bytes but is %d bytes", len(b))
+		}
+		if b != nil {
+			v.SetFloat(math.Float64frombits(binary.BigEndian.Uint64(b)))
+		}
+	case t.Kind() == reflect.Bool:
+		if b != nil && len(b) != 1 {
+			return v, fmt.Errorf("bool value must be 1 byte but is %d bytes", len(b))
+		}
+		if b != nil {
+			v.SetBool(b[0] != 0)
+		}
+	default:
+		return v, fmt.Errorf("unsupported type %v", t)
+	}
+	return v, nil
+}
+
+// setBytes sets the string or []byte value to the bytes.
+func setBytes(v reflect.Value, b []byte) {
+	if v.Kind() == reflect.String {
+		v.SetString(string(b))
+		return
+	}
+	v.SetBytes(b)
+}
+
+// newRow converts a bigtable.Row to a Row.
+func newRow(row bigtable.Row) Row {
+	r := Row{Key: row.Key()}
+	for name, items := range row {
+		family := Family{Name: name}
+		for _, item := range items {
+			family.Cells = append(family.Cells, Cell{
+				Column:    strings.TrimPrefix(item.Column, name+":"),
+				Timestamp: item.Timestamp,
+				Value:     item.Value,
+			})
+		}
+		r.Families = append(r.Families, family)
+	}
+	sort.Slice(r.Families, func(i, j int) bool {
+		return r.Families[i].Name < r.Families[j].Name
+	})
+	return r
+}
@@ -0,0 +1,155 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtableio
+
+import (
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/bigtable"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRead_InvalidType(t *testing.T) {
+	tests := []struct {
+		name string
+		t    reflect.Type
+	}{
+		{
+			name: "not a struct",
+			t:    reflect.TypeOf(""),
+		},
+		{
+			name: "invalid key field",
+			t: reflect.TypeOf(struct {
+				Key int64 `bigtable:"__key__"`
+			}{}),
+		},
+		{
+			name: "invalid column tag",
+			t: reflect.TypeOf(struct {
+				Name string `bigtable:"name"`
+			}{}),
+		},
+		{
+			name: "unsupported field type",
+			t: reflect.TypeOf(struct {
+				Count int32 `bigtable:"stats:count"`
+			}{}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Error("Read() did not panic")
+				}
+			}()
+
+			_, s := beam.NewPipelineWithRoot()
+			Read(s, "project", "instance", "table", tt.t)
+		})
+	}
+}
+
+func Test_newRowDecoder(t *testing.T) {
+	type values struct {
+		Key   []byte  `bigtable:"__key__"`
+		Bytes []byte  `bigtable:"f:bytes"`
+		Float float64 `bigtable:"f:float"`
+		Bool  bool    `bigtable:"f:bool"`
+		Count int64   `bigtable:"f:count"`
+	}
+
+	decode, err := newRowDecoder(reflect.TypeOf(values{}))
+	if err != nil {
+		t.Fatalf("newRowDecoder() error = %v", err)
+	}
+
+	got, err := decode(bigtable.Row{
+		"f": {
+			{Row: "key", Column: "f:bool", Timestamp: 2, Value: []byte{1}},
+			{Row: "key", Column: "f:bool", Timestamp: 1, Value: []byte{0}},
+			{Row: "key", Column: "f:bytes", Timestamp: 1, Value: []byte("bytes")},
+			{Row: "key", Column: "f:float", Timestamp: 1, Value: []byte{0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("decode() error = %v", err)
+	}
+
+	want := values{Key: []byte("key"), Bytes: []byte("bytes"), Float: 1.5, Bool: true}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("decode() mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := decode(bigtable.Row{"f": {{Row: "key", Column: "f:count", Value: []byte{1}}}}); err == nil {
+		t.Error("decode() of an invalid int64 value succeeded, want error")
+	}
+}
+
+func Test_prefixSuccessor(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{prefix: "", want: ""},
+		{prefix: "a", want: "b"},
+		{prefix: "ab\xff", want: "ac"},
+		{prefix: "\xff\xff", want: ""},
+	}
+	for _, tt := range tests {
+		if got := prefixSuccessor(tt.prefix); got != tt.want {
+			t.Errorf("prefixSuccessor(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func Test_mergeRowRanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		ranges []RowRange
+		want   []keyRange
+	}{
+		{
+			name: "no ranges",
+			want: []keyRange{{}},
+		},
+		{
+			name:   "disjoint ranges",
+			ranges: []RowRange{{Start: "c", End: "d"}, {Start: "a", End: "b"}},
+			want:   []keyRange{{Start: "a", End: "b"}, {Start: "c", End: "d"}},
+		},
+		{
+			name:   "overlapping and adjacent ranges",
+			ranges: []RowRange{{Start: "b", End: "c"}, {Start: "a", End: "b"}, {Start: "a", End: "bb"}},
+			want:   []keyRange{{Start: "a", End: "c"}},
+		},
+		{
+			name:   "unbounded range",
+			ranges: []RowRange{{Start: "b"}, {Start: "c", End: "d"}, {Start: "a", End: "b"}},
+			want:   []keyRange{{Start: "a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, mergeRowRanges(tt.ranges)); diff != "" {
+				t.Errorf("mergeRowRanges() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package csvio contains transforms for reading and writing CSV files, and
+// other delimiter-separated files such as TSV files, as Go structs or schema
+// rows.
+//
+// The columns of a file are mapped to the exported fields of a struct by
+// name, as given by the header row of the file. The name of a field is taken
+// from its csv tag, or else from its beam tag, as used by schema rows, or else
+// is the name of the field. Fields tagged with `csv:"-"` are ignored:
+//
+//	type Purchase struct {
+//		Customer string    `csv:"customer_id"`
+//		Amount   float64   `csv:"amount"`
+//		Time     time.Time `csv:"time"`
+//		Coupon   *string   `csv:"coupon"`
+//		Internal string    `csv:"-"`
+//	}
+//
+// Fields may be strings, booleans, integers, floating point numbers, byte
+// slices, time.Time values in RFC 3339 format, or pointers to them. Null
+// values, which are empty cells by default, are read as nil pointers or zero
+// values.
+package csvio
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field is a struct field that is mapped to a column.
+type field struct {
+	name  string
+	index int
+}
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	bytesType = reflect.TypeOf([]byte(nil))
+)
+
+// structFields returns the fields of the struct type that are mapped to
+// columns, in order.
+func structFields(t reflect.Type) ([]field, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("type %v is not a struct", t)
+	}
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := fieldName(f)
+		if name == "-" {
+			continue
+		}
+		if !isSupported(f.Type) {
+			return nil, fmt.Errorf("field %v of type %v has unsupported type %v", f.Name, t, f.Type)
+		}
+		fields = append(fields, field{name: name, index: i})
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("type %v has no exported fields", t)
+	}
+	return fields, nil
+}
+
+func fieldName(f reflect.StructField) string {
+	for _, key := range []string{"csv", "beam"} {
+		if tag, ok := f.Tag.Lookup(key); ok {
+			if name, _, _ := strings.Cut(tag, ","); name != "" {
+				return name
+			}
+		}
+	}
+	return f.Name
+}
+
+func isSupported(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType || t == bytesType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// columnFields maps the columns of the header to the indices of the fields
+// with the same names, or -1 for columns without a field. Names are matched
+// exactly, or else case-insensitively. Without a header, the columns are
+// mapped to the fields in order.
+func columnFields(fields []field, header []string) []int {
+	if header == nil {
+		cols := make([]int, len(fields))
+		for i, f := range fields {
+			cols[i] = f.index
+		}
+		return cols
+	}
+	cols := make([]int, len(header))
+	for i, name := range header {
+		cols[i] = -1
+		for _, f := range fields {
+			if f.name == name {
+				cols[i] = f.index
+				break
+			}
+			if cols[i] == -1 && strings.EqualFold(f.name, name) {
+				cols[i] = f.index
+			}
+		}
+	}
+	return cols
+}
+
+// parseValue sets v to the value of the cell, or to its zero value if the
+// cell is null.
+func parseValue(v reflect.Value, cell string, null bool) error {
+	if null {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		p := reflect.New(v.Type().Elem())
+		if err := parseValue(p.Elem(), cell, false); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+
+	switch {
+	case v.Type() == timeType:
+		t, err := time.Parse(time.RFC3339Nano, cell)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case v.Type() == bytesType:
+		v.SetBytes([]byte(cell))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(cell)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(cell)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(cell, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(cell, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(cell, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	}
+	return nil
+}
+
+// formatValue formats v as a cell, or returns ok false if v is a nil
+// pointer.
+func formatValue(v reflect.Value) (cell string, ok bool) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", false
+		}
+		v = v.Elem()
+	}
+
+	switch {
+	case v.Type() == timeType:
+		return v.Interface().(time.Time).Format(time.RFC3339Nano), true
+	case v.Type() == bytesType:
+		return string(v.Bytes()), true
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), true
+	default:
+		return fmt.Sprint(v.Interface()), true
+	}
+}
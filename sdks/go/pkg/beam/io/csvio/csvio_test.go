@@ -0,0 +1,342 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csvio
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/graphx/schema"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/local"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*purchase)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*row)(nil)).Elem())
+	register.Function1x1(parseErrorOffset)
+}
+
+func TestMain(m *testing.M) {
+	ptest.Main(m)
+}
+
+type purchase struct {
+	Customer string    `csv:"customer_id"`
+	Amount   float64   `csv:"amount"`
+	Time     time.Time `csv:"time"`
+	Coupon   *string   `csv:"coupon"`
+	Note     string
+	Ignored  string `csv:"-"`
+}
+
+func parseErrorOffset(e ParseError) int64 {
+	return e.Offset
+}
+
+func coupon(s string) *string {
+	return &s
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "in.csv")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const purchases = `Note,customer_id,amount,time,coupon,extra
+"multi
+line, ""quoted""",c1,1.5,2024-01-01T00:00:00Z,,x
+,c2,2,2024-01-02T00:00:00Z,SALE,y
+bad,c3,not a number,2024-01-03T00:00:00Z,,z
+b"ad,c4,1,2024-01-04T00:00:00Z,,z
+`
+
+func wantPurchases() []any {
+	return []any{
+		purchase{Customer: "c1", Amount: 1.5, Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Note: "multi\nline, \"quoted\""},
+		purchase{Customer: "c2", Amount: 2, Time: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Coupon: coupon("SALE")},
+	}
+}
+
+func TestRead(t *testing.T) {
+	path := writeFile(t, purchases)
+
+	p, s := beam.NewPipelineWithRoot()
+	rows, errs := Read(s, path, reflect.TypeOf(purchase{}))
+	passert.Equals(s, rows, wantPurchases()...)
+	passert.Equals(s, beam.ParDo(s, parseErrorOffset, errs), int64(132), int64(176))
+	ptest.RunAndValidate(t, p)
+}
+
+const singleLinePurchases = `Note,customer_id,amount,time,coupon,extra
+,c1,1.5,2024-01-01T00:00:00Z,,x
+,c2,2,2024-01-02T00:00:00Z,SALE,y
+bad,c3,not a number,2024-01-03T00:00:00Z,,z
+,c5,5,2024-01-05T00:00:00Z,,z
+b"ad,c4,1,2024-01-04T00:00:00Z,,z
+`
+
+func TestRead_Splits(t *testing.T) {
+	defer func(size int64) { blockSize = size }(blockSize)
+	blockSize = 10
+	path := writeFile(t, singleLinePurchases)
+
+	p, s := beam.NewPipelineWithRoot()
+	rows, errs := Read(s, path, reflect.TypeOf(purchase{}))
+	passert.Equals(s, rows,
+		purchase{Customer: "c1", Amount: 1.5, Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		purchase{Customer: "c2", Amount: 2, Time: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Coupon: coupon("SALE")},
+		purchase{Customer: "c5", Amount: 5, Time: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)},
+	)
+	passert.Equals(s, beam.ParDo(s, parseErrorOffset, errs), int64(108), int64(182))
+	ptest.RunAndValidate(t, p)
+}
+
+func TestRead_QuotedSplits(t *testing.T) {
+	defer func(size int64) { blockSize = size }(blockSize)
+	blockSize = 10
+	path := writeFile(t, purchases)
+
+	p, s := beam.NewPipelineWithRoot()
+	rows, errs := Read(s, path, reflect.TypeOf(purchase{}))
+	passert.Equals(s, rows, wantPurchases()...)
+	passert.Equals(s, beam.ParDo(s, parseErrorOffset, errs), int64(132), int64(176))
+	ptest.RunAndValidate(t, p)
+}
+
+func TestFormatSyncRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  format
+		content string
+	}{
+		{
+			name:    "quoted line breaks",
+			format:  format{Comma: ','},
+			content: "a,\"b\nc\"\n\"d\"\"\n\",e\n\"\",\"\"\"\n\"\nf\n",
+		},
+		{
+			name:    "unquoted",
+			format:  format{Comma: ';'},
+			content: "a;b\nc;d\n\ne;f",
+		},
+		{
+			name:    "quotes around delimiters",
+			format:  format{Comma: ','},
+			content: "\",\n,\",\"\n\"\n\"a\nb\",\"\"\"\"\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The records start where csv.Reader starts reading them, and
+			// syncRecords may return any of them after the offset.
+			var starts []int64
+			cr := test.format.newReader(strings.NewReader(test.content))
+			for {
+				offset := cr.InputOffset()
+				if _, err := cr.Read(); err != nil {
+					if !errors.Is(err, io.EOF) {
+						t.Fatal(err)
+					}
+					break
+				}
+				starts = append(starts, offset)
+			}
+			starts = append(starts, int64(len(test.content)))
+
+			for offset := int64(1); offset <= int64(len(test.content)); offset++ {
+				got, err := test.format.syncRecords(strings.NewReader(test.content[offset-1:]), offset, 1024)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got < offset || !slices.Contains(starts, got) {
+					t.Errorf("syncRecords(%v) = %v, want one of %v after it", offset, got, starts)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatSyncRecords_Ambiguous(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    format
+		content   string
+		offset    int64
+		maxLength int64
+		want      int64
+	}{
+		{
+			name:      "long quoted cell",
+			format:    format{Comma: ','},
+			content:   "\"aaaa\naaaa\"\nb\n",
+			offset:    3,
+			maxLength: 64,
+			want:      12,
+		},
+		{
+			name:      "quoted cell longer than max length",
+			format:    format{Comma: ','},
+			content:   "\"aaaa\naaaa\"\nb\n",
+			offset:    3,
+			maxLength: 2,
+			want:      6,
+		},
+		{
+			name:      "comments",
+			format:    format{Comma: ';', Comment: '#'},
+			content:   "#\"a\n\"b;#\"\nc\n",
+			offset:    2,
+			maxLength: 64,
+			want:      10,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.format.syncRecords(strings.NewReader(test.content[test.offset-1:]), test.offset, test.maxLength)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("syncRecords(%v) = %v, want %v", test.offset, got, test.want)
+			}
+		})
+	}
+}
+
+type row struct {
+	Name  string
+	Count *int
+}
+
+func TestRead_TSVNoHeader(t *testing.T) {
+	path := writeFile(t, "a\t1\nb\tNULL\n")
+
+	p, s := beam.NewPipelineWithRoot()
+	rows, _ := Read(s, path, reflect.TypeOf(row{}), ReadDelimiter('\t'), ReadNoHeader(), ReadNullValue("NULL"))
+	one := 1
+	passert.Equals(s, rows, row{Name: "a", Count: &one}, row{Name: "b"})
+	ptest.RunAndValidate(t, p)
+}
+
+func TestReadSchema(t *testing.T) {
+	path := writeFile(t, "count,name\n1,a\n,b\n")
+	scm := &pipepb.Schema{
+		Fields: []*pipepb.Field{
+			{
+				Name: "name",
+				Type: &pipepb.FieldType{
+					TypeInfo: &pipepb.FieldType_AtomicType{AtomicType: pipepb.AtomicType_STRING},
+				},
+			},
+			{
+				Name: "count",
+				Type: &pipepb.FieldType{
+					Nullable: true,
+					TypeInfo: &pipepb.FieldType_AtomicType{AtomicType: pipepb.AtomicType_INT64},
+				},
+			},
+		},
+	}
+	rt, err := schema.ToType(scm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRow := func(name string, count *int64) any {
+		v := reflect.New(rt).Elem()
+		v.Field(0).SetString(name)
+		v.Field(1).Set(reflect.ValueOf(count))
+		return v.Interface()
+	}
+	one := int64(1)
+
+	p, s := beam.NewPipelineWithRoot()
+	rows, errs := ReadSchema(s, path, scm)
+	passert.Equals(s, rows, newRow("a", &one), newRow("b", nil))
+	passert.Empty(s, errs)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestWrite(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.csv")
+	p, s := beam.NewPipelineWithRoot()
+	Write(s, out, beam.Create(s, wantPurchases()[0]), WriteNullValue("NULL"))
+	ptest.RunAndValidate(t, p)
+
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "customer_id,amount,time,coupon,Note\n" +
+		"c1,1.5,2024-01-01T00:00:00Z,NULL,\"multi\nline, \"\"quoted\"\"\"\n"
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("Write() wrote diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	p, s := beam.NewPipelineWithRoot()
+	Write(s, out, beam.Create(s, wantPurchases()...), WriteDelimiter(';'))
+	ptest.RunAndValidate(t, p)
+
+	p, s = beam.NewPipelineWithRoot()
+	rows, errs := Read(s, out, reflect.TypeOf(purchase{}), ReadDelimiter(';'))
+	passert.Equals(s, rows, wantPurchases()...)
+	passert.Empty(s, errs)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestStructFields(t *testing.T) {
+	type tagged struct {
+		A string `csv:"a,omitempty"`
+		B int    `beam:"b"`
+		C bool
+		D string `csv:"-"`
+		e string
+	}
+	got, err := structFields(reflect.TypeOf(tagged{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []field{{"a", 0}, {"b", 1}, {"C", 2}}
+	if !cmp.Equal(got, want, cmp.AllowUnexported(field{})) {
+		t.Errorf("structFields() = %v, want %v", got, want)
+	}
+
+	type unsupported struct {
+		M map[string]string
+	}
+	if _, err := structFields(reflect.TypeOf(unsupported{})); err == nil {
+		t.Error("structFields() of unsupported type succeeded, want error")
+	}
+}
@@ -0,0 +1,445 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csvio
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime/graphx/schema"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/fileio"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	pipepb "github.com/apache/beam/sdks/v2/go/pkg/beam/model/pipeline_v1"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*ParseError)(nil)).Elem())
+	register.DoFn4x1[context.Context, fileio.FileRange, func(beam.X), func(ParseError), error](&readFn{})
+	register.Emitter1[beam.X]()
+	register.Emitter1[ParseError]()
+}
+
+// blockSize is the desired size of the ranges of a file that are read in
+// parallel.
+var blockSize = fileio.DefaultBlockSize
+
+// maxSyncLength is the length after which a range that may start inside a
+// quoted cell, as it isn't yet known whether the range starts inside a quoted
+// cell or not, is assumed not to.
+var maxSyncLength int64 = 1024 * 1024 // 1 MB
+
+// ParseError is a record that could not be read, either because it is not
+// valid CSV, or because a cell could not be parsed into its field.
+type ParseError struct {
+	// Filename is the name of the file of the record.
+	Filename string
+	// Offset is the offset in bytes of the record in the file.
+	Offset int64
+	// Record is the cells of the record, if it is valid CSV.
+	Record []string
+	// Error is the cause of the error.
+	Error string
+}
+
+type readOption struct {
+	Comma     rune
+	Comment   rune
+	NullValue string
+	NoHeader  bool
+	FileOpts  []fileio.ReadOptionFn
+}
+
+// ReadOptionFn is a function that can be passed to Read or ReadAll to
+// configure options for reading files.
+type ReadOptionFn func(*readOption)
+
+// ReadDelimiter specifies the delimiter of the cells, such as '\t' for TSV
+// files. By default, cells are delimited by commas.
+func ReadDelimiter(delim rune) ReadOptionFn {
+	return func(o *readOption) {
+		o.Comma = delim
+	}
+}
+
+// ReadComment specifies a character that starts comment lines, which are
+// skipped.
+func ReadComment(comment rune) ReadOptionFn {
+	return func(o *readOption) {
+		o.Comment = comment
+	}
+}
+
+// ReadNullValue specifies the value of null cells, such as "NULL". By
+// default, empty cells are null.
+func ReadNullValue(null string) ReadOptionFn {
+	return func(o *readOption) {
+		o.NullValue = null
+	}
+}
+
+// ReadNoHeader specifies that the files have no header row, in which case the
+// columns are mapped to the fields in order.
+func ReadNoHeader() ReadOptionFn {
+	return func(o *readOption) {
+		o.NoHeader = true
+	}
+}
+
+// ReadFileOptions specifies options for reading the matched files, such as
+// their compression.
+func ReadFileOptions(opts ...fileio.ReadOptionFn) ReadOptionFn {
+	return func(o *readOption) {
+		o.FileOpts = append(o.FileOpts, opts...)
+	}
+}
+
+// Read reads a set of files indicated by the glob pattern, and returns a
+// PCollection<T> of the records parsed into the struct type t, and a
+// PCollection<ParseError> of the records that could not be parsed. Read
+// accepts a variadic number of ReadOptionFn that can be used to configure the
+// format of the files.
+//
+// Cells may be quoted, in which case they may contain delimiters, line breaks,
+// and quotes as two quotes. Uncompressed files larger than 64 MB are split
+// into ranges that are read in parallel. Each range starts at the first
+// record after its offset, which is found by scanning from the offset as if
+// it were both inside and outside a quoted cell, until a quote tells which
+// one it is. If no quote does so within 1 MB, the offset is assumed to be
+// outside a quoted cell, so files with quoted cells longer than that may be
+// read incorrectly. Compressed files are read as a whole.
+func Read(s beam.Scope, glob string, t reflect.Type, opts ...ReadOptionFn) (beam.PCollection, beam.PCollection) {
+	s = s.Scope("csvio.Read")
+
+	filesystem.ValidateScheme(glob)
+	return read(s, t, beam.Create(s, glob), opts...)
+}
+
+// ReadSchema reads a set of files indicated by the glob pattern as Read does,
+// and returns a PCollection of schema rows of the given schema, and a
+// PCollection<ParseError>. The rows are values of the struct type that
+// schema.ToType returns for the schema, whose fields are named after the
+// fields of the schema. The fields of the schema must have atomic types, or
+// logical types of the Go types that Read supports, and may be nullable.
+func ReadSchema(s beam.Scope, glob string, scm *pipepb.Schema, opts ...ReadOptionFn) (beam.PCollection, beam.PCollection) {
+	s = s.Scope("csvio.ReadSchema")
+
+	t, err := schema.ToType(scm)
+	if err != nil {
+		panic(fmt.Sprintf("csvio: converting schema to type: %v", err))
+	}
+	filesystem.ValidateScheme(glob)
+	return read(s, t, beam.Create(s, glob), opts...)
+}
+
+// ReadAll expands and reads the filenames given as globs by the incoming
+// PCollection<string>, as Read does.
+func ReadAll(s beam.Scope, col beam.PCollection, t reflect.Type, opts ...ReadOptionFn) (beam.PCollection, beam.PCollection) {
+	s = s.Scope("csvio.ReadAll")
+	return read(s, t, col, opts...)
+}
+
+func read(s beam.Scope, t reflect.Type, col beam.PCollection, opts ...ReadOptionFn) (beam.PCollection, beam.PCollection) {
+	if _, err := structFields(t); err != nil {
+		panic(fmt.Sprintf("csvio: %v", err))
+	}
+	option := &readOption{Comma: ','}
+	for _, opt := range opts {
+		opt(option)
+	}
+	format := format{
+		Comma:     option.Comma,
+		Comment:   option.Comment,
+		NullValue: option.NullValue,
+		NoHeader:  option.NoHeader,
+	}
+	if err := format.validate(); err != nil {
+		panic(fmt.Sprintf("csvio: %v", err))
+	}
+
+	matches := fileio.MatchAll(s, col, fileio.MatchEmptyAllow())
+	files := fileio.ReadMatches(s, matches, option.FileOpts...)
+	ranges := fileio.SplitFiles(s, files, blockSize)
+	return beam.ParDo2(s,
+		&readFn{Format: format, Type: beam.EncodedType{T: t}, MaxSyncLength: maxSyncLength},
+		ranges,
+		beam.TypeDefinition{Var: beam.XType, T: t},
+	)
+}
+
+// format is the format of the files.
+type format struct {
+	Comma     rune   `json:"comma"`
+	Comment   rune   `json:"comment"`
+	NullValue string `json:"nullValue"`
+	NoHeader  bool   `json:"noHeader"`
+}
+
+func (f format) validate() error {
+	r := csv.NewReader(strings.NewReader(""))
+	r.Comma = f.Comma
+	r.Comment = f.Comment
+	// Read checks the delimiters before reading anything.
+	if _, err := r.Read(); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+func (f format) newReader(r io.Reader) *csv.Reader {
+	cr := csv.NewReader(r)
+	cr.Comma = f.Comma
+	cr.Comment = f.Comment
+	cr.FieldsPerRecord = -1
+	return cr
+}
+
+// scanState is the state of a scan of the records of a file after a
+// character.
+type scanState struct {
+	quoted    bool // in a quoted cell
+	closed    bool // after the closing quote of a quoted cell
+	comment   bool // in a comment line
+	cellStart bool // at the start of a cell
+	lineStart bool // at the start of a record
+}
+
+// next returns the state after the character c. A quote only starts a quoted
+// cell at the start of a cell, as in encoding/csv, so records start where
+// csv.Reader starts reading them, including records that are not valid CSV.
+func (f format) next(s scanState, c rune) scanState {
+	switch {
+	case s.comment:
+		s.comment = c != '\n'
+	case s.quoted:
+		if c == '"' {
+			s.quoted, s.closed = false, true
+		}
+		return s
+	case s.closed && c == '"':
+		// A quote right after a closing quote is an escaped quote.
+		s.quoted, s.closed = true, false
+		return s
+	case s.lineStart && f.Comment != 0 && c == f.Comment:
+		s.comment = true
+	case s.cellStart && c == '"':
+		s.quoted = true
+		s.cellStart, s.lineStart = false, false
+		return s
+	}
+	s.closed = false
+	s.lineStart = c == '\n' && !s.comment
+	s.cellStart = s.lineStart || c == f.Comma
+	return s
+}
+
+// syncRecords returns the offset of the first record that starts at or after
+// offset, reading r from the character before offset. Since that character
+// may be inside a quoted cell, or right after one, or in a comment, the
+// characters are scanned from each of these states until the scans agree on
+// the state, and the first record after that is returned. If they don't agree
+// within maxLength bytes, the character is assumed to be outside a quoted
+// cell. It returns the offset of the end of the file if no record starts
+// after offset.
+func (f format) syncRecords(r io.RuneReader, offset, maxLength int64) (int64, error) {
+	states := []scanState{{}, {quoted: true}, {closed: true}}
+	if f.Comment != 0 {
+		states = append(states, scanState{comment: true})
+	}
+	pos := offset - 1
+	for {
+		c, size, err := r.ReadRune()
+		if errors.Is(err, io.EOF) {
+			return pos, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		pos += int64(size)
+
+		unique := states[:0]
+		for _, s := range states {
+			s = f.next(s, c)
+			if !slices.Contains(unique, s) {
+				unique = append(unique, s)
+			}
+		}
+		states = unique
+		if len(states) > 1 && pos-offset >= maxLength {
+			// The first state is outside a quoted cell.
+			states = states[:1]
+		}
+		if len(states) == 1 && states[0].lineStart {
+			return pos, nil
+		}
+	}
+}
+
+// readFn reads the records of a range of a file.
+type readFn struct {
+	Format        format           `json:"format"`
+	Type          beam.EncodedType `json:"type"`
+	MaxSyncLength int64            `json:"maxSyncLength"`
+
+	fields []field
+}
+
+func (fn *readFn) Setup() error {
+	var err error
+	fn.fields, err = structFields(fn.Type.T)
+	return err
+}
+
+// ProcessElement reads the records from the first record at or after the
+// start of the range, to the first record at or after its end, which is where
+// the next range starts reading.
+func (fn *readFn) ProcessElement(ctx context.Context, r fileio.FileRange, emit func(beam.X), emitErr func(ParseError)) error {
+	header, headerEnd, err := fn.readHeader(ctx, r.File)
+	if errors.Is(err, io.EOF) {
+		if r.Start == 0 {
+			log.Warnf(ctx, "csvio: skipping empty file %v", r.File.Metadata.Path)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	offset, err := fn.syncRecords(ctx, r.File, r.Start, headerEnd)
+	if err != nil {
+		return err
+	}
+	end := r.End
+	if end != math.MaxInt64 {
+		if end, err = fn.syncRecords(ctx, r.File, end, headerEnd); err != nil {
+			return err
+		}
+	}
+	if offset >= end {
+		return nil
+	}
+	fd, err := r.File.OpenAt(ctx, offset)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	cols := columnFields(fn.fields, header)
+	cr := fn.Format.newReader(bufio.NewReader(fd))
+	for {
+		recordOffset := offset + cr.InputOffset()
+		if recordOffset >= end {
+			return nil
+		}
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil && !isParseError(err) {
+			return err
+		}
+		if err == nil {
+			var v reflect.Value
+			if v, err = fn.parse(record, cols); err == nil {
+				emit(v.Interface())
+				continue
+			}
+		}
+		emitErr(ParseError{
+			Filename: r.File.Metadata.Path,
+			Offset:   recordOffset,
+			Record:   record,
+			Error:    err.Error(),
+		})
+	}
+}
+
+// syncRecords returns the offset of the first record at or after offset, or
+// the end of the header if offset is before it.
+func (fn *readFn) syncRecords(ctx context.Context, file fileio.ReadableFile, offset, headerEnd int64) (int64, error) {
+	if offset <= headerEnd {
+		return headerEnd, nil
+	}
+	fd, err := file.OpenAt(ctx, offset-1)
+	if err != nil {
+		return 0, err
+	}
+	defer fd.Close()
+
+	sync, err := fn.Format.syncRecords(bufio.NewReader(fd), offset, fn.MaxSyncLength)
+	if err != nil {
+		return 0, fmt.Errorf("finding record at %v of %v: %w", offset, file.Metadata.Path, err)
+	}
+	return sync, nil
+}
+
+// readHeader reads the header of the file, and returns it along with the
+// offset of the first record after it. Files without a header start with a
+// record. It returns io.EOF if the file is empty.
+func (fn *readFn) readHeader(ctx context.Context, file fileio.ReadableFile) ([]string, int64, error) {
+	if fn.Format.NoHeader {
+		return nil, 0, nil
+	}
+
+	fd, err := file.Open(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer fd.Close()
+
+	cr := fn.Format.newReader(bufio.NewReader(fd))
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, 0, err
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading header of %v: %w", file.Metadata.Path, err)
+	}
+	return header, cr.InputOffset(), nil
+}
+
+func isParseError(err error) bool {
+	var perr *csv.ParseError
+	return errors.As(err, &perr)
+}
+
+// parse parses the record into a new value of the type.
+func (fn *readFn) parse(record []string, cols []int) (reflect.Value, error) {
+	if len(record) != len(cols) {
+		return reflect.Value{}, fmt.Errorf("record has %v cells, want %v", len(record), len(cols))
+	}
+	v := reflect.New(fn.Type.T).Elem()
+	for i, cell := range record {
+		if cols[i] < 0 {
+			continue
+		}
+		f := v.Field(cols[i])
+		if err := parseValue(f, cell, cell == fn.Format.NullValue); err != nil {
+			return reflect.Value{}, fmt.Errorf("parsing cell %v into field %v: %w", i+1, fn.Type.T.Field(cols[i]).Name, err)
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,163 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csvio
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/fileio"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*csvSink)(nil)).Elem())
+}
+
+type writeOption struct {
+	Comma     rune
+	NullValue string
+	NoHeader  bool
+	UseCRLF   bool
+	FileOpts  []fileio.WriteOptionFn
+}
+
+// WriteOptionFn is a function that can be passed to Write to configure
+// options for writing files.
+type WriteOptionFn func(*writeOption)
+
+// WriteDelimiter specifies the delimiter of the cells, such as '\t' for TSV
+// files. By default, cells are delimited by commas.
+func WriteDelimiter(delim rune) WriteOptionFn {
+	return func(o *writeOption) {
+		o.Comma = delim
+	}
+}
+
+// WriteNullValue specifies the value written for nil pointers, such as
+// "NULL". By default, nil pointers are written as empty cells.
+func WriteNullValue(null string) WriteOptionFn {
+	return func(o *writeOption) {
+		o.NullValue = null
+	}
+}
+
+// WriteNoHeader specifies that no header row is written.
+func WriteNoHeader() WriteOptionFn {
+	return func(o *writeOption) {
+		o.NoHeader = true
+	}
+}
+
+// WriteCRLF specifies that records end with \r\n rather than \n.
+func WriteCRLF() WriteOptionFn {
+	return func(o *writeOption) {
+		o.UseCRLF = true
+	}
+}
+
+// WriteFileOptions specifies options for writing the files, such as their
+// number of shards, suffix or compression.
+func WriteFileOptions(opts ...fileio.WriteOptionFn) WriteOptionFn {
+	return func(o *writeOption) {
+		o.FileOpts = append(o.FileOpts, opts...)
+	}
+}
+
+// Write writes a PCollection<T> of structs to CSV files, with a header row of
+// the names of the fields in each file, and returns a PCollection<string> of
+// the names of the written files. Cells are quoted as needed. Write accepts a
+// variadic number of WriteOptionFn that can be used to configure the format
+// and how the files are written. By default, a single file is written per
+// window, as described in fileio.WriteFiles.
+func Write(s beam.Scope, filename string, col beam.PCollection, opts ...WriteOptionFn) beam.PCollection {
+	s = s.Scope("csvio.Write")
+
+	t := beam.ValidateNonCompositeType(col).Type()
+	if _, err := structFields(t); err != nil {
+		panic(fmt.Sprintf("csvio.Write: %v", err))
+	}
+	option := &writeOption{Comma: ','}
+	for _, opt := range opts {
+		opt(option)
+	}
+	sink := &csvSink{
+		Type:      beam.EncodedType{T: t},
+		Comma:     option.Comma,
+		NullValue: option.NullValue,
+		NoHeader:  option.NoHeader,
+		UseCRLF:   option.UseCRLF,
+	}
+	if err := (format{Comma: option.Comma}).validate(); err != nil {
+		panic(fmt.Sprintf("csvio.Write: %v", err))
+	}
+
+	fileOpts := append([]fileio.WriteOptionFn{fileio.WriteNumShards(1)}, option.FileOpts...)
+	return fileio.WriteFiles(s, filename, sink, col, fileOpts...)
+}
+
+// csvSink writes structs as CSV records.
+type csvSink struct {
+	Type      beam.EncodedType `json:"type"`
+	Comma     rune             `json:"comma"`
+	NullValue string           `json:"nullValue"`
+	NoHeader  bool             `json:"noHeader"`
+	UseCRLF   bool             `json:"useCRLF"`
+
+	fields []field
+	cw     *csv.Writer
+	record []string
+}
+
+func (s *csvSink) Open(_ context.Context, w io.Writer) error {
+	fields, err := structFields(s.Type.T)
+	if err != nil {
+		return err
+	}
+	s.fields = fields
+	s.record = make([]string, len(fields))
+	s.cw = csv.NewWriter(w)
+	s.cw.Comma = s.Comma
+	s.cw.UseCRLF = s.UseCRLF
+
+	if s.NoHeader {
+		return nil
+	}
+	for i, f := range fields {
+		s.record[i] = f.name
+	}
+	return s.cw.Write(s.record)
+}
+
+func (s *csvSink) Write(_ context.Context, elm any) error {
+	v := reflect.ValueOf(elm)
+	for i, f := range s.fields {
+		cell, ok := formatValue(v.Field(f.index))
+		if !ok {
+			cell = s.NullValue
+		}
+		s.record[i] = cell
+	}
+	return s.cw.Write(s.record)
+}
+
+func (s *csvSink) Flush(_ context.Context) error {
+	s.cw.Flush()
+	return s.cw.Error()
+}
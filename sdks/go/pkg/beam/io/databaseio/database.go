@@ -85,18 +85,29 @@ func (f *queryFn) ProcessElement(ctx context.Context, _ []byte, emit func(beam.X
 		return errors.Wrapf(err, "failed to run query: %v", f.Query)
 	}
 	defer rows.Close()
+	err = scanRows(rows, f.Type.T, func(row any, _ []any) error {
+		emit(row)
+		return nil
+	})
+	return errors.WithContextf(err, "reading %v", f.Query)
+}
+
+// scanRows scans the rows into values of type t, and calls fn with each value
+// and the values of its columns.
+func scanRows(rows *sql.Rows, t reflect.Type, fn func(row any, values []any) error) error {
 	var mapper rowMapper
 	var columns []string
 	for rows.Next() {
-		reflectRow := reflect.New(f.Type.T)
+		reflectRow := reflect.New(t)
 		row := reflectRow.Interface() // row : *T
 		if mapper == nil {
+			var err error
 			columns, err = rows.Columns()
 			if err != nil {
 				return err
 			}
 			columnsTypes, _ := rows.ColumnTypes()
-			if mapper, err = newQueryMapper(columns, columnsTypes, f.Type.T); err != nil {
+			if mapper, err = newQueryMapper(columns, columnsTypes, t); err != nil {
 				return errors.WithContext(err, "creating rowValues mapper")
 			}
 		}
@@ -106,7 +117,11 @@ func (f *queryFn) ProcessElement(ctx context.Context, _ []byte, emit func(beam.X
 		}
 		err = rows.Scan(rowValues...)
 		if err != nil {
-			return errors.Wrapf(err, "failed to scan %v", f.Query)
+			return errors.Wrap(err, "failed to scan row")
+		}
+		values := make([]any, len(rowValues))
+		for i, value := range rowValues {
+			values[i] = indirect(value)
 		}
 		if loader, ok := row.(MapLoader); ok {
 			asDereferenceSlice(rowValues)
@@ -115,9 +130,11 @@ func (f *queryFn) ProcessElement(ctx context.Context, _ []byte, emit func(beam.X
 			asDereferenceSlice(rowValues)
 			loader.LoadSlice(rowValues)
 		}
-		emit(reflect.ValueOf(row).Elem().Interface()) // emit(*row)
+		if err := fn(reflect.ValueOf(row).Elem().Interface(), values); err != nil { // fn(*row)
+			return err
+		}
 	}
-	return nil
+	return rows.Err()
 }
 
 // Write writes the elements of the given PCollection<T> to database, if columns left empty all table columns are used to insert into, otherwise selected
@@ -138,6 +155,77 @@ func WriteWithBatchSize(s beam.Scope, batchSize int, driver, dsn, table string,
 	beam.ParDo0(s, &writeFn{Driver: driver, Dsn: dsn, Table: table, Columns: columns, BatchSize: batchSize, Type: beam.EncodedType{T: t}}, post)
 }
 
+type writeOption struct {
+	BatchSize     int
+	Keys          []string
+	Transactional bool
+	MaxRetries    int
+}
+
+// WriteOptionFn is a function that can be passed to WriteWithOptions to
+// configure options for writing rows.
+type WriteOptionFn func(*writeOption)
+
+// WriteBatchSize specifies the number of rows in each batch INSERT statement.
+// By default, batches have 1000 rows.
+func WriteBatchSize(batchSize int) WriteOptionFn {
+	return func(o *writeOption) {
+		o.BatchSize = batchSize
+	}
+}
+
+// WriteUpsert specifies that rows whose key columns match existing rows
+// update the other columns of those rows, rather than fail to be inserted.
+// The key columns must have a unique index. Upserts are supported for the
+// Postgres ("postgres", "pgx"), MySQL ("mysql") and SQLite ("sqlite",
+// "sqlite3") drivers, with ON CONFLICT or ON DUPLICATE KEY clauses.
+func WriteUpsert(keys ...string) WriteOptionFn {
+	return func(o *writeOption) {
+		o.Keys = keys
+	}
+}
+
+// WriteTransactional specifies that each batch is written in a transaction,
+// which is retried up to maxRetries times with exponential backoff if it fails
+// with a serialization failure or deadlock.
+func WriteTransactional(maxRetries int) WriteOptionFn {
+	return func(o *writeOption) {
+		o.Transactional = true
+		o.MaxRetries = maxRetries
+	}
+}
+
+// WriteWithOptions writes the elements of the given PCollection<T> to database,
+// as described in Write. It accepts a variadic number of WriteOptionFn that
+// can be used to configure how the rows are written, such as upserting them.
+func WriteWithOptions(s beam.Scope, driver, dsn, table string, columns []string, col beam.PCollection, opts ...WriteOptionFn) {
+	t := col.Type().Type()
+	s = s.Scope(driver + ".Write")
+	option := &writeOption{BatchSize: writeRowLimit}
+	for _, opt := range opts {
+		opt(option)
+	}
+	if option.BatchSize <= 0 {
+		panic(fmt.Sprintf("databaseio: batch size must be positive, got %v", option.BatchSize))
+	}
+	if len(option.Keys) > 0 && dialectOf(driver) == dialectUnknown {
+		panic(fmt.Sprintf("databaseio: upsert isn't supported for driver %v", driver))
+	}
+	pre := beam.AddFixedKey(s, col)
+	post := beam.GroupByKey(s, pre)
+	beam.ParDo0(s, &writeFn{
+		Driver:        driver,
+		Dsn:           dsn,
+		Table:         table,
+		Columns:       columns,
+		BatchSize:     option.BatchSize,
+		Keys:          option.Keys,
+		Transactional: option.Transactional,
+		MaxRetries:    option.MaxRetries,
+		Type:          beam.EncodedType{T: t},
+	}, post)
+}
+
 type writeFn struct {
 	// Project is the project
 	Driver string `json:"driver"`
@@ -149,6 +237,12 @@ type writeFn struct {
 	Columns []string `json:"columns"`
 	//BatchSize size
 	BatchSize int `json:"batchSize"`
+	// Keys are the key columns of upserts, if any.
+	Keys []string `json:"keys,omitempty"`
+	// Transactional is whether batches are written in transactions.
+	Transactional bool `json:"transactional,omitempty"`
+	// MaxRetries is the number of retries of transactions.
+	MaxRetries int `json:"maxRetries,omitempty"`
 	// Type is the encoded schema type.
 	Type beam.EncodedType `json:"type"`
 }
@@ -183,10 +277,17 @@ func (f *writeFn) ProcessElement(ctx context.Context, _ int, iter func(*beam.X)
 	if err != nil {
 		return errors.WithContext(err, "creating row mapper")
 	}
-	writer, err := newWriter(f.Driver, f.BatchSize, f.Table, columns)
+	var writer *writer
+	if len(f.Keys) > 0 {
+		writer, err = newUpsertWriter(f.Driver, f.BatchSize, f.Table, columns, f.Keys)
+	} else {
+		writer, err = newWriter(f.Driver, f.BatchSize, f.Table, columns)
+	}
 	if err != nil {
 		return err
 	}
+	writer.transactional = f.Transactional
+	writer.maxRetries = f.MaxRetries
 	var val beam.X
 	for iter(&val) {
 		var row []any
@@ -0,0 +1,182 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package databaseio
+
+import (
+	goerrors "errors"
+	"fmt"
+	"strings"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+)
+
+// dialect is the SQL dialect of a database, as far as it differs between the
+// supported databases.
+type dialect int
+
+const (
+	dialectUnknown dialect = iota
+	dialectPostgres
+	dialectMySQL
+	dialectSQLite
+)
+
+// dialectOf returns the dialect of the databases of a driver.
+func dialectOf(driver string) dialect {
+	switch driver {
+	case "postgres", "pgx", "cloudsqlpostgres":
+		return dialectPostgres
+	case "mysql":
+		return dialectMySQL
+	case "sqlite", "sqlite3":
+		return dialectSQLite
+	default:
+		return dialectUnknown
+	}
+}
+
+// placeholder returns the placeholder of the i-th parameter of a statement,
+// starting from 1.
+func (d dialect) placeholder(i int) string {
+	if d == dialectPostgres {
+		return fmt.Sprintf("$%d", i)
+	}
+	return "?"
+}
+
+// quote returns an identifier quoted for the dialect, so that it keeps its
+// case and may be a reserved word.
+func (d dialect) quote(identifier string) string {
+	if d == dialectMySQL {
+		return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+// quoteName returns a possibly qualified name, such as schema.table, with
+// each of its parts quoted for the dialect.
+func (d dialect) quoteName(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = d.quote(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// upsertClause returns the clause that turns an INSERT of the columns into an
+// upsert, which updates the non-key columns of the rows whose keys exist.
+// Keys match columns case-insensitively, and are quoted as named by columns.
+func (d dialect) upsertClause(columns, keys []string) (string, error) {
+	if len(keys) == 0 {
+		return "", errors.New("upsert requires key columns")
+	}
+	isKey := make(map[string]bool)
+	var conflict []string
+	for _, key := range keys {
+		i := indexFold(columns, key)
+		if i < 0 {
+			return "", errors.Errorf("key column %v is not one of the written columns %v", key, columns)
+		}
+		isKey[strings.ToLower(key)] = true
+		conflict = append(conflict, d.quote(columns[i]))
+	}
+	var updates []string
+	for _, column := range columns {
+		if isKey[strings.ToLower(column)] {
+			continue
+		}
+		column = d.quote(column)
+		switch d {
+		case dialectMySQL:
+			updates = append(updates, fmt.Sprintf("%v = VALUES(%v)", column, column))
+		default:
+			updates = append(updates, fmt.Sprintf("%v = excluded.%v", column, column))
+		}
+	}
+
+	switch d {
+	case dialectPostgres, dialectSQLite:
+		if len(updates) == 0 {
+			return fmt.Sprintf(" ON CONFLICT (%v) DO NOTHING", strings.Join(conflict, ",")), nil
+		}
+		return fmt.Sprintf(" ON CONFLICT (%v) DO UPDATE SET %v", strings.Join(conflict, ","), strings.Join(updates, ", ")), nil
+	case dialectMySQL:
+		if len(updates) == 0 {
+			updates = []string{fmt.Sprintf("%v = %v", conflict[0], conflict[0])}
+		}
+		return " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", "), nil
+	default:
+		return "", errors.New("upsert is only supported for Postgres, MySQL and SQLite")
+	}
+}
+
+// upsertAffected returns the least and the most rows that an upsert of
+// rowCount rows may report as affected. MySQL counts an inserted row once, an
+// updated row twice, and an unchanged row not at all, and Postgres and SQLite
+// count the inserted and updated rows, which are all rows unless the upsert
+// has no columns to update.
+func (d dialect) upsertAffected(rowCount int, updates bool) (int, int) {
+	switch {
+	case d == dialectMySQL:
+		return 0, 2 * rowCount
+	case !updates:
+		return 0, rowCount
+	default:
+		return rowCount, rowCount
+	}
+}
+
+// indexFold returns the index of the first value that equals value
+// case-insensitively, or -1.
+func indexFold(values []string, value string) int {
+	for i, v := range values {
+		if strings.EqualFold(v, value) {
+			return i
+		}
+	}
+	return -1
+}
+
+// isSerializationFailure reports whether an error is a serialization failure
+// or deadlock of a transaction, after which the transaction may succeed if
+// it's retried.
+func isSerializationFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var state interface{ SQLState() string }
+	if goerrors.As(err, &state) {
+		switch state.SQLState() {
+		case "40001", "40P01":
+			return true
+		}
+	}
+	msg := err.Error()
+	for _, s := range []string{
+		"40001",                      // serialization_failure
+		"40P01",                      // deadlock_detected
+		"Error 1213",                 // MySQL ER_LOCK_DEADLOCK
+		"Error 1205",                 // MySQL ER_LOCK_WAIT_TIMEOUT
+		"could not serialize access", // Postgres
+		"database is locked",         // SQLITE_BUSY
+		"database table is locked",   // SQLITE_LOCKED
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package databaseio
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDialect_upsertClause(t *testing.T) {
+	tests := []struct {
+		driver  string
+		columns []string
+		keys    []string
+		want    string
+	}{
+		{"postgres", []string{"id", "name", "age"}, []string{"id"}, ` ON CONFLICT ("id") DO UPDATE SET "name" = excluded."name", "age" = excluded."age"`},
+		{"sqlite3", []string{"a", "b", "c"}, []string{"a", "B"}, ` ON CONFLICT ("a","b") DO UPDATE SET "c" = excluded."c"`},
+		{"pgx", []string{"id"}, []string{"id"}, ` ON CONFLICT ("id") DO NOTHING`},
+		{"postgres", []string{"Key", `Quoted"Name`}, []string{"key"}, ` ON CONFLICT ("Key") DO UPDATE SET "Quoted""Name" = excluded."Quoted""Name"`},
+		{"mysql", []string{"id", "order"}, []string{"id"}, " ON DUPLICATE KEY UPDATE `order` = VALUES(`order`)"},
+		{"mysql", []string{"id"}, []string{"id"}, " ON DUPLICATE KEY UPDATE `id` = `id`"},
+	}
+	for _, test := range tests {
+		got, err := dialectOf(test.driver).upsertClause(test.columns, test.keys)
+		if err != nil {
+			t.Errorf("upsertClause(%v, %v) for %v failed: %v", test.columns, test.keys, test.driver, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("upsertClause(%v, %v) for %v = %q, want %q", test.columns, test.keys, test.driver, got, test.want)
+		}
+	}
+
+	for _, test := range []struct {
+		driver  string
+		columns []string
+		keys    []string
+	}{
+		{"ramsql", []string{"id", "name"}, []string{"id"}},
+		{"postgres", []string{"id", "name"}, nil},
+		{"postgres", []string{"id", "name"}, []string{"key"}},
+	} {
+		if _, err := dialectOf(test.driver).upsertClause(test.columns, test.keys); err == nil {
+			t.Errorf("upsertClause(%v, %v) for %v succeeded, want error", test.columns, test.keys, test.driver)
+		}
+	}
+}
+
+func TestDialect_quoteName(t *testing.T) {
+	tests := []struct {
+		d    dialect
+		name string
+		want string
+	}{
+		{dialectPostgres, "order", `"order"`},
+		{dialectPostgres, "public.Order", `"public"."Order"`},
+		{dialectSQLite, `a"b`, `"a""b"`},
+		{dialectMySQL, "db.order", "`db`.`order`"},
+	}
+	for _, test := range tests {
+		if got := test.d.quoteName(test.name); got != test.want {
+			t.Errorf("quoteName(%q) = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDialect_upsertAffected(t *testing.T) {
+	tests := []struct {
+		driver      string
+		updates     bool
+		least, most int
+	}{
+		{"postgres", true, 3, 3},
+		{"sqlite", true, 3, 3},
+		{"postgres", false, 0, 3},
+		{"mysql", true, 0, 6},
+		{"mysql", false, 0, 6},
+	}
+	for _, test := range tests {
+		least, most := dialectOf(test.driver).upsertAffected(3, test.updates)
+		if least != test.least || most != test.most {
+			t.Errorf("upsertAffected(3, %v) for %v = (%v, %v), want (%v, %v)", test.updates, test.driver, least, most, test.least, test.most)
+		}
+	}
+}
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "error" }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func TestIsSerializationFailure(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{sqlStateError("40001"), true},
+		{fmt.Errorf("commit: %w", sqlStateError("40P01")), true},
+		{sqlStateError("23505"), false},
+		{errors.New("Error 1213 (40001): Deadlock found when trying to get lock"), true},
+		{errors.New("pq: could not serialize access due to concurrent update"), true},
+		{errors.New("database is locked (5) (SQLITE_BUSY)"), true},
+		{errors.New("duplicate key value violates unique constraint"), false},
+	}
+	for _, test := range tests {
+		if got := isSerializationFailure(test.err); got != test.want {
+			t.Errorf("isSerializationFailure(%v) = %v, want %v", test.err, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package databaseio
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/rtrackers/offsetrange"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*bounds)(nil)).Elem())
+	register.DoFn3x1[context.Context, []byte, func(bounds), error](&boundsFn{})
+	register.DoFn4x1[context.Context, *sdf.LockRTracker, bounds, func(beam.X), error](&partitionFn{})
+	register.DoFn3x1[context.Context, []byte, func(beam.X), error](&keysetFn{})
+	register.Emitter1[bounds]()
+	register.Emitter1[beam.X]()
+}
+
+// ReadPartitioned reads all rows from the given table in parallel, by
+// splitting the range of the values of a numeric or time column into
+// partitions that are read by separate queries. The table must have a schema
+// compatible with the given type, t, and ReadPartitioned returns a
+// PCollection<t>.
+//
+// The partitions are further split dynamically while they are read, so the
+// column should be indexed and its values should be spread evenly, such as
+// an auto-incremented key or a creation time. Rows whose column is null are
+// not read. The table, which may be qualified by its schema, and the column
+// are quoted as identifiers, so their names must match their case in the
+// database.
+func ReadPartitioned(s beam.Scope, driver, dsn, table string, t reflect.Type, column string, partitions int) beam.PCollection {
+	s = s.Scope(driver + ".ReadPartitioned")
+	return queryPartitioned(s, driver, dsn, dialectOf(driver).quoteName(table), t, column, partitions)
+}
+
+// QueryPartitioned executes a query in parallel, as described in
+// ReadPartitioned, by splitting the range of the values of a numeric or time
+// column of its results. The output must have a schema compatible with the
+// given type, t. It returns a PCollection<t>.
+func QueryPartitioned(s beam.Scope, driver, dsn, q string, t reflect.Type, column string, partitions int) beam.PCollection {
+	s = s.Scope(driver + ".QueryPartitioned")
+	return queryPartitioned(s, driver, dsn, fmt.Sprintf("(%v) AS partitioned", q), t, column, partitions)
+}
+
+func queryPartitioned(s beam.Scope, driver, dsn, from string, t reflect.Type, column string, partitions int) beam.PCollection {
+	if partitions <= 0 {
+		panic(fmt.Sprintf("databaseio: partitions must be positive, got %v", partitions))
+	}
+	imp := beam.Impulse(s)
+	b := beam.ParDo(s, &boundsFn{Driver: driver, Dsn: dsn, From: from, Column: column}, imp)
+	return beam.ParDo(s, &partitionFn{
+		Driver:     driver,
+		Dsn:        dsn,
+		From:       from,
+		Column:     column,
+		Partitions: partitions,
+		Type:       beam.EncodedType{T: t},
+	}, b, beam.TypeDefinition{Var: beam.XType, T: t})
+}
+
+// bounds is the range of the values of a partitioning column, as integers or
+// as nanoseconds since the Unix epoch for times.
+type bounds struct {
+	// Start is the lowest value, and End is one more than the highest value.
+	Start, End int64
+	// Time is whether the values are times.
+	Time bool
+}
+
+// position returns the position of a value of the column in a range of its
+// values, and whether the value is a time.
+func position(value any) (int64, bool, error) {
+	switch v := value.(type) {
+	case int64:
+		return v, false, nil
+	case int, int8, int16, int32, uint, uint8, uint16, uint32, uint64:
+		n := reflect.ValueOf(v)
+		if n.CanInt() {
+			return n.Int(), false, nil
+		}
+		if n.Uint() > math.MaxInt64 {
+			return 0, false, errors.Errorf("value %v is out of range", v)
+		}
+		return int64(n.Uint()), false, nil
+	case float32:
+		return int64(math.Floor(float64(v))), false, nil
+	case float64:
+		return int64(math.Floor(v)), false, nil
+	case time.Time:
+		return v.UnixNano(), true, nil
+	case []byte:
+		return position(string(v))
+	case string:
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n, false, nil
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return int64(math.Floor(f)), false, nil
+		}
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05.999999999", "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.UnixNano(), true, nil
+			}
+		}
+	}
+	return 0, false, errors.Errorf("value %v of type %T is neither a number nor a time", value, value)
+}
+
+// boundsFn queries the range of the values of the partitioning column.
+type boundsFn struct {
+	Driver string `json:"driver"`
+	Dsn    string `json:"dsn"`
+	From   string `json:"from"`
+	Column string `json:"column"`
+}
+
+func (f *boundsFn) ProcessElement(ctx context.Context, _ []byte, emit func(bounds)) error {
+	db, err := sql.Open(f.Driver, f.Dsn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open database: %v", f.Driver)
+	}
+	defer db.Close()
+	q := f.query()
+	var lo, hi any
+	if err := db.QueryRowContext(ctx, q).Scan(&lo, &hi); err != nil {
+		return errors.Wrapf(err, "failed to query bounds: %v", q)
+	}
+	if lo == nil || hi == nil {
+		return nil // No rows.
+	}
+	start, isTime, err := position(lo)
+	if err != nil {
+		return errors.WithContextf(err, "lowest value of %v", f.Column)
+	}
+	end, _, err := position(hi)
+	if err != nil {
+		return errors.WithContextf(err, "highest value of %v", f.Column)
+	}
+	if end == math.MaxInt64 {
+		return errors.Errorf("highest value of %v is out of range", f.Column)
+	}
+	emit(bounds{Start: start, End: end + 1, Time: isTime})
+	return nil
+}
+
+// query returns the query of the bounds.
+func (f *boundsFn) query() string {
+	column := dialectOf(f.Driver).quote(f.Column)
+	return fmt.Sprintf("SELECT MIN(%v), MAX(%v) FROM %v", column, column, f.From)
+}
+
+// partitionFn reads the rows whose partitioning column is in a range of its
+// values, with an offset range restriction over the values.
+type partitionFn struct {
+	Driver     string           `json:"driver"`
+	Dsn        string           `json:"dsn"`
+	From       string           `json:"from"`
+	Column     string           `json:"column"`
+	Partitions int              `json:"partitions"`
+	Type       beam.EncodedType `json:"type"`
+}
+
+// CreateInitialRestriction creates a restriction over the range of the values.
+func (f *partitionFn) CreateInitialRestriction(b bounds) offsetrange.Restriction {
+	return offsetrange.Restriction{Start: b.Start, End: b.End}
+}
+
+// SplitRestriction splits the range into the requested number of partitions.
+func (f *partitionFn) SplitRestriction(_ bounds, rest offsetrange.Restriction) []offsetrange.Restriction {
+	return rest.EvenSplits(int64(f.Partitions))
+}
+
+// RestrictionSize returns the size of the range of a restriction.
+func (f *partitionFn) RestrictionSize(_ bounds, rest offsetrange.Restriction) float64 {
+	return rest.Size()
+}
+
+// CreateTracker creates sdf.LockRTrackers wrapping offsetrange.Trackers for
+// each restriction.
+func (f *partitionFn) CreateTracker(rest offsetrange.Restriction) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(offsetrange.NewTracker(rest))
+}
+
+// ProcessElement queries the rows of the range of a restriction in the order
+// of the partitioning column, and claims each value before emitting its rows,
+// so that the rest of the range can be split off while it's read.
+func (f *partitionFn) ProcessElement(ctx context.Context, rt *sdf.LockRTracker, b bounds, emit func(beam.X)) error {
+	rest := rt.GetRestriction().(offsetrange.Restriction)
+	q := f.query()
+	var lo, hi any = rest.Start, rest.End
+	if b.Time {
+		lo, hi = time.Unix(0, rest.Start).UTC(), time.Unix(0, rest.End).UTC()
+	}
+
+	db, err := sql.Open(f.Driver, f.Dsn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open database: %v", f.Driver)
+	}
+	defer db.Close()
+	rows, err := db.QueryContext(ctx, q, lo, hi)
+	if err != nil {
+		return errors.Wrapf(err, "failed to run query: %v", q)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	index := columnIndex(columns, f.Column)
+	if index < 0 {
+		return errors.Errorf("partitioning column %v is not one of the columns %v", f.Column, columns)
+	}
+
+	claimed := int64(math.MinInt64)
+	stopped := false
+	err = scanRows(rows, f.Type.T, func(row any, values []any) error {
+		pos, _, err := position(values[index])
+		if err != nil {
+			return errors.WithContextf(err, "value of %v", f.Column)
+		}
+		if pos != claimed {
+			if !rt.TryClaim(pos) {
+				stopped = true
+				return errStopped
+			}
+			claimed = pos
+		}
+		emit(row)
+		return nil
+	})
+	if err != nil && err != errStopped {
+		return errors.WithContextf(err, "reading %v", q)
+	}
+	if !stopped {
+		rt.TryClaim(rest.End)
+	}
+	return nil
+}
+
+// query returns the query of the rows of a range of values, whose bounds are
+// its parameters.
+func (f *partitionFn) query() string {
+	d := dialectOf(f.Driver)
+	column := d.quote(f.Column)
+	return fmt.Sprintf("SELECT * FROM %v WHERE %v >= %v AND %v < %v ORDER BY %v",
+		f.From, column, d.placeholder(1), column, d.placeholder(2), column)
+}
+
+// errStopped stops scanning rows once the restriction tracker doesn't allow
+// claiming any more of them.
+var errStopped = errors.New("stopped")
+
+// columnIndex returns the index of a column by its case-insensitive name, or
+// -1 if there is no such column.
+func columnIndex(columns []string, column string) int {
+	for i, c := range columns {
+		if strings.EqualFold(c, column) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ReadKeyset reads all rows from the given table page by page, ordered by a
+// unique key column, where each page is queried with the last key of the
+// previous page, as in
+//
+//	SELECT * FROM table WHERE key > ? ORDER BY key LIMIT pageSize
+//
+// Unlike a single query, this doesn't hold a long-running query or cursor
+// open, and unlike paging by offset, each page is found by the index of the
+// key. The table must have a schema compatible with the given type, t, and
+// ReadKeyset returns a PCollection<t>. The table and the key column are quoted
+// as in ReadPartitioned.
+func ReadKeyset(s beam.Scope, driver, dsn, table string, t reflect.Type, key string, pageSize int) beam.PCollection {
+	s = s.Scope(driver + ".ReadKeyset")
+	if pageSize <= 0 {
+		panic(fmt.Sprintf("databaseio: page size must be positive, got %v", pageSize))
+	}
+	imp := beam.Impulse(s)
+	return beam.ParDo(s, &keysetFn{
+		Driver:   driver,
+		Dsn:      dsn,
+		Table:    table,
+		Key:      key,
+		PageSize: pageSize,
+		Type:     beam.EncodedType{T: t},
+	}, imp, beam.TypeDefinition{Var: beam.XType, T: t})
+}
+
+type keysetFn struct {
+	Driver   string           `json:"driver"`
+	Dsn      string           `json:"dsn"`
+	Table    string           `json:"table"`
+	Key      string           `json:"key"`
+	PageSize int              `json:"pageSize"`
+	Type     beam.EncodedType `json:"type"`
+}
+
+func (f *keysetFn) ProcessElement(ctx context.Context, _ []byte, emit func(beam.X)) error {
+	db, err := sql.Open(f.Driver, f.Dsn)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open database: %v", f.Driver)
+	}
+	defer db.Close()
+
+	first, next := f.queries()
+	var last any
+	for page := 0; ; page++ {
+		var rows *sql.Rows
+		if page == 0 {
+			rows, err = db.QueryContext(ctx, first)
+		} else {
+			rows, err = db.QueryContext(ctx, next, last)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to query page %v of %v", page, f.Table)
+		}
+		n, err := f.readPage(rows, &last, emit)
+		rows.Close()
+		if err != nil {
+			return errors.WithContextf(err, "reading page %v of %v", page, f.Table)
+		}
+		if n < f.PageSize {
+			return nil
+		}
+	}
+}
+
+// queries returns the query of the first page, and the query of the pages
+// after a key, which is its parameter.
+func (f *keysetFn) queries() (first, next string) {
+	d := dialectOf(f.Driver)
+	table, key := d.quoteName(f.Table), d.quote(f.Key)
+	first = fmt.Sprintf("SELECT * FROM %v ORDER BY %v LIMIT %d", table, key, f.PageSize)
+	next = fmt.Sprintf("SELECT * FROM %v WHERE %v > %v ORDER BY %v LIMIT %d", table, key, d.placeholder(1), key, f.PageSize)
+	return first, next
+}
+
+// readPage emits the rows of a page, and sets last to the key of its last
+// row.
+func (f *keysetFn) readPage(rows *sql.Rows, last *any, emit func(beam.X)) (int, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	index := columnIndex(columns, f.Key)
+	if index < 0 {
+		return 0, errors.Errorf("key column %v is not one of the columns %v", f.Key, columns)
+	}
+	n := 0
+	err = scanRows(rows, f.Type.T, func(row any, values []any) error {
+		if values[index] == nil {
+			return errors.Errorf("key column %v is null", f.Key)
+		}
+		*last = values[index]
+		n++
+		emit(row)
+		return nil
+	})
+	return n, err
+}
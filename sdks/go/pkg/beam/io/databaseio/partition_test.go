@@ -0,0 +1,85 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package databaseio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPosition(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value  any
+		want   int64
+		isTime bool
+	}{
+		{int64(-5), -5, false},
+		{int32(7), 7, false},
+		{uint16(7), 7, false},
+		{2.5, 2, false},
+		{[]byte("42"), 42, false},
+		{"-1.5", -2, false},
+		{ts, ts.UnixNano(), true},
+		{"2024-01-02 03:04:05", ts.UnixNano(), true},
+		{[]byte("2024-01-02T03:04:05Z"), ts.UnixNano(), true},
+	}
+	for _, test := range tests {
+		got, isTime, err := position(test.value)
+		if err != nil {
+			t.Errorf("position(%v) failed: %v", test.value, err)
+			continue
+		}
+		if got != test.want || isTime != test.isTime {
+			t.Errorf("position(%v) = %v, %v, want %v, %v", test.value, got, isTime, test.want, test.isTime)
+		}
+	}
+
+	for _, value := range []any{"abc", true, uint64(1 << 63)} {
+		if _, _, err := position(value); err == nil {
+			t.Errorf("position(%v) succeeded, want error", value)
+		}
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	columns := []string{"id", "Created_At"}
+	if got := columnIndex(columns, "created_at"); got != 1 {
+		t.Errorf("columnIndex(%v, created_at) = %v, want 1", columns, got)
+	}
+	if got := columnIndex(columns, "name"); got != -1 {
+		t.Errorf("columnIndex(%v, name) = %v, want -1", columns, got)
+	}
+}
+
+func TestPartitionQueries(t *testing.T) {
+	bf := &boundsFn{Driver: "postgres", From: `public."Order"`, Column: "createdAt"}
+	if got, want := bf.query(), `SELECT MIN("createdAt"), MAX("createdAt") FROM public."Order"`; got != want {
+		t.Errorf("boundsFn.query() = %v, want %v", got, want)
+	}
+	pf := &partitionFn{Driver: "mysql", From: "`order`", Column: "created at"}
+	if got, want := pf.query(), "SELECT * FROM `order` WHERE `created at` >= ? AND `created at` < ? ORDER BY `created at`"; got != want {
+		t.Errorf("partitionFn.query() = %v, want %v", got, want)
+	}
+	kf := &keysetFn{Driver: "postgres", Table: "public.order", Key: "id", PageSize: 10}
+	first, next := kf.queries()
+	if want := `SELECT * FROM "public"."order" ORDER BY "id" LIMIT 10`; first != want {
+		t.Errorf("keysetFn.queries() first = %v, want %v", first, want)
+	}
+	if want := `SELECT * FROM "public"."order" WHERE "id" > $1 ORDER BY "id" LIMIT 10`; next != want {
+		t.Errorf("keysetFn.queries() next = %v, want %v", next, want)
+	}
+}
@@ -73,3 +73,19 @@ func asMap(keys []string, values []any) map[string]any {
 	}
 	return result
 }
+
+// indirect returns the value that a value points to, through any number of
+// pointers and interfaces.
+func indirect(value any) any {
+	v := reflect.ValueOf(value)
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	return v.Interface()
+}
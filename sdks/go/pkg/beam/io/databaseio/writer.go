@@ -19,8 +19,12 @@ package databaseio
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"math"
+	"reflect"
 	"strings"
+	"time"
 
 	"golang.org/x/net/context"
 
@@ -37,6 +41,13 @@ type writer struct {
 	batchSize              int
 	table                  string
 	sqlTemplate            string
+	sqlSuffix              string
+	upsert                 bool
+	dialect                dialect
+	keyIndexes             []int
+	rowIndexes             map[any]int
+	transactional          bool
+	maxRetries             int
 	valueTemplateGenerator *valueTemplateGenerator
 	binding                []any
 	columnCount            int
@@ -44,36 +55,193 @@ type writer struct {
 	totalCount             int
 }
 
+// add adds a row to the batch. An upserted row replaces the row of the batch
+// with the same key, since a statement can't upsert a key twice in Postgres.
 func (w *writer) add(row []any) error {
-	w.rowCount++
 	w.totalCount++
 	if len(row) != w.columnCount {
 		return errors.Errorf("expected %v row values, but had: %v", w.columnCount, len(row))
 	}
+	if w.keyIndexes != nil {
+		key, err := w.key(row)
+		if err != nil {
+			return err
+		}
+		if i, ok := w.rowIndexes[key]; ok {
+			copy(w.binding[i*w.columnCount:], row)
+			return nil
+		}
+		w.rowIndexes[key] = w.rowCount
+	}
+	w.rowCount++
 	w.binding = append(w.binding, row...)
 	return nil
 }
 
+// rowKey is a comparable key of a row, which links the value of a key column
+// to the key of the remaining key columns.
+type rowKey struct {
+	value any
+	next  any
+}
+
+// key returns the values of the key columns of the row as a comparable key,
+// which is the same for rows whose key columns have the same values once
+// they're passed to the database.
+func (w *writer) key(row []any) (any, error) {
+	var key any
+	for i := len(w.keyIndexes) - 1; i >= 0; i-- {
+		value, err := keyValue(row[w.keyIndexes[i]])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get value of key column %v", w.keyIndexes[i])
+		}
+		key = rowKey{value: value, next: key}
+	}
+	return key, nil
+}
+
+// bytesKey is the key value of a []byte, which is distinct from the key
+// value of a string.
+type bytesKey string
+
+// keyValue returns a comparable value of a column. Pointers are dereferenced,
+// driver.Valuers are replaced by their values, and numbers are converted to
+// int64, uint64 or float64, so that a column has the same value regardless of
+// the Go type that holds it.
+func keyValue(v any) (any, error) {
+	rv := reflect.ValueOf(v)
+	valued := false
+	for {
+		if !rv.IsValid() || rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return nil, nil
+		}
+		if valuer, ok := rv.Interface().(driver.Valuer); ok && !valued {
+			value, err := valuer.Value()
+			if err != nil {
+				return nil, err
+			}
+			rv, valued = reflect.ValueOf(value), true
+			continue
+		}
+		if rv.Kind() != reflect.Pointer {
+			break
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := rv.Uint(); u <= math.MaxInt64 {
+			return int64(u), nil
+		}
+		return rv.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return bytesKey(rv.Bytes()), nil
+		}
+	}
+	if t, ok := rv.Interface().(time.Time); ok {
+		// Times of the same instant are equal in UTC, without monotonic clock readings.
+		return t.UTC(), nil
+	}
+	if rv.Type().Comparable() {
+		return rv.Interface(), nil
+	}
+	return fmt.Sprintf("%T:%#v", rv.Interface(), rv.Interface()), nil
+}
+
 func (w *writer) write(ctx context.Context, db *sql.DB) error {
 	values := w.valueTemplateGenerator.generate(w.rowCount, w.columnCount)
 	if len(values) == 0 {
 		log.Info(ctx, "No value(s) to be written....")
 		return nil
 	}
-	SQL := w.sqlTemplate + values
-	resultSet, err := db.ExecContext(ctx, SQL, w.binding...)
+	SQL := w.sqlTemplate + values + w.sqlSuffix
+	var affected int64
+	var err error
+	if w.transactional {
+		affected, err = w.execInTx(ctx, db, SQL)
+	} else {
+		affected, err = exec(ctx, db, SQL, w.binding)
+	}
 	if err != nil {
 		return err
 	}
-	affected, _ := resultSet.RowsAffected()
-	if int(affected) != w.rowCount {
+	least, most := w.rowCount, w.rowCount
+	if w.upsert {
+		least, most = w.dialect.upsertAffected(w.rowCount, len(w.keyIndexes) < w.columnCount)
+	}
+	if int(affected) < least || int(affected) > most {
 		return errors.Errorf("expected to write: %v, but written: %v", w.rowCount, affected)
 	}
 	w.binding = []any{}
 	w.rowCount = 0
+	clear(w.rowIndexes)
 	return nil
 }
 
+// execer is a *sql.DB or *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func exec(ctx context.Context, db execer, SQL string, binding []any) (int64, error) {
+	resultSet, err := db.ExecContext(ctx, SQL, binding...)
+	if err != nil {
+		return 0, err
+	}
+	affected, _ := resultSet.RowsAffected()
+	return affected, nil
+}
+
+// execInTx executes a statement in a transaction, and retries the transaction
+// with exponential backoff if it fails with a serialization failure.
+func (w *writer) execInTx(ctx context.Context, db *sql.DB, SQL string) (int64, error) {
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		affected, err := w.tryExecInTx(ctx, db, SQL)
+		if err == nil || !isSerializationFailure(err) || attempt >= w.maxRetries {
+			return affected, err
+		}
+		log.Warnf(ctx, "retrying write into %v after serialization failure: %v", w.table, err)
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+func (w *writer) tryExecInTx(ctx context.Context, db *sql.DB, SQL string) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := exec(ctx, tx, SQL, w.binding)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return affected, tx.Commit()
+}
+
+// retryBackoff is the initial backoff of retries of transactions, which
+// doubles with each retry up to maxRetryBackoff.
+var retryBackoff = 100 * time.Millisecond
+
+const maxRetryBackoff = 10 * time.Second
+
 func (w *writer) writeBatchIfNeeded(ctx context.Context, db *sql.DB) error {
 	if w.rowCount >= w.batchSize {
 		return w.write(ctx, db)
@@ -102,6 +270,35 @@ func newWriter(driver string, batchSize int, table string, columns []string) (*w
 	}, nil
 }
 
+// newUpsertWriter creates a writer that updates the rows whose keys exist,
+// rather than inserting them.
+func newUpsertWriter(driver string, batchSize int, table string, columns, keys []string) (*writer, error) {
+	w, err := newWriter(driver, batchSize, table, columns)
+	if err != nil {
+		return nil, err
+	}
+	w.dialect = dialectOf(driver)
+	if w.sqlSuffix, err = w.dialect.upsertClause(columns, keys); err != nil {
+		return nil, errors.WithContextf(err, "upserting into %v with driver %v", table, driver)
+	}
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = w.dialect.quote(column)
+	}
+	w.sqlTemplate = fmt.Sprintf("INSERT INTO %v(%v) VALUES", table, strings.Join(quoted, ","))
+	w.upsert = true
+	for _, key := range keys {
+		for i, column := range columns {
+			if strings.EqualFold(column, key) {
+				w.keyIndexes = append(w.keyIndexes, i)
+				break
+			}
+		}
+	}
+	w.rowIndexes = make(map[any]int)
+	return w, nil
+}
+
 type valueTemplateGenerator struct {
 	driver string
 }
@@ -16,8 +16,13 @@
 package databaseio
 
 import (
+	"database/sql"
 	"fmt"
+	"math"
 	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestValueTemplateGenerator_generate(t *testing.T) {
@@ -71,3 +76,66 @@ func TestValueTemplateGenerator_generate(t *testing.T) {
 		})
 	}
 }
+
+func TestWriter_addUpsert(t *testing.T) {
+	w, err := newUpsertWriter("postgres", 10, "person", []string{"id", "name", "age"}, []string{"ID", "name"})
+	if err != nil {
+		t.Fatalf("newUpsertWriter() failed: %v", err)
+	}
+	name := "b"
+	rows := [][]any{
+		{1, "a", 10},
+		{int64(1), &name, 20},
+		{int32(1), "a", 30},
+		{"1", "a", 40},
+		{sql.NullInt64{Int64: 1, Valid: true}, []byte("a"), 50},
+	}
+	for _, row := range rows {
+		if err := w.add(row); err != nil {
+			t.Fatalf("add(%v) failed: %v", row, err)
+		}
+	}
+
+	if w.rowCount != 4 {
+		t.Errorf("rowCount = %v, want 4", w.rowCount)
+	}
+	want := []any{int32(1), "a", 30, int64(1), &name, 20, "1", "a", 40, sql.NullInt64{Int64: 1, Valid: true}, []byte("a"), 50}
+	if !cmp.Equal(w.binding, want) {
+		t.Errorf("binding = %v, want %v", w.binding, want)
+	}
+	wantSQL := `INSERT INTO person("id","name","age") VALUES`
+	if w.sqlTemplate != wantSQL {
+		t.Errorf("sqlTemplate = %q, want %q", w.sqlTemplate, wantSQL)
+	}
+}
+
+func TestKeyValue(t *testing.T) {
+	one := 1
+	var nilInt *int
+	tests := []struct {
+		value any
+		want  any
+	}{
+		{nil, nil},
+		{nilInt, nil},
+		{int8(1), int64(1)},
+		{&one, int64(1)},
+		{uint(1), int64(1)},
+		{uint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{float32(0.5), 0.5},
+		{sql.NullString{String: "a", Valid: true}, "a"},
+		{sql.NullString{}, nil},
+		{[]byte("a"), bytesKey("a")},
+		{time.Unix(1, 0).In(time.FixedZone("x", 3600)), time.Unix(1, 0).UTC()},
+	}
+	for _, test := range tests {
+		got, err := keyValue(test.value)
+		if err != nil {
+			t.Errorf("keyValue(%v) failed: %v", test.value, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("keyValue(%v) = %#v, want %#v", test.value, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,443 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastoreio
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"cloud.google.com/go/datastore"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+)
+
+// ParseGQL parses a GQL query into a Query. Only the subset of GQL with a
+// direct equivalent in Query is supported:
+//
+//	SELECT * FROM <kind>
+//	  [WHERE <condition> [AND <condition> ...]]
+//	  [ORDER BY <property> [ASC | DESC] [, ...]]
+//	  [LIMIT <integer>]
+//
+// where a condition is one of
+//
+//	<property> <op> <value>
+//	<property> IS NULL
+//	<property> [NOT] IN ARRAY(<value>, ...)
+//	__key__ HAS ANCESTOR <key>
+//
+// with op one of =, !=, <, <=, > and >=. Values are string, integer and
+// floating point literals, TRUE, FALSE, NULL, KEY(<kind>, <id or name>, ...)
+// and DATETIME('<RFC 3339 timestamp>'). Keywords are case insensitive, and
+// names may be quoted with backticks.
+func ParseGQL(gql string) (Query, error) {
+	toks, err := lexGQL(gql)
+	if err != nil {
+		return Query{}, errors.Wrap(err, "invalid GQL")
+	}
+	p := &gqlParser{toks: toks}
+	q, err := p.query()
+	if err != nil {
+		return Query{}, errors.Wrap(err, "invalid GQL")
+	}
+	return q, nil
+}
+
+type gqlTokenKind int
+
+const (
+	gqlEOF gqlTokenKind = iota
+	gqlName
+	gqlQuotedName
+	gqlString
+	gqlNumber
+	gqlSymbol
+)
+
+type gqlToken struct {
+	kind gqlTokenKind
+	text string
+	pos  int
+}
+
+func lexGQL(s string) ([]gqlToken, error) {
+	var toks []gqlToken
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"' || r == '`':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(rs); j++ {
+				if rs[j] == '\\' && j+1 < len(rs) {
+					j++
+					sb.WriteRune(rs[j])
+					continue
+				}
+				if rs[j] == r {
+					// A doubled quote is an escaped quote.
+					if j+1 < len(rs) && rs[j+1] == r {
+						sb.WriteRune(r)
+						j++
+						continue
+					}
+					break
+				}
+				sb.WriteRune(rs[j])
+			}
+			if j >= len(rs) {
+				return nil, errors.Errorf("unterminated quote at offset %d", i)
+			}
+			kind := gqlString
+			if r == '`' {
+				kind = gqlQuotedName
+			}
+			toks = append(toks, gqlToken{kind: kind, text: sb.String(), pos: i})
+			i = j + 1
+		case unicode.IsDigit(r) || ((r == '-' || r == '+' || r == '.') && i+1 < len(rs) && unicode.IsDigit(rs[i+1])):
+			j := i + 1
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.' || rs[j] == 'e' || rs[j] == 'E' ||
+				((rs[j] == '-' || rs[j] == '+') && (rs[j-1] == 'e' || rs[j-1] == 'E'))) {
+				j++
+			}
+			toks = append(toks, gqlToken{kind: gqlNumber, text: string(rs[i:j]), pos: i})
+			i = j
+		case unicode.IsLetter(r) || r == '_' || r == '$':
+			j := i + 1
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_' || rs[j] == '$' || rs[j] == '.') {
+				j++
+			}
+			toks = append(toks, gqlToken{kind: gqlName, text: string(rs[i:j]), pos: i})
+			i = j
+		case r == '!' || r == '<' || r == '>':
+			j := i + 1
+			if j < len(rs) && rs[j] == '=' {
+				j++
+			}
+			if string(rs[i:j]) == "!" {
+				return nil, errors.Errorf("unexpected character %q at offset %d", r, i)
+			}
+			toks = append(toks, gqlToken{kind: gqlSymbol, text: string(rs[i:j]), pos: i})
+			i = j
+		case strings.ContainsRune("=*(),", r):
+			toks = append(toks, gqlToken{kind: gqlSymbol, text: string(r), pos: i})
+			i++
+		default:
+			return nil, errors.Errorf("unexpected character %q at offset %d", r, i)
+		}
+	}
+	return append(toks, gqlToken{kind: gqlEOF, pos: len(rs)}), nil
+}
+
+type gqlParser struct {
+	toks []gqlToken
+	pos  int
+}
+
+func (p *gqlParser) peek() gqlToken {
+	return p.toks[p.pos]
+}
+
+func (p *gqlParser) next() gqlToken {
+	t := p.toks[p.pos]
+	if t.kind != gqlEOF {
+		p.pos++
+	}
+	return t
+}
+
+// backup returns t, the last token consumed by next, to the input.
+func (p *gqlParser) backup(t gqlToken) {
+	if t.kind != gqlEOF {
+		p.pos--
+	}
+}
+
+// keyword consumes the next token if it is the given keyword.
+func (p *gqlParser) keyword(kw string) bool {
+	if t := p.peek(); t.kind == gqlName && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// symbol consumes the next token if it is the given symbol.
+func (p *gqlParser) symbol(sym string) bool {
+	if t := p.peek(); t.kind == gqlSymbol && t.text == sym {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *gqlParser) expectKeyword(kw string) error {
+	if !p.keyword(kw) {
+		return p.unexpected(kw)
+	}
+	return nil
+}
+
+func (p *gqlParser) expectSymbol(sym string) error {
+	if !p.symbol(sym) {
+		return p.unexpected(sym)
+	}
+	return nil
+}
+
+func (p *gqlParser) unexpected(want string) error {
+	t := p.peek()
+	if t.kind == gqlEOF {
+		return errors.Errorf("expected %v at end of query", want)
+	}
+	return errors.Errorf("expected %v at offset %d, got %q", want, t.pos, t.text)
+}
+
+func (p *gqlParser) name() (string, error) {
+	t := p.peek()
+	if t.kind != gqlName && t.kind != gqlQuotedName {
+		return "", p.unexpected("a name")
+	}
+	p.pos++
+	return t.text, nil
+}
+
+func (p *gqlParser) query() (Query, error) {
+	var q Query
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return q, err
+	}
+	if !p.symbol("*") {
+		return q, errors.Errorf("only SELECT * queries are supported")
+	}
+	if err := p.expectKeyword("FROM"); err != nil {
+		return q, err
+	}
+	kind, err := p.name()
+	if err != nil {
+		return q, err
+	}
+	q.Kind = kind
+
+	if p.keyword("WHERE") {
+		for {
+			if err := p.condition(&q); err != nil {
+				return q, err
+			}
+			if !p.keyword("AND") {
+				break
+			}
+		}
+	}
+	if p.keyword("ORDER") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return q, err
+		}
+		for {
+			prop, err := p.name()
+			if err != nil {
+				return q, err
+			}
+			if p.keyword("DESC") {
+				prop = "-" + prop
+			} else {
+				p.keyword("ASC")
+			}
+			q.Orders = append(q.Orders, prop)
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+	if p.keyword("LIMIT") {
+		t := p.next()
+		n, err := strconv.Atoi(t.text)
+		if t.kind != gqlNumber || err != nil || n <= 0 {
+			return q, errors.Errorf("LIMIT must be a positive integer, got %q", t.text)
+		}
+		q.Limit = n
+	}
+	if t := p.peek(); t.kind != gqlEOF {
+		return q, errors.Errorf("unexpected %q at offset %d", t.text, t.pos)
+	}
+	return q, nil
+}
+
+func (p *gqlParser) condition(q *Query) error {
+	prop, err := p.name()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case p.keyword("HAS"):
+		if err := p.expectKeyword("ANCESTOR"); err != nil {
+			return err
+		}
+		if prop != "__key__" {
+			return errors.Errorf("HAS ANCESTOR requires __key__, got %v", prop)
+		}
+		v, err := p.value()
+		if err != nil {
+			return err
+		}
+		k, ok := v.(*datastore.Key)
+		if !ok {
+			return errors.Errorf("HAS ANCESTOR requires a key, got %v", v)
+		}
+		q.Ancestor = k
+		return nil
+	case p.keyword("IS"):
+		if err := p.expectKeyword("NULL"); err != nil {
+			return err
+		}
+		q.Filters = append(q.Filters, Filter{Property: prop, Operator: "="})
+		return nil
+	case p.keyword("IN"):
+		vs, err := p.array()
+		if err != nil {
+			return err
+		}
+		q.Filters = append(q.Filters, Filter{Property: prop, Operator: "in", Value: vs})
+		return nil
+	case p.keyword("NOT"):
+		if err := p.expectKeyword("IN"); err != nil {
+			return err
+		}
+		vs, err := p.array()
+		if err != nil {
+			return err
+		}
+		q.Filters = append(q.Filters, Filter{Property: prop, Operator: "not-in", Value: vs})
+		return nil
+	}
+
+	t := p.next()
+	op, ok := normalizeOperator(t.text)
+	if t.kind != gqlSymbol || !ok {
+		p.backup(t)
+		return p.unexpected("an operator")
+	}
+	v, err := p.value()
+	if err != nil {
+		return err
+	}
+	q.Filters = append(q.Filters, Filter{Property: prop, Operator: op, Value: v})
+	return nil
+}
+
+func (p *gqlParser) array() ([]any, error) {
+	if err := p.expectKeyword("ARRAY"); err != nil {
+		return nil, err
+	}
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	var vs []any
+	for {
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		vs = append(vs, v)
+		if !p.symbol(",") {
+			break
+		}
+	}
+	return vs, p.expectSymbol(")")
+}
+
+func (p *gqlParser) value() (any, error) {
+	t := p.next()
+	switch t.kind {
+	case gqlString:
+		return t.text, nil
+	case gqlNumber:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid number %q at offset %d", t.text, t.pos)
+		}
+		return f, nil
+	case gqlName:
+		switch strings.ToUpper(t.text) {
+		case "TRUE":
+			return true, nil
+		case "FALSE":
+			return false, nil
+		case "NULL":
+			return nil, nil
+		case "KEY":
+			return p.key()
+		case "DATETIME":
+			if err := p.expectSymbol("("); err != nil {
+				return nil, err
+			}
+			s := p.next()
+			if s.kind != gqlString {
+				return nil, errors.Errorf("DATETIME requires a string at offset %d", s.pos)
+			}
+			ts, err := time.Parse(time.RFC3339Nano, s.text)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid DATETIME at offset %d", s.pos)
+			}
+			return ts, p.expectSymbol(")")
+		}
+	}
+	p.backup(t)
+	return nil, p.unexpected("a value")
+}
+
+// key parses the arguments of a KEY literal: pairs of kinds and IDs or names
+// from the root of the key path.
+func (p *gqlParser) key() (*datastore.Key, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	var k *datastore.Key
+	for {
+		kind, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectSymbol(","); err != nil {
+			return nil, err
+		}
+		switch t := p.next(); t.kind {
+		case gqlString:
+			k = datastore.NameKey(kind, t.text, k)
+		case gqlNumber:
+			id, err := strconv.ParseInt(t.text, 10, 64)
+			if err != nil {
+				return nil, errors.Errorf("invalid key ID %q at offset %d", t.text, t.pos)
+			}
+			k = datastore.IDKey(kind, id, k)
+		default:
+			p.backup(t)
+			return nil, p.unexpected("a key ID or name")
+		}
+		if !p.symbol(",") {
+			break
+		}
+	}
+	return k, p.expectSymbol(")")
+}
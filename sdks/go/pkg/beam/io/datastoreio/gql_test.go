@@ -0,0 +1,97 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastoreio
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseGQL(t *testing.T) {
+	tests := []struct {
+		gql  string
+		want Query
+	}{
+		{
+			gql:  "SELECT * FROM Item",
+			want: Query{Kind: "Item"},
+		},
+		{
+			gql:  "select * from `My Kind` where `a b` = 'it''s' and Count >= -3 AND Price < 2.5e1",
+			want: Query{Kind: "My Kind", Filters: []Filter{{"a b", "=", "it's"}, {"Count", ">=", int64(-3)}, {"Price", "<", 25.0}}},
+		},
+		{
+			gql: "SELECT * FROM Item WHERE Done = TRUE AND Deleted IS NULL AND Owner != NULL AND Tag IN ARRAY('a', \"b\") AND Size NOT IN ARRAY(1)",
+			want: Query{Kind: "Item", Filters: []Filter{
+				{"Done", "=", true},
+				{"Deleted", "=", nil},
+				{"Owner", "!=", nil},
+				{"Tag", "in", []any{"a", "b"}},
+				{"Size", "not-in", []any{int64(1)}},
+			}},
+		},
+		{
+			gql: "SELECT * FROM Item WHERE __key__ HAS ANCESTOR KEY(Parent, 'p') AND Ref = KEY(Parent, 'p', Item, 12) AND Created > DATETIME('2024-03-01T12:30:00Z')",
+			want: Query{
+				Kind:     "Item",
+				Ancestor: datastore.NameKey("Parent", "p", nil),
+				Filters: []Filter{
+					{"Ref", "=", datastore.IDKey("Item", 12, datastore.NameKey("Parent", "p", nil))},
+					{"Created", ">", time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)},
+				},
+			},
+		},
+		{
+			gql:  "SELECT * FROM Item ORDER BY Count DESC, Name ASC, Price LIMIT 10",
+			want: Query{Kind: "Item", Orders: []string{"-Count", "Name", "Price"}, Limit: 10},
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseGQL(tt.gql)
+		if err != nil {
+			t.Errorf("ParseGQL(%q) failed: %v", tt.gql, err)
+			continue
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("ParseGQL(%q) mismatch (-want +got):\n%s", tt.gql, diff)
+		}
+	}
+}
+
+func TestParseGQL_Bad(t *testing.T) {
+	for _, gql := range []string{
+		"",
+		"SELECT Name FROM Item",
+		"SELECT * FROM",
+		"SELECT * FROM Item WHERE",
+		"SELECT * FROM Item WHERE Count",
+		"SELECT * FROM Item WHERE Count ~ 1",
+		"SELECT * FROM Item WHERE Count = ",
+		"SELECT * FROM Item WHERE Name = 'unterminated",
+		"SELECT * FROM Item WHERE Name HAS ANCESTOR KEY(Parent, 'p')",
+		"SELECT * FROM Item WHERE Created > DATETIME('yesterday')",
+		"SELECT * FROM Item WHERE Tag IN ('a')",
+		"SELECT * FROM Item LIMIT 0",
+		"SELECT * FROM Item LIMIT 10 OFFSET 5",
+	} {
+		if q, err := ParseGQL(gql); err == nil {
+			t.Errorf("ParseGQL(%q) = %+v, want error", gql, q)
+		}
+	}
+}
@@ -0,0 +1,418 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastoreio
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	"google.golang.org/api/iterator"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*splitReadQueryFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*readQueryFn)(nil)).Elem())
+}
+
+// Query is a serializable description of a datastore query. It can be built
+// directly or parsed from GQL with ParseGQL.
+type Query struct {
+	// Kind is the entity kind to query.
+	Kind string `json:"kind"`
+	// Namespace is the namespace to query. The default namespace is used if
+	// empty.
+	Namespace string `json:"namespace,omitempty"`
+	// Ancestor restricts the results to descendants of the given key.
+	Ancestor *datastore.Key `json:"ancestor,omitempty"`
+	// Filters are the property filters, all of which must match.
+	Filters []Filter `json:"filters,omitempty"`
+	// Orders are the property names to sort by. A name prefixed with "-" sorts
+	// in descending order.
+	Orders []string `json:"orders,omitempty"`
+	// Limit is the maximum number of results. Zero means no limit.
+	Limit int `json:"limit,omitempty"`
+}
+
+// Filter is a single property filter of a Query. Operator is one of "=",
+// "!=", "<", "<=", ">", ">=", "in" and "not-in". Value must be an integer,
+// float, string, bool, nil, *datastore.Key or time.Time, or for the "in" and
+// "not-in" operators, a []any of those.
+type Filter struct {
+	Property string
+	Operator string
+	Value    any
+}
+
+// validate checks that the query can be translated and serialized.
+func (q Query) validate() error {
+	if q.Kind == "" {
+		return errors.New("query has no kind")
+	}
+	for _, f := range q.Filters {
+		if _, ok := normalizeOperator(f.Operator); !ok {
+			return errors.Errorf("filter on %v has invalid operator %q", f.Property, f.Operator)
+		}
+		if _, err := encodeFilterValue(f.Value); err != nil {
+			return errors.Wrapf(err, "invalid value for filter on %v", f.Property)
+		}
+	}
+	return nil
+}
+
+// splittable reports whether the query can be split into key ranges. Sort
+// orders, limits and any filter other than equality prevent splitting, since
+// the results of the shards could not be combined into the same result.
+func (q Query) splittable() bool {
+	if q.Limit > 0 || len(q.Orders) > 0 {
+		return false
+	}
+	for _, f := range q.Filters {
+		if op, _ := normalizeOperator(f.Operator); op != "=" {
+			return false
+		}
+	}
+	return true
+}
+
+// datastoreQuery translates q to a datastore query, restricted to the keys in
+// the range [start, end). Nil bounds are open.
+func (q Query) datastoreQuery(start, end *datastore.Key) *datastore.Query {
+	dq := datastore.NewQuery(q.Kind).Namespace(q.Namespace)
+	if q.Ancestor != nil {
+		dq = dq.Ancestor(q.Ancestor)
+	}
+	for _, f := range q.Filters {
+		op, _ := normalizeOperator(f.Operator)
+		dq = dq.FilterField(f.Property, op, f.Value)
+	}
+	if start != nil {
+		dq = dq.FilterField("__key__", ">=", start)
+	}
+	if end != nil {
+		dq = dq.FilterField("__key__", "<", end)
+	}
+	for _, o := range q.Orders {
+		dq = dq.Order(o)
+	}
+	if q.Limit > 0 {
+		dq = dq.Limit(q.Limit)
+	}
+	return dq
+}
+
+// filterValue is the JSON encoding of a filter value, which keeps the type of
+// the value so that integers, keys and timestamps survive serialization.
+type filterValue struct {
+	Type   string          `json:"type"`
+	Value  json.RawMessage `json:"value,omitempty"`
+	Values []filterValue   `json:"values,omitempty"`
+}
+
+type filterJSON struct {
+	Property string      `json:"property"`
+	Operator string      `json:"operator"`
+	Value    filterValue `json:"value"`
+}
+
+// MarshalJSON encodes the filter, including the type of its value.
+func (f Filter) MarshalJSON() ([]byte, error) {
+	v, err := encodeFilterValue(f.Value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid value for filter on %v", f.Property)
+	}
+	return json.Marshal(filterJSON{Property: f.Property, Operator: f.Operator, Value: v})
+}
+
+// UnmarshalJSON decodes a filter encoded by MarshalJSON.
+func (f *Filter) UnmarshalJSON(b []byte) error {
+	var fj filterJSON
+	if err := json.Unmarshal(b, &fj); err != nil {
+		return err
+	}
+	v, err := decodeFilterValue(fj.Value)
+	if err != nil {
+		return errors.Wrapf(err, "invalid value for filter on %v", fj.Property)
+	}
+	*f = Filter{Property: fj.Property, Operator: fj.Operator, Value: v}
+	return nil
+}
+
+func encodeFilterValue(v any) (filterValue, error) {
+	raw := func(typ string, v any) (filterValue, error) {
+		b, err := json.Marshal(v)
+		return filterValue{Type: typ, Value: b}, err
+	}
+	switch v := v.(type) {
+	case nil:
+		return filterValue{Type: "null"}, nil
+	case int, int8, int16, int32, int64:
+		return raw("int", strconv.FormatInt(reflect.ValueOf(v).Int(), 10))
+	case float32, float64:
+		return raw("float", reflect.ValueOf(v).Float())
+	case string:
+		return raw("string", v)
+	case bool:
+		return raw("bool", v)
+	case *datastore.Key:
+		if v == nil {
+			return filterValue{Type: "null"}, nil
+		}
+		return raw("key", v.Encode())
+	case time.Time:
+		return raw("time", v.Format(time.RFC3339Nano))
+	case []any:
+		fv := filterValue{Type: "array", Values: make([]filterValue, len(v))}
+		for i, e := range v {
+			ev, err := encodeFilterValue(e)
+			if err != nil {
+				return filterValue{}, err
+			}
+			fv.Values[i] = ev
+		}
+		return fv, nil
+	default:
+		return filterValue{}, errors.Errorf("unsupported type %T", v)
+	}
+}
+
+func decodeFilterValue(fv filterValue) (any, error) {
+	var s string
+	switch fv.Type {
+	case "null":
+		return nil, nil
+	case "float":
+		var f float64
+		err := json.Unmarshal(fv.Value, &f)
+		return f, err
+	case "bool":
+		var b bool
+		err := json.Unmarshal(fv.Value, &b)
+		return b, err
+	case "array":
+		vs := make([]any, len(fv.Values))
+		for i, e := range fv.Values {
+			v, err := decodeFilterValue(e)
+			if err != nil {
+				return nil, err
+			}
+			vs[i] = v
+		}
+		return vs, nil
+	case "int", "string", "key", "time":
+		if err := json.Unmarshal(fv.Value, &s); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("unknown value type %q", fv.Type)
+	}
+	switch fv.Type {
+	case "int":
+		return strconv.ParseInt(s, 10, 64)
+	case "key":
+		return datastore.DecodeKey(s)
+	case "time":
+		return time.Parse(time.RFC3339Nano, s)
+	}
+	return s, nil
+}
+
+// ReadQuery reads the results of the given query. The entities must have a
+// schema compatible with the given type, t, and ReadQuery returns a
+// PCollection<t>. As with Read, the type must be registered with
+// runtime.RegisterType, and typeKey is its registered key.
+//
+// ReadQuery panics if the query is invalid.
+//
+// Queries without sort orders, limits or filters other than equality are split
+// into up to the given number of shards on ranges of keys, using the
+// __scatter__ property of the kind. Other queries are read in a single shard.
+//
+// Example:
+//
+//	q, err := datastoreio.ParseGQL("SELECT * FROM Item WHERE Category = 'tools'")
+//	if err != nil {
+//		return err
+//	}
+//	datastoreio.ReadQuery(s, "project", q, 256, reflect.TypeOf(Item{}), itemKey)
+func ReadQuery(s beam.Scope, project string, q Query, shards int, t reflect.Type, typeKey string) beam.PCollection {
+	s = s.Scope("datastore.ReadQuery")
+	if err := q.validate(); err != nil {
+		panic(errors.Wrap(err, "datastoreio.ReadQuery: invalid query"))
+	}
+	return readQuery(s, project, q, shards, t, typeKey, nil)
+}
+
+func readQuery(s beam.Scope, project string, q Query, shards int, t reflect.Type, typeKey string, newClient newClientFuncType) beam.PCollection {
+	b, err := json.Marshal(q)
+	if err != nil {
+		panic(errors.Wrap(err, "datastoreio.ReadQuery: failed to encode query"))
+	}
+	imp := beam.Impulse(s)
+	ex := beam.ParDo(s, &splitReadQueryFn{Project: project, Query: string(b), Shards: shards, newClientFunc: newClient}, imp)
+	g := beam.GroupByKey(s, ex)
+	return beam.ParDo(s, &readQueryFn{Project: project, Query: string(b), Type: typeKey, newClientFunc: newClient}, g, beam.TypeDefinition{Var: beam.XType, T: t})
+}
+
+// splitReadQueryFn splits a query into queries on ranges of keys, emitted as
+// BoundedQuery values in JSON.
+type splitReadQueryFn struct {
+	Project string `json:"project"`
+	// Query is the JSON encoded Query. Keys are recursive, so a Query cannot
+	// be a field of a registered type.
+	Query         string `json:"query"`
+	Shards        int    `json:"shards"`
+	newClientFunc newClientFuncType
+	query         Query
+}
+
+func (s *splitReadQueryFn) Setup() error {
+	if s.newClientFunc == nil {
+		s.newClientFunc = datastoreNewClient
+	}
+	return json.Unmarshal([]byte(s.Query), &s.query)
+}
+
+func (s *splitReadQueryFn) ProcessElement(ctx context.Context, _ []byte, emit func(k string, val string)) error {
+	if s.Shards <= 1 || !s.query.splittable() {
+		if s.Shards > 1 {
+			log.Infof(ctx, "Datastore: query on kind %v cannot be split, reading it in a single shard", s.query.Kind)
+		}
+		b, err := json.Marshal(BoundedQuery{})
+		if err != nil {
+			return err
+		}
+		emit(strconv.Itoa(0), string(b))
+		return nil
+	}
+
+	client, err := s.newClientFunc(ctx, s.Project)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	scatter := datastore.NewQuery(s.query.Kind).
+		Namespace(s.query.Namespace).
+		Order(scatterPropertyName).
+		Limit((s.Shards - 1) * 32).
+		KeysOnly()
+	var keys []*datastore.Key
+	iter := client.Run(ctx, scatter)
+	for {
+		k, err := iter.Next(nil)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to read scatter keys of kind %v", s.query.Kind)
+		}
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keyLessThan(keys[i], keys[j])
+	})
+
+	var start *datastore.Key
+	var queries []BoundedQuery
+	for _, k := range getSplits(keys, s.Shards-1) {
+		queries = append(queries, BoundedQuery{Start: start, End: k})
+		start = k
+	}
+	queries = append(queries, BoundedQuery{Start: start})
+
+	log.Debugf(ctx, "Datastore: Splitting query on kind %v into %d shards", s.query.Kind, len(queries))
+	for n, q := range queries {
+		b, err := json.Marshal(q)
+		if err != nil {
+			return err
+		}
+		emit(strconv.Itoa(n), string(b))
+	}
+	return nil
+}
+
+// readQueryFn runs the query on each of the key ranges of a shard.
+type readQueryFn struct {
+	Project string `json:"project"`
+	// Query is the JSON encoded Query.
+	Query string `json:"query"`
+	// Type is the name of the global schema type
+	Type          string `json:"type"`
+	newClientFunc newClientFuncType
+	query         Query
+}
+
+func (s *readQueryFn) Setup() error {
+	if s.newClientFunc == nil {
+		s.newClientFunc = datastoreNewClient
+	}
+	return json.Unmarshal([]byte(s.Query), &s.query)
+}
+
+func (s *readQueryFn) ProcessElement(ctx context.Context, _ string, v func(*string) bool, emit func(beam.X)) error {
+	t, ok := runtime.LookupType(s.Type)
+	if !ok {
+		return errors.Errorf("No type registered %s", s.Type)
+	}
+
+	client, err := s.newClientFunc(ctx, s.Project)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var b string
+	for v(&b) {
+		var q BoundedQuery
+		if err := json.Unmarshal([]byte(b), &q); err != nil {
+			return err
+		}
+		iter := client.Run(ctx, s.query.datastoreQuery(q.Start, q.End))
+		for {
+			val := reflect.New(t).Interface() // val : *T
+			if _, err := iter.Next(val); err != nil {
+				if err == iterator.Done {
+					break
+				}
+				return errors.Wrapf(err, "failed to read query results of kind %v", s.query.Kind)
+			}
+			emit(reflect.ValueOf(val).Elem().Interface()) // emit(*val)
+		}
+	}
+	return nil
+}
+
+// normalizeOperator maps GQL and datastore operator spellings to the
+// operators accepted by datastore.Query.FilterField.
+func normalizeOperator(op string) (string, bool) {
+	switch strings.ToLower(op) {
+	case "=", "!=", "<", "<=", ">", ">=", "in", "not-in":
+		return strings.ToLower(op), true
+	case "not in":
+		return "not-in", true
+	}
+	return "", false
+}
@@ -0,0 +1,171 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastoreio
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/option"
+)
+
+func Test_readQuery(t *testing.T) {
+	testCases := []struct {
+		name      string
+		q         Query
+		shards    int
+		expectRun int
+	}{
+		{
+			name:      "single shard",
+			q:         Query{Kind: "Item"},
+			shards:    1,
+			expectRun: 1,
+		},
+		{
+			name:      "split query",
+			q:         Query{Kind: "Item", Filters: []Filter{{Property: "Count", Operator: "=", Value: 1}}},
+			shards:    4,
+			expectRun: 2,
+		},
+		{
+			name:      "unsplittable query",
+			q:         Query{Kind: "Item", Orders: []string{"-Count"}},
+			shards:    4,
+			expectRun: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := fakeClient{}
+			newClient := func(ctx context.Context, projectID string, opts ...option.ClientOption) (clientType, error) {
+				return &client, nil
+			}
+
+			itemType := reflect.TypeOf(Foo{})
+			itemKey, _ := runtime.TypeKey(itemType)
+
+			p, s := beam.NewPipelineWithRoot()
+			readQuery(s, "project", tc.q, tc.shards, itemType, itemKey, newClient)
+			ptest.RunAndValidate(t, p)
+
+			if got, want := client.runCounter, tc.expectRun; got != want {
+				t.Errorf("got number of datastore.Client.Run call: %v, wanted %v", got, want)
+			}
+			if got, want := client.closeCounter, tc.expectRun; got != want {
+				t.Errorf("got number of datastore.Client.Close call: %v, wanted %v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadQuery_Invalid(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("ReadQuery() with an invalid query succeeded, want panic")
+		}
+	}()
+	_, s := beam.NewPipelineWithRoot()
+	q := Query{Kind: "Item", Filters: []Filter{{Property: "Count", Operator: "~", Value: 1}}}
+	ReadQuery(s, "project", q, 1, reflect.TypeOf(Foo{}), "Foo")
+}
+
+func TestQuery_splittable(t *testing.T) {
+	tests := []struct {
+		name string
+		q    Query
+		want bool
+	}{
+		{"kind", Query{Kind: "Item"}, true},
+		{"equality", Query{Kind: "Item", Filters: []Filter{{"Name", "=", "a"}}}, true},
+		{"ancestor", Query{Kind: "Item", Ancestor: datastore.NameKey("Parent", "p", nil)}, true},
+		{"inequality", Query{Kind: "Item", Filters: []Filter{{"Count", ">", 1}}}, false},
+		{"in", Query{Kind: "Item", Filters: []Filter{{"Count", "in", []any{1, 2}}}}, false},
+		{"order", Query{Kind: "Item", Orders: []string{"Count"}}, false},
+		{"limit", Query{Kind: "Item", Limit: 10}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.q.splittable(); got != tt.want {
+			t.Errorf("%v: splittable() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQuery_datastoreQuery(t *testing.T) {
+	parent := datastore.NameKey("Parent", "p", nil)
+	start, end := datastore.NameKey("Item", "a", nil), datastore.NameKey("Item", "m", nil)
+	q := Query{
+		Kind:      "Item",
+		Namespace: "ns",
+		Ancestor:  parent,
+		Filters:   []Filter{{"Count", ">=", int64(3)}, {"Name", "not in", []any{"x"}}},
+		Orders:    []string{"-Count"},
+		Limit:     5,
+	}
+
+	got := q.datastoreQuery(start, end)
+	want := datastore.NewQuery("Item").Namespace("ns").Ancestor(parent).
+		FilterField("Count", ">=", int64(3)).
+		FilterField("Name", "not-in", []any{"x"}).
+		FilterField("__key__", ">=", start).
+		FilterField("__key__", "<", end).
+		Order("-Count").
+		Limit(5)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("datastoreQuery() = %+v, want %+v", got, want)
+	}
+}
+
+func TestFilter_JSON(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 30, 0, 5, time.UTC)
+	q := Query{
+		Kind: "Item",
+		Filters: []Filter{
+			{"Int", "=", int64(1 << 60)},
+			{"Float", "<", 2.5},
+			{"String", "=", "s"},
+			{"Bool", "=", true},
+			{"Null", "=", nil},
+			{"Key", "=", datastore.IDKey("Item", 7, datastore.NameKey("Parent", "p", nil))},
+			{"Time", ">", ts},
+			{"Array", "in", []any{int64(1), "two", nil}},
+		},
+	}
+
+	b, err := json.Marshal(q)
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %v", err)
+	}
+	var got Query
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("json.Unmarshal() failed: %v", err)
+	}
+	if diff := cmp.Diff(q, got); diff != "" {
+		t.Errorf("round trip mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := json.Marshal(Filter{"Bad", "=", struct{}{}}); err == nil {
+		t.Error("json.Marshal() of an unsupported value succeeded, want error")
+	}
+}
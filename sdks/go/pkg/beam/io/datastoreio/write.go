@@ -0,0 +1,377 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastoreio
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxBatchSize is the maximum number of mutations in a single commit.
+	maxBatchSize = 500
+
+	// rampUpBaseBudget is the number of operations per second allowed across
+	// all workers before ramp-up, and rampUpInterval is how often the budget
+	// grows by 50%.
+	rampUpBaseBudget = 500
+	rampUpInterval   = 5 * time.Minute
+
+	defaultHintNumWorkers = 500
+
+	maxCommitAttempts = 5
+	initialBackoff    = time.Second
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*writeFn)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*deleteFn)(nil)).Elem())
+}
+
+type mutateClientType interface {
+	Mutate(ctx context.Context, muts ...*datastore.Mutation) ([]*datastore.Key, error)
+	Close() error
+}
+
+// newMutateClientFuncType is the signature of the function datastore.NewClient
+// for clients that commit mutations.
+type newMutateClientFuncType func(ctx context.Context, projectID string, opts ...option.ClientOption) (mutateClientType, error)
+
+func datastoreNewMutateClient(ctx context.Context, projectID string, opts ...option.ClientOption) (mutateClientType, error) {
+	return datastore.NewClient(ctx, projectID, opts...)
+}
+
+// WriteOption represents options for writing to and deleting from Datastore.
+type WriteOption struct {
+	// BatchSize is the maximum number of mutations committed together.
+	BatchSize int
+	// RampUp enables throttling of new writes, starting at a budget of 500
+	// operations per second shared by all workers and growing by 50% every 5
+	// minutes, as recommended for Datastore.
+	RampUp bool
+	// HintNumWorkers is the expected number of workers, which share the
+	// ramp-up budget.
+	HintNumWorkers int
+}
+
+// WriteOptionFn is a function that configures a WriteOption.
+type WriteOptionFn func(option *WriteOption) error
+
+// WithWriteBatchSize configures the maximum number of mutations committed
+// together. It must be between 1 and 500.
+func WithWriteBatchSize(batchSize int) WriteOptionFn {
+	return func(o *WriteOption) error {
+		if batchSize <= 0 || batchSize > maxBatchSize {
+			return errors.Errorf("batch size must be between 1 and %d, got %d", maxBatchSize, batchSize)
+		}
+		o.BatchSize = batchSize
+		return nil
+	}
+}
+
+// WithRampUp configures whether writes are throttled to ramp up traffic to
+// Datastore. Ramp-up is enabled by default.
+func WithRampUp(enabled bool) WriteOptionFn {
+	return func(o *WriteOption) error {
+		o.RampUp = enabled
+		return nil
+	}
+}
+
+// WithHintNumWorkers configures the expected number of workers, which share
+// the ramp-up budget. Defaults to 500.
+func WithHintNumWorkers(numWorkers int) WriteOptionFn {
+	return func(o *WriteOption) error {
+		if numWorkers <= 0 {
+			return errors.Errorf("number of workers must be greater than 0, got %d", numWorkers)
+		}
+		o.HintNumWorkers = numWorkers
+		return nil
+	}
+}
+
+func newWriteOption(opts []WriteOptionFn) (WriteOption, error) {
+	option := WriteOption{
+		BatchSize:      maxBatchSize,
+		RampUp:         true,
+		HintNumWorkers: defaultHintNumWorkers,
+	}
+	for _, opt := range opts {
+		if err := opt(&option); err != nil {
+			return option, err
+		}
+	}
+	return option, nil
+}
+
+// Write upserts entities to Datastore. The input must be a
+// PCollection<KV<string, T>>, where the key is the encoded complete key of the
+// entity, as returned by datastore.Key.Encode, and T is a struct or a type
+// implementing datastore.PropertyLoadSaver.
+//
+// Mutations are committed in batches of up to 500, and are throttled to
+// ramp up traffic gradually unless disabled with WithRampUp(false). Throttled
+// batches are cut to the ramp-up budget of the worker, which starts at one
+// mutation per second with the default of 500 workers.
+//
+// Example:
+//
+//	keyed := beam.ParDo(s, func(item Item) (string, Item) {
+//		return datastore.NameKey("Item", item.ID, nil).Encode(), item
+//	}, items)
+//	datastoreio.Write(s, "project", keyed)
+func Write(s beam.Scope, project string, col beam.PCollection, opts ...WriteOptionFn) {
+	s = s.Scope("datastore.Write")
+	if !typex.IsKV(col.Type()) || col.Type().Components()[0].Type() != reflectx.String {
+		panic(errors.Errorf("datastoreio.Write: input must be a KV<string, T>, got %v", col.Type()))
+	}
+	option, err := newWriteOption(opts)
+	if err != nil {
+		panic(errors.Wrap(err, "datastoreio.Write: invalid option"))
+	}
+	write(s, project, col, option, nil)
+}
+
+func write(s beam.Scope, project string, col beam.PCollection, option WriteOption, newClient newMutateClientFuncType) {
+	beam.ParDo0(s, &writeFn{mutateFn: mutateFn{Project: project, Option: option, newClientFunc: newClient}}, col)
+}
+
+// Delete deletes entities from Datastore. The input must be a
+// PCollection<string> of encoded complete keys, as returned by
+// datastore.Key.Encode. Deletes are batched and throttled as in Write.
+func Delete(s beam.Scope, project string, keys beam.PCollection, opts ...WriteOptionFn) {
+	s = s.Scope("datastore.Delete")
+	option, err := newWriteOption(opts)
+	if err != nil {
+		panic(errors.Wrap(err, "datastoreio.Delete: invalid option"))
+	}
+	deleteKeys(s, project, keys, option, nil)
+}
+
+func deleteKeys(s beam.Scope, project string, keys beam.PCollection, option WriteOption, newClient newMutateClientFuncType) {
+	beam.ParDo0(s, &deleteFn{mutateFn: mutateFn{Project: project, Option: option, newClientFunc: newClient}}, keys)
+}
+
+// mutateFn batches mutations and commits them, throttled by a rampUpThrottler.
+type mutateFn struct {
+	Project string      `json:"project"`
+	Option  WriteOption `json:"option"`
+
+	newClientFunc newMutateClientFuncType
+	client        mutateClientType
+	throttler     *rampUpThrottler
+	sleep         func(time.Duration)
+	batch         []*datastore.Mutation
+	keys          map[string]bool
+}
+
+func (f *mutateFn) Setup(ctx context.Context) error {
+	if f.newClientFunc == nil {
+		f.newClientFunc = datastoreNewMutateClient
+	}
+	if f.sleep == nil {
+		f.sleep = time.Sleep
+	}
+	if f.Option.RampUp && f.throttler == nil {
+		f.throttler = newRampUpThrottler(f.Option.HintNumWorkers, time.Now, f.sleep)
+	}
+	client, err := f.newClientFunc(ctx, f.Project)
+	if err != nil {
+		return err
+	}
+	f.client = client
+	return nil
+}
+
+func (f *mutateFn) StartBundle() {
+	f.batch = nil
+	f.keys = make(map[string]bool)
+}
+
+// add adds a mutation of the entity with the given key to the batch, and
+// commits the batch when it is full. The batch is committed first if it
+// already contains the key, since a commit may mutate each entity once.
+func (f *mutateFn) add(ctx context.Context, key *datastore.Key, m *datastore.Mutation) error {
+	if key.Incomplete() {
+		return errors.Errorf("cannot mutate entity with incomplete key %v", key)
+	}
+	id := key.String()
+	if f.keys[id] {
+		if err := f.flush(ctx); err != nil {
+			return err
+		}
+	}
+	f.batch = append(f.batch, m)
+	f.keys[id] = true
+	if len(f.batch) >= f.Option.BatchSize {
+		return f.flush(ctx)
+	}
+	return nil
+}
+
+// flush commits the batch. When throttled, the batch is committed in parts
+// that fit in the ramp-up budget.
+func (f *mutateFn) flush(ctx context.Context) error {
+	for len(f.batch) > 0 {
+		n := len(f.batch)
+		if f.throttler != nil {
+			n = f.throttler.acquire(ctx, n)
+		}
+		if err := f.commit(ctx, f.batch[:n]); err != nil {
+			return err
+		}
+		f.batch = f.batch[n:]
+	}
+
+	f.batch = nil
+	f.keys = make(map[string]bool)
+	return nil
+}
+
+// commit commits the mutations, retrying transient errors with exponential
+// backoff.
+func (f *mutateFn) commit(ctx context.Context, muts []*datastore.Mutation) error {
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		_, err := f.client.Mutate(ctx, muts...)
+		if err == nil {
+			return nil
+		}
+		if attempt >= maxCommitAttempts || !isRetryable(err) {
+			return errors.Wrapf(err, "failed to commit %d mutations", len(muts))
+		}
+		log.Warnf(ctx, "Datastore: commit of %d mutations failed, retrying in %v: %v", len(muts), backoff, err)
+		f.sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (f *mutateFn) FinishBundle(ctx context.Context) error {
+	return f.flush(ctx)
+}
+
+func (f *mutateFn) Teardown() error {
+	if f.client == nil {
+		return nil
+	}
+	return f.client.Close()
+}
+
+// isRetryable reports whether a failed commit may succeed if retried.
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted, codes.ResourceExhausted, codes.Internal:
+		return true
+	}
+	return false
+}
+
+type writeFn struct {
+	mutateFn
+}
+
+func (f *writeFn) ProcessElement(ctx context.Context, encodedKey string, entity beam.Y) error {
+	key, err := datastore.DecodeKey(encodedKey)
+	if err != nil {
+		return errors.Wrapf(err, "invalid key %q", encodedKey)
+	}
+	return f.add(ctx, key, datastore.NewUpsert(key, entityPointer(entity)))
+}
+
+// entityPointer returns a pointer to the given entity, as required by
+// datastore.SaveStruct, unless it already is a pointer or implements
+// datastore.PropertyLoadSaver.
+func entityPointer(entity any) any {
+	if _, ok := entity.(datastore.PropertyLoadSaver); ok {
+		return entity
+	}
+	v := reflect.ValueOf(entity)
+	if v.Kind() == reflect.Ptr {
+		return entity
+	}
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return p.Interface()
+}
+
+type deleteFn struct {
+	mutateFn
+}
+
+func (f *deleteFn) ProcessElement(ctx context.Context, encodedKey string) error {
+	key, err := datastore.DecodeKey(encodedKey)
+	if err != nil {
+		return errors.Wrapf(err, "invalid key %q", encodedKey)
+	}
+	return f.add(ctx, key, datastore.NewDelete(key))
+}
+
+// rampUpThrottler limits operations to a budget per one second window. The
+// budget starts at the base budget divided by the number of workers and
+// grows by 50% every ramp-up interval.
+type rampUpThrottler struct {
+	numWorkers int
+	now        func() time.Time
+	sleep      func(time.Duration)
+
+	start       time.Time
+	windowStart time.Time
+	used        int
+}
+
+func newRampUpThrottler(numWorkers int, now func() time.Time, sleep func(time.Duration)) *rampUpThrottler {
+	t := now()
+	return &rampUpThrottler{numWorkers: numWorkers, now: now, sleep: sleep, start: t, windowStart: t}
+}
+
+// budget returns the number of operations allowed per second at time t.
+func (r *rampUpThrottler) budget(t time.Time) int {
+	growth := math.Pow(1.5, math.Floor(float64(t.Sub(r.start))/float64(rampUpInterval)))
+	return max(1, int(rampUpBaseBudget*growth/float64(r.numWorkers)))
+}
+
+// acquire blocks until the budget of the current window has room for at
+// least one operation, and takes up to n operations from it. It returns the
+// number of operations taken.
+func (r *rampUpThrottler) acquire(ctx context.Context, n int) int {
+	for {
+		t := r.now()
+		if t.Sub(r.windowStart) >= time.Second {
+			r.windowStart = t
+			r.used = 0
+		}
+		if room := r.budget(t) - r.used; room > 0 {
+			n = min(n, room)
+			r.used += n
+			return n
+		}
+		wait := time.Second - t.Sub(r.windowStart)
+		log.Debugf(ctx, "Datastore: throttling writes for %v to ramp up", wait)
+		r.sleep(wait)
+	}
+}
@@ -0,0 +1,378 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastoreio
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*Item)(nil)).Elem())
+	register.Function1x2(keyItem)
+	register.Function1x1(itemKey)
+}
+
+type Item struct {
+	Name  string
+	Count int
+}
+
+func keyItem(item Item) (string, Item) {
+	return itemKey(item), item
+}
+
+func itemKey(item Item) string {
+	return datastore.NameKey("Item", item.Name, nil).Encode()
+}
+
+// fakeMutateClient implements mutateClientType, recording the size of each
+// commit and failing the first commits with the given errors.
+type fakeMutateClient struct {
+	mu           sync.Mutex
+	errs         []error
+	commits      []int
+	closeCounter int
+}
+
+func (c *fakeMutateClient) Mutate(_ context.Context, muts ...*datastore.Mutation) ([]*datastore.Key, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.errs) > 0 {
+		err := c.errs[0]
+		c.errs = c.errs[1:]
+		return nil, err
+	}
+	c.commits = append(c.commits, len(muts))
+	return make([]*datastore.Key, len(muts)), nil
+}
+
+func (c *fakeMutateClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeCounter++
+	return nil
+}
+
+func (c *fakeMutateClient) newClient(context.Context, string, ...option.ClientOption) (mutateClientType, error) {
+	return c, nil
+}
+
+func (c *fakeMutateClient) total() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, m := range c.commits {
+		n += m
+	}
+	return n
+}
+
+func TestWrite(t *testing.T) {
+	var items []any
+	for i := 0; i < 25; i++ {
+		items = append(items, Item{Name: string(rune('a' + i)), Count: i})
+	}
+
+	client := &fakeMutateClient{}
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.ParDo(s, keyItem, beam.Create(s, items...))
+	write(s, "project", col, WriteOption{BatchSize: 10}, client.newClient)
+	ptest.RunAndValidate(t, p)
+
+	if got, want := client.total(), len(items); got != want {
+		t.Errorf("got %v upserts, want %v", got, want)
+	}
+	for _, n := range client.commits {
+		if n > 10 {
+			t.Errorf("got commit of %v mutations, want at most 10", n)
+		}
+	}
+	if client.closeCounter == 0 {
+		t.Error("client was not closed")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	client := &fakeMutateClient{}
+	p, s := beam.NewPipelineWithRoot()
+	keys := beam.ParDo(s, itemKey, beam.Create(s, Item{Name: "a"}, Item{Name: "b"}, Item{Name: "c"}))
+	deleteKeys(s, "project", keys, WriteOption{BatchSize: 500}, client.newClient)
+	ptest.RunAndValidate(t, p)
+
+	if got, want := client.total(), 3; got != want {
+		t.Errorf("got %v deletes, want %v", got, want)
+	}
+}
+
+func TestDelete_BadKey(t *testing.T) {
+	client := &fakeMutateClient{}
+	p, s := beam.NewPipelineWithRoot()
+	keys := beam.Create(s, "not a key")
+	deleteKeys(s, "project", keys, WriteOption{BatchSize: 500}, client.newClient)
+	err := ptest.Run(p)
+	if err == nil || !strings.Contains(err.Error(), "invalid key") {
+		t.Errorf("got error %v, want an invalid key error", err)
+	}
+}
+
+func newTestMutateFn(client *fakeMutateClient, batchSize int) *mutateFn {
+	fn := &mutateFn{
+		Project:       "project",
+		Option:        WriteOption{BatchSize: batchSize},
+		newClientFunc: client.newClient,
+		sleep:         func(time.Duration) {},
+	}
+	return fn
+}
+
+func TestMutateFn_Batching(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeMutateClient{}
+	fn := newTestMutateFn(client, 2)
+	if err := fn.Setup(ctx); err != nil {
+		t.Fatalf("Setup() failed: %v", err)
+	}
+	fn.StartBundle()
+
+	for _, name := range []string{"a", "b", "c", "c", "d"} {
+		k := datastore.NameKey("Item", name, nil)
+		if err := fn.add(ctx, k, datastore.NewDelete(k)); err != nil {
+			t.Fatalf("add(%v) failed: %v", name, err)
+		}
+	}
+	if err := fn.FinishBundle(ctx); err != nil {
+		t.Fatalf("FinishBundle() failed: %v", err)
+	}
+
+	// The repeated key "c" forces a commit of the batch holding the first.
+	if got, want := client.commits, []int{2, 1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got commits of %v mutations, want %v", got, want)
+	}
+}
+
+func TestMutateFn_IncompleteKey(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeMutateClient{}
+	fn := newTestMutateFn(client, 2)
+	if err := fn.Setup(ctx); err != nil {
+		t.Fatalf("Setup() failed: %v", err)
+	}
+	fn.StartBundle()
+
+	k := datastore.IncompleteKey("Item", nil)
+	err := fn.add(ctx, k, datastore.NewDelete(k))
+	if err == nil || !strings.Contains(err.Error(), "incomplete key") {
+		t.Errorf("got error %v, want an incomplete key error", err)
+	}
+}
+
+func TestMutateFn_Retry(t *testing.T) {
+	tests := []struct {
+		name    string
+		errs    []error
+		wantErr bool
+		commits int
+	}{
+		{
+			name:    "retries transient errors",
+			errs:    []error{status.Error(codes.Unavailable, "unavailable"), status.Error(codes.Aborted, "aborted")},
+			commits: 1,
+		},
+		{
+			name:    "fails on permanent errors",
+			errs:    []error{status.Error(codes.InvalidArgument, "invalid")},
+			wantErr: true,
+		},
+		{
+			name: "fails after the maximum attempts",
+			errs: []error{
+				status.Error(codes.Unavailable, "1"),
+				status.Error(codes.Unavailable, "2"),
+				status.Error(codes.Unavailable, "3"),
+				status.Error(codes.Unavailable, "4"),
+				status.Error(codes.Unavailable, "5"),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			client := &fakeMutateClient{errs: tt.errs}
+			fn := newTestMutateFn(client, 10)
+			var sleeps []time.Duration
+			fn.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+			if err := fn.Setup(ctx); err != nil {
+				t.Fatalf("Setup() failed: %v", err)
+			}
+			fn.StartBundle()
+			k := datastore.NameKey("Item", "a", nil)
+			if err := fn.add(ctx, k, datastore.NewDelete(k)); err != nil {
+				t.Fatalf("add() failed: %v", err)
+			}
+
+			err := fn.FinishBundle(ctx)
+			if gotErr := err != nil; gotErr != tt.wantErr {
+				t.Fatalf("FinishBundle() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := len(client.commits); got != tt.commits {
+				t.Errorf("got %v commits, want %v", got, tt.commits)
+			}
+			for i := 1; i < len(sleeps); i++ {
+				if sleeps[i] != 2*sleeps[i-1] {
+					t.Errorf("got backoffs %v, want exponential backoff", sleeps)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestRampUpThrottler_Budget(t *testing.T) {
+	start := time.Unix(0, 0)
+	r := newRampUpThrottler(1, func() time.Time { return start }, nil)
+
+	tests := []struct {
+		elapsed time.Duration
+		want    int
+	}{
+		{0, 500},
+		{4 * time.Minute, 500},
+		{5 * time.Minute, 750},
+		{10 * time.Minute, 1125},
+	}
+	for _, tt := range tests {
+		if got := r.budget(start.Add(tt.elapsed)); got != tt.want {
+			t.Errorf("budget after %v = %v, want %v", tt.elapsed, got, tt.want)
+		}
+	}
+
+	shared := newRampUpThrottler(500, func() time.Time { return start }, nil)
+	if got, want := shared.budget(start), 1; got != want {
+		t.Errorf("budget shared by 500 workers = %v, want %v", got, want)
+	}
+}
+
+func TestRampUpThrottler_Acquire(t *testing.T) {
+	now := time.Unix(0, 0)
+	var slept time.Duration
+	r := newRampUpThrottler(2, func() time.Time { return now }, func(d time.Duration) {
+		slept += d
+		now = now.Add(d)
+	})
+
+	// The budget is 250 operations per second.
+	if got := r.acquire(context.Background(), 200); got != 200 || slept != 0 {
+		t.Errorf("first acquire took %v and slept %v, want 200 without a wait", got, slept)
+	}
+	now = now.Add(100 * time.Millisecond)
+	if got := r.acquire(context.Background(), 100); got != 50 || slept != 0 {
+		t.Errorf("second acquire took %v and slept %v, want the remaining 50 without a wait", got, slept)
+	}
+	if got := r.acquire(context.Background(), 100); got != 100 || slept != 900*time.Millisecond {
+		t.Errorf("third acquire took %v and slept %v, want 100 after the window ends", got, slept)
+	}
+	// A batch larger than the budget is only taken in part.
+	if got := r.acquire(context.Background(), 400); got != 150 || slept != 900*time.Millisecond {
+		t.Errorf("fourth acquire took %v and slept %v, want the remaining 150 without a wait", got, slept)
+	}
+}
+
+func TestMutateFn_RampUp(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeMutateClient{}
+	option, err := newWriteOption(nil)
+	if err != nil {
+		t.Fatalf("newWriteOption() failed: %v", err)
+	}
+	fn := newTestMutateFn(client, option.BatchSize)
+	fn.Option = option
+	start := time.Unix(0, 0)
+	now := start
+	fn.throttler = newRampUpThrottler(option.HintNumWorkers, func() time.Time { return now }, func(d time.Duration) {
+		now = now.Add(d)
+	})
+	if err := fn.Setup(ctx); err != nil {
+		t.Fatalf("Setup() failed: %v", err)
+	}
+	fn.StartBundle()
+
+	for i := 0; i < 10; i++ {
+		k := datastore.IDKey("Item", int64(i+1), nil)
+		if err := fn.add(ctx, k, datastore.NewDelete(k)); err != nil {
+			t.Fatalf("add() failed: %v", err)
+		}
+	}
+	if err := fn.FinishBundle(ctx); err != nil {
+		t.Fatalf("FinishBundle() failed: %v", err)
+	}
+
+	// With the default 500 workers, each worker starts at 1 operation per
+	// second.
+	if got, want := client.commits, []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got commits of %v mutations, want %v", got, want)
+	}
+	if got, want := now.Sub(start), 9*time.Second; got != want {
+		t.Errorf("writes took %v, want %v", got, want)
+	}
+}
+
+func TestWriteOption(t *testing.T) {
+	option, err := newWriteOption(nil)
+	if err != nil {
+		t.Fatalf("newWriteOption() failed: %v", err)
+	}
+	if want := (WriteOption{BatchSize: 500, RampUp: true, HintNumWorkers: 500}); option != want {
+		t.Errorf("got default options %+v, want %+v", option, want)
+	}
+
+	option, err = newWriteOption([]WriteOptionFn{WithWriteBatchSize(10), WithRampUp(false), WithHintNumWorkers(3)})
+	if err != nil {
+		t.Fatalf("newWriteOption() failed: %v", err)
+	}
+	if want := (WriteOption{BatchSize: 10, RampUp: false, HintNumWorkers: 3}); option != want {
+		t.Errorf("got options %+v, want %+v", option, want)
+	}
+
+	for _, opt := range []WriteOptionFn{WithWriteBatchSize(0), WithWriteBatchSize(501), WithHintNumWorkers(0)} {
+		if _, err := newWriteOption([]WriteOptionFn{opt}); err == nil {
+			t.Error("newWriteOption() succeeded with an invalid option, want error")
+		}
+	}
+}
+
+func TestEntityPointer(t *testing.T) {
+	item := Item{Name: "a"}
+	if got, ok := entityPointer(item).(*Item); !ok || *got != item {
+		t.Errorf("entityPointer(%v) = %v, want a pointer to it", item, got)
+	}
+	if got := entityPointer(&item); got != &item {
+		t.Errorf("entityPointer(%p) = %p, want the same pointer", &item, got)
+	}
+}
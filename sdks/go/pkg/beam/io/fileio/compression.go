@@ -0,0 +1,337 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileio
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	dbzip2 "github.com/dsnet/compress/bzip2"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+// Names of the built-in compressions.
+const (
+	// CompressionGzip is gzip, with the extension ".gz".
+	CompressionGzip = "gzip"
+	// CompressionZstd is Zstandard, with the extensions ".zst" and ".zstd".
+	CompressionZstd = "zstd"
+	// CompressionBzip2 is bzip2, with the extension ".bz2".
+	CompressionBzip2 = "bzip2"
+	// CompressionDeflate is deflate in the zlib format, with the extension
+	// ".deflate".
+	CompressionDeflate = "deflate"
+	// CompressionSnappy is the snappy framing format, with the extension
+	// ".sz".
+	CompressionSnappy = "snappy"
+	// CompressionLZ4 is the LZ4 frame format, with the extension ".lz4".
+	CompressionLZ4 = "lz4"
+)
+
+func init() {
+	RegisterCompression(CompressionGzip, gzipCodec{}, ".gz")
+	RegisterCompression(CompressionZstd, zstdCodec{}, ".zst", ".zstd")
+	RegisterCompression(CompressionBzip2, bzip2Codec{}, ".bz2")
+	RegisterCompression(CompressionDeflate, deflateCodec{}, ".deflate")
+	RegisterCompression(CompressionSnappy, snappyCodec{}, ".sz")
+	RegisterCompression(CompressionLZ4, lz4Codec{}, ".lz4")
+}
+
+// Codec decompresses data in a compression format. Codecs that also
+// compress data implement WriteCodec.
+type Codec interface {
+	// NewReader returns a reader of the decompressed data of r. Closing the
+	// reader must not close r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// WriteCodec is a Codec that also compresses data. Files can only be written
+// with compressions whose codec is a WriteCodec.
+type WriteCodec interface {
+	Codec
+	// NewWriter returns a writer that compresses data to w. Closing the
+	// writer must flush the compressed data, but not close w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+// compressionType is the type of compression used to compress a file.
+type compressionType int
+
+const (
+	// compressionAuto indicates that the compression type should be auto-detected.
+	compressionAuto compressionType = iota
+	// compressionGzip indicates that the file is compressed using gzip.
+	compressionGzip
+	// compressionUncompressed indicates that the file is not compressed.
+	compressionUncompressed
+	// compressionZstd indicates that the file is compressed using Zstandard.
+	compressionZstd
+	// compressionBzip2 indicates that the file is compressed using bzip2.
+	compressionBzip2
+	// compressionDeflate indicates that the file is compressed using deflate.
+	compressionDeflate
+	// compressionSnappy indicates that the file is compressed using snappy.
+	compressionSnappy
+	// compressionLZ4 indicates that the file is compressed using LZ4.
+	compressionLZ4
+	// compressionCustom is the first type of the compressions registered
+	// under other names, which are numbered in the order that they are
+	// registered in.
+	compressionCustom
+)
+
+// builtinCompressions are the types of the built-in compressions by name.
+var builtinCompressions = map[string]compressionType{
+	CompressionGzip:    compressionGzip,
+	CompressionZstd:    compressionZstd,
+	CompressionBzip2:   compressionBzip2,
+	CompressionDeflate: compressionDeflate,
+	CompressionSnappy:  compressionSnappy,
+	CompressionLZ4:     compressionLZ4,
+}
+
+func (t compressionType) String() string {
+	switch t {
+	case compressionAuto:
+		return "auto"
+	case compressionUncompressed:
+		return "uncompressed"
+	}
+	if c, ok := compressions[t]; ok {
+		return c.name
+	}
+	return fmt.Sprintf("compressionType(%d)", int(t))
+}
+
+type compression struct {
+	name  string
+	codec Codec
+	ext   string
+}
+
+var (
+	compressions     = make(map[compressionType]compression)
+	compressionNames = make(map[string]compressionType)
+	compressionExts  = make(map[string]compressionType)
+	nextCompression  = compressionCustom
+)
+
+// RegisterCompression registers a compression Codec under the given name and
+// file extensions, such as ".gz". Files with the extensions are decompressed
+// with the codec when the compression is auto-detected, and the first
+// extension is appended to the names of the files written with the codec.
+// RegisterCompression is expected to be called in init functions, so that
+// the compressions are registered in the same order in every worker.
+func RegisterCompression(name string, codec Codec, exts ...string) {
+	if name == "" || name == compressionUncompressed.String() {
+		panic(fmt.Sprintf("invalid compression name %q", name))
+	}
+	if _, ok := compressionNames[name]; ok {
+		panic(fmt.Sprintf("compression %v already registered", name))
+	}
+	if len(exts) == 0 {
+		panic(fmt.Sprintf("compression %v has no extension", name))
+	}
+	for _, ext := range exts {
+		if other, ok := compressionExts[ext]; ok {
+			panic(fmt.Sprintf("extension %v of compression %v already registered for compression %v", ext, name, other))
+		}
+	}
+
+	t, ok := builtinCompressions[name]
+	if !ok {
+		t = nextCompression
+		nextCompression++
+	}
+	for _, ext := range exts {
+		compressionExts[ext] = t
+	}
+	compressionNames[name] = t
+	compressions[t] = compression{name: name, codec: codec, ext: exts[0]}
+}
+
+// compressionByName returns the type of the compression registered under the
+// given name.
+func compressionByName(name string) (compressionType, error) {
+	t, ok := compressionNames[name]
+	if !ok {
+		return 0, fmt.Errorf("compression %q not registered", name)
+	}
+	return t, nil
+}
+
+// lookupCompression returns the registered compression of the given type.
+func lookupCompression(t compressionType) (compression, error) {
+	c, ok := compressions[t]
+	if !ok {
+		return compression{}, fmt.Errorf("compression %v not registered", t)
+	}
+	return c, nil
+}
+
+// lookupWriteCodec returns the codec that files of the compression type are
+// written with, or nil if they are not compressed. It returns an error if the
+// compression is not registered or can't be written.
+func lookupWriteCodec(t compressionType) (WriteCodec, error) {
+	if t == compressionAuto || t == compressionUncompressed {
+		return nil, nil
+	}
+	c, err := lookupCompression(t)
+	if err != nil {
+		return nil, err
+	}
+	wc, ok := c.codec.(WriteCodec)
+	if !ok {
+		return nil, fmt.Errorf("compression %v is not supported for writing", t)
+	}
+	return wc, nil
+}
+
+// compressionFromExt detects the compression of a file based on its extension. If the extension is
+// not recognized, compressionUncompressed is returned.
+func compressionFromExt(path string) compressionType {
+	if t, ok := compressionExts[filepath.Ext(path)]; ok {
+		return t
+	}
+	return compressionUncompressed
+}
+
+// newDecompressionReader returns an io.ReadCloser that can be used to read uncompressed data from
+// reader, based on the specified compression. If the compression is compressionAuto, a non-nil
+// error is returned. It is the caller's responsibility to close the returned reader.
+func newDecompressionReader(
+	reader io.ReadCloser,
+	compression compressionType,
+) (io.ReadCloser, error) {
+	switch compression {
+	case compressionAuto:
+		return nil, errors.New(
+			"compression must be resolved into a concrete type before obtaining a reader",
+		)
+	case compressionUncompressed:
+		return reader, nil
+	}
+
+	c, err := lookupCompression(compression)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := c.codec.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	return &decompressionReader{rc: reader, zr: zr}, nil
+}
+
+// decompressionReader reads the decompressed data of a file, and closes both
+// the decompressing reader and the file.
+type decompressionReader struct {
+	rc io.ReadCloser
+	zr io.ReadCloser
+}
+
+func (r *decompressionReader) Read(p []byte) (int, error) {
+	return r.zr.Read(p)
+}
+
+func (r *decompressionReader) Close() (err error) {
+	defer func() {
+		rcErr := r.rc.Close()
+		if err != nil {
+			if rcErr != nil {
+				log.Errorf(context.Background(), "error closing reader: %v", rcErr)
+			}
+			return
+		}
+		err = rcErr
+	}()
+
+	return r.zr.Close()
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func (gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+type zstdCodec struct{}
+
+func (zstdCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return zr.IOReadCloser(), nil
+}
+
+func (zstdCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w)
+}
+
+type bzip2Codec struct{}
+
+func (bzip2Codec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(bzip2.NewReader(r)), nil
+}
+
+func (bzip2Codec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	// The standard library only decompresses bzip2.
+	return dbzip2.NewWriter(w, nil)
+}
+
+type deflateCodec struct{}
+
+func (deflateCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
+func (deflateCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriter(w), nil
+}
+
+type snappyCodec struct{}
+
+func (snappyCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(snappy.NewReader(r)), nil
+}
+
+func (snappyCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return snappy.NewBufferedWriter(w), nil
+}
+
+type lz4Codec struct{}
+
+func (lz4Codec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(lz4.NewReader(r)), nil
+}
+
+func (lz4Codec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return lz4.NewWriter(w), nil
+}
@@ -0,0 +1,144 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileio
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// compressionReadOnly is a compression that only supports reading.
+const compressionReadOnly = "read-only"
+
+func init() {
+	RegisterCompression(compressionReadOnly, readOnlyCodec{}, ".read-only")
+}
+
+type readOnlyCodec struct{}
+
+func (readOnlyCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(r), nil
+}
+
+func TestCompression_RoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat("hello compressed world\n", 1000))
+	for _, name := range []string{CompressionGzip, CompressionZstd, CompressionBzip2, CompressionDeflate, CompressionSnappy, CompressionLZ4} {
+		t.Run(name, func(t *testing.T) {
+			comp, err := compressionByName(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := lookupCompression(comp)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			w, err := c.codec.(WriteCodec).NewWriter(&buf)
+			if err != nil {
+				t.Fatalf("NewWriter() failed: %v", err)
+			}
+			if _, err := w.Write(data); err != nil {
+				t.Fatalf("Write() failed: %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close() failed: %v", err)
+			}
+			if buf.Len() >= len(data) {
+				t.Errorf("compressed %v bytes to %v bytes, want fewer", len(data), buf.Len())
+			}
+
+			dr, err := newDecompressionReader(io.NopCloser(&buf), comp)
+			if err != nil {
+				t.Fatalf("newDecompressionReader() failed: %v", err)
+			}
+			defer dr.Close()
+			if err := iotest.TestReader(dr, data); err != nil {
+				t.Errorf("TestReader() error = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestLookupWriteCodec(t *testing.T) {
+	for _, name := range []string{CompressionGzip, CompressionZstd, CompressionBzip2, CompressionDeflate, CompressionSnappy, CompressionLZ4} {
+		if c, err := lookupWriteCodec(compressionNames[name]); c == nil || err != nil {
+			t.Errorf("lookupWriteCodec(%q) = %v, %v, want codec", name, c, err)
+		}
+	}
+	for _, comp := range []compressionType{compressionAuto, compressionUncompressed} {
+		if c, err := lookupWriteCodec(comp); c != nil || err != nil {
+			t.Errorf("lookupWriteCodec(%v) = %v, %v, want nil, nil", comp, c, err)
+		}
+	}
+	for _, comp := range []compressionType{compressionNames[compressionReadOnly], nextCompression} {
+		if _, err := lookupWriteCodec(comp); err == nil {
+			t.Errorf("lookupWriteCodec(%v) succeeded, want error", comp)
+		}
+	}
+}
+
+func TestCompressionType_Values(t *testing.T) {
+	// The types are encoded in the Compression field of ReadableFile, so
+	// their values must not change.
+	tests := []struct {
+		comp compressionType
+		want int
+	}{
+		{compressionAuto, 0},
+		{compressionGzip, 1},
+		{compressionUncompressed, 2},
+		{compressionZstd, 3},
+		{compressionBzip2, 4},
+		{compressionDeflate, 5},
+		{compressionSnappy, 6},
+		{compressionLZ4, 7},
+	}
+	for _, test := range tests {
+		if got := int(test.comp); got != test.want {
+			t.Errorf("%v = %v, want %v", test.comp, got, test.want)
+		}
+	}
+	if got := compressionNames[compressionReadOnly]; got < compressionCustom {
+		t.Errorf("compressionNames[%q] = %d, want at least %d", compressionReadOnly, got, compressionCustom)
+	}
+}
+
+func TestRegisterCompression_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		compName string
+		exts     []string
+	}{
+		{"registered name", CompressionGzip, []string{".gzip2"}},
+		{"registered extension", "gzip2", []string{".gz"}},
+		{"reserved name", "uncompressed", []string{".none"}},
+		{"no extension", "gzip2", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("RegisterCompression() succeeded, want panic")
+				}
+			}()
+			RegisterCompression(test.compName, gzipCodec{}, test.exts...)
+		})
+	}
+}
@@ -0,0 +1,475 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/google/uuid"
+)
+
+func init() {
+	beam.RegisterType(destinationType)
+	beam.RegisterCoder(destinationType, encodeDestination, decodeDestination)
+	beam.RegisterType(reflect.TypeOf((*destinationShard)(nil)).Elem())
+	register.DoFn7x1[context.Context, typex.PaneInfo, typex.Window, int, func(*beam.T) bool, func(writeResult), func(destinationShard, beam.T), error](&writeDynamicFn{})
+	register.DoFn6x1[context.Context, typex.PaneInfo, typex.Window, destinationShard, func(*beam.T) bool, func(writeResult), error](&writeSpilledFn{})
+	register.DoFn5x1[context.Context, typex.Window, int, func(*writeResult) bool, func(string, string), error](&finalizeDynamicFn{})
+	register.Emitter2[destinationShard, beam.T]()
+	register.Emitter2[string, string]()
+}
+
+// Destination configures the files written to a destination by WriteDynamic.
+// Destinations are encoded with their Sink as WriteFiles encodes sinks, so
+// that they may also be elements.
+type Destination struct {
+	// Filename is the filename prefix or template of the files, as for
+	// WriteFiles.
+	Filename string
+	// Suffix is appended to the filenames. If empty, the suffix given with
+	// WriteSuffix is used.
+	Suffix string
+	// Compression is the name of the registered compression that the files
+	// are compressed with, whose extension is appended to the filenames. If
+	// empty, the compression given with WriteCompression is used, or if none
+	// was given, the compression of the extension of the filenames, if any.
+	Compression string
+	// Header and Footer are written at the start and at the end of each file,
+	// around the output of the Sink.
+	Header, Footer []byte
+	// Sink writes the elements to the files. It is used for a single file,
+	// so a new Sink must be returned for each call.
+	Sink Sink
+}
+
+var destinationType = reflect.TypeOf((*Destination)(nil)).Elem()
+
+// encodedDestination is a Destination with its Sink encoded.
+type encodedDestination struct {
+	Filename    string      `json:"filename"`
+	Suffix      string      `json:"suffix"`
+	Compression string      `json:"compression"`
+	Header      []byte      `json:"header"`
+	Footer      []byte      `json:"footer"`
+	Sink        encodedSink `json:"sink"`
+}
+
+func encodeDestination(d Destination) ([]byte, error) {
+	sink, err := encodeSink(d.Sink)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(encodedDestination{
+		Filename:    d.Filename,
+		Suffix:      d.Suffix,
+		Compression: d.Compression,
+		Header:      d.Header,
+		Footer:      d.Footer,
+		Sink:        sink,
+	})
+}
+
+func decodeDestination(data []byte) (Destination, error) {
+	var e encodedDestination
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Destination{}, err
+	}
+	sink, err := e.Sink.newSink()
+	if err != nil {
+		return Destination{}, err
+	}
+	return Destination{
+		Filename:    e.Filename,
+		Suffix:      e.Suffix,
+		Compression: e.Compression,
+		Header:      e.Header,
+		Footer:      e.Footer,
+		Sink:        sink,
+	}, nil
+}
+
+// WriteDynamic writes the elements of a PCollection<T> to files, as
+// WriteFiles does, but splits them across destinations. The elements are
+// mapped to destination keys by destFn, which must be a registered
+// func(T) string, and the files of each destination are configured by
+// configFn, which must be a registered func(string) Destination. WriteDynamic
+// returns a PCollection<KV<string,string>> of the destination keys and the
+// names of the written files, in the windows of the elements.
+//
+// The options of WriteFiles apply to each destination, unless the
+// Destination overrides them. The shards of a
+// destination only contain the elements of that destination, so with a fixed
+// number of shards, the shards that received none of them are written as
+// empty files, with the header and footer of the destination.
+//
+// Each worker writes the destinations of a shard to at most
+// WriteMaxOpenWriters open files at once. The elements of the destinations
+// beyond that are spilled: they are grouped by destination and shard, and
+// written one file at a time.
+//
+// For example, to write events to a directory per customer:
+//
+//	func customerFn(e Event) string {
+//		return e.CustomerID
+//	}
+//
+//	func destinationFn(customerID string) fileio.Destination {
+//		return fileio.Destination{
+//			Filename: "gs://bucket/events/" + customerID + "/part-{shard}-of-{n}",
+//			Suffix:   ".json",
+//			Sink:     &jsonSink{},
+//		}
+//	}
+//
+//	fileio.WriteDynamic(s, customerFn, destinationFn, events)
+func WriteDynamic(s beam.Scope, destFn, configFn any, col beam.PCollection, opts ...WriteOptionFn) beam.PCollection {
+	s = s.Scope("fileio.WriteDynamic")
+
+	t := beam.ValidateNonCompositeType(col)
+	if err := validateDestFn(destFn, t.Type()); err != nil {
+		panic(fmt.Sprintf("fileio.WriteDynamic: %v", err))
+	}
+	if err := validateConfigFn(configFn); err != nil {
+		panic(fmt.Sprintf("fileio.WriteDynamic: %v", err))
+	}
+
+	option := newWriteOption()
+	for _, opt := range opts {
+		opt(option)
+	}
+	if option.NumShards < 0 {
+		panic(fmt.Sprintf("fileio.WriteDynamic: invalid number of shards %v", option.NumShards))
+	}
+	if option.MaxOpenWriters < 1 {
+		panic(fmt.Sprintf("fileio.WriteDynamic: invalid maximum number of open writers %v", option.MaxOpenWriters))
+	}
+
+	if _, err := lookupWriteCodec(option.Compression); err != nil {
+		panic(fmt.Sprintf("fileio.WriteDynamic: %v", err))
+	}
+
+	config := destinationConfig{
+		Fn:          beam.EncodedFunc{Fn: reflectx.MakeFunc(configFn)},
+		Suffix:      option.Suffix,
+		NumShards:   option.NumShards,
+		Compression: option.Compression,
+		TempID:      uuid.NewString(),
+	}
+	sharded := beam.ParDo(s, &shardFn{NumShards: option.NumShards}, col)
+	written, spilled := beam.ParDo2(s, &writeDynamicFn{
+		DestFn:         beam.EncodedFunc{Fn: reflectx.MakeFunc(destFn)},
+		Config:         config,
+		MaxOpenWriters: option.MaxOpenWriters,
+	}, beam.GroupByKey(s, sharded))
+	rewritten := beam.ParDo(s, &writeSpilledFn{Config: config}, beam.GroupByKey(s, spilled))
+
+	results := beam.Flatten(s, written, rewritten)
+	grouped := beam.GroupByKey(s, beam.AddFixedKey(s, results))
+	return beam.ParDo(s, &finalizeDynamicFn{Config: config}, grouped)
+}
+
+func validateDestFn(fn any, in reflect.Type) error {
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func {
+		return fmt.Errorf("destination fn %v is not a function", t)
+	}
+	if t.NumIn() != 1 || t.NumOut() != 1 || t.Out(0) != reflectx.String ||
+		(!typex.IsUniversal(in) && t.In(0) != in) {
+		return fmt.Errorf("destination fn has type %v, want a func(%v) string", t, in)
+	}
+	return nil
+}
+
+func validateConfigFn(fn any) error {
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func {
+		return fmt.Errorf("destination config fn %v is not a function", t)
+	}
+	if t.NumIn() != 1 || t.In(0) != reflectx.String || t.NumOut() != 1 || t.Out(0) != destinationType {
+		return fmt.Errorf("destination config fn has type %v, want a func(string) fileio.Destination", t)
+	}
+	return nil
+}
+
+// destinationConfig returns the Destination of the destination keys.
+type destinationConfig struct {
+	Fn          beam.EncodedFunc `json:"fn"`
+	Suffix      string           `json:"suffix"`
+	NumShards   int              `json:"numShards"`
+	Compression compressionType  `json:"compression"`
+	TempID      string           `json:"tempId"`
+
+	fn    reflectx.Func1x1
+	files map[string]destinationFiles
+}
+
+// destinationFiles is the naming and the compression of the files of a
+// destination.
+type destinationFiles struct {
+	naming fileNaming
+	comp   compressionType
+}
+
+func (c *destinationConfig) setup() {
+	c.fn = reflectx.ToFunc1x1(c.Fn.Fn)
+	c.files = make(map[string]destinationFiles)
+}
+
+// destination returns the Destination of the destination key, and the naming
+// and compression of its files.
+func (c *destinationConfig) destination(key string) (Destination, destinationFiles, error) {
+	d := c.fn.Call1x1(key).(Destination)
+	if files, ok := c.files[key]; ok {
+		return d, files, nil
+	}
+
+	option := writeOption{Suffix: d.Suffix, Compression: c.Compression}
+	if option.Suffix == "" {
+		option.Suffix = c.Suffix
+	}
+	if d.Compression != "" {
+		comp, err := compressionByName(d.Compression)
+		if err != nil {
+			return Destination{}, destinationFiles{}, fmt.Errorf("destination %q: %w", key, err)
+		}
+		option.Compression = comp
+	}
+	suffix, err := option.suffix()
+	if err != nil {
+		return Destination{}, destinationFiles{}, fmt.Errorf("destination %q: %w", key, err)
+	}
+	files := destinationFiles{
+		naming: fileNaming{Filename: d.Filename, Suffix: suffix, NumShards: c.NumShards, TempID: c.TempID},
+		comp:   option.Compression,
+	}
+	if files.comp == compressionAuto {
+		files.comp = compressionFromExt(d.Filename + suffix)
+		if _, err := lookupWriteCodec(files.comp); err != nil {
+			return Destination{}, destinationFiles{}, fmt.Errorf("destination %q: %w", key, err)
+		}
+	}
+	if err := files.naming.validate(); err != nil {
+		return Destination{}, destinationFiles{}, fmt.Errorf("destination %q: %w", key, err)
+	}
+	c.files[key] = files
+	return d, files, nil
+}
+
+// open opens a writer of a new temporary file for the destination key in a
+// pane of a window.
+func (c *destinationConfig) open(ctx context.Context, key string, w typex.Window, pane int64) (*fileWriter, string, error) {
+	d, files, err := c.destination(key)
+	if err != nil {
+		return nil, "", err
+	}
+	tempFile := tempFilename(files.naming, w, pane, key)
+	fw, err := c.openFile(ctx, key, d, files.comp, tempFile)
+	return fw, tempFile, err
+}
+
+// openFile opens a writer of the temporary file for the destination, which
+// is compressed with comp.
+func (c *destinationConfig) openFile(ctx context.Context, key string, d Destination, comp compressionType, tempFile string) (*fileWriter, error) {
+	if d.Sink == nil {
+		return nil, fmt.Errorf("destination %q has no sink", key)
+	}
+	w, err := openFileWriter(ctx, tempFile, comp, d.Sink, d.Header, d.Footer)
+	if err != nil {
+		return nil, fmt.Errorf("writing temporary file %v: %w", tempFile, err)
+	}
+	return w, nil
+}
+
+// destinationShard is the key of the spilled elements.
+type destinationShard struct {
+	Destination string
+	Shard       int
+}
+
+// writeDynamicFn writes the elements of a shard to a temporary file per
+// destination, with up to MaxOpenWriters files open at once. The elements of
+// the other destinations are spilled.
+type writeDynamicFn struct {
+	DestFn         beam.EncodedFunc  `json:"destFn"`
+	Config         destinationConfig `json:"config"`
+	MaxOpenWriters int               `json:"maxOpenWriters"`
+
+	destFn reflectx.Func1x1
+}
+
+func (fn *writeDynamicFn) Setup() {
+	fn.destFn = reflectx.ToFunc1x1(fn.DestFn.Fn)
+	fn.Config.setup()
+}
+
+func (fn *writeDynamicFn) ProcessElement(
+	ctx context.Context,
+	pane typex.PaneInfo,
+	win typex.Window,
+	shard int,
+	iter func(*beam.T) bool,
+	emit func(writeResult),
+	spill func(destinationShard, beam.T),
+) error {
+	type openFile struct {
+		w        *fileWriter
+		tempFile string
+	}
+	files := make(map[string]openFile)
+	defer func() {
+		for _, f := range files {
+			f.w.abort()
+		}
+	}()
+
+	var elm beam.T
+	for iter(&elm) {
+		key := fn.destFn.Call1x1(elm).(string)
+		f, ok := files[key]
+		if !ok {
+			if len(files) >= fn.MaxOpenWriters {
+				spill(destinationShard{Destination: key, Shard: shard}, elm)
+				continue
+			}
+			w, tempFile, err := fn.Config.open(ctx, key, win, pane.Index)
+			if err != nil {
+				return err
+			}
+			f = openFile{w: w, tempFile: tempFile}
+			files[key] = f
+		}
+		if err := f.w.Write(ctx, elm); err != nil {
+			return fmt.Errorf("writing temporary file %v: %w", f.tempFile, err)
+		}
+	}
+
+	for key, f := range files {
+		delete(files, key)
+		if err := f.w.Close(ctx); err != nil {
+			return fmt.Errorf("writing temporary file %v: %w", f.tempFile, err)
+		}
+		emit(writeResult{
+			TempFile:    f.tempFile,
+			Destination: key,
+			Shard:       shard,
+			PaneIndex:   pane.Index,
+			PaneFirst:   pane.IsFirst,
+			PaneLast:    pane.IsLast,
+		})
+	}
+	return nil
+}
+
+// writeSpilledFn writes the spilled elements of a destination and shard to a
+// temporary file.
+type writeSpilledFn struct {
+	Config destinationConfig `json:"config"`
+}
+
+func (fn *writeSpilledFn) Setup() {
+	fn.Config.setup()
+}
+
+func (fn *writeSpilledFn) ProcessElement(
+	ctx context.Context,
+	pane typex.PaneInfo,
+	win typex.Window,
+	key destinationShard,
+	iter func(*beam.T) bool,
+	emit func(writeResult),
+) error {
+	w, tempFile, err := fn.Config.open(ctx, key.Destination, win, pane.Index)
+	if err != nil {
+		return err
+	}
+	var elm beam.T
+	for iter(&elm) {
+		if err := w.Write(ctx, elm); err != nil {
+			w.abort()
+			return fmt.Errorf("writing temporary file %v: %w", tempFile, err)
+		}
+	}
+	if err := w.Close(ctx); err != nil {
+		return fmt.Errorf("writing temporary file %v: %w", tempFile, err)
+	}
+
+	emit(writeResult{
+		TempFile:    tempFile,
+		Destination: key.Destination,
+		Shard:       key.Shard,
+		PaneIndex:   pane.Index,
+		PaneFirst:   pane.IsFirst,
+		PaneLast:    pane.IsLast,
+	})
+	return nil
+}
+
+// finalizeDynamicFn renames the temporary files of a window to their final
+// names, per destination.
+type finalizeDynamicFn struct {
+	Config destinationConfig `json:"config"`
+}
+
+func (fn *finalizeDynamicFn) Setup() {
+	fn.Config.setup()
+}
+
+func (fn *finalizeDynamicFn) ProcessElement(
+	ctx context.Context,
+	w typex.Window,
+	_ int,
+	iter func(*writeResult) bool,
+	emit func(string, string),
+) error {
+	type destinationPane struct {
+		destination string
+		pane        int64
+	}
+	panes := make(map[destinationPane][]writeResult)
+	var r writeResult
+	for iter(&r) {
+		key := destinationPane{destination: r.Destination, pane: r.PaneIndex}
+		panes[key] = append(panes[key], r)
+	}
+
+	for key, results := range panes {
+		d, files, err := fn.Config.destination(key.destination)
+		if err != nil {
+			return err
+		}
+		writeEmpty := func(ctx context.Context, tempFile string) error {
+			fw, err := fn.Config.openFile(ctx, key.destination, d, files.comp, tempFile)
+			if err != nil {
+				return err
+			}
+			return fw.Close(ctx)
+		}
+		emitFile := func(filename string) {
+			emit(key.destination, filename)
+		}
+		if err := finalize(ctx, files.naming, w, key.destination, results, writeEmpty, emitFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileio
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func init() {
+	register.Function1x1(modDestFn)
+	register.Function1x1(modConfigFn)
+	register.Function1x1(compressionConfigFn)
+}
+
+// dynamicDir is the directory written by modConfigFn. Tests run the workers
+// in process, so it can be set by the test.
+var dynamicDir string
+
+func modDestFn(n int) string {
+	return fmt.Sprintf("mod%d", n%3)
+}
+
+func modConfigFn(key string) Destination {
+	return Destination{
+		Filename: filepath.Join(dynamicDir, key, "out"),
+		Suffix:   "." + key,
+		Footer:   []byte("footer\n"),
+		Sink:     &testSink{Header: "header"},
+	}
+}
+
+func TestWriteDynamic(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []WriteOptionFn
+		files int
+	}{
+		{"open writers", []WriteOptionFn{WriteNumShards(1)}, 3},
+		{"spilled", []WriteOptionFn{WriteNumShards(1), WriteMaxOpenWriters(1)}, 3},
+		{"runner shards", []WriteOptionFn{WriteMaxOpenWriters(2)}, 3},
+		{"empty shards", []WriteOptionFn{WriteNumShards(4)}, 12},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dynamicDir = t.TempDir()
+			p, s := beam.NewPipelineWithRoot()
+			col := beam.Create(s, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+			files := WriteDynamic(s, modDestFn, modConfigFn, col, test.opts...)
+			passert.Count(s, beam.DropValue(s, files), "destinations", test.files)
+			ptest.RunAndValidate(t, p)
+
+			want := map[string][]string{
+				"mod0": {"3", "6", "9"},
+				"mod1": {"1", "4", "7"},
+				"mod2": {"2", "5", "8"},
+			}
+			got := make(map[string][]string)
+			for key := range want {
+				dir := filepath.Join(dynamicDir, key)
+				entries, err := os.ReadDir(dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, e := range entries {
+					if !strings.HasSuffix(e.Name(), "."+key) {
+						t.Errorf("file %v of destination %v, want suffix .%v", e.Name(), key, key)
+					}
+					data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+					if err != nil {
+						t.Fatal(err)
+					}
+					lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+					if lines[0] != "header" || lines[len(lines)-1] != "footer" {
+						t.Errorf("file %v is %q, want header and footer", e.Name(), lines)
+					}
+					got[key] = append(got[key], lines[1:len(lines)-1]...)
+				}
+				sort.Strings(got[key])
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("WriteDynamic() wrote diff (-want, +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+// compressionConfigFn configures a destination compressed with zstd, one
+// compressed with gzip as its extension indicates, and an uncompressed one.
+func compressionConfigFn(key string) Destination {
+	d := Destination{
+		Filename: filepath.Join(dynamicDir, key, "out"),
+		Suffix:   ".txt",
+		Sink:     &testSink{Header: "header"},
+	}
+	switch key {
+	case "mod0":
+		d.Compression = CompressionZstd
+	case "mod1":
+		d.Suffix = ".txt.gz"
+	}
+	return d
+}
+
+func TestWriteDynamic_Compression(t *testing.T) {
+	dynamicDir = t.TempDir()
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Create(s, 1, 2, 3, 4, 5, 6)
+	WriteDynamic(s, modDestFn, compressionConfigFn, col, WriteNumShards(1))
+	ptest.RunAndValidate(t, p)
+
+	tests := []struct {
+		path string
+		comp compressionType
+		want []string
+	}{
+		{"mod0/out.txt.zst", compressionZstd, []string{"3", "6", "header"}},
+		{"mod1/out.txt.gz", compressionGzip, []string{"1", "4", "header"}},
+		{"mod2/out.txt", compressionUncompressed, []string{"2", "5", "header"}},
+	}
+	for _, test := range tests {
+		file := ReadableFile{
+			Metadata:    FileMetadata{Path: filepath.Join(dynamicDir, test.path)},
+			Compression: test.comp,
+		}
+		got, err := file.ReadString(context.Background())
+		if err != nil {
+			t.Fatalf("ReadString(%v) failed: %v", test.path, err)
+		}
+		lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+		sort.Strings(lines)
+		if !cmp.Equal(lines, test.want) {
+			t.Errorf("WriteDynamic() wrote %v to %v, want %v", lines, test.path, test.want)
+		}
+	}
+}
+
+func TestWriteDynamic_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		destFn   any
+		configFn any
+		opts     []WriteOptionFn
+	}{
+		{"bad dest fn", func(n string) string { return n }, modConfigFn, nil},
+		{"bad config fn", modDestFn, modDestFn, nil},
+		{"no open writers", modDestFn, modConfigFn, []WriteOptionFn{WriteMaxOpenWriters(0)}},
+		{"read-only compression", modDestFn, modConfigFn, []WriteOptionFn{WriteCompression(compressionReadOnly)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("WriteDynamic() succeeded, want panic")
+				}
+			}()
+			_, s := beam.NewPipelineWithRoot()
+			WriteDynamic(s, test.destFn, test.configFn, beam.Create(s, 1), test.opts...)
+		})
+	}
+}
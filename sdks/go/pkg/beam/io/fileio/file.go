@@ -17,9 +17,8 @@ package fileio
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"io"
-	"path/filepath"
 	"reflect"
 	"time"
 
@@ -41,18 +40,6 @@ type FileMetadata struct {
 	LastModified time.Time
 }
 
-// compressionType is the type of compression used to compress a file.
-type compressionType int
-
-const (
-	// compressionAuto indicates that the compression type should be auto-detected.
-	compressionAuto compressionType = iota
-	// compressionGzip indicates that the file is compressed using gzip.
-	compressionGzip
-	// compressionUncompressed indicates that the file is not compressed.
-	compressionUncompressed
-)
-
 // ReadableFile is a wrapper around a FileMetadata and compressionType that can be used to obtain a
 // file descriptor or read the file's contents.
 type ReadableFile struct {
@@ -60,6 +47,17 @@ type ReadableFile struct {
 	Compression compressionType
 }
 
+// IsCompressed reports whether the file is compressed. If Compression is compressionAuto, the
+// compression type is auto-detected from the file extension. The offsets of compressed files do not
+// map to offsets of their contents, so they can only be read as a whole.
+func (f ReadableFile) IsCompressed() bool {
+	comp := f.Compression
+	if comp == compressionAuto {
+		comp = compressionFromExt(f.Metadata.Path)
+	}
+	return comp != compressionUncompressed
+}
+
 // Open opens the file for reading. The compression type is determined by the Compression field of
 // the ReadableFile. If Compression is compressionAuto, the compression type is auto-detected from
 // the file extension. It is the caller's responsibility to close the returned reader.
@@ -83,34 +81,36 @@ func (f ReadableFile) Open(ctx context.Context) (io.ReadCloser, error) {
 	return newDecompressionReader(rc, comp)
 }
 
-// compressionFromExt detects the compression of a file based on its extension. If the extension is
-// not recognized, compressionUncompressed is returned.
-func compressionFromExt(path string) compressionType {
-	switch filepath.Ext(path) {
-	case ".gz":
-		return compressionGzip
-	default:
-		return compressionUncompressed
+// OpenAt opens the file for reading from the given offset. Only uncompressed
+// files can be opened at an offset other than 0. If the file system is a
+// filesystem.RangeReader, the file is read from the offset, and otherwise the
+// bytes before it are read and discarded.
+func (f ReadableFile) OpenAt(ctx context.Context, offset int64) (io.ReadCloser, error) {
+	if offset == 0 {
+		return f.Open(ctx)
+	}
+	if f.IsCompressed() {
+		return nil, fmt.Errorf("cannot open compressed file %v at offset %v", f.Metadata.Path, offset)
 	}
-}
 
-// newDecompressionReader returns an io.ReadCloser that can be used to read uncompressed data from
-// reader, based on the specified compression. If the compression is compressionAuto, a non-nil
-// error is returned. It is the caller's responsibility to close the returned reader.
-func newDecompressionReader(
-	reader io.ReadCloser,
-	compression compressionType,
-) (io.ReadCloser, error) {
-	switch compression {
-	case compressionAuto:
-		return nil, errors.New(
-			"compression must be resolved into a concrete type before obtaining a reader",
-		)
-	case compressionGzip:
-		return newGzipReader(reader)
-	default:
-		return reader, nil
+	fs, err := filesystem.New(ctx, f.Metadata.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer fs.Close()
+
+	if rr, ok := fs.(filesystem.RangeReader); ok {
+		return rr.OpenReadRange(ctx, f.Metadata.Path, offset, -1)
+	}
+	rc, err := fs.OpenRead(ctx, f.Metadata.Path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, rc, offset); err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("seeking to offset %v of %v: %w", offset, f.Metadata.Path, err)
 	}
+	return rc, nil
 }
 
 // Read reads the entire file into memory and returns the contents.
@@ -18,9 +18,15 @@ package fileio
 import (
 	"bytes"
 	"context"
+	"io"
 	"path/filepath"
+	"strings"
 	"testing"
 	"testing/iotest"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/memfs"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestReadableFile_Open(t *testing.T) {
@@ -74,6 +80,93 @@ func TestReadableFile_Open(t *testing.T) {
 	}
 }
 
+func TestReadableFile_OpenAt(t *testing.T) {
+	dir := t.TempDir()
+	write(t, filepath.Join(dir, "file1.txt"), []byte("test1"))
+	writeGzip(t, filepath.Join(dir, "file2.gz"), []byte("test2"))
+
+	uncompressed := ReadableFile{
+		Metadata:    FileMetadata{Path: filepath.Join(dir, "file1.txt")},
+		Compression: compressionUncompressed,
+	}
+	compressed := ReadableFile{
+		Metadata:    FileMetadata{Path: filepath.Join(dir, "file2.gz")},
+		Compression: compressionGzip,
+	}
+
+	ctx := context.Background()
+
+	rc, err := uncompressed.OpenAt(ctx, 2)
+	if err != nil {
+		t.Fatalf("OpenAt() error = %v, want nil", err)
+	}
+	t.Cleanup(func() {
+		rc.Close()
+	})
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v, want nil", err)
+	}
+	if want := "st1"; string(got) != want {
+		t.Errorf("ReadAll() = %q, want %q", got, want)
+	}
+
+	if _, err := uncompressed.OpenAt(ctx, 10); err == nil {
+		t.Error("OpenAt() past the end of the file error = nil, want error")
+	}
+	if _, err := compressed.OpenAt(ctx, 2); err == nil {
+		t.Error("OpenAt() of compressed file error = nil, want error")
+	}
+}
+
+// rangeFS is a memfs file system that reads ranges of files, and records
+// the offsets that they are read from.
+type rangeFS struct {
+	filesystem.Interface
+	offsets *[]int64
+}
+
+func (f rangeFS) OpenReadRange(ctx context.Context, filename string, offset, length int64) (io.ReadCloser, error) {
+	*f.offsets = append(*f.offsets, offset)
+	rc, err := f.OpenRead(ctx, strings.Replace(filename, "rangefs://", "memfs://", 1))
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data[offset:])), nil
+}
+
+func TestReadableFile_OpenAt_RangeReader(t *testing.T) {
+	var offsets []int64
+	filesystem.Register("rangefs", func(ctx context.Context) filesystem.Interface {
+		return rangeFS{Interface: memfs.New(ctx), offsets: &offsets}
+	})
+	memfs.Write("memfs://range/file.txt", []byte("test1"))
+	file := ReadableFile{
+		Metadata:    FileMetadata{Path: "rangefs://range/file.txt"},
+		Compression: compressionUncompressed,
+	}
+
+	rc, err := file.OpenAt(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("OpenAt() error = %v, want nil", err)
+	}
+	defer rc.Close()
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v, want nil", err)
+	}
+	if want := "st1"; string(got) != want {
+		t.Errorf("ReadAll() = %q, want %q", got, want)
+	}
+	if want := []int64{2}; !cmp.Equal(offsets, want) {
+		t.Errorf("OpenReadRange() offsets = %v, want %v", offsets, want)
+	}
+}
+
 func Test_compressionFromExt(t *testing.T) {
 	tests := []struct {
 		name string
@@ -85,6 +178,16 @@ func Test_compressionFromExt(t *testing.T) {
 			path: "file.gz",
 			want: compressionGzip,
 		},
+		{
+			name: "zstd for zst extension",
+			path: "file.zst",
+			want: compressionZstd,
+		},
+		{
+			name: "bzip2 for bz2 extension",
+			path: "dir.bz2/file.csv.bz2",
+			want: compressionBzip2,
+		},
 		{
 			name: "compressionUncompressed for no extension",
 			path: "file",
@@ -0,0 +1,118 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileio
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
+)
+
+// Placeholders of filename templates.
+const (
+	shardPlaceholder     = "{shard}"
+	numShardsPlaceholder = "{n}"
+	windowPlaceholder    = "{window}"
+	panePlaceholder      = "{pane}"
+)
+
+var placeholderRegexp = regexp.MustCompile(`\{[^{}]*\}`)
+
+// fileNaming names the files written by WriteFiles. The filename is either a
+// template with placeholders, or a prefix to which the window, pane and shard
+// are appended as needed to make the filenames unique.
+type fileNaming struct {
+	Filename  string `json:"filename"`
+	Suffix    string `json:"suffix"`
+	NumShards int    `json:"numShards"`
+	// TempID identifies the temporary files of the transform that writes
+	// the files.
+	TempID string `json:"tempId"`
+}
+
+func (n fileNaming) isTemplate() bool {
+	return placeholderRegexp.MatchString(n.Filename)
+}
+
+func (n fileNaming) validate() error {
+	for _, p := range placeholderRegexp.FindAllString(n.Filename, -1) {
+		switch p {
+		case shardPlaceholder, numShardsPlaceholder, windowPlaceholder, panePlaceholder:
+		default:
+			return fmt.Errorf("unknown placeholder %v in filename %v", p, n.Filename)
+		}
+	}
+	if n.isTemplate() && n.NumShards != 1 && !strings.Contains(n.Filename, shardPlaceholder) {
+		return fmt.Errorf("filename %v must contain %v when writing more than one shard", n.Filename, shardPlaceholder)
+	}
+	return nil
+}
+
+// dir returns the directory of the files, in which the temporary files are
+// written.
+func (n fileNaming) dir() string {
+	prefix := n.Filename
+	if loc := placeholderRegexp.FindStringIndex(prefix); loc != nil {
+		prefix = prefix[:loc[0]]
+	}
+	return prefix[:strings.LastIndexAny(prefix, `/\`)+1]
+}
+
+// name returns the name of the given shard of the given pane of a window.
+// Panes are only distinguished if the window has several of them.
+func (n fileNaming) name(w typex.Window, pane typex.PaneInfo, shard, numShards int) string {
+	if n.isTemplate() {
+		r := strings.NewReplacer(
+			shardPlaceholder, fmt.Sprintf("%05d", shard),
+			numShardsPlaceholder, fmt.Sprintf("%05d", numShards),
+			windowPlaceholder, formatWindow(w),
+			panePlaceholder, fmt.Sprint(pane.Index),
+		)
+		return r.Replace(n.Filename) + n.Suffix
+	}
+
+	name := n.Filename
+	if _, ok := w.(window.GlobalWindow); !ok {
+		name += "-" + formatWindow(w)
+	}
+	if !pane.IsFirst || !pane.IsLast {
+		name += fmt.Sprintf("-pane-%d", pane.Index)
+	}
+	if n.NumShards != 1 {
+		name += fmt.Sprintf("-%05d-of-%05d", shard, numShards)
+	}
+	return name + n.Suffix
+}
+
+// formatWindow formats interval windows as their start and end times.
+func formatWindow(w typex.Window) string {
+	switch w := w.(type) {
+	case window.GlobalWindow:
+		return "global"
+	case window.IntervalWindow:
+		return formatTime(w.Start) + "-" + formatTime(w.End)
+	default:
+		return fmt.Sprint(w)
+	}
+}
+
+func formatTime(t typex.EventTime) string {
+	return mtime.Time(t).ToTime().UTC().Format("2006-01-02T15:04:05.000Z")
+}
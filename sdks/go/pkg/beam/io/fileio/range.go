@@ -0,0 +1,85 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileio
+
+import (
+	"math"
+	"reflect"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*FileRange)(nil)).Elem())
+	register.DoFn2x0[ReadableFile, func(FileRange)](&splitFn{})
+	register.Emitter1[FileRange]()
+}
+
+// DefaultBlockSize is the default size of the ranges that files are split
+// into to be read in parallel.
+const DefaultBlockSize int64 = 64 * 1024 * 1024 // 64 MB
+
+// FileRange is a range of a file that is read by a single worker. Readers of
+// formats whose records can't be split read the records that start within the
+// range, including the part of the last record past its end.
+type FileRange struct {
+	File ReadableFile
+	// Start is the offset in bytes of the start of the range, inclusive.
+	Start int64
+	// End is the offset in bytes of the end of the range, exclusive. The last
+	// range of a file ends at math.MaxInt64, so that it is read to the end of
+	// the file.
+	End int64
+}
+
+// SplitFile splits a file into ranges of about blockSize bytes. The last
+// range is merged into the previous one if it is smaller than a quarter of
+// blockSize. Compressed files can't be read from an offset, so they are a
+// single range.
+func SplitFile(file ReadableFile, blockSize int64) []FileRange {
+	size := file.Metadata.Size
+	if file.IsCompressed() || size <= blockSize {
+		return []FileRange{{File: file, Start: 0, End: math.MaxInt64}}
+	}
+
+	var ranges []FileRange
+	for start := int64(0); ; start += blockSize {
+		end := start + blockSize
+		if size-end <= blockSize/4 {
+			return append(ranges, FileRange{File: file, Start: start, End: math.MaxInt64})
+		}
+		ranges = append(ranges, FileRange{File: file, Start: start, End: end})
+	}
+}
+
+// SplitFiles splits the files of a PCollection<ReadableFile> with SplitFile,
+// and returns a PCollection<FileRange> of their ranges, reshuffled so that
+// they are read in parallel.
+func SplitFiles(s beam.Scope, col beam.PCollection, blockSize int64) beam.PCollection {
+	s = s.Scope("fileio.SplitFiles")
+	return beam.Reshuffle(s, beam.ParDo(s, &splitFn{BlockSize: blockSize}, col))
+}
+
+type splitFn struct {
+	BlockSize int64 `json:"blockSize"`
+}
+
+func (fn *splitFn) ProcessElement(file ReadableFile, emit func(FileRange)) {
+	for _, r := range SplitFile(file, fn.BlockSize) {
+		emit(r)
+	}
+}
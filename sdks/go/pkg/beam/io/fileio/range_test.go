@@ -0,0 +1,73 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileio
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSplitFile(t *testing.T) {
+	file := func(size int64, comp compressionType) ReadableFile {
+		return ReadableFile{
+			Metadata:    FileMetadata{Path: "file", Size: size},
+			Compression: comp,
+		}
+	}
+
+	tests := []struct {
+		name string
+		file ReadableFile
+		want [][2]int64
+	}{
+		{
+			name: "Small file",
+			file: file(100, compressionUncompressed),
+			want: [][2]int64{{0, math.MaxInt64}},
+		},
+		{
+			name: "Compressed file",
+			file: file(1000, compressionGzip),
+			want: [][2]int64{{0, math.MaxInt64}},
+		},
+		{
+			name: "Split file",
+			file: file(350, compressionUncompressed),
+			want: [][2]int64{{0, 100}, {100, 200}, {200, 300}, {300, math.MaxInt64}},
+		},
+		{
+			name: "Small remainder merged into last range",
+			file: file(320, compressionUncompressed),
+			want: [][2]int64{{0, 100}, {100, 200}, {200, math.MaxInt64}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][2]int64
+			for _, r := range SplitFile(tt.file, 100) {
+				if r.File != tt.file {
+					t.Errorf("SplitFile() range file = %v, want %v", r.File, tt.file)
+				}
+				got = append(got, [2]int64{r.Start, r.End})
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("SplitFile() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -61,6 +61,19 @@ func ReadGzip() ReadOptionFn {
 	}
 }
 
+// ReadCompression specifies that files have been compressed with the given
+// compression, such as CompressionZstd, which must be registered with
+// RegisterCompression.
+func ReadCompression(name string) ReadOptionFn {
+	return func(o *readOption) {
+		t, err := compressionByName(name)
+		if err != nil {
+			panic(fmt.Sprintf("fileio.ReadCompression: %v", err))
+		}
+		o.Compression = t
+	}
+}
+
 // ReadUncompressed specifies that files have not been compressed.
 func ReadUncompressed() ReadOptionFn {
 	return func(o *readOption) {
@@ -87,7 +100,8 @@ func ReadDirectoryDisallow() ReadOptionFn {
 // used to retrieve file metadata, open the file for reading or read the entire file into memory.
 // ReadMatches accepts a variadic number of ReadOptionFn that can be used to configure the
 // compression type of the files and treatment of directories. By default, the compression type is
-// determined by the file extension and directories are skipped.
+// determined by the file extension, among those of the registered compressions, and directories are
+// skipped.
 func ReadMatches(s beam.Scope, col beam.PCollection, opts ...ReadOptionFn) beam.PCollection {
 	s = s.Scope("fileio.ReadMatches")
 
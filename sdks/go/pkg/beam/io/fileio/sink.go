@@ -0,0 +1,77 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+)
+
+// Sink writes elements to a file in a specific format. A new Sink is used for
+// each file, so a Sink may keep the state of the file being written.
+//
+// Sinks are serialized as JSON, so their configuration must be held in
+// exported fields, and their type must be registered with beam.RegisterType.
+type Sink interface {
+	// Open starts writing a new file to w, such as by writing a header.
+	Open(ctx context.Context, w io.Writer) error
+	// Write writes an element to the file.
+	Write(ctx context.Context, elm any) error
+	// Flush writes any buffered data and trailer of the file. It must not
+	// close the writer passed to Open.
+	Flush(ctx context.Context) error
+}
+
+// encodedSink is a Sink serialized as JSON along with its type, so that it
+// can be a field of a DoFn.
+type encodedSink struct {
+	Type beam.EncodedType `json:"type"`
+	Data []byte           `json:"data"`
+}
+
+func encodeSink(sink Sink) (encodedSink, error) {
+	if sink == nil {
+		return encodedSink{}, errors.New("sink must not be nil")
+	}
+	data, err := json.Marshal(sink)
+	if err != nil {
+		return encodedSink{}, fmt.Errorf("encoding sink %T: %w", sink, err)
+	}
+	return encodedSink{Type: beam.EncodedType{T: reflect.TypeOf(sink)}, Data: data}, nil
+}
+
+// newSink returns a new Sink decoded from the encoded sink.
+func (e encodedSink) newSink() (Sink, error) {
+	t := e.Type.T
+	ptr := t.Kind() == reflect.Ptr
+	if ptr {
+		t = t.Elem()
+	}
+	v := reflect.New(t)
+	if err := json.Unmarshal(e.Data, v.Interface()); err != nil {
+		return nil, fmt.Errorf("decoding sink %v: %w", e.Type.T, err)
+	}
+	if !ptr {
+		v = v.Elem()
+	}
+	return v.Interface().(Sink), nil
+}
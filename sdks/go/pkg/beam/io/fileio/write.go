@@ -0,0 +1,516 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileio
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"reflect"
+	"sort"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/google/uuid"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*writeResult)(nil)).Elem())
+	register.DoFn1x2[beam.T, int, beam.T](&shardFn{})
+	register.DoFn6x1[context.Context, typex.PaneInfo, typex.Window, int, func(*beam.T) bool, func(writeResult), error](&writeFn{})
+	register.DoFn5x1[context.Context, typex.Window, int, func(*writeResult) bool, func(string), error](&finalizeFn{})
+	register.Emitter1[writeResult]()
+	register.Iter1[beam.T]()
+	register.Iter1[writeResult]()
+}
+
+type writeOption struct {
+	NumShards      int
+	Suffix         string
+	MaxOpenWriters int
+	Compression    compressionType
+}
+
+func newWriteOption() *writeOption {
+	return &writeOption{MaxOpenWriters: 20}
+}
+
+// suffix returns the suffix of the filenames, with the extension of the
+// compression if any. It returns an error if the compression can't be
+// written.
+func (o *writeOption) suffix() (string, error) {
+	codec, err := lookupWriteCodec(o.Compression)
+	if err != nil || codec == nil {
+		return o.Suffix, err
+	}
+	c, err := lookupCompression(o.Compression)
+	if err != nil {
+		return "", err
+	}
+	return o.Suffix + c.ext, nil
+}
+
+// WriteOptionFn is a function that can be passed to WriteFiles to configure
+// options for writing files.
+type WriteOptionFn func(*writeOption)
+
+// WriteNumShards specifies the number of files, or shards, written per window
+// and pane. Elements are spread evenly across the shards. By default, or if n
+// is zero, the number of shards is chosen by the runner, and there is a shard
+// per bundle of elements. With a fixed number of shards, the shards without
+// elements are written as empty files, in the windows and panes with
+// elements.
+func WriteNumShards(n int) WriteOptionFn {
+	return func(o *writeOption) {
+		o.NumShards = n
+	}
+}
+
+// WriteSuffix specifies a suffix, such as a file extension, that is appended
+// to the filenames.
+func WriteSuffix(suffix string) WriteOptionFn {
+	return func(o *writeOption) {
+		o.Suffix = suffix
+	}
+}
+
+// WriteCompression specifies that files are compressed with the given
+// compression, such as CompressionGzip, which must be registered with
+// RegisterCompression with a WriteCodec. The extension of the compression is appended to the
+// filenames, after any suffix.
+func WriteCompression(name string) WriteOptionFn {
+	return func(o *writeOption) {
+		t, err := compressionByName(name)
+		if err != nil {
+			panic(fmt.Sprintf("fileio.WriteCompression: %v", err))
+		}
+		o.Compression = t
+	}
+}
+
+// WriteMaxOpenWriters specifies the maximum number of files that WriteDynamic
+// keeps open at once while writing a shard. The elements of any further
+// destinations are grouped by destination and written afterwards. By default,
+// up to 20 files are kept open. It has no effect on WriteFiles.
+func WriteMaxOpenWriters(n int) WriteOptionFn {
+	return func(o *writeOption) {
+		o.MaxOpenWriters = n
+	}
+}
+
+// WriteFiles writes the elements of a PCollection<T> to files with the given
+// Sink, and returns a PCollection<string> of the names of the written files,
+// in the windows of the elements. WriteFiles accepts a variadic number of
+// WriteOptionFn that can be used to configure the sharding and the suffix of
+// the filenames.
+//
+// The filename is either a prefix or a template. A prefix is extended with
+// the window, if the elements are not in the global window, the pane index,
+// if a window has several panes, and the shard number and count, unless a
+// single shard is written. For example, with fixed windows and three shards:
+//
+//	out-2024-01-01T00:00:00.000Z-2024-01-01T01:00:00.000Z-00001-of-00003
+//
+// A template contains any of the following placeholders: {window} for the
+// window, {pane} for the pane index, {shard} for the shard number and {n}
+// for the number of shards. The template must contain {shard} unless a single
+// shard is written:
+//
+//	fileio.WriteFiles(s, "gs://bucket/out/{window}/part-{shard}-of-{n}", sink, col,
+//		fileio.WriteSuffix(".txt"))
+//
+// Each shard is first written to a temporary file in the directory of the
+// filename, and is only renamed to its final name, with filesystem.Renamer
+// or else filesystem.Copier, once all shards of its window and pane are
+// written. The temporary files of failed or retried writes of the window and
+// pane are then removed, if the filesystem implements filesystem.Remover.
+func WriteFiles(s beam.Scope, filename string, sink Sink, col beam.PCollection, opts ...WriteOptionFn) beam.PCollection {
+	s = s.Scope("fileio.WriteFiles")
+
+	filesystem.ValidateScheme(filename)
+	beam.ValidateNonCompositeType(col)
+
+	option := newWriteOption()
+	for _, opt := range opts {
+		opt(option)
+	}
+	if option.NumShards < 0 {
+		panic(fmt.Sprintf("fileio.WriteFiles: invalid number of shards %v", option.NumShards))
+	}
+	suffix, err := option.suffix()
+	if err != nil {
+		panic(fmt.Sprintf("fileio.WriteFiles: %v", err))
+	}
+	naming := fileNaming{Filename: filename, Suffix: suffix, NumShards: option.NumShards, TempID: uuid.NewString()}
+	if err := naming.validate(); err != nil {
+		panic(fmt.Sprintf("fileio.WriteFiles: %v", err))
+	}
+	enc, err := encodeSink(sink)
+	if err != nil {
+		panic(fmt.Sprintf("fileio.WriteFiles: %v", err))
+	}
+
+	sharded := beam.ParDo(s, &shardFn{NumShards: option.NumShards}, col)
+	results := beam.ParDo(s, &writeFn{Naming: naming, Compression: option.Compression, Sink: enc}, beam.GroupByKey(s, sharded))
+	grouped := beam.GroupByKey(s, beam.AddFixedKey(s, results))
+	return beam.ParDo(s, &finalizeFn{Naming: naming, Compression: option.Compression, Sink: enc}, grouped)
+}
+
+// shardFn assigns elements to shards. With a fixed number of shards, elements
+// are assigned round-robin from a random shard, and otherwise to a random
+// shard per bundle.
+type shardFn struct {
+	NumShards int `json:"numShards"`
+
+	shard int
+}
+
+func (fn *shardFn) StartBundle() {
+	if fn.NumShards > 0 {
+		fn.shard = rand.Intn(fn.NumShards)
+	} else {
+		fn.shard = rand.Int()
+	}
+}
+
+func (fn *shardFn) ProcessElement(elm beam.T) (int, beam.T) {
+	shard := fn.shard
+	if fn.NumShards > 0 {
+		fn.shard = (fn.shard + 1) % fn.NumShards
+	}
+	return shard, elm
+}
+
+// writeResult is a temporary file written by writeFn, or by WriteDynamic for
+// the given destination.
+type writeResult struct {
+	TempFile    string
+	Destination string
+	Shard       int
+	PaneIndex   int64
+	PaneFirst   bool
+	PaneLast    bool
+}
+
+func (r writeResult) pane() typex.PaneInfo {
+	return typex.PaneInfo{Index: r.PaneIndex, IsFirst: r.PaneFirst, IsLast: r.PaneLast}
+}
+
+// writeFn writes the elements of a shard to a temporary file.
+type writeFn struct {
+	Naming      fileNaming      `json:"naming"`
+	Compression compressionType `json:"compression"`
+	Sink        encodedSink     `json:"sink"`
+}
+
+func (fn *writeFn) ProcessElement(
+	ctx context.Context,
+	pane typex.PaneInfo,
+	w typex.Window,
+	shard int,
+	iter func(*beam.T) bool,
+	emit func(writeResult),
+) error {
+	tempFile := tempFilename(fn.Naming, w, pane.Index, "")
+	if err := writeFile(ctx, tempFile, fn.Compression, fn.Sink, iter); err != nil {
+		return fmt.Errorf("writing temporary file %v: %w", tempFile, err)
+	}
+
+	emit(writeResult{
+		TempFile:  tempFile,
+		Shard:     shard,
+		PaneIndex: pane.Index,
+		PaneFirst: pane.IsFirst,
+		PaneLast:  pane.IsLast,
+	})
+	return nil
+}
+
+// writeFile writes the elements to a file with the sink.
+func writeFile(ctx context.Context, filename string, comp compressionType, enc encodedSink, iter func(*beam.T) bool) error {
+	sink, err := enc.newSink()
+	if err != nil {
+		return err
+	}
+	w, err := openFileWriter(ctx, filename, comp, sink, nil, nil)
+	if err != nil {
+		return err
+	}
+	var elm beam.T
+	for iter(&elm) {
+		if err := w.Write(ctx, elm); err != nil {
+			w.abort()
+			return err
+		}
+	}
+	return w.Close(ctx)
+}
+
+// tempFilename returns a new temporary filename in the directory of the
+// files, for the files of the destination in a pane of a window.
+func tempFilename(naming fileNaming, w typex.Window, pane int64, destination string) string {
+	return tempPrefix(naming, w, pane, destination) + uuid.NewString()
+}
+
+// tempPrefix returns the prefix of the temporary filenames of the files of
+// the destination in a pane of a window, by which the temporary files left
+// by failed or retried writes are found.
+func tempPrefix(naming fileNaming, w typex.Window, pane int64, destination string) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\x00%d\x00%s", formatWindow(w), pane, destination)
+	return fmt.Sprintf("%s.temp-beam-%s-%016x-", naming.dir(), naming.TempID, h.Sum64())
+}
+
+// fileWriter writes a file with a Sink, between an optional header and
+// footer, and compresses it if a compression is given.
+type fileWriter struct {
+	fs     filesystem.Interface
+	fd     io.WriteCloser
+	buf    *bufio.Writer
+	zw     io.WriteCloser
+	w      io.Writer
+	sink   Sink
+	footer []byte
+}
+
+func openFileWriter(ctx context.Context, filename string, comp compressionType, sink Sink, header, footer []byte) (*fileWriter, error) {
+	codec, err := lookupWriteCodec(comp)
+	if err != nil {
+		return nil, err
+	}
+
+	fs, err := filesystem.New(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := fs.OpenWrite(ctx, filename)
+	if err != nil {
+		fs.Close()
+		return nil, err
+	}
+	w := &fileWriter{
+		fs:     fs,
+		fd:     fd,
+		buf:    bufio.NewWriterSize(fd, 1<<20), // use 1MB buffer
+		sink:   sink,
+		footer: footer,
+	}
+	w.w = w.buf
+	if codec != nil {
+		if w.zw, err = codec.NewWriter(w.buf); err != nil {
+			w.abort()
+			return nil, err
+		}
+		w.w = w.zw
+	}
+	if _, err := w.w.Write(header); err != nil {
+		w.abort()
+		return nil, err
+	}
+	if err := sink.Open(ctx, w.w); err != nil {
+		w.abort()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *fileWriter) Write(ctx context.Context, elm any) error {
+	return w.sink.Write(ctx, elm)
+}
+
+// Close flushes the sink, writes the footer and closes the file.
+func (w *fileWriter) Close(ctx context.Context) error {
+	defer w.fs.Close()
+	if err := w.sink.Flush(ctx); err != nil {
+		w.fd.Close()
+		return err
+	}
+	if _, err := w.w.Write(w.footer); err != nil {
+		w.fd.Close()
+		return err
+	}
+	if w.zw != nil {
+		if err := w.zw.Close(); err != nil {
+			w.fd.Close()
+			return err
+		}
+	}
+	if err := w.buf.Flush(); err != nil {
+		w.fd.Close()
+		return err
+	}
+	return w.fd.Close()
+}
+
+// abort closes the file without flushing it.
+func (w *fileWriter) abort() {
+	w.fd.Close()
+	w.fs.Close()
+}
+
+// finalizeFn renames the temporary files of a window to their final names.
+type finalizeFn struct {
+	Naming      fileNaming      `json:"naming"`
+	Compression compressionType `json:"compression"`
+	Sink        encodedSink     `json:"sink"`
+}
+
+func (fn *finalizeFn) ProcessElement(
+	ctx context.Context,
+	w typex.Window,
+	_ int,
+	iter func(*writeResult) bool,
+	emit func(string),
+) error {
+	panes := make(map[int64][]writeResult)
+	var r writeResult
+	for iter(&r) {
+		panes[r.PaneIndex] = append(panes[r.PaneIndex], r)
+	}
+	if len(panes) == 0 {
+		return nil
+	}
+
+	writeEmpty := func(ctx context.Context, tempFile string) error {
+		return writeFile(ctx, tempFile, fn.Compression, fn.Sink, func(*beam.T) bool { return false })
+	}
+	for _, results := range panes {
+		if err := finalize(ctx, fn.Naming, w, "", results, writeEmpty, emit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finalize moves the temporary files of the shards of a destination in a
+// pane of a window to their final names, and emits the final names. With a
+// fixed number of shards, the shards without elements are written as empty
+// files with writeEmpty. The temporary files left by failed or retried writes
+// of the pane are removed afterwards.
+func finalize(
+	ctx context.Context,
+	naming fileNaming,
+	w typex.Window,
+	destination string,
+	results []writeResult,
+	writeEmpty func(ctx context.Context, tempFile string) error,
+	emit func(string),
+) error {
+	fs, err := filesystem.New(ctx, naming.Filename)
+	if err != nil {
+		return err
+	}
+	defer fs.Close()
+
+	pane := results[0].pane()
+	if naming.NumShards > 0 {
+		written := make(map[int]bool)
+		for _, r := range results {
+			written[r.Shard] = true
+		}
+		for shard := 0; shard < naming.NumShards; shard++ {
+			if written[shard] {
+				continue
+			}
+			tempFile := tempFilename(naming, w, pane.Index, destination)
+			if err := writeEmpty(ctx, tempFile); err != nil {
+				return fmt.Errorf("writing temporary file %v: %w", tempFile, err)
+			}
+			r := results[0]
+			r.TempFile, r.Shard = tempFile, shard
+			results = append(results, r)
+		}
+	}
+
+	// Sort the shards so that the runner chosen shards are numbered
+	// deterministically on retries.
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Shard != results[j].Shard {
+			return results[i].Shard < results[j].Shard
+		}
+		return results[i].TempFile < results[j].TempFile
+	})
+	for i, r := range results {
+		shard, numShards := i, len(results)
+		if naming.NumShards > 0 {
+			shard, numShards = r.Shard, naming.NumShards
+		}
+		filename := naming.name(w, pane, shard, numShards)
+		if err := move(ctx, fs, r.TempFile, filename); err != nil {
+			return fmt.Errorf("finalizing %v: %w", filename, err)
+		}
+		emit(filename)
+	}
+
+	removeTempFiles(ctx, fs, tempPrefix(naming, w, pane.Index, destination))
+	return nil
+}
+
+// removeTempFiles removes the temporary files with the prefix. Failures are
+// only logged, since the files have no effect on the written files.
+func removeTempFiles(ctx context.Context, fs filesystem.Interface, prefix string) {
+	rm, ok := fs.(filesystem.Remover)
+	if !ok {
+		return
+	}
+	files, err := fs.List(ctx, prefix+"*")
+	if err != nil {
+		log.Warnf(ctx, "listing temporary files %v*: %v", prefix, err)
+		return
+	}
+	for _, file := range files {
+		log.Infof(ctx, "removing temporary file %v of a failed or retried write", file)
+		if err := rm.Remove(ctx, file); err != nil {
+			log.Warnf(ctx, "removing temporary file %v: %v", file, err)
+		}
+	}
+}
+
+// move moves the file at oldpath to newpath, with filesystem.Renamer if the
+// filesystem implements it, and otherwise by copying and removing the file.
+// The move is considered done if it was already done by a previous attempt.
+func move(ctx context.Context, fs filesystem.Interface, oldpath, newpath string) error {
+	err := moveFile(ctx, fs, oldpath, newpath)
+	if err == nil {
+		return nil
+	}
+	if _, oldErr := fs.Size(ctx, oldpath); oldErr != nil {
+		if _, newErr := fs.Size(ctx, newpath); newErr == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func moveFile(ctx context.Context, fs filesystem.Interface, oldpath, newpath string) error {
+	if _, ok := fs.(filesystem.Renamer); ok {
+		return filesystem.Rename(ctx, fs, oldpath, newpath)
+	}
+	if err := filesystem.Copy(ctx, fs, oldpath, newpath); err != nil {
+		return err
+	}
+	if rm, ok := fs.(filesystem.Remover); ok {
+		return rm.Remove(ctx, oldpath)
+	}
+	log.Warnf(ctx, "%T doesn't implement filesystem.Remover: leaving temporary file %v", fs, oldpath)
+	return nil
+}
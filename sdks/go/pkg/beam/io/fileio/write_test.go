@@ -0,0 +1,328 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*testSink)(nil)).Elem())
+	register.Function1x2(timestampFn)
+}
+
+// testSink writes a header followed by the elements as lines.
+type testSink struct {
+	Header string
+
+	w io.Writer
+}
+
+func (s *testSink) Open(_ context.Context, w io.Writer) error {
+	s.w = w
+	_, err := fmt.Fprintln(w, s.Header)
+	return err
+}
+
+func (s *testSink) Write(_ context.Context, elm any) error {
+	_, err := fmt.Fprintln(s.w, elm)
+	return err
+}
+
+func (s *testSink) Flush(_ context.Context) error {
+	return nil
+}
+
+func timestampFn(n int) (beam.EventTime, int) {
+	return mtime.FromMilliseconds(int64(n) * 1000), n
+}
+
+// readFiles returns the sorted lines of the files in the directory, without
+// the headers, keyed by filename.
+func readFiles(t *testing.T, dir string) map[string][]string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string][]string)
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		if lines[0] != "header" {
+			t.Errorf("file %v has header %q, want %q", e.Name(), lines[0], "header")
+		}
+		sort.Strings(lines[1:])
+		files[e.Name()] = lines[1:]
+	}
+	return files
+}
+
+func TestWriteFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		opts     []WriteOptionFn
+		want     func(files map[string][]string) error
+	}{
+		{
+			name:     "single shard",
+			filename: "out",
+			opts:     []WriteOptionFn{WriteNumShards(1), WriteSuffix(".txt")},
+			want: func(files map[string][]string) error {
+				if len(files["out.txt"]) != 9 {
+					return fmt.Errorf("got files %v, want out.txt with all elements", files)
+				}
+				return nil
+			},
+		},
+		{
+			name:     "fixed shards",
+			filename: "out",
+			opts:     []WriteOptionFn{WriteNumShards(3)},
+			want: func(files map[string][]string) error {
+				for name := range files {
+					if ok, _ := filepath.Match("out-0000[0-2]-of-00003", name); !ok {
+						return fmt.Errorf("got file %v, want out-0000[0-2]-of-00003", name)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name:     "empty shards",
+			filename: "out",
+			opts:     []WriteOptionFn{WriteNumShards(12)},
+			want: func(files map[string][]string) error {
+				if len(files) != 12 {
+					return fmt.Errorf("got files %v, want 12 shards", files)
+				}
+				return nil
+			},
+		},
+		{
+			name:     "runner shards",
+			filename: "part-{shard}-of-{n}",
+			want: func(files map[string][]string) error {
+				for name := range files {
+					if ok, _ := filepath.Match("part-0000?-of-0000?", name); !ok {
+						return fmt.Errorf("got file %v, want part-0000?-of-0000?", name)
+					}
+				}
+				return nil
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			p, s := beam.NewPipelineWithRoot()
+			col := beam.Create(s, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+			filenames := WriteFiles(s, filepath.Join(dir, test.filename), &testSink{Header: "header"}, col, test.opts...)
+			passert.NonEmpty(s, filenames)
+			ptest.RunAndValidate(t, p)
+
+			files := readFiles(t, dir)
+			if err := test.want(files); err != nil {
+				t.Error(err)
+			}
+			var got []string
+			for _, lines := range files {
+				got = append(got, lines...)
+			}
+			sort.Strings(got)
+			if want := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9"}; !cmp.Equal(got, want) {
+				t.Errorf("WriteFiles() wrote %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteFiles_Windowed(t *testing.T) {
+	dir := t.TempDir()
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.ParDo(s, timestampFn, beam.Create(s, 1, 2, 61, 62, 63))
+	col = beam.WindowInto(s, window.NewFixedWindows(time.Minute), col)
+	WriteFiles(s, filepath.Join(dir, "out"), &testSink{Header: "header"}, col, WriteNumShards(1))
+	ptest.RunAndValidate(t, p)
+
+	want := map[string][]string{
+		"out-1970-01-01T00:00:00.000Z-1970-01-01T00:01:00.000Z": {"1", "2"},
+		"out-1970-01-01T00:01:00.000Z-1970-01-01T00:02:00.000Z": {"61", "62", "63"},
+	}
+	if diff := cmp.Diff(want, readFiles(t, dir)); diff != "" {
+		t.Errorf("WriteFiles() wrote files diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestFinalize(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	naming := fileNaming{Filename: filepath.Join(dir, "out"), NumShards: 2, TempID: "id"}
+	pane := typex.NoFiringPane()
+	tempFile := func(pane int64) string {
+		t.Helper()
+		name := tempFilename(naming, window.GlobalWindow{}, pane, "")
+		if err := os.WriteFile(name, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return name
+	}
+	written := tempFile(pane.Index)
+	tempFile(pane.Index) // left by a failed write of the pane
+	otherPane := tempFile(pane.Index + 1)
+
+	results := []writeResult{{TempFile: written, Shard: 1, PaneIndex: pane.Index, PaneFirst: true, PaneLast: true}}
+	writeEmpty := func(_ context.Context, tempFile string) error {
+		return os.WriteFile(tempFile, nil, 0644)
+	}
+	var got []string
+	if err := finalize(ctx, naming, window.GlobalWindow{}, "", results, writeEmpty, func(name string) { got = append(got, filepath.Base(name)) }); err != nil {
+		t.Fatalf("finalize() failed: %v", err)
+	}
+	if want := []string{"out-00000-of-00002", "out-00001-of-00002"}; !cmp.Equal(got, want) {
+		t.Errorf("finalize() emitted %v, want %v", got, want)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]int)
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[e.Name()] = int(info.Size())
+	}
+	want := map[string]int{
+		"out-00000-of-00002":     0,
+		"out-00001-of-00002":     4,
+		filepath.Base(otherPane): 4,
+	}
+	if diff := cmp.Diff(want, files); diff != "" {
+		t.Errorf("finalize() left files diff (-want, +got):\n%v", diff)
+	}
+}
+
+func TestWriteFiles_Compression(t *testing.T) {
+	dir := t.TempDir()
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Create(s, 1, 2, 3)
+	WriteFiles(s, filepath.Join(dir, "out"), &testSink{Header: "header"}, col,
+		WriteNumShards(1), WriteSuffix(".txt"), WriteCompression(CompressionZstd))
+	ptest.RunAndValidate(t, p)
+
+	file := ReadableFile{Metadata: FileMetadata{Path: filepath.Join(dir, "out.txt.zst")}}
+	got, err := file.ReadString(context.Background())
+	if err != nil {
+		t.Fatalf("ReadString() failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	sort.Strings(lines)
+	if want := []string{"1", "2", "3", "header"}; !cmp.Equal(lines, want) {
+		t.Errorf("WriteFiles() wrote %v, want %v", lines, want)
+	}
+}
+
+func TestWriteFiles_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		sink     Sink
+		opts     []WriteOptionFn
+	}{
+		{"unknown placeholder", "out-{foo}", &testSink{}, nil},
+		{"missing shard", "out-{window}", &testSink{}, []WriteOptionFn{WriteNumShards(2)}},
+		{"negative shards", "out", &testSink{}, []WriteOptionFn{WriteNumShards(-1)}},
+		{"nil sink", "out", nil, nil},
+		{"unknown compression", "out", &testSink{}, []WriteOptionFn{WriteCompression("unknown")}},
+		{"read-only compression", "out", &testSink{}, []WriteOptionFn{WriteCompression(compressionReadOnly)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("WriteFiles() succeeded, want panic")
+				}
+			}()
+			_, s := beam.NewPipelineWithRoot()
+			WriteFiles(s, test.filename, test.sink, beam.Create(s, 1), test.opts...)
+		})
+	}
+}
+
+func TestFileNaming(t *testing.T) {
+	w := window.IntervalWindow{Start: 0, End: 60000}
+	onlyPane := typex.NoFiringPane()
+	latePane := typex.PaneInfo{Index: 2, Timing: typex.PaneLate}
+	tests := []struct {
+		naming fileNaming
+		w      typex.Window
+		pane   typex.PaneInfo
+		want   string
+	}{
+		{fileNaming{Filename: "out", NumShards: 1}, window.GlobalWindow{}, onlyPane, "out"},
+		{fileNaming{Filename: "out", Suffix: ".txt"}, window.GlobalWindow{}, onlyPane, "out-00001-of-00003.txt"},
+		{fileNaming{Filename: "out", NumShards: 1}, w, latePane, "out-1970-01-01T00:00:00.000Z-1970-01-01T00:01:00.000Z-pane-2"},
+		{fileNaming{Filename: "{window}/{pane}/{shard}-of-{n}"}, w, latePane, "1970-01-01T00:00:00.000Z-1970-01-01T00:01:00.000Z/2/00001-of-00003"},
+		{fileNaming{Filename: "{window}/out", NumShards: 1}, window.GlobalWindow{}, onlyPane, "global/out"},
+	}
+	for _, test := range tests {
+		if got := test.naming.name(test.w, test.pane, 1, 3); got != test.want {
+			t.Errorf("%+v.name(%v, %+v, 1, 3) = %v, want %v", test.naming, test.w, test.pane, got, test.want)
+		}
+	}
+}
+
+func TestFileNaming_Dir(t *testing.T) {
+	tests := []struct {
+		filename, want string
+	}{
+		{"out", ""},
+		{"/tmp/out", "/tmp/"},
+		{"gs://bucket/path/{window}/out-{shard}", "gs://bucket/path/"},
+		{"memfs://out", "memfs://"},
+	}
+	for _, test := range tests {
+		if got := (fileNaming{Filename: test.filename}).dir(); got != test.want {
+			t.Errorf("dir() of %v = %v, want %v", test.filename, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package azure contains an Azure Blob Storage implementation of the Beam file
+// system, which also serves Azure Data Lake Storage Gen2 accounts.
+//
+// Paths are either of the form 'az://container/blob', which refer to the
+// account configured in the environment, or of the form
+// 'abfs://container@account.dfs.core.windows.net/blob' (or 'abfss://'), which
+// name the account explicitly.
+//
+// Credentials are resolved from the environment in the following order:
+//   - AZURE_STORAGE_CONNECTION_STRING, for the account of the connection
+//     string. This is how the file system is pointed at Azurite.
+//   - AZURE_STORAGE_ACCOUNT and AZURE_STORAGE_KEY, for that account.
+//   - The default Azure credential chain, for any account.
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/util/fsx"
+)
+
+const (
+	connectionStringEnv = "AZURE_STORAGE_CONNECTION_STRING"
+	accountEnv          = "AZURE_STORAGE_ACCOUNT"
+	keyEnv              = "AZURE_STORAGE_KEY"
+
+	// folderMetadataKey marks the blobs representing directories in accounts
+	// with a hierarchical namespace.
+	folderMetadataKey = "hdi_isfolder"
+	// copyPollInterval is the interval to poll the status of pending copies at.
+	copyPollInterval = 500 * time.Millisecond
+	// copySASExpiry is how long the shared access signature that authorizes
+	// the service to read the source of a copy is valid for.
+	copySASExpiry = time.Hour
+)
+
+func init() {
+	for _, scheme := range []string{"az", "abfs", "abfss"} {
+		filesystem.Register(scheme, New)
+	}
+}
+
+type fs struct {
+	newClient func(account string) (*azblob.Client, error)
+
+	mu      sync.Mutex
+	clients map[string]*azblob.Client
+}
+
+// New creates a new Azure Blob Storage filesystem using credentials from the
+// environment. Clients are created lazily for each storage account accessed.
+func New(_ context.Context) filesystem.Interface {
+	return &fs{newClient: newClientFromEnv}
+}
+
+// newClientFromEnv creates a client for the storage account using credentials
+// from the environment. An empty account refers to the default account, which
+// is either the account of the connection string or the AZURE_STORAGE_ACCOUNT.
+func newClientFromEnv(account string) (*azblob.Client, error) {
+	if cs := os.Getenv(connectionStringEnv); cs != "" {
+		if account == "" || account == connectionStringAccount(cs) {
+			return azblob.NewClientFromConnectionString(cs, nil)
+		}
+	}
+
+	defaultAccount := os.Getenv(accountEnv)
+	if account == "" {
+		if defaultAccount == "" {
+			return nil, fmt.Errorf("no storage account: set %s or %s, or use an abfs uri", connectionStringEnv, accountEnv)
+		}
+		account = defaultAccount
+	}
+	serviceURL := fmt.Sprintf("https://%s.blob.core.windows.net/", account)
+
+	if key := os.Getenv(keyEnv); key != "" && account == defaultAccount {
+		cred, err := azblob.NewSharedKeyCredential(account, key)
+		if err != nil {
+			return nil, fmt.Errorf("error creating shared key credential: %v", err)
+		}
+		return azblob.NewClientWithSharedKeyCredential(serviceURL, cred, nil)
+	}
+
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating default Azure credential: %v", err)
+	}
+	return azblob.NewClient(serviceURL, cred, nil)
+}
+
+// connectionStringAccount returns the account name of the connection string.
+func connectionStringAccount(cs string) string {
+	for _, part := range strings.Split(cs, ";") {
+		if k, v, ok := strings.Cut(part, "="); ok && strings.EqualFold(k, "AccountName") {
+			return v
+		}
+	}
+	return ""
+}
+
+// client returns the client for the storage account, creating it on first use.
+func (f *fs) client(account string) (*azblob.Client, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if c, ok := f.clients[account]; ok {
+		return c, nil
+	}
+	c, err := f.newClient(account)
+	if err != nil {
+		return nil, fmt.Errorf("error creating client for storage account %q: %v", account, err)
+	}
+	if f.clients == nil {
+		f.clients = make(map[string]*azblob.Client)
+	}
+	f.clients[account] = c
+	return c, nil
+}
+
+// blobClient returns the client for the blob at the location.
+func (f *fs) blobClient(loc location) (*blob.Client, error) {
+	c, err := f.client(loc.account)
+	if err != nil {
+		return nil, err
+	}
+	return c.ServiceClient().NewContainerClient(loc.container).NewBlobClient(loc.blob), nil
+}
+
+// Close closes the filesystem.
+func (f *fs) Close() error {
+	return nil
+}
+
+// List returns a slice of the files in the filesystem that match the glob pattern.
+func (f *fs) List(ctx context.Context, glob string) ([]string, error) {
+	loc, err := parseURI(glob)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Azure uri %s: %v", glob, err)
+	}
+	c, err := f.client(loc.account)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := fsx.GetPrefix(loc.blob)
+	pager := c.NewListBlobsFlatPager(loc.container, &azblob.ListBlobsFlatOptions{
+		Prefix:  &prefix,
+		Include: container.ListBlobsInclude{Metadata: true},
+	})
+
+	var uris []string
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing blobs of %s: %v", glob, err)
+		}
+
+		for _, item := range page.Segment.BlobItems {
+			if item.Name == nil || isFolder(item.Metadata) {
+				continue
+			}
+			match, err := path.Match(loc.blob, *item.Name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid blob pattern: %s", loc.blob)
+			}
+			if match {
+				uris = append(uris, loc.withBlob(*item.Name).String())
+			}
+		}
+	}
+	return uris, nil
+}
+
+// isFolder reports whether the metadata marks the blob as a directory.
+func isFolder(metadata map[string]*string) bool {
+	for k, v := range metadata {
+		if strings.EqualFold(k, folderMetadataKey) && v != nil && strings.EqualFold(*v, "true") {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenRead returns a new io.ReadCloser to read contents from the file. The caller must call Close
+// on the returned io.ReadCloser when done reading.
+func (f *fs) OpenRead(ctx context.Context, filename string) (io.ReadCloser, error) {
+	return f.OpenReadRange(ctx, filename, 0, -1)
+}
+
+// OpenReadRange returns a new io.ReadCloser to read length bytes from the offset of the file, or
+// the rest of the file if length is negative. The caller must call Close on the returned
+// io.ReadCloser when done reading.
+func (f *fs) OpenReadRange(ctx context.Context, filename string, offset, length int64) (io.ReadCloser, error) {
+	loc, err := parseURI(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Azure uri %s: %v", filename, err)
+	}
+	if length == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	bc, err := f.blobClient(loc)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &blob.DownloadStreamOptions{}
+	if offset > 0 || length > 0 {
+		// A zero count reads to the end of the blob.
+		opts.Range = blob.HTTPRange{Offset: offset, Count: max(length, 0)}
+	}
+	resp, err := bc.DownloadStream(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading blob %s: %v", filename, err)
+	}
+	return resp.Body, nil
+}
+
+// OpenWrite returns a new io.WriteCloser to write contents to the file. The content is uploaded
+// in blocks as it is written, and committed when the returned io.WriteCloser is closed.
+func (f *fs) OpenWrite(ctx context.Context, filename string) (io.WriteCloser, error) {
+	loc, err := parseURI(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Azure uri %s: %v", filename, err)
+	}
+	c, err := f.client(loc.account)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	w := &writer{pw: pw, done: make(chan error, 1)}
+	go func() {
+		_, err := c.UploadStream(ctx, loc.container, loc.blob, pr, nil)
+		if err != nil {
+			err = fmt.Errorf("error uploading blob %s: %v", filename, err)
+		}
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w, nil
+}
+
+// writer writes to a blob through a pipe to an upload running in the
+// background.
+type writer struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+// Write writes p to the upload of the blob.
+func (w *writer) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+// Close finishes the upload of the blob and waits for it to be committed.
+func (w *writer) Close() error {
+	if err := w.pw.Close(); err != nil {
+		return err
+	}
+	return <-w.done
+}
+
+// Size returns the size of the file.
+func (f *fs) Size(ctx context.Context, filename string) (int64, error) {
+	props, err := f.properties(ctx, filename)
+	if err != nil {
+		return -1, err
+	}
+	if props.ContentLength == nil {
+		return -1, fmt.Errorf("content length for blob %s was nil", filename)
+	}
+	return *props.ContentLength, nil
+}
+
+// LastModified returns the time at which the file was last modified.
+func (f *fs) LastModified(ctx context.Context, filename string) (time.Time, error) {
+	props, err := f.properties(ctx, filename)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if props.LastModified == nil {
+		return time.Time{}, fmt.Errorf("last modified time for blob %s was nil", filename)
+	}
+	return *props.LastModified, nil
+}
+
+// properties returns the properties of the blob.
+func (f *fs) properties(ctx context.Context, filename string) (blob.GetPropertiesResponse, error) {
+	loc, err := parseURI(filename)
+	if err != nil {
+		return blob.GetPropertiesResponse{}, fmt.Errorf("error parsing Azure uri %s: %v", filename, err)
+	}
+	bc, err := f.blobClient(loc)
+	if err != nil {
+		return blob.GetPropertiesResponse{}, err
+	}
+
+	props, err := bc.GetProperties(ctx, nil)
+	if err != nil {
+		return blob.GetPropertiesResponse{}, fmt.Errorf("error getting properties of blob %s: %v", filename, err)
+	}
+	return props, nil
+}
+
+// Remove removes the file from the filesystem.
+func (f *fs) Remove(ctx context.Context, filename string) error {
+	loc, err := parseURI(filename)
+	if err != nil {
+		return fmt.Errorf("error parsing Azure uri %s: %v", filename, err)
+	}
+	bc, err := f.blobClient(loc)
+	if err != nil {
+		return err
+	}
+
+	if _, err := bc.Delete(ctx, nil); err != nil {
+		return fmt.Errorf("error deleting blob %s: %v", filename, err)
+	}
+	return nil
+}
+
+// Copy copies the file from the old path to the new path, waiting for the copy to complete. The
+// blob is copied by the service if the client of the source can sign a shared access signature
+// that lets the service read it, which requires a shared key credential. Otherwise, the blob is
+// read and written by the client.
+func (f *fs) Copy(ctx context.Context, oldpath, newpath string) error {
+	src, err := parseURI(oldpath)
+	if err != nil {
+		return fmt.Errorf("error parsing Azure source uri %s: %v", oldpath, err)
+	}
+	dst, err := parseURI(newpath)
+	if err != nil {
+		return fmt.Errorf("error parsing Azure destination uri %s: %v", newpath, err)
+	}
+	srcClient, err := f.blobClient(src)
+	if err != nil {
+		return err
+	}
+	dstClient, err := f.blobClient(dst)
+	if err != nil {
+		return err
+	}
+
+	srcURL, err := srcClient.GetSASURL(sas.BlobPermissions{Read: true}, time.Now().Add(copySASExpiry), nil)
+	if errors.Is(err, bloberror.MissingSharedKeyCredential) {
+		return f.streamCopy(ctx, oldpath, newpath)
+	}
+	if err != nil {
+		return fmt.Errorf("error signing source blob %s for copy: %v", oldpath, err)
+	}
+	resp, err := dstClient.StartCopyFromURL(ctx, srcURL, nil)
+	if err != nil {
+		return fmt.Errorf("error copying blob %s to %s: %v", oldpath, newpath, err)
+	}
+
+	status := resp.CopyStatus
+	for status != nil && *status == blob.CopyStatusTypePending {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(copyPollInterval):
+		}
+
+		props, err := dstClient.GetProperties(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("error getting copy status of blob %s: %v", newpath, err)
+		}
+		status = props.CopyStatus
+	}
+	if status != nil && *status != blob.CopyStatusTypeSuccess {
+		return fmt.Errorf("error copying blob %s to %s: copy status %s", oldpath, newpath, *status)
+	}
+	return nil
+}
+
+// streamCopy copies the file from the old path to the new path by reading and writing it.
+func (f *fs) streamCopy(ctx context.Context, oldpath, newpath string) error {
+	r, err := f.OpenRead(ctx, oldpath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := f.OpenWrite(ctx, newpath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return fmt.Errorf("error copying blob %s to %s: %v", oldpath, newpath, err)
+	}
+	return w.Close()
+}
+
+// Rename moves the file from the old path to the new path, by copying it and removing the old
+// path.
+func (f *fs) Rename(ctx context.Context, oldpath, newpath string) error {
+	if err := f.Copy(ctx, oldpath, newpath); err != nil {
+		return err
+	}
+	return f.Remove(ctx, oldpath)
+}
+
+// Compile time check for interface implementations.
+var (
+	_ filesystem.LastModifiedGetter = (*fs)(nil)
+	_ filesystem.RangeReader        = (*fs)(nil)
+	_ filesystem.Remover            = (*fs)(nil)
+	_ filesystem.Copier             = (*fs)(nil)
+	_ filesystem.Renamer            = (*fs)(nil)
+)
@@ -0,0 +1,494 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAzure_FilesystemNew(t *testing.T) {
+	ctx := context.Background()
+	for _, path := range []string{
+		"az://container/file.txt",
+		"abfs://container@account.dfs.core.windows.net/file.txt",
+		"abfss://container@account.dfs.core.windows.net/file.txt",
+	} {
+		fileSystem, err := filesystem.New(ctx, path)
+		if err != nil {
+			t.Errorf("filesystem.New(%q) error = %v, want %v", path, err, nil)
+			continue
+		}
+		if _, ok := fileSystem.(*fs); !ok {
+			t.Errorf("filesystem.New(%q) got type = %T, want %T", path, fileSystem, &fs{})
+		}
+		if err := fileSystem.Close(); err != nil {
+			t.Errorf("filesystem.Close() error = %v, want %v", err, nil)
+		}
+	}
+}
+
+func Test_newClientFromEnv(t *testing.T) {
+	t.Setenv(connectionStringEnv, newServer(t, newFakeBlobService("container")))
+	t.Setenv(accountEnv, "")
+
+	if _, err := newClientFromEnv(""); err != nil {
+		t.Errorf("newClientFromEnv() for the default account error = %v, want %v", err, nil)
+	}
+	if _, err := newClientFromEnv(testAccount); err != nil {
+		t.Errorf("newClientFromEnv() for the connection string account error = %v, want %v", err, nil)
+	}
+
+	t.Setenv(connectionStringEnv, "")
+	if _, err := newClientFromEnv(""); err == nil {
+		t.Error("newClientFromEnv() without a default account succeeded, want error")
+	}
+}
+
+func Test_fs_List(t *testing.T) {
+	tests := []struct {
+		name    string
+		glob    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "List match with full path",
+			glob: "az://container/file-1.txt",
+			want: []string{"az://container/file-1.txt"},
+		},
+		{
+			name: "List matches with wildcard",
+			glob: "az://container/*.txt",
+			want: []string{"az://container/file-1.txt", "az://container/file-2.txt"},
+		},
+		{
+			name: "List matches in directory",
+			glob: "abfs://container@devstoreaccount1.dfs.core.windows.net/dir/*",
+			want: []string{"abfs://container@devstoreaccount1.dfs.core.windows.net/dir/file-4.txt"},
+		},
+		{
+			name: "List no matches",
+			glob: "az://container/*.json",
+			want: nil,
+		},
+		{
+			name:    "Error: invalid Azure uri",
+			glob:    "container/without/scheme",
+			wantErr: true,
+		},
+		{
+			name:    "Error: container does not exist",
+			glob:    "az://non-existing-container/file-1.txt",
+			wantErr: true,
+		},
+		{
+			name:    "Error: invalid glob pattern",
+			glob:    "az://container/*file-[].txt",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newFakeBlobService("container")
+			content := []byte("content")
+			for _, name := range []string{"file-1.txt", "file-2.txt", "file-3.csv", "dir/file-4.txt"} {
+				service.putBlob("container", name, content)
+			}
+			service.putFolder("container", "dir/sub")
+
+			fileSystem := newTestFS(t, newServer(t, service))
+			got, err := fileSystem.List(ctx, tt.glob)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("List() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !cmp.Equal(got, tt.want) {
+				t.Errorf("List() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fs_OpenRead(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		wantErr  bool
+	}{
+		{
+			name:     "Open and read blob",
+			filename: "az://container/file.txt",
+		},
+		{
+			name:     "Error: invalid Azure uri",
+			filename: "container/without/scheme",
+			wantErr:  true,
+		},
+		{
+			name:     "Error: blob does not exist",
+			filename: "az://container/non-existing-file.txt",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newFakeBlobService("container")
+			content := []byte("content")
+			service.putBlob("container", "file.txt", content)
+
+			fileSystem := newTestFS(t, newServer(t, service))
+			reader, err := fileSystem.OpenRead(ctx, tt.filename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OpenRead() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			defer reader.Close()
+			if err := iotest.TestReader(reader, content); err != nil {
+				t.Errorf("TestReader() error = %v, want %v", err, nil)
+			}
+		})
+	}
+}
+
+func Test_fs_OpenReadRange(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset int64
+		length int64
+		want   string
+	}{
+		{name: "Range within the blob", offset: 2, length: 3, want: "234"},
+		{name: "Rest of the blob", offset: 6, length: -1, want: "6789"},
+		{name: "Range past the end of the blob", offset: 8, length: 5, want: "89"},
+		{name: "Empty range", offset: 4, length: 0, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newFakeBlobService("container")
+			service.putBlob("container", "file.txt", []byte("0123456789"))
+
+			fileSystem := newTestFS(t, newServer(t, service))
+			reader, err := fileSystem.OpenReadRange(ctx, "az://container/file.txt", tt.offset, tt.length)
+			if err != nil {
+				t.Fatalf("OpenReadRange() error = %v, want %v", err, nil)
+			}
+			defer reader.Close()
+
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v, want %v", err, nil)
+			}
+			if string(got) != tt.want {
+				t.Errorf("OpenReadRange() read %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fs_OpenWrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		content  []byte
+		wantErr  bool
+	}{
+		{
+			name:     "Open and write blob",
+			filename: "az://container/file.txt",
+			content:  []byte("content"),
+		},
+		{
+			name:     "Open and write empty blob",
+			filename: "az://container/file.txt",
+			content:  []byte{},
+		},
+		{
+			name:     "Open and write blob of multiple blocks",
+			filename: "az://container/file.txt",
+			content:  bytes.Repeat([]byte("0123456789"), 300_000),
+		},
+		{
+			name:     "Error: invalid Azure uri",
+			filename: "container/without/scheme",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newFakeBlobService("container")
+
+			fileSystem := newTestFS(t, newServer(t, service))
+			writer, err := fileSystem.OpenWrite(ctx, tt.filename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OpenWrite() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if _, err := writer.Write(tt.content); err != nil {
+				t.Fatalf("Write() error = %v, want %v", err, nil)
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("Close() error = %v, want %v", err, nil)
+			}
+
+			got, ok := service.getBlob("container", "file.txt")
+			if !ok {
+				t.Fatal("blob does not exist after closing the writer")
+			}
+			if !bytes.Equal(got, tt.content) {
+				t.Errorf("blob content = %q, want %q", got, tt.content)
+			}
+		})
+	}
+}
+
+func Test_fs_OpenWrite_error(t *testing.T) {
+	ctx := context.Background()
+	service := newFakeBlobService("container")
+
+	fileSystem := newTestFS(t, newServer(t, service))
+	writer, err := fileSystem.OpenWrite(ctx, "az://non-existing-container/file.txt")
+	if err != nil {
+		t.Fatalf("OpenWrite() error = %v, want %v", err, nil)
+	}
+
+	writer.Write([]byte("content"))
+	if err := writer.Close(); err == nil {
+		t.Error("Close() of a writer to a non-existing container succeeded, want error")
+	}
+}
+
+func Test_fs_Size(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		want     int64
+		wantErr  bool
+	}{
+		{
+			name:     "Size of blob",
+			filename: "az://container/file.txt",
+			want:     7,
+		},
+		{
+			name:     "Error: invalid Azure uri",
+			filename: "container/without/scheme",
+			want:     -1,
+			wantErr:  true,
+		},
+		{
+			name:     "Error: blob does not exist",
+			filename: "az://container/non-existing-file.txt",
+			want:     -1,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newFakeBlobService("container")
+			service.putBlob("container", "file.txt", []byte("content"))
+
+			fileSystem := newTestFS(t, newServer(t, service))
+			got, err := fileSystem.Size(ctx, tt.filename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Size() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Size() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fs_LastModified(t *testing.T) {
+	ctx := context.Background()
+	service := newFakeBlobService("container")
+	before := time.Now().Truncate(time.Second)
+	service.putBlob("container", "file.txt", []byte("content"))
+
+	fileSystem := newTestFS(t, newServer(t, service))
+	got, err := fileSystem.LastModified(ctx, "az://container/file.txt")
+	if err != nil {
+		t.Fatalf("LastModified() error = %v, want %v", err, nil)
+	}
+	if got.Before(before) || got.After(time.Now()) {
+		t.Errorf("LastModified() got = %v, want between %v and now", got, before)
+	}
+
+	if _, err := fileSystem.LastModified(ctx, "az://container/non-existing-file.txt"); err == nil {
+		t.Error("LastModified() of a non-existing blob succeeded, want error")
+	}
+}
+
+func Test_fs_Remove(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		wantErr  bool
+	}{
+		{
+			name:     "Remove blob",
+			filename: "az://container/file.txt",
+		},
+		{
+			name:     "Error: invalid Azure uri",
+			filename: "container/without/scheme",
+			wantErr:  true,
+		},
+		{
+			name:     "Error: blob does not exist",
+			filename: "az://container/non-existing-file.txt",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newFakeBlobService("container")
+			service.putBlob("container", "file.txt", []byte("content"))
+
+			fileSystem := newTestFS(t, newServer(t, service))
+			if err := fileSystem.Remove(ctx, tt.filename); (err != nil) != tt.wantErr {
+				t.Fatalf("Remove() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if _, ok := service.getBlob("container", "file.txt"); ok {
+				t.Error("blob exists after Remove()")
+			}
+		})
+	}
+}
+
+func Test_fs_Copy(t *testing.T) {
+	tests := []struct {
+		name          string
+		oldpath       string
+		newpath       string
+		pendingCopies bool
+		noKey         bool
+		wantErr       bool
+	}{
+		{
+			name:    "Copy blob",
+			oldpath: "az://container/file.txt",
+			newpath: "az://container/copy.txt",
+		},
+		{
+			name:    "Copy blob without a shared key",
+			oldpath: "az://container/file.txt",
+			newpath: "az://container/copy.txt",
+			noKey:   true,
+		},
+		{
+			name:          "Copy blob and wait for pending copy",
+			oldpath:       "az://container/file.txt",
+			newpath:       "az://container/copy.txt",
+			pendingCopies: true,
+		},
+		{
+			name:    "Error: invalid source uri",
+			oldpath: "container/without/scheme",
+			newpath: "az://container/copy.txt",
+			wantErr: true,
+		},
+		{
+			name:    "Error: invalid destination uri",
+			oldpath: "az://container/file.txt",
+			newpath: "container/without/scheme",
+			wantErr: true,
+		},
+		{
+			name:    "Error: source does not exist",
+			oldpath: "az://container/non-existing-file.txt",
+			newpath: "az://container/copy.txt",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newFakeBlobService("container")
+			service.pendingCopies = tt.pendingCopies
+			content := []byte("content")
+			service.putBlob("container", "file.txt", content)
+
+			fileSystem := newTestFS(t, newServer(t, service))
+			if tt.noKey {
+				fileSystem = newNoKeyTestFS(t, newServer(t, service))
+			}
+			if err := fileSystem.Copy(ctx, tt.oldpath, tt.newpath); (err != nil) != tt.wantErr {
+				t.Fatalf("Copy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if _, ok := service.getBlob("container", "file.txt"); !ok {
+				t.Error("source blob does not exist after Copy()")
+			}
+			got, ok := service.getBlob("container", "copy.txt")
+			if !ok {
+				t.Fatal("destination blob does not exist after Copy()")
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("destination blob content = %q, want %q", got, content)
+			}
+		})
+	}
+}
+
+func Test_fs_Rename(t *testing.T) {
+	ctx := context.Background()
+	service := newFakeBlobService("container")
+	content := []byte("content")
+	service.putBlob("container", "file.txt", content)
+
+	fileSystem := newTestFS(t, newServer(t, service))
+	if err := fileSystem.Rename(ctx, "az://container/file.txt", "az://container/dir/renamed.txt"); err != nil {
+		t.Fatalf("Rename() error = %v, want %v", err, nil)
+	}
+
+	if _, ok := service.getBlob("container", "file.txt"); ok {
+		t.Error("source blob exists after Rename()")
+	}
+	got, ok := service.getBlob("container", "dir/renamed.txt")
+	if !ok {
+		t.Fatal("destination blob does not exist after Rename()")
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("destination blob content = %q, want %q", got, content)
+	}
+}
@@ -0,0 +1,296 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+)
+
+const (
+	// testAccount and testKey are the well-known development account of Azurite.
+	testAccount = "devstoreaccount1"
+	testKey     = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+// fakeBlob is a blob stored in a fakeBlobService.
+type fakeBlob struct {
+	content      []byte
+	lastModified time.Time
+	folder       bool
+	copyStatus   string
+}
+
+// fakeBlobService is an in-memory stand-in for the subset of the Blob service
+// REST API used by the file system, serving a single account at the path
+// style endpoints used by Azurite.
+type fakeBlobService struct {
+	mu         sync.Mutex
+	containers map[string]map[string]*fakeBlob
+	blocks     map[string][]byte
+	// pendingCopies is whether copies are reported as pending until the
+	// properties of the destination are read.
+	pendingCopies bool
+}
+
+func newFakeBlobService(containers ...string) *fakeBlobService {
+	s := &fakeBlobService{
+		containers: make(map[string]map[string]*fakeBlob),
+		blocks:     make(map[string][]byte),
+	}
+	for _, c := range containers {
+		s.containers[c] = make(map[string]*fakeBlob)
+	}
+	return s
+}
+
+// newServer starts a server for the fake blob service and returns a connection
+// string for it, as for Azurite.
+func newServer(t *testing.T, s *fakeBlobService) string {
+	t.Helper()
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return fmt.Sprintf(
+		"DefaultEndpointsProtocol=http;AccountName=%s;AccountKey=%s;BlobEndpoint=%s/%s;",
+		testAccount, testKey, server.URL, testAccount,
+	)
+}
+
+// newTestFS creates a file system using the connection string for all accounts.
+func newTestFS(t *testing.T, connectionString string) *fs {
+	t.Helper()
+	return &fs{
+		newClient: func(string) (*azblob.Client, error) {
+			return azblob.NewClientFromConnectionString(connectionString, nil)
+		},
+	}
+}
+
+// newNoKeyTestFS creates a file system with clients without a credential for
+// the endpoint of the connection string, which can't sign shared access
+// signatures.
+func newNoKeyTestFS(t *testing.T, connectionString string) *fs {
+	t.Helper()
+	var endpoint string
+	for _, part := range strings.Split(connectionString, ";") {
+		if k, v, ok := strings.Cut(part, "="); ok && k == "BlobEndpoint" {
+			endpoint = v
+		}
+	}
+	return &fs{
+		newClient: func(string) (*azblob.Client, error) {
+			return azblob.NewClientWithNoCredential(endpoint, nil)
+		},
+	}
+}
+
+func (s *fakeBlobService) putBlob(container, name string, content []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.containers[container][name] = &fakeBlob{content: content, lastModified: time.Now().UTC()}
+}
+
+func (s *fakeBlobService) putFolder(container, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.containers[container][name] = &fakeBlob{folder: true, lastModified: time.Now().UTC()}
+}
+
+func (s *fakeBlobService) getBlob(container, name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.containers[container][name]
+	if !ok {
+		return nil, false
+	}
+	return b.content, true
+}
+
+func (s *fakeBlobService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := strings.TrimPrefix(r.URL.Path, "/"+testAccount+"/")
+	containerName, name, _ := strings.Cut(p, "/")
+	blobs, ok := s.containers[containerName]
+	if !ok {
+		writeError(w, http.StatusNotFound, "ContainerNotFound")
+		return
+	}
+	q := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodGet && q.Get("comp") == "list":
+		s.list(w, containerName, blobs, q.Get("prefix"), strings.Contains(q.Get("include"), "metadata"))
+	case r.Method == http.MethodPut && q.Get("comp") == "block":
+		body, _ := io.ReadAll(r.Body)
+		s.blocks[p+"#"+q.Get("blockid")] = body
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && q.Get("comp") == "blocklist":
+		var list struct {
+			IDs []string `xml:",any"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&list); err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidXmlDocument")
+			return
+		}
+		var content []byte
+		for _, id := range list.IDs {
+			content = append(content, s.blocks[p+"#"+id]...)
+			delete(s.blocks, p+"#"+id)
+		}
+		blobs[name] = &fakeBlob{content: content, lastModified: time.Now().UTC()}
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && r.Header.Get("x-ms-copy-source") != "":
+		src, err := url.Parse(r.Header.Get("x-ms-copy-source"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidHeaderValue")
+			return
+		}
+		if src.Query().Get("sig") == "" {
+			// The service reads copy sources with the credential of the
+			// request only in the same account, so require a signature.
+			writeError(w, http.StatusForbidden, "CannotVerifyCopySource")
+			return
+		}
+		srcPath, _ := url.PathUnescape(strings.TrimPrefix(src.Path, "/"+testAccount+"/"))
+		srcContainer, srcName, _ := strings.Cut(srcPath, "/")
+		b, ok := s.containers[srcContainer][srcName]
+		if !ok {
+			writeError(w, http.StatusNotFound, "CannotVerifyCopySource")
+			return
+		}
+		status := "success"
+		if s.pendingCopies {
+			status = "pending"
+		}
+		blobs[name] = &fakeBlob{content: b.content, lastModified: time.Now().UTC(), copyStatus: status}
+		w.Header().Set("x-ms-copy-status", status)
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		blobs[name] = &fakeBlob{content: body, lastModified: time.Now().UTC()}
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodHead:
+		b, ok := blobs[name]
+		if !ok {
+			w.Header().Set("x-ms-error-code", "BlobNotFound")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeProperties(w, b)
+		if b.copyStatus != "" {
+			w.Header().Set("x-ms-copy-status", b.copyStatus)
+			b.copyStatus = "success"
+		}
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet:
+		b, ok := blobs[name]
+		if !ok {
+			writeError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		s.download(w, r, b)
+	case r.Method == http.MethodDelete:
+		if _, ok := blobs[name]; !ok {
+			writeError(w, http.StatusNotFound, "BlobNotFound")
+			return
+		}
+		delete(blobs, name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (s *fakeBlobService) list(w http.ResponseWriter, container string, blobs map[string]*fakeBlob, prefix string, metadata bool) {
+	var names []string
+	for name := range blobs {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults ServiceEndpoint="http://localhost/%s" ContainerName="%s"><Prefix>%s</Prefix><Blobs>`, testAccount, container, prefix)
+	for _, name := range names {
+		b := blobs[name]
+		fmt.Fprintf(&sb, "<Blob><Name>%s</Name><Properties><Last-Modified>%s</Last-Modified><Content-Length>%d</Content-Length><BlobType>BlockBlob</BlobType></Properties>",
+			name, b.lastModified.Format(http.TimeFormat), len(b.content))
+		if metadata && b.folder {
+			sb.WriteString("<Metadata><hdi_isfolder>true</hdi_isfolder></Metadata>")
+		}
+		sb.WriteString("</Blob>")
+	}
+	sb.WriteString("</Blobs><NextMarker/></EnumerationResults>")
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, sb.String())
+}
+
+func (s *fakeBlobService) download(w http.ResponseWriter, r *http.Request, b *fakeBlob) {
+	content := b.content
+	status := http.StatusOK
+	if rng := r.Header.Get("x-ms-range"); rng != "" {
+		start, end, _ := strings.Cut(strings.TrimPrefix(rng, "bytes="), "-")
+		from, _ := strconv.Atoi(start)
+		to := len(content) - 1
+		if end != "" {
+			to, _ = strconv.Atoi(end)
+		}
+		if from >= len(content) {
+			writeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+			return
+		}
+		to = min(to, len(content)-1)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", from, to, len(content)))
+		content = content[from : to+1]
+		status = http.StatusPartialContent
+	}
+
+	writeProperties(w, b)
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.WriteHeader(status)
+	w.Write(content)
+}
+
+func writeProperties(w http.ResponseWriter, b *fakeBlob) {
+	w.Header().Set("Content-Length", strconv.Itoa(len(b.content)))
+	w.Header().Set("Last-Modified", b.lastModified.Format(http.TimeFormat))
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, b.lastModified.UnixNano()))
+	w.Header().Set("x-ms-blob-type", "BlockBlob")
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("x-ms-error-code", code)
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
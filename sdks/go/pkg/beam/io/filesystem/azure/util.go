@@ -0,0 +1,79 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// location is a parsed Azure uri.
+type location struct {
+	scheme string
+	// host is the authority of the uri, which is the container for 'az' uris
+	// and 'container@account.dfs.core.windows.net' for 'abfs' uris.
+	host string
+	// account is the storage account, or empty for the default account.
+	account   string
+	container string
+	blob      string
+}
+
+// parseURI deconstructs an Azure uri in the format 'az://container/blob' or
+// 'abfs://container@account.dfs.core.windows.net/blob'.
+func parseURI(uri string) (location, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return location{}, err
+	}
+
+	loc := location{scheme: parsed.Scheme, host: parsed.Host}
+	switch parsed.Scheme {
+	case "az":
+		loc.container = parsed.Host
+	case "abfs", "abfss":
+		if parsed.User == nil || parsed.User.Username() == "" {
+			return location{}, errors.New("container must not be empty")
+		}
+		loc.host = parsed.User.Username() + "@" + parsed.Host
+		loc.container = parsed.User.Username()
+		loc.account, _, _ = strings.Cut(parsed.Hostname(), ".")
+		if loc.account == "" {
+			return location{}, errors.New("account must not be empty")
+		}
+	default:
+		return location{}, errors.New("scheme must be 'az', 'abfs' or 'abfss'")
+	}
+	if loc.container == "" {
+		return location{}, errors.New("container must not be empty")
+	}
+
+	loc.blob = strings.TrimPrefix(parsed.Path, "/")
+	return loc, nil
+}
+
+// withBlob returns a location of the blob in the same container.
+func (l location) withBlob(blob string) location {
+	l.blob = blob
+	return l
+}
+
+// String constructs the uri of the location, in the same format it was parsed from.
+func (l location) String() string {
+	return fmt.Sprintf("%s://%s/%s", l.scheme, l.host, l.blob)
+}
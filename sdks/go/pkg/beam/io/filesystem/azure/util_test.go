@@ -0,0 +1,99 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_parseURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		want    location
+		wantErr bool
+	}{
+		{
+			name: "Valid az uri",
+			uri:  "az://container/path/to/blob.txt",
+			want: location{scheme: "az", host: "container", container: "container", blob: "path/to/blob.txt"},
+		},
+		{
+			name: "Valid abfs uri",
+			uri:  "abfs://container@account.dfs.core.windows.net/path/to/blob.txt",
+			want: location{
+				scheme:    "abfs",
+				host:      "container@account.dfs.core.windows.net",
+				account:   "account",
+				container: "container",
+				blob:      "path/to/blob.txt",
+			},
+		},
+		{
+			name: "Valid abfss uri without blob",
+			uri:  "abfss://container@account.dfs.core.windows.net",
+			want: location{
+				scheme:    "abfss",
+				host:      "container@account.dfs.core.windows.net",
+				account:   "account",
+				container: "container",
+			},
+		},
+		{
+			name:    "Error: invalid scheme",
+			uri:     "s3://container/blob.txt",
+			wantErr: true,
+		},
+		{
+			name:    "Error: az uri without container",
+			uri:     "az:///blob.txt",
+			wantErr: true,
+		},
+		{
+			name:    "Error: abfs uri without container",
+			uri:     "abfs://account.dfs.core.windows.net/blob.txt",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseURI(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseURI() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(location{})); diff != "" {
+				t.Errorf("parseURI() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_location_String(t *testing.T) {
+	for _, uri := range []string{
+		"az://container/path/to/blob.txt",
+		"abfs://container@account.dfs.core.windows.net/path/to/blob.txt",
+	} {
+		loc, err := parseURI(uri)
+		if err != nil {
+			t.Fatalf("parseURI(%q) error = %v", uri, err)
+		}
+		if got := loc.String(); got != uri {
+			t.Errorf("String() = %q, want %q", got, uri)
+		}
+	}
+}
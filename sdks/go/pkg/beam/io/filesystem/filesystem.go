@@ -18,7 +18,8 @@
 //
 // Registered file systems at minimum implement the Interface abstraction, and
 // can then optionally implement Remover, Renamer, and Copier to support
-// rename operations. Filesystems are only expected to handle their own IO, and
+// rename operations, and RangeReader to read files from an offset without
+// reading the bytes before it. Filesystems are only expected to handle their own IO, and
 // not cross file system IO. Should cross file system IO be required, additional
 // utility methods should be added to this package to support them.
 package filesystem
@@ -42,6 +43,10 @@ var wellKnownSchemeImportPaths = map[string]string{
 	"default": "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/local",
 	"gs":      "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/gcs",
 	"s3":      "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/s3",
+	"az":      "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/azure",
+	"abfs":    "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/azure",
+	"abfss":   "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/azure",
+	"hdfs":    "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/hdfs",
 }
 
 // Register registers a file system backend under the given scheme.  For
@@ -99,6 +104,14 @@ type LastModifiedGetter interface {
 	LastModified(ctx context.Context, filename string) (time.Time, error)
 }
 
+// RangeReader is an interface for reading a range of a file.
+// To be considered for promotion to Interface.
+type RangeReader interface {
+	// OpenReadRange opens a file for reading length bytes from the offset, or
+	// the rest of the file if length is negative.
+	OpenReadRange(ctx context.Context, filename string, offset, length int64) (io.ReadCloser, error)
+}
+
 // Remover is an interface for removing files from the filesystem.
 // To be considered for promotion to Interface.
 type Remover interface {
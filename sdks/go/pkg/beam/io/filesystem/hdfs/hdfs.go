@@ -0,0 +1,503 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hdfs contains a Hadoop Distributed File System (HDFS) implementation
+// of the Beam file system, which accesses HDFS through the WebHDFS REST API of
+// the namenode.
+//
+// Paths are of the form 'hdfs://namenode:port/path', where namenode:port is
+// the HTTP address serving WebHDFS, which defaults to port 9870. Requests are
+// made as the user named by the HADOOP_USER_NAME environment variable, if set.
+package hdfs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
+)
+
+const (
+	// defaultPort is the default port of the namenode HTTP server.
+	defaultPort = "9870"
+	// userEnv is the environment variable naming the user to access HDFS as.
+	userEnv = "HADOOP_USER_NAME"
+)
+
+func init() {
+	filesystem.Register("hdfs", New)
+}
+
+type fs struct {
+	client *http.Client
+	user   string
+}
+
+// New creates a new HDFS filesystem accessing the namenodes of the paths
+// through WebHDFS, as the user named by HADOOP_USER_NAME.
+func New(_ context.Context) filesystem.Interface {
+	return &fs{client: http.DefaultClient, user: os.Getenv(userEnv)}
+}
+
+// Close closes the filesystem.
+func (f *fs) Close() error {
+	return nil
+}
+
+// fileStatus is the status of a file or directory returned by WebHDFS.
+type fileStatus struct {
+	PathSuffix       string `json:"pathSuffix"`
+	Type             string `json:"type"`
+	Length           int64  `json:"length"`
+	ModificationTime int64  `json:"modificationTime"`
+}
+
+func (s fileStatus) isDir() bool {
+	return s.Type == "DIRECTORY"
+}
+
+// remoteException is an error returned by WebHDFS.
+type remoteException struct {
+	Exception string `json:"exception"`
+	Message   string `json:"message"`
+}
+
+// errNotFound is returned for files or directories that do not exist.
+var errNotFound = errors.New("file not found")
+
+// List returns a slice of the files in the filesystem that match the glob pattern. Wildcards match
+// within a single path segment.
+func (f *fs) List(ctx context.Context, glob string) ([]string, error) {
+	p, err := parseURI(glob)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing HDFS uri %s: %v", glob, err)
+	}
+	if _, err := path.Match(p.path, ""); err != nil {
+		return nil, fmt.Errorf("invalid path pattern: %s", p.path)
+	}
+
+	// Start listing at the deepest directory without wildcards, and descend
+	// into directories until the depth of the pattern.
+	segments := strings.Split(strings.TrimPrefix(p.path, "/"), "/")
+	base := "/"
+	i := 0
+	for ; i < len(segments)-1 && !hasMeta(segments[i]); i++ {
+		base = path.Join(base, segments[i])
+	}
+
+	var files []string
+	if err := f.walk(ctx, p, base, len(segments)-i, &files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// walk appends the files matching the pattern of p under the directory, up to
+// the depth below it.
+func (f *fs) walk(ctx context.Context, p hdfsPath, dir string, depth int, files *[]string) error {
+	statuses, err := f.listStatus(ctx, p.withPath(dir))
+	if errors.Is(err, errNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error listing %s: %v", p.withPath(dir), err)
+	}
+
+	for _, s := range statuses {
+		if s.PathSuffix == "" {
+			// The directory is a file, which is shallower than the pattern.
+			continue
+		}
+
+		name := path.Join(dir, s.PathSuffix)
+		switch {
+		case depth > 1 && s.isDir():
+			if err := f.walk(ctx, p, name, depth-1, files); err != nil {
+				return err
+			}
+		case depth == 1 && !s.isDir() && matches(p.path, name):
+			*files = append(*files, p.withPath(name).String())
+		}
+	}
+	return nil
+}
+
+func matches(pattern, name string) bool {
+	match, _ := path.Match(pattern, name)
+	return match
+}
+
+func hasMeta(segment string) bool {
+	return strings.ContainsAny(segment, `*?[\`)
+}
+
+// OpenRead returns a new io.ReadCloser to read contents from the file. The caller must call Close
+// on the returned io.ReadCloser when done reading.
+func (f *fs) OpenRead(ctx context.Context, filename string) (io.ReadCloser, error) {
+	return f.OpenReadRange(ctx, filename, 0, -1)
+}
+
+// OpenReadRange returns a new io.ReadCloser to read length bytes from the offset of the file, or
+// the rest of the file if length is negative. The caller must call Close on the returned
+// io.ReadCloser when done reading.
+func (f *fs) OpenReadRange(ctx context.Context, filename string, offset, length int64) (io.ReadCloser, error) {
+	p, err := parseURI(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing HDFS uri %s: %v", filename, err)
+	}
+	if length == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+
+	params := url.Values{}
+	if offset > 0 {
+		params.Set("offset", strconv.FormatInt(offset, 10))
+	}
+	if length > 0 {
+		params.Set("length", strconv.FormatInt(length, 10))
+	}
+	resp, err := f.do(ctx, http.MethodGet, f.opURL(p, "OPEN", params), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file %s: %v", filename, err)
+	}
+	return resp.Body, nil
+}
+
+// OpenWrite returns a new io.WriteCloser to write contents to the file. The content is streamed to
+// a datanode as it is written, and the file is complete when the returned io.WriteCloser is closed.
+func (f *fs) OpenWrite(ctx context.Context, filename string) (io.WriteCloser, error) {
+	p, err := parseURI(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing HDFS uri %s: %v", filename, err)
+	}
+
+	location, err := f.createLocation(ctx, p)
+	if err != nil {
+		return nil, fmt.Errorf("error creating file %s: %v", filename, err)
+	}
+
+	pr, pw := io.Pipe()
+	w := &writer{pw: pw, done: make(chan error, 1)}
+	go func() {
+		resp, err := f.do(ctx, http.MethodPut, location, pr)
+		if err != nil {
+			err = fmt.Errorf("error writing file %s: %v", filename, err)
+		} else {
+			resp.Body.Close()
+		}
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w, nil
+}
+
+// createLocation asks the namenode to create the file, and returns the
+// location of the datanode to write its content to.
+func (f *fs) createLocation(ctx context.Context, p hdfsPath) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, f.opURL(p, "CREATE", url.Values{"overwrite": {"true"}}), nil)
+	if err != nil {
+		return "", err
+	}
+
+	// The namenode redirects to a datanode, which must receive the content.
+	client := *f.client
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTemporaryRedirect:
+		return resp.Header.Get("Location"), nil
+	case resp.StatusCode == http.StatusOK:
+		// The namenode returns the location in the body for noredirect.
+		var body struct {
+			Location string `json:"Location"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return "", fmt.Errorf("error decoding response: %v", err)
+		}
+		return body.Location, nil
+	default:
+		return "", responseError(resp)
+	}
+}
+
+// writer writes to a file through a pipe to a request running in the
+// background.
+type writer struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+// Write writes p to the file.
+func (w *writer) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+// Close finishes writing the file and waits for the datanode to acknowledge it.
+func (w *writer) Close() error {
+	if err := w.pw.Close(); err != nil {
+		return err
+	}
+	return <-w.done
+}
+
+// Size returns the size of the file.
+func (f *fs) Size(ctx context.Context, filename string) (int64, error) {
+	s, err := f.fileStatus(ctx, filename)
+	if err != nil {
+		return -1, err
+	}
+	return s.Length, nil
+}
+
+// LastModified returns the time at which the file was last modified.
+func (f *fs) LastModified(ctx context.Context, filename string) (time.Time, error) {
+	s, err := f.fileStatus(ctx, filename)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(s.ModificationTime), nil
+}
+
+// fileStatus returns the status of the file.
+func (f *fs) fileStatus(ctx context.Context, filename string) (fileStatus, error) {
+	p, err := parseURI(filename)
+	if err != nil {
+		return fileStatus{}, fmt.Errorf("error parsing HDFS uri %s: %v", filename, err)
+	}
+
+	var body struct {
+		FileStatus fileStatus `json:"FileStatus"`
+	}
+	if err := f.doJSON(ctx, http.MethodGet, f.opURL(p, "GETFILESTATUS", nil), &body); err != nil {
+		return fileStatus{}, fmt.Errorf("error getting status of file %s: %v", filename, err)
+	}
+	if body.FileStatus.isDir() {
+		return fileStatus{}, fmt.Errorf("error getting status of file %s: is a directory", filename)
+	}
+	return body.FileStatus, nil
+}
+
+// listStatus returns the statuses of the entries of the directory, or of the
+// file itself with an empty path suffix.
+func (f *fs) listStatus(ctx context.Context, p hdfsPath) ([]fileStatus, error) {
+	var body struct {
+		FileStatuses struct {
+			FileStatus []fileStatus `json:"FileStatus"`
+		} `json:"FileStatuses"`
+	}
+	if err := f.doJSON(ctx, http.MethodGet, f.opURL(p, "LISTSTATUS", nil), &body); err != nil {
+		return nil, err
+	}
+	return body.FileStatuses.FileStatus, nil
+}
+
+// Remove removes the file from the filesystem.
+func (f *fs) Remove(ctx context.Context, filename string) error {
+	p, err := parseURI(filename)
+	if err != nil {
+		return fmt.Errorf("error parsing HDFS uri %s: %v", filename, err)
+	}
+
+	var body struct {
+		Boolean bool `json:"boolean"`
+	}
+	if err := f.doJSON(ctx, http.MethodDelete, f.opURL(p, "DELETE", nil), &body); err != nil {
+		return fmt.Errorf("error deleting file %s: %v", filename, err)
+	}
+	if !body.Boolean {
+		return fmt.Errorf("error deleting file %s: %v", filename, errNotFound)
+	}
+	return nil
+}
+
+// Copy copies the file from the old path to the new path, by streaming its content through the
+// worker.
+func (f *fs) Copy(ctx context.Context, oldpath, newpath string) error {
+	r, err := f.OpenRead(ctx, oldpath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := f.OpenWrite(ctx, newpath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return fmt.Errorf("error copying file %s to %s: %v", oldpath, newpath, err)
+	}
+	return w.Close()
+}
+
+// Rename moves the file from the old path to the new path, creating the parent directories of the
+// new path if needed. An existing file at the new path is replaced.
+func (f *fs) Rename(ctx context.Context, oldpath, newpath string) error {
+	src, err := parseURI(oldpath)
+	if err != nil {
+		return fmt.Errorf("error parsing HDFS source uri %s: %v", oldpath, err)
+	}
+	dst, err := parseURI(newpath)
+	if err != nil {
+		return fmt.Errorf("error parsing HDFS destination uri %s: %v", newpath, err)
+	}
+	if src.host != dst.host {
+		return fmt.Errorf("error renaming file %s to %s: paths are on different namenodes", oldpath, newpath)
+	}
+
+	var body struct {
+		Boolean bool `json:"boolean"`
+	}
+	if err := f.doJSON(ctx, http.MethodPut, f.opURL(dst.withPath(path.Dir(dst.path)), "MKDIRS", nil), &body); err != nil {
+		return fmt.Errorf("error creating parent directory of %s: %v", newpath, err)
+	}
+	renamed, err := f.rename(ctx, src, dst)
+	if err != nil {
+		return fmt.Errorf("error renaming file %s to %s: %v", oldpath, newpath, err)
+	}
+	if renamed {
+		return nil
+	}
+
+	// WebHDFS doesn't rename a file onto an existing file, so the destination is deleted and the
+	// rename is retried. The destination is kept if the source doesn't exist, such as when it
+	// has already been renamed by an earlier attempt.
+	if _, err := f.fileStatus(ctx, oldpath); err != nil {
+		return fmt.Errorf("error renaming file %s to %s: %v", oldpath, newpath, err)
+	}
+	if err := f.doJSON(ctx, http.MethodDelete, f.opURL(dst, "DELETE", nil), &body); err != nil {
+		return fmt.Errorf("error deleting existing file %s: %v", newpath, err)
+	}
+	if renamed, err = f.rename(ctx, src, dst); err != nil {
+		return fmt.Errorf("error renaming file %s to %s: %v", oldpath, newpath, err)
+	}
+	if !renamed {
+		return fmt.Errorf("error renaming file %s to %s: source does not exist or destination exists", oldpath, newpath)
+	}
+	return nil
+}
+
+// rename renames the file from src to dst, and returns false if the source does not exist or the
+// destination exists.
+func (f *fs) rename(ctx context.Context, src, dst hdfsPath) (bool, error) {
+	var body struct {
+		Boolean bool `json:"boolean"`
+	}
+	if err := f.doJSON(ctx, http.MethodPut, f.opURL(src, "RENAME", url.Values{"destination": {dst.path}}), &body); err != nil {
+		return false, err
+	}
+	return body.Boolean, nil
+}
+
+// opURL returns the WebHDFS url of the operation on the path.
+func (f *fs) opURL(p hdfsPath, op string, params url.Values) string {
+	q := url.Values{"op": {op}}
+	for k, v := range params {
+		q[k] = v
+	}
+	if f.user != "" {
+		q.Set("user.name", f.user)
+	}
+
+	u := url.URL{
+		Scheme:   "http",
+		Host:     p.host,
+		Path:     "/webhdfs/v1" + p.path,
+		RawQuery: q.Encode(),
+	}
+	if u.Port() == "" {
+		u.Host += ":" + defaultPort
+	}
+	return u.String()
+}
+
+// do sends a request, and returns the response if it succeeded. The caller
+// must close the body of the response.
+func (f *fs) do(ctx context.Context, method, u string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
+
+// doJSON sends a request and decodes the JSON body of the response into v.
+func (f *fs) doJSON(ctx context.Context, method, u string, v any) error {
+	resp, err := f.do(ctx, method, u, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error decoding response: %v", err)
+	}
+	return nil
+}
+
+// responseError returns the error of a failed response, wrapping errNotFound
+// if the file or directory does not exist.
+func responseError(resp *http.Response) error {
+	var body struct {
+		RemoteException remoteException `json:"RemoteException"`
+	}
+	msg := resp.Status
+	if err := json.NewDecoder(resp.Body).Decode(&body); err == nil && body.RemoteException.Exception != "" {
+		msg = fmt.Sprintf("%s: %s", body.RemoteException.Exception, body.RemoteException.Message)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", errNotFound, msg)
+	}
+	return errors.New(msg)
+}
+
+// Compile time check for interface implementations.
+var (
+	_ filesystem.LastModifiedGetter = (*fs)(nil)
+	_ filesystem.RangeReader        = (*fs)(nil)
+	_ filesystem.Remover            = (*fs)(nil)
+	_ filesystem.Copier             = (*fs)(nil)
+	_ filesystem.Renamer            = (*fs)(nil)
+)
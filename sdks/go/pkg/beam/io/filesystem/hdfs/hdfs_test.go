@@ -0,0 +1,475 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hdfs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHDFS_FilesystemNew(t *testing.T) {
+	ctx := context.Background()
+	path := "hdfs://namenode:9870/file.txt"
+	fileSystem, err := filesystem.New(ctx, path)
+	if err != nil {
+		t.Errorf("filesystem.New() error = %v, want %v", err, nil)
+	}
+	if _, ok := fileSystem.(*fs); !ok {
+		t.Errorf("filesystem.New() got type = %T, want %T", fileSystem, &fs{})
+	}
+	if err := fileSystem.Close(); err != nil {
+		t.Errorf("filesystem.Close() error = %v, want %v", err, nil)
+	}
+}
+
+func Test_fs_List(t *testing.T) {
+	tests := []struct {
+		name    string
+		glob    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "List match with full path",
+			glob: "/data/file-1.txt",
+			want: []string{"/data/file-1.txt"},
+		},
+		{
+			name: "List matches with wildcard",
+			glob: "/data/*.txt",
+			want: []string{"/data/file-1.txt", "/data/file-2.txt"},
+		},
+		{
+			name: "List matches with wildcard directories",
+			glob: "/data/*/*.txt",
+			want: []string{"/data/a/file-4.txt", "/data/b/file-5.txt"},
+		},
+		{
+			name: "List matches with wildcard in the first segment",
+			glob: "/*/file-1.txt",
+			want: []string{"/data/file-1.txt"},
+		},
+		{
+			name: "List no matches",
+			glob: "/data/*.json",
+			want: nil,
+		},
+		{
+			name: "List no matches in non-existing directory",
+			glob: "/non-existing/*.txt",
+			want: nil,
+		},
+		{
+			name:    "Error: invalid glob pattern",
+			glob:    "/data/*file-[].txt",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newFakeWebHDFS()
+			content := []byte("content")
+			for _, name := range []string{"/data/file-1.txt", "/data/file-2.txt", "/data/file-3.csv", "/data/a/file-4.txt", "/data/b/file-5.txt", "/data/b/c/file-6.txt"} {
+				service.putFile(name, content)
+			}
+			host := newServer(t, service)
+
+			fileSystem := &fs{client: http.DefaultClient}
+			got, err := fileSystem.List(ctx, "hdfs://"+host+tt.glob)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("List() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var want []string
+			for _, name := range tt.want {
+				want = append(want, "hdfs://"+host+name)
+			}
+			if !cmp.Equal(got, want) {
+				t.Errorf("List() got = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func Test_fs_List_invalidURI(t *testing.T) {
+	fileSystem := &fs{client: http.DefaultClient}
+	if _, err := fileSystem.List(context.Background(), "data/without/scheme"); err == nil {
+		t.Error("List() of an invalid uri succeeded, want error")
+	}
+}
+
+func Test_fs_OpenRead(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		wantErr  bool
+	}{
+		{
+			name:     "Open and read file",
+			filename: "/data/file.txt",
+		},
+		{
+			name:     "Error: file does not exist",
+			filename: "/data/non-existing-file.txt",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newFakeWebHDFS()
+			content := []byte("content")
+			service.putFile("/data/file.txt", content)
+			host := newServer(t, service)
+
+			fileSystem := &fs{client: http.DefaultClient, user: "beam"}
+			reader, err := fileSystem.OpenRead(ctx, "hdfs://"+host+tt.filename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OpenRead() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			defer reader.Close()
+			if err := iotest.TestReader(reader, content); err != nil {
+				t.Errorf("TestReader() error = %v, want %v", err, nil)
+			}
+			if got, want := service.users, []string{"beam"}; !cmp.Equal(got, want) {
+				t.Errorf("request users = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func Test_fs_OpenReadRange(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset int64
+		length int64
+		want   string
+	}{
+		{name: "Range within the file", offset: 2, length: 3, want: "234"},
+		{name: "Rest of the file", offset: 6, length: -1, want: "6789"},
+		{name: "Range past the end of the file", offset: 8, length: 5, want: "89"},
+		{name: "Empty range", offset: 4, length: 0, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newFakeWebHDFS()
+			service.putFile("/file.txt", []byte("0123456789"))
+			host := newServer(t, service)
+
+			fileSystem := &fs{client: http.DefaultClient}
+			reader, err := fileSystem.OpenReadRange(ctx, "hdfs://"+host+"/file.txt", tt.offset, tt.length)
+			if err != nil {
+				t.Fatalf("OpenReadRange() error = %v, want %v", err, nil)
+			}
+			defer reader.Close()
+
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v, want %v", err, nil)
+			}
+			if string(got) != tt.want {
+				t.Errorf("OpenReadRange() read %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fs_OpenWrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		wantErr  bool
+	}{
+		{
+			name:     "Open and write file",
+			filename: "/data/new/file.txt",
+		},
+		{
+			name:     "Open and overwrite file",
+			filename: "/data/file.txt",
+		},
+		{
+			name:     "Error: path is a directory",
+			filename: "/data",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newFakeWebHDFS()
+			service.putFile("/data/file.txt", []byte("old content"))
+			host := newServer(t, service)
+
+			fileSystem := &fs{client: http.DefaultClient}
+			writer, err := fileSystem.OpenWrite(ctx, "hdfs://"+host+tt.filename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OpenWrite() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			content := []byte("content")
+			if _, err := writer.Write(content); err != nil {
+				t.Fatalf("Write() error = %v, want %v", err, nil)
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("Close() error = %v, want %v", err, nil)
+			}
+
+			got, ok := service.getFile(tt.filename)
+			if !ok {
+				t.Fatal("file does not exist after closing the writer")
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("file content = %q, want %q", got, content)
+			}
+		})
+	}
+}
+
+func Test_fs_Size(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		want     int64
+		wantErr  bool
+	}{
+		{
+			name:     "Size of file",
+			filename: "/data/file.txt",
+			want:     7,
+		},
+		{
+			name:     "Error: file does not exist",
+			filename: "/data/non-existing-file.txt",
+			want:     -1,
+			wantErr:  true,
+		},
+		{
+			name:     "Error: path is a directory",
+			filename: "/data",
+			want:     -1,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newFakeWebHDFS()
+			service.putFile("/data/file.txt", []byte("content"))
+			host := newServer(t, service)
+
+			fileSystem := &fs{client: http.DefaultClient}
+			got, err := fileSystem.Size(ctx, "hdfs://"+host+tt.filename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Size() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Size() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fs_LastModified(t *testing.T) {
+	ctx := context.Background()
+	service := newFakeWebHDFS()
+	before := time.Now().Truncate(time.Millisecond)
+	service.putFile("/data/file.txt", []byte("content"))
+	host := newServer(t, service)
+
+	fileSystem := &fs{client: http.DefaultClient}
+	got, err := fileSystem.LastModified(ctx, "hdfs://"+host+"/data/file.txt")
+	if err != nil {
+		t.Fatalf("LastModified() error = %v, want %v", err, nil)
+	}
+	if got.Before(before) || got.After(time.Now()) {
+		t.Errorf("LastModified() got = %v, want between %v and now", got, before)
+	}
+}
+
+func Test_fs_Remove(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		wantErr  bool
+	}{
+		{
+			name:     "Remove file",
+			filename: "/data/file.txt",
+		},
+		{
+			name:     "Error: file does not exist",
+			filename: "/data/non-existing-file.txt",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newFakeWebHDFS()
+			service.putFile("/data/file.txt", []byte("content"))
+			host := newServer(t, service)
+
+			fileSystem := &fs{client: http.DefaultClient}
+			if err := fileSystem.Remove(ctx, "hdfs://"+host+tt.filename); (err != nil) != tt.wantErr {
+				t.Fatalf("Remove() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if _, ok := service.getFile(tt.filename); ok {
+				t.Error("file exists after Remove()")
+			}
+		})
+	}
+}
+
+func Test_fs_Copy(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldpath string
+		wantErr bool
+	}{
+		{
+			name:    "Copy file",
+			oldpath: "/data/file.txt",
+		},
+		{
+			name:    "Error: source does not exist",
+			oldpath: "/data/non-existing-file.txt",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newFakeWebHDFS()
+			content := []byte("content")
+			service.putFile("/data/file.txt", content)
+			host := newServer(t, service)
+
+			fileSystem := &fs{client: http.DefaultClient}
+			err := fileSystem.Copy(ctx, "hdfs://"+host+tt.oldpath, "hdfs://"+host+"/copy/file.txt")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Copy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if _, ok := service.getFile("/data/file.txt"); !ok {
+				t.Error("source file does not exist after Copy()")
+			}
+			got, ok := service.getFile("/copy/file.txt")
+			if !ok {
+				t.Fatal("destination file does not exist after Copy()")
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("destination file content = %q, want %q", got, content)
+			}
+		})
+	}
+}
+
+func Test_fs_Rename(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldpath string
+		newpath string
+		wantErr bool
+	}{
+		{
+			name:    "Rename file to new directory",
+			oldpath: "/data/file.txt",
+			newpath: "/renamed/file.txt",
+		},
+		{
+			name:    "Error: source does not exist",
+			oldpath: "/data/non-existing-file.txt",
+			newpath: "/renamed/file.txt",
+			wantErr: true,
+		},
+		{
+			name:    "Error: source does not exist and destination exists",
+			oldpath: "/data/non-existing-file.txt",
+			newpath: "/data/other.txt",
+			wantErr: true,
+		},
+		{
+			name:    "Rename file over existing file",
+			oldpath: "/data/file.txt",
+			newpath: "/data/other.txt",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			service := newFakeWebHDFS()
+			content := []byte("content")
+			service.putFile("/data/file.txt", content)
+			service.putFile("/data/other.txt", []byte("other"))
+			host := newServer(t, service)
+
+			fileSystem := &fs{client: http.DefaultClient}
+			err := fileSystem.Rename(ctx, "hdfs://"+host+tt.oldpath, "hdfs://"+host+tt.newpath)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Rename() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if got, _ := service.getFile("/data/other.txt"); !bytes.Equal(got, []byte("other")) {
+					t.Errorf("existing file content = %q after failed Rename(), want %q", got, "other")
+				}
+				return
+			}
+
+			if _, ok := service.getFile(tt.oldpath); ok {
+				t.Error("source file exists after Rename()")
+			}
+			got, ok := service.getFile(tt.newpath)
+			if !ok {
+				t.Fatal("destination file does not exist after Rename()")
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("destination file content = %q, want %q", got, content)
+			}
+		})
+	}
+}
+
+func Test_fs_Rename_differentNamenodes(t *testing.T) {
+	fileSystem := &fs{client: http.DefaultClient}
+	if err := fileSystem.Rename(context.Background(), "hdfs://nn1/file.txt", "hdfs://nn2/file.txt"); err == nil {
+		t.Error("Rename() across namenodes succeeded, want error")
+	}
+}
@@ -0,0 +1,224 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hdfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const webHDFSPrefix = "/webhdfs/v1"
+
+// fakeFile is a file stored in a fakeWebHDFS.
+type fakeFile struct {
+	content      []byte
+	modification time.Time
+}
+
+// fakeWebHDFS is an in-memory stand-in for the subset of the WebHDFS REST API
+// used by the file system. It serves both as the namenode and the datanode,
+// redirecting writes to itself as a namenode does.
+type fakeWebHDFS struct {
+	mu    sync.Mutex
+	files map[string]*fakeFile
+	dirs  map[string]bool
+	// users are the users of the requests.
+	users []string
+}
+
+func newFakeWebHDFS() *fakeWebHDFS {
+	return &fakeWebHDFS{
+		files: make(map[string]*fakeFile),
+		dirs:  map[string]bool{"/": true},
+	}
+}
+
+// newServer starts a server for the fake and returns its address, for use as
+// the authority of hdfs uris.
+func newServer(t *testing.T, h *fakeWebHDFS) string {
+	t.Helper()
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func (h *fakeWebHDFS) putFile(name string, content []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.mkdirs(path.Dir(name))
+	h.files[name] = &fakeFile{content: content, modification: time.Now()}
+}
+
+func (h *fakeWebHDFS) getFile(name string) ([]byte, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f, ok := h.files[name]
+	if !ok {
+		return nil, false
+	}
+	return f.content, true
+}
+
+func (h *fakeWebHDFS) mkdirs(dir string) {
+	for ; dir != "/"; dir = path.Dir(dir) {
+		h.dirs[dir] = true
+	}
+}
+
+func (h *fakeWebHDFS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	name := strings.TrimPrefix(r.URL.Path, webHDFSPrefix)
+	q := r.URL.Query()
+	h.users = append(h.users, q.Get("user.name"))
+
+	switch op := q.Get("op"); {
+	case op == "LISTSTATUS" && r.Method == http.MethodGet:
+		var statuses []map[string]any
+		if f, ok := h.files[name]; ok {
+			statuses = append(statuses, fileStatusJSON("", f))
+		} else if h.dirs[name] {
+			statuses = h.children(name)
+		} else {
+			writeException(w, http.StatusNotFound, "FileNotFoundException", "File "+name+" does not exist.")
+			return
+		}
+		writeJSON(w, map[string]any{"FileStatuses": map[string]any{"FileStatus": statuses}})
+	case op == "GETFILESTATUS" && r.Method == http.MethodGet:
+		if f, ok := h.files[name]; ok {
+			writeJSON(w, map[string]any{"FileStatus": fileStatusJSON("", f)})
+		} else if h.dirs[name] {
+			writeJSON(w, map[string]any{"FileStatus": map[string]any{"pathSuffix": "", "type": "DIRECTORY"}})
+		} else {
+			writeException(w, http.StatusNotFound, "FileNotFoundException", "File does not exist: "+name)
+		}
+	case op == "OPEN" && r.Method == http.MethodGet:
+		f, ok := h.files[name]
+		if !ok {
+			writeException(w, http.StatusNotFound, "FileNotFoundException", "File does not exist: "+name)
+			return
+		}
+		content := f.content
+		if offset, err := strconv.Atoi(q.Get("offset")); err == nil {
+			content = content[min(offset, len(content)):]
+		}
+		if length, err := strconv.Atoi(q.Get("length")); err == nil {
+			content = content[:min(length, len(content))]
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(content)
+	case op == "CREATE" && r.Method == http.MethodPut && q.Get("datanode") == "":
+		if h.dirs[name] {
+			writeException(w, http.StatusForbidden, "FileAlreadyExistsException", name+" is a directory")
+			return
+		}
+		q.Set("datanode", "true")
+		w.Header().Set("Location", fmt.Sprintf("http://%s%s?%s", r.Host, r.URL.Path, q.Encode()))
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	case op == "CREATE" && r.Method == http.MethodPut:
+		content, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeException(w, http.StatusBadRequest, "IOException", err.Error())
+			return
+		}
+		h.mkdirs(path.Dir(name))
+		h.files[name] = &fakeFile{content: content, modification: time.Now()}
+		w.WriteHeader(http.StatusCreated)
+	case op == "DELETE" && r.Method == http.MethodDelete:
+		_, ok := h.files[name]
+		delete(h.files, name)
+		writeJSON(w, map[string]any{"boolean": ok})
+	case op == "MKDIRS" && r.Method == http.MethodPut:
+		h.mkdirs(name)
+		writeJSON(w, map[string]any{"boolean": true})
+	case op == "RENAME" && r.Method == http.MethodPut:
+		dst := q.Get("destination")
+		f, ok := h.files[name]
+		_, exists := h.files[dst]
+		if !ok || exists || !h.dirs[path.Dir(dst)] {
+			writeJSON(w, map[string]any{"boolean": false})
+			return
+		}
+		delete(h.files, name)
+		h.files[dst] = f
+		writeJSON(w, map[string]any{"boolean": true})
+	default:
+		writeException(w, http.StatusBadRequest, "IllegalArgumentException", "Invalid operation "+op)
+	}
+}
+
+// children returns the statuses of the entries of the directory.
+func (h *fakeWebHDFS) children(dir string) []map[string]any {
+	var names []string
+	for name := range h.files {
+		if path.Dir(name) == dir {
+			names = append(names, name)
+		}
+	}
+	for name := range h.dirs {
+		if name != "/" && path.Dir(name) == dir {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var statuses []map[string]any
+	for _, name := range names {
+		if f, ok := h.files[name]; ok {
+			statuses = append(statuses, fileStatusJSON(path.Base(name), f))
+		} else {
+			statuses = append(statuses, map[string]any{"pathSuffix": path.Base(name), "type": "DIRECTORY"})
+		}
+	}
+	return statuses
+}
+
+func fileStatusJSON(suffix string, f *fakeFile) map[string]any {
+	return map[string]any{
+		"pathSuffix":       suffix,
+		"type":             "FILE",
+		"length":           len(f.content),
+		"modificationTime": f.modification.UnixMilli(),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeException(w http.ResponseWriter, status int, exception, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"RemoteException": map[string]any{
+			"exception":     exception,
+			"javaClassName": "java.io." + exception,
+			"message":       message,
+		},
+	})
+}
@@ -0,0 +1,59 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hdfs
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+)
+
+// hdfsPath is a parsed HDFS uri.
+type hdfsPath struct {
+	// host is the HTTP address of the namenode.
+	host string
+	// path is the absolute, cleaned path of the file.
+	path string
+}
+
+// parseURI deconstructs the HDFS uri in the format 'hdfs://namenode:port/path'.
+func parseURI(uri string) (hdfsPath, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return hdfsPath{}, err
+	}
+
+	if parsed.Scheme != "hdfs" {
+		return hdfsPath{}, errors.New("scheme must be 'hdfs'")
+	}
+	if parsed.Host == "" {
+		return hdfsPath{}, errors.New("namenode must not be empty")
+	}
+
+	return hdfsPath{host: parsed.Host, path: path.Join("/", parsed.Path)}, nil
+}
+
+// withPath returns the HDFS path of the file on the same namenode.
+func (p hdfsPath) withPath(name string) hdfsPath {
+	p.path = name
+	return p
+}
+
+// String constructs the HDFS uri in the format 'hdfs://namenode:port/path'.
+func (p hdfsPath) String() string {
+	return fmt.Sprintf("hdfs://%s%s", p.host, p.path)
+}
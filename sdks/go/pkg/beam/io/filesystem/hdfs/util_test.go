@@ -0,0 +1,91 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hdfs
+
+import (
+	"net/url"
+	"testing"
+)
+
+func Test_parseURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		want    hdfsPath
+		wantErr bool
+	}{
+		{
+			name: "Valid HDFS uri",
+			uri:  "hdfs://namenode:9870/path/to/file.txt",
+			want: hdfsPath{host: "namenode:9870", path: "/path/to/file.txt"},
+		},
+		{
+			name: "Valid HDFS uri without path",
+			uri:  "hdfs://namenode",
+			want: hdfsPath{host: "namenode", path: "/"},
+		},
+		{
+			name:    "Error: invalid scheme",
+			uri:     "s3://namenode/file.txt",
+			wantErr: true,
+		},
+		{
+			name:    "Error: empty namenode",
+			uri:     "hdfs:///file.txt",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseURI(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseURI() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseURI() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fs_opURL(t *testing.T) {
+	tests := []struct {
+		name string
+		fs   *fs
+		p    hdfsPath
+		want string
+	}{
+		{
+			name: "Default port",
+			fs:   &fs{},
+			p:    hdfsPath{host: "namenode", path: "/file.txt"},
+			want: "http://namenode:9870/webhdfs/v1/file.txt?op=OPEN",
+		},
+		{
+			name: "Explicit port and user",
+			fs:   &fs{user: "beam"},
+			p:    hdfsPath{host: "namenode:50070", path: "/file.txt"},
+			want: "http://namenode:50070/webhdfs/v1/file.txt?op=OPEN&user.name=beam",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fs.opURL(tt.p, "OPEN", url.Values{}); got != tt.want {
+				t.Errorf("opURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonio
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/fileio"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
+)
+
+// InferType reads up to maxLines lines of the files indicated by the glob
+// pattern at pipeline construction time, and returns a struct type with a
+// field for each key of the JSON objects on the lines, which can be passed to
+// Read to read the files as schema rows. Empty lines are skipped.
+//
+// Numbers are inferred as int64, or float64 if any of their values has a
+// fraction or exponent, objects as nested structs, and arrays as slices.
+// Fields that are null or missing on some of the lines are pointers, and
+// fields that are null on all of the lines are *string. Fields are named
+// after their keys, and tagged with their keys for encoding/json and schemas.
+// An error is returned if a key has values of conflicting types.
+func InferType(ctx context.Context, glob string, maxLines int) (reflect.Type, error) {
+	if maxLines <= 0 {
+		return nil, fmt.Errorf("jsonio.InferType: maxLines must be positive, got %v", maxLines)
+	}
+	fs, err := filesystem.New(ctx, glob)
+	if err != nil {
+		return nil, err
+	}
+	defer fs.Close()
+
+	files, err := fs.List(ctx, glob)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("jsonio.InferType: no files match %v", glob)
+	}
+
+	root := &node{}
+	lines := 0
+	for _, filename := range files {
+		n, err := sampleFile(ctx, filename, maxLines-lines, root)
+		if err != nil {
+			return nil, fmt.Errorf("jsonio.InferType: %v: %w", filename, err)
+		}
+		lines += n
+		if lines >= maxLines {
+			break
+		}
+	}
+	if lines == 0 {
+		return nil, fmt.Errorf("jsonio.InferType: no lines in files matching %v", glob)
+	}
+	if root.kind != kindObject {
+		return nil, fmt.Errorf("jsonio.InferType: lines must be JSON objects, got %v", root.kind)
+	}
+	return root.fieldsType(), nil
+}
+
+// sampleFile merges up to maxLines non-empty lines of the file into root, and
+// returns the number of merged lines.
+func sampleFile(ctx context.Context, filename string, maxLines int, root *node) (int, error) {
+	file := fileio.ReadableFile{Metadata: fileio.FileMetadata{Path: filename}}
+	fd, err := file.Open(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer fd.Close()
+
+	lines := 0
+	br := bufio.NewReader(fd)
+	for lines < maxLines {
+		line, err := br.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return lines, err
+		}
+		if strings.TrimSpace(line) != "" {
+			var v any
+			dec := json.NewDecoder(strings.NewReader(line))
+			dec.UseNumber()
+			if err := dec.Decode(&v); err != nil {
+				return lines, fmt.Errorf("decoding line %q: %w", line, err)
+			}
+			if err := root.merge(v); err != nil {
+				return lines, err
+			}
+			lines++
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+	}
+	return lines, nil
+}
+
+type kind int
+
+const (
+	kindNull kind = iota
+	kindBool
+	kindInt
+	kindFloat
+	kindString
+	kindObject
+	kindArray
+)
+
+func (k kind) String() string {
+	switch k {
+	case kindNull:
+		return "null"
+	case kindBool:
+		return "boolean"
+	case kindInt, kindFloat:
+		return "number"
+	case kindString:
+		return "string"
+	case kindObject:
+		return "object"
+	default:
+		return "array"
+	}
+}
+
+// node is the type inferred from the values of a key.
+type node struct {
+	kind     kind
+	nullable bool
+
+	// objects is the number of objects merged into an object node.
+	objects int
+	keys    []string
+	fields  map[string]*node
+	// present is the number of objects that had each key.
+	present map[string]int
+
+	elem *node
+}
+
+func (n *node) merge(v any) error {
+	var k kind
+	switch v.(type) {
+	case nil:
+		n.nullable = true
+		return nil
+	case bool:
+		k = kindBool
+	case json.Number:
+		k = kindFloat
+		if _, err := v.(json.Number).Int64(); err == nil {
+			k = kindInt
+		}
+	case string:
+		k = kindString
+	case map[string]any:
+		k = kindObject
+	case []any:
+		k = kindArray
+	}
+
+	switch {
+	case n.kind == kindNull || n.kind == k:
+		n.kind = k
+	case n.kind == kindInt && k == kindFloat:
+		n.kind = kindFloat
+	case n.kind == kindFloat && k == kindInt:
+	default:
+		return fmt.Errorf("conflicting types %v and %v", n.kind, k)
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		if n.fields == nil {
+			n.fields = make(map[string]*node)
+			n.present = make(map[string]int)
+		}
+		n.objects++
+		// Keys are merged in sorted order, so that the fields of the
+		// inferred type are in a deterministic order.
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			val := v[key]
+			f, ok := n.fields[key]
+			if !ok {
+				f = &node{}
+				n.fields[key] = f
+				n.keys = append(n.keys, key)
+			}
+			if err := f.merge(val); err != nil {
+				return fmt.Errorf("key %q: %w", key, err)
+			}
+			n.present[key]++
+		}
+	case []any:
+		if n.elem == nil {
+			n.elem = &node{}
+		}
+		for _, e := range v {
+			if err := n.elem.merge(e); err != nil {
+				return fmt.Errorf("array element: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// goType returns the type of the values of the node, which is a pointer if
+// the values may be null or missing.
+func (n *node) goType(optional bool) reflect.Type {
+	var t reflect.Type
+	switch n.kind {
+	case kindNull:
+		return reflect.PtrTo(reflect.TypeOf(""))
+	case kindBool:
+		t = reflect.TypeOf(false)
+	case kindInt:
+		t = reflect.TypeOf(int64(0))
+	case kindFloat:
+		t = reflect.TypeOf(float64(0))
+	case kindString:
+		t = reflect.TypeOf("")
+	case kindObject:
+		t = n.fieldsType()
+	case kindArray:
+		elem := reflect.TypeOf("")
+		if n.elem.kind != kindNull {
+			elem = n.elem.goType(n.elem.nullable)
+		}
+		// Null and missing arrays decode as nil slices.
+		return reflect.SliceOf(elem)
+	}
+	if optional || n.nullable {
+		return reflect.PtrTo(t)
+	}
+	return t
+}
+
+// fieldsType returns a struct type with a field for each key of an object
+// node.
+func (n *node) fieldsType() reflect.Type {
+	used := make(map[string]bool)
+	fields := make([]reflect.StructField, 0, len(n.keys))
+	for _, key := range n.keys {
+		name := fieldName(key)
+		for i := 2; used[name]; i++ {
+			name = fieldName(key) + strconv.Itoa(i)
+		}
+		used[name] = true
+
+		f := n.fields[key]
+		fields = append(fields, reflect.StructField{
+			Name: name,
+			Type: f.goType(n.present[key] < n.objects),
+			Tag:  reflect.StructTag(fmt.Sprintf(`json:%q beam:%q`, key, key)),
+		})
+	}
+	return reflect.StructOf(fields)
+}
+
+// fieldName returns an exported Go identifier for the key, such as UserId for
+// "user_id".
+func fieldName(key string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range key {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if sb.Len() == 0 && !unicode.IsLetter(r) {
+			sb.WriteRune('F')
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	if sb.Len() == 0 {
+		return "F"
+	}
+	name := sb.String()
+	// Letters without an upper case, such as in CJK scripts, are unexported.
+	if r := []rune(name)[0]; !unicode.IsUpper(r) {
+		name = "F" + name
+	}
+	return name
+}
@@ -0,0 +1,172 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jsonio contains transforms for reading and writing newline-delimited
+// JSON files, in which each line is a JSON value.
+package jsonio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/fileio"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/textio"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*MalformedLine)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*jsonSink)(nil)).Elem())
+	register.DoFn4x1[string, string, func(beam.X), func(MalformedLine), error](&parseFn{})
+	register.Emitter1[beam.X]()
+	register.Emitter1[MalformedLine]()
+}
+
+// MalformedLine is a line that could not be decoded.
+type MalformedLine struct {
+	// Filename is the name of the file of the line.
+	Filename string
+	// Line is the malformed line.
+	Line string
+	// Error is the cause of the error.
+	Error string
+}
+
+type readOption struct {
+	Strict   bool
+	TextOpts []textio.ReadOptionFn
+}
+
+// ReadOptionFn is a function that can be passed to Read to configure options
+// for reading files.
+type ReadOptionFn func(*readOption)
+
+// ReadStrict specifies that the read fails on malformed lines, and on lines
+// with fields that the type has no field for, instead of returning them.
+func ReadStrict() ReadOptionFn {
+	return func(o *readOption) {
+		o.Strict = true
+	}
+}
+
+// ReadTextOptions specifies options for reading the lines of the files, such
+// as their compression.
+func ReadTextOptions(opts ...textio.ReadOptionFn) ReadOptionFn {
+	return func(o *readOption) {
+		o.TextOpts = append(o.TextOpts, opts...)
+	}
+}
+
+// Read reads a set of newline-delimited JSON files indicated by the glob
+// pattern, and returns a PCollection<T> of the lines decoded with
+// encoding/json into the type t, and a PCollection<MalformedLine> of the lines
+// that could not be decoded. Empty lines are skipped. Read accepts a variadic
+// number of ReadOptionFn that can be used to fail on malformed lines instead,
+// and to configure how the lines are read.
+//
+// The files are read with textio, so uncompressed files are split into
+// blocks that are read in parallel. To read the lines as schema rows, t may be
+// a struct type with beam tags, such as one returned by InferType.
+func Read(s beam.Scope, glob string, t reflect.Type, opts ...ReadOptionFn) (beam.PCollection, beam.PCollection) {
+	s = s.Scope("jsonio.Read")
+
+	option := &readOption{}
+	for _, opt := range opts {
+		opt(option)
+	}
+	lines := textio.ReadWithFilename(s, glob, option.TextOpts...)
+	return beam.ParDo2(s,
+		&parseFn{Type: beam.EncodedType{T: t}, Strict: option.Strict},
+		lines,
+		beam.TypeDefinition{Var: beam.XType, T: t},
+	)
+}
+
+// parseFn decodes JSON lines into values of the type.
+type parseFn struct {
+	Type   beam.EncodedType `json:"type"`
+	Strict bool             `json:"strict"`
+}
+
+func (fn *parseFn) ProcessElement(filename, line string, emit func(beam.X), emitErr func(MalformedLine)) error {
+	line = strings.TrimSuffix(line, "\r")
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	v, err := fn.decode(line)
+	if err != nil {
+		if fn.Strict {
+			return fmt.Errorf("decoding line of %v: %w", filename, err)
+		}
+		emitErr(MalformedLine{Filename: filename, Line: line, Error: err.Error()})
+		return nil
+	}
+	emit(v.Elem().Interface())
+	return nil
+}
+
+func (fn *parseFn) decode(line string) (reflect.Value, error) {
+	v := reflect.New(fn.Type.T)
+	dec := json.NewDecoder(strings.NewReader(line))
+	if fn.Strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return reflect.Value{}, errors.New("invalid data after top-level value")
+	}
+	return v, nil
+}
+
+// Write writes a PCollection<T> to newline-delimited JSON files, with each
+// element encoded with encoding/json on a line, and returns a
+// PCollection<string> of the names of the written files. Write accepts a
+// variadic number of fileio.WriteOptionFn that can be used to configure how
+// the files are written. By default, a single file is written per window, as
+// described in fileio.WriteFiles.
+func Write(s beam.Scope, filename string, col beam.PCollection, opts ...fileio.WriteOptionFn) beam.PCollection {
+	s = s.Scope("jsonio.Write")
+
+	opts = append([]fileio.WriteOptionFn{fileio.WriteNumShards(1)}, opts...)
+	return fileio.WriteFiles(s, filename, &jsonSink{}, col, opts...)
+}
+
+// jsonSink writes elements as JSON lines.
+type jsonSink struct {
+	enc *json.Encoder
+}
+
+func (s *jsonSink) Open(_ context.Context, w io.Writer) error {
+	s.enc = json.NewEncoder(w)
+	s.enc.SetEscapeHTML(false)
+	return nil
+}
+
+func (s *jsonSink) Write(_ context.Context, elm any) error {
+	// Encode terminates each value with a newline.
+	return s.enc.Encode(elm)
+}
+
+func (s *jsonSink) Flush(_ context.Context) error {
+	return nil
+}
@@ -0,0 +1,174 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonio
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/local"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*event)(nil)).Elem())
+	register.Function1x1(malformedLine)
+}
+
+func TestMain(m *testing.M) {
+	ptest.Main(m)
+}
+
+type event struct {
+	ID    int64    `json:"id"`
+	Name  string   `json:"name"`
+	Score *float64 `json:"score"`
+	Tags  []string `json:"tags"`
+}
+
+func malformedLine(l MalformedLine) string {
+	return l.Line
+}
+
+func score(f float64) *float64 {
+	return &f
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const events = `{"id": 1, "name": "a", "score": 1.5, "tags": ["x", "y"]}
+{"id": 2, "name": "b", "extra": true}
+
+{"id": 3, "name":
+{"id": 4, "name": "d"} {}
+`
+
+func TestRead(t *testing.T) {
+	path := writeFile(t, "in.json", events)
+
+	p, s := beam.NewPipelineWithRoot()
+	rows, malformed := Read(s, path, reflect.TypeOf(event{}))
+	passert.Equals(s, rows,
+		event{ID: 1, Name: "a", Score: score(1.5), Tags: []string{"x", "y"}},
+		event{ID: 2, Name: "b"},
+	)
+	passert.Equals(s, beam.ParDo(s, malformedLine, malformed),
+		`{"id": 3, "name":`,
+		`{"id": 4, "name": "d"} {}`,
+	)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestRead_Strict(t *testing.T) {
+	path := writeFile(t, "in.json", "{\"id\": 1}\n{\"id\": 2, \"extra\": true}\n")
+
+	p, s := beam.NewPipelineWithRoot()
+	Read(s, path, reflect.TypeOf(event{}), ReadStrict())
+	if err := ptest.Run(p); err == nil {
+		t.Error("Read() with ReadStrict() succeeded for a line with an unknown field, want error")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.json")
+	in := []any{
+		event{ID: 1, Name: "<a>", Score: score(2)},
+		event{ID: 2, Tags: []string{"z"}},
+	}
+
+	p, s := beam.NewPipelineWithRoot()
+	Write(s, out, beam.Create(s, in...))
+	ptest.RunAndValidate(t, p)
+
+	p, s = beam.NewPipelineWithRoot()
+	rows, malformed := Read(s, out, reflect.TypeOf(event{}), ReadStrict())
+	passert.Equals(s, rows, in...)
+	passert.Empty(s, malformed)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestInferType(t *testing.T) {
+	path := writeFile(t, "in.json", `{"id": 1, "user_name": "a", "score": 1, "tags": [], "loc": {"lat": 1.5}, "note": null}
+{"id": 2, "user_name": "b", "score": 2.5, "tags": ["x"], "loc": null, "note": null, "ok": true}
+`)
+
+	got, err := InferType(context.Background(), path, 10)
+	if err != nil {
+		t.Fatalf("InferType() failed: %v", err)
+	}
+	want := reflect.TypeOf(struct {
+		Id  int64 `json:"id" beam:"id"`
+		Loc *struct {
+			Lat float64 `json:"lat" beam:"lat"`
+		} `json:"loc" beam:"loc"`
+		Note     *string  `json:"note" beam:"note"`
+		Score    float64  `json:"score" beam:"score"`
+		Tags     []string `json:"tags" beam:"tags"`
+		UserName string   `json:"user_name" beam:"user_name"`
+		Ok       *bool    `json:"ok" beam:"ok"`
+	}{})
+	// Struct types are identical to the struct literal types with the same
+	// fields.
+	if got != want {
+		t.Errorf("InferType() = %v, want %v", got, want)
+	}
+}
+
+func TestInferType_Errors(t *testing.T) {
+	tests := []struct {
+		name, content string
+	}{
+		{"conflict", "{\"id\": 1}\n{\"id\": \"1\"}\n"},
+		{"not object", "[1]\n"},
+		{"empty", "\n"},
+		{"invalid", "{\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeFile(t, "in.json", test.content)
+			if got, err := InferType(context.Background(), path, 10); err == nil {
+				t.Errorf("InferType() = %v, want error", got)
+			}
+		})
+	}
+}
+
+func TestRead_InferredType(t *testing.T) {
+	path := writeFile(t, "in.json", "{\"name\": \"a\", \"count\": 1}\n{\"name\": \"b\"}\n{\"name\": 3}\n")
+
+	typ, err := InferType(context.Background(), path, 2)
+	if err != nil {
+		t.Fatalf("InferType() failed: %v", err)
+	}
+	p, s := beam.NewPipelineWithRoot()
+	rows, malformed := Read(s, path, typ)
+	passert.Count(s, rows, "rows", 2)
+	passert.Count(s, malformed, "malformed", 1)
+	ptest.RunAndValidate(t, p)
+}
@@ -0,0 +1,209 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafkaio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+const (
+	pollTimeout    = 3 * time.Second
+	maxPollRecords = 1000
+)
+
+// kafkaClient is a client of a Kafka cluster.
+type kafkaClient struct {
+	servers []string
+	cl      *kgo.Client
+	adm     *kadm.Client
+
+	mu  sync.Mutex
+	err error // The first error of producing records since the last flush.
+}
+
+func newKafkaClient(servers []string) (*kafkaClient, error) {
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(servers...),
+		kgo.RecordPartitioner(kgo.StickyKeyPartitioner(nil)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaClient{servers: servers, cl: cl, adm: kadm.NewClient(cl)}, nil
+}
+
+func (c *kafkaClient) Partitions(ctx context.Context, topic string) ([]int32, error) {
+	topics, err := c.adm.ListTopics(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+	detail, ok := topics[topic]
+	if !ok {
+		return nil, fmt.Errorf("topic %v not found", topic)
+	}
+	if detail.Err != nil {
+		return nil, detail.Err
+	}
+	return detail.Partitions.Numbers(), nil
+}
+
+func (c *kafkaClient) StartOffset(ctx context.Context, topic string, partition int32) (int64, error) {
+	offsets, err := c.adm.ListStartOffsets(ctx, topic)
+	return listedOffset(offsets, err, topic, partition)
+}
+
+func (c *kafkaClient) EndOffset(ctx context.Context, topic string, partition int32) (int64, error) {
+	offsets, err := c.adm.ListEndOffsets(ctx, topic)
+	return listedOffset(offsets, err, topic, partition)
+}
+
+func (c *kafkaClient) OffsetAfter(ctx context.Context, topic string, partition int32, t time.Time) (int64, error) {
+	offsets, err := c.adm.ListOffsetsAfterMilli(ctx, t.UnixMilli(), topic)
+	return listedOffset(offsets, err, topic, partition)
+}
+
+func listedOffset(offsets kadm.ListedOffsets, err error, topic string, partition int32) (int64, error) {
+	if err != nil {
+		return -1, err
+	}
+	o, ok := offsets.Lookup(topic, partition)
+	if !ok {
+		return -1, fmt.Errorf("partition %v of topic %v not found", partition, topic)
+	}
+	if o.Err != nil {
+		return -1, o.Err
+	}
+	return o.Offset, nil
+}
+
+func (c *kafkaClient) CommittedOffset(ctx context.Context, group, topic string, partition int32) (int64, error) {
+	offsets, err := c.adm.FetchOffsetsForTopics(ctx, group, topic)
+	if err != nil {
+		return -1, err
+	}
+	o, ok := offsets.Lookup(topic, partition)
+	if !ok {
+		return -1, nil
+	}
+	if o.Err != nil {
+		return -1, o.Err
+	}
+	return o.At, nil
+}
+
+func (c *kafkaClient) Commit(ctx context.Context, group, topic string, partition int32, offset int64) error {
+	var offsets kadm.Offsets
+	offsets.AddOffset(topic, partition, offset, -1)
+	resp, err := c.adm.CommitOffsets(ctx, group, offsets)
+	if err != nil {
+		return err
+	}
+	return resp.Error()
+}
+
+func (c *kafkaClient) Consume(topic string, partition int32, offset int64) (consumer, error) {
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(c.servers...),
+		kgo.ConsumePartitions(map[string]map[int32]kgo.Offset{
+			topic: {partition: kgo.NewOffset().At(offset)},
+		}),
+		kgo.FetchMaxWait(pollTimeout),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaConsumer{cl: cl}, nil
+}
+
+func (c *kafkaClient) Produce(ctx context.Context, r producerRecord) {
+	record := &kgo.Record{
+		Topic: r.Topic,
+		Key:   r.Key,
+		Value: r.Value,
+	}
+	for _, h := range r.Headers {
+		record.Headers = append(record.Headers, kgo.RecordHeader{Key: h.Key, Value: h.Value})
+	}
+	c.cl.Produce(ctx, record, func(_ *kgo.Record, err error) {
+		if err == nil {
+			return
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.err == nil {
+			c.err = err
+		}
+	})
+}
+
+func (c *kafkaClient) Flush(ctx context.Context) error {
+	if err := c.cl.Flush(ctx); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.err
+	c.err = nil
+	return err
+}
+
+func (c *kafkaClient) Close() {
+	c.cl.Close()
+}
+
+// kafkaConsumer consumes a partition with its own client.
+type kafkaConsumer struct {
+	cl *kgo.Client
+}
+
+func (c *kafkaConsumer) Poll(ctx context.Context) ([]ConsumerRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, pollTimeout)
+	defer cancel()
+	fetches := c.cl.PollRecords(ctx, maxPollRecords)
+	for _, fe := range fetches.Errors() {
+		if errors.Is(fe.Err, context.DeadlineExceeded) || errors.Is(fe.Err, context.Canceled) {
+			continue
+		}
+		return nil, fmt.Errorf("error fetching partition %v of topic %v: %w", fe.Partition, fe.Topic, fe.Err)
+	}
+	var records []ConsumerRecord
+	fetches.EachRecord(func(r *kgo.Record) {
+		record := ConsumerRecord{
+			Topic:     r.Topic,
+			Partition: r.Partition,
+			Offset:    r.Offset,
+			Timestamp: r.Timestamp,
+			Key:       r.Key,
+			Value:     r.Value,
+		}
+		for _, h := range r.Headers {
+			record.Headers = append(record.Headers, Header{Key: h.Key, Value: h.Value})
+		}
+		records = append(records, record)
+	})
+	return records, nil
+}
+
+func (c *kafkaConsumer) Close() {
+	c.cl.Close()
+}
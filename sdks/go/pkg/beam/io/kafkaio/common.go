@@ -0,0 +1,129 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kafkaio contains transforms for reading from and writing to Apache
+// Kafka (http://kafka.apache.org/) with a native Go client, which unlike
+// io/xlang/kafkaio doesn't require a Java expansion service.
+package kafkaio
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*ConsumerRecord)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*Header)(nil)).Elem())
+}
+
+// ConsumerRecord is a record read from a partition of a Kafka topic.
+type ConsumerRecord struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Timestamp time.Time
+	Key       []byte
+	Value     []byte
+	Headers   []Header
+}
+
+// Header is a header of a record. Records may have several headers with the
+// same key.
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// producerRecord is a record to be written to a Kafka topic.
+type producerRecord struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers []Header
+}
+
+// client is the subset of a Kafka client that is used by the transforms, so
+// that they can be tested against an in-process fake.
+type client interface {
+	// Partitions returns the partitions of a topic.
+	Partitions(ctx context.Context, topic string) ([]int32, error)
+	// StartOffset returns the offset of the first record of a partition.
+	StartOffset(ctx context.Context, topic string, partition int32) (int64, error)
+	// EndOffset returns the offset after the last record of a partition.
+	EndOffset(ctx context.Context, topic string, partition int32) (int64, error)
+	// OffsetAfter returns the offset of the first record of a partition at
+	// or after a time, or the end offset if there is no such record.
+	OffsetAfter(ctx context.Context, topic string, partition int32, t time.Time) (int64, error)
+	// CommittedOffset returns the offset committed by a consumer group for a
+	// partition, or -1 if none is committed.
+	CommittedOffset(ctx context.Context, group, topic string, partition int32) (int64, error)
+	// Commit commits the offset of the next record to read of a partition
+	// for a consumer group.
+	Commit(ctx context.Context, group, topic string, partition int32, offset int64) error
+	// Consume starts consuming a partition from an offset.
+	Consume(topic string, partition int32, offset int64) (consumer, error)
+	// Produce writes a record asynchronously.
+	Produce(ctx context.Context, r producerRecord)
+	// Flush waits for the records that are written to be acknowledged, and
+	// returns the first error of writing them since the last flush.
+	Flush(ctx context.Context) error
+	// Close closes the client.
+	Close()
+}
+
+// consumer consumes a partition.
+type consumer interface {
+	// Poll returns the next records of the partition, or no records if there
+	// are none within the poll timeout.
+	Poll(ctx context.Context) ([]ConsumerRecord, error)
+	// Close stops consuming the partition.
+	Close()
+}
+
+// newClient creates a client connected to a comma-separated list of
+// bootstrap servers. It's replaced by tests.
+var newClient = func(servers string) (client, error) {
+	return newKafkaClient(strings.Split(servers, ","))
+}
+
+// kafkaFn is embedded by the DoFns that connect to Kafka.
+type kafkaFn struct {
+	Servers string
+	client  client
+}
+
+func (fn *kafkaFn) Setup() error {
+	if fn.client != nil {
+		return nil
+	}
+	cl, err := newClient(fn.Servers)
+	if err != nil {
+		return fmt.Errorf("error connecting to Kafka: %v", err)
+	}
+	fn.client = cl
+	return nil
+}
+
+func (fn *kafkaFn) Teardown() {
+	if fn.client != nil {
+		fn.client.Close()
+		fn.client = nil
+	}
+}
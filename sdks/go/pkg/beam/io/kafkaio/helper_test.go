@@ -0,0 +1,217 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafkaio
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBrokers are in-process fakes of Kafka clusters by their servers, which
+// replace the clients of the transforms in tests.
+var (
+	fakeMu      sync.Mutex
+	fakeBrokers = make(map[string]*fakeBroker)
+)
+
+func init() {
+	newClient = func(servers string) (client, error) {
+		fakeMu.Lock()
+		defer fakeMu.Unlock()
+		b, ok := fakeBrokers[servers]
+		if !ok {
+			return nil, fmt.Errorf("no broker at %v", servers)
+		}
+		return b, nil
+	}
+}
+
+// newFakeBroker creates a fake cluster with topics with numbers of
+// partitions.
+func newFakeBroker(t *testing.T, topics map[string]int) (string, *fakeBroker) {
+	t.Helper()
+
+	b := &fakeBroker{
+		topics:    make(map[string][][]ConsumerRecord),
+		committed: make(map[string]int64),
+	}
+	for topic, n := range topics {
+		b.topics[topic] = make([][]ConsumerRecord, n)
+	}
+	servers := t.Name()
+
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	fakeBrokers[servers] = b
+	t.Cleanup(func() {
+		fakeMu.Lock()
+		defer fakeMu.Unlock()
+		delete(fakeBrokers, servers)
+	})
+	return servers, b
+}
+
+type fakeBroker struct {
+	mu        sync.Mutex
+	topics    map[string][][]ConsumerRecord
+	committed map[string]int64
+	produced  int
+}
+
+func committedKey(group, topic string, partition int32) string {
+	return fmt.Sprintf("%v/%v/%v", group, topic, partition)
+}
+
+// append appends a record to a partition, and returns its offset.
+func (b *fakeBroker) append(topic string, partition int32, r ConsumerRecord) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	records := b.topics[topic][partition]
+	r.Topic, r.Partition, r.Offset = topic, partition, int64(len(records))
+	b.topics[topic][partition] = append(records, r)
+	return r.Offset
+}
+
+func (b *fakeBroker) records(topic string, partition int32) []ConsumerRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]ConsumerRecord(nil), b.topics[topic][partition]...)
+}
+
+func (b *fakeBroker) commit(group, topic string, partition int32) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if offset, ok := b.committed[committedKey(group, topic, partition)]; ok {
+		return offset
+	}
+	return -1
+}
+
+func (b *fakeBroker) partition(topic string, partition int32) ([]ConsumerRecord, error) {
+	partitions, ok := b.topics[topic]
+	if !ok || partition < 0 || int(partition) >= len(partitions) {
+		return nil, fmt.Errorf("partition %v of topic %v not found", partition, topic)
+	}
+	return partitions[partition], nil
+}
+
+func (b *fakeBroker) Partitions(_ context.Context, topic string) ([]int32, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	partitions, ok := b.topics[topic]
+	if !ok {
+		return nil, fmt.Errorf("topic %v not found", topic)
+	}
+	var ps []int32
+	for p := range partitions {
+		ps = append(ps, int32(p))
+	}
+	return ps, nil
+}
+
+func (b *fakeBroker) StartOffset(_ context.Context, topic string, partition int32) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, err := b.partition(topic, partition)
+	return 0, err
+}
+
+func (b *fakeBroker) EndOffset(_ context.Context, topic string, partition int32) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	records, err := b.partition(topic, partition)
+	return int64(len(records)), err
+}
+
+func (b *fakeBroker) OffsetAfter(_ context.Context, topic string, partition int32, t time.Time) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	records, err := b.partition(topic, partition)
+	if err != nil {
+		return -1, err
+	}
+	for _, r := range records {
+		if !r.Timestamp.Before(t) {
+			return r.Offset, nil
+		}
+	}
+	return -1, nil
+}
+
+func (b *fakeBroker) CommittedOffset(_ context.Context, group, topic string, partition int32) (int64, error) {
+	return b.commit(group, topic, partition), nil
+}
+
+func (b *fakeBroker) Commit(_ context.Context, group, topic string, partition int32, offset int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.committed[committedKey(group, topic, partition)] = offset
+	return nil
+}
+
+func (b *fakeBroker) Consume(topic string, partition int32, offset int64) (consumer, error) {
+	return &fakeConsumer{b: b, topic: topic, partition: partition, offset: offset}, nil
+}
+
+func (b *fakeBroker) Produce(_ context.Context, r producerRecord) {
+	b.mu.Lock()
+	partitions := len(b.topics[r.Topic])
+	b.produced++
+	b.mu.Unlock()
+	var p int32
+	if partitions > 0 {
+		p = int32(len(r.Key) % partitions)
+	}
+	b.append(r.Topic, p, ConsumerRecord{Key: r.Key, Value: r.Value, Headers: r.Headers, Timestamp: time.Now()})
+}
+
+func (b *fakeBroker) Flush(context.Context) error {
+	return nil
+}
+
+func (b *fakeBroker) Close() {}
+
+type fakeConsumer struct {
+	b         *fakeBroker
+	topic     string
+	partition int32
+	offset    int64
+}
+
+// Poll returns up to two records at a time, so that reads take several polls.
+func (c *fakeConsumer) Poll(context.Context) ([]ConsumerRecord, error) {
+	c.b.mu.Lock()
+	defer c.b.mu.Unlock()
+	records, err := c.b.partition(c.topic, c.partition)
+	if err != nil {
+		return nil, err
+	}
+	if c.offset >= int64(len(records)) {
+		return nil, nil
+	}
+	end := c.offset + 2
+	if end > int64(len(records)) {
+		end = int64(len(records))
+	}
+	polled := append([]ConsumerRecord(nil), records[c.offset:end]...)
+	c.offset = end
+	return polled, nil
+}
+
+func (c *fakeConsumer) Close() {}
@@ -0,0 +1,393 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafkaio
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/rtrackers/offsetrange"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+)
+
+func init() {
+	register.DoFn3x1[context.Context, []byte, func(partition), error](&partitionsFn{})
+	register.DoFn6x2[
+		context.Context, *watermarkEstimator, beam.BundleFinalization, *sdf.LockRTracker, partition,
+		func(beam.EventTime, ConsumerRecord), sdf.ProcessContinuation, error,
+	](
+		&readFn{},
+	)
+	register.Emitter1[partition]()
+	register.Emitter2[beam.EventTime, ConsumerRecord]()
+	beam.RegisterType(reflect.TypeOf((*partition)(nil)).Elem())
+}
+
+const (
+	unboundedEnd  = math.MaxInt64
+	assumedLag    = 1 * time.Second
+	resumeDelay   = 5 * time.Second
+	commitTimeout = 5 * time.Minute
+	// maxEmptyPolls is the number of consecutive empty polls after which a
+	// bounded read of a partition is done, even though it hasn't reached its
+	// end offset, as the remaining offsets may be those of control records of
+	// transactions or of compacted records.
+	maxEmptyPolls = 3
+)
+
+// partition is a partition of a topic to read, from a start offset up to an
+// end offset, which is math.MaxInt64 for unbounded reads.
+type partition struct {
+	Topic     string
+	Partition int32
+	Start     int64
+	End       int64
+}
+
+// Read reads records from the partitions of Kafka topics and returns a
+// PCollection<ConsumerRecord>. The servers are a comma-separated list of
+// bootstrap servers, such as "localhost:9092". Each partition is read by a
+// splittable DoFn over a range of its offsets, which is unbounded unless
+// ReadStopAtEnd is set.
+//
+// Read takes a variable number of ReadOptionFn to configure the read operation:
+//   - ConsumerGroup: the consumer group whose committed offsets reading starts from. Defaults to
+//     none.
+//   - CommitOffsetsInFinalize: whether to commit the offsets of the records read for the consumer
+//     group once their bundles are finalized. Defaults to false.
+//   - FromEarliest, FromLatest, FromTimestamp: where reading partitions without committed offsets
+//     starts. Defaults to the latest records.
+//   - StopAtEnd: whether to stop reading at the last records when the pipeline starts. Defaults to
+//     false.
+//   - RecordTimePolicy, ProcessingTimePolicy: whether the record timestamps or the processing time
+//     is used as the event time and to estimate the watermark. Defaults to the record timestamps.
+func Read(s beam.Scope, servers string, topics []string, opts ...ReadOptionFn) beam.PCollection {
+	s = s.Scope("kafkaio.Read")
+
+	option := &readOption{
+		TimePolicy: recordTimePolicy,
+	}
+	for _, opt := range opts {
+		if err := opt(option); err != nil {
+			panic(fmt.Sprintf("kafkaio.Read: invalid option: %v", err))
+		}
+	}
+	if option.CommitOffsets && option.Group == "" {
+		panic("kafkaio.Read: committing offsets requires a consumer group")
+	}
+	if len(topics) == 0 {
+		panic("kafkaio.Read: no topics")
+	}
+
+	imp := beam.Impulse(s)
+	partitions := beam.ParDo(s, newPartitionsFn(servers, topics, option), imp)
+	partitions = beam.Reshuffle(s, partitions)
+	return beam.ParDo(s, newReadFn(servers, option), partitions)
+}
+
+// partitionsFn lists the partitions of the topics and their offset ranges.
+type partitionsFn struct {
+	kafkaFn
+	Topics    []string
+	Group     string
+	Start     startPolicy
+	StartTime time.Time
+	StopAtEnd bool
+}
+
+func newPartitionsFn(servers string, topics []string, option *readOption) *partitionsFn {
+	return &partitionsFn{
+		kafkaFn:   kafkaFn{Servers: servers},
+		Topics:    topics,
+		Group:     option.Group,
+		Start:     option.Start,
+		StartTime: option.StartTime,
+		StopAtEnd: option.StopAtEnd,
+	}
+}
+
+func (fn *partitionsFn) ProcessElement(ctx context.Context, _ []byte, emit func(partition)) error {
+	for _, topic := range fn.Topics {
+		partitions, err := fn.client.Partitions(ctx, topic)
+		if err != nil {
+			return fmt.Errorf("error listing partitions of topic %v: %v", topic, err)
+		}
+		for _, p := range partitions {
+			start, err := fn.startOffset(ctx, topic, p)
+			if err != nil {
+				return fmt.Errorf("error getting start offset of partition %v of topic %v: %v", p, topic, err)
+			}
+			end := int64(unboundedEnd)
+			if fn.StopAtEnd {
+				if end, err = fn.client.EndOffset(ctx, topic, p); err != nil {
+					return fmt.Errorf("error getting end offset of partition %v of topic %v: %v", p, topic, err)
+				}
+				if start >= end {
+					continue
+				}
+			}
+			emit(partition{Topic: topic, Partition: p, Start: start, End: end})
+		}
+	}
+	return nil
+}
+
+func (fn *partitionsFn) startOffset(ctx context.Context, topic string, p int32) (int64, error) {
+	if fn.Group != "" {
+		offset, err := fn.client.CommittedOffset(ctx, fn.Group, topic, p)
+		if err != nil {
+			return -1, err
+		}
+		if offset >= 0 {
+			return offset, nil
+		}
+	}
+	switch fn.Start {
+	case startEarliest:
+		return fn.client.StartOffset(ctx, topic, p)
+	case startTimestamp:
+		offset, err := fn.client.OffsetAfter(ctx, topic, p, fn.StartTime)
+		if err != nil || offset >= 0 {
+			return offset, err
+		}
+		return fn.client.EndOffset(ctx, topic, p)
+	default:
+		return fn.client.EndOffset(ctx, topic, p)
+	}
+}
+
+type readFn struct {
+	kafkaFn
+	Group         string
+	CommitOffsets bool
+	TimePolicy    timePolicy
+	MaxDelay      time.Duration
+	timestampFn   timestampFn
+}
+
+func newReadFn(servers string, option *readOption) *readFn {
+	return &readFn{
+		kafkaFn:       kafkaFn{Servers: servers},
+		Group:         option.Group,
+		CommitOffsets: option.CommitOffsets,
+		TimePolicy:    option.TimePolicy,
+		MaxDelay:      option.MaxDelay,
+	}
+}
+
+func (fn *readFn) Setup() error {
+	if err := fn.kafkaFn.Setup(); err != nil {
+		return err
+	}
+
+	fn.timestampFn = fn.TimePolicy.TimestampFn()
+	return nil
+}
+
+func (fn *readFn) CreateInitialRestriction(p partition) offsetrange.Restriction {
+	return offsetrange.Restriction{
+		Start: p.Start,
+		End:   p.End,
+	}
+}
+
+func (fn *readFn) SplitRestriction(_ partition, rest offsetrange.Restriction) []offsetrange.Restriction {
+	return []offsetrange.Restriction{rest}
+}
+
+func (fn *readFn) RestrictionSize(p partition, rest offsetrange.Restriction) (float64, error) {
+	if rest.End != unboundedEnd {
+		return rest.Size(), nil
+	}
+	if err := fn.kafkaFn.Setup(); err != nil {
+		return -1, err
+	}
+	end, err := fn.client.EndOffset(context.Background(), p.Topic, p.Partition)
+	if err != nil {
+		return -1, fmt.Errorf("error getting end offset of partition %v of topic %v: %v", p.Partition, p.Topic, err)
+	}
+	return math.Max(0, float64(end-rest.Start)), nil
+}
+
+func (fn *readFn) CreateTracker(rest offsetrange.Restriction) (*sdf.LockRTracker, error) {
+	estimator := &endEstimator{end: rest.Start}
+	rt, err := offsetrange.NewGrowableTracker(rest, estimator)
+	if err != nil {
+		return nil, fmt.Errorf("error creating growable tracker: %v", err)
+	}
+	return sdf.NewLockRTracker(&partitionTracker{GrowableTracker: rt, estimator: estimator}), nil
+}
+
+func (fn *readFn) TruncateRestriction(rt *sdf.LockRTracker, _ partition) offsetrange.Restriction {
+	start := rt.GetRestriction().(offsetrange.Restriction).Start
+	return offsetrange.Restriction{
+		Start: start,
+		End:   start,
+	}
+}
+
+func (fn *readFn) InitialWatermarkEstimatorState(
+	et beam.EventTime,
+	_ offsetrange.Restriction,
+	_ partition,
+) int64 {
+	return et.Milliseconds()
+}
+
+func (fn *readFn) CreateWatermarkEstimator(ms int64) *watermarkEstimator {
+	return &watermarkEstimator{state: ms, maxDelay: fn.MaxDelay}
+}
+
+func (fn *readFn) WatermarkEstimatorState(we *watermarkEstimator) int64 {
+	return we.state
+}
+
+func (fn *readFn) ProcessElement(
+	ctx context.Context,
+	we *watermarkEstimator,
+	bf beam.BundleFinalization,
+	rt *sdf.LockRTracker,
+	p partition,
+	emit func(beam.EventTime, ConsumerRecord),
+) (sdf.ProcessContinuation, error) {
+	if pt, ok := rt.Rt.(*partitionTracker); ok {
+		pt.estimator.bind(fn.client, p)
+	}
+
+	rest := rt.GetRestriction().(offsetrange.Restriction)
+	next := rest.Start
+	if fn.CommitOffsets {
+		defer func() {
+			if next > rest.Start {
+				fn.commitInFinalize(bf, p, next)
+			}
+		}()
+	}
+
+	cons, err := fn.client.Consume(p.Topic, p.Partition, rest.Start)
+	if err != nil {
+		return sdf.StopProcessing(), fmt.Errorf("error consuming partition %v of topic %v: %v", p.Partition, p.Topic, err)
+	}
+	defer cons.Close()
+
+	emptyPolls := 0
+	for {
+		records, err := cons.Poll(ctx)
+		if err != nil {
+			return sdf.StopProcessing(), err
+		}
+
+		for _, r := range records {
+			if r.Offset < next {
+				continue
+			}
+			if !rt.TryClaim(r.Offset) {
+				return sdf.StopProcessing(), nil
+			}
+			et := fn.timestampFn(r.Timestamp)
+			emit(et, r)
+			we.ObserveTimestamp(et.ToTime())
+			next = r.Offset + 1
+		}
+
+		if rest.End != unboundedEnd {
+			if next >= rest.End {
+				return sdf.StopProcessing(), nil
+			}
+			if len(records) > 0 {
+				emptyPolls = 0
+				continue
+			}
+			if emptyPolls++; emptyPolls >= maxEmptyPolls {
+				log.Warnf(ctx, "kafkaio: stopping read of partition %v of topic %v at offset %v before its end offset %v",
+					p.Partition, p.Topic, next, rest.End)
+				rt.TryClaim(rest.End)
+				return sdf.StopProcessing(), nil
+			}
+			continue
+		}
+
+		if len(records) == 0 {
+			fn.updateWatermarkManually(we)
+			return sdf.ResumeProcessingIn(resumeDelay), nil
+		}
+	}
+}
+
+// commitInFinalize commits the offset of the next record to read of a
+// partition once the bundle that read the previous records is finalized.
+func (fn *readFn) commitInFinalize(bf beam.BundleFinalization, p partition, offset int64) {
+	bf.RegisterCallback(commitTimeout, func() error {
+		if err := fn.kafkaFn.Setup(); err != nil {
+			return err
+		}
+		if err := fn.client.Commit(context.Background(), fn.Group, p.Topic, p.Partition, offset); err != nil {
+			return fmt.Errorf("error committing offset %v of partition %v of topic %v: %v", offset, p.Partition, p.Topic, err)
+		}
+		return nil
+	})
+}
+
+func (fn *readFn) updateWatermarkManually(we *watermarkEstimator) {
+	t := time.Now().Add(-1 * assumedLag)
+	et := fn.timestampFn(t)
+	we.ObserveTimestamp(et.ToTime())
+}
+
+// partitionTracker is a growable tracker of the offsets of a partition, whose
+// end estimator is bound to the partition once it's processed.
+type partitionTracker struct {
+	*offsetrange.GrowableTracker
+	estimator *endEstimator
+}
+
+// endEstimator estimates the end offset of a partition, which is the offset
+// after its last record.
+type endEstimator struct {
+	mu        sync.Mutex
+	client    client
+	topic     string
+	partition int32
+	end       int64
+}
+
+func (e *endEstimator) bind(cl client, p partition) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.client, e.topic, e.partition = cl, p.Topic, p.Partition
+}
+
+// Estimate returns the end offset of the partition, or the last estimate if
+// it can't be queried.
+func (e *endEstimator) Estimate() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.client == nil {
+		return e.end
+	}
+	end, err := e.client.EndOffset(context.Background(), e.topic, e.partition)
+	if err == nil && end > e.end {
+		e.end = end
+	}
+	return e.end
+}
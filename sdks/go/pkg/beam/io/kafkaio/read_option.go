@@ -0,0 +1,133 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafkaio
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	errInvalidGroup  = errors.New("consumer group must not be empty")
+	errInvalidMaxLag = errors.New("maximum delay must not be negative")
+)
+
+// startPolicy is where reading partitions starts, if their consumer group
+// hasn't committed an offset.
+type startPolicy int
+
+const (
+	startLatest startPolicy = iota
+	startEarliest
+	startTimestamp
+)
+
+type readOption struct {
+	Group         string
+	CommitOffsets bool
+	Start         startPolicy
+	StartTime     time.Time
+	StopAtEnd     bool
+	TimePolicy    timePolicy
+	MaxDelay      time.Duration
+}
+
+// ReadOptionFn is a function that can be passed to Read to configure options for reading
+// from Kafka.
+type ReadOptionFn func(option *readOption) error
+
+// ReadConsumerGroup sets the consumer group whose committed offsets reading starts from. Partitions
+// without committed offsets are read from the start position.
+func ReadConsumerGroup(group string) ReadOptionFn {
+	return func(o *readOption) error {
+		if group == "" {
+			return errInvalidGroup
+		}
+		o.Group = group
+		return nil
+	}
+}
+
+// ReadCommitOffsetsInFinalize specifies that the offsets of the records read are committed for
+// the consumer group when the bundles that read them are finalized, that is once the runner has
+// durably persisted their output. It requires ReadConsumerGroup.
+func ReadCommitOffsetsInFinalize() ReadOptionFn {
+	return func(o *readOption) error {
+		o.CommitOffsets = true
+		return nil
+	}
+}
+
+// ReadFromEarliest specifies that partitions are read from their first record. By default,
+// partitions are read from the records written after the pipeline starts.
+func ReadFromEarliest() ReadOptionFn {
+	return func(o *readOption) error {
+		o.Start = startEarliest
+		return nil
+	}
+}
+
+// ReadFromLatest specifies that partitions are read from the records written after the pipeline
+// starts. This is the default.
+func ReadFromLatest() ReadOptionFn {
+	return func(o *readOption) error {
+		o.Start = startLatest
+		return nil
+	}
+}
+
+// ReadFromTimestamp specifies that partitions are read from their first record whose timestamp is
+// at or after the time.
+func ReadFromTimestamp(t time.Time) ReadOptionFn {
+	return func(o *readOption) error {
+		o.Start = startTimestamp
+		o.StartTime = t
+		return nil
+	}
+}
+
+// ReadStopAtEnd specifies that partitions are only read up to their last records when the
+// pipeline starts, which makes the output bounded.
+func ReadStopAtEnd() ReadOptionFn {
+	return func(o *readOption) error {
+		o.StopAtEnd = true
+		return nil
+	}
+}
+
+// ReadRecordTimePolicy specifies that the timestamps of the records are used as their event times
+// and to compute the watermark estimate. This is the default. The watermark trails the latest
+// timestamp read by the maximum delay, which allows for records that are written out of order.
+func ReadRecordTimePolicy(maxDelay time.Duration) ReadOptionFn {
+	return func(o *readOption) error {
+		if maxDelay < 0 {
+			return errInvalidMaxLag
+		}
+		o.TimePolicy = recordTimePolicy
+		o.MaxDelay = maxDelay
+		return nil
+	}
+}
+
+// ReadProcessingTimePolicy specifies that the pipeline processing time of the records is used as
+// their event times and to compute the watermark estimate.
+func ReadProcessingTimePolicy() ReadOptionFn {
+	return func(o *readOption) error {
+		o.TimePolicy = processingTimePolicy
+		o.MaxDelay = 0
+		return nil
+	}
+}
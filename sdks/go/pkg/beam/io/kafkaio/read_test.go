@@ -0,0 +1,201 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafkaio
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/rtrackers/offsetrange"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+)
+
+func init() {
+	register.Function1x1(recordValue)
+	register.Function2x1(recordSkew)
+}
+
+func recordValue(r ConsumerRecord) string {
+	return string(r.Value)
+}
+
+// recordSkew returns the difference in milliseconds between the event time of
+// a record and its timestamp.
+func recordSkew(et beam.EventTime, r ConsumerRecord) float64 {
+	return float64(et.Milliseconds() - r.Timestamp.UnixMilli())
+}
+
+var baseTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// appendRecords appends records with values to a partition, one second
+// apart from the time.
+func appendRecords(b *fakeBroker, topic string, partition int32, t time.Time, values ...string) {
+	for i, v := range values {
+		b.append(topic, partition, ConsumerRecord{
+			Key:       []byte(v),
+			Value:     []byte(v),
+			Timestamp: t.Add(time.Duration(i) * time.Second),
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	servers, b := newFakeBroker(t, map[string]int{"events": 2, "other": 1})
+	appendRecords(b, "events", 0, baseTime, "a", "b", "c")
+	appendRecords(b, "events", 1, baseTime, "d", "e", "f", "g", "h")
+	appendRecords(b, "other", 0, baseTime, "x")
+
+	p, s := beam.NewPipelineWithRoot()
+	records := Read(s, servers, []string{"events"}, ReadFromEarliest(), ReadStopAtEnd())
+	passert.Equals(s, beam.ParDo(s, recordValue, records), "a", "b", "c", "d", "e", "f", "g", "h")
+	passert.AllWithinBounds(s, beam.ParDo(s, recordSkew, records), 0, 0)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestRead_FromTimestamp(t *testing.T) {
+	servers, b := newFakeBroker(t, map[string]int{"events": 1})
+	appendRecords(b, "events", 0, baseTime, "a", "b", "c", "d")
+
+	p, s := beam.NewPipelineWithRoot()
+	records := Read(s, servers, []string{"events"}, ReadFromTimestamp(baseTime.Add(2*time.Second)), ReadStopAtEnd())
+	passert.Equals(s, beam.ParDo(s, recordValue, records), "c", "d")
+	ptest.RunAndValidate(t, p)
+}
+
+func TestRead_CommitOffsets(t *testing.T) {
+	servers, b := newFakeBroker(t, map[string]int{"events": 2})
+	appendRecords(b, "events", 0, baseTime, "a", "b", "c")
+	appendRecords(b, "events", 1, baseTime, "d", "e")
+	if err := b.Commit(context.Background(), "group", "events", 0, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	p, s := beam.NewPipelineWithRoot()
+	records := Read(s, servers, []string{"events"},
+		ReadConsumerGroup("group"), ReadCommitOffsetsInFinalize(), ReadFromEarliest(), ReadStopAtEnd())
+	passert.Equals(s, beam.ParDo(s, recordValue, records), "b", "c", "d", "e")
+	ptest.RunAndValidate(t, p)
+
+	for partition, want := range []int64{3, 2} {
+		if got := b.commit("group", "events", int32(partition)); got != want {
+			t.Errorf("committed offset of partition %v = %v, want %v", partition, got, want)
+		}
+	}
+}
+
+func TestRead_InvalidOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []ReadOptionFn
+	}{
+		{"empty group", []ReadOptionFn{ReadConsumerGroup("")}},
+		{"commit without group", []ReadOptionFn{ReadCommitOffsetsInFinalize()}},
+		{"negative delay", []ReadOptionFn{ReadRecordTimePolicy(-time.Second)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Read() succeeded, want panic")
+				}
+			}()
+			_, s := beam.NewPipelineWithRoot()
+			Read(s, "servers", []string{"events"}, test.opts...)
+		})
+	}
+}
+
+type fakeBundleFinalization struct {
+	callbacks []func() error
+}
+
+func (f *fakeBundleFinalization) RegisterCallback(_ time.Duration, callback func() error) {
+	f.callbacks = append(f.callbacks, callback)
+}
+
+func TestReadFn_Unbounded(t *testing.T) {
+	servers, b := newFakeBroker(t, map[string]int{"events": 1})
+	appendRecords(b, "events", 0, baseTime, "a", "b", "c")
+
+	fn := newReadFn(servers, &readOption{Group: "group", CommitOffsets: true, MaxDelay: time.Minute})
+	if err := fn.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	p := partition{Topic: "events", Partition: 0, Start: 1, End: unboundedEnd}
+	rt, err := fn.CreateTracker(fn.CreateInitialRestriction(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt.IsBounded() {
+		t.Error("tracker of unbounded read is bounded")
+	}
+	we := fn.CreateWatermarkEstimator(math.MinInt64)
+	bf := &fakeBundleFinalization{}
+
+	var got []string
+	pc, err := fn.ProcessElement(context.Background(), we, bf, rt, p, func(_ beam.EventTime, r ConsumerRecord) {
+		got = append(got, string(r.Value))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"b", "c"}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("ProcessElement() emitted %v, want %v", got, want)
+	}
+	if !pc.ShouldResume() {
+		t.Error("ProcessElement() stopped processing, want resumption")
+	}
+	if got := we.CurrentWatermark(); got.Before(time.Now().Add(-time.Minute - 10*time.Second)) {
+		t.Errorf("watermark = %v, want it advanced to about a minute ago when idle", got)
+	}
+	_, before := rt.GetProgress()
+
+	if len(bf.callbacks) != 1 {
+		t.Fatalf("ProcessElement() registered %v finalization callbacks, want 1", len(bf.callbacks))
+	}
+	if got := b.commit("group", "events", 0); got != -1 {
+		t.Errorf("committed offset before finalization = %v, want -1", got)
+	}
+	if err := bf.callbacks[0](); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.commit("group", "events", 0); got != 3 {
+		t.Errorf("committed offset after finalization = %v, want 3", got)
+	}
+
+	appendRecords(b, "events", 0, baseTime, "d")
+	if _, after := rt.GetProgress(); after != before+1 {
+		t.Errorf("remaining work after a new record = %v, want %v", after, before+1)
+	}
+}
+
+func TestWatermarkEstimator(t *testing.T) {
+	we := &watermarkEstimator{state: 0, maxDelay: time.Second}
+	we.ObserveTimestamp(baseTime.Add(10 * time.Second))
+	we.ObserveTimestamp(baseTime)
+	if got, want := we.CurrentWatermark(), baseTime.Add(9*time.Second); !got.Equal(want) {
+		t.Errorf("CurrentWatermark() = %v, want %v", got, want)
+	}
+}
+
+var _ sdf.RTracker = (*partitionTracker)(nil)
+var _ offsetrange.RangeEndEstimator = (*endEstimator)(nil)
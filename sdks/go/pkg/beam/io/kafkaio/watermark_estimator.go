@@ -0,0 +1,68 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafkaio
+
+import (
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/mtime"
+)
+
+type timePolicy int
+
+const (
+	recordTimePolicy timePolicy = iota
+	processingTimePolicy
+)
+
+type timestampFn func(time.Time) mtime.Time
+
+func processingTime(_ time.Time) mtime.Time {
+	return mtime.Now()
+}
+
+func recordTime(t time.Time) mtime.Time {
+	return mtime.FromTime(t)
+}
+
+func (p timePolicy) TimestampFn() timestampFn {
+	switch p {
+	case recordTimePolicy:
+		return recordTime
+	case processingTimePolicy:
+		return processingTime
+	default:
+		panic("unsupported time policy")
+	}
+}
+
+// watermarkEstimator estimates the watermark of a partition as the latest
+// event time that is observed, less a maximum delay of records.
+type watermarkEstimator struct {
+	state    int64
+	maxDelay time.Duration
+}
+
+func (e *watermarkEstimator) CurrentWatermark() time.Time {
+	return time.UnixMilli(e.state)
+}
+
+func (e *watermarkEstimator) ObserveTimestamp(t time.Time) {
+	ms := t.Add(-e.maxDelay).UnixMilli()
+	if ms > e.state {
+		e.state = ms
+	}
+}
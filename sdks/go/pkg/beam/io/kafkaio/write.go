@@ -0,0 +1,152 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafkaio
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+)
+
+func init() {
+	register.DoFn2x1[context.Context, beam.X, error](&writeFn{})
+	register.DoFn3x1[context.Context, beam.X, beam.Y, error](&writeKVFn{})
+	register.Function1x1(serializeBytes)
+	register.Function1x1(serializeString)
+}
+
+func serializeBytes(b []byte) []byte {
+	return b
+}
+
+func serializeString(s string) []byte {
+	return []byte(s)
+}
+
+// Write writes a PCollection<KV<K,V>> or PCollection<V> to a Kafka topic, as records with the keys
+// and values of the elements. The servers are a comma-separated list of bootstrap servers, such as
+// "localhost:9092".
+//
+// Write takes a variable number of WriteOptionFn to configure the write operation:
+//   - KeySerializer: the function that converts keys to bytes. Required unless the keys are of type
+//     []byte or string.
+//   - ValueSerializer: the function that converts values to bytes. Required unless the values are
+//     of type []byte or string.
+//
+// Records are written with an idempotent producer, and each bundle waits for its records to be
+// acknowledged before it completes, so a record is only written more than once when a bundle is
+// retried after a failure.
+func Write(s beam.Scope, servers, topic string, col beam.PCollection, opts ...WriteOptionFn) {
+	s = s.Scope("kafkaio.Write")
+
+	option := &writeOption{}
+	for _, opt := range opts {
+		opt(option)
+	}
+
+	t := col.Type()
+	if typex.IsKV(t) {
+		beam.ParDo0(s, &writeKVFn{
+			writeFn: writeFn{
+				kafkaFn:         kafkaFn{Servers: servers},
+				Topic:           topic,
+				ValueSerializer: serializer("value", t.Components()[1].Type(), option.ValueSerializer),
+			},
+			KeySerializer: serializer("key", t.Components()[0].Type(), option.KeySerializer),
+		}, col)
+		return
+	}
+	beam.ParDo0(s, &writeFn{
+		kafkaFn:         kafkaFn{Servers: servers},
+		Topic:           topic,
+		ValueSerializer: serializer("value", t.Type(), option.ValueSerializer),
+	}, col)
+}
+
+// serializer returns the serializer of values of a type, which is the given
+// function if any, or a default serializer.
+func serializer(name string, t reflect.Type, fn any) beam.EncodedFunc {
+	if fn == nil {
+		switch t {
+		case reflectx.ByteSlice:
+			fn = serializeBytes
+		case reflectx.String:
+			fn = serializeString
+		default:
+			panic(fmt.Sprintf("kafkaio.Write: %v serializer required for type %v", name, t))
+		}
+	}
+	ft := reflect.TypeOf(fn)
+	if ft.Kind() != reflect.Func || ft.NumIn() != 1 || !t.AssignableTo(ft.In(0)) ||
+		ft.NumOut() < 1 || ft.NumOut() > 2 || ft.Out(0) != reflectx.ByteSlice ||
+		ft.NumOut() == 2 && ft.Out(1) != reflectx.Error {
+		panic(fmt.Sprintf("kafkaio.Write: %v serializer must be of the form func(%v) []byte or func(%v) ([]byte, error), got %v", name, t, t, ft))
+	}
+	return beam.EncodedFunc{Fn: reflectx.MakeFunc(fn)}
+}
+
+// serialize calls a serializer.
+func serialize(fn reflectx.Func, v any) ([]byte, error) {
+	out := fn.Call([]any{v})
+	if len(out) == 2 && out[1] != nil {
+		return nil, out[1].(error)
+	}
+	return out[0].([]byte), nil
+}
+
+type writeFn struct {
+	kafkaFn
+	Topic           string
+	ValueSerializer beam.EncodedFunc
+}
+
+func (fn *writeFn) ProcessElement(ctx context.Context, elem beam.X) error {
+	return fn.produce(ctx, nil, elem)
+}
+
+func (fn *writeFn) produce(ctx context.Context, key []byte, value any) error {
+	b, err := serialize(fn.ValueSerializer.Fn, value)
+	if err != nil {
+		return fmt.Errorf("error serializing value: %v", err)
+	}
+	fn.client.Produce(ctx, producerRecord{Topic: fn.Topic, Key: key, Value: b})
+	return nil
+}
+
+func (fn *writeFn) FinishBundle(ctx context.Context) error {
+	if err := fn.client.Flush(ctx); err != nil {
+		return fmt.Errorf("error writing records to topic %v: %v", fn.Topic, err)
+	}
+	return nil
+}
+
+type writeKVFn struct {
+	writeFn
+	KeySerializer beam.EncodedFunc
+}
+
+func (fn *writeKVFn) ProcessElement(ctx context.Context, key beam.X, value beam.Y) error {
+	b, err := serialize(fn.KeySerializer.Fn, key)
+	if err != nil {
+		return fmt.Errorf("error serializing key: %v", err)
+	}
+	return fn.produce(ctx, b, value)
+}
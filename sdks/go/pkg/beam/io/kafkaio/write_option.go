@@ -0,0 +1,43 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafkaio
+
+type writeOption struct {
+	KeySerializer   any
+	ValueSerializer any
+}
+
+// WriteOptionFn is a function that can be passed to Write to configure options for
+// writing records.
+type WriteOptionFn func(option *writeOption)
+
+// WriteKeySerializer sets the function that converts the keys of the elements into the keys of the
+// records. It must be a registered function of the form func(K) []byte or func(K) ([]byte, error).
+// Keys of type []byte or string don't need a serializer.
+func WriteKeySerializer(fn any) WriteOptionFn {
+	return func(o *writeOption) {
+		o.KeySerializer = fn
+	}
+}
+
+// WriteValueSerializer sets the function that converts the values of the elements into the values
+// of the records. It must be a registered function of the form func(V) []byte or
+// func(V) ([]byte, error). Values of type []byte or string don't need a serializer.
+func WriteValueSerializer(fn any) WriteOptionFn {
+	return func(o *writeOption) {
+		o.ValueSerializer = fn
+	}
+}
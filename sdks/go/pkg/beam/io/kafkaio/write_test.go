@@ -0,0 +1,90 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafkaio
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+)
+
+func init() {
+	register.Function1x2(serializeInt)
+	register.Function2x0(withLength)
+	register.Emitter2[string, int]()
+}
+
+func TestMain(m *testing.M) {
+	ptest.Main(m)
+}
+
+func withLength(v string, emit func(string, int)) {
+	emit(v, len(v))
+}
+
+func serializeInt(n int) ([]byte, error) {
+	return []byte(strconv.Itoa(n)), nil
+}
+
+func TestWrite(t *testing.T) {
+	servers, b := newFakeBroker(t, map[string]int{"events": 1})
+
+	p, s := beam.NewPipelineWithRoot()
+	kvs := beam.ParDo(s, withLength, beam.Create(s, "a", "bb"))
+	Write(s, servers, "events", kvs, WriteValueSerializer(serializeInt))
+	Write(s, servers, "events", beam.Create(s, []byte("ccc")))
+	ptest.RunAndValidate(t, p)
+
+	got := make(map[string]string)
+	for _, r := range b.records("events", 0) {
+		got[string(r.Key)] = string(r.Value)
+	}
+	want := map[string]string{"a": "1", "bb": "2", "": "ccc"}
+	if len(got) != len(want) {
+		t.Fatalf("Write() wrote %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("Write() wrote %v, want %v", got, want)
+		}
+	}
+}
+
+func TestWrite_InvalidSerializer(t *testing.T) {
+	tests := []struct {
+		name string
+		col  func(s beam.Scope) beam.PCollection
+		opts []WriteOptionFn
+	}{
+		{"missing", func(s beam.Scope) beam.PCollection { return beam.Create(s, 1) }, nil},
+		{"wrong type", func(s beam.Scope) beam.PCollection { return beam.Create(s, "a") }, []WriteOptionFn{WriteValueSerializer(serializeInt)}},
+		{"not a function", func(s beam.Scope) beam.PCollection { return beam.Create(s, 1) }, []WriteOptionFn{WriteValueSerializer(1)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Write() succeeded, want panic")
+				}
+			}()
+			_, s := beam.NewPipelineWithRoot()
+			Write(s, "servers", "events", test.col(s), test.opts...)
+		})
+	}
+}
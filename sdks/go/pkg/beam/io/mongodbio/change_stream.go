@@ -0,0 +1,401 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mongodbio
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultChangeStreamMaxAwaitTime = 1 * time.Second
+	// maxReadTime is how long a change stream is read before the restriction is checkpointed.
+	maxReadTime = 10 * time.Second
+	// resumeDelay is how long to wait before resuming an idle change stream.
+	resumeDelay = 1 * time.Second
+	// assumedLag is how far the watermark is held behind the current time when the change stream
+	// is idle.
+	assumedLag = 1 * time.Second
+)
+
+func init() {
+	register.DoFn5x2[
+		context.Context, *watermarkEstimator, *sdf.LockRTracker, []byte,
+		func(beam.EventTime, ChangeEvent), sdf.ProcessContinuation, error,
+	](&changeStreamFn{})
+	register.Emitter2[beam.EventTime, ChangeEvent]()
+}
+
+// ChangeEvent is a change event read from a MongoDB change stream. The documents are kept as raw
+// BSON, which can be decoded with bson.Unmarshal.
+type ChangeEvent struct {
+	// OperationType is the type of the operation that caused the event, such as "insert",
+	// "update", "replace" or "delete".
+	OperationType string `bson:"operationType"`
+	// Database and Collection are the namespace of the changed document.
+	Database   string `bson:"database"`
+	Collection string `bson:"collection"`
+	// DocumentKey holds the _id, and the shard key if any, of the changed document.
+	DocumentKey bson.Raw `bson:"documentKey,omitempty"`
+	// FullDocument is the document after the change, depending on the full document mode.
+	FullDocument bson.Raw `bson:"fullDocument,omitempty"`
+	// FullDocumentBeforeChange is the document before the change, depending on the full document
+	// before change mode.
+	FullDocumentBeforeChange bson.Raw `bson:"fullDocumentBeforeChange,omitempty"`
+	// UpdatedFields and RemovedFields describe the changes of an update event.
+	UpdatedFields bson.Raw `bson:"updatedFields,omitempty"`
+	RemovedFields []string `bson:"removedFields,omitempty"`
+	// ClusterTime is the time of the oplog entry of the change.
+	ClusterTime primitive.Timestamp `bson:"clusterTime"`
+	// ResumeToken is the resume token of the event.
+	ResumeToken bson.Raw `bson:"resumeToken,omitempty"`
+}
+
+// ReadChangeStream reads the change stream of a MongoDB collection and returns an unbounded
+// PCollection<ChangeEvent>. Change streams are only available on replica sets and sharded
+// clusters.
+//
+// The ReadChangeStream transform has the required parameters:
+//   - s: the scope of the pipeline
+//   - uri: the MongoDB connection string
+//   - database: the MongoDB database to read from
+//   - collection: the MongoDB collection to read from
+//
+// The ReadChangeStream transform takes a variadic number of ChangeStreamOptionFn which can set the
+// ChangeStreamOption fields:
+//   - Pipeline: an aggregation pipeline that is applied to the change events, e.g. to filter them
+//     with a $match stage. Defaults to nil, which means all change events are read
+//   - FullDocument: whether update events include the current version of the document. Defaults
+//     to options.Default, which means they do not
+//   - FullDocumentBeforeChange: whether events include the version of the document before the
+//     change. Defaults to options.Off
+//   - StartAtOperationTime: the time to start reading change events from. Defaults to the time
+//     the pipeline starts
+//   - MaxAwaitTime: the maximum time the server waits for new change events. Defaults to 1 second
+//
+// The event time of each change event is its cluster time. The restriction of the read holds the
+// resume token of the last change event read, so that the change stream resumes after it when
+// the read is checkpointed or retried. The change stream stops when it is invalidated, e.g. when
+// the collection is dropped or renamed.
+func ReadChangeStream(
+	s beam.Scope,
+	uri string,
+	database string,
+	collection string,
+	opts ...ChangeStreamOptionFn,
+) beam.PCollection {
+	s = s.Scope("mongodbio.ReadChangeStream")
+
+	option := &ChangeStreamOption{
+		MaxAwaitTime: defaultChangeStreamMaxAwaitTime,
+	}
+
+	for _, opt := range opts {
+		if err := opt(option); err != nil {
+			panic(fmt.Sprintf("mongodbio.ReadChangeStream: invalid option: %v", err))
+		}
+	}
+
+	imp := beam.Impulse(s)
+
+	return beam.ParDo(s, newChangeStreamFn(uri, database, collection, option), imp)
+}
+
+// changeStream is the part of a *mongo.ChangeStream that is used to read change events. It
+// allows tests to stand in for a replica set.
+type changeStream interface {
+	TryNext(ctx context.Context) bool
+	Decode(val any) error
+	ResumeToken() bson.Raw
+	Err() error
+	Close(ctx context.Context) error
+}
+
+// watchFn opens a change stream with the provided pipeline and options.
+type watchFn func(
+	ctx context.Context,
+	pipeline mongo.Pipeline,
+	opts *options.ChangeStreamOptions,
+) (changeStream, error)
+
+type changeStreamFn struct {
+	mongoDBFn
+	Pipeline                 []byte
+	FullDocument             string
+	FullDocumentBeforeChange string
+	StartAtOperationTime     int64
+	MaxAwaitTime             time.Duration
+	pipeline                 mongo.Pipeline
+	watch                    watchFn
+}
+
+// pipelineDocument wraps an aggregation pipeline so that it can be encoded as a BSON document.
+type pipelineDocument struct {
+	Stages mongo.Pipeline `bson:"stages"`
+}
+
+func newChangeStreamFn(
+	uri string,
+	database string,
+	collection string,
+	option *ChangeStreamOption,
+) *changeStreamFn {
+	pipeline, err := encodeBSON(pipelineDocument{Stages: option.Pipeline})
+	if err != nil {
+		panic(fmt.Sprintf("mongodbio.newChangeStreamFn: %v", err))
+	}
+
+	var startAt int64
+	if !option.StartAtOperationTime.IsZero() {
+		startAt = option.StartAtOperationTime.Unix()
+	}
+
+	return &changeStreamFn{
+		mongoDBFn: mongoDBFn{
+			URI:        uri,
+			Database:   database,
+			Collection: collection,
+		},
+		Pipeline:                 pipeline,
+		FullDocument:             string(option.FullDocument),
+		FullDocumentBeforeChange: string(option.FullDocumentBeforeChange),
+		StartAtOperationTime:     startAt,
+		MaxAwaitTime:             option.MaxAwaitTime,
+	}
+}
+
+func (fn *changeStreamFn) Setup(ctx context.Context) error {
+	doc, err := decodeBSON[pipelineDocument](fn.Pipeline)
+	if err != nil {
+		return err
+	}
+
+	fn.pipeline = doc.Stages
+
+	if fn.watch != nil {
+		return nil
+	}
+
+	if err := fn.mongoDBFn.Setup(ctx); err != nil {
+		return err
+	}
+
+	fn.watch = func(
+		ctx context.Context,
+		pipeline mongo.Pipeline,
+		opts *options.ChangeStreamOptions,
+	) (changeStream, error) {
+		return fn.collection.Watch(ctx, pipeline, opts)
+	}
+
+	return nil
+}
+
+func (fn *changeStreamFn) Teardown(ctx context.Context) error {
+	if fn.client == nil {
+		return nil
+	}
+
+	return fn.mongoDBFn.Teardown(ctx)
+}
+
+func (fn *changeStreamFn) CreateInitialRestriction(_ []byte) changeStreamRestriction {
+	startAt := fn.StartAtOperationTime
+	if startAt == 0 {
+		startAt = time.Now().Unix()
+	}
+
+	return changeStreamRestriction{
+		StartAtOperationTime: primitive.Timestamp{T: uint32(startAt)},
+	}
+}
+
+func (fn *changeStreamFn) SplitRestriction(
+	_ []byte,
+	rest changeStreamRestriction,
+) []changeStreamRestriction {
+	return []changeStreamRestriction{rest}
+}
+
+func (fn *changeStreamFn) CreateTracker(rest changeStreamRestriction) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(newChangeStreamTracker(rest))
+}
+
+func (fn *changeStreamFn) RestrictionSize(_ []byte, _ changeStreamRestriction) float64 {
+	return 1
+}
+
+func (fn *changeStreamFn) InitialWatermarkEstimatorState(
+	_ beam.EventTime,
+	_ changeStreamRestriction,
+	_ []byte,
+) int64 {
+	return math.MinInt64
+}
+
+func (fn *changeStreamFn) CreateWatermarkEstimator(state int64) *watermarkEstimator {
+	return &watermarkEstimator{state: state}
+}
+
+func (fn *changeStreamFn) WatermarkEstimatorState(we *watermarkEstimator) int64 {
+	return we.state
+}
+
+func (fn *changeStreamFn) ProcessElement(
+	ctx context.Context,
+	we *watermarkEstimator,
+	rt *sdf.LockRTracker,
+	_ []byte,
+	emit func(beam.EventTime, ChangeEvent),
+) (pc sdf.ProcessContinuation, err error) {
+	rest := rt.GetRestriction().(changeStreamRestriction)
+
+	stream, err := fn.watch(ctx, fn.pipeline, fn.changeStreamOptions(rest))
+	if err != nil {
+		return sdf.StopProcessing(), fmt.Errorf("error opening change stream: %w", err)
+	}
+
+	defer func() {
+		closeErr := stream.Close(ctx)
+
+		if err != nil {
+			if closeErr != nil {
+				log.Errorf(ctx, "error closing change stream: %v", closeErr)
+			}
+			return
+		}
+
+		if closeErr != nil {
+			err = fmt.Errorf("error closing change stream: %w", closeErr)
+		}
+	}()
+
+	deadline := time.Now().Add(maxReadTime)
+
+	for time.Now().Before(deadline) {
+		if !stream.TryNext(ctx) {
+			if err := stream.Err(); err != nil {
+				return sdf.StopProcessing(), fmt.Errorf("error reading change stream: %w", err)
+			}
+
+			if token := stream.ResumeToken(); token != nil && !rt.TryClaim(token) {
+				return sdf.StopProcessing(), nil
+			}
+
+			we.advance(time.Now().Add(-assumedLag))
+
+			return sdf.ResumeProcessingIn(resumeDelay), nil
+		}
+
+		var doc changeDocument
+		if err := stream.Decode(&doc); err != nil {
+			return sdf.StopProcessing(), fmt.Errorf("error decoding change event: %w", err)
+		}
+
+		if doc.OperationType == "invalidate" {
+			log.Infof(
+				ctx,
+				"Change stream of collection %s.%s was invalidated",
+				fn.Database,
+				fn.Collection,
+			)
+			rt.TryClaim(bson.Raw(nil))
+
+			return sdf.StopProcessing(), nil
+		}
+
+		if !rt.TryClaim(stream.ResumeToken()) {
+			return sdf.StopProcessing(), nil
+		}
+
+		event := doc.event()
+		eventTime := time.Unix(int64(event.ClusterTime.T), 0)
+
+		we.advance(eventTime)
+		emit(beam.EventTime(eventTime.UnixMilli()), event)
+	}
+
+	return sdf.ResumeProcessingIn(0), nil
+}
+
+// changeStreamOptions returns the options to open a change stream at the position of the
+// provided restriction.
+func (fn *changeStreamFn) changeStreamOptions(
+	rest changeStreamRestriction,
+) *options.ChangeStreamOptions {
+	opts := options.ChangeStream().SetMaxAwaitTime(fn.MaxAwaitTime)
+
+	if fn.FullDocument != "" {
+		opts.SetFullDocument(options.FullDocument(fn.FullDocument))
+	}
+
+	if fn.FullDocumentBeforeChange != "" {
+		opts.SetFullDocumentBeforeChange(options.FullDocument(fn.FullDocumentBeforeChange))
+	}
+
+	if rest.ResumeToken != nil {
+		opts.SetResumeAfter(rest.ResumeToken)
+	} else {
+		startAt := rest.StartAtOperationTime
+		opts.SetStartAtOperationTime(&startAt)
+	}
+
+	return opts
+}
+
+// changeDocument is a change event as returned by a MongoDB change stream.
+type changeDocument struct {
+	ID            bson.Raw `bson:"_id"`
+	OperationType string   `bson:"operationType"`
+	Namespace     struct {
+		Database   string `bson:"db"`
+		Collection string `bson:"coll"`
+	} `bson:"ns"`
+	DocumentKey              bson.Raw `bson:"documentKey"`
+	FullDocument             bson.Raw `bson:"fullDocument"`
+	FullDocumentBeforeChange bson.Raw `bson:"fullDocumentBeforeChange"`
+	UpdateDescription        struct {
+		UpdatedFields bson.Raw `bson:"updatedFields"`
+		RemovedFields []string `bson:"removedFields"`
+	} `bson:"updateDescription"`
+	ClusterTime primitive.Timestamp `bson:"clusterTime"`
+}
+
+func (doc changeDocument) event() ChangeEvent {
+	return ChangeEvent{
+		OperationType:            doc.OperationType,
+		Database:                 doc.Namespace.Database,
+		Collection:               doc.Namespace.Collection,
+		DocumentKey:              doc.DocumentKey,
+		FullDocument:             doc.FullDocument,
+		FullDocumentBeforeChange: doc.FullDocumentBeforeChange,
+		UpdatedFields:            doc.UpdateDescription.UpdatedFields,
+		RemovedFields:            doc.UpdateDescription.RemovedFields,
+		ClusterTime:              doc.ClusterTime,
+		ResumeToken:              doc.ID,
+	}
+}
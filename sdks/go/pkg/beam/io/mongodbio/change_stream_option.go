@@ -0,0 +1,106 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mongodbio
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ChangeStreamOption represents options for reading a change stream from MongoDB.
+type ChangeStreamOption struct {
+	Pipeline                 mongo.Pipeline
+	FullDocument             options.FullDocument
+	FullDocumentBeforeChange options.FullDocument
+	StartAtOperationTime     time.Time
+	MaxAwaitTime             time.Duration
+}
+
+// ChangeStreamOptionFn is a function that configures a ChangeStreamOption.
+type ChangeStreamOptionFn func(option *ChangeStreamOption) error
+
+// WithChangeStreamPipeline configures the ChangeStreamOption to use the provided aggregation
+// pipeline to filter and transform the change events.
+func WithChangeStreamPipeline(pipeline mongo.Pipeline) ChangeStreamOptionFn {
+	return func(o *ChangeStreamOption) error {
+		o.Pipeline = pipeline
+		return nil
+	}
+}
+
+// WithChangeStreamFullDocument configures the ChangeStreamOption to use the provided full document
+// mode, which determines whether update events include the current version of the document. The
+// mode must be one of options.Default, options.UpdateLookup, options.WhenAvailable or
+// options.Required.
+func WithChangeStreamFullDocument(fullDocument options.FullDocument) ChangeStreamOptionFn {
+	return func(o *ChangeStreamOption) error {
+		switch fullDocument {
+		case options.Default, options.UpdateLookup, options.WhenAvailable, options.Required:
+		default:
+			return fmt.Errorf("invalid full document mode: %q", fullDocument)
+		}
+
+		o.FullDocument = fullDocument
+		return nil
+	}
+}
+
+// WithChangeStreamFullDocumentBeforeChange configures the ChangeStreamOption to use the provided
+// mode for including the version of the document before the change. The mode must be one of
+// options.Off, options.WhenAvailable or options.Required, and the collection must have
+// changeStreamPreAndPostImages enabled for modes other than options.Off.
+func WithChangeStreamFullDocumentBeforeChange(fullDocument options.FullDocument) ChangeStreamOptionFn {
+	return func(o *ChangeStreamOption) error {
+		switch fullDocument {
+		case options.Off, options.WhenAvailable, options.Required:
+		default:
+			return fmt.Errorf("invalid full document before change mode: %q", fullDocument)
+		}
+
+		o.FullDocumentBeforeChange = fullDocument
+		return nil
+	}
+}
+
+// WithChangeStreamStartAtOperationTime configures the ChangeStreamOption to start reading change
+// events from the provided time.
+func WithChangeStreamStartAtOperationTime(startAt time.Time) ChangeStreamOptionFn {
+	return func(o *ChangeStreamOption) error {
+		if startAt.IsZero() {
+			return errors.New("start at operation time must be set")
+		}
+
+		o.StartAtOperationTime = startAt
+		return nil
+	}
+}
+
+// WithChangeStreamMaxAwaitTime configures the ChangeStreamOption to use the provided maximum time
+// the server waits for new change events before returning an empty batch.
+func WithChangeStreamMaxAwaitTime(maxAwaitTime time.Duration) ChangeStreamOptionFn {
+	return func(o *ChangeStreamOption) error {
+		if maxAwaitTime <= 0 {
+			return errors.New("max await time must be greater than 0")
+		}
+
+		o.MaxAwaitTime = maxAwaitTime
+		return nil
+	}
+}
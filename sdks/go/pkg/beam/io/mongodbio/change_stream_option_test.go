@@ -0,0 +1,206 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mongodbio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestWithChangeStreamPipeline(t *testing.T) {
+	tests := []struct {
+		name     string
+		pipeline mongo.Pipeline
+		want     mongo.Pipeline
+		wantErr  bool
+	}{
+		{
+			name: "Set pipeline to a $match stage",
+			pipeline: mongo.Pipeline{
+				{{Key: "$match", Value: bson.M{"operationType": "insert"}}},
+			},
+			want: mongo.Pipeline{
+				{{Key: "$match", Value: bson.M{"operationType": "insert"}}},
+			},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var option ChangeStreamOption
+
+			if err := WithChangeStreamPipeline(tt.pipeline)(&option); (err != nil) != tt.wantErr {
+				t.Fatalf("WithChangeStreamPipeline() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !cmp.Equal(option.Pipeline, tt.want) {
+				t.Errorf("option.Pipeline = %v, want %v", option.Pipeline, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithChangeStreamFullDocument(t *testing.T) {
+	tests := []struct {
+		name         string
+		fullDocument options.FullDocument
+		want         options.FullDocument
+		wantErr      bool
+	}{
+		{
+			name:         "Set full document to updateLookup",
+			fullDocument: options.UpdateLookup,
+			want:         options.UpdateLookup,
+			wantErr:      false,
+		},
+		{
+			name:         "Set full document to required",
+			fullDocument: options.Required,
+			want:         options.Required,
+			wantErr:      false,
+		},
+		{
+			name:         "Error - full document must be a valid mode",
+			fullDocument: options.Off,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var option ChangeStreamOption
+
+			if err := WithChangeStreamFullDocument(tt.fullDocument)(&option); (err != nil) != tt.wantErr {
+				t.Fatalf("WithChangeStreamFullDocument() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if option.FullDocument != tt.want {
+				t.Errorf("option.FullDocument = %v, want %v", option.FullDocument, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithChangeStreamFullDocumentBeforeChange(t *testing.T) {
+	tests := []struct {
+		name         string
+		fullDocument options.FullDocument
+		want         options.FullDocument
+		wantErr      bool
+	}{
+		{
+			name:         "Set full document before change to whenAvailable",
+			fullDocument: options.WhenAvailable,
+			want:         options.WhenAvailable,
+			wantErr:      false,
+		},
+		{
+			name:         "Error - full document before change must be a valid mode",
+			fullDocument: options.UpdateLookup,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var option ChangeStreamOption
+
+			err := WithChangeStreamFullDocumentBeforeChange(tt.fullDocument)(&option)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WithChangeStreamFullDocumentBeforeChange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if option.FullDocumentBeforeChange != tt.want {
+				t.Errorf(
+					"option.FullDocumentBeforeChange = %v, want %v",
+					option.FullDocumentBeforeChange,
+					tt.want,
+				)
+			}
+		})
+	}
+}
+
+func TestWithChangeStreamStartAtOperationTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		startAt time.Time
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name:    "Set start at operation time to 2022-01-01",
+			startAt: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			want:    time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			wantErr: false,
+		},
+		{
+			name:    "Error - start at operation time must be set",
+			startAt: time.Time{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var option ChangeStreamOption
+
+			if err := WithChangeStreamStartAtOperationTime(tt.startAt)(&option); (err != nil) != tt.wantErr {
+				t.Fatalf("WithChangeStreamStartAtOperationTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !option.StartAtOperationTime.Equal(tt.want) {
+				t.Errorf("option.StartAtOperationTime = %v, want %v", option.StartAtOperationTime, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithChangeStreamMaxAwaitTime(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxAwaitTime time.Duration
+		want         time.Duration
+		wantErr      bool
+	}{
+		{
+			name:         "Set max await time to 5 seconds",
+			maxAwaitTime: 5 * time.Second,
+			want:         5 * time.Second,
+			wantErr:      false,
+		},
+		{
+			name:         "Error - max await time must be greater than 0",
+			maxAwaitTime: 0,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var option ChangeStreamOption
+
+			if err := WithChangeStreamMaxAwaitTime(tt.maxAwaitTime)(&option); (err != nil) != tt.wantErr {
+				t.Fatalf("WithChangeStreamMaxAwaitTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if option.MaxAwaitTime != tt.want {
+				t.Errorf("option.MaxAwaitTime = %v, want %v", option.MaxAwaitTime, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mongodbio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	"github.com/google/go-cmp/cmp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fakeReplicaSet stands in for the oplog of a MongoDB replica set, from which change streams
+// are opened.
+type fakeReplicaSet struct {
+	events   []bson.Raw
+	lastOpts *options.ChangeStreamOptions
+}
+
+func (rs *fakeReplicaSet) append(t *testing.T, clusterTime uint32, doc bson.M) {
+	t.Helper()
+
+	doc["_id"] = bson.M{"_data": fmt.Sprintf("token-%d", len(rs.events))}
+	doc["clusterTime"] = primitive.Timestamp{T: clusterTime}
+	doc["ns"] = bson.M{"db": "db", "coll": "coll"}
+
+	rs.events = append(rs.events, mustMarshalBSON(t, doc))
+}
+
+func (rs *fakeReplicaSet) watch(
+	_ context.Context,
+	_ mongo.Pipeline,
+	opts *options.ChangeStreamOptions,
+) (changeStream, error) {
+	rs.lastOpts = opts
+
+	pos := 0
+
+	if opts.ResumeAfter != nil {
+		token := opts.ResumeAfter.(bson.Raw)
+		for pos < len(rs.events) && !bytes.Equal(rs.events[pos].Lookup("_id").Document(), token) {
+			pos++
+		}
+		pos++
+	} else if opts.StartAtOperationTime != nil {
+		for pos < len(rs.events) {
+			if t, _ := rs.events[pos].Lookup("clusterTime").Timestamp(); t >= opts.StartAtOperationTime.T {
+				break
+			}
+			pos++
+		}
+	}
+
+	return &fakeChangeStream{rs: rs, pos: pos}, nil
+}
+
+type fakeChangeStream struct {
+	rs      *fakeReplicaSet
+	pos     int
+	current bson.Raw
+	token   bson.Raw
+}
+
+func (cs *fakeChangeStream) TryNext(_ context.Context) bool {
+	if cs.pos >= len(cs.rs.events) {
+		cs.current = nil
+		return false
+	}
+
+	cs.current = cs.rs.events[cs.pos]
+	cs.token = cs.current.Lookup("_id").Document()
+	cs.pos++
+
+	return true
+}
+
+func (cs *fakeChangeStream) Decode(val any) error {
+	return bson.Unmarshal(cs.current, val)
+}
+
+func (cs *fakeChangeStream) ResumeToken() bson.Raw {
+	return cs.token
+}
+
+func (cs *fakeChangeStream) Err() error {
+	return nil
+}
+
+func (cs *fakeChangeStream) Close(_ context.Context) error {
+	return nil
+}
+
+func newTestChangeStreamFn(t *testing.T, rs *fakeReplicaSet, opts ...ChangeStreamOptionFn) *changeStreamFn {
+	t.Helper()
+
+	option := &ChangeStreamOption{
+		MaxAwaitTime: defaultChangeStreamMaxAwaitTime,
+	}
+
+	for _, opt := range opts {
+		if err := opt(option); err != nil {
+			t.Fatalf("invalid option: %v", err)
+		}
+	}
+
+	fn := newChangeStreamFn("mongodb://localhost:27017", "db", "coll", option)
+	fn.watch = rs.watch
+
+	if err := fn.Setup(context.Background()); err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+
+	return fn
+}
+
+func processChangeStream(
+	t *testing.T,
+	fn *changeStreamFn,
+	rest changeStreamRestriction,
+	we *watermarkEstimator,
+) (*sdf.LockRTracker, sdf.ProcessContinuation, []ChangeEvent, []beam.EventTime) {
+	t.Helper()
+
+	rt := fn.CreateTracker(rest)
+
+	var events []ChangeEvent
+	var times []beam.EventTime
+
+	pc, err := fn.ProcessElement(
+		context.Background(),
+		we,
+		rt,
+		nil,
+		func(et beam.EventTime, event ChangeEvent) {
+			times = append(times, et)
+			events = append(events, event)
+		},
+	)
+	if err != nil {
+		t.Fatalf("ProcessElement() error = %v", err)
+	}
+
+	return rt, pc, events, times
+}
+
+func Test_changeStreamFn_ProcessElement(t *testing.T) {
+	rs := &fakeReplicaSet{}
+	rs.append(t, 1640995100, bson.M{"operationType": "insert"})
+	rs.append(t, 1640995200, bson.M{
+		"operationType": "insert",
+		"documentKey":   bson.M{"_id": int32(1)},
+		"fullDocument":  bson.D{{Key: "_id", Value: int32(1)}, {Key: "field1", Value: int32(1)}},
+	})
+	rs.append(t, 1640995201, bson.M{
+		"operationType": "update",
+		"documentKey":   bson.M{"_id": int32(1)},
+		"fullDocument":  bson.D{{Key: "_id", Value: int32(1)}, {Key: "field1", Value: int32(2)}},
+		"updateDescription": bson.M{
+			"updatedFields": bson.M{"field1": int32(2)},
+			"removedFields": bson.A{"field2"},
+		},
+	})
+
+	fn := newTestChangeStreamFn(
+		t,
+		rs,
+		WithChangeStreamFullDocument(options.UpdateLookup),
+		WithChangeStreamStartAtOperationTime(time.Unix(1640995200, 0)),
+	)
+
+	rest := fn.CreateInitialRestriction(nil)
+	we := fn.CreateWatermarkEstimator(fn.InitialWatermarkEstimatorState(0, rest, nil))
+
+	rt, pc, events, times := processChangeStream(t, fn, rest, we)
+
+	if got, want := *rs.lastOpts.FullDocument, options.UpdateLookup; got != want {
+		t.Errorf("FullDocument = %v, want %v", got, want)
+	}
+
+	want := []ChangeEvent{
+		{
+			OperationType: "insert",
+			Database:      "db",
+			Collection:    "coll",
+			DocumentKey:   mustMarshalBSON(t, bson.M{"_id": int32(1)}),
+			FullDocument:  mustMarshalBSON(t, bson.D{{Key: "_id", Value: int32(1)}, {Key: "field1", Value: int32(1)}}),
+			ClusterTime:   primitive.Timestamp{T: 1640995200},
+			ResumeToken:   mustMarshalBSON(t, bson.M{"_data": "token-1"}),
+		},
+		{
+			OperationType: "update",
+			Database:      "db",
+			Collection:    "coll",
+			DocumentKey:   mustMarshalBSON(t, bson.M{"_id": int32(1)}),
+			FullDocument:  mustMarshalBSON(t, bson.D{{Key: "_id", Value: int32(1)}, {Key: "field1", Value: int32(2)}}),
+			UpdatedFields: mustMarshalBSON(t, bson.M{"field1": int32(2)}),
+			RemovedFields: []string{"field2"},
+			ClusterTime:   primitive.Timestamp{T: 1640995201},
+			ResumeToken:   mustMarshalBSON(t, bson.M{"_data": "token-2"}),
+		},
+	}
+	if diff := cmp.Diff(want, events); diff != "" {
+		t.Errorf("ProcessElement() emitted events mismatch (-want +got):\n%s", diff)
+	}
+
+	wantTimes := []beam.EventTime{
+		beam.EventTime(time.Unix(1640995200, 0).UnixMilli()),
+		beam.EventTime(time.Unix(1640995201, 0).UnixMilli()),
+	}
+	if diff := cmp.Diff(wantTimes, times); diff != "" {
+		t.Errorf("ProcessElement() event times mismatch (-want +got):\n%s", diff)
+	}
+
+	if !pc.ShouldResume() {
+		t.Error("ProcessElement() stopped processing, want resumption")
+	}
+
+	if got := we.CurrentWatermark(); got.Before(time.Now().Add(-time.Minute)) {
+		t.Errorf("watermark = %v, want it advanced to about the current time when idle", got)
+	}
+
+	_, residual, err := rt.TrySplit(0)
+	if err != nil {
+		t.Fatalf("TrySplit() error = %v", err)
+	}
+
+	if !rt.IsDone() {
+		t.Error("tracker is not done after checkpointing")
+	}
+
+	rs.append(t, 1640995300, bson.M{"operationType": "delete", "documentKey": bson.M{"_id": int32(1)}})
+
+	_, _, events, _ = processChangeStream(
+		t,
+		fn,
+		residual.(changeStreamRestriction),
+		fn.CreateWatermarkEstimator(we.state),
+	)
+
+	if got, want := rs.lastOpts.ResumeAfter, mustMarshalBSON(t, bson.M{"_data": "token-2"}); !cmp.Equal(got, want) {
+		t.Errorf("ResumeAfter = %v, want %v", got, want)
+	}
+
+	if len(events) != 1 || events[0].OperationType != "delete" {
+		t.Errorf("ProcessElement() after resuming emitted %v, want a single delete event", events)
+	}
+}
+
+func Test_changeStreamFn_ProcessElementInvalidate(t *testing.T) {
+	rs := &fakeReplicaSet{}
+	rs.append(t, 1640995200, bson.M{"operationType": "drop"})
+	rs.append(t, 1640995201, bson.M{"operationType": "invalidate"})
+	rs.append(t, 1640995202, bson.M{"operationType": "insert"})
+
+	fn := newTestChangeStreamFn(t, rs, WithChangeStreamStartAtOperationTime(time.Unix(1640995200, 0)))
+
+	rest := fn.CreateInitialRestriction(nil)
+	we := fn.CreateWatermarkEstimator(math.MinInt64)
+
+	rt, pc, events, _ := processChangeStream(t, fn, rest, we)
+
+	if pc.ShouldResume() {
+		t.Error("ProcessElement() resumed processing, want it stopped after invalidate")
+	}
+
+	if !rt.IsDone() {
+		t.Error("tracker is not done after invalidate")
+	}
+
+	if len(events) != 1 || events[0].OperationType != "drop" {
+		t.Errorf("ProcessElement() emitted %v, want a single drop event", events)
+	}
+}
+
+func Test_changeStreamFn_CreateInitialRestriction(t *testing.T) {
+	fn := newTestChangeStreamFn(t, &fakeReplicaSet{})
+
+	before := time.Now().Unix()
+	rest := fn.CreateInitialRestriction(nil)
+
+	if rest.ResumeToken != nil {
+		t.Errorf("ResumeToken = %v, want nil", rest.ResumeToken)
+	}
+
+	if got := int64(rest.StartAtOperationTime.T); got < before || got > time.Now().Unix() {
+		t.Errorf("StartAtOperationTime = %v, want the current time", got)
+	}
+}
@@ -0,0 +1,125 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mongodbio
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*changeStreamTracker)(nil)))
+}
+
+// changeStreamRestriction represents the position in a change stream to read from. The change
+// stream is resumed after the ResumeToken if set, or started at the StartAtOperationTime otherwise.
+type changeStreamRestriction struct {
+	ResumeToken          bson.Raw            `bson:"resumeToken,omitempty"`
+	StartAtOperationTime primitive.Timestamp `bson:"startAtOperationTime"`
+}
+
+// changeStreamTracker is a tracker of a changeStreamRestriction. The restriction is unbounded and
+// can only be split to checkpoint.
+type changeStreamTracker struct {
+	rest    changeStreamRestriction
+	stopped bool
+	err     error
+}
+
+// newChangeStreamTracker creates a new changeStreamTracker tracking the provided
+// changeStreamRestriction.
+func newChangeStreamTracker(rest changeStreamRestriction) *changeStreamTracker {
+	return &changeStreamTracker{
+		rest: rest,
+	}
+}
+
+// TryClaim accepts a position representing the resume token of a change event, or of an empty
+// batch of change events, to read from MongoDB. The position is successfully claimed if the tracker
+// has not been stopped. An empty resume token represents the end of the change stream, which
+// stops the tracker.
+func (rt *changeStreamTracker) TryClaim(pos any) (ok bool) {
+	token, ok := pos.(bson.Raw)
+	if !ok {
+		rt.err = fmt.Errorf("invalid pos type: %T", pos)
+		return false
+	}
+
+	if rt.IsDone() {
+		return false
+	}
+
+	if len(token) == 0 {
+		rt.stopped = true
+		return false
+	}
+
+	rt.rest.ResumeToken = token
+
+	return true
+}
+
+// GetError returns the error associated with the tracker, if any.
+func (rt *changeStreamTracker) GetError() error {
+	return rt.err
+}
+
+// TrySplit checkpoints the tracker if the fraction is 0, by stopping it and returning a residual
+// that resumes the change stream after the last claimed position. Otherwise, returns the full
+// restriction as the primary and nil as the residual.
+func (rt *changeStreamTracker) TrySplit(fraction float64) (primary, residual any, err error) {
+	if fraction < 0 || fraction > 1 {
+		return nil, nil, errors.New("fraction must be between 0 and 1")
+	}
+
+	if fraction != 0 || rt.IsDone() {
+		return rt.rest, nil, nil
+	}
+
+	rt.stopped = true
+
+	return rt.rest, rt.rest, nil
+}
+
+// GetProgress returns the amount of done and remaining work. As the amount of remaining change
+// events is unknown, the remaining work is reported as 1 until the tracker is stopped.
+func (rt *changeStreamTracker) GetProgress() (done float64, remaining float64) {
+	if rt.stopped {
+		return 1, 0
+	}
+
+	return 0, 1
+}
+
+// IsDone returns true if the tracker has been stopped.
+func (rt *changeStreamTracker) IsDone() bool {
+	return rt.stopped
+}
+
+// GetRestriction returns a copy of the restriction the tracker is tracking.
+func (rt *changeStreamTracker) GetRestriction() any {
+	return rt.rest
+}
+
+// IsBounded returns whether the tracker is tracking a restriction with a finite amount of work.
+func (*changeStreamTracker) IsBounded() bool {
+	return false
+}
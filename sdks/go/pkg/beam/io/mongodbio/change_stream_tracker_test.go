@@ -0,0 +1,158 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mongodbio
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func Test_changeStreamTracker_TryClaim(t *testing.T) {
+	token := mustMarshalBSON(t, bson.M{"_data": "token"})
+
+	tests := []struct {
+		name      string
+		tracker   *changeStreamTracker
+		pos       any
+		wantOk    bool
+		wantToken bson.Raw
+		wantDone  bool
+		wantErr   bool
+	}{
+		{
+			name:      "Return true and update the resume token when claiming a token",
+			tracker:   &changeStreamTracker{},
+			pos:       token,
+			wantOk:    true,
+			wantToken: token,
+			wantDone:  false,
+		},
+		{
+			name:      "Return false and set to done when claiming an empty token",
+			tracker:   &changeStreamTracker{},
+			pos:       bson.Raw(nil),
+			wantOk:    false,
+			wantToken: nil,
+			wantDone:  true,
+		},
+		{
+			name:      "Return false when the tracker is stopped",
+			tracker:   &changeStreamTracker{stopped: true},
+			pos:       token,
+			wantOk:    false,
+			wantToken: nil,
+			wantDone:  true,
+		},
+		{
+			name:     "Return false and set error when pos is of invalid type",
+			tracker:  &changeStreamTracker{},
+			pos:      "invalid",
+			wantOk:   false,
+			wantDone: false,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotOk := tt.tracker.TryClaim(tt.pos); gotOk != tt.wantOk {
+				t.Errorf("TryClaim() = %v, want %v", gotOk, tt.wantOk)
+			}
+
+			if !cmp.Equal(tt.tracker.rest.ResumeToken, tt.wantToken) {
+				t.Errorf("tracker.rest.ResumeToken = %v, want %v", tt.tracker.rest.ResumeToken, tt.wantToken)
+			}
+
+			if gotDone := tt.tracker.IsDone(); gotDone != tt.wantDone {
+				t.Errorf("IsDone() = %v, want %v", gotDone, tt.wantDone)
+			}
+
+			if gotErr := tt.tracker.GetError(); (gotErr != nil) != tt.wantErr {
+				t.Errorf("GetError() error = %v, wantErr %v", gotErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_changeStreamTracker_TrySplit(t *testing.T) {
+	rest := changeStreamRestriction{
+		ResumeToken:          mustMarshalBSON(t, bson.M{"_data": "token"}),
+		StartAtOperationTime: primitive.Timestamp{T: 1640995200},
+	}
+
+	tests := []struct {
+		name         string
+		tracker      *changeStreamTracker
+		fraction     float64
+		wantPrimary  any
+		wantResidual any
+		wantDone     bool
+		wantErr      bool
+	}{
+		{
+			name:         "Checkpoint with the restriction as residual when fraction is 0",
+			tracker:      &changeStreamTracker{rest: rest},
+			fraction:     0,
+			wantPrimary:  rest,
+			wantResidual: rest,
+			wantDone:     true,
+		},
+		{
+			name:         "Return nil residual when fraction is greater than 0",
+			tracker:      &changeStreamTracker{rest: rest},
+			fraction:     0.5,
+			wantPrimary:  rest,
+			wantResidual: nil,
+			wantDone:     false,
+		},
+		{
+			name:         "Return nil residual when the tracker is stopped",
+			tracker:      &changeStreamTracker{rest: rest, stopped: true},
+			fraction:     0,
+			wantPrimary:  rest,
+			wantResidual: nil,
+			wantDone:     true,
+		},
+		{
+			name:     "Error - fraction is less than 0",
+			tracker:  &changeStreamTracker{rest: rest},
+			fraction: -0.1,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotPrimary, gotResidual, err := tt.tracker.TrySplit(tt.fraction)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TrySplit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !cmp.Equal(gotPrimary, tt.wantPrimary) {
+				t.Errorf("TrySplit() gotPrimary = %v, want %v", gotPrimary, tt.wantPrimary)
+			}
+
+			if !cmp.Equal(gotResidual, tt.wantResidual) {
+				t.Errorf("TrySplit() gotResidual = %v, want %v", gotResidual, tt.wantResidual)
+			}
+
+			if gotDone := tt.tracker.IsDone(); gotDone != tt.wantDone {
+				t.Errorf("IsDone() = %v, want %v", gotDone, tt.wantDone)
+			}
+		})
+	}
+}
@@ -98,6 +98,87 @@ func Test_encodeDecodeIDRangeRestriction(t *testing.T) {
 	}
 }
 
+func Test_encodeDecodeChangeStreamRestriction(t *testing.T) {
+	tests := []struct {
+		name string
+		rest changeStreamRestriction
+	}{
+		{
+			name: "Encode/decode changeStreamRestriction with resume token",
+			rest: changeStreamRestriction{
+				ResumeToken:          mustMarshalBSON(t, bson.M{"_data": "826470A3B6000000012B"}),
+				StartAtOperationTime: primitive.Timestamp{T: 1640995200},
+			},
+		},
+		{
+			name: "Encode/decode changeStreamRestriction without resume token",
+			rest: changeStreamRestriction{
+				StartAtOperationTime: primitive.Timestamp{T: 1640995200},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := encodeChangeStreamRestriction(tt.rest)
+			if err != nil {
+				t.Fatalf("encodeChangeStreamRestriction() error = %v", err)
+			}
+
+			decoded, err := decodeChangeStreamRestriction(encoded)
+			if err != nil {
+				t.Fatalf("decodeChangeStreamRestriction() error = %v", err)
+			}
+
+			if diff := cmp.Diff(tt.rest, decoded); diff != "" {
+				t.Errorf("encode/decode mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_encodeDecodeChangeEvent(t *testing.T) {
+	tests := []struct {
+		name  string
+		event ChangeEvent
+	}{
+		{
+			name: "Encode/decode update ChangeEvent",
+			event: ChangeEvent{
+				OperationType: "update",
+				Database:      "db",
+				Collection:    "coll",
+				DocumentKey:   mustMarshalBSON(t, bson.M{"_id": int32(1)}),
+				FullDocument:  mustMarshalBSON(t, bson.M{"_id": int32(1), "field1": int32(2)}),
+				UpdatedFields: mustMarshalBSON(t, bson.M{"field1": int32(2)}),
+				RemovedFields: []string{"field2"},
+				ClusterTime:   primitive.Timestamp{T: 1640995200, I: 1},
+				ResumeToken:   mustMarshalBSON(t, bson.M{"_data": "826470A3B6000000012B"}),
+			},
+		},
+		{
+			name:  "Encode/decode empty ChangeEvent",
+			event: ChangeEvent{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := encodeChangeEvent(tt.event)
+			if err != nil {
+				t.Fatalf("encodeChangeEvent() error = %v", err)
+			}
+
+			decoded, err := decodeChangeEvent(encoded)
+			if err != nil {
+				t.Fatalf("decodeChangeEvent() error = %v", err)
+			}
+
+			if diff := cmp.Diff(tt.event, decoded); diff != "" {
+				t.Errorf("encode/decode mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func Test_encodeDecodeObjectID(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -27,6 +27,8 @@ import (
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/x/debug"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func ExampleRead_default() {
@@ -178,3 +180,37 @@ func ExampleWrite_generateID() {
 		log.Fatalf("Failed to execute job: %v", err)
 	}
 }
+
+func ExampleReadChangeStream_default() {
+	beam.Init()
+	p, s := beam.NewPipelineWithRoot()
+
+	col := mongodbio.ReadChangeStream(s, "mongodb://localhost:27017", "demo", "events")
+	debug.Print(s, col)
+
+	if err := beamx.Run(context.Background(), p); err != nil {
+		log.Fatalf("Failed to execute job: %v", err)
+	}
+}
+
+func ExampleReadChangeStream_options() {
+	beam.Init()
+	p, s := beam.NewPipelineWithRoot()
+
+	col := mongodbio.ReadChangeStream(
+		s,
+		"mongodb://localhost:27017",
+		"demo",
+		"events",
+		mongodbio.WithChangeStreamPipeline(mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"operationType": bson.M{"$in": bson.A{"insert", "update"}}}}},
+		}),
+		mongodbio.WithChangeStreamFullDocument(options.UpdateLookup),
+		mongodbio.WithChangeStreamStartAtOperationTime(time.UnixMilli(1640995200000)),
+	)
+	debug.Print(s, col)
+
+	if err := beamx.Run(context.Background(), p); err != nil {
+		log.Fatalf("Failed to execute job: %v", err)
+	}
+}
@@ -18,6 +18,7 @@ package mongodbio
 import (
 	"testing"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -31,3 +32,14 @@ func objectIDFromHex(t *testing.T, hex string) primitive.ObjectID {
 
 	return id
 }
+
+func mustMarshalBSON(t *testing.T, val any) bson.Raw {
+	t.Helper()
+
+	raw, err := bson.Marshal(val)
+	if err != nil {
+		t.Fatalf("error marshaling BSON: %v", err)
+	}
+
+	return raw
+}
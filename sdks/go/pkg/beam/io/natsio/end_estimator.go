@@ -19,7 +19,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/rtrackers/offsetrange"
 	"github.com/nats-io/nats.go/jetstream"
 )
 
@@ -75,3 +77,76 @@ func isMessageNotFound(err error) bool {
 
 	return false
 }
+
+type consumerEndEstimator struct {
+	js       jetstream.JetStream
+	stream   string
+	consumer string
+}
+
+func newConsumerEndEstimator(js jetstream.JetStream, stream string, consumer string) *consumerEndEstimator {
+	return &consumerEndEstimator{
+		js:       js,
+		stream:   stream,
+		consumer: consumer,
+	}
+}
+
+func (e *consumerEndEstimator) Estimate() int64 {
+	ctx := context.Background()
+	end, err := e.getEndSeqNo(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return end
+}
+
+// getEndSeqNo returns the consumer sequence number after the last message
+// that is pending for the consumer.
+func (e *consumerEndEstimator) getEndSeqNo(ctx context.Context) (int64, error) {
+	cons, err := e.js.Consumer(ctx, e.stream, e.consumer)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrConsumerNotFound) {
+			return 1, nil
+		}
+
+		return -1, fmt.Errorf("error getting consumer: %v", err)
+	}
+
+	info, err := cons.Info(ctx)
+	if err != nil {
+		return -1, fmt.Errorf("error getting consumer info: %v", err)
+	}
+
+	return int64(info.Delivered.Consumer+info.NumPending) + 1, nil
+}
+
+// sourceTracker is a growable tracker of the messages of a source, whose end
+// estimator is bound to the source once it's known.
+type sourceTracker struct {
+	*offsetrange.GrowableTracker
+	estimator *sourceEndEstimator
+}
+
+// sourceEndEstimator estimates the end of a source with the estimator that
+// it's bound to, or returns the last estimate if it isn't bound.
+type sourceEndEstimator struct {
+	mu        sync.Mutex
+	estimator offsetrange.RangeEndEstimator
+	end       int64
+}
+
+func (e *sourceEndEstimator) bind(estimator offsetrange.RangeEndEstimator) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.estimator = estimator
+}
+
+func (e *sourceEndEstimator) Estimate() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.estimator != nil {
+		e.end = e.estimator.Estimate()
+	}
+	return e.end
+}
@@ -71,3 +71,35 @@ func ExampleWrite() {
 		log.Fatalf("Failed to execute job: %v", err)
 	}
 }
+
+func ExampleReadKeyValue() {
+	beam.Init()
+
+	p, s := beam.NewPipelineWithRoot()
+
+	uri := "nats://localhost:4222"
+	bucket := "CONFIG"
+
+	col := natsio.ReadKeyValue(s, uri, bucket, natsio.KeyValueKeys("services.*"))
+	debug.Print(s, col)
+
+	if err := beamx.Run(context.Background(), p); err != nil {
+		log.Fatalf("Failed to execute job: %v", err)
+	}
+}
+
+func ExampleReadObjects() {
+	beam.Init()
+
+	p, s := beam.NewPipelineWithRoot()
+
+	uri := "nats://localhost:4222"
+	bucket := "FILES"
+
+	col := natsio.ReadObjects(s, uri, bucket)
+	debug.Print(s, col)
+
+	if err := beamx.Run(context.Background(), p); err != nil {
+		log.Fatalf("Failed to execute job: %v", err)
+	}
+}
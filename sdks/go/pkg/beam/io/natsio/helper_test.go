@@ -29,11 +29,12 @@ import (
 func newServer(t *testing.T) *server.Server {
 	t.Helper()
 
-	opts := &test.DefaultTestOptions
+	opts := test.DefaultTestOptions
 	opts.Port = server.RANDOM_PORT
 	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
 
-	srv := test.RunServer(opts)
+	srv := test.RunServer(&opts)
 	t.Cleanup(srv.Shutdown)
 
 	return srv
@@ -172,3 +173,54 @@ func messageTimestamp(t *testing.T, msg jetstream.Msg) time.Time {
 
 	return metadata.Timestamp
 }
+
+func createKeyValue(
+	ctx context.Context,
+	t *testing.T,
+	js jetstream.JetStream,
+	bucket string,
+) jetstream.KeyValue {
+	t.Helper()
+
+	cfg := jetstream.KeyValueConfig{
+		Bucket:  bucket,
+		History: 10,
+	}
+	kv, err := js.CreateKeyValue(ctx, cfg)
+	if err != nil {
+		t.Fatalf("Failed to create bucket: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := js.DeleteKeyValue(ctx, bucket); err != nil {
+			t.Fatalf("Failed to delete bucket: %v", err)
+		}
+	})
+
+	return kv
+}
+
+func createObjectStore(
+	ctx context.Context,
+	t *testing.T,
+	js jetstream.JetStream,
+	bucket string,
+) jetstream.ObjectStore {
+	t.Helper()
+
+	cfg := jetstream.ObjectStoreConfig{
+		Bucket: bucket,
+	}
+	obs, err := js.CreateObjectStore(ctx, cfg)
+	if err != nil {
+		t.Fatalf("Failed to create bucket: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := js.DeleteObjectStore(ctx, bucket); err != nil {
+			t.Fatalf("Failed to delete bucket: %v", err)
+		}
+	})
+
+	return obs
+}
@@ -0,0 +1,379 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package natsio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/rtrackers/offsetrange"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func init() {
+	register.DoFn3x1[context.Context, []byte, func(KeyValueEntry), error](&readKeyValueFn{})
+	register.DoFn5x2[
+		context.Context, *watermarkEstimator, *sdf.LockRTracker, []byte,
+		func(beam.EventTime, KeyValueEntry), sdf.ProcessContinuation, error,
+	](
+		&watchKeyValueFn{},
+	)
+	register.DoFn3x1[context.Context, string, []byte, error](&writeKeyValueFn{})
+	register.Emitter1[KeyValueEntry]()
+	register.Emitter2[beam.EventTime, KeyValueEntry]()
+	beam.RegisterType(reflect.TypeOf((*KeyValueEntry)(nil)).Elem())
+}
+
+// KeyValueEntry represents a revision of a key in a NATS JetStream KeyValue bucket.
+type KeyValueEntry struct {
+	Bucket   string
+	Key      string
+	Value    []byte
+	Revision int64
+	Created  time.Time
+	// Deleted is true if the key was deleted or purged at the revision.
+	Deleted bool
+}
+
+// ReadKeyValue reads the current values of the keys of a NATS JetStream KeyValue bucket and returns
+// a bounded PCollection<KeyValueEntry>. Keys that are deleted aren't read.
+// ReadKeyValue takes a variable number of KeyValueOptionFn to configure the read operation:
+//   - UserCredentials: path to the user credentials file. Defaults to empty.
+//   - Keys: the keys to read, which may contain wildcards. Defaults to all keys.
+func ReadKeyValue(s beam.Scope, uri string, bucket string, opts ...KeyValueOptionFn) beam.PCollection {
+	s = s.Scope("natsio.ReadKeyValue")
+
+	option := newKeyValueOption("natsio.ReadKeyValue", opts)
+
+	imp := beam.Impulse(s)
+	return beam.ParDo(s, &readKeyValueFn{
+		natsFn: natsFn{
+			URI:       uri,
+			CredsFile: option.CredsFile,
+		},
+		Bucket: bucket,
+		Keys:   option.Keys,
+	}, imp)
+}
+
+// WatchKeyValue watches the keys of a NATS JetStream KeyValue bucket and returns an unbounded
+// PCollection<KeyValueEntry> of their revisions. Revisions that delete keys are included, with
+// Deleted set to true. WatchKeyValue takes a variable number of KeyValueOptionFn to configure the
+// watch operation:
+//   - UserCredentials: path to the user credentials file. Defaults to empty.
+//   - ProcessingTimePolicy: whether to use the pipeline processing time of the entries as the event
+//     time. Defaults to true.
+//   - PublishingTimePolicy: whether to use the creation time of the entries as the event time.
+//     Defaults to false.
+//   - Keys: the keys to watch, which may contain wildcards. Defaults to all keys.
+//   - StartRevision: the revision to watch the keys from, including their history. Defaults to
+//     none, in which case the current values of the keys are read before their later revisions.
+func WatchKeyValue(s beam.Scope, uri string, bucket string, opts ...KeyValueOptionFn) beam.PCollection {
+	s = s.Scope("natsio.WatchKeyValue")
+
+	option := newKeyValueOption("natsio.WatchKeyValue", opts)
+
+	imp := beam.Impulse(s)
+	return beam.ParDo(s, &watchKeyValueFn{
+		natsFn: natsFn{
+			URI:       uri,
+			CredsFile: option.CredsFile,
+		},
+		Bucket:        bucket,
+		Keys:          option.Keys,
+		TimePolicy:    option.TimePolicy,
+		StartRevision: option.StartRevision,
+	}, imp)
+}
+
+// WriteKeyValue writes a PCollection<KV<string,[]byte>> to a NATS JetStream KeyValue bucket, by
+// putting each value at its key. WriteKeyValue takes a variable number of KeyValueOptionFn to
+// configure the write operation:
+//   - UserCredentials: path to the user credentials file. Defaults to empty.
+func WriteKeyValue(s beam.Scope, uri string, bucket string, col beam.PCollection, opts ...KeyValueOptionFn) {
+	s = s.Scope("natsio.WriteKeyValue")
+
+	option := newKeyValueOption("natsio.WriteKeyValue", opts)
+
+	beam.ParDo0(s, &writeKeyValueFn{
+		natsFn: natsFn{
+			URI:       uri,
+			CredsFile: option.CredsFile,
+		},
+		Bucket: bucket,
+	}, col)
+}
+
+func newKeyValueOption(name string, opts []KeyValueOptionFn) *keyValueOption {
+	option := &keyValueOption{
+		TimePolicy: processingTimePolicy,
+	}
+
+	for _, opt := range opts {
+		if err := opt(option); err != nil {
+			panic(fmt.Sprintf("%s: invalid option: %v", name, err))
+		}
+	}
+
+	return option
+}
+
+type readKeyValueFn struct {
+	natsFn
+	Bucket string
+	Keys   []string
+}
+
+func (fn *readKeyValueFn) ProcessElement(ctx context.Context, _ []byte, emit func(KeyValueEntry)) error {
+	kv, err := fn.js.KeyValue(ctx, fn.Bucket)
+	if err != nil {
+		return fmt.Errorf("error getting bucket %v: %v", fn.Bucket, err)
+	}
+
+	w, err := watchKeys(ctx, kv, fn.Keys, jetstream.IgnoreDeletes())
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+
+	// The watcher sends a nil entry once it has sent the current values.
+	for entry := range w.Updates() {
+		if entry == nil {
+			return nil
+		}
+		emit(createKeyValueEntry(entry))
+	}
+
+	return errors.New("watcher stopped before reading all keys")
+}
+
+type watchKeyValueFn struct {
+	natsFn
+	Bucket        string
+	Keys          []string
+	TimePolicy    timePolicy
+	StartRevision int64
+	timestampFn   timestampFn
+}
+
+func (fn *watchKeyValueFn) Setup() error {
+	if err := fn.natsFn.Setup(); err != nil {
+		return err
+	}
+
+	fn.timestampFn = fn.TimePolicy.TimestampFn()
+	return nil
+}
+
+func (fn *watchKeyValueFn) CreateInitialRestriction(_ []byte) offsetrange.Restriction {
+	start := fn.StartRevision
+	if start == 0 {
+		start = 1
+	}
+	return offsetrange.Restriction{
+		Start: start,
+		End:   math.MaxInt64,
+	}
+}
+
+func (fn *watchKeyValueFn) SplitRestriction(
+	_ []byte,
+	rest offsetrange.Restriction,
+) []offsetrange.Restriction {
+	return []offsetrange.Restriction{rest}
+}
+
+func (fn *watchKeyValueFn) RestrictionSize(_ []byte, rest offsetrange.Restriction) (float64, error) {
+	if err := fn.natsFn.Setup(); err != nil {
+		return -1, err
+	}
+
+	rt, err := fn.createRTracker(rest)
+	if err != nil {
+		return -1, err
+	}
+
+	_, remaining := rt.GetProgress()
+	return remaining, nil
+}
+
+func (fn *watchKeyValueFn) CreateTracker(rest offsetrange.Restriction) (*sdf.LockRTracker, error) {
+	rt, err := fn.createRTracker(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	return sdf.NewLockRTracker(rt), nil
+}
+
+func (fn *watchKeyValueFn) TruncateRestriction(rt *sdf.LockRTracker, _ []byte) offsetrange.Restriction {
+	start := rt.GetRestriction().(offsetrange.Restriction).Start
+	return offsetrange.Restriction{
+		Start: start,
+		End:   start,
+	}
+}
+
+func (fn *watchKeyValueFn) InitialWatermarkEstimatorState(
+	et beam.EventTime,
+	_ offsetrange.Restriction,
+	_ []byte,
+) int64 {
+	return et.Milliseconds()
+}
+
+func (fn *watchKeyValueFn) CreateWatermarkEstimator(ms int64) *watermarkEstimator {
+	return &watermarkEstimator{state: ms}
+}
+
+func (fn *watchKeyValueFn) WatermarkEstimatorState(we *watermarkEstimator) int64 {
+	return we.state
+}
+
+func (fn *watchKeyValueFn) ProcessElement(
+	ctx context.Context,
+	we *watermarkEstimator,
+	rt *sdf.LockRTracker,
+	_ []byte,
+	emit func(beam.EventTime, KeyValueEntry),
+) (sdf.ProcessContinuation, error) {
+	kv, err := fn.js.KeyValue(ctx, fn.Bucket)
+	if err != nil {
+		return sdf.StopProcessing(), fmt.Errorf("error getting bucket %v: %v", fn.Bucket, err)
+	}
+
+	// Without a start revision, the initial restriction is read from the
+	// current values of the keys, and resumed restrictions from their first
+	// unclaimed revision.
+	var opts []jetstream.WatchOpt
+	if start := rt.GetRestriction().(offsetrange.Restriction).Start; fn.StartRevision != 0 || start > 1 {
+		opts = append(opts, jetstream.IncludeHistory(), jetstream.ResumeFromRevision(uint64(start)))
+	}
+
+	w, err := watchKeys(ctx, kv, fn.Keys, opts...)
+	if err != nil {
+		return sdf.StopProcessing(), err
+	}
+	defer w.Stop()
+
+	// The watcher sends a nil entry once it has sent the revisions that exist
+	// when it's created. Later revisions are read by resuming from the first
+	// unclaimed revision, so that the watch doesn't block on a bucket that
+	// isn't updated.
+	timer := time.NewTimer(fetchTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case entry, ok := <-w.Updates():
+			if !ok {
+				return sdf.StopProcessing(), errors.New("watcher stopped")
+			}
+			if entry == nil {
+				return fn.resume(we), nil
+			}
+
+			if !rt.TryClaim(int64(entry.Revision())) {
+				return sdf.StopProcessing(), nil
+			}
+
+			et := fn.timestampFn(entry.Created())
+			emit(et, createKeyValueEntry(entry))
+
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(fetchTimeout)
+		case <-timer.C:
+			return fn.resume(we), nil
+		}
+	}
+}
+
+func (fn *watchKeyValueFn) resume(we *watermarkEstimator) sdf.ProcessContinuation {
+	t := time.Now().Add(-1 * assumedLag)
+	we.ObserveTimestamp(fn.timestampFn(t).ToTime())
+	return sdf.ResumeProcessingIn(resumeDelay)
+}
+
+func (fn *watchKeyValueFn) createRTracker(rest offsetrange.Restriction) (sdf.RTracker, error) {
+	stream := "KV_" + fn.Bucket
+	subject := "$KV." + fn.Bucket + ".>"
+	estimator := newEndEstimator(fn.js, stream, subject)
+	rt, err := offsetrange.NewGrowableTracker(rest, estimator)
+	if err != nil {
+		return nil, fmt.Errorf("error creating growable tracker: %v", err)
+	}
+
+	return rt, nil
+}
+
+type writeKeyValueFn struct {
+	natsFn
+	Bucket string
+	kv     jetstream.KeyValue
+}
+
+func (fn *writeKeyValueFn) ProcessElement(ctx context.Context, key string, value []byte) error {
+	if fn.kv == nil {
+		kv, err := fn.js.KeyValue(ctx, fn.Bucket)
+		if err != nil {
+			return fmt.Errorf("error getting bucket %v: %v", fn.Bucket, err)
+		}
+		fn.kv = kv
+	}
+
+	if _, err := fn.kv.Put(ctx, key, value); err != nil {
+		return fmt.Errorf("error putting key %v: %v", key, err)
+	}
+
+	return nil
+}
+
+// watchKeys watches keys of a bucket, or all keys if none are given.
+func watchKeys(
+	ctx context.Context,
+	kv jetstream.KeyValue,
+	keys []string,
+	opts ...jetstream.WatchOpt,
+) (jetstream.KeyWatcher, error) {
+	// WatchFiltered modifies the keys that it's given.
+	filters := append([]string(nil), keys...)
+	w, err := kv.WatchFiltered(ctx, filters, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error watching bucket %v: %v", kv.Bucket(), err)
+	}
+
+	return w, nil
+}
+
+func createKeyValueEntry(entry jetstream.KeyValueEntry) KeyValueEntry {
+	op := entry.Operation()
+	return KeyValueEntry{
+		Bucket:   entry.Bucket(),
+		Key:      entry.Key(),
+		Value:    entry.Value(),
+		Revision: int64(entry.Revision()),
+		Created:  entry.Created(),
+		Deleted:  op == jetstream.KeyValueDelete || op == jetstream.KeyValuePurge,
+	}
+}
@@ -0,0 +1,78 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package natsio
+
+import "errors"
+
+var errInvalidStartRevision = errors.New("start revision must be greater than 0")
+
+type keyValueOption struct {
+	CredsFile     string
+	TimePolicy    timePolicy
+	Keys          []string
+	StartRevision int64
+}
+
+// KeyValueOptionFn is a function that can be passed to ReadKeyValue, WatchKeyValue and
+// WriteKeyValue to configure options for accessing a KeyValue bucket.
+type KeyValueOptionFn func(option *keyValueOption) error
+
+// KeyValueUserCredentials sets the user credentials when connecting to NATS.
+func KeyValueUserCredentials(credsFile string) KeyValueOptionFn {
+	return func(o *keyValueOption) error {
+		o.CredsFile = credsFile
+		return nil
+	}
+}
+
+// KeyValueProcessingTimePolicy specifies that the pipeline processing time of the entries should
+// be used to compute the watermark estimate when watching a bucket.
+func KeyValueProcessingTimePolicy() KeyValueOptionFn {
+	return func(o *keyValueOption) error {
+		o.TimePolicy = processingTimePolicy
+		return nil
+	}
+}
+
+// KeyValuePublishingTimePolicy specifies that the time at which the entries were created should be
+// used to compute the watermark estimate when watching a bucket.
+func KeyValuePublishingTimePolicy() KeyValueOptionFn {
+	return func(o *keyValueOption) error {
+		o.TimePolicy = publishingTimePolicy
+		return nil
+	}
+}
+
+// KeyValueKeys sets the keys to read or watch, which may contain wildcards.
+func KeyValueKeys(keys ...string) KeyValueOptionFn {
+	return func(o *keyValueOption) error {
+		o.Keys = keys
+		return nil
+	}
+}
+
+// KeyValueStartRevision sets the revision to watch a bucket from, including the history of the
+// keys from that revision.
+func KeyValueStartRevision(revision int64) KeyValueOptionFn {
+	return func(o *keyValueOption) error {
+		if revision <= 0 {
+			return errInvalidStartRevision
+		}
+
+		o.StartRevision = revision
+		return nil
+	}
+}
@@ -0,0 +1,228 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package natsio
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/rtrackers/offsetrange"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func init() {
+	register.Function1x1(entryString)
+	register.Function2x0(splitKeyValue)
+	register.Emitter2[string, []byte]()
+}
+
+func entryString(entry KeyValueEntry) string {
+	if entry.Deleted {
+		return entry.Key + " deleted"
+	}
+	return entry.Key + "=" + string(entry.Value)
+}
+
+func putKeys(ctx context.Context, t *testing.T, kv jetstream.KeyValue, kvs ...string) {
+	t.Helper()
+
+	for i := 0; i < len(kvs); i += 2 {
+		if _, err := kv.Put(ctx, kvs[i], []byte(kvs[i+1])); err != nil {
+			t.Fatalf("Failed to put key: %v", err)
+		}
+	}
+}
+
+func TestReadKeyValue(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []KeyValueOptionFn
+		want []any
+	}{
+		{
+			name: "Read all keys",
+			want: []any{"key.1=b", "key.3=d"},
+		},
+		{
+			name: "Read filtered keys",
+			opts: []KeyValueOptionFn{KeyValueKeys("key.1")},
+			want: []any{"key.1=b"},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			srv := newServer(t)
+			url := srv.ClientURL()
+			conn := newConn(t, url)
+			js := newJetStream(t, conn)
+
+			bucket := fmt.Sprintf("bucket-read-%d", i)
+			kv := createKeyValue(ctx, t, js, bucket)
+			putKeys(ctx, t, kv, "key.1", "a", "key.2", "c", "key.1", "b", "key.3", "d")
+			if err := kv.Delete(ctx, "key.2"); err != nil {
+				t.Fatalf("Failed to delete key: %v", err)
+			}
+
+			p, s := beam.NewPipelineWithRoot()
+			got := ReadKeyValue(s, url, bucket, tt.opts...)
+
+			passert.Equals(s, beam.ParDo(s, entryString, got), tt.want...)
+			ptest.RunAndValidate(t, p)
+		})
+	}
+}
+
+func TestWatchKeyValue(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []KeyValueOptionFn
+		want []string
+	}{
+		{
+			name: "Watch current values",
+			want: []string{"key.1=b", "key.2 deleted"},
+		},
+		{
+			name: "Watch from start revision",
+			opts: []KeyValueOptionFn{KeyValueStartRevision(2)},
+			want: []string{"key.2=c", "key.1=b", "key.2 deleted"},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			srv := newServer(t)
+			url := srv.ClientURL()
+			conn := newConn(t, url)
+			js := newJetStream(t, conn)
+
+			bucket := fmt.Sprintf("bucket-watch-%d", i)
+			kv := createKeyValue(ctx, t, js, bucket)
+			putKeys(ctx, t, kv, "key.1", "a", "key.2", "c", "key.1", "b")
+			if err := kv.Delete(ctx, "key.2"); err != nil {
+				t.Fatalf("Failed to delete key: %v", err)
+			}
+
+			option := newKeyValueOption("natsio.WatchKeyValue", tt.opts)
+			fn := &watchKeyValueFn{
+				natsFn:        natsFn{URI: url},
+				Bucket:        bucket,
+				TimePolicy:    option.TimePolicy,
+				StartRevision: option.StartRevision,
+			}
+			if err := fn.Setup(); err != nil {
+				t.Fatalf("Failed to set up DoFn: %v", err)
+			}
+			t.Cleanup(fn.Teardown)
+
+			rt, err := fn.CreateTracker(fn.CreateInitialRestriction(nil))
+			if err != nil {
+				t.Fatalf("Failed to create tracker: %v", err)
+			}
+			we := fn.CreateWatermarkEstimator(0)
+
+			var got []string
+			pc, err := fn.ProcessElement(ctx, we, rt, nil, func(_ beam.EventTime, entry KeyValueEntry) {
+				got = append(got, entryString(entry))
+			})
+			if err != nil {
+				t.Fatalf("ProcessElement() failed: %v", err)
+			}
+			if !pc.ShouldResume() {
+				t.Error("ProcessElement() stopped processing, want resumption")
+			}
+			if !equalStrings(got, tt.want) {
+				t.Errorf("ProcessElement() emitted %v, want %v", got, tt.want)
+			}
+
+			// A resumed watch continues after the last claimed revision.
+			putKeys(ctx, t, kv, "key.3", "d")
+			primary, residual, err := rt.TrySplit(0)
+			if err != nil {
+				t.Fatalf("Failed to checkpoint: %v", err)
+			}
+			if primary == nil || residual == nil {
+				t.Fatal("TrySplit(0) returned no residual")
+			}
+			rt, err = fn.CreateTracker(residual.(offsetrange.Restriction))
+			if err != nil {
+				t.Fatalf("Failed to create tracker: %v", err)
+			}
+
+			got = nil
+			if _, err := fn.ProcessElement(ctx, we, rt, nil, func(_ beam.EventTime, entry KeyValueEntry) {
+				got = append(got, entryString(entry))
+			}); err != nil {
+				t.Fatalf("ProcessElement() failed: %v", err)
+			}
+			if want := []string{"key.3=d"}; !equalStrings(got, want) {
+				t.Errorf("ProcessElement() after resumption emitted %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteKeyValue(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t)
+	url := srv.ClientURL()
+	conn := newConn(t, url)
+	js := newJetStream(t, conn)
+
+	bucket := "bucket-write"
+	kv := createKeyValue(ctx, t, js, bucket)
+
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.ParDo(s, splitKeyValue, beam.Create(s, "key.1=a", "key.2=b"))
+	WriteKeyValue(s, url, bucket, col)
+	ptest.RunAndValidate(t, p)
+
+	for key, want := range map[string]string{"key.1": "a", "key.2": "b"} {
+		entry, err := kv.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Failed to get key %v: %v", key, err)
+		}
+		if got := string(entry.Value()); got != want {
+			t.Errorf("Value of key %v = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func splitKeyValue(kv string, emit func(string, []byte)) {
+	for i := range kv {
+		if kv[i] == '=' {
+			emit(kv[:i], []byte(kv[i+1:]))
+			return
+		}
+	}
+}
+
+func TestKeyValueStartRevision_Invalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("WatchKeyValue() succeeded, want panic")
+		}
+	}()
+
+	_, s := beam.NewPipelineWithRoot()
+	WatchKeyValue(s, "nats://localhost:4222", "bucket", KeyValueStartRevision(0))
+}
@@ -0,0 +1,209 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package natsio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func init() {
+	register.DoFn3x1[context.Context, []byte, func(string), error](&listObjectsFn{})
+	register.DoFn3x1[context.Context, string, func(Object), error](&readObjectFn{})
+	register.DoFn2x1[context.Context, Object, error](&writeObjectFn{})
+	register.Emitter1[string]()
+	register.Emitter1[Object]()
+	beam.RegisterType(reflect.TypeOf((*Object)(nil)).Elem())
+}
+
+// Object represents an object in a NATS JetStream Object Store bucket.
+type Object struct {
+	Name        string
+	Description string
+	Headers     map[string][]string
+	Metadata    map[string]string
+	Data        []byte
+	// ModTime is the time at which the object was last modified. It's ignored
+	// when writing objects.
+	ModTime time.Time
+}
+
+// ReadObjects reads the objects of a NATS JetStream Object Store bucket and returns a bounded
+// PCollection<Object>. The objects are read in parallel, and each object is read into memory.
+// ReadObjects takes a variable number of ObjectStoreOptionFn to configure the read operation:
+//   - UserCredentials: path to the user credentials file. Defaults to empty.
+func ReadObjects(s beam.Scope, uri string, bucket string, opts ...ObjectStoreOptionFn) beam.PCollection {
+	s = s.Scope("natsio.ReadObjects")
+
+	option := &objectStoreOption{}
+	for _, opt := range opts {
+		opt(option)
+	}
+
+	fn := objectStoreFn{
+		natsFn: natsFn{
+			URI:       uri,
+			CredsFile: option.CredsFile,
+		},
+		Bucket: bucket,
+	}
+
+	imp := beam.Impulse(s)
+	names := beam.ParDo(s, &listObjectsFn{objectStoreFn: fn}, imp)
+	names = beam.Reshuffle(s, names)
+	return beam.ParDo(s, &readObjectFn{objectStoreFn: fn}, names)
+}
+
+// WriteObjects writes a PCollection<Object> to a NATS JetStream Object Store bucket. Objects
+// replace any object of the same name in the bucket. WriteObjects takes a variable number of
+// ObjectStoreOptionFn to configure the write operation:
+//   - UserCredentials: path to the user credentials file. Defaults to empty.
+func WriteObjects(s beam.Scope, uri string, bucket string, col beam.PCollection, opts ...ObjectStoreOptionFn) {
+	s = s.Scope("natsio.WriteObjects")
+
+	option := &objectStoreOption{}
+	for _, opt := range opts {
+		opt(option)
+	}
+
+	beam.ParDo0(s, &writeObjectFn{
+		objectStoreFn: objectStoreFn{
+			natsFn: natsFn{
+				URI:       uri,
+				CredsFile: option.CredsFile,
+			},
+			Bucket: bucket,
+		},
+	}, col)
+}
+
+type objectStoreFn struct {
+	natsFn
+	Bucket string
+	obs    jetstream.ObjectStore
+}
+
+func (fn *objectStoreFn) objectStore(ctx context.Context) (jetstream.ObjectStore, error) {
+	if fn.obs == nil {
+		obs, err := fn.js.ObjectStore(ctx, fn.Bucket)
+		if err != nil {
+			return nil, fmt.Errorf("error getting bucket %v: %v", fn.Bucket, err)
+		}
+		fn.obs = obs
+	}
+
+	return fn.obs, nil
+}
+
+type listObjectsFn struct {
+	objectStoreFn
+}
+
+func (fn *listObjectsFn) ProcessElement(ctx context.Context, _ []byte, emit func(string)) error {
+	obs, err := fn.objectStore(ctx)
+	if err != nil {
+		return err
+	}
+
+	infos, err := obs.List(ctx)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrNoObjectsFound) {
+			return nil
+		}
+
+		return fmt.Errorf("error listing objects: %v", err)
+	}
+
+	for _, info := range infos {
+		emit(info.Name)
+	}
+
+	return nil
+}
+
+type readObjectFn struct {
+	objectStoreFn
+}
+
+func (fn *readObjectFn) ProcessElement(ctx context.Context, name string, emit func(Object)) error {
+	obs, err := fn.objectStore(ctx)
+	if err != nil {
+		return err
+	}
+
+	res, err := obs.Get(ctx, name)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrObjectNotFound) {
+			// The object was deleted after it was listed.
+			return nil
+		}
+
+		return fmt.Errorf("error getting object %v: %v", name, err)
+	}
+	defer res.Close()
+
+	info, err := res.Info()
+	if err != nil {
+		return fmt.Errorf("error getting info of object %v: %v", name, err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(res); err != nil {
+		return fmt.Errorf("error reading object %v: %v", name, err)
+	}
+
+	emit(Object{
+		Name:        info.Name,
+		Description: info.Description,
+		Headers:     info.Headers,
+		Metadata:    info.Metadata,
+		Data:        buf.Bytes(),
+		ModTime:     info.ModTime,
+	})
+
+	return nil
+}
+
+type writeObjectFn struct {
+	objectStoreFn
+}
+
+func (fn *writeObjectFn) ProcessElement(ctx context.Context, obj Object) error {
+	obs, err := fn.objectStore(ctx)
+	if err != nil {
+		return err
+	}
+
+	meta := jetstream.ObjectMeta{
+		Name:        obj.Name,
+		Description: obj.Description,
+		Headers:     obj.Headers,
+		Metadata:    obj.Metadata,
+	}
+	if _, err := obs.Put(ctx, meta, bytes.NewReader(obj.Data)); err != nil {
+		return fmt.Errorf("error putting object %v: %v", obj.Name, err)
+	}
+
+	return nil
+}
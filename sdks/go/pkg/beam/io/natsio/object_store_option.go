@@ -0,0 +1,31 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package natsio
+
+type objectStoreOption struct {
+	CredsFile string
+}
+
+// ObjectStoreOptionFn is a function that can be passed to ReadObjects and WriteObjects to
+// configure options for accessing an Object Store bucket.
+type ObjectStoreOptionFn func(option *objectStoreOption)
+
+// ObjectStoreUserCredentials sets the user credentials when connecting to NATS.
+func ObjectStoreUserCredentials(credsFile string) ObjectStoreOptionFn {
+	return func(o *objectStoreOption) {
+		o.CredsFile = credsFile
+	}
+}
@@ -0,0 +1,112 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package natsio
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func init() {
+	register.Function1x1(objectString)
+}
+
+func objectString(obj Object) string {
+	return obj.Name + "=" + string(obj.Data) + " " + obj.Metadata["key"]
+}
+
+func TestReadObjects(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t)
+	url := srv.ClientURL()
+	conn := newConn(t, url)
+	js := newJetStream(t, conn)
+
+	bucket := "objects-read"
+	obs := createObjectStore(ctx, t, js, bucket)
+	for name, data := range map[string]string{"obj1": "a", "obj2": "b"} {
+		meta := jetstream.ObjectMeta{Name: name, Metadata: map[string]string{"key": "val"}}
+		if _, err := obs.Put(ctx, meta, strings.NewReader(data)); err != nil {
+			t.Fatalf("Failed to put object: %v", err)
+		}
+	}
+
+	p, s := beam.NewPipelineWithRoot()
+	got := ReadObjects(s, url, bucket)
+
+	passert.Equals(s, beam.ParDo(s, objectString, got), "obj1=a val", "obj2=b val")
+	ptest.RunAndValidate(t, p)
+}
+
+func TestReadObjects_Empty(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t)
+	url := srv.ClientURL()
+	conn := newConn(t, url)
+	js := newJetStream(t, conn)
+
+	bucket := "objects-empty"
+	createObjectStore(ctx, t, js, bucket)
+
+	p, s := beam.NewPipelineWithRoot()
+	got := ReadObjects(s, url, bucket)
+
+	passert.Empty(s, got)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestWriteObjects(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t)
+	url := srv.ClientURL()
+	conn := newConn(t, url)
+	js := newJetStream(t, conn)
+
+	bucket := "objects-write"
+	obs := createObjectStore(ctx, t, js, bucket)
+
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Create(s,
+		Object{Name: "obj1", Data: []byte("a"), Metadata: map[string]string{"key": "val"}},
+		Object{Name: "obj2", Data: []byte("b")},
+	)
+	WriteObjects(s, url, bucket, col)
+	ptest.RunAndValidate(t, p)
+
+	for name, want := range map[string]string{"obj1": "a", "obj2": "b"} {
+		data, err := obs.GetBytes(ctx, name)
+		if err != nil {
+			t.Fatalf("Failed to get object %v: %v", name, err)
+		}
+		if got := string(data); got != want {
+			t.Errorf("Data of object %v = %v, want %v", name, got, want)
+		}
+	}
+	info, err := obs.GetInfo(ctx, "obj1")
+	if err != nil {
+		t.Fatalf("Failed to get object info: %v", err)
+	}
+	if got := info.Metadata["key"]; got != "val" {
+		t.Errorf("Metadata of object obj1 = %v, want val", info.Metadata)
+	}
+}
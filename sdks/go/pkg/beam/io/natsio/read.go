@@ -17,6 +17,7 @@ package natsio
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"reflect"
@@ -32,13 +33,20 @@ import (
 
 func init() {
 	register.DoFn5x2[
-		context.Context, *watermarkEstimator, *sdf.LockRTracker, []byte,
+		context.Context, *watermarkEstimator, *sdf.LockRTracker, readSource,
 		func(beam.EventTime, ConsumerMessage), sdf.ProcessContinuation, error,
 	](
 		&readFn{},
 	)
+	register.DoFn6x2[
+		context.Context, *watermarkEstimator, beam.BundleFinalization, *sdf.LockRTracker, readSource,
+		func(beam.EventTime, ConsumerMessage), sdf.ProcessContinuation, error,
+	](
+		&durableReadFn{},
+	)
 	register.Emitter2[beam.EventTime, ConsumerMessage]()
 	beam.RegisterType(reflect.TypeOf((*ConsumerMessage)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*readSource)(nil)).Elem())
 }
 
 const (
@@ -48,6 +56,13 @@ const (
 	fetchTimeout      = 3 * time.Second
 	assumedLag        = 1 * time.Second
 	resumeDelay       = 5 * time.Second
+	// maxReadTime is how long a read through a durable consumer keeps fetching
+	// messages before it checkpoints, so that the messages are acked when the
+	// bundle is finalized.
+	maxReadTime = 10 * time.Second
+	// ackWait is how long messages fetched through a durable consumer wait to
+	// be acked before they are redelivered.
+	ackWait = 2 * time.Minute
 )
 
 type ConsumerMessage struct {
@@ -68,6 +83,14 @@ type ConsumerMessage struct {
 //   - FetchSize: the maximum number of messages to retrieve at a time. Defaults to 100.
 //   - StartSeqNo: the start sequence number of messages to read. Defaults to 1.
 //   - EndSeqNo: the end sequence number of messages to read (exclusive). Defaults to math.MaxInt64.
+//   - Consumer: the name of a durable consumer to read through. Defaults to empty, in which case
+//     messages are read through an ephemeral ordered consumer.
+//
+// When reading through a durable consumer, the consumer is created if it doesn't exist, and the
+// messages are acked once the bundle that emitted them is finalized, so a restarted pipeline
+// resumes from the first message that wasn't acked. The StartSeqNo then only applies when the
+// consumer is created, and the EndSeqNo can't be set. Messages whose bundles fail are redelivered,
+// so they may be emitted more than once.
 func Read(
 	s beam.Scope,
 	uri string,
@@ -76,7 +99,36 @@ func Read(
 	opts ...ReadOptionFn,
 ) beam.PCollection {
 	s = s.Scope("natsio.Read")
+	return read(s, uri, stream, []string{subject}, opts)
+}
+
+// ReadSubjects reads messages of several subject filters from NATS JetStream and returns a
+// PCollection<ConsumerMessage>. Each subject filter is read as a separate restriction, so the
+// subject filters are read in parallel, and they shouldn't overlap to not read messages more than
+// once. ReadSubjects takes the same ReadOptionFn as Read. When reading through a durable consumer,
+// each subject filter is read through its own durable consumer, named after the Consumer and the
+// index of the subject filter, such as "name_0".
+func ReadSubjects(
+	s beam.Scope,
+	uri string,
+	stream string,
+	subjects []string,
+	opts ...ReadOptionFn,
+) beam.PCollection {
+	s = s.Scope("natsio.ReadSubjects")
+	if len(subjects) == 0 {
+		panic("natsio.ReadSubjects: no subjects")
+	}
+	return read(s, uri, stream, subjects, opts)
+}
 
+func read(
+	s beam.Scope,
+	uri string,
+	stream string,
+	subjects []string,
+	opts []ReadOptionFn,
+) beam.PCollection {
 	option := &readOption{
 		TimePolicy: processingTimePolicy,
 		FetchSize:  defaultFetchSize,
@@ -90,14 +142,40 @@ func Read(
 		}
 	}
 
-	imp := beam.Impulse(s)
-	return beam.ParDo(s, newReadFn(uri, stream, subject, option), imp)
+	if option.Consumer != "" && option.EndSeqNo != defaultEndSeqNo {
+		panic(fmt.Sprintf("natsio.Read: invalid option: %v", errEndSeqNoWithConsumer))
+	}
+
+	srcs := make([]readSource, len(subjects))
+	for i, subject := range subjects {
+		srcs[i] = readSource{Subject: subject}
+		switch {
+		case option.Consumer == "":
+		case len(subjects) == 1:
+			srcs[i].Consumer = option.Consumer
+		default:
+			srcs[i].Consumer = fmt.Sprintf("%s_%d", option.Consumer, i)
+		}
+	}
+	col := beam.CreateList(s, srcs)
+
+	fn := newReadFn(uri, stream, option)
+	if option.Consumer != "" {
+		return beam.ParDo(s, &durableReadFn{readFn: *fn}, col)
+	}
+	return beam.ParDo(s, fn, col)
+}
+
+// readSource is a subject filter to read from a stream, and the name of the
+// durable consumer to read it through, if any.
+type readSource struct {
+	Subject  string
+	Consumer string
 }
 
 type readFn struct {
 	natsFn
 	Stream      string
-	Subject     string
 	TimePolicy  timePolicy
 	FetchSize   int
 	StartSeqNo  int64
@@ -105,14 +183,13 @@ type readFn struct {
 	timestampFn timestampFn
 }
 
-func newReadFn(uri string, stream string, subject string, option *readOption) *readFn {
+func newReadFn(uri string, stream string, option *readOption) *readFn {
 	return &readFn{
 		natsFn: natsFn{
 			URI:       uri,
 			CredsFile: option.CredsFile,
 		},
 		Stream:     stream,
-		Subject:    subject,
 		TimePolicy: option.TimePolicy,
 		FetchSize:  option.FetchSize,
 		StartSeqNo: option.StartSeqNo,
@@ -129,7 +206,15 @@ func (fn *readFn) Setup() error {
 	return nil
 }
 
-func (fn *readFn) CreateInitialRestriction(_ []byte) offsetrange.Restriction {
+func (fn *readFn) CreateInitialRestriction(src readSource) offsetrange.Restriction {
+	if src.Consumer != "" {
+		// The positions of messages read through a durable consumer are their
+		// consumer sequence numbers, which keep increasing across restarts.
+		return offsetrange.Restriction{
+			Start: 1,
+			End:   defaultEndSeqNo,
+		}
+	}
 	return offsetrange.Restriction{
 		Start: fn.StartSeqNo,
 		End:   fn.EndSeqNo,
@@ -137,13 +222,13 @@ func (fn *readFn) CreateInitialRestriction(_ []byte) offsetrange.Restriction {
 }
 
 func (fn *readFn) SplitRestriction(
-	_ []byte,
+	_ readSource,
 	rest offsetrange.Restriction,
 ) []offsetrange.Restriction {
 	return []offsetrange.Restriction{rest}
 }
 
-func (fn *readFn) RestrictionSize(_ []byte, rest offsetrange.Restriction) (float64, error) {
+func (fn *readFn) RestrictionSize(src readSource, rest offsetrange.Restriction) (float64, error) {
 	if err := fn.natsFn.Setup(); err != nil {
 		return -1, err
 	}
@@ -152,6 +237,9 @@ func (fn *readFn) RestrictionSize(_ []byte, rest offsetrange.Restriction) (float
 	if err != nil {
 		return -1, err
 	}
+	if st, ok := rt.(*sourceTracker); ok {
+		st.estimator.bind(fn.newEndEstimator(src))
+	}
 
 	_, remaining := rt.GetProgress()
 	return remaining, nil
@@ -166,7 +254,7 @@ func (fn *readFn) CreateTracker(rest offsetrange.Restriction) (*sdf.LockRTracker
 	return sdf.NewLockRTracker(rt), nil
 }
 
-func (fn *readFn) TruncateRestriction(rt *sdf.LockRTracker, _ []byte) offsetrange.Restriction {
+func (fn *readFn) TruncateRestriction(rt *sdf.LockRTracker, _ readSource) offsetrange.Restriction {
 	start := rt.GetRestriction().(offsetrange.Restriction).Start
 	return offsetrange.Restriction{
 		Start: start,
@@ -177,7 +265,7 @@ func (fn *readFn) TruncateRestriction(rt *sdf.LockRTracker, _ []byte) offsetrang
 func (fn *readFn) InitialWatermarkEstimatorState(
 	et beam.EventTime,
 	_ offsetrange.Restriction,
-	_ []byte,
+	_ readSource,
 ) int64 {
 	return et.Milliseconds()
 }
@@ -194,11 +282,13 @@ func (fn *readFn) ProcessElement(
 	ctx context.Context,
 	we *watermarkEstimator,
 	rt *sdf.LockRTracker,
-	_ []byte,
+	src readSource,
 	emit func(beam.EventTime, ConsumerMessage),
 ) (sdf.ProcessContinuation, error) {
+	fn.bindEstimator(rt, src)
+
 	startSeqNo := rt.GetRestriction().(offsetrange.Restriction).Start
-	cons, err := fn.createConsumer(ctx, startSeqNo)
+	cons, err := fn.createConsumer(ctx, src.Subject, startSeqNo)
 	if err != nil {
 		return sdf.StopProcessing(), err
 	}
@@ -233,32 +323,81 @@ func (fn *readFn) ProcessElement(
 		}
 
 		if count == 0 {
+			done, err := fn.claimEnd(ctx, rt)
+			if err != nil {
+				return sdf.StopProcessing(), err
+			}
+			if done {
+				return sdf.StopProcessing(), nil
+			}
+
 			fn.updateWatermarkManually(we)
 			return sdf.ResumeProcessingIn(resumeDelay), nil
 		}
 	}
 }
 
+// claimEnd claims the end of a bounded restriction once the stream has
+// reached it, since no more messages of the subject filter can be read before
+// the end if the filter has no messages at or after it.
+func (fn *readFn) claimEnd(ctx context.Context, rt *sdf.LockRTracker) (bool, error) {
+	end := rt.GetRestriction().(offsetrange.Restriction).End
+	if end == math.MaxInt64 {
+		return false, nil
+	}
+
+	str, err := fn.js.Stream(ctx, fn.Stream)
+	if err != nil {
+		return false, fmt.Errorf("error getting stream: %v", err)
+	}
+	info, err := str.Info(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error getting stream info: %v", err)
+	}
+	if int64(info.State.LastSeq) < end-1 {
+		return false, nil
+	}
+
+	rt.TryClaim(end)
+	return true, nil
+}
+
 func (fn *readFn) createRTracker(rest offsetrange.Restriction) (sdf.RTracker, error) {
 	if rest.End < math.MaxInt64 {
 		return offsetrange.NewTracker(rest), nil
 	}
 
-	estimator := newEndEstimator(fn.js, fn.Stream, fn.Subject)
+	estimator := &sourceEndEstimator{end: rest.Start}
 	rt, err := offsetrange.NewGrowableTracker(rest, estimator)
 	if err != nil {
 		return nil, fmt.Errorf("error creating growable tracker: %v", err)
 	}
 
-	return rt, nil
+	return &sourceTracker{GrowableTracker: rt, estimator: estimator}, nil
+}
+
+// bindEstimator binds the end estimator of a growable tracker to the source
+// that it tracks, which isn't known when the tracker is created.
+func (fn *readFn) bindEstimator(rt *sdf.LockRTracker, src readSource) {
+	if st, ok := rt.Rt.(*sourceTracker); ok {
+		st.estimator.bind(fn.newEndEstimator(src))
+	}
+}
+
+func (fn *readFn) newEndEstimator(src readSource) offsetrange.RangeEndEstimator {
+	if src.Consumer != "" {
+		return newConsumerEndEstimator(fn.js, fn.Stream, src.Consumer)
+	}
+	return newEndEstimator(fn.js, fn.Stream, src.Subject)
 }
 
 func (fn *readFn) createConsumer(
 	ctx context.Context,
+	subject string,
 	startSeqNo int64,
 ) (jetstream.Consumer, error) {
 	cfg := jetstream.OrderedConsumerConfig{
-		FilterSubjects:   []string{fn.Subject},
+		FilterSubjects:   []string{subject},
 		DeliverPolicy:    jetstream.DeliverByStartSequencePolicy,
 		OptStartSeq:      uint64(startSeqNo),
 		MaxResetAttempts: 5,
@@ -287,3 +426,133 @@ func (fn *readFn) updateWatermarkManually(we *watermarkEstimator) {
 	et := fn.timestampFn(t)
 	we.ObserveTimestamp(et.ToTime())
 }
+
+// durableReadFn reads messages through a durable consumer, and acks them once
+// their bundle is finalized.
+type durableReadFn struct {
+	readFn
+}
+
+func (fn *durableReadFn) ProcessElement(
+	ctx context.Context,
+	we *watermarkEstimator,
+	bf beam.BundleFinalization,
+	rt *sdf.LockRTracker,
+	src readSource,
+	emit func(beam.EventTime, ConsumerMessage),
+) (sdf.ProcessContinuation, error) {
+	fn.bindEstimator(rt, src)
+
+	cons, err := fn.durableConsumer(ctx, src)
+	if err != nil {
+		return sdf.StopProcessing(), err
+	}
+
+	var pending []jetstream.Msg
+	defer func() {
+		if len(pending) == 0 {
+			return
+		}
+		bf.RegisterCallback(ackWait, func() error {
+			return ackMessages(pending)
+		})
+	}()
+
+	deadline := time.Now().Add(maxReadTime)
+	for time.Now().Before(deadline) {
+		msgs, err := cons.Fetch(fn.FetchSize, jetstream.FetchMaxWait(fetchTimeout))
+		if err != nil {
+			return sdf.StopProcessing(), fmt.Errorf("error fetching messages: %v", err)
+		}
+
+		count := 0
+		for msg := range msgs.Messages() {
+			metadata, err := msg.Metadata()
+			if err != nil {
+				return sdf.StopProcessing(), fmt.Errorf("error retrieving metadata: %v", err)
+			}
+
+			// Messages that can't be claimed are nacked, so that they're
+			// redelivered to the residual without waiting for the ack wait.
+			seqNo := int64(metadata.Sequence.Consumer)
+			if !rt.TryClaim(seqNo) {
+				if err := nakMessages(msg, msgs); err != nil {
+					return sdf.StopProcessing(), err
+				}
+				return sdf.StopProcessing(), nil
+			}
+
+			et := fn.timestampFn(metadata.Timestamp)
+			consMsg := createConsumerMessage(msg, metadata.Timestamp)
+			emit(et, consMsg)
+			pending = append(pending, msg)
+
+			count++
+		}
+
+		if err := msgs.Error(); err != nil {
+			return sdf.StopProcessing(), fmt.Errorf("error in message batch: %v", err)
+		}
+
+		if count == 0 {
+			fn.updateWatermarkManually(we)
+			return sdf.ResumeProcessingIn(resumeDelay), nil
+		}
+	}
+
+	return sdf.ResumeProcessingIn(0), nil
+}
+
+// durableConsumer returns the durable consumer of a source, and creates it if
+// it doesn't exist.
+func (fn *durableReadFn) durableConsumer(
+	ctx context.Context,
+	src readSource,
+) (jetstream.Consumer, error) {
+	cons, err := fn.js.Consumer(ctx, fn.Stream, src.Consumer)
+	if err == nil {
+		return cons, nil
+	}
+	if !errors.Is(err, jetstream.ErrConsumerNotFound) {
+		return nil, fmt.Errorf("error getting consumer %v: %v", src.Consumer, err)
+	}
+
+	cfg := jetstream.ConsumerConfig{
+		Durable:       src.Consumer,
+		FilterSubject: src.Subject,
+		DeliverPolicy: jetstream.DeliverByStartSequencePolicy,
+		OptStartSeq:   uint64(fn.StartSeqNo),
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       ackWait,
+		MaxAckPending: -1,
+	}
+
+	cons, err = fn.js.CreateConsumer(ctx, fn.Stream, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating consumer %v: %v", src.Consumer, err)
+	}
+
+	return cons, nil
+}
+
+// nakMessages nacks the message and the rest of the fetched batch.
+func nakMessages(msg jetstream.Msg, batch jetstream.MessageBatch) error {
+	if err := msg.Nak(); err != nil {
+		return fmt.Errorf("error nacking message: %v", err)
+	}
+	for msg := range batch.Messages() {
+		if err := msg.Nak(); err != nil {
+			return fmt.Errorf("error nacking message: %v", err)
+		}
+	}
+	return nil
+}
+
+func ackMessages(msgs []jetstream.Msg) error {
+	for _, msg := range msgs {
+		if err := msg.Ack(); err != nil {
+			return fmt.Errorf("error acking message: %v", err)
+		}
+	}
+	return nil
+}
@@ -21,6 +21,9 @@ var (
 	errInvalidFetchSize  = errors.New("fetch size must be greater than 0")
 	errInvalidStartSeqNo = errors.New("start sequence number must be greater than 0")
 	errInvalidEndSeqNo   = errors.New("end sequence number must be greater than 0")
+	errInvalidConsumer   = errors.New("consumer name must not be empty")
+
+	errEndSeqNoWithConsumer = errors.New("end sequence number can't be set when reading through a durable consumer")
 )
 
 type readOption struct {
@@ -29,6 +32,7 @@ type readOption struct {
 	FetchSize  int
 	StartSeqNo int64
 	EndSeqNo   int64
+	Consumer   string
 }
 
 // ReadOptionFn is a function that can be passed to Read to configure options for reading
@@ -96,3 +100,16 @@ func ReadEndSeqNo(seqNo int64) ReadOptionFn {
 		return nil
 	}
 }
+
+// ReadConsumer sets the name of a durable consumer to read messages through, which acks the
+// messages once their bundle is finalized.
+func ReadConsumer(name string) ReadOptionFn {
+	return func(o *readOption) error {
+		if name == "" {
+			return errInvalidConsumer
+		}
+
+		o.Consumer = name
+		return nil
+	}
+}
@@ -18,14 +18,23 @@ package natsio
 import (
 	"context"
 	"fmt"
+	"sort"
 	"testing"
+	"time"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/rtrackers/offsetrange"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 )
 
+func init() {
+	register.Function1x1(messageData)
+}
+
 func TestRead(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -210,3 +219,233 @@ func TestRead(t *testing.T) {
 		})
 	}
 }
+
+func TestReadSubjects(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t)
+	url := srv.ClientURL()
+	conn := newConn(t, url)
+	js := newJetStream(t, conn)
+
+	stream := "STREAM-SUBJECTS"
+	createStream(ctx, t, js, stream, []string{"subjects.*"})
+	publishMessages(ctx, t, js, []*nats.Msg{
+		{Subject: "subjects.1", Data: []byte("msg1")},
+		{Subject: "subjects.2", Data: []byte("msg2")},
+		{Subject: "subjects.3", Data: []byte("msg3")},
+		{Subject: "subjects.1", Data: []byte("msg4")},
+	})
+
+	p, s := beam.NewPipelineWithRoot()
+	got := ReadSubjects(s, url, stream, []string{"subjects.1", "subjects.2"}, ReadEndSeqNo(5))
+
+	passert.Equals(s, beam.ParDo(s, messageData, got), "msg1", "msg2", "msg4")
+	ptest.RunAndValidate(t, p)
+}
+
+func TestRead_Consumer(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t)
+	url := srv.ClientURL()
+	conn := newConn(t, url)
+	js := newJetStream(t, conn)
+
+	stream := "STREAM-CONSUMER"
+	createStream(ctx, t, js, stream, []string{"consumer.*"})
+	publishMessages(ctx, t, js, []*nats.Msg{
+		{Subject: "consumer.1", Data: []byte("msg1")},
+		{Subject: "consumer.2", Data: []byte("msg2")},
+		{Subject: "consumer.1", Data: []byte("msg3")},
+	})
+
+	src := readSource{Subject: "consumer.1", Consumer: "consumer"}
+	got, bf := processDurable(t, url, stream, src)
+	if want := []string{"msg1", "msg3"}; !equalStrings(got, want) {
+		t.Fatalf("ProcessElement() emitted %v, want %v", got, want)
+	}
+	if len(bf.callbacks) != 1 {
+		t.Fatalf("ProcessElement() registered %v finalization callbacks, want 1", len(bf.callbacks))
+	}
+	if got := numAckPending(ctx, t, js, stream, src.Consumer); got != 2 {
+		t.Errorf("NumAckPending before finalization = %v, want 2", got)
+	}
+	if err := bf.callbacks[0](); err != nil {
+		t.Fatalf("Failed to finalize bundle: %v", err)
+	}
+	if got := numAckPending(ctx, t, js, stream, src.Consumer); got != 0 {
+		t.Errorf("NumAckPending after finalization = %v, want 0", got)
+	}
+
+	// A restarted read resumes after the acked messages.
+	publishMessages(ctx, t, js, []*nats.Msg{
+		{Subject: "consumer.1", Data: []byte("msg4")},
+	})
+	got, _ = processDurable(t, url, stream, src)
+	if want := []string{"msg4"}; !equalStrings(got, want) {
+		t.Errorf("ProcessElement() after restart emitted %v, want %v", got, want)
+	}
+}
+
+func TestRead_ConsumerNaksUnclaimed(t *testing.T) {
+	ctx := context.Background()
+	srv := newServer(t)
+	url := srv.ClientURL()
+	conn := newConn(t, url)
+	js := newJetStream(t, conn)
+
+	stream := "STREAM-CONSUMER-NAK"
+	createStream(ctx, t, js, stream, []string{"consumer.*"})
+	publishMessages(ctx, t, js, []*nats.Msg{
+		{Subject: "consumer.1", Data: []byte("msg1")},
+		{Subject: "consumer.1", Data: []byte("msg2")},
+		{Subject: "consumer.1", Data: []byte("msg3")},
+	})
+
+	src := readSource{Subject: "consumer.1", Consumer: "consumer"}
+	fn := &durableReadFn{readFn: *newReadFn(url, stream, &readOption{
+		FetchSize:  defaultFetchSize,
+		StartSeqNo: defaultStartSeqNo,
+		EndSeqNo:   defaultEndSeqNo,
+	})}
+	if err := fn.Setup(); err != nil {
+		t.Fatalf("Failed to set up DoFn: %v", err)
+	}
+	t.Cleanup(fn.Teardown)
+
+	// The restriction only holds the first message, as after a split.
+	rt, err := fn.CreateTracker(offsetrange.Restriction{Start: 1, End: 2})
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %v", err)
+	}
+	var got []string
+	_, err = fn.ProcessElement(ctx, fn.CreateWatermarkEstimator(0), &fakeBundleFinalization{}, rt, src, func(_ beam.EventTime, msg ConsumerMessage) {
+		got = append(got, string(msg.Data))
+	})
+	if err != nil {
+		t.Fatalf("ProcessElement() failed: %v", err)
+	}
+	if want := []string{"msg1"}; !equalStrings(got, want) {
+		t.Fatalf("ProcessElement() emitted %v, want %v", got, want)
+	}
+
+	// The unclaimed messages are redelivered right away.
+	got, _ = processDurable(t, url, stream, src)
+	sort.Strings(got)
+	if want := []string{"msg2", "msg3"}; !equalStrings(got, want) {
+		t.Errorf("ProcessElement() of the residual emitted %v, want %v", got, want)
+	}
+}
+
+func TestRead_InvalidOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []ReadOptionFn
+	}{
+		{
+			name: "Empty consumer name",
+			opts: []ReadOptionFn{ReadConsumer("")},
+		},
+		{
+			name: "End seq no with consumer",
+			opts: []ReadOptionFn{ReadConsumer("consumer"), ReadEndSeqNo(5)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Read() succeeded, want panic")
+				}
+			}()
+
+			_, s := beam.NewPipelineWithRoot()
+			Read(s, "nats://localhost:4222", "STREAM", "subject.1", tt.opts...)
+		})
+	}
+}
+
+func messageData(msg ConsumerMessage) string {
+	return string(msg.Data)
+}
+
+type fakeBundleFinalization struct {
+	callbacks []func() error
+}
+
+func (f *fakeBundleFinalization) RegisterCallback(_ time.Duration, callback func() error) {
+	f.callbacks = append(f.callbacks, callback)
+}
+
+// processDurable processes the initial restriction of a source that is read
+// through a durable consumer, and returns the data of the emitted messages.
+func processDurable(
+	t *testing.T,
+	url string,
+	stream string,
+	src readSource,
+) ([]string, *fakeBundleFinalization) {
+	t.Helper()
+
+	fn := &durableReadFn{readFn: *newReadFn(url, stream, &readOption{
+		FetchSize:  defaultFetchSize,
+		StartSeqNo: defaultStartSeqNo,
+		EndSeqNo:   defaultEndSeqNo,
+	})}
+	if err := fn.Setup(); err != nil {
+		t.Fatalf("Failed to set up DoFn: %v", err)
+	}
+	t.Cleanup(fn.Teardown)
+
+	rt, err := fn.CreateTracker(fn.CreateInitialRestriction(src))
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %v", err)
+	}
+	we := fn.CreateWatermarkEstimator(0)
+	bf := &fakeBundleFinalization{}
+
+	var got []string
+	pc, err := fn.ProcessElement(context.Background(), we, bf, rt, src, func(_ beam.EventTime, msg ConsumerMessage) {
+		got = append(got, string(msg.Data))
+	})
+	if err != nil {
+		t.Fatalf("ProcessElement() failed: %v", err)
+	}
+	if !pc.ShouldResume() {
+		t.Error("ProcessElement() stopped processing, want resumption")
+	}
+
+	return got, bf
+}
+
+func numAckPending(
+	ctx context.Context,
+	t *testing.T,
+	js jetstream.JetStream,
+	stream string,
+	consumer string,
+) int {
+	t.Helper()
+
+	cons, err := js.Consumer(ctx, stream, consumer)
+	if err != nil {
+		t.Fatalf("Failed to get consumer: %v", err)
+	}
+	info, err := cons.Info(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get consumer info: %v", err)
+	}
+
+	return info.NumAckPending
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parquetio
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+// maxSkip is the largest forward seek that is done by reading and discarding
+// the data in between, rather than by seeking the underlying reader.
+const maxSkip = 1024 * 1024 // 1 MB
+
+// parquetFile is a source.ParquetFile that reads a file of a Beam filesystem.
+// If the reader of the file is an io.Seeker, such as a local file, seeking
+// seeks it, so that only the footer and the column chunks that are read are
+// fetched. Otherwise the file is read up to the new offset, and reopened to
+// seek backwards.
+type parquetFile struct {
+	ctx  context.Context
+	fs   filesystem.Interface
+	path string
+	size int64
+
+	// offset is the offset of the next read, and pos the offset of r.
+	offset int64
+	pos    int64
+	r      io.ReadCloser
+	br     *bufio.Reader
+}
+
+var _ source.ParquetFile = (*parquetFile)(nil)
+
+func openParquetFile(ctx context.Context, path string, size int64) (*parquetFile, error) {
+	fs, err := filesystem.New(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return &parquetFile{ctx: ctx, fs: fs, path: path, size: size}, nil
+}
+
+// Open opens the file again, or the file of a column chunk relative to it,
+// as the reader does for each column.
+func (f *parquetFile) Open(name string) (source.ParquetFile, error) {
+	if name == "" {
+		return openParquetFile(f.ctx, f.path, f.size)
+	}
+	name = path.Join(path.Dir(f.path), name)
+	fs, err := filesystem.New(f.ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	size, err := fs.Size(f.ctx, name)
+	if err != nil {
+		fs.Close()
+		return nil, err
+	}
+	return &parquetFile{ctx: f.ctx, fs: fs, path: name, size: size}, nil
+}
+
+func (f *parquetFile) Create(string) (source.ParquetFile, error) {
+	return nil, errors.New("parquetio: files are read-only")
+}
+
+func (f *parquetFile) Write([]byte) (int, error) {
+	return 0, errors.New("parquetio: files are read-only")
+}
+
+func (f *parquetFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("parquetio: invalid offset %v of %v", offset, f.path)
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *parquetFile) Read(p []byte) (int, error) {
+	if err := f.seek(); err != nil {
+		return 0, err
+	}
+	n, err := f.br.Read(p)
+	f.pos += int64(n)
+	f.offset = f.pos
+	return n, err
+}
+
+// seek positions the reader at the offset.
+func (f *parquetFile) seek() error {
+	if f.r == nil {
+		if err := f.reopen(); err != nil {
+			return err
+		}
+	}
+	if s, ok := f.r.(io.Seeker); ok {
+		if f.offset < f.pos || f.offset-f.pos > maxSkip {
+			pos, err := s.Seek(f.offset, io.SeekStart)
+			if err != nil {
+				return err
+			}
+			f.pos = pos
+			f.br.Reset(f.r)
+		}
+	} else if f.offset < f.pos {
+		if err := f.reopen(); err != nil {
+			return err
+		}
+	}
+	if skip := f.offset - f.pos; skip > 0 {
+		n, err := f.br.Discard(int(skip))
+		f.pos += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reopen opens the file at its start.
+func (f *parquetFile) reopen() error {
+	f.closeReader()
+	r, err := f.fs.OpenRead(f.ctx, f.path)
+	if err != nil {
+		return err
+	}
+	f.r, f.br, f.pos = r, bufio.NewReader(r), 0
+	return nil
+}
+
+func (f *parquetFile) closeReader() error {
+	if f.r == nil {
+		return nil
+	}
+	err := f.r.Close()
+	f.r, f.br = nil, nil
+	return err
+}
+
+func (f *parquetFile) Close() error {
+	err := f.closeReader()
+	if ferr := f.fs.Close(); err == nil {
+		err = ferr
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parquetio
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/memfs"
+)
+
+func TestParquetFile_Seek(t *testing.T) {
+	ctx := context.Background()
+	data := make([]byte, 3*maxSkip)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	localPath := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(localPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	memPath := "memfs://parquetio/file"
+	memfs.Write(memPath, data)
+
+	// Local files are seeked, while memfs files are read up to the offset and
+	// reopened to seek backwards.
+	for name, path := range map[string]string{"local": localPath, "memfs": memPath} {
+		t.Run(name, func(t *testing.T) {
+			f, err := openParquetFile(ctx, path, int64(len(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			tests := []struct {
+				offset int64
+				whence int
+				want   int64
+			}{
+				{-4, io.SeekEnd, int64(len(data)) - 4},
+				{0, io.SeekStart, 0},
+				{2 * maxSkip, io.SeekCurrent, 2*maxSkip + 4},
+				{10, io.SeekStart, 10},
+				{100, io.SeekCurrent, 114},
+			}
+			for _, test := range tests {
+				got, err := f.Seek(test.offset, test.whence)
+				if err != nil || got != test.want {
+					t.Fatalf("Seek(%v, %v) = %v, %v, want %v, nil", test.offset, test.whence, got, err, test.want)
+				}
+				buf := make([]byte, 4)
+				if _, err := io.ReadFull(f, buf); err != nil {
+					t.Fatalf("Read() at %v failed: %v", got, err)
+				}
+				if want := data[got : got+4]; string(buf) != string(want) {
+					t.Errorf("Read() at %v = %v, want %v", got, buf, want)
+				}
+			}
+		})
+	}
+}
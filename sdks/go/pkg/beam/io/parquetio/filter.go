@@ -0,0 +1,332 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parquetio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/xitongsys/parquet-go/parquet"
+)
+
+// Op is a comparison operator of a filter.
+type Op string
+
+// Comparison operators of filters.
+const (
+	Eq   Op = "="
+	Lt   Op = "<"
+	LtEq Op = "<="
+	Gt   Op = ">"
+	GtEq Op = ">="
+)
+
+// filter is a comparison of a column with a value. The value is held in the
+// field of its kind, so that it survives serialization.
+type filter struct {
+	Column string  `json:"column"`
+	Op     Op      `json:"op"`
+	Kind   string  `json:"kind"`
+	Int    int64   `json:"int,omitempty"`
+	Float  float64 `json:"float,omitempty"`
+	String string  `json:"string,omitempty"`
+	Bool   bool    `json:"bool,omitempty"`
+}
+
+const (
+	kindInt    = "int"
+	kindFloat  = "float"
+	kindString = "string"
+	kindBool   = "bool"
+)
+
+func newFilter(column string, op Op, value any) (filter, error) {
+	switch op {
+	case Eq, Lt, LtEq, Gt, GtEq:
+	default:
+		return filter{}, fmt.Errorf("invalid operator %q", op)
+	}
+	f := filter{Column: column, Op: op}
+	v, ok := comparable(reflect.ValueOf(value))
+	if !ok {
+		return filter{}, fmt.Errorf("unsupported value %v of type %T for column %v", value, value, column)
+	}
+	switch v := v.(type) {
+	case int64:
+		f.Kind, f.Int = kindInt, v
+	case float64:
+		f.Kind, f.Float = kindFloat, v
+	case string:
+		f.Kind, f.String = kindString, v
+	case bool:
+		f.Kind, f.Bool = kindBool, v
+	}
+	return f, nil
+}
+
+func (f filter) value() any {
+	switch f.Kind {
+	case kindInt:
+		return f.Int
+	case kindFloat:
+		return f.Float
+	case kindString:
+		return f.String
+	default:
+		return f.Bool
+	}
+}
+
+// comparable returns the value as an int64, float64, string or bool, and
+// whether it has such a kind. Pointers are dereferenced, and nil pointers
+// have no value.
+func comparable(v reflect.Value) (any, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	switch k := v.Kind(); {
+	case k >= reflect.Int && k <= reflect.Int64:
+		return v.Int(), true
+	case k >= reflect.Uint && k <= reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return float64(v.Uint()), true
+		}
+		return int64(v.Uint()), true
+	case k == reflect.Float32 || k == reflect.Float64:
+		return v.Float(), true
+	case k == reflect.String:
+		return v.String(), true
+	case k == reflect.Bool:
+		return v.Bool(), true
+	}
+	return nil, false
+}
+
+// compare compares two comparable values, and reports whether they are of
+// comparable kinds. Integers and floats are compared as numbers.
+func compare(a, b any) (int, bool) {
+	switch a := a.(type) {
+	case int64:
+		switch b := b.(type) {
+		case int64:
+			return cmpOrdered(a, b), true
+		case float64:
+			return cmpOrdered(float64(a), b), true
+		}
+	case float64:
+		switch b := b.(type) {
+		case int64:
+			return cmpOrdered(a, float64(b)), true
+		case float64:
+			return cmpOrdered(a, b), true
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), true
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			switch {
+			case a == b:
+				return 0, true
+			case !a:
+				return -1, true
+			default:
+				return 1, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func cmpOrdered[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// match reports whether the value matches the filter. Values that are null or
+// can't be compared don't match.
+func (f filter) match(v reflect.Value) bool {
+	val, ok := comparable(v)
+	if !ok {
+		return false
+	}
+	c, ok := compare(val, f.value())
+	if !ok {
+		return false
+	}
+	switch f.Op {
+	case Eq:
+		return c == 0
+	case Lt:
+		return c < 0
+	case LtEq:
+		return c <= 0
+	case Gt:
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+// mayMatch reports whether any value between min and max may match the
+// filter.
+func (f filter) mayMatch(min, max any) bool {
+	cmin, ok1 := compare(min, f.value())
+	cmax, ok2 := compare(max, f.value())
+	if !ok1 || !ok2 {
+		return true
+	}
+	switch f.Op {
+	case Eq:
+		return cmin <= 0 && cmax >= 0
+	case Lt:
+		return cmin < 0
+	case LtEq:
+		return cmin <= 0
+	case Gt:
+		return cmax > 0
+	default:
+		return cmax >= 0
+	}
+}
+
+// columnStats returns the min and max values of the statistics of a column
+// chunk, and whether they can be used for pruning. Statistics are only used
+// for types whose order is that of their Go values.
+func columnStats(el *parquet.SchemaElement, stats *parquet.Statistics) (min, max any, ok bool) {
+	if stats == nil {
+		return nil, nil, false
+	}
+	minb, maxb := stats.MinValue, stats.MaxValue
+	if minb == nil || maxb == nil {
+		// The deprecated min and max are signed, so are only valid for
+		// numbers.
+		if el.GetType() == parquet.Type_BYTE_ARRAY {
+			return nil, nil, false
+		}
+		minb, maxb = stats.Min, stats.Max
+	}
+	if minb == nil || maxb == nil {
+		return nil, nil, false
+	}
+	if el.IsSetConvertedType() {
+		switch el.GetConvertedType() {
+		case parquet.ConvertedType_UINT_8, parquet.ConvertedType_UINT_16, parquet.ConvertedType_UINT_32,
+			parquet.ConvertedType_UINT_64, parquet.ConvertedType_DECIMAL, parquet.ConvertedType_INTERVAL:
+			return nil, nil, false
+		}
+	}
+	if lt := el.GetLogicalType(); lt != nil && (lt.IsSetDECIMAL() || lt.IsSetINTEGER() && !lt.GetINTEGER().GetIsSigned()) {
+		return nil, nil, false
+	}
+
+	decode := func(b []byte) (any, bool) {
+		switch el.GetType() {
+		case parquet.Type_BOOLEAN:
+			return len(b) == 1 && b[0] != 0, len(b) == 1
+		case parquet.Type_INT32:
+			return int64(int32(binary.LittleEndian.Uint32(b))), len(b) == 4
+		case parquet.Type_INT64:
+			return int64(binary.LittleEndian.Uint64(b)), len(b) == 8
+		case parquet.Type_FLOAT:
+			if len(b) != 4 {
+				return nil, false
+			}
+			f := float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+			return f, !math.IsNaN(f)
+		case parquet.Type_DOUBLE:
+			if len(b) != 8 {
+				return nil, false
+			}
+			f := math.Float64frombits(binary.LittleEndian.Uint64(b))
+			return f, !math.IsNaN(f)
+		case parquet.Type_BYTE_ARRAY:
+			return string(b), true
+		}
+		return nil, false
+	}
+	if len(minb) < 4 && el.GetType() != parquet.Type_BOOLEAN && el.GetType() != parquet.Type_BYTE_ARRAY {
+		return nil, nil, false
+	}
+	var ok1, ok2 bool
+	min, ok1 = decode(minb)
+	max, ok2 = decode(maxb)
+	return min, max, ok1 && ok2
+}
+
+// rowGroupMayMatch reports whether any row of the row group may match all of
+// the filters, from the statistics of its column chunks.
+func rowGroupMayMatch(rg *parquet.RowGroup, leaves map[string]*parquet.SchemaElement, filters []filter) bool {
+	for _, f := range filters {
+		for _, chunk := range rg.GetColumns() {
+			md := chunk.GetMetaData()
+			if md == nil || strings.Join(md.GetPathInSchema(), ".") != f.Column {
+				continue
+			}
+			stats := md.GetStatistics()
+			if stats != nil && stats.IsSetNullCount() && stats.GetNullCount() == rg.GetNumRows() {
+				// Null values match no filter.
+				return false
+			}
+			el, ok := leaves[f.Column]
+			if !ok {
+				continue
+			}
+			if min, max, ok := columnStats(el, stats); ok && !f.mayMatch(min, max) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// leafElements returns the leaf elements of a flattened schema by their
+// dot-separated paths, excluding the root.
+func leafElements(elements []*parquet.SchemaElement) map[string]*parquet.SchemaElement {
+	leaves := make(map[string]*parquet.SchemaElement)
+	if len(elements) == 0 {
+		return leaves
+	}
+	i := 1
+	var walk func(prefix []string, n int32)
+	walk = func(prefix []string, n int32) {
+		for c := int32(0); c < n && i < len(elements); c++ {
+			el := elements[i]
+			i++
+			p := append(append([]string{}, prefix...), el.GetName())
+			if el.GetNumChildren() == 0 {
+				leaves[strings.Join(p, ".")] = el
+				continue
+			}
+			walk(p, el.GetNumChildren())
+		}
+	}
+	walk(nil, elements[0].GetNumChildren())
+	return leaves
+}
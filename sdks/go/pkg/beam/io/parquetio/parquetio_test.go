@@ -16,19 +16,29 @@
 package parquetio
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/fileio"
 	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/local"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
 	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
 	"github.com/xitongsys/parquet-go/reader"
 )
 
+func init() {
+	beam.RegisterType(reflect.TypeOf((*Student)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*nameAge)(nil)).Elem())
+}
+
 func TestMain(m *testing.M) {
 	ptest.Main(m)
 }
@@ -124,3 +134,158 @@ func TestWrite(t *testing.T) {
 		t.Fatalf("students differs from studentList. got %+v, expected %+v", students, studentList)
 	}
 }
+
+// nameAge has a subset of the columns of Student, in a different order.
+type nameAge struct {
+	Age  int32  `parquet:"name=age, type=INT32, encoding=PLAIN"`
+	Name string `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+}
+
+func TestRead_Projection(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	rows := Read(s, "../../../../data/student.parquet", reflect.TypeOf(nameAge{}))
+	passert.Equals(s, rows, nameAge{Name: "StudentName", Age: 20}, nameAge{Name: "StudentName", Age: 21})
+	ptest.RunAndValidate(t, p)
+}
+
+func TestRead_Filter(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	rows := Read(s, "../../../../data/student.parquet", reflect.TypeOf(nameAge{}), ReadFilter("age", Gt, 20))
+	passert.Equals(s, rows, nameAge{Name: "StudentName", Age: 21})
+	ptest.RunAndValidate(t, p)
+}
+
+// writeStudents writes n students with ids from 0 to n to a file with many
+// row groups, and returns the footer of the file.
+func writeStudents(t *testing.T, n int, opts ...WriteOptionFn) (string, *parquet.FileMetaData) {
+	t.Helper()
+	var students []any
+	for i := 0; i < n; i++ {
+		students = append(students, Student{Name: fmt.Sprintf("s%d", i), Age: int32(i % 100), Id: int64(i)})
+	}
+	out := filepath.Join(t.TempDir(), "students.parquet")
+	p, s, col := ptest.CreateList(students)
+	Write(s, out, col, opts...)
+	ptest.RunAndValidate(t, p)
+
+	pf, err := openParquetFile(context.Background(), out, fileSize(t, out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pf.Close()
+	footer, err := readFooter(pf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out, footer
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func TestWrite_Options(t *testing.T) {
+	_, footer := writeStudents(t, 100, WriteCompression("gzip"))
+	for _, chunk := range footer.GetRowGroups()[0].GetColumns() {
+		if got, want := chunk.GetMetaData().GetCodec(), parquet.CompressionCodec_GZIP; got != want {
+			t.Errorf("codec of column %v = %v, want %v", chunk.GetMetaData().GetPathInSchema(), got, want)
+		}
+	}
+}
+
+func TestRead_RowGroups(t *testing.T) {
+	const n = 20000
+	out, footer := writeStudents(t, n, WriteRowGroupSize(1), WriteCompression("zstd"))
+	groups := len(footer.GetRowGroups())
+	if groups < 2 {
+		t.Fatalf("wrote %v row groups, want several", groups)
+	}
+
+	p, s := beam.NewPipelineWithRoot()
+	passert.Count(s, Read(s, out, reflect.TypeOf(Student{})), "all", n)
+	passert.Count(s, Read(s, out, reflect.TypeOf(nameAge{}), ReadFilter("age", Eq, 7)), "age", n/100)
+	passert.Equals(s, Read(s, out, reflect.TypeOf(Student{}), ReadFilter("id", GtEq, n-1), ReadFilter("name", Eq, fmt.Sprintf("s%d", n-1))),
+		Student{Name: fmt.Sprintf("s%d", n-1), Age: (n - 1) % 100, Id: n - 1})
+	ptest.RunAndValidate(t, p)
+
+	// The ids increase, so only the last row group may have the last id.
+	fn := &footerFn{Filters: []filter{mustFilter(t, "id", GtEq, n-1)}}
+	var got []rowGroups
+	file := fileio.ReadableFile{Metadata: fileio.FileMetadata{Path: out, Size: fileSize(t, out)}}
+	if err := fn.ProcessElement(context.Background(), file, func(g rowGroups) { got = append(got, g) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || !reflect.DeepEqual(got[0].Groups, []int64{int64(groups - 1)}) {
+		t.Errorf("footerFn emitted %+v, want the last of %v row groups", got, groups)
+	}
+}
+
+func TestRead_Batches(t *testing.T) {
+	defer func(size int64) { readBatchSize = size }(readBatchSize)
+	readBatchSize = 7
+	const n = 100
+	out, footer := writeStudents(t, n)
+	if got := footer.GetRowGroups()[0].GetNumRows(); got != n {
+		t.Fatalf("wrote %v rows to the first row group, want %v", got, n)
+	}
+
+	var want []any
+	for i := 0; i < n; i++ {
+		want = append(want, Student{Name: fmt.Sprintf("s%d", i), Age: int32(i % 100), Id: int64(i)})
+	}
+	p, s := beam.NewPipelineWithRoot()
+	passert.Equals(s, Read(s, out, reflect.TypeOf(Student{})), want...)
+	ptest.RunAndValidate(t, p)
+}
+
+func mustFilter(t *testing.T, column string, op Op, value any) filter {
+	t.Helper()
+	f, err := newFilter(column, op, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestFilter(t *testing.T) {
+	age := int32(30)
+	tests := []struct {
+		op       Op
+		value    any
+		v        any
+		min, max any
+		match    bool
+		mayMatch bool
+	}{
+		{Eq, 30, age, int64(10), int64(20), true, false},
+		{Eq, 30, &age, int64(30), int64(40), true, true},
+		{Lt, 30.5, age, int64(31), int64(40), true, false},
+		{LtEq, 30, age, int64(30), int64(40), true, true},
+		{Gt, 30, age, int64(10), int64(30), false, false},
+		{GtEq, 30, (*int32)(nil), int64(10), int64(30), false, true},
+		{Eq, "b", "b", "a", "c", true, true},
+		{Lt, "b", "c", "b", "c", false, false},
+		{Eq, true, false, false, false, false, false},
+	}
+	for _, test := range tests {
+		f := mustFilter(t, "c", test.op, test.value)
+		if got := f.match(reflect.ValueOf(test.v)); got != test.match {
+			t.Errorf("filter %v %v match(%v) = %v, want %v", test.op, test.value, test.v, got, test.match)
+		}
+		if got := f.mayMatch(test.min, test.max); got != test.mayMatch {
+			t.Errorf("filter %v %v mayMatch(%v, %v) = %v, want %v", test.op, test.value, test.min, test.max, got, test.mayMatch)
+		}
+	}
+
+	if _, err := newFilter("c", "!=", 1); err == nil {
+		t.Error("newFilter() with invalid operator succeeded, want error")
+	}
+	if _, err := newFilter("c", Eq, []int{1}); err == nil {
+		t.Error("newFilter() with invalid value succeeded, want error")
+	}
+}
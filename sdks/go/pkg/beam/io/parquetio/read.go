@@ -0,0 +1,328 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parquetio
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/fileio"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/rtrackers/offsetrange"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/log"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/schema"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*rowGroups)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*filter)(nil)).Elem())
+	register.DoFn3x1[context.Context, fileio.ReadableFile, func(rowGroups), error](&footerFn{})
+	register.DoFn4x1[context.Context, *sdf.LockRTracker, rowGroups, func(beam.X), error](&readFn{})
+	register.Emitter1[rowGroups]()
+	register.Emitter1[beam.X]()
+}
+
+// parallelism is the number of columns of a row group that are read
+// concurrently.
+const parallelism = 4
+
+// readBatchSize is the number of rows of a row group that are read at once.
+var readBatchSize int64 = 1024
+
+type readOption struct {
+	Filters  []filter
+	FileOpts []fileio.ReadOptionFn
+}
+
+// ReadOptionFn is a function that can be passed to Read or ReadAll to
+// configure options for reading files.
+type ReadOptionFn func(*readOption)
+
+// ReadFilter specifies that only the rows whose column compares to the value
+// with the operator are read, such as ReadFilter("age", parquetio.Gt, 20). The
+// column is the name of a top-level column of the target struct, and the
+// value is a number, string or bool. Rows with null values match no filter.
+// Multiple filters must all match.
+//
+// Row groups whose statistics show that none of their rows match are not
+// read at all, and the rows of the other row groups are filtered as they are
+// read.
+func ReadFilter(column string, op Op, value any) ReadOptionFn {
+	f, err := newFilter(column, op, value)
+	if err != nil {
+		panic(fmt.Sprintf("parquetio.ReadFilter: %v", err))
+	}
+	return func(o *readOption) {
+		o.Filters = append(o.Filters, f)
+	}
+}
+
+// ReadFileOptions specifies options for matching and reading the files.
+func ReadFileOptions(opts ...fileio.ReadOptionFn) ReadOptionFn {
+	return func(o *readOption) {
+		o.FileOpts = append(o.FileOpts, opts...)
+	}
+}
+
+// Read reads a set of files and returns lines as a PCollection<elem>
+// based on type of a parquetStruct (struct with parquet tags).
+// For example:
+//
+//	type Student struct {
+//	  Name    string  `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+//	  Age     int32   `parquet:"name=age, type=INT32, encoding=PLAIN"`
+//	  Id      int64   `parquet:"name=id, type=INT64"`
+//	  Weight  float32 `parquet:"name=weight, type=FLOAT"`
+//	  Sex     bool    `parquet:"name=sex, type=BOOLEAN"`
+//	  Day     int32   `parquet:"name=day, type=INT32, convertedtype=DATE"`
+//	  Ignored int32   //without parquet tag and won't write
+//	}
+//
+// Only the columns of the fields of the struct are read, so a struct with a
+// subset of the columns of the files reads just those columns. The row groups
+// of each file are read in parallel. Read accepts a variadic number of
+// ReadOptionFn that can be used to filter the rows.
+func Read(s beam.Scope, glob string, t reflect.Type, opts ...ReadOptionFn) beam.PCollection {
+	s = s.Scope("parquetio.Read")
+	filesystem.ValidateScheme(glob)
+	return read(s, t, beam.Create(s, glob), opts...)
+}
+
+// ReadAll expands and reads the filename given as globs by the incoming
+// PCollection<string>, as described in Read.
+func ReadAll(s beam.Scope, col beam.PCollection, t reflect.Type, opts ...ReadOptionFn) beam.PCollection {
+	s = s.Scope("parquetio.ReadAll")
+	return read(s, t, col, opts...)
+}
+
+func read(s beam.Scope, t reflect.Type, col beam.PCollection, opts ...ReadOptionFn) beam.PCollection {
+	option := &readOption{}
+	for _, opt := range opts {
+		opt(option)
+	}
+	fields, err := columnFields(t)
+	if err != nil {
+		panic(fmt.Sprintf("parquetio: %v", err))
+	}
+	for _, f := range option.Filters {
+		if _, ok := fields[f.Column]; !ok {
+			panic(fmt.Sprintf("parquetio: filtered column %q is not a column of %v", f.Column, t))
+		}
+	}
+
+	matches := fileio.MatchAll(s, col, fileio.MatchEmptyAllow())
+	fileOpts := append([]fileio.ReadOptionFn{fileio.ReadUncompressed()}, option.FileOpts...)
+	files := fileio.ReadMatches(s, matches, fileOpts...)
+	groups := beam.ParDo(s, &footerFn{Filters: option.Filters}, files)
+	return beam.ParDo(s,
+		&readFn{Type: beam.EncodedType{T: t}, Filters: option.Filters},
+		groups,
+		beam.TypeDefinition{Var: beam.XType, T: t},
+	)
+}
+
+// rowGroups is the indices of the row groups of a file that are read.
+type rowGroups struct {
+	File   fileio.ReadableFile
+	Groups []int64
+}
+
+// footerFn reads the footer of a file and emits the row groups whose
+// statistics don't rule out the filters.
+type footerFn struct {
+	Filters []filter `json:"filters"`
+}
+
+func (fn *footerFn) ProcessElement(ctx context.Context, file fileio.ReadableFile, emit func(rowGroups)) error {
+	pf, err := openParquetFile(ctx, file.Metadata.Path, file.Metadata.Size)
+	if err != nil {
+		return err
+	}
+	defer pf.Close()
+
+	footer, err := readFooter(pf)
+	if err != nil {
+		return fmt.Errorf("parquetio: reading footer of %v: %w", file.Metadata.Path, err)
+	}
+	leaves := leafElements(footer.GetSchema())
+
+	var groups []int64
+	for i, rg := range footer.GetRowGroups() {
+		if rg.GetNumRows() == 0 {
+			continue
+		}
+		if !rowGroupMayMatch(rg, leaves, fn.Filters) {
+			continue
+		}
+		groups = append(groups, int64(i))
+	}
+	if pruned := len(footer.GetRowGroups()) - len(groups); pruned > 0 {
+		log.Debugf(ctx, "parquetio: skipping %v of %v row groups of %v", pruned, len(footer.GetRowGroups()), file.Metadata.Path)
+	}
+	if len(groups) > 0 {
+		emit(rowGroups{File: file, Groups: groups})
+	}
+	return nil
+}
+
+func readFooter(pf *parquetFile) (*parquet.FileMetaData, error) {
+	pr := &reader.ParquetReader{PFile: pf}
+	if err := pr.ReadFooter(); err != nil {
+		return nil, err
+	}
+	return pr.Footer, nil
+}
+
+// readFn reads the rows of row groups of a file, with a restriction over the
+// row groups, so that the row groups of a file can be read in parallel.
+type readFn struct {
+	Type    beam.EncodedType `json:"type"`
+	Filters []filter         `json:"filters"`
+
+	fields map[string]int
+}
+
+func (fn *readFn) Setup() error {
+	var err error
+	fn.fields, err = columnFields(fn.Type.T)
+	return err
+}
+
+// CreateInitialRestriction creates an offset range restriction over the
+// indices of the row groups.
+func (fn *readFn) CreateInitialRestriction(groups rowGroups) offsetrange.Restriction {
+	return offsetrange.Restriction{Start: 0, End: int64(len(groups.Groups))}
+}
+
+// SplitRestriction splits a restriction into one restriction per row group.
+func (fn *readFn) SplitRestriction(_ rowGroups, rest offsetrange.Restriction) []offsetrange.Restriction {
+	return rest.SizedSplits(1)
+}
+
+// RestrictionSize returns the number of row groups of a restriction.
+func (fn *readFn) RestrictionSize(_ rowGroups, rest offsetrange.Restriction) float64 {
+	return rest.Size()
+}
+
+// CreateTracker creates sdf.LockRTrackers wrapping offsetrange.Trackers for
+// each restriction.
+func (fn *readFn) CreateTracker(rest offsetrange.Restriction) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(offsetrange.NewTracker(rest))
+}
+
+// ProcessElement reads the row groups that are claimed, reading only the
+// column chunks of the fields of the target struct.
+func (fn *readFn) ProcessElement(ctx context.Context, rt *sdf.LockRTracker, groups rowGroups, emit func(beam.X)) error {
+	path := groups.File.Metadata.Path
+	pf, err := openParquetFile(ctx, path, groups.File.Metadata.Size)
+	if err != nil {
+		return err
+	}
+	defer pf.Close()
+
+	footer, err := readFooter(pf)
+	if err != nil {
+		return fmt.Errorf("parquetio: reading footer of %v: %w", path, err)
+	}
+	obj := reflect.New(fn.Type.T).Interface()
+	sh, err := schema.NewSchemaHandlerFromStruct(obj)
+	if err != nil {
+		return fmt.Errorf("parquetio: schema of %v: %w", fn.Type.T, err)
+	}
+	pr := &reader.ParquetReader{
+		SchemaHandler: sh,
+		NP:            parallelism,
+		Footer:        footer,
+		PFile:         pf,
+		ObjType:       fn.Type.T,
+	}
+	pr.RenameSchema()
+
+	rest := rt.GetRestriction().(offsetrange.Restriction)
+	for i := rest.Start; rt.TryClaim(i); i++ {
+		g := groups.Groups[i]
+		if g >= int64(len(footer.GetRowGroups())) {
+			return fmt.Errorf("parquetio: row group %v of %v doesn't exist", g, path)
+		}
+		if err := fn.readRowGroup(pr, footer, g, emit); err != nil {
+			return fmt.Errorf("parquetio: reading row group %v of %v: %w", g, path, err)
+		}
+	}
+	return nil
+}
+
+// readRowGroup reads the rows of a row group, by reading the row group as if
+// it were the only row group of the file. The rows are read and emitted in
+// batches of readBatchSize, so that only the pages of the column chunks that
+// a batch is decoded from are held in memory.
+func (fn *readFn) readRowGroup(pr *reader.ParquetReader, footer *parquet.FileMetaData, g int64, emit func(beam.X)) error {
+	rg := footer.GetRowGroups()[g]
+	single := *footer
+	single.RowGroups = []*parquet.RowGroup{rg}
+	single.NumRows = rg.GetNumRows()
+
+	pr.Footer = &single
+	pr.ColumnBuffers = make(map[string]*reader.ColumnBufferType)
+	defer pr.ReadStop()
+	for i, el := range pr.SchemaHandler.SchemaElements {
+		if el.GetNumChildren() != 0 {
+			continue
+		}
+		path := pr.SchemaHandler.IndexMap[int32(i)]
+		cb, err := reader.NewColumnBuffer(pr.PFile, pr.Footer, pr.SchemaHandler, path)
+		if cb != nil {
+			pr.ColumnBuffers[path] = cb
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for left := rg.GetNumRows(); left > 0; {
+		n := min(left, readBatchSize)
+		rows, err := pr.ReadByNumber(int(n))
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if fn.match(row) {
+				emit(row)
+			}
+		}
+		left -= n
+	}
+	return nil
+}
+
+// match reports whether a row matches all of the filters.
+func (fn *readFn) match(row any) bool {
+	if len(fn.Filters) == 0 {
+		return true
+	}
+	v := reflect.ValueOf(row)
+	for _, f := range fn.Filters {
+		if !f.match(v.Field(fn.fields[f.Column])) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,152 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parquetio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/fileio"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*parquetSink)(nil)).Elem())
+}
+
+type writeOption struct {
+	Compression  string
+	RowGroupSize int64
+	FileOpts     []fileio.WriteOptionFn
+}
+
+// WriteOptionFn is a function that can be passed to Write to configure
+// options for writing files.
+type WriteOptionFn func(*writeOption)
+
+// WriteCompression specifies the codec that the pages of the files are
+// compressed with, one of "uncompressed", "snappy", "gzip", "lz4" or "zstd".
+// By default, pages are compressed with snappy.
+func WriteCompression(codec string) WriteOptionFn {
+	if _, err := compressionCodec(codec); err != nil {
+		panic(fmt.Sprintf("parquetio.WriteCompression: %v", err))
+	}
+	return func(o *writeOption) {
+		o.Compression = codec
+	}
+}
+
+// WriteRowGroupSize specifies the approximate size in bytes of the row groups
+// of the files, which are the units that files are read in parallel by. By
+// default, row groups are 128 MB.
+func WriteRowGroupSize(size int64) WriteOptionFn {
+	if size <= 0 {
+		panic(fmt.Sprintf("parquetio.WriteRowGroupSize: size must be positive, got %v", size))
+	}
+	return func(o *writeOption) {
+		o.RowGroupSize = size
+	}
+}
+
+// WriteFileOptions specifies options for writing the files, such as their
+// number of shards or suffix.
+func WriteFileOptions(opts ...fileio.WriteOptionFn) WriteOptionFn {
+	return func(o *writeOption) {
+		o.FileOpts = append(o.FileOpts, opts...)
+	}
+}
+
+func compressionCodec(codec string) (parquet.CompressionCodec, error) {
+	switch c := strings.ToUpper(codec); c {
+	case "UNCOMPRESSED", "SNAPPY", "GZIP", "LZ4", "ZSTD":
+		return parquet.CompressionCodecFromString(c)
+	}
+	return 0, fmt.Errorf("unsupported compression codec %q", codec)
+}
+
+// Write writes a PCollection<parquetStruct> to .parquet file.
+// Write expects elements of a struct type with parquet tags
+// For example:
+//
+//	type Student struct {
+//	  Name    string  `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+//	  Age     int32   `parquet:"name=age, type=INT32, encoding=PLAIN"`
+//	  Id      int64   `parquet:"name=id, type=INT64"`
+//	  Weight  float32 `parquet:"name=weight, type=FLOAT"`
+//	  Sex     bool    `parquet:"name=sex, type=BOOLEAN"`
+//	  Day     int32   `parquet:"name=day, type=INT32, convertedtype=DATE"`
+//	  Ignored int32   //without parquet tag and won't write
+//	}
+//
+// Write returns a PCollection<string> with the names of the written files.
+// It accepts a variadic number of WriteOptionFn that can be used to configure
+// the compression and row groups of the files, and how the files are written.
+// By default, a single file is written per window, as described in
+// fileio.WriteFiles.
+func Write(s beam.Scope, filename string, col beam.PCollection, opts ...WriteOptionFn) beam.PCollection {
+	t := col.Type().Type()
+	s = s.Scope("parquetio.Write")
+
+	option := &writeOption{}
+	for _, opt := range opts {
+		opt(option)
+	}
+	sink := &parquetSink{
+		Type:         beam.EncodedType{T: t},
+		Compression:  option.Compression,
+		RowGroupSize: option.RowGroupSize,
+	}
+	fileOpts := append([]fileio.WriteOptionFn{fileio.WriteNumShards(1)}, option.FileOpts...)
+	return fileio.WriteFiles(s, filename, sink, col, fileOpts...)
+}
+
+// parquetSink writes elements of a struct type with parquet tags to a file.
+type parquetSink struct {
+	Type         beam.EncodedType `json:"type"`
+	Compression  string           `json:"compression,omitempty"`
+	RowGroupSize int64            `json:"rowGroupSize,omitempty"`
+
+	pw *writer.ParquetWriter
+}
+
+func (a *parquetSink) Open(_ context.Context, w io.Writer) (err error) {
+	a.pw, err = writer.NewParquetWriterFromWriter(w, reflect.New(a.Type.T).Interface(), parallelism)
+	if err != nil {
+		return err
+	}
+	if a.Compression != "" {
+		if a.pw.CompressionType, err = compressionCodec(a.Compression); err != nil {
+			return err
+		}
+	}
+	if a.RowGroupSize > 0 {
+		a.pw.RowGroupSize = a.RowGroupSize
+	}
+	return nil
+}
+
+func (a *parquetSink) Write(_ context.Context, elm any) error {
+	return a.pw.Write(elm)
+}
+
+func (a *parquetSink) Flush(_ context.Context) error {
+	return a.pw.WriteStop()
+}
@@ -0,0 +1,42 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubio
+
+import (
+	"context"
+	"os"
+
+	vkit "cloud.google.com/go/pubsub/apiv1"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// newSubscriberClient creates a client of the low-level subscriber API, which
+// unlike the streaming pull of pubsub.Client lets messages be acknowledged after
+// the bundle that emitted them is finalized. Like pubsub.NewClient, it connects
+// to the emulator if PUBSUB_EMULATOR_HOST is set.
+func newSubscriberClient(ctx context.Context) (*vkit.SubscriberClient, error) {
+	var opts []option.ClientOption
+	if addr := os.Getenv("PUBSUB_EMULATOR_HOST"); addr != "" {
+		opts = append(opts,
+			option.WithEndpoint(addr),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+			option.WithoutAuthentication(),
+		)
+	}
+	return vkit.NewSubscriberClient(ctx, opts...)
+}
@@ -0,0 +1,428 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	vkit "cloud.google.com/go/pubsub/apiv1"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/util/pubsubx"
+	"github.com/google/uuid"
+	pb "google.golang.org/genproto/googleapis/pubsub/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func init() {
+	register.DoFn3x1[context.Context, []byte, func(string), error](&createSubscriptionFn{})
+	register.DoFn6x2[
+		context.Context, *watermarkEstimator, beam.BundleFinalization, *sdf.LockRTracker, string,
+		func(beam.EventTime, *pb.PubsubMessage), sdf.ProcessContinuation, error,
+	](&readFn{})
+	register.Function2x0(messageData)
+	register.Emitter1[string]()
+	register.Emitter2[beam.EventTime, *pb.PubsubMessage]()
+}
+
+const (
+	maxPullMessages = 1000
+	pullTimeout     = 5 * time.Second
+	// maxReadTime is how long a read keeps pulling messages before it
+	// checkpoints, so that the messages are acknowledged when the bundle is
+	// finalized.
+	maxReadTime = 10 * time.Second
+	// ackDeadline is how long pulled messages are leased for, which must cover
+	// the time until their bundle is finalized.
+	ackDeadline = 2 * time.Minute
+	// dedupWindow is how long the IDs of messages are remembered for
+	// deduplication after their bundle is finalized.
+	dedupWindow = 10 * time.Minute
+	assumedLag  = 1 * time.Second
+	resumeDelay = 5 * time.Second
+	subIDPrefix = "beam-"
+	// subExpiration is how long the subscriptions created for topics are kept
+	// while idle, which is the minimum that Pub/Sub allows.
+	subExpiration = 24 * time.Hour
+)
+
+// NativeRead reads an unbounded number of messages from the given Pub/Sub topic or subscription
+// with the Pub/Sub client library. It produces an unbounded PCollection<*PubsubMessage>, if
+// WithAttributes is set, or an unbounded PCollection<[]byte>.
+//
+// Exactly one of Topic or Subscription must be set in the ReadOptions. If a Topic is set, a new
+// subscription to it is created when the pipeline starts, so only messages published after that are
+// read. The subscription isn't deleted when the pipeline stops, since workers can't tell when a
+// streaming pipeline is done, but it expires once it has been idle for a day.
+//
+// Messages are acknowledged once the bundle that emitted them is finalized. Messages that are
+// redelivered after their bundle was finalized, such as when acknowledging them failed, are dropped
+// if the bundle was finalized recently by the same worker. Messages of bundles that failed are
+// emitted again when they are redelivered. Messages are identified by the IDAttribute, if set, or
+// by their message IDs otherwise.
+//
+// The event time of the messages is the value of the TimestampAttribute, if set, as milliseconds
+// since the epoch or an RFC 3339 time, and their publish time otherwise. The watermark advances to
+// the earliest event time of each batch of messages that are pulled, and to the current time when
+// the subscription is idle.
+func NativeRead(s beam.Scope, project string, opts ReadOptions) beam.PCollection {
+	s = s.Scope("pubsubio.NativeRead")
+
+	if (opts.Topic == "" && opts.Subscription == "") || (opts.Topic != "" && opts.Subscription != "") {
+		panic("Exactly one of Topic or Subscription must be set in ReadOptions")
+	}
+
+	var sub beam.PCollection
+	if opts.Topic != "" {
+		sub = beam.ParDo(s, &createSubscriptionFn{
+			Project:      project,
+			Topic:        opts.Topic,
+			Subscription: subIDPrefix + uuid.NewString(),
+		}, beam.Impulse(s))
+	} else {
+		sub = beam.Create(s, opts.Subscription)
+	}
+
+	msgs := beam.ParDo(s, &readFn{
+		Project:            project,
+		IDAttribute:        opts.IDAttribute,
+		TimestampAttribute: opts.TimestampAttribute,
+	}, sub)
+	if opts.WithAttributes {
+		return msgs
+	}
+	return beam.ParDo(s, messageData, msgs)
+}
+
+func messageData(msg *pb.PubsubMessage, emit func([]byte)) {
+	emit(msg.GetData())
+}
+
+// createSubscriptionFn creates a subscription to a topic, and emits its ID.
+type createSubscriptionFn struct {
+	Project      string
+	Topic        string
+	Subscription string
+}
+
+func (fn *createSubscriptionFn) ProcessElement(ctx context.Context, _ []byte, emit func(string)) error {
+	client, err := pubsub.NewClient(ctx, fn.Project)
+	if err != nil {
+		return fmt.Errorf("error creating Pub/Sub client: %v", err)
+	}
+	defer client.Close()
+
+	// The subscription exists if the bundle that created it is retried.
+	sub := client.Subscription(fn.Subscription)
+	exists, err := sub.Exists(ctx)
+	if err != nil {
+		return fmt.Errorf("error checking subscription %v: %v", fn.Subscription, err)
+	}
+	if !exists {
+		sub, err = client.CreateSubscription(ctx, fn.Subscription, pubsub.SubscriptionConfig{
+			Topic:            client.Topic(fn.Topic),
+			ExpirationPolicy: subExpiration,
+		})
+		if err != nil {
+			return fmt.Errorf("error creating subscription to topic %v: %v", fn.Topic, err)
+		}
+	}
+	emit(sub.ID())
+	return nil
+}
+
+type readFn struct {
+	Project            string
+	IDAttribute        string
+	TimestampAttribute string
+	client             *vkit.SubscriberClient
+
+	// mu guards seen and seenOrder, which are updated by finalization
+	// callbacks.
+	mu sync.Mutex
+	// seen holds the times at which the bundles that emitted messages were
+	// finalized, by the IDs of the messages.
+	seen map[string]time.Time
+	// seenOrder holds the IDs in seen in the order they were finalized, so
+	// that expired IDs are forgotten without scanning seen.
+	seenOrder []seenID
+}
+
+// seenID is a message ID remembered for deduplication, and the time at which
+// the bundle that emitted it was finalized.
+type seenID struct {
+	id string
+	t  time.Time
+}
+
+func (fn *readFn) Setup(ctx context.Context) error {
+	if fn.client == nil {
+		client, err := newSubscriberClient(ctx)
+		if err != nil {
+			return fmt.Errorf("error creating Pub/Sub subscriber client: %v", err)
+		}
+		fn.client = client
+	}
+	if fn.seen == nil {
+		fn.seen = make(map[string]time.Time)
+	}
+	return nil
+}
+
+func (fn *readFn) Teardown() error {
+	if fn.client == nil {
+		return nil
+	}
+	err := fn.client.Close()
+	fn.client = nil
+	return err
+}
+
+func (fn *readFn) CreateInitialRestriction(sub string) string {
+	return sub
+}
+
+func (fn *readFn) SplitRestriction(_ string, rest string) []string {
+	return []string{rest}
+}
+
+func (fn *readFn) RestrictionSize(_ string, _ string) float64 {
+	return 1
+}
+
+func (fn *readFn) CreateTracker(rest string) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(newSubscriptionTracker(rest))
+}
+
+func (fn *readFn) TruncateRestriction(_ *sdf.LockRTracker, _ string) string {
+	return ""
+}
+
+func (fn *readFn) InitialWatermarkEstimatorState(_ beam.EventTime, _ string, _ string) int64 {
+	return math.MinInt64
+}
+
+func (fn *readFn) CreateWatermarkEstimator(ms int64) *watermarkEstimator {
+	return &watermarkEstimator{state: ms}
+}
+
+func (fn *readFn) WatermarkEstimatorState(we *watermarkEstimator) int64 {
+	return we.state
+}
+
+func (fn *readFn) ProcessElement(
+	ctx context.Context,
+	we *watermarkEstimator,
+	bf beam.BundleFinalization,
+	rt *sdf.LockRTracker,
+	sub string,
+	emit func(beam.EventTime, *pb.PubsubMessage),
+) (sdf.ProcessContinuation, error) {
+	name := pubsubx.MakeQualifiedSubscriptionName(fn.Project, sub)
+
+	// The IDs of emitted messages are only remembered once the bundle is
+	// finalized, so that messages of failed bundles are emitted again when
+	// they are redelivered.
+	var ackIDs, emitted []string
+	pending := make(map[string]bool)
+	defer func() {
+		if len(ackIDs) == 0 {
+			return
+		}
+		bf.RegisterCallback(ackDeadline, func() error {
+			fn.markSeen(emitted)
+			return fn.acknowledge(name, ackIDs)
+		})
+	}()
+
+	deadline := time.Now().Add(maxReadTime)
+	for time.Now().Before(deadline) {
+		if !rt.TryClaim(sub) {
+			return sdf.StopProcessing(), nil
+		}
+
+		msgs, err := fn.pull(ctx, name)
+		if err != nil {
+			return sdf.StopProcessing(), err
+		}
+		if len(msgs) == 0 {
+			we.advance(time.Now().Add(-assumedLag))
+			return sdf.ResumeProcessingIn(resumeDelay), nil
+		}
+
+		ids := make([]string, len(msgs))
+		for i, msg := range msgs {
+			ids[i] = msg.GetAckId()
+		}
+		if err := fn.extendDeadline(ctx, name, ids); err != nil {
+			return sdf.StopProcessing(), err
+		}
+		ackIDs = append(ackIDs, ids...)
+
+		fn.forgetSeen()
+		earliest := time.Time{}
+		for _, msg := range msgs {
+			m := msg.GetMessage()
+			id := fn.id(m)
+			if pending[id] || fn.isSeen(id) {
+				continue
+			}
+			pending[id] = true
+			emitted = append(emitted, id)
+
+			et := fn.eventTime(m)
+			if earliest.IsZero() || et.Before(earliest) {
+				earliest = et
+			}
+			emit(beam.EventTime(et.UnixMilli()), m)
+		}
+		if !earliest.IsZero() {
+			we.advance(earliest)
+		}
+	}
+	return sdf.ResumeProcessingIn(0), nil
+}
+
+// pull pulls the available messages from a subscription, or none if no
+// message becomes available in time.
+func (fn *readFn) pull(ctx context.Context, name string) ([]*pb.ReceivedMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, pullTimeout)
+	defer cancel()
+
+	resp, err := fn.client.Pull(ctx, &pb.PullRequest{
+		Subscription: name,
+		MaxMessages:  maxPullMessages,
+	})
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error pulling messages from subscription %v: %v", name, err)
+	}
+	return resp.GetReceivedMessages(), nil
+}
+
+// extendDeadline extends the leases of messages so that they aren't
+// redelivered before their bundle is finalized.
+func (fn *readFn) extendDeadline(ctx context.Context, name string, ackIDs []string) error {
+	err := fn.client.ModifyAckDeadline(ctx, &pb.ModifyAckDeadlineRequest{
+		Subscription:       name,
+		AckIds:             ackIDs,
+		AckDeadlineSeconds: int32(ackDeadline.Seconds()),
+	})
+	if err != nil {
+		return fmt.Errorf("error extending ack deadline of messages from subscription %v: %v", name, err)
+	}
+	return nil
+}
+
+func (fn *readFn) acknowledge(name string, ackIDs []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), pullTimeout)
+	defer cancel()
+
+	err := fn.client.Acknowledge(ctx, &pb.AcknowledgeRequest{
+		Subscription: name,
+		AckIds:       ackIDs,
+	})
+	if err != nil {
+		return fmt.Errorf("error acknowledging messages from subscription %v: %v", name, err)
+	}
+	return nil
+}
+
+// id returns the ID of a message for deduplication, which is the value of
+// the ID attribute if set, or the message ID otherwise.
+func (fn *readFn) id(msg *pb.PubsubMessage) string {
+	if fn.IDAttribute != "" {
+		if id, ok := msg.GetAttributes()[fn.IDAttribute]; ok {
+			return id
+		}
+	}
+	return msg.GetMessageId()
+}
+
+// isSeen reports whether a message with the ID was emitted by a bundle that
+// was finalized within the deduplication window.
+func (fn *readFn) isSeen(id string) bool {
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+	_, ok := fn.seen[id]
+	return ok
+}
+
+// markSeen remembers the IDs of the messages emitted by a finalized bundle.
+func (fn *readFn) markSeen(ids []string) {
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+	now := time.Now()
+	for _, id := range ids {
+		fn.seen[id] = now
+		fn.seenOrder = append(fn.seenOrder, seenID{id: id, t: now})
+	}
+}
+
+// forgetSeen forgets the IDs of messages of bundles finalized before the
+// deduplication window.
+func (fn *readFn) forgetSeen() {
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+	cutoff := time.Now().Add(-dedupWindow)
+	n := 0
+	for n < len(fn.seenOrder) && fn.seenOrder[n].t.Before(cutoff) {
+		// An ID seen again later is remembered until its latest time.
+		if e := fn.seenOrder[n]; fn.seen[e.id].Equal(e.t) {
+			delete(fn.seen, e.id)
+		}
+		n++
+	}
+	fn.seenOrder = slices.Delete(fn.seenOrder, 0, n)
+}
+
+// eventTime returns the event time of a message, which is the value of the
+// timestamp attribute if set and valid, or the publish time otherwise.
+func (fn *readFn) eventTime(msg *pb.PubsubMessage) time.Time {
+	if fn.TimestampAttribute != "" {
+		if v, ok := msg.GetAttributes()[fn.TimestampAttribute]; ok {
+			if t, err := parseTimestamp(v); err == nil {
+				return t
+			}
+		}
+	}
+	return msg.GetPublishTime().AsTime()
+}
+
+// parseTimestamp parses a timestamp attribute, which is either milliseconds
+// since the epoch or an RFC 3339 time.
+func parseTimestamp(v string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q: want milliseconds since the epoch or an RFC 3339 time", v)
+	}
+	return t, nil
+}
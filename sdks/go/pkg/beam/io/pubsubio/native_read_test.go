@@ -0,0 +1,361 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubio
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	pb "google.golang.org/genproto/googleapis/pubsub/v1"
+)
+
+const (
+	testProject = "project"
+	testTopic   = "topic"
+	testSub     = "sub"
+)
+
+// newFakeServer starts a fake Pub/Sub server with a topic and a subscription
+// to it, which clients connect to through PUBSUB_EMULATOR_HOST.
+func newFakeServer(t *testing.T) *pstest.Server {
+	t.Helper()
+	srv := pstest.NewServer()
+	t.Cleanup(func() { srv.Close() })
+	t.Setenv("PUBSUB_EMULATOR_HOST", srv.Addr)
+
+	ctx := context.Background()
+	client, err := pubsub.NewClient(ctx, testProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	topic, err := client.CreateTopic(ctx, testTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateSubscription(ctx, testSub, pubsub.SubscriptionConfig{Topic: topic}); err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+type fakeBundleFinalization struct {
+	callbacks []func() error
+}
+
+func (f *fakeBundleFinalization) RegisterCallback(_ time.Duration, callback func() error) {
+	f.callbacks = append(f.callbacks, callback)
+}
+
+func TestReadFn(t *testing.T) {
+	srv := newFakeServer(t)
+	topic := "projects/" + testProject + "/topics/" + testTopic
+	ids := []string{
+		srv.Publish(topic, []byte("a"), map[string]string{"id": "1", "ts": "1000"}),
+		srv.Publish(topic, []byte("b"), map[string]string{"id": "2", "ts": "1970-01-01T00:00:02Z"}),
+		srv.Publish(topic, []byte("a"), map[string]string{"id": "1", "ts": "1000"}),
+	}
+
+	fn := &readFn{Project: testProject, IDAttribute: "id", TimestampAttribute: "ts"}
+	if err := fn.Setup(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer fn.Teardown()
+
+	rt := fn.CreateTracker(fn.CreateInitialRestriction(testSub))
+	we := fn.CreateWatermarkEstimator(math.MinInt64)
+	bf := &fakeBundleFinalization{}
+
+	got := make(map[string]beam.EventTime)
+	pc, err := fn.ProcessElement(context.Background(), we, bf, rt, testSub, func(et beam.EventTime, msg *pb.PubsubMessage) {
+		if _, ok := got[string(msg.GetData())]; ok {
+			t.Errorf("ProcessElement() emitted duplicate message %v", msg)
+		}
+		got[string(msg.GetData())] = et
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pc.ShouldResume() {
+		t.Error("ProcessElement() stopped processing, want resumption")
+	}
+	want := map[string]beam.EventTime{"a": 1000, "b": 2000}
+	if len(got) != len(want) || got["a"] != want["a"] || got["b"] != want["b"] {
+		t.Errorf("ProcessElement() emitted %v, want %v", got, want)
+	}
+	if got := we.CurrentWatermark(); got.Before(time.Now().Add(-time.Minute)) {
+		t.Errorf("watermark = %v, want it advanced to about now when idle", got)
+	}
+
+	if len(bf.callbacks) != 1 {
+		t.Fatalf("ProcessElement() registered %v finalization callbacks, want 1", len(bf.callbacks))
+	}
+	for _, id := range ids {
+		if acks := srv.Message(id).Acks; acks != 0 {
+			t.Errorf("message %v acked %v times before finalization, want 0", id, acks)
+		}
+	}
+	if err := bf.callbacks[0](); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if acks := srv.Message(id).Acks; acks != 1 {
+			t.Errorf("message %v acked %v times after finalization, want 1", id, acks)
+		}
+	}
+}
+
+// processMessages runs a bundle of the read from the test subscription, and
+// returns the data of the emitted messages and the bundle finalization.
+func processMessages(t *testing.T, fn *readFn) ([]string, *fakeBundleFinalization) {
+	t.Helper()
+	rt := fn.CreateTracker(fn.CreateInitialRestriction(testSub))
+	bf := &fakeBundleFinalization{}
+	var got []string
+	_, err := fn.ProcessElement(context.Background(), fn.CreateWatermarkEstimator(math.MinInt64), bf, rt, testSub, func(_ beam.EventTime, msg *pb.PubsubMessage) {
+		got = append(got, string(msg.GetData()))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got, bf
+}
+
+// expireLeases makes the fake server redeliver unacknowledged messages, as if
+// their ack deadlines passed.
+func expireLeases(srv *pstest.Server) {
+	srv.SetTimeNowFunc(func() time.Time { return time.Now().Add(2 * ackDeadline) })
+}
+
+func TestReadFn_FailedBundleRedelivered(t *testing.T) {
+	srv := newFakeServer(t)
+	topic := "projects/" + testProject + "/topics/" + testTopic
+	id := srv.Publish(topic, []byte("a"), nil)
+
+	fn := &readFn{Project: testProject}
+	if err := fn.Setup(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer fn.Teardown()
+
+	// The first bundle fails, so it's never finalized.
+	if got, _ := processMessages(t, fn); len(got) != 1 {
+		t.Fatalf("first bundle emitted %v, want [a]", got)
+	}
+
+	expireLeases(srv)
+	got, bf := processMessages(t, fn)
+	if len(got) != 1 || got[0] != "a" {
+		t.Fatalf("retried bundle emitted %v, want the redelivered message [a]", got)
+	}
+	for _, callback := range bf.callbacks {
+		if err := callback(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if acks := srv.Message(id).Acks; acks != 1 {
+		t.Errorf("message acked %v times after finalization, want 1", acks)
+	}
+}
+
+func TestReadFn_FinalizedBundleDeduplicated(t *testing.T) {
+	srv := newFakeServer(t)
+	topic := "projects/" + testProject + "/topics/" + testTopic
+	srv.Publish(topic, []byte("a"), map[string]string{"id": "1"})
+
+	fn := &readFn{Project: testProject, IDAttribute: "id"}
+	if err := fn.Setup(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer fn.Teardown()
+
+	got, bf := processMessages(t, fn)
+	if len(got) != 1 {
+		t.Fatalf("first bundle emitted %v, want [a]", got)
+	}
+	for _, callback := range bf.callbacks {
+		if err := callback(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A publisher retry of the message of the finalized bundle is dropped, but
+	// still acknowledged.
+	id := srv.Publish(topic, []byte("a"), map[string]string{"id": "1"})
+	got, bf = processMessages(t, fn)
+	if len(got) != 0 {
+		t.Errorf("bundle after finalization emitted %v, want no duplicates", got)
+	}
+	for _, callback := range bf.callbacks {
+		if err := callback(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if acks := srv.Message(id).Acks; acks != 1 {
+		t.Errorf("duplicate message acked %v times after finalization, want 1", acks)
+	}
+}
+
+func TestReadFn_Checkpointed(t *testing.T) {
+	newFakeServer(t)
+
+	fn := &readFn{Project: testProject}
+	if err := fn.Setup(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer fn.Teardown()
+
+	rt := fn.CreateTracker(fn.TruncateRestriction(nil, testSub))
+	bf := &fakeBundleFinalization{}
+	pc, err := fn.ProcessElement(context.Background(), &watermarkEstimator{}, bf, rt, testSub, func(beam.EventTime, *pb.PubsubMessage) {
+		t.Error("ProcessElement() emitted a message of a truncated restriction")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pc.ShouldResume() {
+		t.Error("ProcessElement() resumed a truncated restriction, want stop")
+	}
+	if !rt.IsDone() {
+		t.Error("tracker of truncated restriction isn't done")
+	}
+	if len(bf.callbacks) != 0 {
+		t.Errorf("ProcessElement() registered %v finalization callbacks, want 0", len(bf.callbacks))
+	}
+}
+
+func TestReadFn_ForgetSeen(t *testing.T) {
+	fn := &readFn{seen: make(map[string]time.Time)}
+	fn.markSeen([]string{"a", "b"})
+	fn.markSeen([]string{"c"})
+	expired := time.Now().Add(-dedupWindow - time.Minute)
+	fn.seenOrder[0].t, fn.seenOrder[1].t = expired, expired
+	fn.seen["a"], fn.seen["b"] = expired, expired
+	// "b" was seen again within the window.
+	fn.markSeen([]string{"b"})
+
+	fn.forgetSeen()
+	for id, want := range map[string]bool{"a": false, "b": true, "c": true} {
+		if got := fn.isSeen(id); got != want {
+			t.Errorf("isSeen(%q) = %v after forgetSeen(), want %v", id, got, want)
+		}
+	}
+	if got := len(fn.seenOrder); got != 2 {
+		t.Errorf("forgetSeen() kept %v IDs in order, want 2", got)
+	}
+}
+
+func TestCreateSubscriptionFn(t *testing.T) {
+	newFakeServer(t)
+	ctx := context.Background()
+
+	fn := &createSubscriptionFn{Project: testProject, Topic: testTopic, Subscription: "beam-test"}
+	// A retried bundle finds the subscription it created.
+	for i := 0; i < 2; i++ {
+		var got []string
+		if err := fn.ProcessElement(ctx, nil, func(id string) { got = append(got, id) }); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0] != "beam-test" {
+			t.Errorf("ProcessElement() emitted %v, want [beam-test]", got)
+		}
+	}
+
+	client, err := pubsub.NewClient(ctx, testProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	cfg, err := client.Subscription("beam-test").Config(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ExpirationPolicy != subExpiration {
+		t.Errorf("subscription expiration policy = %v, want %v", cfg.ExpirationPolicy, subExpiration)
+	}
+}
+
+func TestSubscriptionTracker(t *testing.T) {
+	rt := newSubscriptionTracker(testSub)
+	if !rt.TryClaim(testSub) {
+		t.Fatal("TryClaim() = false, want true")
+	}
+
+	primary, residual, err := rt.TrySplit(0.5)
+	if err != nil || primary != testSub || residual != nil {
+		t.Errorf("TrySplit(0.5) = (%v, %v, %v), want (%v, nil, nil)", primary, residual, err, testSub)
+	}
+	primary, residual, err = rt.TrySplit(0)
+	if err != nil || primary != "" || residual != testSub {
+		t.Errorf("TrySplit(0) = (%v, %v, %v), want (\"\", %v, nil)", primary, residual, err, testSub)
+	}
+	if !rt.IsDone() {
+		t.Error("IsDone() = false after checkpoint, want true")
+	}
+	if rt.TryClaim(testSub) {
+		t.Error("TryClaim() = true after checkpoint, want false")
+	}
+
+	rt = newSubscriptionTracker(testSub)
+	if rt.TryClaim(1) || rt.GetError() == nil {
+		t.Error("TryClaim(1) succeeded, want error")
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		v    string
+		want time.Time
+	}{
+		{"1700000000123", time.UnixMilli(1700000000123)},
+		{"2023-11-14T22:13:20.123Z", time.UnixMilli(1700000000123)},
+	}
+	for _, test := range tests {
+		got, err := parseTimestamp(test.v)
+		if err != nil {
+			t.Errorf("parseTimestamp(%q) failed: %v", test.v, err)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("parseTimestamp(%q) = %v, want %v", test.v, got, test.want)
+		}
+	}
+	if _, err := parseTimestamp("yesterday"); err == nil {
+		t.Error("parseTimestamp(\"yesterday\") succeeded, want error")
+	}
+}
+
+func TestNativeRead_InvalidOptionsPanics(t *testing.T) {
+	for _, opts := range []ReadOptions{{}, {Topic: testTopic, Subscription: testSub}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NativeRead(%+v) succeeded, want panic", opts)
+				}
+			}()
+			_, s := beam.NewPipelineWithRoot()
+			NativeRead(s, testProject, opts)
+		}()
+	}
+}
+
+var _ sdf.RTracker = (*subscriptionTracker)(nil)
@@ -0,0 +1,194 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubio
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/google/uuid"
+	pb "google.golang.org/genproto/googleapis/pubsub/v1"
+)
+
+func init() {
+	register.DoFn3x1[context.Context, beam.EventTime, *pb.PubsubMessage, error](&writeFn{})
+	register.DoFn2x0[*pb.PubsubMessage, func(*pb.PubsubMessage)](&assignIDFn{})
+}
+
+// WriteOptions represents options for writing to Pub/Sub with NativeWrite.
+type WriteOptions struct {
+	IDAttribute        string        // IDAttribute sets the attribute that holds a unique ID of each message, to deduplicate messages that are published more than once.
+	TimestampAttribute string        // TimestampAttribute sets the attribute that holds the event time of each message, in milliseconds since the epoch.
+	MaxBatchSize       int           // MaxBatchSize sets the maximum number of messages that are published in a batch. Defaults to 100.
+	MaxBatchBytes      int           // MaxBatchBytes sets the maximum size in bytes of a batch of messages. Defaults to 1 MB.
+	MaxBatchDelay      time.Duration // MaxBatchDelay sets the maximum time that a message waits for its batch to be published. Defaults to 10 ms.
+}
+
+// NativeWrite publishes PubsubMessages or []bytes to the given Pub/Sub topic with the Pub/Sub client
+// library. Panics if the input PCollection type is not one of those two types.
+//
+// Messages are published in batches, and each bundle waits for its messages to be published before
+// it completes. A message is only published more than once when a bundle is retried after a
+// failure. Messages with ordering keys are published in order of their keys, and a failure to
+// publish a message of a key fails the bundle, after which the key is published again when
+// the bundle is retried. If an IDAttribute is set, each message is assigned a unique ID before a Reshuffle, so
+// that messages that are published again keep their IDs and can be deduplicated by readers.
+func NativeWrite(s beam.Scope, project, topic string, col beam.PCollection, opts WriteOptions) {
+	s = s.Scope("pubsubio.NativeWrite")
+
+	if opts.MaxBatchSize < 0 || opts.MaxBatchBytes < 0 || opts.MaxBatchDelay < 0 {
+		panic(fmt.Sprintf("pubsubio.NativeWrite: batch limits must not be negative, got %+v", opts))
+	}
+
+	out := col
+	switch col.Type().Type() {
+	case pubSubMessageT:
+	case reflectx.ByteSlice:
+		out = beam.ParDo(s, wrapInMessage, col)
+	default:
+		panic(fmt.Sprintf("pubsubio.NativeWrite only accepts PCollections of %v and %v, received %v", pubSubMessageT, reflectx.ByteSlice, col.Type().Type()))
+	}
+	if opts.IDAttribute != "" {
+		out = beam.Reshuffle(s, beam.ParDo(s, &assignIDFn{IDAttribute: opts.IDAttribute}, out))
+	}
+	beam.ParDo0(s, &writeFn{
+		Project:            project,
+		Topic:              topic,
+		TimestampAttribute: opts.TimestampAttribute,
+		MaxBatchSize:       opts.MaxBatchSize,
+		MaxBatchBytes:      opts.MaxBatchBytes,
+		MaxBatchDelay:      opts.MaxBatchDelay,
+	}, out)
+}
+
+// assignIDFn sets the ID attribute of messages that don't have one to a
+// random unique ID.
+type assignIDFn struct {
+	IDAttribute string
+}
+
+func (fn *assignIDFn) ProcessElement(msg *pb.PubsubMessage, emit func(*pb.PubsubMessage)) {
+	if _, ok := msg.GetAttributes()[fn.IDAttribute]; ok {
+		emit(msg)
+		return
+	}
+	attrs := make(map[string]string, len(msg.GetAttributes())+1)
+	for k, v := range msg.GetAttributes() {
+		attrs[k] = v
+	}
+	attrs[fn.IDAttribute] = uuid.NewString()
+	emit(&pb.PubsubMessage{Data: msg.GetData(), Attributes: attrs, OrderingKey: msg.GetOrderingKey()})
+}
+
+type writeFn struct {
+	Project            string
+	Topic              string
+	TimestampAttribute string
+	MaxBatchSize       int
+	MaxBatchBytes      int
+	MaxBatchDelay      time.Duration
+	client             *pubsub.Client
+	topic              *pubsub.Topic
+	results            []publishResult
+}
+
+// publishResult is the result of publishing a message with an ordering key.
+type publishResult struct {
+	result      *pubsub.PublishResult
+	orderingKey string
+}
+
+func (fn *writeFn) Setup(ctx context.Context) error {
+	if fn.client != nil {
+		return nil
+	}
+	client, err := pubsub.NewClient(ctx, fn.Project)
+	if err != nil {
+		return fmt.Errorf("error creating Pub/Sub client: %v", err)
+	}
+	fn.client = client
+	fn.topic = client.Topic(fn.Topic)
+	if fn.MaxBatchSize > 0 {
+		fn.topic.PublishSettings.CountThreshold = fn.MaxBatchSize
+	}
+	if fn.MaxBatchBytes > 0 {
+		fn.topic.PublishSettings.ByteThreshold = fn.MaxBatchBytes
+	}
+	if fn.MaxBatchDelay > 0 {
+		fn.topic.PublishSettings.DelayThreshold = fn.MaxBatchDelay
+	}
+	return nil
+}
+
+func (fn *writeFn) ProcessElement(ctx context.Context, et beam.EventTime, msg *pb.PubsubMessage) error {
+	m := &pubsub.Message{
+		Data:        msg.GetData(),
+		Attributes:  msg.GetAttributes(),
+		OrderingKey: msg.GetOrderingKey(),
+	}
+	if fn.TimestampAttribute != "" {
+		attrs := make(map[string]string, len(m.Attributes)+1)
+		for k, v := range m.Attributes {
+			attrs[k] = v
+		}
+		attrs[fn.TimestampAttribute] = strconv.FormatInt(et.Milliseconds(), 10)
+		m.Attributes = attrs
+	}
+	if m.OrderingKey != "" {
+		// Messages without ordering keys are published concurrently unless
+		// ordering is enabled.
+		fn.topic.EnableMessageOrdering = true
+	}
+	fn.results = append(fn.results, publishResult{result: fn.topic.Publish(ctx, m), orderingKey: m.OrderingKey})
+	return nil
+}
+
+func (fn *writeFn) FinishBundle(ctx context.Context) error {
+	fn.topic.Flush()
+	results := fn.results
+	fn.results = nil
+	var err error
+	for _, r := range results {
+		if _, rerr := r.result.Get(ctx); rerr != nil {
+			if r.orderingKey != "" {
+				// The client pauses publishing of the key after a failure, so
+				// that its later messages aren't published out of order.
+				// Resuming it lets the retry of the bundle publish them.
+				fn.topic.ResumePublish(r.orderingKey)
+			}
+			if err == nil {
+				err = fmt.Errorf("error publishing message to topic %v: %v", fn.Topic, rerr)
+			}
+		}
+	}
+	return err
+}
+
+func (fn *writeFn) Teardown() error {
+	if fn.client == nil {
+		return nil
+	}
+	fn.topic.Stop()
+	err := fn.client.Close()
+	fn.client = nil
+	return err
+}
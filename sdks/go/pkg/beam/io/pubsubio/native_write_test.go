@@ -0,0 +1,123 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubio
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+	pb "google.golang.org/genproto/googleapis/pubsub/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMain(m *testing.M) {
+	ptest.Main(m)
+}
+
+func TestNativeWrite(t *testing.T) {
+	srv := newFakeServer(t)
+
+	p, s := beam.NewPipelineWithRoot()
+	NativeWrite(s, testProject, testTopic, beam.Create(s, []byte("a"), []byte("b")), WriteOptions{
+		IDAttribute:        "id",
+		TimestampAttribute: "ts",
+		MaxBatchSize:       1,
+		MaxBatchDelay:      time.Millisecond,
+	})
+	ptest.RunAndValidate(t, p)
+
+	msgs := srv.Messages()
+	var data []string
+	ids := make(map[string]bool)
+	for _, msg := range msgs {
+		data = append(data, string(msg.Data))
+		if msg.Attributes["id"] == "" {
+			t.Errorf("message %v has no ID attribute", msg.ID)
+		}
+		ids[msg.Attributes["id"]] = true
+		if msg.Attributes["ts"] == "" {
+			t.Errorf("message %v has no timestamp attribute", msg.ID)
+		}
+	}
+	sort.Strings(data)
+	if len(data) != 2 || data[0] != "a" || data[1] != "b" {
+		t.Errorf("NativeWrite() published %v, want [a b]", data)
+	}
+	if len(ids) != len(msgs) {
+		t.Errorf("NativeWrite() published %v messages with %v unique IDs, want unique IDs", len(msgs), len(ids))
+	}
+}
+
+func TestNativeWrite_Messages(t *testing.T) {
+	srv := newFakeServer(t)
+
+	p, s := beam.NewPipelineWithRoot()
+	msgs := beam.Create(s, &pb.PubsubMessage{Data: []byte("a"), Attributes: map[string]string{"k": "v"}})
+	NativeWrite(s, testProject, testTopic, msgs, WriteOptions{IDAttribute: "k"})
+	ptest.RunAndValidate(t, p)
+
+	got := srv.Messages()
+	if len(got) != 1 || string(got[0].Data) != "a" || got[0].Attributes["k"] != "v" || len(got[0].Attributes) != 1 {
+		t.Errorf("NativeWrite() published %+v, want a single message a with attribute k=v", got)
+	}
+}
+
+func TestWriteFn_ResumesOrderingKeyAfterFailure(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+
+	fn := &writeFn{Project: testProject, Topic: testTopic}
+	if err := fn.Setup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer fn.Teardown()
+
+	srv.SetAutoPublishResponse(false)
+	srv.AddPublishResponse(nil, status.Error(codes.InvalidArgument, "invalid"))
+	if err := fn.ProcessElement(ctx, 0, &pb.PubsubMessage{Data: []byte("a"), OrderingKey: "k"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fn.FinishBundle(ctx); err == nil {
+		t.Fatal("FinishBundle() succeeded, want publish error")
+	}
+
+	// The retry of the bundle publishes the message of the key.
+	srv.SetAutoPublishResponse(true)
+	if err := fn.ProcessElement(ctx, 0, &pb.PubsubMessage{Data: []byte("a"), OrderingKey: "k"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fn.FinishBundle(ctx); err != nil {
+		t.Fatalf("FinishBundle() of the retry failed: %v", err)
+	}
+	if got := srv.Messages(); len(got) != 1 || got[0].OrderingKey != "k" {
+		t.Errorf("published %+v, want a single message with ordering key k", got)
+	}
+}
+
+func TestNativeWrite_InvalidTypePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NativeWrite() succeeded, want panic")
+		}
+	}()
+	_, s := beam.NewPipelineWithRoot()
+	NativeWrite(s, testProject, testTopic, beam.Create(s, "a"), WriteOptions{})
+}
@@ -13,12 +13,18 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package pubsubio provides access to Pub/Sub on Dataflow streaming.
+// Package pubsubio provides access to Pub/Sub in streaming pipelines.
 //
-// This implementation only functions on the Dataflow runner.
-//
-// See https://cloud.google.com/dataflow/docs/concepts/streaming-with-cloud-pubsub
+// Read and Write only function on the Dataflow runner, which replaces them
+// with its own Pub/Sub implementation. See
+// https://cloud.google.com/dataflow/docs/concepts/streaming-with-cloud-pubsub
 // for details on using Pub/Sub with Dataflow.
+//
+// NativeRead and NativeWrite are implemented in Go with the Pub/Sub client
+// library, and function on any runner that supports splittable DoFns and
+// bundle finalization, such as Prism and Flink. Like the client library, they
+// connect to the Pub/Sub emulator instead when the PUBSUB_EMULATOR_HOST
+// environment variable is set.
 package pubsubio
 
 import (
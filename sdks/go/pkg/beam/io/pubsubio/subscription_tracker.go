@@ -0,0 +1,83 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubio
+
+import "fmt"
+
+// subscriptionTracker tracks the reading of a subscription, which is an
+// unbounded restriction that can't be split other than to checkpoint.
+type subscriptionTracker struct {
+	subscription string
+	done         bool
+	err          error
+}
+
+func newSubscriptionTracker(subscription string) *subscriptionTracker {
+	return &subscriptionTracker{subscription: subscription}
+}
+
+// TryClaim returns true if the position is the subscription of the tracker,
+// and the tracker hasn't been checkpointed.
+func (t *subscriptionTracker) TryClaim(pos any) bool {
+	if t.done {
+		return false
+	}
+	subscription, ok := pos.(string)
+	if !ok {
+		t.err = fmt.Errorf("invalid position type %T, want string", pos)
+		t.done = true
+		return false
+	}
+	if subscription != t.subscription {
+		t.done = true
+		return false
+	}
+	return true
+}
+
+// TrySplit moves the subscription to the residual when checkpointing, and
+// doesn't split the restriction otherwise.
+func (t *subscriptionTracker) TrySplit(fraction float64) (primary, residual any, err error) {
+	if fraction != 0 || t.done {
+		return t.subscription, nil, nil
+	}
+	residual = t.subscription
+	t.subscription = ""
+	t.done = true
+	return "", residual, nil
+}
+
+func (t *subscriptionTracker) GetError() error {
+	return t.err
+}
+
+// GetProgress reports the restriction as done, as the backlog of the
+// subscription is unknown and the restriction can't be split.
+func (t *subscriptionTracker) GetProgress() (done, remaining float64) {
+	return 1, 0
+}
+
+func (t *subscriptionTracker) IsDone() bool {
+	return t.done
+}
+
+func (t *subscriptionTracker) IsBounded() bool {
+	return false
+}
+
+func (t *subscriptionTracker) GetRestriction() any {
+	return t.subscription
+}
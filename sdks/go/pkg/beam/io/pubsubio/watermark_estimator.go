@@ -0,0 +1,38 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsubio
+
+import (
+	"time"
+)
+
+// watermarkEstimator is a manual watermark estimator, which is advanced to
+// the earliest event time of each batch of pulled messages, or to the current
+// time less an assumed lag when the subscription is idle. The watermark never
+// moves backwards.
+type watermarkEstimator struct {
+	state int64
+}
+
+func (e *watermarkEstimator) CurrentWatermark() time.Time {
+	return time.UnixMilli(e.state)
+}
+
+func (e *watermarkEstimator) advance(t time.Time) {
+	if ms := t.UnixMilli(); ms > e.state {
+		e.state = ms
+	}
+}
@@ -0,0 +1,486 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerio
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	// maxReadTime is how long the partitions of a restriction are read before it is checkpointed.
+	maxReadTime = 10 * time.Second
+)
+
+func init() {
+	register.DoFn5x2[
+		context.Context, *watermarkEstimator, *sdf.LockRTracker, []byte, func(beam.EventTime, DataChangeRecord),
+		sdf.ProcessContinuation, error,
+	]((*readChangeStreamFn)(nil))
+	register.Emitter2[beam.EventTime, DataChangeRecord]()
+
+	beam.RegisterType(reflect.TypeOf((*DataChangeRecord)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*ColumnType)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*Mod)(nil)).Elem())
+}
+
+// DataChangeRecord is a change to the rows of a table, read from a change stream.
+type DataChangeRecord struct {
+	PartitionToken                       string       `json:"partitionToken"`                       // The token of the partition the record was read from.
+	CommitTimestamp                      time.Time    `json:"commitTimestamp"`                      // The commit timestamp of the transaction of the change.
+	RecordSequence                       string       `json:"recordSequence"`                       // The order of the record within the transaction.
+	ServerTransactionID                  string       `json:"serverTransactionId"`                  // The unique ID of the transaction.
+	IsLastRecordInTransactionInPartition bool         `json:"isLastRecordInTransactionInPartition"` // Whether the record is the last of the transaction in the partition.
+	TableName                            string       `json:"tableName"`                            // The table of the changed rows.
+	ColumnTypes                          []ColumnType `json:"columnTypes"`                          // The columns of the changed rows.
+	Mods                                 []Mod        `json:"mods"`                                 // The changed rows.
+	ModType                              string       `json:"modType"`                              // INSERT, UPDATE or DELETE.
+	ValueCaptureType                     string       `json:"valueCaptureType"`                     // The value capture type of the change stream.
+	NumberOfRecordsInTransaction         int64        `json:"numberOfRecordsInTransaction"`         // The number of records of the transaction across all partitions.
+	NumberOfPartitionsInTransaction      int64        `json:"numberOfPartitionsInTransaction"`      // The number of partitions with records of the transaction.
+	TransactionTag                       string       `json:"transactionTag"`                       // The tag of the transaction, if any.
+	IsSystemTransaction                  bool         `json:"isSystemTransaction"`                  // Whether the transaction was a system transaction.
+}
+
+// ColumnType describes a column of the rows changed by a DataChangeRecord.
+type ColumnType struct {
+	Name            string `json:"name"`            // The name of the column.
+	Type            string `json:"type"`            // The type of the column as JSON, e.g. {"code":"INT64"}.
+	IsPrimaryKey    bool   `json:"isPrimaryKey"`    // Whether the column is part of the primary key.
+	OrdinalPosition int64  `json:"ordinalPosition"` // The position of the column in the table.
+}
+
+// Mod is a row changed by a DataChangeRecord. The values are JSON objects keyed by column name.
+type Mod struct {
+	Keys      string `json:"keys"`      // The primary key of the row.
+	NewValues string `json:"newValues"` // The values of the row after the change, depending on the value capture type.
+	OldValues string `json:"oldValues"` // The values of the row before the change, depending on the value capture type.
+}
+
+// ReadChangeStream reads the data change records of the given change stream. It returns an unbounded
+// PCollection<DataChangeRecord>, or a bounded one if an end timestamp is set with WithEndTimestamp.
+//
+// The change stream is read from the start timestamp set with WithStartTimestamp, or from the time the pipeline starts.
+// Its partitions are tracked in the restrictions of the read, which split off the partitions a restriction holds,
+// including the child partitions of partitions that end, so that they can be read in parallel. A child partition with
+// several parents is read once, as part of the restriction of the parent with the lowest token, starting with the next
+// read of that restriction after the parent ended.
+//
+// The event time of each record is its commit timestamp. The watermark advances to the earliest timestamp that all
+// partitions of a restriction have been read to, which idle partitions advance with their heartbeat records.
+//
+// Records are not emitted in commit timestamp order across partitions. The partitions of a restriction are all read to
+// the same timestamp before their children are read, but the parents of a merged child that were split off into other
+// restrictions may still be read after the child. Their records precede those of the child in event time though, and
+// the watermark does not pass the start of the child until all its parents have been read, so records of the same key
+// can be ordered by event time downstream, such as with event time windows or timers.
+func ReadChangeStream(s beam.Scope, db string, changeStream string, options ...ChangeStreamOptionFn) beam.PCollection {
+	if db == "" {
+		panic("no database provided!")
+	}
+
+	if changeStream == "" {
+		panic("no change stream provided!")
+	}
+
+	s = s.Scope("spannerio.ReadChangeStream")
+
+	imp := beam.Impulse(s)
+
+	return beam.ParDo(s, newReadChangeStreamFn(db, changeStream, newChangeStreamOptions(options...)), imp)
+}
+
+// rowIterator is the part of a *spanner.RowIterator that is used to read a change stream.
+type rowIterator interface {
+	Next() (*spanner.Row, error)
+	Stop()
+}
+
+type readChangeStreamFn struct {
+	spannerFn
+	ChangeStream string              `json:"changeStream"` // The name of the change stream.
+	Options      changeStreamOptions `json:"options"`      // Options specifies additional change stream options.
+	query        func(ctx context.Context, stmt spanner.Statement) rowIterator
+}
+
+func newReadChangeStreamFn(db string, changeStream string, options changeStreamOptions) *readChangeStreamFn {
+	return &readChangeStreamFn{spannerFn: newSpannerFn(db), ChangeStream: changeStream, Options: options}
+}
+
+func (f *readChangeStreamFn) Setup(ctx context.Context) error {
+	if f.query != nil {
+		return nil
+	}
+
+	if err := f.spannerFn.Setup(ctx); err != nil {
+		return err
+	}
+
+	f.query = func(ctx context.Context, stmt spanner.Statement) rowIterator {
+		return f.client.Single().Query(ctx, stmt)
+	}
+
+	return nil
+}
+
+func (f *readChangeStreamFn) Teardown() {
+	f.spannerFn.Teardown()
+}
+
+// CreateInitialRestriction creates a restriction holding the root partition of the change stream, which reports its
+// initial partitions.
+func (f *readChangeStreamFn) CreateInitialRestriction(_ []byte) changeStreamRestriction {
+	start := f.Options.StartTimestamp
+	if start.IsZero() {
+		start = time.Now()
+	}
+
+	return changeStreamRestriction{
+		Partitions: []changeStreamPartition{{Start: start, End: f.Options.EndTimestamp}},
+	}
+}
+
+func (f *readChangeStreamFn) SplitRestriction(_ []byte, rest changeStreamRestriction) []changeStreamRestriction {
+	return []changeStreamRestriction{rest}
+}
+
+// RestrictionSize returns the size of each restriction as its number of partitions.
+func (f *readChangeStreamFn) RestrictionSize(_ []byte, rest changeStreamRestriction) float64 {
+	return float64(len(rest.Partitions))
+}
+
+func (f *readChangeStreamFn) CreateTracker(rest changeStreamRestriction) *sdf.LockRTracker {
+	return sdf.NewLockRTracker(newChangeStreamTracker(rest))
+}
+
+func (f *readChangeStreamFn) InitialWatermarkEstimatorState(_ beam.EventTime, rest changeStreamRestriction, _ []byte) int64 {
+	if start, ok := earliestStart(rest); ok {
+		return start.Add(-time.Millisecond).UnixMilli()
+	}
+	return math.MinInt64
+}
+
+func (f *readChangeStreamFn) CreateWatermarkEstimator(state int64) *watermarkEstimator {
+	return &watermarkEstimator{state: state}
+}
+
+func (f *readChangeStreamFn) WatermarkEstimatorState(we *watermarkEstimator) int64 {
+	return we.state
+}
+
+// ProcessElement reads the partitions of the restriction concurrently for a while, and checkpoints the restriction
+// unless all of its partitions have been read to their end.
+func (f *readChangeStreamFn) ProcessElement(
+	ctx context.Context,
+	we *watermarkEstimator,
+	rt *sdf.LockRTracker,
+	_ []byte,
+	emit func(beam.EventTime, DataChangeRecord),
+) (sdf.ProcessContinuation, error) {
+	rest := rt.GetRestriction().(changeStreamRestriction)
+	readEnd := time.Now().Add(maxReadTime)
+
+	var mu sync.Mutex
+	emitRecord := func(record DataChangeRecord) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		emit(beam.EventTime(record.CommitTimestamp.UnixMilli()), record)
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	for _, p := range rest.Partitions {
+		p := p
+		g.Go(func() error {
+			return f.readPartition(gctx, rt, p, readEnd, emitRecord)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return sdf.StopProcessing(), err
+	}
+
+	rest = rt.GetRestriction().(changeStreamRestriction)
+	if start, ok := earliestStart(rest); ok {
+		// All records of the partitions before their start timestamps have been read.
+		we.advance(start.Add(-time.Millisecond))
+	}
+
+	if rt.IsDone() {
+		return sdf.StopProcessing(), nil
+	}
+
+	return sdf.ResumeProcessingIn(0), nil
+}
+
+// readPartition reads the records of a partition until the read end, claiming the timestamps it has been read to. It
+// stops early when a claim fails, which happens after the partition has been split off into a residual.
+//
+// The records of a commit timestamp, which may be the records of several transactions, are emitted together once a
+// later record, a heartbeat or the end of the query shows that there are no more of them, after claiming the timestamp
+// after theirs. A checkpoint therefore resumes the partition either before or after all of them, and never emits the
+// records of a transaction twice.
+func (f *readChangeStreamFn) readPartition(
+	ctx context.Context,
+	rt *sdf.LockRTracker,
+	p changeStreamPartition,
+	readEnd time.Time,
+	emit func(DataChangeRecord),
+) error {
+	end := readEnd
+	bounded := !p.End.IsZero() && !p.End.After(readEnd)
+	if bounded {
+		end = p.End
+	}
+
+	if p.Start.After(end) {
+		if bounded {
+			rt.TryClaim(partitionDone{Token: p.Token})
+		}
+		return nil
+	}
+
+	it := f.query(ctx, f.changeStreamQuery(p, end))
+	defer it.Stop()
+
+	var children []changeStreamPartition
+	ended := false
+
+	// pending are the records of the latest commit timestamp, which are emitted by flush.
+	var pending []DataChangeRecord
+	flush := func() bool {
+		if len(pending) == 0 {
+			return true
+		}
+		if !rt.TryClaim(partitionProgress{Token: p.Token, Start: pending[0].CommitTimestamp.Add(time.Microsecond)}) {
+			return false
+		}
+		for _, record := range pending {
+			emit(record)
+		}
+		pending = nil
+		return true
+	}
+
+	for {
+		row, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read partition %q of change stream %v: %v", p.Token, f.ChangeStream, err)
+		}
+
+		var result changeStreamResult
+		if err := row.ToStructLenient(&result); err != nil {
+			return fmt.Errorf("failed to decode change stream record: %v", err)
+		}
+
+		for _, record := range result.ChangeRecord {
+			for _, d := range record.DataChangeRecord {
+				if len(pending) > 0 && d.CommitTimestamp.After(pending[0].CommitTimestamp) && !flush() {
+					return nil
+				}
+				pending = append(pending, d.toRecord(p.Token))
+			}
+
+			for _, h := range record.HeartbeatRecord {
+				if !flush() || !rt.TryClaim(partitionProgress{Token: p.Token, Start: h.Timestamp.Add(time.Microsecond)}) {
+					return nil
+				}
+			}
+
+			for _, c := range record.ChildPartitionsRecord {
+				ended = true
+
+				for _, child := range c.ChildPartitions {
+					if adoptsChild(p.Token, child.ParentPartitionTokens) {
+						children = append(children, changeStreamPartition{Token: child.Token, Start: c.StartTimestamp, End: p.End})
+					}
+				}
+			}
+		}
+	}
+
+	if !flush() {
+		return nil
+	}
+
+	if ended || bounded {
+		rt.TryClaim(partitionDone{Token: p.Token, Children: children})
+		return nil
+	}
+
+	rt.TryClaim(partitionProgress{Token: p.Token, Start: end.Add(time.Microsecond)})
+	return nil
+}
+
+// changeStreamQuery returns the query that reads a partition of the change stream until the given end timestamp.
+func (f *readChangeStreamFn) changeStreamQuery(p changeStreamPartition, end time.Time) spanner.Statement {
+	token := spanner.NullString{StringVal: p.Token, Valid: p.Token != ""}
+
+	return spanner.Statement{
+		SQL: fmt.Sprintf(`SELECT ChangeRecord FROM READ_%v(
+			start_timestamp => @startTimestamp,
+			end_timestamp => @endTimestamp,
+			partition_token => @partitionToken,
+			heartbeat_milliseconds => @heartbeatMilliseconds
+		)`, f.ChangeStream),
+		Params: map[string]any{
+			"startTimestamp":        p.Start,
+			"endTimestamp":          end,
+			"partitionToken":        token,
+			"heartbeatMilliseconds": f.Options.HeartbeatInterval.Milliseconds(),
+		},
+	}
+}
+
+// adoptsChild returns whether the partition with the given token reads a child partition with the given parents,
+// which is the case for the parent with the lowest token, so that a child of merged partitions is only read once. The
+// child is not held back until its other parents end, as they may be read by other restrictions.
+func adoptsChild(token string, parents []string) bool {
+	for _, parent := range parents {
+		if parent < token {
+			return false
+		}
+	}
+	return true
+}
+
+// earliestStart returns the earliest start timestamp of the partitions of a restriction.
+func earliestStart(rest changeStreamRestriction) (time.Time, bool) {
+	if len(rest.Partitions) == 0 {
+		return time.Time{}, false
+	}
+
+	start := rest.Partitions[0].Start
+	for _, p := range rest.Partitions[1:] {
+		if p.Start.Before(start) {
+			start = p.Start
+		}
+	}
+	return start, true
+}
+
+// changeStreamResult is a row returned by a change stream query.
+type changeStreamResult struct {
+	ChangeRecord []*changeRecordRow `spanner:"ChangeRecord"`
+}
+
+type changeRecordRow struct {
+	DataChangeRecord      []*dataChangeRecordRow      `spanner:"data_change_record"`
+	HeartbeatRecord       []*heartbeatRecordRow       `spanner:"heartbeat_record"`
+	ChildPartitionsRecord []*childPartitionsRecordRow `spanner:"child_partitions_record"`
+}
+
+type dataChangeRecordRow struct {
+	CommitTimestamp                      time.Time        `spanner:"commit_timestamp"`
+	RecordSequence                       string           `spanner:"record_sequence"`
+	ServerTransactionID                  string           `spanner:"server_transaction_id"`
+	IsLastRecordInTransactionInPartition bool             `spanner:"is_last_record_in_transaction_in_partition"`
+	TableName                            string           `spanner:"table_name"`
+	ColumnTypes                          []*columnTypeRow `spanner:"column_types"`
+	Mods                                 []*modRow        `spanner:"mods"`
+	ModType                              string           `spanner:"mod_type"`
+	ValueCaptureType                     string           `spanner:"value_capture_type"`
+	NumberOfRecordsInTransaction         int64            `spanner:"number_of_records_in_transaction"`
+	NumberOfPartitionsInTransaction      int64            `spanner:"number_of_partitions_in_transaction"`
+	TransactionTag                       string           `spanner:"transaction_tag"`
+	IsSystemTransaction                  bool             `spanner:"is_system_transaction"`
+}
+
+type columnTypeRow struct {
+	Name            string           `spanner:"name"`
+	Type            spanner.NullJSON `spanner:"type"`
+	IsPrimaryKey    bool             `spanner:"is_primary_key"`
+	OrdinalPosition int64            `spanner:"ordinal_position"`
+}
+
+type modRow struct {
+	Keys      spanner.NullJSON `spanner:"keys"`
+	NewValues spanner.NullJSON `spanner:"new_values"`
+	OldValues spanner.NullJSON `spanner:"old_values"`
+}
+
+type heartbeatRecordRow struct {
+	Timestamp time.Time `spanner:"timestamp"`
+}
+
+type childPartitionsRecordRow struct {
+	StartTimestamp  time.Time            `spanner:"start_timestamp"`
+	RecordSequence  string               `spanner:"record_sequence"`
+	ChildPartitions []*childPartitionRow `spanner:"child_partitions"`
+}
+
+type childPartitionRow struct {
+	Token                 string   `spanner:"token"`
+	ParentPartitionTokens []string `spanner:"parent_partition_tokens"`
+}
+
+func (d *dataChangeRecordRow) toRecord(token string) DataChangeRecord {
+	columnTypes := make([]ColumnType, len(d.ColumnTypes))
+	for i, c := range d.ColumnTypes {
+		columnTypes[i] = ColumnType{
+			Name:            c.Name,
+			Type:            jsonString(c.Type),
+			IsPrimaryKey:    c.IsPrimaryKey,
+			OrdinalPosition: c.OrdinalPosition,
+		}
+	}
+
+	mods := make([]Mod, len(d.Mods))
+	for i, m := range d.Mods {
+		mods[i] = Mod{
+			Keys:      jsonString(m.Keys),
+			NewValues: jsonString(m.NewValues),
+			OldValues: jsonString(m.OldValues),
+		}
+	}
+
+	return DataChangeRecord{
+		PartitionToken:                       token,
+		CommitTimestamp:                      d.CommitTimestamp,
+		RecordSequence:                       d.RecordSequence,
+		ServerTransactionID:                  d.ServerTransactionID,
+		IsLastRecordInTransactionInPartition: d.IsLastRecordInTransactionInPartition,
+		TableName:                            d.TableName,
+		ColumnTypes:                          columnTypes,
+		Mods:                                 mods,
+		ModType:                              d.ModType,
+		ValueCaptureType:                     d.ValueCaptureType,
+		NumberOfRecordsInTransaction:         d.NumberOfRecordsInTransaction,
+		NumberOfPartitionsInTransaction:      d.NumberOfPartitionsInTransaction,
+		TransactionTag:                       d.TransactionTag,
+		IsSystemTransaction:                  d.IsSystemTransaction,
+	}
+}
+
+func jsonString(v spanner.NullJSON) string {
+	if !v.Valid {
+		return ""
+	}
+	return v.String()
+}
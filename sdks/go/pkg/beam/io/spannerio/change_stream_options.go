@@ -0,0 +1,95 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerio
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultHeartbeatInterval = 10 * time.Second
+	minHeartbeatInterval     = 1 * time.Second
+	maxHeartbeatInterval     = 5 * time.Minute
+)
+
+// ChangeStreamOptionFn is a function that can be passed to ReadChangeStream to configure options for reading a change
+// stream.
+type ChangeStreamOptionFn func(*changeStreamOptions) error
+
+// changeStreamOptions represents additional options for reading a change stream.
+type changeStreamOptions struct {
+	StartTimestamp    time.Time     `json:"startTimestamp"`    // The commit timestamp to read changes from, default is the time the pipeline starts.
+	EndTimestamp      time.Time     `json:"endTimestamp"`      // The commit timestamp to read changes until, default is unbounded.
+	HeartbeatInterval time.Duration `json:"heartbeatInterval"` // The interval of heartbeat records of idle partitions.
+}
+
+func newChangeStreamOptions(options ...ChangeStreamOptionFn) changeStreamOptions {
+	opts := changeStreamOptions{
+		HeartbeatInterval: defaultHeartbeatInterval,
+	}
+
+	for _, opt := range options {
+		if err := opt(&opts); err != nil {
+			panic(fmt.Sprintf("spannerio.ReadChangeStream: invalid option: %v", err))
+		}
+	}
+
+	if !opts.StartTimestamp.IsZero() && !opts.EndTimestamp.IsZero() && opts.EndTimestamp.Before(opts.StartTimestamp) {
+		panic("spannerio.ReadChangeStream: invalid option: end timestamp must not be before start timestamp")
+	}
+
+	return opts
+}
+
+// WithStartTimestamp sets the commit timestamp to start reading changes from. It must be within the retention period
+// of the change stream.
+func WithStartTimestamp(start time.Time) ChangeStreamOptionFn {
+	return func(opts *changeStreamOptions) error {
+		if start.IsZero() {
+			return errors.New("start timestamp must be set")
+		}
+
+		opts.StartTimestamp = start
+		return nil
+	}
+}
+
+// WithEndTimestamp sets the commit timestamp to read changes until, which makes the read bounded.
+func WithEndTimestamp(end time.Time) ChangeStreamOptionFn {
+	return func(opts *changeStreamOptions) error {
+		if end.IsZero() {
+			return errors.New("end timestamp must be set")
+		}
+
+		opts.EndTimestamp = end
+		return nil
+	}
+}
+
+// WithHeartbeatInterval sets how often idle partitions of the change stream report heartbeat records, which advance
+// the watermark. It must be between 1 second and 5 minutes.
+func WithHeartbeatInterval(interval time.Duration) ChangeStreamOptionFn {
+	return func(opts *changeStreamOptions) error {
+		if interval < minHeartbeatInterval || interval > maxHeartbeatInterval {
+			return fmt.Errorf("heartbeat interval must be between %v and %v", minHeartbeatInterval, maxHeartbeatInterval)
+		}
+
+		opts.HeartbeatInterval = interval
+		return nil
+	}
+}
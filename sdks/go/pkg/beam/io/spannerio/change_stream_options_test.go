@@ -0,0 +1,135 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithStartTimestamp(t *testing.T) {
+	tests := []struct {
+		name    string
+		start   time.Time
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name:  "Set start timestamp",
+			start: baseTime,
+			want:  baseTime,
+		},
+		{
+			name:    "Error - start timestamp must be set",
+			start:   time.Time{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var option changeStreamOptions
+
+			if err := WithStartTimestamp(tt.start)(&option); (err != nil) != tt.wantErr {
+				t.Fatalf("WithStartTimestamp() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !option.StartTimestamp.Equal(tt.want) {
+				t.Errorf("option.StartTimestamp = %v, want %v", option.StartTimestamp, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithEndTimestamp(t *testing.T) {
+	tests := []struct {
+		name    string
+		end     time.Time
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name: "Set end timestamp",
+			end:  baseTime,
+			want: baseTime,
+		},
+		{
+			name:    "Error - end timestamp must be set",
+			end:     time.Time{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var option changeStreamOptions
+
+			if err := WithEndTimestamp(tt.end)(&option); (err != nil) != tt.wantErr {
+				t.Fatalf("WithEndTimestamp() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !option.EndTimestamp.Equal(tt.want) {
+				t.Errorf("option.EndTimestamp = %v, want %v", option.EndTimestamp, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithHeartbeatInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		want     time.Duration
+		wantErr  bool
+	}{
+		{
+			name:     "Set heartbeat interval to 5 seconds",
+			interval: 5 * time.Second,
+			want:     5 * time.Second,
+		},
+		{
+			name:     "Error - heartbeat interval is less than 1 second",
+			interval: 100 * time.Millisecond,
+			wantErr:  true,
+		},
+		{
+			name:     "Error - heartbeat interval is more than 5 minutes",
+			interval: 10 * time.Minute,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var option changeStreamOptions
+
+			if err := WithHeartbeatInterval(tt.interval)(&option); (err != nil) != tt.wantErr {
+				t.Fatalf("WithHeartbeatInterval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if option.HeartbeatInterval != tt.want {
+				t.Errorf("option.HeartbeatInterval = %v, want %v", option.HeartbeatInterval, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewChangeStreamOptions_EndBeforeStart(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("newChangeStreamOptions() does not panic")
+		}
+	}()
+
+	newChangeStreamOptions(WithStartTimestamp(baseTime), WithEndTimestamp(baseTime.Add(-time.Second)))
+}
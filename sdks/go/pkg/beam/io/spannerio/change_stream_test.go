@@ -0,0 +1,418 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerio
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/iterator"
+)
+
+var baseTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// fakeChangeStream stands in for the change stream queries of Spanner, returning the records of each partition whose
+// timestamps are within the queried range.
+type fakeChangeStream struct {
+	t          *testing.T
+	partitions map[string][]*changeRecordRow
+}
+
+func (cs *fakeChangeStream) query(_ context.Context, stmt spanner.Statement) rowIterator {
+	start := stmt.Params["startTimestamp"].(time.Time)
+	end := stmt.Params["endTimestamp"].(time.Time)
+	token := stmt.Params["partitionToken"].(spanner.NullString)
+
+	var rows []*spanner.Row
+	for _, record := range cs.partitions[token.StringVal] {
+		ts := recordTimestamp(record)
+		if ts.Before(start) || ts.After(end) {
+			continue
+		}
+
+		row, err := spanner.NewRow([]string{"ChangeRecord"}, []any{[]*changeRecordRow{record}})
+		if err != nil {
+			cs.t.Fatalf("Creating change stream row: %v", err)
+		}
+		rows = append(rows, row)
+	}
+
+	return &fakeRowIterator{rows: rows}
+}
+
+func recordTimestamp(record *changeRecordRow) time.Time {
+	switch {
+	case len(record.DataChangeRecord) > 0:
+		return record.DataChangeRecord[0].CommitTimestamp
+	case len(record.HeartbeatRecord) > 0:
+		return record.HeartbeatRecord[0].Timestamp
+	default:
+		return record.ChildPartitionsRecord[0].StartTimestamp
+	}
+}
+
+type fakeRowIterator struct {
+	rows []*spanner.Row
+	err  error
+}
+
+func (it *fakeRowIterator) Next() (*spanner.Row, error) {
+	if len(it.rows) == 0 {
+		if it.err != nil {
+			return nil, it.err
+		}
+		return nil, iterator.Done
+	}
+	row := it.rows[0]
+	it.rows = it.rows[1:]
+	return row, nil
+}
+
+func (it *fakeRowIterator) Stop() {}
+
+func dataRecord(ts time.Time, table string, keys string) *changeRecordRow {
+	return &changeRecordRow{
+		DataChangeRecord: []*dataChangeRecordRow{{
+			CommitTimestamp:                      ts,
+			RecordSequence:                       "00000000",
+			ServerTransactionID:                  "txn-" + keys,
+			IsLastRecordInTransactionInPartition: true,
+			TableName:                            table,
+			ColumnTypes: []*columnTypeRow{{
+				Name:            "Two",
+				Type:            spanner.NullJSON{Value: map[string]any{"code": "INT64"}, Valid: true},
+				IsPrimaryKey:    true,
+				OrdinalPosition: 1,
+			}},
+			Mods: []*modRow{{
+				Keys:      spanner.NullJSON{Value: map[string]any{"Two": keys}, Valid: true},
+				NewValues: spanner.NullJSON{Value: map[string]any{}, Valid: true},
+			}},
+			ModType:                         "INSERT",
+			ValueCaptureType:                "OLD_AND_NEW_VALUES",
+			NumberOfRecordsInTransaction:    1,
+			NumberOfPartitionsInTransaction: 1,
+		}},
+	}
+}
+
+func heartbeatRecord(ts time.Time) *changeRecordRow {
+	return &changeRecordRow{
+		HeartbeatRecord: []*heartbeatRecordRow{{Timestamp: ts}},
+	}
+}
+
+func childPartitionsRecord(ts time.Time, children ...*childPartitionRow) *changeRecordRow {
+	return &changeRecordRow{
+		ChildPartitionsRecord: []*childPartitionsRecordRow{{
+			StartTimestamp:  ts,
+			RecordSequence:  "00000001",
+			ChildPartitions: children,
+		}},
+	}
+}
+
+func TestReadChangeStreamFn(t *testing.T) {
+	end := baseTime.Add(time.Minute)
+
+	cs := &fakeChangeStream{t: t, partitions: map[string][]*changeRecordRow{
+		"": {
+			childPartitionsRecord(baseTime,
+				&childPartitionRow{Token: "a"},
+				&childPartitionRow{Token: "b"},
+			),
+		},
+		"a": {
+			dataRecord(baseTime.Add(1*time.Second), "Test", "1"),
+			heartbeatRecord(baseTime.Add(2 * time.Second)),
+			childPartitionsRecord(baseTime.Add(3*time.Second),
+				&childPartitionRow{Token: "c", ParentPartitionTokens: []string{"a", "b"}},
+			),
+		},
+		"b": {
+			dataRecord(baseTime.Add(2*time.Second), "Test", "2"),
+			childPartitionsRecord(baseTime.Add(3*time.Second),
+				&childPartitionRow{Token: "c", ParentPartitionTokens: []string{"a", "b"}},
+			),
+		},
+		"c": {
+			dataRecord(baseTime.Add(4*time.Second), "Test", "3"),
+		},
+	}}
+
+	fn := newReadChangeStreamFn("projects/fake-proj/instances/fake-instance/databases/fake-db", "TestStream",
+		newChangeStreamOptions(WithStartTimestamp(baseTime), WithEndTimestamp(end)))
+	fn.query = cs.query
+
+	if err := fn.Setup(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	rest := fn.CreateInitialRestriction(nil)
+	we := fn.CreateWatermarkEstimator(fn.InitialWatermarkEstimatorState(0, rest, nil))
+
+	var got []DataChangeRecord
+	var times []beam.EventTime
+	emit := func(et beam.EventTime, record DataChangeRecord) {
+		times = append(times, et)
+		got = append(got, record)
+	}
+
+	var partitions [][]string
+	for i := 0; i < 10; i++ {
+		rt := fn.CreateTracker(rest)
+		if rt.IsBounded() != true {
+			t.Errorf("IsBounded() = false, want true for a read with an end timestamp")
+		}
+
+		pc, err := fn.ProcessElement(context.Background(), we, rt, nil, emit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pc.ShouldResume() {
+			if !rt.IsDone() {
+				t.Error("ProcessElement() stopped processing before the restriction is done")
+			}
+			break
+		}
+
+		_, residual, err := rt.TrySplit(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rest = residual.(changeStreamRestriction)
+		partitions = append(partitions, tokens(rest))
+	}
+
+	wantPartitions := [][]string{{"a", "b"}, {"c"}}
+	if diff := cmp.Diff(wantPartitions, partitions); diff != "" {
+		t.Errorf("partitions of checkpoints mismatch (-want +got):\n%s", diff)
+	}
+
+	var keys []string
+	for _, record := range got {
+		keys = append(keys, record.Mods[0].Keys)
+	}
+	sort.Strings(keys)
+	if want := []string{`{"Two":"1"}`, `{"Two":"2"}`, `{"Two":"3"}`}; !cmp.Equal(keys, want) {
+		t.Errorf("emitted records with keys %v, want %v", keys, want)
+	}
+
+	want := DataChangeRecord{
+		PartitionToken:                       "c",
+		CommitTimestamp:                      baseTime.Add(4 * time.Second),
+		RecordSequence:                       "00000000",
+		ServerTransactionID:                  "txn-3",
+		IsLastRecordInTransactionInPartition: true,
+		TableName:                            "Test",
+		ColumnTypes:                          []ColumnType{{Name: "Two", Type: `{"code":"INT64"}`, IsPrimaryKey: true, OrdinalPosition: 1}},
+		Mods:                                 []Mod{{Keys: `{"Two":"3"}`, NewValues: `{}`}},
+		ModType:                              "INSERT",
+		ValueCaptureType:                     "OLD_AND_NEW_VALUES",
+		NumberOfRecordsInTransaction:         1,
+		NumberOfPartitionsInTransaction:      1,
+	}
+	if diff := cmp.Diff(want, got[len(got)-1]); diff != "" {
+		t.Errorf("last record mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := times[len(times)-1], beam.EventTime(baseTime.Add(4*time.Second).UnixMilli()); got != want {
+		t.Errorf("event time of last record = %v, want %v", got, want)
+	}
+}
+
+func TestReadChangeStreamFn_CheckpointInTransaction(t *testing.T) {
+	end := baseTime.Add(time.Minute)
+	commit := baseTime.Add(time.Second)
+
+	// The first two records are of the same transaction.
+	first, second := dataRecord(commit, "Test", "1"), dataRecord(commit, "Test", "2")
+	first.DataChangeRecord[0].IsLastRecordInTransactionInPartition = false
+	second.DataChangeRecord[0].RecordSequence = "00000001"
+	second.DataChangeRecord[0].ServerTransactionID = first.DataChangeRecord[0].ServerTransactionID
+
+	cs := &fakeChangeStream{t: t, partitions: map[string][]*changeRecordRow{
+		"a": {first, second, dataRecord(baseTime.Add(2*time.Second), "Test", "3")},
+	}}
+
+	fn := newReadChangeStreamFn("projects/fake-proj/instances/fake-instance/databases/fake-db", "TestStream",
+		newChangeStreamOptions(WithStartTimestamp(baseTime), WithEndTimestamp(end)))
+	fn.query = cs.query
+
+	rest := changeStreamRestriction{Partitions: []changeStreamPartition{{Token: "a", Start: baseTime, End: end}}}
+	we := fn.CreateWatermarkEstimator(fn.InitialWatermarkEstimatorState(0, rest, nil))
+
+	var keys []string
+	var rt *sdf.LockRTracker
+	var residual any
+	emit := func(_ beam.EventTime, record DataChangeRecord) {
+		keys = append(keys, record.Mods[0].Keys)
+		if len(keys) == 1 {
+			// Checkpoint after the first record of the transaction has been emitted.
+			var err error
+			if _, residual, err = rt.TrySplit(0); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var starts []time.Time
+	for i := 0; i < 10 && len(rest.Partitions) > 0; i++ {
+		rt, residual = fn.CreateTracker(rest), nil
+		if _, err := fn.ProcessElement(context.Background(), we, rt, nil, emit); err != nil {
+			t.Fatal(err)
+		}
+		if residual == nil {
+			break
+		}
+		rest = residual.(changeStreamRestriction)
+		starts = append(starts, rest.Partitions[0].Start)
+	}
+
+	if want := []string{`{"Two":"1"}`, `{"Two":"2"}`, `{"Two":"3"}`}; !cmp.Equal(keys, want) {
+		t.Errorf("emitted records with keys %v, want %v", keys, want)
+	}
+	if len(starts) == 0 || !starts[0].Equal(commit.Add(time.Microsecond)) {
+		t.Errorf("starts of the residuals = %v, want the first after the transaction at %v", starts, commit)
+	}
+}
+
+func TestReadChangeStreamFn_Heartbeats(t *testing.T) {
+	cs := &fakeChangeStream{t: t, partitions: map[string][]*changeRecordRow{
+		"a": {
+			dataRecord(baseTime.Add(1*time.Second), "Test", "1"),
+			heartbeatRecord(baseTime.Add(5 * time.Second)),
+		},
+		"b": {
+			heartbeatRecord(baseTime.Add(3 * time.Second)),
+		},
+	}}
+
+	fn := newReadChangeStreamFn("projects/fake-proj/instances/fake-instance/databases/fake-db", "TestStream",
+		newChangeStreamOptions())
+	fn.query = func(ctx context.Context, stmt spanner.Statement) rowIterator {
+		// Stand in for queries that fail after the heartbeats, before reaching their end.
+		it := cs.query(ctx, stmt).(*fakeRowIterator)
+		it.err = errors.New("connection lost")
+		return it
+	}
+
+	rest := changeStreamRestriction{Partitions: []changeStreamPartition{
+		{Token: "a", Start: baseTime},
+		{Token: "b", Start: baseTime},
+	}}
+	rt := sdf.NewLockRTracker(newChangeStreamTracker(rest))
+	we := fn.CreateWatermarkEstimator(fn.InitialWatermarkEstimatorState(0, rest, nil))
+
+	if rt.IsBounded() {
+		t.Error("IsBounded() = true, want false for a read without an end timestamp")
+	}
+
+	if _, err := fn.ProcessElement(context.Background(), we, rt, nil, func(beam.EventTime, DataChangeRecord) {}); err == nil {
+		t.Fatal("ProcessElement() succeeded, want error")
+	}
+
+	got := rt.GetRestriction().(changeStreamRestriction)
+	want := changeStreamRestriction{Partitions: []changeStreamPartition{
+		{Token: "a", Start: baseTime.Add(5*time.Second + time.Microsecond)},
+		{Token: "b", Start: baseTime.Add(3*time.Second + time.Microsecond)},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("restriction after heartbeats mismatch (-want +got):\n%s", diff)
+	}
+
+	if start, _ := earliestStart(got); !start.Equal(baseTime.Add(3*time.Second + time.Microsecond)) {
+		t.Errorf("earliestStart() = %v, want the earliest heartbeat", start)
+	}
+}
+
+func TestReadChangeStreamFn_MergedParentsInSeparateRestrictions(t *testing.T) {
+	end := baseTime.Add(time.Minute)
+	merge := baseTime.Add(3 * time.Second)
+
+	cs := &fakeChangeStream{t: t, partitions: map[string][]*changeRecordRow{
+		"a": {
+			dataRecord(baseTime.Add(1*time.Second), "Test", "1"),
+			childPartitionsRecord(merge, &childPartitionRow{Token: "c", ParentPartitionTokens: []string{"a", "b"}}),
+		},
+		"b": {
+			dataRecord(baseTime.Add(2*time.Second), "Test", "2"),
+			childPartitionsRecord(merge, &childPartitionRow{Token: "c", ParentPartitionTokens: []string{"a", "b"}}),
+		},
+		"c": {
+			dataRecord(baseTime.Add(4*time.Second), "Test", "3"),
+		},
+	}}
+
+	fn := newReadChangeStreamFn("projects/fake-proj/instances/fake-instance/databases/fake-db", "TestStream",
+		newChangeStreamOptions(WithStartTimestamp(baseTime), WithEndTimestamp(end)))
+	fn.query = cs.query
+
+	// The parents were split off into separate restrictions, the one of a being read first.
+	restA := changeStreamRestriction{Partitions: []changeStreamPartition{{Token: "a", Start: baseTime, End: end}}}
+	restB := changeStreamRestriction{Partitions: []changeStreamPartition{{Token: "b", Start: baseTime, End: end}}}
+	weA := fn.CreateWatermarkEstimator(fn.InitialWatermarkEstimatorState(0, restA, nil))
+	weB := fn.CreateWatermarkEstimator(fn.InitialWatermarkEstimatorState(0, restB, nil))
+
+	var keys []string
+	emit := func(_ beam.EventTime, record DataChangeRecord) {
+		keys = append(keys, record.Mods[0].Keys)
+	}
+
+	// The restriction of a adopts the child, and reads it before b is read.
+	for rest := restA; len(rest.Partitions) > 0; {
+		rt := fn.CreateTracker(rest)
+		if _, err := fn.ProcessElement(context.Background(), weA, rt, nil, emit); err != nil {
+			t.Fatal(err)
+		}
+		rest = rt.GetRestriction().(changeStreamRestriction)
+	}
+	if want := []string{`{"Two":"1"}`, `{"Two":"3"}`}; !cmp.Equal(keys, want) {
+		t.Errorf("records emitted by the restriction of a = %v, want %v", keys, want)
+	}
+
+	// The watermark of the restriction of b holds back the output watermark until b is read.
+	if wm := weB.CurrentWatermark(); !wm.Before(merge) {
+		t.Errorf("watermark of the restriction of b = %v, want before the start %v of the child", wm, merge)
+	}
+
+	// The restriction of b does not read the child again.
+	keys = nil
+	rt := fn.CreateTracker(restB)
+	if _, err := fn.ProcessElement(context.Background(), weB, rt, nil, emit); err != nil {
+		t.Fatal(err)
+	}
+	if !rt.IsDone() {
+		t.Errorf("restriction of b = %v, want done", rt.GetRestriction())
+	}
+	if want := []string{`{"Two":"2"}`}; !cmp.Equal(keys, want) {
+		t.Errorf("records emitted by the restriction of b = %v, want %v", keys, want)
+	}
+}
+
+func tokens(rest changeStreamRestriction) []string {
+	var tokens []string
+	for _, p := range rest.Partitions {
+		tokens = append(tokens, p.Token)
+	}
+	return tokens
+}
@@ -0,0 +1,182 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerio
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*changeStreamRestriction)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*changeStreamPartition)(nil)).Elem())
+	beam.RegisterType(reflect.TypeOf((*changeStreamTracker)(nil)))
+}
+
+// changeStreamPartition is a partition of a change stream, identified by its partition token, that is read from its
+// start timestamp until its end timestamp or until it ends in child partitions. The root partition, which reports the
+// initial partitions of the change stream, has an empty token.
+type changeStreamPartition struct {
+	Token string    `json:"token"` // The partition token.
+	Start time.Time `json:"start"` // The commit timestamp the partition is read from, inclusive.
+	End   time.Time `json:"end"`   // The commit timestamp the partition is read until, zero if unbounded.
+}
+
+// changeStreamRestriction is the set of partitions of a change stream that is read by a single element.
+type changeStreamRestriction struct {
+	Partitions []changeStreamPartition `json:"partitions"`
+}
+
+// partitionProgress is a position that advances the start timestamp of a partition, after its records up to then have
+// been read.
+type partitionProgress struct {
+	Token string
+	Start time.Time
+}
+
+// partitionDone is a position that removes a partition that has been read to its end, and adds the child partitions
+// it ended in.
+type partitionDone struct {
+	Token    string
+	Children []changeStreamPartition
+}
+
+// changeStreamTracker tracks the partitions of a changeStreamRestriction. The restriction can be split between its
+// partitions, or checkpointed.
+type changeStreamTracker struct {
+	rest    changeStreamRestriction
+	stopped bool
+	err     error
+}
+
+func newChangeStreamTracker(rest changeStreamRestriction) *changeStreamTracker {
+	return &changeStreamTracker{rest: rest}
+}
+
+func (t *changeStreamTracker) index(token string) int {
+	for i, p := range t.rest.Partitions {
+		if p.Token == token {
+			return i
+		}
+	}
+	return -1
+}
+
+// TryClaim accepts a partitionProgress or a partitionDone position. The position is claimed if the tracker hasn't
+// been checkpointed and its partition is still part of the restriction, which is not the case after the partition
+// has been split off into a residual.
+func (t *changeStreamTracker) TryClaim(pos any) bool {
+	if t.stopped {
+		return false
+	}
+
+	switch p := pos.(type) {
+	case partitionProgress:
+		i := t.index(p.Token)
+		if i < 0 {
+			return false
+		}
+		if p.Start.Before(t.rest.Partitions[i].Start) {
+			t.err = fmt.Errorf("cannot claim timestamp %v of partition %q before its start %v", p.Start, p.Token, t.rest.Partitions[i].Start)
+			t.stopped = true
+			return false
+		}
+		t.rest.Partitions[i].Start = p.Start
+		return true
+	case partitionDone:
+		i := t.index(p.Token)
+		if i < 0 {
+			return false
+		}
+		partitions := append(t.rest.Partitions[:i:i], t.rest.Partitions[i+1:]...)
+		for _, child := range p.Children {
+			if t.index(child.Token) < 0 {
+				partitions = append(partitions, child)
+			}
+		}
+		t.rest.Partitions = partitions
+		return true
+	default:
+		t.err = fmt.Errorf("invalid position type %T", pos)
+		t.stopped = true
+		return false
+	}
+}
+
+func (t *changeStreamTracker) GetError() error {
+	return t.err
+}
+
+// TrySplit checkpoints the restriction if the fraction is 0, moving all of its partitions to the residual. Otherwise
+// it splits the partitions between the primary and the residual by the fraction, keeping at least one partition in
+// each.
+func (t *changeStreamTracker) TrySplit(fraction float64) (primary, residual any, err error) {
+	if fraction < 0 || fraction > 1 {
+		return nil, nil, errors.New("fraction must be between 0 and 1")
+	}
+
+	n := len(t.rest.Partitions)
+	if t.stopped || n == 0 {
+		return t.rest, nil, nil
+	}
+
+	if fraction == 0 {
+		residual = changeStreamRestriction{Partitions: t.rest.Partitions}
+		t.rest = changeStreamRestriction{}
+		t.stopped = true
+		return t.rest, residual, nil
+	}
+
+	split := int(float64(n) * fraction)
+	if split < 1 {
+		split = 1
+	}
+	if split >= n {
+		return t.rest, nil, nil
+	}
+
+	partitions := t.rest.Partitions
+	t.rest = changeStreamRestriction{Partitions: partitions[:split:split]}
+	residual = changeStreamRestriction{Partitions: append([]changeStreamPartition(nil), partitions[split:]...)}
+	return t.rest, residual, nil
+}
+
+// GetProgress reports each partition as a unit of remaining work, as the amount of changes is unknown.
+func (t *changeStreamTracker) GetProgress() (done, remaining float64) {
+	return 0, float64(len(t.rest.Partitions))
+}
+
+func (t *changeStreamTracker) IsDone() bool {
+	return t.stopped || len(t.rest.Partitions) == 0
+}
+
+func (t *changeStreamTracker) GetRestriction() any {
+	return t.rest
+}
+
+// IsBounded returns whether all partitions of the restriction have an end timestamp.
+func (t *changeStreamTracker) IsBounded() bool {
+	for _, p := range t.rest.Partitions {
+		if p.End.IsZero() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,169 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestChangeStreamTracker_TryClaim(t *testing.T) {
+	rest := func() changeStreamRestriction {
+		return changeStreamRestriction{Partitions: []changeStreamPartition{
+			{Token: "a", Start: baseTime},
+			{Token: "b", Start: baseTime},
+		}}
+	}
+
+	tests := []struct {
+		name     string
+		stopped  bool
+		pos      any
+		wantOk   bool
+		wantRest changeStreamRestriction
+		wantErr  bool
+	}{
+		{
+			name:   "Claim progress of a partition",
+			pos:    partitionProgress{Token: "a", Start: baseTime.Add(time.Second)},
+			wantOk: true,
+			wantRest: changeStreamRestriction{Partitions: []changeStreamPartition{
+				{Token: "a", Start: baseTime.Add(time.Second)},
+				{Token: "b", Start: baseTime},
+			}},
+		},
+		{
+			name:   "Claim the end of a partition with children",
+			pos:    partitionDone{Token: "a", Children: []changeStreamPartition{{Token: "b"}, {Token: "c", Start: baseTime}}},
+			wantOk: true,
+			wantRest: changeStreamRestriction{Partitions: []changeStreamPartition{
+				{Token: "b", Start: baseTime},
+				{Token: "c", Start: baseTime},
+			}},
+		},
+		{
+			name:     "Fail to claim a partition that is not in the restriction",
+			pos:      partitionProgress{Token: "c", Start: baseTime},
+			wantOk:   false,
+			wantRest: rest(),
+		},
+		{
+			name:     "Fail to claim after checkpointing",
+			stopped:  true,
+			pos:      partitionProgress{Token: "a", Start: baseTime.Add(time.Second)},
+			wantOk:   false,
+			wantRest: rest(),
+		},
+		{
+			name:     "Fail to claim progress before the start of a partition",
+			pos:      partitionProgress{Token: "a", Start: baseTime.Add(-time.Second)},
+			wantOk:   false,
+			wantRest: rest(),
+			wantErr:  true,
+		},
+		{
+			name:     "Fail to claim an invalid position",
+			pos:      int64(1),
+			wantOk:   false,
+			wantRest: rest(),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newChangeStreamTracker(rest())
+			tracker.stopped = tt.stopped
+
+			if got := tracker.TryClaim(tt.pos); got != tt.wantOk {
+				t.Errorf("TryClaim() = %v, want %v", got, tt.wantOk)
+			}
+			if diff := cmp.Diff(tt.wantRest, tracker.GetRestriction()); diff != "" {
+				t.Errorf("GetRestriction() mismatch (-want +got):\n%s", diff)
+			}
+			if err := tracker.GetError(); (err != nil) != tt.wantErr {
+				t.Errorf("GetError() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChangeStreamTracker_TrySplit(t *testing.T) {
+	partitions := []changeStreamPartition{
+		{Token: "a", Start: baseTime},
+		{Token: "b", Start: baseTime},
+		{Token: "c", Start: baseTime},
+		{Token: "d", Start: baseTime},
+	}
+
+	tests := []struct {
+		name         string
+		partitions   []changeStreamPartition
+		fraction     float64
+		wantPrimary  any
+		wantResidual any
+		wantDone     bool
+	}{
+		{
+			name:         "Checkpoint all partitions",
+			partitions:   partitions,
+			fraction:     0,
+			wantPrimary:  changeStreamRestriction{},
+			wantResidual: changeStreamRestriction{Partitions: partitions},
+			wantDone:     true,
+		},
+		{
+			name:         "Split partitions by fraction",
+			partitions:   partitions,
+			fraction:     0.5,
+			wantPrimary:  changeStreamRestriction{Partitions: partitions[:2]},
+			wantResidual: changeStreamRestriction{Partitions: partitions[2:]},
+		},
+		{
+			name:         "Keep at least one partition in the primary",
+			partitions:   partitions,
+			fraction:     0.1,
+			wantPrimary:  changeStreamRestriction{Partitions: partitions[:1]},
+			wantResidual: changeStreamRestriction{Partitions: partitions[1:]},
+		},
+		{
+			name:        "Don't split a single partition",
+			partitions:  partitions[:1],
+			fraction:    0.5,
+			wantPrimary: changeStreamRestriction{Partitions: partitions[:1]},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newChangeStreamTracker(changeStreamRestriction{Partitions: tt.partitions})
+
+			primary, residual, err := tracker.TrySplit(tt.fraction)
+			if err != nil {
+				t.Fatalf("TrySplit() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.wantPrimary, primary); diff != "" {
+				t.Errorf("TrySplit() primary mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantResidual, residual); diff != "" {
+				t.Errorf("TrySplit() residual mismatch (-want +got):\n%s", diff)
+			}
+			if got := tracker.IsDone(); got != tt.wantDone {
+				t.Errorf("IsDone() = %v, want %v", got, tt.wantDone)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerio
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// keyColumn is a primary key column of a table.
+type keyColumn struct {
+	Name string
+	Desc bool
+}
+
+// tableSplits are the primary key of a table and the keys that start its splits.
+type tableSplits struct {
+	columns []keyColumn
+	// types are the types of the key columns, which are known if the table has split points.
+	types []*spannerpb.Type
+	// points are the first keys of the splits of the table, in key order.
+	points [][]*structpb.Value
+}
+
+// split returns the index of the split of the table that the key belongs to, which is the number of split points
+// that are not after the key.
+func (t *tableSplits) split(key []*structpb.Value) int {
+	return sort.Search(len(t.points), func(i int) bool {
+		return t.compareKeys(t.points[i], key) > 0
+	})
+}
+
+// compareKeys compares two keys of the table in key order. A key that is a prefix of the other sorts first.
+func (t *tableSplits) compareKeys(a, b []*structpb.Value) int {
+	for i := 0; i < len(a) && i < len(b) && i < len(t.types); i++ {
+		c := compareValues(t.types[i], a[i], b[i])
+		if t.columns[i].Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// mutationKey returns the primary key of the row that the mutation writes, or of the first row that it deletes. The
+// key of a delete of all rows is empty.
+func (t *tableSplits) mutationKey(m *spannerpb.Mutation) []*structpb.Value {
+	var write *spannerpb.Mutation_Write
+	switch op := m.GetOperation().(type) {
+	case *spannerpb.Mutation_Insert:
+		write = op.Insert
+	case *spannerpb.Mutation_Update:
+		write = op.Update
+	case *spannerpb.Mutation_InsertOrUpdate:
+		write = op.InsertOrUpdate
+	case *spannerpb.Mutation_Replace:
+		write = op.Replace
+	case *spannerpb.Mutation_Delete_:
+		keys := op.Delete.GetKeySet()
+		if len(keys.GetKeys()) > 0 {
+			return keys.GetKeys()[0].GetValues()
+		}
+		if len(keys.GetRanges()) > 0 {
+			r := keys.GetRanges()[0]
+			if r.GetStartClosed() != nil {
+				return r.GetStartClosed().GetValues()
+			}
+			return r.GetStartOpen().GetValues()
+		}
+		return nil
+	default:
+		return nil
+	}
+
+	if len(write.GetValues()) == 0 {
+		return nil
+	}
+	row := write.GetValues()[0].GetValues()
+	key := make([]*structpb.Value, 0, len(t.columns))
+	for _, col := range t.columns {
+		i := columnIndex(write.GetColumns(), col.Name)
+		if i < 0 || i >= len(row) {
+			break
+		}
+		key = append(key, row[i])
+	}
+	return key
+}
+
+// mutationTable returns the table of the mutation.
+func mutationTable(m *spannerpb.Mutation) string {
+	switch op := m.GetOperation().(type) {
+	case *spannerpb.Mutation_Insert:
+		return op.Insert.GetTable()
+	case *spannerpb.Mutation_Update:
+		return op.Update.GetTable()
+	case *spannerpb.Mutation_InsertOrUpdate:
+		return op.InsertOrUpdate.GetTable()
+	case *spannerpb.Mutation_Replace:
+		return op.Replace.GetTable()
+	case *spannerpb.Mutation_Delete_:
+		return op.Delete.GetTable()
+	default:
+		return ""
+	}
+}
+
+// columnIndex returns the index of the column with the given name, which spanner compares case-insensitively, or -1.
+func columnIndex(columns []string, name string) int {
+	for i, c := range columns {
+		if strings.EqualFold(c, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// readTableSplits reads the primary key of the table and the first keys of its splits, which are the first rows of
+// the partitions of a read of the whole table.
+func readTableSplits(ctx context.Context, client *spanner.Client, table string) (*tableSplits, error) {
+	columns, err := readPrimaryKey(ctx, client, table)
+	if err != nil {
+		return nil, err
+	}
+	splits := &tableSplits{columns: columns}
+	if len(columns) == 0 {
+		return splits, nil
+	}
+
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.Name
+	}
+
+	txn, err := client.BatchReadOnlyTransaction(ctx, spanner.StrongRead())
+	if err != nil {
+		return nil, fmt.Errorf("unable to create batch read only transaction: %v", err)
+	}
+	defer txn.Close()
+
+	partitions, err := txn.PartitionRead(ctx, table, spanner.AllKeys(), names, spanner.PartitionOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to partition read of table %v: %v", table, err)
+	}
+
+	for _, p := range partitions {
+		row, err := firstRow(txn.Execute(ctx, p))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read partition of table %v: %v", table, err)
+		}
+		if row == nil {
+			continue
+		}
+
+		key := make([]*structpb.Value, row.Size())
+		splits.types = make([]*spannerpb.Type, row.Size())
+		for i := range key {
+			key[i] = row.ColumnValue(i)
+			splits.types[i] = row.ColumnType(i)
+		}
+		splits.points = append(splits.points, key)
+	}
+
+	sort.Slice(splits.points, func(i, j int) bool {
+		return splits.compareKeys(splits.points[i], splits.points[j]) < 0
+	})
+
+	return splits, nil
+}
+
+// readPrimaryKey reads the primary key columns of the table from the information schema.
+func readPrimaryKey(ctx context.Context, client *spanner.Client, table string) ([]keyColumn, error) {
+	stmt := spanner.Statement{
+		SQL: `SELECT COLUMN_NAME, COLUMN_ORDERING FROM INFORMATION_SCHEMA.INDEX_COLUMNS
+			WHERE TABLE_SCHEMA = '' AND TABLE_NAME = @table AND INDEX_NAME = 'PRIMARY_KEY'
+			ORDER BY ORDINAL_POSITION`,
+		Params: map[string]any{"table": table},
+	}
+
+	it := client.Single().Query(ctx, stmt)
+	defer it.Stop()
+
+	var columns []keyColumn
+	for {
+		row, err := it.Next()
+		if err == iterator.Done {
+			return columns, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read primary key of table %v: %v", table, err)
+		}
+
+		var name string
+		var ordering spanner.NullString
+		if err := row.Columns(&name, &ordering); err != nil {
+			return nil, fmt.Errorf("unable to read primary key of table %v: %v", table, err)
+		}
+		columns = append(columns, keyColumn{Name: name, Desc: ordering.StringVal == "DESC"})
+	}
+}
+
+func firstRow(it *spanner.RowIterator) (*spanner.Row, error) {
+	defer it.Stop()
+
+	row, err := it.Next()
+	if err == iterator.Done {
+		return nil, nil
+	}
+	return row, err
+}
+
+// compareValues compares two values of a key column of the given type in the order of spanner, where nulls sort
+// first.
+func compareValues(t *spannerpb.Type, a, b *structpb.Value) int {
+	_, aNull := a.GetKind().(*structpb.Value_NullValue)
+	_, bNull := b.GetKind().(*structpb.Value_NullValue)
+	switch {
+	case aNull && bNull:
+		return 0
+	case aNull:
+		return -1
+	case bNull:
+		return 1
+	}
+
+	switch t.GetCode() {
+	case spannerpb.TypeCode_INT64, spannerpb.TypeCode_ENUM:
+		x, errA := strconv.ParseInt(a.GetStringValue(), 10, 64)
+		y, errB := strconv.ParseInt(b.GetStringValue(), 10, 64)
+		if errA == nil && errB == nil {
+			return cmp.Compare(x, y)
+		}
+	case spannerpb.TypeCode_FLOAT64, spannerpb.TypeCode_FLOAT32:
+		// NaN sorts first, as it does in spanner.
+		return cmp.Compare(floatValue(a), floatValue(b))
+	case spannerpb.TypeCode_BOOL:
+		x, y := a.GetBoolValue(), b.GetBoolValue()
+		switch {
+		case x == y:
+			return 0
+		case !x:
+			return -1
+		default:
+			return 1
+		}
+	case spannerpb.TypeCode_NUMERIC:
+		x, okA := new(big.Rat).SetString(a.GetStringValue())
+		y, okB := new(big.Rat).SetString(b.GetStringValue())
+		if okA && okB {
+			return x.Cmp(y)
+		}
+	case spannerpb.TypeCode_TIMESTAMP:
+		x, errA := time.Parse(time.RFC3339Nano, a.GetStringValue())
+		y, errB := time.Parse(time.RFC3339Nano, b.GetStringValue())
+		if errA == nil && errB == nil {
+			return x.Compare(y)
+		}
+	case spannerpb.TypeCode_BYTES:
+		x, errA := base64.StdEncoding.DecodeString(a.GetStringValue())
+		y, errB := base64.StdEncoding.DecodeString(b.GetStringValue())
+		if errA == nil && errB == nil {
+			return bytes.Compare(x, y)
+		}
+	}
+	return strings.Compare(a.GetStringValue(), b.GetStringValue())
+}
+
+// floatValue returns the value of a float, which is a string for NaN and infinities.
+func floatValue(v *structpb.Value) float64 {
+	if s, ok := v.GetKind().(*structpb.Value_StringValue); ok {
+		switch s.StringValue {
+		case "Infinity":
+			return math.Inf(1)
+		case "-Infinity":
+			return math.Inf(-1)
+		default:
+			return math.NaN()
+		}
+	}
+	return v.GetNumberValue()
+}
@@ -0,0 +1,111 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerio
+
+import (
+	"testing"
+
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestTableSplits_split(t *testing.T) {
+	// The splits of a table with the primary key (Id INT64, Name STRING(MAX) DESC).
+	splits := &tableSplits{
+		columns: []keyColumn{{Name: "Id"}, {Name: "Name", Desc: true}},
+		types: []*spannerpb.Type{
+			{Code: spannerpb.TypeCode_INT64},
+			{Code: spannerpb.TypeCode_STRING},
+		},
+		points: [][]*structpb.Value{
+			{structpb.NewStringValue("9"), structpb.NewStringValue("b")},
+			{structpb.NewStringValue("10"), structpb.NewStringValue("b")},
+		},
+	}
+	write := func(values ...*structpb.Value) *spannerpb.Mutation {
+		return &spannerpb.Mutation{
+			Operation: &spannerpb.Mutation_InsertOrUpdate{
+				InsertOrUpdate: &spannerpb.Mutation_Write{
+					Table:   "Table",
+					Columns: []string{"value", "name", "id"},
+					Values:  []*structpb.ListValue{{Values: values}},
+				},
+			},
+		}
+	}
+	deleteRange := func(start ...*structpb.Value) *spannerpb.Mutation {
+		return &spannerpb.Mutation{
+			Operation: &spannerpb.Mutation_Delete_{
+				Delete: &spannerpb.Mutation_Delete{
+					Table: "Table",
+					KeySet: &spannerpb.KeySet{
+						Ranges: []*spannerpb.KeyRange{{
+							StartKeyType: &spannerpb.KeyRange_StartClosed{StartClosed: &structpb.ListValue{Values: start}},
+						}},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		mutation *spannerpb.Mutation
+		want     int
+	}{
+		{
+			name:     "before the first split point in descending order",
+			mutation: write(structpb.NewNullValue(), structpb.NewStringValue("c"), structpb.NewStringValue("9")),
+			want:     0,
+		},
+		{
+			name:     "at a split point",
+			mutation: write(structpb.NewNullValue(), structpb.NewStringValue("b"), structpb.NewStringValue("9")),
+			want:     1,
+		},
+		{
+			name:     "after a split point in numeric order",
+			mutation: write(structpb.NewNullValue(), structpb.NewStringValue("a"), structpb.NewStringValue("10")),
+			want:     2,
+		},
+		{
+			name:     "null key",
+			mutation: write(structpb.NewNullValue(), structpb.NewStringValue("a"), structpb.NewNullValue()),
+			want:     0,
+		},
+		{
+			name:     "delete of a key prefix",
+			mutation: deleteRange(structpb.NewStringValue("9")),
+			want:     0,
+		},
+		{
+			name: "delete of all rows",
+			mutation: &spannerpb.Mutation{
+				Operation: &spannerpb.Mutation_Delete_{
+					Delete: &spannerpb.Mutation_Delete{Table: "Table", KeySet: &spannerpb.KeySet{All: true}},
+				},
+			},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splits.split(splits.mutationKey(tt.mutation)); got != tt.want {
+				t.Errorf("split() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerio
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	statuspb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*splitKey)(nil)).Elem())
+	register.DoFn3x1[context.Context, *MutationGroup, func(splitKey, *MutationGroup), error]((*keyMutationGroupsFn)(nil))
+	register.DoFn5x1[context.Context, splitKey, func(**MutationGroup) bool, func(*MutationGroup, time.Time), func(*MutationGroup, *statuspb.Status), error]((*writeMutationGroupsFn)(nil))
+	register.Emitter2[splitKey, *MutationGroup]()
+	register.Iter1[*MutationGroup]()
+	register.Emitter2[*MutationGroup, time.Time]()
+	register.Emitter2[*MutationGroup, *statuspb.Status]()
+}
+
+// MutationGroup is a group of mutations that are committed atomically. It is a protocol buffer message so that it can
+// be encoded in a PCollection.
+type MutationGroup = spannerpb.BatchWriteRequest_MutationGroup
+
+// NewMutationGroup returns a MutationGroup of the given mutations.
+func NewMutationGroup(mutations ...*spannerpb.Mutation) *MutationGroup {
+	return &MutationGroup{Mutations: mutations}
+}
+
+// WriteMutationGroups writes the mutation groups of the given PCollection<*MutationGroup> to spanner with batch
+// writes, which commit each group atomically but not the groups together. It returns a
+// PCollection<KV<*MutationGroup, time.Time>> of the written groups and their commit timestamps, and a
+// PCollection<KV<*MutationGroup, *status.Status>> of the groups that failed to commit and their status.
+//
+// The groups are grouped by key, in each window, by the split of the table that the first mutation of each group
+// writes to, so unbounded PCollections must be windowed. The groups of each split are sent in batch writes of up to
+// the batch size set with UseBatchSize mutations, or in a batch write of their own for groups with more mutations, and
+// are output in the window of the split, at its end. The splits of a table are read from spanner when it is first
+// written to, as the first keys of the partitions of a read of the table. Spanner may commit several groups of a batch
+// write in the same transaction, in which case they share its commit timestamp, which is also the value of columns
+// that are set to spanner.CommitTimestamp ("spanner.commit_timestamp()").
+func WriteMutationGroups(s beam.Scope, db string, col beam.PCollection, options ...WriteOptionsFn) (beam.PCollection, beam.PCollection) {
+	if db == "" {
+		panic("no database provided!")
+	}
+
+	s = s.Scope("spanner.WriteMutationGroups")
+
+	keyed := beam.ParDo(s, &keyMutationGroupsFn{spannerFn: newSpannerFn(db)}, col)
+	return beam.ParDo2(s, newWriteMutationGroupsFn(db, options...), beam.GroupByKey(s, keyed))
+}
+
+// splitKey is a split of a table.
+type splitKey struct {
+	Table string
+	Split int
+}
+
+// keyMutationGroupsFn keys the mutation groups by the split of the table that their first mutation writes to.
+type keyMutationGroupsFn struct {
+	spannerFn
+	// tables are the splits of the tables that have been written to.
+	tables map[string]*tableSplits
+}
+
+func (f *keyMutationGroupsFn) Setup(ctx context.Context) error {
+	return f.spannerFn.Setup(ctx)
+}
+
+func (f *keyMutationGroupsFn) Teardown() {
+	f.spannerFn.Teardown()
+}
+
+func (f *keyMutationGroupsFn) ProcessElement(ctx context.Context, group *MutationGroup, emit func(splitKey, *MutationGroup)) error {
+	key, err := f.splitKey(ctx, group)
+	if err != nil {
+		return err
+	}
+	emit(key, group)
+	return nil
+}
+
+type writeMutationGroupsFn struct {
+	spannerFn
+	Options writeOptions `json:"options"` // Spanner write options
+}
+
+func newWriteMutationGroupsFn(db string, options ...WriteOptionsFn) *writeMutationGroupsFn {
+	writeOptions := writeOptions{
+		BatchSize: 1000, // default
+	}
+
+	for _, opt := range options {
+		if err := opt(&writeOptions); err != nil {
+			panic(err)
+		}
+	}
+
+	return &writeMutationGroupsFn{spannerFn: newSpannerFn(db), Options: writeOptions}
+}
+
+func (f *writeMutationGroupsFn) Setup(ctx context.Context) error {
+	return f.spannerFn.Setup(ctx)
+}
+
+func (f *writeMutationGroupsFn) Teardown() {
+	f.spannerFn.Teardown()
+}
+
+// ProcessElement writes the groups of a split in batch writes, and emits each group with its commit timestamp, or with
+// its status if it failed to commit.
+func (f *writeMutationGroupsFn) ProcessElement(ctx context.Context, _ splitKey, groups func(**MutationGroup) bool, emit func(*MutationGroup, time.Time), emitFailed func(*MutationGroup, *statuspb.Status)) error {
+	var batch []*MutationGroup
+	var size int
+	var group *MutationGroup
+	for groups(&group) {
+		if len(batch) > 0 && size+len(group.GetMutations()) > f.Options.BatchSize {
+			if err := f.flush(ctx, batch, emit, emitFailed); err != nil {
+				return err
+			}
+			batch, size = nil, 0
+		}
+		batch = append(batch, group)
+		size += len(group.GetMutations())
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return f.flush(ctx, batch, emit, emitFailed)
+}
+
+// splitKey returns the split of the table that the first mutation of the group writes to.
+func (f *keyMutationGroupsFn) splitKey(ctx context.Context, group *MutationGroup) (splitKey, error) {
+	if len(group.GetMutations()) == 0 {
+		return splitKey{}, nil
+	}
+	m := group.GetMutations()[0]
+	table := mutationTable(m)
+
+	splits, ok := f.tables[table]
+	if !ok {
+		var err error
+		if splits, err = readTableSplits(ctx, f.client, table); err != nil {
+			return splitKey{}, err
+		}
+		if f.tables == nil {
+			f.tables = make(map[string]*tableSplits)
+		}
+		f.tables[table] = splits
+	}
+
+	return splitKey{Table: table, Split: splits.split(splits.mutationKey(m))}, nil
+}
+
+// flush commits the groups in a single batch write, and emits each group with its commit timestamp, or with its
+// status if it failed to commit.
+func (f *writeMutationGroupsFn) flush(ctx context.Context, batch []*MutationGroup, emit func(*MutationGroup, time.Time), emitFailed func(*MutationGroup, *statuspb.Status)) error {
+	groups := make([]*spanner.MutationGroup, len(batch))
+	for i, g := range batch {
+		groups[i] = &spanner.MutationGroup{}
+		for _, m := range g.GetMutations() {
+			mutation, err := spanner.WrapMutation(m)
+			if err != nil {
+				return fmt.Errorf("invalid mutation: %v", err)
+			}
+			groups[i].Mutations = append(groups[i].Mutations, mutation)
+		}
+	}
+
+	reported := make([]bool, len(groups))
+	err := f.client.BatchWriteWithOptions(ctx, groups, spanner.BatchWriteOptions{}).Do(func(resp *spannerpb.BatchWriteResponse) error {
+		for _, i := range resp.GetIndexes() {
+			if int(i) >= len(groups) || reported[i] {
+				return fmt.Errorf("unexpected mutation group index %v in batch write response", i)
+			}
+			reported[i] = true
+
+			if code := codes.Code(resp.GetStatus().GetCode()); code != codes.OK {
+				emitFailed(batch[i], resp.GetStatus())
+			} else {
+				emit(batch[i], resp.GetCommitTimestamp().AsTime())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write %v mutation groups: %v", len(groups), err)
+	}
+	for i, ok := range reported {
+		if !ok {
+			return fmt.Errorf("no batch write response for mutation group %v", batch[i])
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,371 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerio
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	spannertest "github.com/apache/beam/sdks/v2/go/test/integration/io/spannerio"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"google.golang.org/api/option/internaloption"
+	statuspb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// batchWriter serves the batch writes of a client, which the in-memory fake spanner does not support, by committing
+// each mutation group in a transaction of its own.
+type batchWriter struct {
+	mu sync.Mutex
+	// requests are the number of groups of each batch write.
+	requests []int
+}
+
+func (b *batchWriter) intercept(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	if method != "/google.spanner.v1.Spanner/BatchWrite" {
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+	return &batchWriteStream{ctx: ctx, cc: cc, writer: b}, nil
+}
+
+// newClient returns a client of the fake spanner that supports batch writes.
+func (b *batchWriter) newClient(ctx context.Context, t *testing.T, endpoint string, db string) *spanner.Client {
+	t.Helper()
+
+	client, err := spanner.NewClient(ctx, db,
+		option.WithEndpoint(endpoint),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		option.WithGRPCDialOption(grpc.WithStreamInterceptor(b.intercept)),
+		option.WithoutAuthentication(),
+		internaloption.SkipDialSettingsValidation(),
+	)
+	if err != nil {
+		t.Fatalf("Unable to create spanner client: %v", err)
+	}
+
+	t.Cleanup(client.Close)
+
+	return client
+}
+
+// batchWriteStream is the stream of a batch write, which it serves when the request is sent.
+type batchWriteStream struct {
+	grpc.ClientStream
+	ctx       context.Context
+	cc        *grpc.ClientConn
+	writer    *batchWriter
+	responses []*spannerpb.BatchWriteResponse
+}
+
+func (s *batchWriteStream) SendMsg(m any) error {
+	req := m.(*spannerpb.BatchWriteRequest)
+
+	s.writer.mu.Lock()
+	s.writer.requests = append(s.writer.requests, len(req.GetMutationGroups()))
+	s.writer.mu.Unlock()
+
+	for i, group := range req.GetMutationGroups() {
+		resp := &spannerpb.BatchWriteResponse{Indexes: []int32{int32(i)}, Status: &statuspb.Status{}}
+		if commit, err := s.commit(req.GetSession(), group); err != nil {
+			resp.Status = status.Convert(err).Proto()
+		} else {
+			resp.CommitTimestamp = commit.GetCommitTimestamp()
+		}
+		s.responses = append(s.responses, resp)
+	}
+
+	return nil
+}
+
+// commit commits the group in a read-write transaction.
+func (s *batchWriteStream) commit(session string, group *MutationGroup) (*spannerpb.CommitResponse, error) {
+	tx := &spannerpb.Transaction{}
+	err := s.cc.Invoke(s.ctx, "/google.spanner.v1.Spanner/BeginTransaction", &spannerpb.BeginTransactionRequest{
+		Session: session,
+		Options: &spannerpb.TransactionOptions{
+			Mode: &spannerpb.TransactionOptions_ReadWrite_{ReadWrite: &spannerpb.TransactionOptions_ReadWrite{}},
+		},
+	}, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &spannerpb.CommitResponse{}
+	err = s.cc.Invoke(s.ctx, "/google.spanner.v1.Spanner/Commit", &spannerpb.CommitRequest{
+		Session:     session,
+		Transaction: &spannerpb.CommitRequest_TransactionId{TransactionId: tx.GetId()},
+		Mutations:   group.GetMutations(),
+	}, resp)
+
+	return resp, err
+}
+
+func (s *batchWriteStream) RecvMsg(m any) error {
+	if len(s.responses) == 0 {
+		return io.EOF
+	}
+
+	proto.Merge(m.(*spannerpb.BatchWriteResponse), s.responses[0])
+	s.responses = s.responses[1:]
+
+	return nil
+}
+
+func (s *batchWriteStream) CloseSend() error {
+	return nil
+}
+
+func (s *batchWriteStream) Header() (metadata.MD, error) {
+	return nil, nil
+}
+
+func (s *batchWriteStream) Trailer() metadata.MD {
+	return nil
+}
+
+func (s *batchWriteStream) Context() context.Context {
+	return s.ctx
+}
+
+func insertMutation(table string, one string, two string) *spannerpb.Mutation {
+	return &spannerpb.Mutation{
+		Operation: &spannerpb.Mutation_InsertOrUpdate{
+			InsertOrUpdate: &spannerpb.Mutation_Write{
+				Table:   table,
+				Columns: []string{"Two", "One"},
+				Values: []*structpb.ListValue{{
+					Values: []*structpb.Value{structpb.NewStringValue(two), structpb.NewStringValue(one)},
+				}},
+			},
+		},
+	}
+}
+
+func deleteMutation(table string, two string) *spannerpb.Mutation {
+	return &spannerpb.Mutation{
+		Operation: &spannerpb.Mutation_Delete_{
+			Delete: &spannerpb.Mutation_Delete{
+				Table: table,
+				KeySet: &spannerpb.KeySet{
+					Keys: []*structpb.ListValue{{Values: []*structpb.Value{structpb.NewStringValue(two)}}},
+				},
+			},
+		},
+	}
+}
+
+func insertOnlyMutation(table string, one string, two string) *spannerpb.Mutation {
+	return &spannerpb.Mutation{
+		Operation: &spannerpb.Mutation_Insert{
+			Insert: &spannerpb.Mutation_Write{
+				Table:   table,
+				Columns: []string{"Two", "One"},
+				Values: []*structpb.ListValue{{
+					Values: []*structpb.Value{structpb.NewStringValue(two), structpb.NewStringValue(one)},
+				}},
+			},
+		},
+	}
+}
+
+// int64Splits returns the splits of a table with an INT64 primary key column Two that start at the given keys.
+func int64Splits(points ...string) *tableSplits {
+	splits := &tableSplits{
+		columns: []keyColumn{{Name: "Two"}},
+		types:   []*spannerpb.Type{{Code: spannerpb.TypeCode_INT64}},
+	}
+	for _, p := range points {
+		splits.points = append(splits.points, []*structpb.Value{structpb.NewStringValue(p)})
+	}
+	return splits
+}
+
+// writeMutationGroups keys the groups by split and writes the groups of each split, in the order of their first groups,
+// and returns the written and the failed groups.
+func writeMutationGroups(ctx context.Context, t *testing.T, keyFn *keyMutationGroupsFn, fn *writeMutationGroupsFn, groups []*MutationGroup) ([]*MutationGroup, []*MutationGroup) {
+	t.Helper()
+
+	var keys []splitKey
+	splits := make(map[splitKey][]*MutationGroup)
+	for _, g := range groups {
+		err := keyFn.ProcessElement(ctx, g, func(key splitKey, g *MutationGroup) {
+			if _, ok := splits[key]; !ok {
+				keys = append(keys, key)
+			}
+			splits[key] = append(splits[key], g)
+		})
+		if err != nil {
+			t.Fatalf("keyMutationGroupsFn.ProcessElement() failed: %v", err)
+		}
+	}
+
+	var written, failed []*MutationGroup
+	emit := func(g *MutationGroup, commit time.Time) {
+		if commit.IsZero() {
+			t.Errorf("Got no commit timestamp for mutation group %v", g)
+		}
+		written = append(written, g)
+	}
+	emitFailed := func(g *MutationGroup, st *statuspb.Status) {
+		if codes.Code(st.GetCode()) == codes.OK {
+			t.Errorf("Got OK status for failed mutation group %v", g)
+		}
+		failed = append(failed, g)
+	}
+
+	for _, key := range keys {
+		iter := func(g **MutationGroup) bool {
+			if len(splits[key]) == 0 {
+				return false
+			}
+			*g, splits[key] = splits[key][0], splits[key][1:]
+			return true
+		}
+		if err := fn.ProcessElement(ctx, key, iter, emit, emitFailed); err != nil {
+			t.Fatalf("writeMutationGroupsFn.ProcessElement() failed: %v", err)
+		}
+	}
+
+	return written, failed
+}
+
+func countRows(ctx context.Context, t *testing.T, client *spanner.Client, table string) int {
+	t.Helper()
+
+	it := client.Single().Query(ctx, spanner.Statement{SQL: "SELECT * FROM " + table})
+	defer it.Stop()
+
+	count := 0
+	for {
+		_, err := it.Next()
+		if err == iterator.Done {
+			return count
+		}
+		if err != nil {
+			t.Fatalf("Querying %v: %v", table, err)
+		}
+		count++
+	}
+}
+
+func TestWriteMutationGroups(t *testing.T) {
+	ctx := context.Background()
+	database := "projects/fake-proj/instances/fake-instance/databases/fake-db-mutation-groups"
+
+	srv := newServer(t)
+	adminClient := spannertest.NewAdminClient(ctx, t, srv.Addr)
+	writer := &batchWriter{}
+	client := writer.newClient(ctx, t, srv.Addr, database)
+
+	spannertest.CreateTable(ctx, t, adminClient, database, []string{
+		`CREATE TABLE GroupsA (
+			One STRING(20),
+			Two INT64,
+		) PRIMARY KEY (Two)`,
+		`CREATE TABLE GroupsB (
+			One STRING(20),
+			Two INT64,
+		) PRIMARY KEY (Two)`,
+	})
+
+	groups := []*MutationGroup{
+		NewMutationGroup(insertMutation("GroupsA", "one", "1")),
+		NewMutationGroup(insertMutation("GroupsB", "one", "1")),
+		NewMutationGroup(insertMutation("GroupsB", "two", "2"), insertMutation("GroupsB", "three", "3")),
+		NewMutationGroup(insertMutation("GroupsA", "three", "3"), deleteMutation("GroupsA", "1")),
+	}
+
+	// The fake spanner has no splits, so GroupsB is split at key 2, and the groups of GroupsA are written together
+	// although they are not consecutive.
+	keyFn := &keyMutationGroupsFn{tables: map[string]*tableSplits{
+		"GroupsA": int64Splits(),
+		"GroupsB": int64Splits("2"),
+	}}
+	fn := newWriteMutationGroupsFn(database, UseBatchSize(4))
+	fn.client = client
+
+	written, failed := writeMutationGroups(ctx, t, keyFn, fn, groups)
+
+	if len(written) != len(groups) || len(failed) != 0 {
+		t.Errorf("Got %v written and %v failed mutation groups, want %v written", len(written), len(failed), len(groups))
+	}
+	if diff := cmp.Diff([]int{2, 1, 1}, writer.requests); diff != "" {
+		t.Errorf("Batch write sizes mismatch (-want +got):\n%s", diff)
+	}
+	for table, want := range map[string]int{"GroupsA": 1, "GroupsB": 3} {
+		if got := countRows(ctx, t, client, table); got != want {
+			t.Errorf("Got %v rows in %v, want %v", got, table, want)
+		}
+	}
+}
+
+func TestWriteMutationGroups_failedGroup(t *testing.T) {
+	ctx := context.Background()
+	database := "projects/fake-proj/instances/fake-instance/databases/fake-db-failed-mutation-groups"
+
+	srv := newServer(t)
+	adminClient := spannertest.NewAdminClient(ctx, t, srv.Addr)
+	writer := &batchWriter{}
+	client := writer.newClient(ctx, t, srv.Addr, database)
+
+	spannertest.CreateTable(ctx, t, adminClient, database, []string{
+		`CREATE TABLE Groups (
+			One STRING(20),
+			Two INT64,
+		) PRIMARY KEY (Two)`,
+	})
+
+	// The second group fails as its first row already exists, while the other groups written in the same batch write
+	// are committed.
+	duplicate := NewMutationGroup(insertOnlyMutation("Groups", "one", "1"), insertOnlyMutation("Groups", "three", "3"))
+	groups := []*MutationGroup{
+		NewMutationGroup(insertOnlyMutation("Groups", "one", "1"), insertOnlyMutation("Groups", "two", "2")),
+		duplicate,
+		NewMutationGroup(insertOnlyMutation("Groups", "four", "4")),
+	}
+
+	keyFn := &keyMutationGroupsFn{tables: map[string]*tableSplits{"Groups": int64Splits()}}
+	fn := newWriteMutationGroupsFn(database)
+	fn.client = client
+
+	written, failed := writeMutationGroups(ctx, t, keyFn, fn, groups)
+
+	if len(written) != 2 {
+		t.Errorf("Got %v written mutation groups, want 2", len(written))
+	}
+	if len(failed) != 1 || !proto.Equal(failed[0], duplicate) {
+		t.Errorf("Got failed mutation groups %v, want %v", failed, duplicate)
+	}
+	if diff := cmp.Diff([]int{3}, writer.requests); diff != "" {
+		t.Errorf("Batch write sizes mismatch (-want +got):\n%s", diff)
+	}
+	if got := countRows(ctx, t, client, "Groups"); got != 3 {
+		t.Errorf("Got %v rows, want 3", got)
+	}
+}
@@ -18,11 +18,12 @@ package textio
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"os"
 	"reflect"
-	"strings"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/sdf"
@@ -41,13 +42,13 @@ func init() {
 	register.DoFn4x1[context.Context, *sdf.LockRTracker, fileio.ReadableFile, func(string, string), error](&readWNameFn{})
 	register.Emitter2[string, string]()
 
-	beam.RegisterType(reflect.TypeOf((*writeFileFn)(nil)).Elem())
-	register.DoFn3x1[context.Context, int, func(*string) bool, error](&writeFileFn{})
-	register.Iter1[string]()
+	beam.RegisterType(reflect.TypeOf((*textSink)(nil)).Elem())
 }
 
 type readOption struct {
-	FileOpts []fileio.ReadOptionFn
+	FileOpts        []fileio.ReadOptionFn
+	Delimiter       string
+	SkipHeaderLines int
 }
 
 // ReadOptionFn is a function that can be passed to Read or ReadAll to configure options for
@@ -68,6 +69,15 @@ func ReadGzip() ReadOptionFn {
 	}
 }
 
+// ReadCompression specifies that files have been compressed with the given
+// compression, such as fileio.CompressionZstd, which must be registered with
+// fileio.RegisterCompression.
+func ReadCompression(name string) ReadOptionFn {
+	return func(o *readOption) {
+		o.FileOpts = append(o.FileOpts, fileio.ReadCompression(name))
+	}
+}
+
 // ReadUncompressed specifies that files have not been compressed.
 func ReadUncompressed() ReadOptionFn {
 	return func(o *readOption) {
@@ -75,15 +85,35 @@ func ReadUncompressed() ReadOptionFn {
 	}
 }
 
+// ReadDelimiter specifies the delimiter of the lines, such as "\r\n" or
+// "\x1e", instead of a newline. The delimiter may be several bytes long.
+func ReadDelimiter(delim string) ReadOptionFn {
+	return func(o *readOption) {
+		o.Delimiter = delim
+	}
+}
+
+// ReadSkipHeaderLines specifies the number of lines at the start of each file
+// that are skipped, such as the header of a CSV file.
+func ReadSkipHeaderLines(n int) ReadOptionFn {
+	return func(o *readOption) {
+		o.SkipHeaderLines = n
+	}
+}
+
 // Read reads a set of files indicated by the glob pattern and returns
 // the lines as a PCollection<string>. The newlines are not part of the lines.
 // Read accepts a variadic number of ReadOptionFn that can be used to configure the compression
-// type of the file. By default, the compression type is determined by the file extension.
+// type of the file, the delimiter of the lines and the number of header lines to skip. By default,
+// the compression type is determined by the file extension.
+//
+// Uncompressed files are split into blocks that are read in parallel. Compressed files cannot be
+// split, and are read as a whole.
 func Read(s beam.Scope, glob string, opts ...ReadOptionFn) beam.PCollection {
 	s = s.Scope("textio.Read")
 
 	filesystem.ValidateScheme(glob)
-	return read(s, &readFn{}, beam.Create(s, glob), opts...)
+	return read(s, false, beam.Create(s, glob), opts...)
 }
 
 // ReadAll expands and reads the filename given as globs by the incoming
@@ -93,7 +123,7 @@ func Read(s beam.Scope, glob string, opts ...ReadOptionFn) beam.PCollection {
 // type of the files. By default, the compression type is determined by the file extension.
 func ReadAll(s beam.Scope, col beam.PCollection, opts ...ReadOptionFn) beam.PCollection {
 	s = s.Scope("textio.ReadAll")
-	return read(s, &readFn{}, col, opts...)
+	return read(s, false, col, opts...)
 }
 
 // ReadWithFilename reads a set of files indicated by the glob pattern and returns
@@ -104,7 +134,7 @@ func ReadWithFilename(s beam.Scope, glob string, opts ...ReadOptionFn) beam.PCol
 	s = s.Scope("textio.ReadWithFilename")
 
 	filesystem.ValidateScheme(glob)
-	return read(s, &readWNameFn{}, beam.Create(s, glob), opts...)
+	return read(s, true, beam.Create(s, glob), opts...)
 }
 
 // ReadSdf is a variation of Read implemented via SplittableDoFn. This should
@@ -115,7 +145,7 @@ func ReadSdf(s beam.Scope, glob string) beam.PCollection {
 	s = s.Scope("textio.ReadSdf")
 
 	filesystem.ValidateScheme(glob)
-	return read(s, &readFn{}, beam.Create(s, glob), ReadUncompressed())
+	return read(s, false, beam.Create(s, glob), ReadUncompressed())
 }
 
 // ReadAllSdf is a variation of ReadAll implemented via SplittableDoFn. This
@@ -124,20 +154,30 @@ func ReadSdf(s beam.Scope, glob string) beam.PCollection {
 // Deprecated: Use ReadAll instead, which has been migrated to use this SDF implementation.
 func ReadAllSdf(s beam.Scope, col beam.PCollection) beam.PCollection {
 	s = s.Scope("textio.ReadAllSdf")
-	return read(s, &readFn{}, col, ReadUncompressed())
+	return read(s, false, col, ReadUncompressed())
 }
 
-// read takes a PCollection of globs, finds all matching files, and applies
-// the given DoFn on the files.
-func read(s beam.Scope, dofn any, col beam.PCollection, opts ...ReadOptionFn) beam.PCollection {
-	option := &readOption{}
+// read takes a PCollection of globs, finds all matching files, and reads
+// their lines, keyed by filename if withFilename is set.
+func read(s beam.Scope, withFilename bool, col beam.PCollection, opts ...ReadOptionFn) beam.PCollection {
+	option := &readOption{Delimiter: "\n"}
 	for _, opt := range opts {
 		opt(option)
 	}
+	if option.Delimiter == "" {
+		panic("textio: empty delimiter")
+	}
+	if option.SkipHeaderLines < 0 {
+		panic(fmt.Sprintf("textio: invalid number of header lines %v", option.SkipHeaderLines))
+	}
 
 	matches := fileio.MatchAll(s, col, fileio.MatchEmptyAllow())
 	files := fileio.ReadMatches(s, matches, option.FileOpts...)
-	return beam.ParDo(s, dofn, files)
+	base := readBaseFn{Delimiter: option.Delimiter, SkipHeaderLines: option.SkipHeaderLines}
+	if withFilename {
+		return beam.ParDo(s, &readWNameFn{readBaseFn: base}, files)
+	}
+	return beam.ParDo(s, &readFn{readBaseFn: base}, files)
 }
 
 // consumer is an interface for consuming a string value.
@@ -168,11 +208,19 @@ func (e *kvEmitter) Consume(value string) {
 // a text file and reading individual lines. A struct that embeds readBaseFn
 // and also implements ProcessElement will serve as a complete SDF.
 type readBaseFn struct {
+	// Delimiter is the delimiter of the lines.
+	Delimiter string `json:"delimiter"`
+	// SkipHeaderLines is the number of lines skipped at the start of each file.
+	SkipHeaderLines int `json:"skipHeaderLines"`
 }
 
 // CreateInitialRestriction creates an offset range restriction representing
-// the file's size in bytes.
+// the file's size in bytes. Compressed files can't be split, so they are
+// represented by the single offset 0, which stands for the whole file.
 func (fn *readBaseFn) CreateInitialRestriction(file fileio.ReadableFile) offsetrange.Restriction {
+	if file.IsCompressed() {
+		return offsetrange.Restriction{Start: 0, End: 1}
+	}
 	return offsetrange.Restriction{
 		Start: 0,
 		End:   file.Metadata.Size,
@@ -203,8 +251,12 @@ func (fn *readBaseFn) SplitRestriction(_ fileio.ReadableFile, rest offsetrange.R
 	return splits
 }
 
-// RestrictionSize returns the size of each restriction as its range.
-func (fn *readBaseFn) RestrictionSize(_ fileio.ReadableFile, rest offsetrange.Restriction) float64 {
+// RestrictionSize returns the size of each restriction as its range, or the
+// size of the file for compressed files.
+func (fn *readBaseFn) RestrictionSize(file fileio.ReadableFile, rest offsetrange.Restriction) float64 {
+	if file.IsCompressed() {
+		return float64(file.Metadata.Size)
+	}
 	return rest.Size()
 }
 
@@ -221,68 +273,207 @@ func (fn *readBaseFn) CreateTracker(rest offsetrange.Restriction) *sdf.LockRTrac
 // before the restriction and end within it (those are ignored), and lines can
 // begin within the restriction and past the restriction (those are entirely
 // output, including the portion outside the restriction). In some cases a
-// valid restriction might not output any lines.
+// valid restriction might not output any lines. A line begins at the start of
+// the file or right after a delimiter, even if the delimiter itself begins
+// before the restriction.
 func (fn *readBaseFn) process(ctx context.Context, rt *sdf.LockRTracker, file fileio.ReadableFile, consumer consumer) error {
 	log.Infof(ctx, "Reading from %v", file.Metadata.Path)
 
+	if file.IsCompressed() {
+		return fn.processWhole(ctx, rt, file, consumer)
+	}
+
+	rest := rt.GetRestriction().(offsetrange.Restriction)
+	start := rest.Start
+	if fn.SkipHeaderLines > 0 && start > 0 {
+		// Lines within the header are skipped, so the restriction effectively
+		// starts at the end of the header if it starts within it.
+		headerEnd, err := fn.headerEnd(ctx, file)
+		if err != nil {
+			return err
+		}
+		if start <= headerEnd {
+			start = headerEnd
+		}
+	}
+
 	fd, err := file.Open(ctx)
 	if err != nil {
 		return err
 	}
 	defer fd.Close()
 
-	rd := bufio.NewReader(fd)
-
-	i := rt.GetRestriction().(offsetrange.Restriction).Start
-	if i > 0 {
+	rd := newLineReader(fd, fn.Delimiter)
+
+	var i int64
+	switch {
+	case rest.Start == 0:
+		// The restriction starts at the start of the file, so its lines are
+		// only preceded by the header.
+		for j := 0; j < fn.SkipHeaderLines; j++ {
+			_, n, err := rd.next()
+			i += int64(n)
+			if err == io.EOF {
+				// Finish claiming restriction before returning to avoid errors.
+				rt.TryClaim(rest.End)
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	case start > rest.Start:
+		// The restriction starts within the header, so the first line begins
+		// at the end of the header.
+		if err := discard(rd, start); err != nil {
+			return err
+		}
+		i = start
+	default:
 		// If restriction's starts after 0, we cannot assume a new line starts
 		// at the beginning of the restriction, so we must search for the first
 		// line beginning at or after restriction.Start. This is done by
-		// scanning to the byte just before the restriction and then reading
-		// until the next newline, leaving the reader at the start of a new
-		// line past restriction.Start.
-		i--
-		n, err := rd.Discard(int(i)) // Scan to just before restriction.
-		if err == io.EOF {
-			return errors.Errorf("TextIO restriction lies outside the file being read. "+
-				"Restriction begins at %v bytes, but file is only %v bytes.", i+1, n)
+		// scanning to the first byte that a delimiter ending at or after the
+		// restriction may begin at, and then reading until the next
+		// delimiter, leaving the reader at the start of a new line past
+		// restriction.Start.
+		i = start - int64(len(fn.Delimiter))
+		if i < 0 {
+			i = 0
 		}
-		if err != nil {
+		if err := discard(rd, i); err != nil {
 			return err
 		}
-		line, err := rd.ReadString('\n') // Read until the first line within the restriction.
+		_, n, err := rd.next() // Read until the first line within the restriction.
 		if err == io.EOF {
 			// No lines start in the restriction but it's still valid, so
 			// finish claiming before returning to avoid errors.
-			rt.TryClaim(rt.GetRestriction().(offsetrange.Restriction).End)
+			rt.TryClaim(rest.End)
 			return nil
 		}
 		if err != nil {
 			return err
 		}
-		i += int64(len(line))
+		i += int64(n)
 	}
 
 	// Claim each line until we claim a line outside the restriction.
 	for rt.TryClaim(i) {
-		line, err := rd.ReadString('\n')
+		line, n, err := rd.next()
 		if err == io.EOF {
 			if len(line) != 0 {
-				consumer.Consume(strings.TrimSuffix(line, "\n"))
+				consumer.Consume(line)
 			}
 			// Finish claiming restriction before breaking to avoid errors.
-			rt.TryClaim(rt.GetRestriction().(offsetrange.Restriction).End)
+			rt.TryClaim(rest.End)
 			break
 		}
 		if err != nil {
 			return err
 		}
-		consumer.Consume(strings.TrimSuffix(line, "\n"))
-		i += int64(len(line))
+		consumer.Consume(line)
+		i += int64(n)
+	}
+	return nil
+}
+
+// processWhole processes all lines of a compressed file, which has a single
+// offset restriction.
+func (fn *readBaseFn) processWhole(ctx context.Context, rt *sdf.LockRTracker, file fileio.ReadableFile, consumer consumer) error {
+	rest := rt.GetRestriction().(offsetrange.Restriction)
+	if !rt.TryClaim(rest.Start) {
+		return nil
+	}
+
+	fd, err := file.Open(ctx)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	rd := newLineReader(fd, fn.Delimiter)
+	for j := 0; ; j++ {
+		line, _, err := rd.next()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if j >= fn.SkipHeaderLines && (err == nil || len(line) != 0) {
+			consumer.Consume(line)
+		}
+		if err == io.EOF {
+			break
+		}
 	}
+	// Finish claiming restriction to avoid errors.
+	rt.TryClaim(rest.End)
 	return nil
 }
 
+// headerEnd returns the offset of the first line after the header.
+func (fn *readBaseFn) headerEnd(ctx context.Context, file fileio.ReadableFile) (int64, error) {
+	fd, err := file.Open(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer fd.Close()
+
+	rd := newLineReader(fd, fn.Delimiter)
+	var end int64
+	for j := 0; j < fn.SkipHeaderLines; j++ {
+		_, n, err := rd.next()
+		end += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return end, nil
+}
+
+// discard skips the first n bytes of the reader.
+func discard(rd *lineReader, n int64) error {
+	m, err := rd.Discard(int(n))
+	if err == io.EOF {
+		return errors.Errorf("TextIO restriction lies outside the file being read. "+
+			"Restriction begins at %v bytes, but file is only %v bytes.", n, m)
+	}
+	return err
+}
+
+// lineReader reads the lines of a file, separated by a delimiter.
+type lineReader struct {
+	*bufio.Reader
+	delim []byte
+	buf   []byte
+}
+
+func newLineReader(r io.Reader, delim string) *lineReader {
+	return &lineReader{Reader: bufio.NewReader(r), delim: []byte(delim)}
+}
+
+// next returns the next line without its delimiter, and the number of bytes
+// read including the delimiter. At the end of the file, it returns the
+// remaining bytes, which may be empty, and io.EOF.
+func (r *lineReader) next() (string, int, error) {
+	r.buf = r.buf[:0]
+	last := r.delim[len(r.delim)-1]
+	for {
+		b, err := r.ReadSlice(last)
+		r.buf = append(r.buf, b...)
+		switch err {
+		case nil:
+			if bytes.HasSuffix(r.buf, r.delim) {
+				return string(r.buf[:len(r.buf)-len(r.delim)]), len(r.buf), nil
+			}
+		case bufio.ErrBufferFull:
+		default:
+			return string(r.buf), len(r.buf), err
+		}
+	}
+}
+
 // readFn is an SDF that emits individual lines from a text file.
 type readFn struct {
 	readBaseFn
@@ -302,60 +493,41 @@ func (fn *readWNameFn) ProcessElement(ctx context.Context, rt *sdf.LockRTracker,
 	return fn.process(ctx, rt, file, &kvEmitter{Key: file.Metadata.Path, Emit: emit})
 }
 
-// TODO(herohde) 7/12/2017: extend Write to write to a series of files
-// as well as allow sharding.
-
 // Write writes a PCollection<string> to a file as separate lines. The
-// writer add a newline after each element.
-func Write(s beam.Scope, filename string, col beam.PCollection) {
+// writer add a newline after each element. It returns a PCollection<string>
+// with the names of the written files. Write accepts a variadic number of
+// fileio.WriteOptionFn that can be used to configure how the files are
+// written. By default, a single file is written per window, so filename is
+// its name for a globally windowed PCollection. The lines can be written to
+// several files in parallel with fileio.WriteNumShards. Filenames are formed
+// as described in fileio.WriteFiles.
+func Write(s beam.Scope, filename string, col beam.PCollection, opts ...fileio.WriteOptionFn) beam.PCollection {
 	s = s.Scope("textio.Write")
 
-	filesystem.ValidateScheme(filename)
-
-	// NOTE(BEAM-3579): We may never call Teardown for non-local runners and
-	// FinishBundle doesn't have the right granularity. We therefore
-	// perform a GBK with a fixed key to get all values in a single invocation.
-
-	// TODO(BEAM-3860) 3/15/2018: use side input instead of GBK.
-
-	pre := beam.AddFixedKey(s, col)
-	post := beam.GroupByKey(s, pre)
-	beam.ParDo0(s, &writeFileFn{Filename: filename}, post)
+	opts = append([]fileio.WriteOptionFn{fileio.WriteNumShards(1)}, opts...)
+	return fileio.WriteFiles(s, filename, &textSink{}, col, opts...)
 }
 
-type writeFileFn struct {
-	Filename string `json:"filename"`
+// textSink writes lines to a file.
+type textSink struct {
+	w io.Writer
 }
 
-func (w *writeFileFn) ProcessElement(ctx context.Context, _ int, lines func(*string) bool) error {
-	fs, err := filesystem.New(ctx, w.Filename)
-	if err != nil {
-		return err
-	}
-	defer fs.Close()
+func (t *textSink) Open(_ context.Context, w io.Writer) error {
+	t.w = w
+	return nil
+}
 
-	fd, err := fs.OpenWrite(ctx, w.Filename)
-	if err != nil {
+func (t *textSink) Write(_ context.Context, elm any) error {
+	if _, err := io.WriteString(t.w, elm.(string)); err != nil {
 		return err
 	}
-	buf := bufio.NewWriterSize(fd, 1<<20) // use 1MB buffer
-
-	log.Infof(ctx, "Writing to %v", w.Filename)
-
-	var line string
-	for lines(&line) {
-		if _, err := buf.WriteString(line); err != nil {
-			return err
-		}
-		if _, err := buf.Write([]byte{'\n'}); err != nil {
-			return err
-		}
-	}
+	_, err := t.w.Write([]byte{'\n'})
+	return err
+}
 
-	if err := buf.Flush(); err != nil {
-		return err
-	}
-	return fd.Close()
+func (t *textSink) Flush(_ context.Context) error {
+	return nil
 }
 
 // Immediate reads a local file at pipeline construction-time and embeds the
@@ -17,16 +17,22 @@
 package textio
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"errors"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/fileio"
 	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/local"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/rtrackers/offsetrange"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
 	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestMain(m *testing.M) {
@@ -163,3 +169,77 @@ func TestReadAllSdf(t *testing.T) {
 
 	ptest.RunAndValidate(t, p)
 }
+
+func TestWriteCompression(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "text.txt")
+	p, s := beam.NewPipelineWithRoot()
+	Write(s, out, beam.Create(s, "hello", "go"), fileio.WriteCompression(fileio.CompressionZstd))
+	ptest.RunAndValidate(t, p)
+
+	p, s = beam.NewPipelineWithRoot()
+	got := Read(s, out+".zst")
+	passert.Equals(s, got, "hello", "go")
+	ptest.RunAndValidate(t, p)
+}
+
+type collector struct {
+	lines []string
+}
+
+func (c *collector) Consume(value string) {
+	c.lines = append(c.lines, value)
+}
+
+// TestReadBaseFn_Splits tests that the lines of a file are read exactly once
+// when the file is split at any offset, including within a delimiter.
+func TestReadBaseFn_Splits(t *testing.T) {
+	const content = "header\r\nfirst\r\n\r\nsecond line\r\nlast"
+	want := []string{"first", "", "second line", "last"}
+
+	path := filepath.Join(t.TempDir(), "in.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file := fileio.ReadableFile{Metadata: fileio.FileMetadata{Path: path, Size: int64(len(content))}}
+	fn := &readBaseFn{Delimiter: "\r\n", SkipHeaderLines: 1}
+
+	for split := int64(0); split <= int64(len(content)); split++ {
+		c := &collector{}
+		for _, rest := range []offsetrange.Restriction{{Start: 0, End: split}, {Start: split, End: int64(len(content))}} {
+			rt := fn.CreateTracker(rest)
+			if err := fn.process(context.Background(), rt, file, c); err != nil {
+				t.Fatalf("process(%v) failed: %v", rest, err)
+			}
+			if !rt.IsDone() {
+				t.Errorf("process(%v) didn't claim the whole restriction", rest)
+			}
+		}
+		if !cmp.Equal(c.lines, want) {
+			t.Errorf("process() split at %v read %q, want %q", split, c.lines, want)
+		}
+	}
+}
+
+func TestRead_DelimiterAndHeader(t *testing.T) {
+	dir := t.TempDir()
+	content := "id\x1e1\x1e2\x1e3\x1e"
+	if err := os.WriteFile(filepath.Join(dir, "in.txt"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(content))
+	zw.Close()
+	if err := os.WriteFile(filepath.Join(dir, "in.gz"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"in.txt", "in.gz"} {
+		t.Run(name, func(t *testing.T) {
+			p, s := beam.NewPipelineWithRoot()
+			got := Read(s, filepath.Join(dir, name), ReadDelimiter("\x1e"), ReadSkipHeaderLines(1))
+			passert.Equals(s, got, "1", "2", "3")
+			ptest.RunAndValidate(t, p)
+		})
+	}
+}
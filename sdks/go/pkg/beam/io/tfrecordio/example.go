@@ -0,0 +1,398 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tfrecordio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Feature is a feature of a tf.Example, which is a list of byte strings,
+// floats or integers. At most one of the lists is set.
+type Feature struct {
+	Bytes  [][]byte
+	Floats []float32
+	Int64s []int64
+}
+
+// Field numbers of the tf.Example protos, as defined in
+// tensorflow/core/example/example.proto and feature.proto.
+const (
+	exampleFeatures  protowire.Number = 1 // Example.features
+	featuresFeature  protowire.Number = 1 // Features.feature
+	mapKey           protowire.Number = 1
+	mapValue         protowire.Number = 2
+	featureBytesList protowire.Number = 1 // Feature.bytes_list
+	featureFloatList protowire.Number = 2 // Feature.float_list
+	featureInt64List protowire.Number = 3 // Feature.int64_list
+	listValue        protowire.Number = 1 // *List.value
+)
+
+// ParseExample parses a serialized tf.Example proto, such as a record of a
+// TFRecord file, into its features by name.
+func ParseExample(data []byte) (map[string]Feature, error) {
+	features := make(map[string]Feature)
+	err := forEachField(data, func(num protowire.Number, typ protowire.Type, b []byte) error {
+		if num != exampleFeatures || typ != protowire.BytesType {
+			return nil
+		}
+		return forEachField(b, func(num protowire.Number, typ protowire.Type, b []byte) error {
+			if num != featuresFeature || typ != protowire.BytesType {
+				return nil
+			}
+			return parseFeatureEntry(b, features)
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("parsing tf.Example: %w", err)
+	}
+	return features, nil
+}
+
+func parseFeatureEntry(data []byte, features map[string]Feature) error {
+	var key string
+	var f Feature
+	err := forEachField(data, func(num protowire.Number, typ protowire.Type, b []byte) error {
+		switch {
+		case num == mapKey && typ == protowire.BytesType:
+			key = string(b)
+		case num == mapValue && typ == protowire.BytesType:
+			// Feature is a oneof, so the last of its fields wins.
+			f = Feature{}
+			return forEachField(b, func(num protowire.Number, typ protowire.Type, b []byte) error {
+				if typ != protowire.BytesType {
+					return nil
+				}
+				switch num {
+				case featureBytesList:
+					f = Feature{Bytes: [][]byte{}}
+					return forEachField(b, func(num protowire.Number, typ protowire.Type, b []byte) error {
+						if num == listValue && typ == protowire.BytesType {
+							f.Bytes = append(f.Bytes, append([]byte{}, b...))
+						}
+						return nil
+					})
+				case featureFloatList:
+					f = Feature{Floats: []float32{}}
+					return forEachField(b, func(num protowire.Number, typ protowire.Type, b []byte) error {
+						if num != listValue {
+							return nil
+						}
+						switch typ {
+						case protowire.BytesType: // Packed.
+							if len(b)%4 != 0 {
+								return errors.New("invalid packed float list")
+							}
+							for ; len(b) > 0; b = b[4:] {
+								f.Floats = append(f.Floats, math.Float32frombits(binary.LittleEndian.Uint32(b)))
+							}
+						case protowire.Fixed32Type:
+							f.Floats = append(f.Floats, math.Float32frombits(binary.LittleEndian.Uint32(b)))
+						}
+						return nil
+					})
+				case featureInt64List:
+					f = Feature{Int64s: []int64{}}
+					return forEachField(b, func(num protowire.Number, typ protowire.Type, b []byte) error {
+						if num != listValue {
+							return nil
+						}
+						switch typ {
+						case protowire.BytesType: // Packed.
+							for len(b) > 0 {
+								v, n := protowire.ConsumeVarint(b)
+								if n < 0 {
+									return protowire.ParseError(n)
+								}
+								f.Int64s = append(f.Int64s, int64(v))
+								b = b[n:]
+							}
+						case protowire.VarintType:
+							v, _ := protowire.ConsumeVarint(b)
+							f.Int64s = append(f.Int64s, int64(v))
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	features[key] = f
+	return nil
+}
+
+// forEachField calls fn with the number, type and value of each field of a
+// serialized message. The value of a bytes field is its contents, and the
+// value of other fields is their encoding.
+func forEachField(data []byte, fn func(num protowire.Number, typ protowire.Type, b []byte) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		n = protowire.ConsumeFieldValue(num, typ, data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b := data[:n]
+		if typ == protowire.BytesType {
+			b, _ = protowire.ConsumeBytes(b)
+		}
+		if err := fn(num, typ, b); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// MarshalExample serializes the features as a tf.Example proto, with the
+// features in order of their names.
+func MarshalExample(features map[string]Feature) []byte {
+	names := make([]string, 0, len(features))
+	for name := range features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var fs []byte
+	for _, name := range names {
+		var entry []byte
+		entry = protowire.AppendTag(entry, mapKey, protowire.BytesType)
+		entry = protowire.AppendString(entry, name)
+		entry = protowire.AppendTag(entry, mapValue, protowire.BytesType)
+		entry = protowire.AppendBytes(entry, marshalFeature(features[name]))
+
+		fs = protowire.AppendTag(fs, featuresFeature, protowire.BytesType)
+		fs = protowire.AppendBytes(fs, entry)
+	}
+	var b []byte
+	b = protowire.AppendTag(b, exampleFeatures, protowire.BytesType)
+	return protowire.AppendBytes(b, fs)
+}
+
+func marshalFeature(f Feature) []byte {
+	var list []byte
+	var num protowire.Number
+	switch {
+	case f.Floats != nil:
+		num = featureFloatList
+		var packed []byte
+		for _, v := range f.Floats {
+			packed = protowire.AppendFixed32(packed, math.Float32bits(v))
+		}
+		list = appendPacked(list, packed)
+	case f.Int64s != nil:
+		num = featureInt64List
+		var packed []byte
+		for _, v := range f.Int64s {
+			packed = protowire.AppendVarint(packed, uint64(v))
+		}
+		list = appendPacked(list, packed)
+	default:
+		num = featureBytesList
+		for _, v := range f.Bytes {
+			list = protowire.AppendTag(list, listValue, protowire.BytesType)
+			list = protowire.AppendBytes(list, v)
+		}
+	}
+	var b []byte
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, list)
+}
+
+func appendPacked(b, packed []byte) []byte {
+	if len(packed) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, listValue, protowire.BytesType)
+	return protowire.AppendBytes(b, packed)
+}
+
+// DecodeExample parses a serialized tf.Example proto into the struct pointed
+// to by v. Each exported field is decoded from the feature named by its
+// tfexample tag, or else its name, and fields tagged with "-" are skipped.
+// Missing features leave their fields unchanged.
+//
+// Fields of type string and []byte are decoded from byte string features,
+// int, int32 and int64 from integer features, and float32 and float64 from
+// float features, which must have a single value. Slices of these types are
+// decoded from features with any number of values.
+func DecodeExample(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("tfrecordio.DecodeExample: v must be a pointer to a struct, got %T", v)
+	}
+	features, err := ParseExample(data)
+	if err != nil {
+		return err
+	}
+	rv = rv.Elem()
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, ok := featureName(sf)
+		if !ok {
+			continue
+		}
+		f, ok := features[name]
+		if !ok {
+			continue
+		}
+		if err := decodeFeature(rv.Field(i), f); err != nil {
+			return fmt.Errorf("tfrecordio.DecodeExample: decoding feature %q into field %v: %w", name, sf.Name, err)
+		}
+	}
+	return nil
+}
+
+// EncodeExample serializes the struct v, or a pointer to it, as a tf.Example
+// proto, with the field types and feature names of DecodeExample.
+func EncodeExample(v any) ([]byte, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("tfrecordio.EncodeExample: v must be a struct, got %T", v)
+	}
+	features := make(map[string]Feature)
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, ok := featureName(sf)
+		if !ok {
+			continue
+		}
+		f, err := encodeFeature(rv.Field(i))
+		if err != nil {
+			return nil, fmt.Errorf("tfrecordio.EncodeExample: encoding field %v: %w", sf.Name, err)
+		}
+		features[name] = f
+	}
+	return MarshalExample(features), nil
+}
+
+func featureName(sf reflect.StructField) (string, bool) {
+	if !sf.IsExported() {
+		return "", false
+	}
+	name := sf.Tag.Get("tfexample")
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = sf.Name
+	}
+	return name, true
+}
+
+var byteSlice = reflect.TypeOf([]byte(nil))
+
+func decodeFeature(v reflect.Value, f Feature) error {
+	t := v.Type()
+	if t == byteSlice || t.Kind() != reflect.Slice {
+		n := len(f.Bytes) + len(f.Floats) + len(f.Int64s)
+		if n != 1 {
+			return fmt.Errorf("feature has %v values, want 1", n)
+		}
+		s := reflect.New(reflect.SliceOf(t)).Elem()
+		if err := decodeFeature(s, f); err != nil {
+			return err
+		}
+		v.Set(s.Index(0))
+		return nil
+	}
+
+	et := t.Elem()
+	switch {
+	case et == byteSlice || et.Kind() == reflect.String:
+		if f.Bytes == nil {
+			return errors.New("feature is not a bytes list")
+		}
+		s := reflect.MakeSlice(t, len(f.Bytes), len(f.Bytes))
+		for i, b := range f.Bytes {
+			s.Index(i).Set(reflect.ValueOf(b).Convert(et))
+		}
+		v.Set(s)
+	case et.Kind() == reflect.Int || et.Kind() == reflect.Int32 || et.Kind() == reflect.Int64:
+		if f.Int64s == nil {
+			return errors.New("feature is not an int64 list")
+		}
+		s := reflect.MakeSlice(t, len(f.Int64s), len(f.Int64s))
+		for i, n := range f.Int64s {
+			if s.Index(i).OverflowInt(n) {
+				return fmt.Errorf("value %v overflows %v", n, et)
+			}
+			s.Index(i).SetInt(n)
+		}
+		v.Set(s)
+	case et.Kind() == reflect.Float32 || et.Kind() == reflect.Float64:
+		if f.Floats == nil {
+			return errors.New("feature is not a float list")
+		}
+		s := reflect.MakeSlice(t, len(f.Floats), len(f.Floats))
+		for i, x := range f.Floats {
+			s.Index(i).SetFloat(float64(x))
+		}
+		v.Set(s)
+	default:
+		return fmt.Errorf("unsupported type %v", t)
+	}
+	return nil
+}
+
+func encodeFeature(v reflect.Value) (Feature, error) {
+	t := v.Type()
+	if t == byteSlice || t.Kind() != reflect.Slice {
+		s := reflect.MakeSlice(reflect.SliceOf(t), 1, 1)
+		s.Index(0).Set(v)
+		return encodeFeature(s)
+	}
+
+	et := t.Elem()
+	switch {
+	case et == byteSlice || et.Kind() == reflect.String:
+		f := Feature{Bytes: make([][]byte, v.Len())}
+		for i := range f.Bytes {
+			f.Bytes[i] = v.Index(i).Convert(byteSlice).Bytes()
+		}
+		return f, nil
+	case et.Kind() == reflect.Int || et.Kind() == reflect.Int32 || et.Kind() == reflect.Int64:
+		f := Feature{Int64s: make([]int64, v.Len())}
+		for i := range f.Int64s {
+			f.Int64s[i] = v.Index(i).Int()
+		}
+		return f, nil
+	case et.Kind() == reflect.Float32 || et.Kind() == reflect.Float64:
+		f := Feature{Floats: make([]float32, v.Len())}
+		for i := range f.Floats {
+			f.Floats[i] = float32(v.Index(i).Float())
+		}
+		return f, nil
+	default:
+		return Feature{}, fmt.Errorf("unsupported type %v", t)
+	}
+}
@@ -0,0 +1,181 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tfrecordio
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/fileio"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+)
+
+func init() {
+	register.DoFn3x1[context.Context, fileio.FileRange, func([]byte), error](&readFn{})
+	register.Emitter1[[]byte]()
+}
+
+// blockSize is the desired size of the ranges of a file that are read in
+// parallel.
+var blockSize = fileio.DefaultBlockSize
+
+type readOption struct {
+	FileOpts []fileio.ReadOptionFn
+}
+
+// ReadOptionFn is a function that can be passed to Read or ReadAll to
+// configure options for reading files.
+type ReadOptionFn func(*readOption)
+
+// ReadFileOptions specifies options for reading the matched files, such as
+// their compression. By default, the compression is detected from the file
+// extension.
+func ReadFileOptions(opts ...fileio.ReadOptionFn) ReadOptionFn {
+	return func(o *readOption) {
+		o.FileOpts = append(o.FileOpts, opts...)
+	}
+}
+
+// Read reads a set of TFRecord files indicated by the glob pattern, and
+// returns a PCollection<[]byte> of their records. The CRCs of the records are
+// validated, and the read fails on corrupted or truncated files.
+//
+// Uncompressed files larger than a block are split into blocks that are read
+// in parallel. Each block reads the records that start within it, the first
+// of which is found by scanning from the start of the block for a header
+// with a valid length CRC, followed by data with a valid CRC and a valid
+// header or the end of the file. A record whose data contains a complete
+// framed record may in rare cases be mistaken for one at the start of a
+// block. Compressed files are read as a whole.
+func Read(s beam.Scope, glob string, opts ...ReadOptionFn) beam.PCollection {
+	s = s.Scope("tfrecordio.Read")
+
+	filesystem.ValidateScheme(glob)
+	return read(s, beam.Create(s, glob), opts...)
+}
+
+// ReadAll expands and reads the filenames given as globs by the incoming
+// PCollection<string>, as Read does.
+func ReadAll(s beam.Scope, col beam.PCollection, opts ...ReadOptionFn) beam.PCollection {
+	s = s.Scope("tfrecordio.ReadAll")
+	return read(s, col, opts...)
+}
+
+func read(s beam.Scope, col beam.PCollection, opts ...ReadOptionFn) beam.PCollection {
+	option := &readOption{}
+	for _, opt := range opts {
+		opt(option)
+	}
+
+	matches := fileio.MatchAll(s, col, fileio.MatchEmptyAllow())
+	files := fileio.ReadMatches(s, matches, option.FileOpts...)
+	ranges := fileio.SplitFiles(s, files, blockSize)
+	return beam.ParDo(s, &readFn{}, ranges)
+}
+
+// readFn reads the records of a range of a file.
+type readFn struct{}
+
+func (fn *readFn) ProcessElement(ctx context.Context, r fileio.FileRange, emit func([]byte)) error {
+	start := r.Start
+	if start > 0 {
+		var err error
+		if start, err = fn.syncRecords(ctx, r.File, start, r.End); err != nil {
+			return fmt.Errorf("reading %v: %w", r.File.Metadata.Path, err)
+		}
+		if start >= r.End {
+			return nil
+		}
+	}
+
+	fd, err := r.File.OpenAt(ctx, start)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	rr := newRecordReader(fd, start)
+	for rr.offset < r.End {
+		data, err := rr.next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading %v: %w", r.File.Metadata.Path, err)
+		}
+		emit(data)
+	}
+	return nil
+}
+
+// syncRecords returns the offset of the first record of the file that starts
+// at or after offset and before end, or end if there is none.
+func (fn *readFn) syncRecords(ctx context.Context, file fileio.ReadableFile, offset, end int64) (int64, error) {
+	fd, err := file.OpenAt(ctx, offset)
+	if err != nil {
+		return 0, err
+	}
+	defer fd.Close()
+
+	br := bufio.NewReader(fd)
+	for ; offset < end; offset++ {
+		header, err := br.Peek(headerSize)
+		if errors.Is(err, io.EOF) {
+			return end, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if validHeader(header) {
+			ok, err := fn.isRecord(ctx, file, offset)
+			if err != nil {
+				return 0, err
+			}
+			if ok {
+				return offset, nil
+			}
+		}
+		if _, err := br.Discard(1); err != nil {
+			return 0, err
+		}
+	}
+	return end, nil
+}
+
+// isRecord reports whether a record starts at offset, which is when its data
+// has a valid CRC and it is followed by a valid header or the end of the
+// file.
+func (fn *readFn) isRecord(ctx context.Context, file fileio.ReadableFile, offset int64) (bool, error) {
+	fd, err := file.OpenAt(ctx, offset)
+	if err != nil {
+		return false, err
+	}
+	defer fd.Close()
+
+	rr := newRecordReader(fd, offset)
+	if _, err := rr.next(); err != nil {
+		return false, nil
+	}
+	if _, err := rr.readHeader(); err != nil && !errors.Is(err, io.EOF) {
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,164 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tfrecordio contains transforms for reading and writing TFRecord
+// files, and helpers for decoding tf.Example records.
+//
+// A TFRecord file is a sequence of records, each of which is framed as:
+//
+//	uint64 length
+//	uint32 masked CRC32C of length
+//	byte   data[length]
+//	uint32 masked CRC32C of data
+//
+// with integers in little-endian order. Files written by TensorFlow with the
+// GZIP or ZLIB compression options are read and written with
+// fileio.CompressionGzip and fileio.CompressionDeflate.
+package tfrecordio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"slices"
+)
+
+const headerSize = 12
+
+// maxRecordSize is the maximum length of the data of a record, which is the
+// size limit of a serialized protocol buffer.
+const maxRecordSize = math.MaxInt32
+
+// chunkSize is the size of the chunks that the data of a record is read in,
+// so that the memory for a record is only allocated as its data is read.
+const chunkSize = 1 << 20
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// maskedCRC returns the masked CRC32C of the data. CRCs are masked so that
+// the CRC of data that contains CRCs is robust.
+func maskedCRC(data []byte) uint32 {
+	crc := crc32.Checksum(data, crc32c)
+	return (crc>>15 | crc<<17) + 0xa282ead8
+}
+
+// writeRecord writes a framed record to w.
+func writeRecord(w io.Writer, data []byte) error {
+	if len(data) > maxRecordSize {
+		return fmt.Errorf("record length %v exceeds the maximum of %v", len(data), maxRecordSize)
+	}
+
+	var header [headerSize]byte
+	binary.LittleEndian.PutUint64(header[:8], uint64(len(data)))
+	binary.LittleEndian.PutUint32(header[8:], maskedCRC(header[:8]))
+	var footer [4]byte
+	binary.LittleEndian.PutUint32(footer[:], maskedCRC(data))
+
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	_, err := w.Write(footer[:])
+	return err
+}
+
+// recordReader reads framed records, and keeps track of the offset of the
+// next record.
+type recordReader struct {
+	r      *bufio.Reader
+	offset int64
+	header [headerSize]byte
+}
+
+func newRecordReader(r io.Reader, offset int64) *recordReader {
+	return &recordReader{r: bufio.NewReader(r), offset: offset}
+}
+
+// readHeader reads the header of the next record and returns the length of
+// its data. It returns io.EOF if there are no more records.
+func (rr *recordReader) readHeader() (int64, error) {
+	if _, err := io.ReadFull(rr.r, rr.header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, fmt.Errorf("truncated record header at offset %v", rr.offset)
+		}
+		return 0, err
+	}
+	if got, want := binary.LittleEndian.Uint32(rr.header[8:]), maskedCRC(rr.header[:8]); got != want {
+		return 0, fmt.Errorf("corrupted record length at offset %v: CRC %#x, want %#x", rr.offset, got, want)
+	}
+	length := binary.LittleEndian.Uint64(rr.header[:8])
+	if length > maxRecordSize {
+		return 0, fmt.Errorf("record length %v at offset %v exceeds the maximum of %v", length, rr.offset, maxRecordSize)
+	}
+	return int64(length), nil
+}
+
+// next reads the data of the next record and validates its CRC. It returns
+// io.EOF if there are no more records.
+func (rr *recordReader) next() ([]byte, error) {
+	length, err := rr.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	data, err := rr.readData(length)
+	if err != nil {
+		return nil, rr.truncated(err)
+	}
+	var footer [4]byte
+	if _, err := io.ReadFull(rr.r, footer[:]); err != nil {
+		return nil, rr.truncated(err)
+	}
+	if got, want := binary.LittleEndian.Uint32(footer[:]), maskedCRC(data); got != want {
+		return nil, fmt.Errorf("corrupted record data at offset %v: CRC %#x, want %#x", rr.offset, got, want)
+	}
+	rr.offset += headerSize + length + 4
+	return data, nil
+}
+
+// readData reads length bytes of data in chunks, so that a truncated record
+// fails before all of its length is allocated.
+func (rr *recordReader) readData(length int64) ([]byte, error) {
+	data := make([]byte, 0, min(length, chunkSize))
+	for int64(len(data)) < length {
+		n := min(length-int64(len(data)), chunkSize)
+		data = slices.Grow(data, int(n))
+		chunk := data[len(data) : len(data)+int(n)]
+		if _, err := io.ReadFull(rr.r, chunk); err != nil {
+			return nil, err
+		}
+		data = data[:len(data)+int(n)]
+	}
+	return data, nil
+}
+
+// validHeader reports whether header is the framing of a record, which is
+// when the CRC of its length is valid and the length is within the maximum.
+func validHeader(header []byte) bool {
+	return binary.LittleEndian.Uint32(header[8:]) == maskedCRC(header[:8]) &&
+		binary.LittleEndian.Uint64(header[:8]) <= maxRecordSize
+}
+
+func (rr *recordReader) truncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("truncated record at offset %v", rr.offset)
+	}
+	return err
+}
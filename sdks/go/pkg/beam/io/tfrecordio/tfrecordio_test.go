@@ -0,0 +1,298 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tfrecordio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/fileio"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/io/filesystem/local"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestMain(m *testing.M) {
+	ptest.Main(m)
+}
+
+func TestMaskedCRC(t *testing.T) {
+	// The CRC32C check value of "123456789" is 0xe3069283.
+	masked := maskedCRC([]byte("123456789"))
+	rot := masked - 0xa282ead8
+	if got, want := rot>>17|rot<<15, uint32(0xe3069283); got != want {
+		t.Errorf("maskedCRC() unmasked = %#x, want %#x", got, want)
+	}
+}
+
+func records() [][]byte {
+	return [][]byte{[]byte("first"), {}, bytes.Repeat([]byte("x"), 100), []byte("last")}
+}
+
+func writeRecords(t *testing.T, records [][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, r := range records {
+		if err := writeRecord(&buf, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestRecordReader(t *testing.T) {
+	data := writeRecords(t, records())
+
+	rr := newRecordReader(bytes.NewReader(data), 0)
+	var got [][]byte
+	for {
+		r, err := rr.next()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.Fatalf("next() failed: %v", err)
+			}
+			break
+		}
+		got = append(got, r)
+	}
+	if !cmp.Equal(got, records()) {
+		t.Errorf("next() read %q, want %q", got, records())
+	}
+	if rr.offset != int64(len(data)) {
+		t.Errorf("offset = %v, want %v", rr.offset, len(data))
+	}
+}
+
+// header returns the header of a record with the given length.
+func header(length uint64) []byte {
+	h := binary.LittleEndian.AppendUint64(nil, length)
+	return binary.LittleEndian.AppendUint32(h, maskedCRC(h))
+}
+
+func TestRecordReader_Corrupted(t *testing.T) {
+	data := writeRecords(t, records()[:1])
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"length", func() []byte { d := bytes.Clone(data); d[0]++; return d }()},
+		{"data", func() []byte { d := bytes.Clone(data); d[headerSize]++; return d }()},
+		{"truncated header", data[:headerSize-1]},
+		{"truncated data", data[:len(data)-1]},
+		{"too large", header(maxRecordSize + 1)},
+		{"truncated large record", header(maxRecordSize)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := newRecordReader(bytes.NewReader(test.data), 0)
+			if r, err := rr.next(); err == nil {
+				t.Errorf("next() = %q, want error", r)
+			}
+		})
+	}
+}
+
+func wantRecords() []any {
+	var want []any
+	for _, r := range records() {
+		want = append(want, r)
+	}
+	return want
+}
+
+func TestWriteRead(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []fileio.WriteOptionFn
+		glob string
+	}{
+		{"uncompressed", []fileio.WriteOptionFn{fileio.WriteNumShards(2)}, "out-*"},
+		{"gzip", []fileio.WriteOptionFn{fileio.WriteCompression(fileio.CompressionGzip)}, "out*.gz"},
+		{"zlib", []fileio.WriteOptionFn{fileio.WriteCompression(fileio.CompressionDeflate)}, "out*.deflate"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			p, s := beam.NewPipelineWithRoot()
+			files := Write(s, filepath.Join(dir, "out"), beam.Create(s, wantRecords()...), test.opts...)
+			passert.NonEmpty(s, files)
+			ptest.RunAndValidate(t, p)
+
+			p, s = beam.NewPipelineWithRoot()
+			passert.Equals(s, Read(s, filepath.Join(dir, test.glob)), wantRecords()...)
+			ptest.RunAndValidate(t, p)
+		})
+	}
+}
+
+func TestRead_Splits(t *testing.T) {
+	defer func(size int64) { blockSize = size }(blockSize)
+	blockSize = 10
+	path := filepath.Join(t.TempDir(), "in.tfrecord")
+	if err := os.WriteFile(path, writeRecords(t, records()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, s := beam.NewPipelineWithRoot()
+	passert.Equals(s, Read(s, path), wantRecords()...)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestReadFn_Ranges(t *testing.T) {
+	// The data of the last record frames a record, which is only read as
+	// part of it.
+	recs := append(records(), writeRecords(t, [][]byte{[]byte("nested")}))
+	data := writeRecords(t, recs)
+	path := filepath.Join(t.TempDir(), "in.tfrecord")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	file := fileio.ReadableFile{Metadata: fileio.FileMetadata{Path: path, Size: int64(len(data))}}
+
+	for split := int64(0); split <= int64(len(data)); split++ {
+		var got [][]byte
+		emit := func(r []byte) { got = append(got, r) }
+		fn := &readFn{}
+		for _, r := range []fileio.FileRange{
+			{File: file, Start: 0, End: split},
+			{File: file, Start: split, End: math.MaxInt64},
+		} {
+			if err := fn.ProcessElement(context.Background(), r, emit); err != nil {
+				t.Fatalf("ProcessElement(%v, %v) failed: %v", r.Start, r.End, err)
+			}
+		}
+		if !cmp.Equal(got, recs) {
+			t.Errorf("ProcessElement() split at %v read %q, want %q", split, got, recs)
+		}
+	}
+}
+
+func TestRead_Corrupted(t *testing.T) {
+	data := writeRecords(t, records())
+	data[len(data)-1]++
+	path := filepath.Join(t.TempDir(), "in.tfrecord")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, s := beam.NewPipelineWithRoot()
+	Read(s, path)
+	if err := ptest.Run(p); err == nil {
+		t.Error("Read() succeeded for a corrupted file, want error")
+	}
+}
+
+type example struct {
+	Label   int64     `tfexample:"label"`
+	Name    string    `tfexample:"name"`
+	Weights []float32 `tfexample:"weights"`
+	Image   []byte    `tfexample:"image/encoded"`
+	IDs     []int
+	Skipped string `tfexample:"-"`
+}
+
+func TestEncodeDecodeExample(t *testing.T) {
+	in := example{
+		Label:   -3,
+		Name:    "cat",
+		Weights: []float32{0.5, 1.5},
+		Image:   []byte{0, 1, 2},
+		IDs:     []int{},
+		Skipped: "x",
+	}
+	data, err := EncodeExample(in)
+	if err != nil {
+		t.Fatalf("EncodeExample() failed: %v", err)
+	}
+	var got example
+	if err := DecodeExample(data, &got); err != nil {
+		t.Fatalf("DecodeExample() failed: %v", err)
+	}
+	in.Skipped = ""
+	if !cmp.Equal(got, in) {
+		t.Errorf("DecodeExample() = %+v, want %+v", got, in)
+	}
+}
+
+func TestParseExample_Unpacked(t *testing.T) {
+	var floats, ints []byte
+	for _, v := range []float32{1, 2} {
+		floats = protowire.AppendTag(floats, listValue, protowire.Fixed32Type)
+		floats = protowire.AppendFixed32(floats, math.Float32bits(v))
+	}
+	ints = protowire.AppendTag(ints, listValue, protowire.VarintType)
+	ints = protowire.AppendVarint(ints, 7)
+
+	entry := func(name string, num protowire.Number, list []byte) []byte {
+		var feature, e, fs []byte
+		feature = protowire.AppendTag(feature, num, protowire.BytesType)
+		feature = protowire.AppendBytes(feature, list)
+		e = protowire.AppendTag(e, mapKey, protowire.BytesType)
+		e = protowire.AppendString(e, name)
+		e = protowire.AppendTag(e, mapValue, protowire.BytesType)
+		e = protowire.AppendBytes(e, feature)
+		fs = protowire.AppendTag(fs, featuresFeature, protowire.BytesType)
+		return protowire.AppendBytes(fs, e)
+	}
+	fs := append(entry("f", featureFloatList, floats), entry("i", featureInt64List, ints)...)
+	var data []byte
+	data = protowire.AppendTag(data, exampleFeatures, protowire.BytesType)
+	data = protowire.AppendBytes(data, fs)
+
+	got, err := ParseExample(data)
+	if err != nil {
+		t.Fatalf("ParseExample() failed: %v", err)
+	}
+	want := map[string]Feature{
+		"f": {Floats: []float32{1, 2}},
+		"i": {Int64s: []int64{7}},
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("ParseExample() = %v, want %v", got, want)
+	}
+}
+
+func TestDecodeExample_Errors(t *testing.T) {
+	data := MarshalExample(map[string]Feature{
+		"label": {Floats: []float32{1}},
+		"name":  {Bytes: [][]byte{[]byte("a"), []byte("b")}},
+	})
+	var v struct {
+		Label int64 `tfexample:"label"`
+	}
+	if err := DecodeExample(data, &v); err == nil {
+		t.Error("DecodeExample() of a float list into int64 succeeded, want error")
+	}
+	var w struct {
+		Name string `tfexample:"name"`
+	}
+	if err := DecodeExample(data, &w); err == nil {
+		t.Error("DecodeExample() of two values into string succeeded, want error")
+	}
+	if err := DecodeExample(data, w); err == nil {
+		t.Error("DecodeExample() into a non-pointer succeeded, want error")
+	}
+}
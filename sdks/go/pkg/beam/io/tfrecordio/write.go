@@ -0,0 +1,68 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tfrecordio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/fileio"
+)
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*recordSink)(nil)).Elem())
+}
+
+// Write writes a PCollection<[]byte> of records to TFRecord files, and returns
+// a PCollection<string> of the names of the written files. Write accepts a
+// variadic number of fileio.WriteOptionFn that can be used to configure the
+// number of shards, the suffix and the compression of the files, as described
+// in fileio.WriteFiles. For example, to write ten gzipped shards:
+//
+//	tfrecordio.Write(s, "gs://bucket/out/part", records,
+//		fileio.WriteNumShards(10),
+//		fileio.WriteSuffix(".tfrecord"),
+//		fileio.WriteCompression(fileio.CompressionGzip))
+func Write(s beam.Scope, filename string, col beam.PCollection, opts ...fileio.WriteOptionFn) beam.PCollection {
+	s = s.Scope("tfrecordio.Write")
+
+	if t := beam.ValidateNonCompositeType(col).Type(); t != reflectx.ByteSlice {
+		panic(fmt.Sprintf("tfrecordio.Write: input must be PCollection<[]byte>, got PCollection<%v>", t))
+	}
+	return fileio.WriteFiles(s, filename, &recordSink{}, col, opts...)
+}
+
+// recordSink writes []byte elements as TFRecord records.
+type recordSink struct {
+	w io.Writer
+}
+
+func (s *recordSink) Open(_ context.Context, w io.Writer) error {
+	s.w = w
+	return nil
+}
+
+func (s *recordSink) Write(_ context.Context, elm any) error {
+	return writeRecord(s.w, elm.([]byte))
+}
+
+func (s *recordSink) Flush(_ context.Context) error {
+	return nil
+}
@@ -18,6 +18,8 @@ package beam
 import (
 	"fmt"
 	"reflect"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph"
 )
 
 // Option is an optional value or context to a transformation, used at pipeline
@@ -59,9 +61,22 @@ func parseOpts(opts []Option) ([]SideInput, []TypeDefinition) {
 			side = append(side, opt)
 		case TypeDefinition:
 			infer = append(infer, opt)
+		case ErrorHandler:
+			// Handled by parseErrorHandler.
 		default:
 			panic(fmt.Sprintf("Unexpected opt: %v", opt))
 		}
 	}
 	return side, infer
 }
+
+// parseErrorHandler returns the last ErrorHandler in the options, if any.
+func parseErrorHandler(opts []Option) *graph.ErrorHandler {
+	var h *graph.ErrorHandler
+	for _, opt := range opts {
+		if opt, ok := opt.(ErrorHandler); ok {
+			h = &opt.h
+		}
+	}
+	return h
+}
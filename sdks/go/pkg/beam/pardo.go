@@ -92,6 +92,11 @@ func TryParDo(s Scope, dofn any, col PCollection, opts ...Option) ([]PCollection
 	if err != nil {
 		return nil, addParDoCtx(err, s)
 	}
+	if h := parseErrorHandler(opts); h != nil {
+		if err := graph.AddErrorHandler(s.real, edge, h); err != nil {
+			return nil, addParDoCtx(err, s)
+		}
+	}
 
 	pipelineState := fn.PipelineState()
 	if len(pipelineState) > 0 {
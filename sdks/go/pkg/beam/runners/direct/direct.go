@@ -261,6 +261,15 @@ func (b *builder) makeLink(id linkID) (exec.Node, error) {
 			Out:     out,
 			PID:     path.Base(edge.DoFn.Name()),
 		}
+		if edge.ErrorHandler != nil {
+			// The dead-letter output is always the last output.
+			pardo.Out = out[:len(out)-1]
+			pardo.DeadLetter = &exec.DeadLetter{
+				Handler: edge.ErrorHandler,
+				Enc:     exec.MakeElementEncoder(edge.Input[0].From.Coder),
+				Out:     out[len(out)-1],
+			}
+		}
 		u = pardo
 		if edge.DoFn.IsSplittable() {
 			u = &exec.SdfFallback{PDo: pardo}
@@ -0,0 +1,199 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package latest contains transformations for finding the latest element of a
+// PCollection, by element event timestamp.
+package latest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+)
+
+func init() {
+	register.DoFn2x1[beam.EventTime, beam.T, []byte]((*reifyFn)(nil))
+	register.DoFn3x2[beam.EventTime, beam.X, beam.Y, beam.X, []byte]((*reifyKVFn)(nil))
+	register.Combiner2[accum, []byte]((*combineFn)(nil))
+	register.DoFn2x0[accum, func(beam.T)]((*extractFn)(nil))
+	register.DoFn3x0[beam.X, accum, func(beam.X, beam.T)]((*extractKVFn)(nil))
+	register.Emitter1[beam.T]()
+	register.Emitter2[beam.X, beam.T]()
+	beam.RegisterType(reflect.TypeOf((*accum)(nil)).Elem())
+}
+
+// Globally returns the element of a PCollection<T> with the latest event
+// timestamp in each window. It returns a PCollection<T> with a single element
+// per non-empty window, and no element for empty windows. If several elements
+// have the latest timestamp, one of them is chosen arbitrarily.
+//
+// Example use:
+//
+//	last := latest.Globally(s, readings)  // PCollection<Reading> with the most recent reading.
+func Globally(s beam.Scope, col beam.PCollection) beam.PCollection {
+	s = s.Scope("latest.Globally")
+
+	t := beam.ValidateNonCompositeType(col)
+	reified := beam.ParDo(s, &reifyFn{Type: beam.EncodedType{T: t.Type()}}, col)
+	latest := beam.Combine(s, &combineFn{}, reified)
+	return beam.ParDo(s, &extractFn{Type: beam.EncodedType{T: t.Type()}}, latest, beam.TypeDefinition{Var: beam.TType, T: t.Type()})
+}
+
+// PerKey returns the value with the latest event timestamp for each key of a
+// PCollection<KV<K,T>> in each window. It returns a PCollection<KV<K,T>>.
+func PerKey(s beam.Scope, col beam.PCollection) beam.PCollection {
+	s = s.Scope("latest.PerKey")
+
+	_, t := beam.ValidateKVType(col)
+	reified := beam.ParDo(s, &reifyKVFn{Type: beam.EncodedType{T: t.Type()}}, col)
+	latest := beam.CombinePerKey(s, &combineFn{}, reified)
+	return beam.ParDo(s, &extractKVFn{Type: beam.EncodedType{T: t.Type()}}, latest, beam.TypeDefinition{Var: beam.TType, T: t.Type()})
+}
+
+// reify returns the encoded element prefixed with its event timestamp, which
+// makes the timestamp available to the combineFn.
+func reify(enc beam.ElementEncoder, buf *bytes.Buffer, et beam.EventTime, elm any) []byte {
+	buf.Reset()
+	binary.Write(buf, binary.BigEndian, et.Milliseconds())
+	if err := enc.Encode(elm, buf); err != nil {
+		panic(fmt.Sprintf("latest: marshalling %v: %v", elm, err))
+	}
+	return bytes.Clone(buf.Bytes())
+}
+
+// reifyFn reifies the timestamp of elements of type A.
+type reifyFn struct {
+	// Type is the element type A.
+	Type beam.EncodedType `json:"type"`
+
+	enc beam.ElementEncoder
+	buf bytes.Buffer
+}
+
+func (f *reifyFn) Setup() {
+	f.enc = beam.NewElementEncoder(f.Type.T)
+}
+
+func (f *reifyFn) ProcessElement(et beam.EventTime, elm beam.T) []byte {
+	return reify(f.enc, &f.buf, et, elm)
+}
+
+// reifyKVFn reifies the timestamp of the values, of type A, of KV elements.
+type reifyKVFn struct {
+	// Type is the value type A.
+	Type beam.EncodedType `json:"type"`
+
+	enc beam.ElementEncoder
+	buf bytes.Buffer
+}
+
+func (f *reifyKVFn) Setup() {
+	f.enc = beam.NewElementEncoder(f.Type.T)
+}
+
+func (f *reifyKVFn) ProcessElement(et beam.EventTime, k beam.X, v beam.Y) (beam.X, []byte) {
+	return k, reify(f.enc, &f.buf, et, v)
+}
+
+// accum holds the encoded latest element, and its event timestamp in
+// milliseconds. Empty accumulators have a timestamp before any valid event
+// timestamp.
+type accum struct {
+	Timestamp int64
+	Value     []byte
+}
+
+func (a accum) empty() bool {
+	return a.Timestamp == math.MinInt64
+}
+
+// combineFn is the internal CombineFn. It takes elements with reified
+// timestamps, and maintains the latest encoded element.
+type combineFn struct{}
+
+func (f *combineFn) CreateAccumulator() accum {
+	return accum{Timestamp: math.MinInt64}
+}
+
+func (f *combineFn) AddInput(a accum, reified []byte) accum {
+	ts := int64(binary.BigEndian.Uint64(reified))
+	return f.MergeAccumulators(a, accum{Timestamp: ts, Value: reified[8:]})
+}
+
+func (f *combineFn) MergeAccumulators(a, b accum) accum {
+	if b.empty() {
+		return a
+	}
+	if a.empty() || b.Timestamp > a.Timestamp {
+		return b
+	}
+	// Break ties deterministically, so that the result does not depend on the
+	// order in which accumulators are merged.
+	if b.Timestamp == a.Timestamp && bytes.Compare(b.Value, a.Value) > 0 {
+		return b
+	}
+	return a
+}
+
+// extractFn decodes the latest element, of type A, of non-empty accumulators.
+// The global window yields an empty accumulator when it has no elements, which
+// is dropped rather than emitted as the zero value of A.
+type extractFn struct {
+	// Type is the element type A.
+	Type beam.EncodedType `json:"type"`
+
+	dec beam.ElementDecoder
+}
+
+func (f *extractFn) Setup() {
+	f.dec = beam.NewElementDecoder(f.Type.T)
+}
+
+func (f *extractFn) ProcessElement(a accum, emit func(beam.T)) {
+	if !a.empty() {
+		emit(decode(f.dec, a))
+	}
+}
+
+// extractKVFn decodes the latest value, of type A, of each key.
+type extractKVFn struct {
+	// Type is the value type A.
+	Type beam.EncodedType `json:"type"`
+
+	dec beam.ElementDecoder
+}
+
+func (f *extractKVFn) Setup() {
+	f.dec = beam.NewElementDecoder(f.Type.T)
+}
+
+func (f *extractKVFn) ProcessElement(k beam.X, a accum, emit func(beam.X, beam.T)) {
+	if !a.empty() {
+		emit(k, decode(f.dec, a))
+	}
+}
+
+func decode(dec beam.ElementDecoder, a accum) any {
+	elm, err := dec.Decode(bytes.NewBuffer(a.Value))
+	if err != nil {
+		panic(fmt.Sprintf("latest: unmarshalling: %v", err))
+	}
+	return elm
+}
@@ -0,0 +1,111 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package latest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+)
+
+func TestMain(m *testing.M) {
+	ptest.Main(m)
+}
+
+func init() {
+	register.Function1x2(timestampFn)
+	register.Function1x3(timestampKVFn)
+}
+
+type reading struct {
+	Sensor string
+	Time   int64
+}
+
+// timestampFn assigns a timestamp that is not in element order.
+func timestampFn(v int) (beam.EventTime, int) {
+	return mtime.FromMilliseconds(int64((v * 7) % 10)), v
+}
+
+func timestampKVFn(r reading) (beam.EventTime, string, int64) {
+	return mtime.FromMilliseconds(r.Time), r.Sensor, r.Time
+}
+
+func TestCombineFn(t *testing.T) {
+	var fn combineFn
+	var buf bytes.Buffer
+	enc := beam.NewElementEncoder(reflectx.String)
+	dec := beam.NewElementDecoder(reflectx.String)
+	a := fn.CreateAccumulator()
+	if !a.empty() {
+		t.Errorf("CreateAccumulator() = %v, want empty", a)
+	}
+	for i, v := range []string{"b", "c", "a"} {
+		a = fn.AddInput(a, reify(enc, &buf, mtime.FromMilliseconds(int64(10-i)), v))
+	}
+	if got, want := decode(dec, a), "b"; got != want {
+		t.Errorf("CombineFn([b@10 c@9 a@8]) = %v, want %v", got, want)
+	}
+
+	b := fn.AddInput(fn.CreateAccumulator(), reify(enc, &buf, mtime.FromMilliseconds(10), "z"))
+	if got, want := decode(dec, fn.MergeAccumulators(a, b)), "z"; got != want {
+		t.Errorf("CombineFn(merge tie [b@10] [z@10]) = %v, want %v", got, want)
+	}
+	if got, want := decode(dec, fn.MergeAccumulators(b, a)), "z"; got != want {
+		t.Errorf("CombineFn(merge tie [z@10] [b@10]) = %v, want %v", got, want)
+	}
+	if got, want := decode(dec, fn.MergeAccumulators(fn.CreateAccumulator(), a)), "b"; got != want {
+		t.Errorf("CombineFn(merge [] [b@10]) = %v, want %v", got, want)
+	}
+}
+
+func TestExtractFn_Empty(t *testing.T) {
+	fn := &extractFn{Type: beam.EncodedType{T: reflectx.String}}
+	fn.Setup()
+	var got []beam.T
+	fn.ProcessElement((&combineFn{}).CreateAccumulator(), func(v beam.T) { got = append(got, v) })
+	if len(got) != 0 {
+		t.Errorf("extractFn(empty accumulator) emitted %v, want nothing", got)
+	}
+}
+
+func TestGlobally(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.ParDo(s, timestampFn, beam.Create(s, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9))
+	passert.Equals(s, Globally(s, col), 7)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestGlobally_Empty(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.CreateList(s, []string{})
+	passert.Empty(s, Globally(s, col))
+	ptest.RunAndValidate(t, p)
+}
+
+func TestPerKey(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.ParDo(s, timestampKVFn, beam.Create(s,
+		reading{"a", 3}, reading{"b", 20}, reading{"a", 10}, reading{"b", 5}, reading{"a", 1}))
+	passert.Equals(s, beam.DropKey(s, PerKey(s, col)), int64(10), int64(20))
+	ptest.RunAndValidate(t, p)
+}
@@ -0,0 +1,93 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reify
+
+import (
+	"bytes"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/coder"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+)
+
+// The kinds of encoded windows.
+const (
+	noWindow       byte = 0
+	globalWindow   byte = 1
+	intervalWindow byte = 2
+)
+
+// encodeInfo encodes the timestamp, the kind of window followed by its bounds
+// for an interval window, and the pane.
+func encodeInfo(info WindowedValueInfo) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := coder.EncodeEventTime(info.timestamp, &buf); err != nil {
+		return nil, err
+	}
+	switch w := info.window.(type) {
+	case nil:
+		buf.WriteByte(noWindow)
+	case window.GlobalWindow:
+		buf.WriteByte(globalWindow)
+	case window.IntervalWindow:
+		buf.WriteByte(intervalWindow)
+		if err := coder.EncodeEventTime(w.Start, &buf); err != nil {
+			return nil, err
+		}
+		if err := coder.EncodeEventTime(w.End, &buf); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("unsupported window type %T", info.window)
+	}
+	if err := coder.EncodePane(info.pane, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeInfo(data []byte) (WindowedValueInfo, error) {
+	var info WindowedValueInfo
+	r := bytes.NewReader(data)
+	var err error
+	if info.timestamp, err = coder.DecodeEventTime(r); err != nil {
+		return info, err
+	}
+	kind, err := r.ReadByte()
+	if err != nil {
+		return info, err
+	}
+	switch kind {
+	case noWindow:
+	case globalWindow:
+		info.window = window.GlobalWindow{}
+	case intervalWindow:
+		var w window.IntervalWindow
+		if w.Start, err = coder.DecodeEventTime(r); err != nil {
+			return info, err
+		}
+		if w.End, err = coder.DecodeEventTime(r); err != nil {
+			return info, err
+		}
+		info.window = w
+	default:
+		return info, errors.Errorf("invalid window kind %v", kind)
+	}
+	if info.pane, err = coder.DecodePane(r); err != nil {
+		return info, err
+	}
+	return info, nil
+}
@@ -0,0 +1,271 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package reify contains transforms that make the timestamp, window and pane
+// of elements available as values, and that set the timestamp and window of
+// elements from values.
+package reify
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+)
+
+func init() {
+	infoType := reflect.TypeOf((*WindowedValueInfo)(nil)).Elem()
+	beam.RegisterType(infoType)
+	beam.RegisterCoder(infoType, encodeInfo, decodeInfo)
+
+	register.DoFn3x1[beam.EventTime, beam.T, func(beam.EventTime, beam.T), error]((*timestampFn)(nil))
+	register.DoFn4x1[beam.EventTime, beam.X, beam.Y, func(beam.EventTime, beam.X, beam.Y), error]((*timestampKVFn)(nil))
+	register.Function2x2(reifyTimestampFn)
+	register.Function4x2(reifyWindowFn)
+	register.Function3x0(restoreTimestampFn)
+	register.Function3x0(rewindowTimestampFn)
+	register.Function4x1(rewindowFilterFn)
+	register.Emitter1[beam.T]()
+	register.Emitter2[beam.EventTime, beam.T]()
+	register.Emitter3[beam.EventTime, beam.X, beam.Y]()
+	register.Emitter3[beam.EventTime, beam.T, WindowedValueInfo]()
+}
+
+// WindowedValueInfo is the timestamp, window and pane of an element, as
+// produced by ReifyTimestamps and ReifyWindows.
+type WindowedValueInfo struct {
+	timestamp beam.EventTime
+	window    beam.Window
+	pane      beam.PaneInfo
+}
+
+// Timestamp returns the event time of the element.
+func (i WindowedValueInfo) Timestamp() beam.EventTime {
+	return i.timestamp
+}
+
+// Window returns the window of the element, or nil if only the timestamp
+// was reified.
+func (i WindowedValueInfo) Window() beam.Window {
+	return i.window
+}
+
+// Pane returns the pane of the element.
+func (i WindowedValueInfo) Pane() beam.PaneInfo {
+	return i.pane
+}
+
+// WithTimestamps sets the timestamp of each element of a PCollection<T> or
+// PCollection<KV<K,V>> to the one returned by fn, which must be a registered
+// func(T) beam.EventTime or func(K, V) beam.EventTime respectively. The
+// elements keep their windows, so the timestamps should usually be set before
+// windowing.
+//
+// Moving a timestamp back in time may make the element late, so timestamps
+// may only move back by up to allowedSkew. Processing fails if fn returns an
+// earlier timestamp. Timestamps may move forward arbitrarily.
+//
+// Example use:
+//
+//	func eventTime(e Event) beam.EventTime { return mtime.FromTime(e.Time) }
+//
+//	events = reify.WithTimestamps(s, events, eventTime, 0)
+//	windowed := beam.WindowInto(s, window.NewFixedWindows(time.Minute), events)
+func WithTimestamps(s beam.Scope, col beam.PCollection, fn any, allowedSkew time.Duration) beam.PCollection {
+	s = s.Scope("reify.WithTimestamps")
+
+	if allowedSkew < 0 {
+		panic(fmt.Sprintf("reify.WithTimestamps: negative allowed skew %v", allowedSkew))
+	}
+	var in []reflect.Type
+	if typex.IsKV(col.Type()) {
+		k, v := beam.ValidateKVType(col)
+		in = []reflect.Type{k.Type(), v.Type()}
+	} else {
+		in = []reflect.Type{beam.ValidateNonCompositeType(col).Type()}
+	}
+	if err := validateTimestampFn(fn, in); err != nil {
+		panic(errors.WithContext(err, "reify.WithTimestamps"))
+	}
+
+	f := beam.EncodedFunc{Fn: reflectx.MakeFunc(fn)}
+	if len(in) == 2 {
+		return beam.ParDo(s, &timestampKVFn{Fn: f, AllowedSkew: allowedSkew}, col)
+	}
+	return beam.ParDo(s, &timestampFn{Fn: f, AllowedSkew: allowedSkew}, col)
+}
+
+func validateTimestampFn(fn any, in []reflect.Type) error {
+	t := reflect.TypeOf(fn)
+	if t == nil || t.Kind() != reflect.Func {
+		return errors.Errorf("timestamp fn %v is not a function", t)
+	}
+	if t.NumIn() != len(in) || t.NumOut() != 1 || t.Out(0) != typex.EventTimeType {
+		return errors.Errorf("timestamp fn has type %v, want a func(%v) beam.EventTime", t, in)
+	}
+	for i, want := range in {
+		if !typex.IsUniversal(want) && t.In(i) != want {
+			return errors.Errorf("timestamp fn has type %v, want a func(%v) beam.EventTime", t, in)
+		}
+	}
+	return nil
+}
+
+// checkSkew returns an error if the timestamp is earlier than the timestamp
+// of the element by more than the allowed skew.
+func checkSkew(et, ts beam.EventTime, allowedSkew time.Duration) error {
+	if ts < et.Subtract(allowedSkew) {
+		return errors.Errorf("timestamp %v is earlier than the element timestamp %v by more than the allowed skew %v", ts, et, allowedSkew)
+	}
+	return nil
+}
+
+// timestampFn sets the timestamps of elements.
+type timestampFn struct {
+	// Fn is a func(T) beam.EventTime.
+	Fn          beam.EncodedFunc `json:"fn"`
+	AllowedSkew time.Duration    `json:"allowedSkew"`
+
+	fn reflectx.Func1x1
+}
+
+func (f *timestampFn) Setup() {
+	f.fn = reflectx.ToFunc1x1(f.Fn.Fn)
+}
+
+func (f *timestampFn) ProcessElement(et beam.EventTime, elm beam.T, emit func(beam.EventTime, beam.T)) error {
+	ts := f.fn.Call1x1(elm).(beam.EventTime)
+	if err := checkSkew(et, ts, f.AllowedSkew); err != nil {
+		return err
+	}
+	emit(ts, elm)
+	return nil
+}
+
+// timestampKVFn sets the timestamps of KV elements.
+type timestampKVFn struct {
+	// Fn is a func(K, V) beam.EventTime.
+	Fn          beam.EncodedFunc `json:"fn"`
+	AllowedSkew time.Duration    `json:"allowedSkew"`
+
+	fn reflectx.Func2x1
+}
+
+func (f *timestampKVFn) Setup() {
+	f.fn = reflectx.ToFunc2x1(f.Fn.Fn)
+}
+
+func (f *timestampKVFn) ProcessElement(et beam.EventTime, k beam.X, v beam.Y, emit func(beam.EventTime, beam.X, beam.Y)) error {
+	ts := f.fn.Call2x1(k, v).(beam.EventTime)
+	if err := checkSkew(et, ts, f.AllowedSkew); err != nil {
+		return err
+	}
+	emit(ts, k, v)
+	return nil
+}
+
+// ReifyTimestamps returns a PCollection<KV<T,WindowedValueInfo>> with each
+// element of a PCollection<T> paired with its timestamp. The Window of the
+// WindowedValueInfo is nil. The elements keep their timestamps and windows.
+func ReifyTimestamps(s beam.Scope, col beam.PCollection) beam.PCollection {
+	s = s.Scope("reify.ReifyTimestamps")
+
+	beam.ValidateNonCompositeType(col)
+	return beam.ParDo(s, reifyTimestampFn, col)
+}
+
+func reifyTimestampFn(et beam.EventTime, elm beam.T) (beam.T, WindowedValueInfo) {
+	return elm, WindowedValueInfo{timestamp: et}
+}
+
+// ReifyWindows returns a PCollection<KV<T,WindowedValueInfo>> with each
+// element of a PCollection<T> paired with its timestamp, window and pane. An
+// element in several windows, such as with sliding windows, is paired with
+// each of them in turn. The elements keep their timestamps and windows.
+func ReifyWindows(s beam.Scope, col beam.PCollection) beam.PCollection {
+	s = s.Scope("reify.ReifyWindows")
+
+	beam.ValidateNonCompositeType(col)
+	return beam.ParDo(s, reifyWindowFn, col)
+}
+
+func reifyWindowFn(pn beam.PaneInfo, w beam.Window, et beam.EventTime, elm beam.T) (beam.T, WindowedValueInfo) {
+	return elm, WindowedValueInfo{timestamp: et, window: w, pane: pn}
+}
+
+// RestoreTimestamps is the inverse of ReifyTimestamps. It returns a
+// PCollection<T> with the elements of a PCollection<KV<T,WindowedValueInfo>>,
+// each with the timestamp of its WindowedValueInfo. The elements keep their
+// windows. Unlike WithTimestamps, timestamps may move back arbitrarily, which
+// may make the elements late.
+func RestoreTimestamps(s beam.Scope, col beam.PCollection) beam.PCollection {
+	s = s.Scope("reify.RestoreTimestamps")
+
+	validateInfo(col)
+	return beam.ParDo(s, restoreTimestampFn, col)
+}
+
+func restoreTimestampFn(elm beam.T, info WindowedValueInfo, emit func(beam.EventTime, beam.T)) {
+	emit(info.timestamp, elm)
+}
+
+// Rewindow is the inverse of ReifyWindows. It returns a PCollection<T> with
+// the elements of a PCollection<KV<T,WindowedValueInfo>>, each with the
+// timestamp and window of its WindowedValueInfo. This restores the windows
+// of elements that were reified and then re-windowed, for example into the
+// global window to group across windows.
+//
+// The elements are windowed into ws, which must be the windowing that the
+// windows were reified from, and are only kept in their reified window. Panes
+// are not restored. Since sessions are merged when grouping, the reified
+// session windows cannot be restored, so ws must not be merging.
+func Rewindow(s beam.Scope, col beam.PCollection, ws *window.Fn) beam.PCollection {
+	s = s.Scope(fmt.Sprintf("reify.Rewindow(%v)", ws))
+
+	validateInfo(col)
+	if ws.Kind == window.Sessions {
+		panic(fmt.Sprintf("reify.Rewindow: merging windows %v are not supported", ws))
+	}
+	timestamped := beam.ParDo(s, rewindowTimestampFn, col)
+	windowed := beam.WindowInto(s, ws, timestamped)
+	return beam.ParDo(s, rewindowFilterFn, windowed)
+}
+
+func rewindowTimestampFn(elm beam.T, info WindowedValueInfo, emit func(beam.EventTime, beam.T, WindowedValueInfo)) {
+	emit(info.timestamp, elm, info)
+}
+
+func rewindowFilterFn(w beam.Window, elm beam.T, info WindowedValueInfo, emit func(beam.T)) error {
+	if info.window == nil {
+		return errors.Errorf("no window reified for element at %v, use ReifyWindows rather than ReifyTimestamps", info.timestamp)
+	}
+	if w.Equals(info.window) {
+		emit(elm)
+	}
+	return nil
+}
+
+func validateInfo(col beam.PCollection) {
+	_, v := beam.ValidateKVType(col)
+	if v.Type() != reflect.TypeOf((*WindowedValueInfo)(nil)).Elem() {
+		panic(fmt.Sprintf("values of %v are not WindowedValueInfo", col))
+	}
+}
@@ -0,0 +1,204 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reify
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func init() {
+	register.Function1x1(secondsFn)
+	register.Function1x1(halfSecondsFn)
+	register.Function1x2(keyFn)
+	register.Function2x1(secondsKVFn)
+	register.Function2x1(formatFn)
+}
+
+func TestMain(m *testing.M) {
+	ptest.Main(m)
+}
+
+func secondsFn(n int) beam.EventTime {
+	return mtime.FromMilliseconds(int64(n) * 1000)
+}
+
+func halfSecondsFn(n int) beam.EventTime {
+	return mtime.FromMilliseconds(int64(n) * 500)
+}
+
+func keyFn(n int) (string, int) {
+	return fmt.Sprint(n), n
+}
+
+func secondsKVFn(k string, n int) beam.EventTime {
+	return secondsFn(n)
+}
+
+func formatFn(n int, info WindowedValueInfo) string {
+	ret := fmt.Sprintf("%v@%v", n, info.Timestamp().Milliseconds())
+	if w, ok := info.Window().(window.IntervalWindow); ok {
+		ret += fmt.Sprintf(" in [%v, %v)", w.Start.Milliseconds(), w.End.Milliseconds())
+	}
+	return ret
+}
+
+func TestWithTimestamps(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := WithTimestamps(s, beam.Create(s, 1, 2, 3), secondsFn, 0)
+	passert.Equals(s, beam.ParDo(s, formatFn, ReifyTimestamps(s, col)), "1@1000", "2@2000", "3@3000")
+	ptest.RunAndValidate(t, p)
+}
+
+func TestWithTimestamps_KV(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	kvs := beam.ParDo(s, keyFn, beam.Create(s, 1, 2))
+	col := beam.DropKey(s, WithTimestamps(s, kvs, secondsKVFn, 0))
+	passert.Equals(s, beam.ParDo(s, formatFn, ReifyTimestamps(s, col)), "1@1000", "2@2000")
+	ptest.RunAndValidate(t, p)
+}
+
+func TestWithTimestamps_AllowedSkew(t *testing.T) {
+	tests := []struct {
+		skew time.Duration
+		ok   bool
+	}{
+		{0, false},
+		{time.Second, false},
+		{2 * time.Second, true},
+	}
+	for _, test := range tests {
+		t.Run(test.skew.String(), func(t *testing.T) {
+			p, s := beam.NewPipelineWithRoot()
+			col := WithTimestamps(s, beam.Create(s, 4), secondsFn, 0)
+			// Moves the timestamp back from 4s to 2s.
+			col = WithTimestamps(s, col, halfSecondsFn, test.skew)
+			passert.Equals(s, beam.ParDo(s, formatFn, ReifyTimestamps(s, col)), "4@2000")
+			if err := ptest.Run(p); (err == nil) != test.ok {
+				t.Errorf("WithTimestamps() with skew %v failed: %v, want ok %v", test.skew, err, test.ok)
+			}
+		})
+	}
+}
+
+func TestWithTimestamps_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   any
+	}{
+		{"notFunc", 1},
+		{"wrongInput", func(s string) beam.EventTime { return 0 }},
+		{"wrongOutput", func(n int) int { return n }},
+		{"kvFn", secondsKVFn},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("WithTimestamps(%T) succeeded, want panic", test.fn)
+				}
+			}()
+			_, s := beam.NewPipelineWithRoot()
+			WithTimestamps(s, beam.Create(s, 1), test.fn, 0)
+		})
+	}
+}
+
+func TestReifyWindows(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := WithTimestamps(s, beam.Create(s, 1, 6, 11), secondsFn, 0)
+	col = beam.WindowInto(s, window.NewFixedWindows(5*time.Second), col)
+	out := beam.ParDo(s, formatFn, ReifyWindows(s, col))
+	passert.Equals(s, beam.WindowInto(s, window.NewGlobalWindows(), out),
+		"1@1000 in [0, 5000)", "6@6000 in [5000, 10000)", "11@11000 in [10000, 15000)")
+	ptest.RunAndValidate(t, p)
+}
+
+func TestRewindow(t *testing.T) {
+	tests := []struct {
+		name string
+		ws   *window.Fn
+		want []any
+	}{
+		{
+			name: "fixed",
+			ws:   window.NewFixedWindows(5 * time.Second),
+			want: []any{"1@1000 in [0, 5000)", "6@6000 in [5000, 10000)"},
+		},
+		{
+			name: "sliding",
+			ws:   window.NewSlidingWindows(5*time.Second, 10*time.Second),
+			want: []any{"1@1000 in [-5000, 5000)", "1@1000 in [0, 10000)", "6@6000 in [0, 10000)", "6@6000 in [5000, 15000)"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, s := beam.NewPipelineWithRoot()
+			col := WithTimestamps(s, beam.Create(s, 1, 6), secondsFn, 0)
+			reified := ReifyWindows(s, beam.WindowInto(s, test.ws, col))
+			global := beam.WindowInto(s, window.NewGlobalWindows(), reified)
+			out := beam.ParDo(s, formatFn, ReifyWindows(s, Rewindow(s, global, test.ws)))
+			passert.Equals(s, beam.WindowInto(s, window.NewGlobalWindows(), out), test.want...)
+			ptest.RunAndValidate(t, p)
+		})
+	}
+}
+
+func TestRewindow_Sessions(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Rewindow() with sessions succeeded, want panic")
+		}
+	}()
+	_, s := beam.NewPipelineWithRoot()
+	col := ReifyWindows(s, beam.Create(s, 1))
+	Rewindow(s, col, window.NewSessions(time.Second))
+}
+
+func TestInfoCoder(t *testing.T) {
+	tests := []WindowedValueInfo{
+		{timestamp: mtime.MinTimestamp},
+		{timestamp: 1000, window: window.GlobalWindow{}, pane: typex.NoFiringPane()},
+		{
+			timestamp: -1000,
+			window:    window.IntervalWindow{Start: -5000, End: 5000},
+			pane:      typex.PaneInfo{Timing: typex.PaneLate, Index: 3, NonSpeculativeIndex: 2},
+		},
+	}
+	for _, info := range tests {
+		data, err := encodeInfo(info)
+		if err != nil {
+			t.Fatalf("encodeInfo(%v) failed: %v", info, err)
+		}
+		got, err := decodeInfo(data)
+		if err != nil {
+			t.Fatalf("decodeInfo(%v) failed: %v", data, err)
+		}
+		if diff := cmp.Diff(info, got, cmp.AllowUnexported(WindowedValueInfo{})); diff != "" {
+			t.Errorf("decodeInfo(encodeInfo(%v)) diff (-want, +got):\n%v", info, diff)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sample contains transformations for sampling the elements of a
+// PCollection.
+package sample
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+)
+
+func init() {
+	register.Combiner3[accum, beam.T, []beam.T]((*combineFn)(nil))
+	beam.RegisterType(reflect.TypeOf((*accum)(nil)).Elem())
+}
+
+// FixedSize returns a uniform random sample of n elements of a PCollection<T>,
+// without replacement. It returns a single-element PCollection<[]T> with a
+// slice of the sampled elements, or all the elements if there are no more
+// than n of them.
+//
+// Example use:
+//
+//	col := beam.Create(s, 1, 11, 7, 5, 10)
+//	sampled := sample.FixedSize(s, col, 2)  // PCollection<[]int> with 2 random elements of col.
+func FixedSize(s beam.Scope, col beam.PCollection, n int) beam.PCollection {
+	s = s.Scope(fmt.Sprintf("sample.FixedSize(%v)", n))
+
+	t := beam.ValidateNonCompositeType(col)
+	validate(n)
+
+	return beam.Combine(s, newCombineFn(n, t.Type(), true), col)
+}
+
+// FixedSizePerKey returns a uniform random sample of n values for each key of
+// a PCollection<KV<K,T>>, without replacement. It returns a
+// PCollection<KV<K,[]T>> with a slice of the sampled values for each key.
+func FixedSizePerKey(s beam.Scope, col beam.PCollection, n int) beam.PCollection {
+	s = s.Scope(fmt.Sprintf("sample.FixedSizePerKey(%v)", n))
+
+	_, t := beam.ValidateKVType(col)
+	validate(n)
+
+	return beam.CombinePerKey(s, newCombineFn(n, t.Type(), true), col)
+}
+
+// Any returns up to n arbitrary elements of a PCollection<T>. Unlike
+// FixedSize, the choice of elements is not random, which makes it cheaper
+// to compute. It returns a PCollection<T>.
+func Any(s beam.Scope, col beam.PCollection, n int) beam.PCollection {
+	s = s.Scope(fmt.Sprintf("sample.Any(%v)", n))
+
+	t := beam.ValidateNonCompositeType(col)
+	validate(n)
+
+	return beam.Explode(s, beam.Combine(s, newCombineFn(n, t.Type(), false), col))
+}
+
+// AnyPerKey returns up to n arbitrary values for each key of a
+// PCollection<KV<K,T>>. It returns a PCollection<KV<K,[]T>>.
+func AnyPerKey(s beam.Scope, col beam.PCollection, n int) beam.PCollection {
+	s = s.Scope(fmt.Sprintf("sample.AnyPerKey(%v)", n))
+
+	_, t := beam.ValidateKVType(col)
+	validate(n)
+
+	return beam.CombinePerKey(s, newCombineFn(n, t.Type(), false), col)
+}
+
+func validate(n int) {
+	if n < 1 {
+		panic("n must be > 0")
+	}
+}
+
+func newCombineFn(n int, t reflect.Type, random bool) *combineFn {
+	fn := &combineFn{N: n, Type: beam.EncodedType{T: t}, Random: random}
+	// Running SetupFn at pipeline construction helps validate the
+	// combineFn, and simplify testing.
+	fn.Setup()
+	return fn
+}
+
+// accum is a reservoir of at most N encoded elements, each with a priority.
+// The sample is made of the elements with the highest priorities, so merging
+// reservoirs keeps the sample uniform.
+type accum struct {
+	Priorities []int64
+	Values     [][]byte
+}
+
+// min returns the index of the element with the lowest priority.
+func (a *accum) min() int {
+	m := 0
+	for i, p := range a.Priorities {
+		if p < a.Priorities[m] {
+			m = i
+		}
+	}
+	return m
+}
+
+// combineFn is the internal CombineFn. It maintains reservoirs of encoded
+// elements of the underlying type, A, up to size N. If Random is set, elements
+// are assigned random priorities. Otherwise, all elements have the same
+// priority, so the first N are kept.
+type combineFn struct {
+	// N is the number of elements to keep.
+	N int `json:"n"`
+	// Type is the element type A.
+	Type beam.EncodedType `json:"type"`
+	// Random indicates whether the sample should be random.
+	Random bool `json:"random"`
+
+	enc beam.ElementEncoder
+	dec beam.ElementDecoder
+	buf bytes.Buffer
+}
+
+func (f *combineFn) Setup() {
+	f.enc = beam.NewElementEncoder(f.Type.T)
+	f.dec = beam.NewElementDecoder(f.Type.T)
+}
+
+func (f *combineFn) CreateAccumulator() accum {
+	return accum{}
+}
+
+func (f *combineFn) AddInput(a accum, val beam.T) accum {
+	var p int64
+	if f.Random {
+		p = rand.Int63()
+	}
+	if len(a.Priorities) == f.N && p <= a.Priorities[a.min()] {
+		return a
+	}
+	f.buf.Reset()
+	if err := f.enc.Encode(val, &f.buf); err != nil {
+		panic(fmt.Sprintf("sample: marshalling %v: %v", val, err))
+	}
+	return f.add(a, p, bytes.Clone(f.buf.Bytes()))
+}
+
+func (f *combineFn) MergeAccumulators(a, b accum) accum {
+	for i, p := range b.Priorities {
+		if len(a.Priorities) == f.N && p <= a.Priorities[a.min()] {
+			continue
+		}
+		a = f.add(a, p, b.Values[i])
+	}
+	return a
+}
+
+func (f *combineFn) ExtractOutput(a accum) []beam.T {
+	var ret []beam.T
+	for _, v := range a.Values {
+		elm, err := f.dec.Decode(bytes.NewBuffer(v))
+		if err != nil {
+			panic(fmt.Sprintf("sample: unmarshalling: %v", err))
+		}
+		ret = append(ret, elm)
+	}
+	return ret
+}
+
+// add adds the value to the reservoir, replacing the element with the lowest
+// priority if the reservoir is full.
+func (f *combineFn) add(a accum, p int64, v []byte) accum {
+	if len(a.Priorities) < f.N {
+		a.Priorities = append(a.Priorities, p)
+		a.Values = append(a.Values, v)
+		return a
+	}
+	m := a.min()
+	a.Priorities[m], a.Values[m] = p, v
+	return a
+}
@@ -0,0 +1,148 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sample
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/util/reflectx"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+)
+
+func TestMain(m *testing.M) {
+	ptest.Main(m)
+}
+
+func init() {
+	register.Function1x1(sampleSize)
+	register.Function2x2(sampleSizePerKey)
+	register.Function1x2(addKeyFn)
+}
+
+func sampleSize(list []int) int {
+	seen := make(map[int]bool)
+	for _, v := range list {
+		if v < 0 || v >= 100 || seen[v] {
+			return -1
+		}
+		seen[v] = true
+	}
+	return len(list)
+}
+
+func sampleSizePerKey(k string, list []int) (string, int) {
+	return k, sampleSize(list)
+}
+
+func addKeyFn(v int) (string, int) {
+	if v%2 == 0 {
+		return "even", v
+	}
+	return "odd", v
+}
+
+func ints(n int) []any {
+	var ret []any
+	for i := 0; i < n; i++ {
+		ret = append(ret, i)
+	}
+	return ret
+}
+
+func load(fn *combineFn, elms ...int) accum {
+	a := fn.CreateAccumulator()
+	for _, elm := range elms {
+		a = fn.AddInput(a, elm)
+	}
+	return a
+}
+
+func output(fn *combineFn, a accum) []int {
+	var ret []int
+	for _, v := range fn.ExtractOutput(a) {
+		ret = append(ret, v.(int))
+	}
+	sort.Ints(ret)
+	return ret
+}
+
+// TestCombineFn verifies that the accumulators keep at most N distinct input
+// elements, also when merged.
+func TestCombineFn(t *testing.T) {
+	for _, random := range []bool{true, false} {
+		fn := newCombineFn(3, reflectx.Int, random)
+
+		if got := output(fn, load(fn, 1, 2)); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+			t.Errorf("CombineFn(3, random: %v; [1 2]) = %v, want [1 2]", random, got)
+		}
+		a := load(fn, 0, 1, 2, 3, 4)
+		b := load(fn, 5, 6, 7, 8, 9)
+		got := output(fn, fn.MergeAccumulators(a, b))
+		if len(got) != 3 || got[0] == got[1] || got[1] == got[2] {
+			t.Errorf("CombineFn(3, random: %v; 0..9) = %v, want 3 distinct elements", random, got)
+		}
+		if !random {
+			got := output(fn, load(fn, 5, 4, 3, 2, 1))
+			if len(got) != 3 || got[0] != 3 || got[1] != 4 || got[2] != 5 {
+				t.Errorf("CombineFn(3, random: false; [5 4 3 2 1]) = %v, want first elements [3 4 5]", got)
+			}
+		}
+	}
+}
+
+// TestCombineFnUniform verifies that every element has a chance to be part of
+// the random sample.
+func TestCombineFnUniform(t *testing.T) {
+	fn := newCombineFn(2, reflectx.Int, true)
+	seen := make(map[int]bool)
+	for i := 0; i < 200; i++ {
+		for _, v := range output(fn, fn.MergeAccumulators(load(fn, 0, 1), load(fn, 2, 3))) {
+			seen[v] = true
+		}
+	}
+	if len(seen) != 4 {
+		t.Errorf("CombineFn(2) sampled %v over 200 runs, want all of [0 1 2 3]", seen)
+	}
+}
+
+func TestFixedSize(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Create(s, ints(100)...)
+	passert.Equals(s, beam.ParDo(s, sampleSize, FixedSize(s, col, 10)), 10)
+	passert.Equals(s, beam.ParDo(s, sampleSize, FixedSize(s, col, 1000)), 100)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestFixedSizePerKey(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.ParDo(s, addKeyFn, beam.Create(s, ints(100)...))
+	sizes := beam.ParDo(s, sampleSizePerKey, FixedSizePerKey(s, col, 5))
+	passert.Equals(s, beam.DropKey(s, sizes), 5, 5)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestAny(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.Create(s, ints(100)...)
+	passert.Count(s, Any(s, col, 7), "any", 7)
+	sizes := beam.ParDo(s, sampleSizePerKey, AnyPerKey(s, beam.ParDo(s, addKeyFn, col), 3))
+	passert.Equals(s, beam.DropKey(s, sizes), 3, 3)
+	ptest.RunAndValidate(t, p)
+}
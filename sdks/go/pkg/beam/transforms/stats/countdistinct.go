@@ -0,0 +1,167 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+)
+
+func init() {
+	sketchType := reflect.TypeOf((**HLLSketch)(nil)).Elem()
+	beam.RegisterType(sketchType)
+	beam.RegisterCoder(sketchType, encodeHLLSketch, decodeHLLSketch)
+
+	register.Combiner3[*HLLSketch, beam.T, int64]((*countDistinctFn)(nil))
+	register.Combiner3[*HLLSketch, beam.T, []byte]((*countDistinctSketchFn)(nil))
+}
+
+// ApproximateCountDistinct estimates the number of distinct elements in a
+// PCollection<T> with a HyperLogLog++ sketch of the given precision. It
+// returns a PCollection<int64> of one element containing the estimate. T's
+// encoding must be deterministic, as elements are compared by their encoded
+// form.
+//
+// The precision, in [MinPrecision, MaxPrecision], trades memory for accuracy:
+// the sketch uses up to 2^precision bytes and the estimate has a relative
+// standard error of about 1.04/sqrt(2^precision). Use DefaultPrecision if in
+// doubt.
+//
+// Example use:
+//
+//	users := beam.ParDo(s, extractUserIDFn, events)
+//	n := stats.ApproximateCountDistinct(s, users, stats.DefaultPrecision)
+func ApproximateCountDistinct(s beam.Scope, col beam.PCollection, precision int) beam.PCollection {
+	s = s.Scope(fmt.Sprintf("stats.ApproximateCountDistinct(%v)", precision))
+
+	t := beam.ValidateNonCompositeType(col)
+	return beam.Combine(s, newCountDistinctFn(precision, t.Type()), col)
+}
+
+// ApproximateCountDistinctPerKey estimates the number of distinct values for
+// each key of a PCollection<KV<K,V>>, as ApproximateCountDistinct does. It
+// returns a PCollection<KV<K,int64>>.
+func ApproximateCountDistinctPerKey(s beam.Scope, col beam.PCollection, precision int) beam.PCollection {
+	s = s.Scope(fmt.Sprintf("stats.ApproximateCountDistinctPerKey(%v)", precision))
+
+	_, t := beam.ValidateKVType(col)
+	return beam.CombinePerKey(s, newCountDistinctFn(precision, t.Type()), col)
+}
+
+// ApproximateCountDistinctSketch is ApproximateCountDistinct, but returns
+// a PCollection<[]byte> of one element containing the serialized
+// HLLSketch rather than its estimate. Sketches of the same precision can
+// be merged later on, for example to count distinct elements over several
+// windows. They are not compatible with ZetaSketch or BigQuery's HLL_COUNT
+// functions, as described by HLLSketch.
+func ApproximateCountDistinctSketch(s beam.Scope, col beam.PCollection, precision int) beam.PCollection {
+	s = s.Scope(fmt.Sprintf("stats.ApproximateCountDistinctSketch(%v)", precision))
+
+	t := beam.ValidateNonCompositeType(col)
+	return beam.Combine(s, &countDistinctSketchFn{*newCountDistinctFn(precision, t.Type())}, col)
+}
+
+// ApproximateCountDistinctSketchPerKey is ApproximateCountDistinctPerKey,
+// but returns a PCollection<KV<K,[]byte>> of serialized HLLSketches.
+func ApproximateCountDistinctSketchPerKey(s beam.Scope, col beam.PCollection, precision int) beam.PCollection {
+	s = s.Scope(fmt.Sprintf("stats.ApproximateCountDistinctSketchPerKey(%v)", precision))
+
+	_, t := beam.ValidateKVType(col)
+	return beam.CombinePerKey(s, &countDistinctSketchFn{*newCountDistinctFn(precision, t.Type())}, col)
+}
+
+func newCountDistinctFn(precision int, t reflect.Type) *countDistinctFn {
+	if _, err := NewHLLSketch(precision); err != nil {
+		panic(err)
+	}
+	fn := &countDistinctFn{Precision: precision, Type: beam.EncodedType{T: t}}
+	fn.Setup()
+	return fn
+}
+
+// countDistinctFn is the internal CombineFn. It adds the encoded elements of
+// the underlying type to HyperLogLog++ sketches.
+type countDistinctFn struct {
+	// Precision is the precision of the sketches.
+	Precision int `json:"precision"`
+	// Type is the element type.
+	Type beam.EncodedType `json:"type"`
+
+	enc beam.ElementEncoder
+	buf bytes.Buffer
+}
+
+func (f *countDistinctFn) Setup() {
+	f.enc = beam.NewElementEncoder(f.Type.T)
+}
+
+func (f *countDistinctFn) CreateAccumulator() *HLLSketch {
+	h, err := NewHLLSketch(f.Precision)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+func (f *countDistinctFn) AddInput(a *HLLSketch, val beam.T) *HLLSketch {
+	f.buf.Reset()
+	if err := f.enc.Encode(val, &f.buf); err != nil {
+		panic(fmt.Sprintf("stats: marshalling %v: %v", val, err))
+	}
+	a.Add(f.buf.Bytes())
+	return a
+}
+
+func (f *countDistinctFn) MergeAccumulators(a, b *HLLSketch) *HLLSketch {
+	if err := a.Merge(b); err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func (f *countDistinctFn) ExtractOutput(a *HLLSketch) int64 {
+	return a.Estimate()
+}
+
+// countDistinctSketchFn is countDistinctFn, but outputs the serialized
+// sketches.
+type countDistinctSketchFn struct {
+	countDistinctFn
+}
+
+func (f *countDistinctSketchFn) ExtractOutput(a *HLLSketch) []byte {
+	data, err := a.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func encodeHLLSketch(h *HLLSketch) ([]byte, error) {
+	return h.MarshalBinary()
+}
+
+func decodeHLLSketch(data []byte) (*HLLSketch, error) {
+	var h HLLSketch
+	if err := h.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
@@ -0,0 +1,85 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+)
+
+func init() {
+	register.Function1x1(decodeSketchFn)
+	register.Function1x2(countToKV)
+	register.Function2x1(kvToCountDistinct)
+}
+
+type countDistinct struct {
+	Key   int
+	Count int64
+}
+
+func countToKV(c count) (int, int) {
+	return c.Elm, c.Count
+}
+
+func kvToCountDistinct(k int, n int64) countDistinct {
+	return countDistinct{k, n}
+}
+
+func decodeSketchFn(data []byte) int64 {
+	h, err := decodeHLLSketch(data)
+	if err != nil {
+		panic(err)
+	}
+	return h.Estimate()
+}
+
+func TestApproximateCountDistinct(t *testing.T) {
+	var in []string
+	for i := 0; i < 300; i++ {
+		in = append(in, fmt.Sprintf("user-%d", i%100))
+	}
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.CreateList(s, in)
+	// Small cardinalities are counted exactly in the sparse representation.
+	passert.Equals(s, ApproximateCountDistinct(s, col, DefaultPrecision), int64(100))
+	passert.Equals(s, beam.ParDo(s, decodeSketchFn, ApproximateCountDistinctSketch(s, col, DefaultPrecision)), int64(100))
+	ptest.RunAndValidate(t, p)
+}
+
+func TestApproximateCountDistinctPerKey(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	in := beam.CreateList(s, []count{{1, 10}, {1, 11}, {1, 10}, {2, 20}, {2, 20}, {3, 30}})
+	kvs := beam.ParDo(s, countToKV, in)
+	counts := ApproximateCountDistinctPerKey(s, kvs, MinPrecision)
+	passert.Equals(s, beam.ParDo(s, kvToCountDistinct, counts), countDistinct{1, 2}, countDistinct{2, 1}, countDistinct{3, 1})
+	ptest.RunAndValidate(t, p)
+}
+
+func TestApproximateCountDistinct_InvalidPrecision(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("ApproximateCountDistinct() with invalid precision succeeded, want panic")
+		}
+	}()
+	_, s := beam.NewPipelineWithRoot()
+	ApproximateCountDistinct(s, beam.Create(s, 1), MaxPrecision+1)
+}
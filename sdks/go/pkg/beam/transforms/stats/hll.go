@@ -0,0 +1,332 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/internal/errors"
+	"github.com/cespare/xxhash/v2"
+)
+
+const (
+	// MinPrecision and MaxPrecision bound the precision of a HLLSketch.
+	MinPrecision = 4
+	MaxPrecision = 18
+	// DefaultPrecision has a relative standard error of about 0.6%. It is
+	// also the default precision of BigQuery's HLL_COUNT functions.
+	DefaultPrecision = 15
+
+	// sparsePrecision is the precision of the sparse representation. Small
+	// cardinalities are counted at this precision, which makes them nearly
+	// exact.
+	sparsePrecision = 25
+
+	hllVersion = 1
+	hllSparse  = 0
+	hllDense   = 1
+)
+
+// HLLSketch is a HyperLogLog++ sketch, which estimates the number of
+// distinct values added to it using a fixed amount of memory. Sketches of the
+// same precision can be merged, and serialized with MarshalBinary.
+//
+// A sketch of precision p uses 2^p bytes once it holds enough values, and has
+// a relative standard error of about 1.04/sqrt(2^p). Until then, it keeps a
+// sparse representation of the values at a higher precision, so that small
+// cardinalities are close to exact.
+//
+// The sketch follows the HyperLogLog++ paper by Heule et al., and uses the
+// improved estimator by Ertl, which needs no empirical bias correction.
+// The sketch and its serialized form are specific to the Beam Go SDK. Values
+// are hashed with 64-bit xxHash and the format is not ZetaSketch's, so the
+// sketches cannot be merged with those of BigQuery's HLL_COUNT functions or
+// Beam Java's HllCount. Use them to merge counts within Beam Go pipelines.
+type HLLSketch struct {
+	precision int
+	// sparse maps the indices of the non-empty sparse registers to their
+	// values. It is nil once the sketch is dense.
+	sparse map[uint32]uint8
+	dense  []uint8
+}
+
+// NewHLLSketch returns an empty sketch of the given precision, which must
+// be in [MinPrecision, MaxPrecision].
+func NewHLLSketch(precision int) (*HLLSketch, error) {
+	if precision < MinPrecision || precision > MaxPrecision {
+		return nil, errors.Errorf("invalid HyperLogLog precision %v, must be in [%v, %v]", precision, MinPrecision, MaxPrecision)
+	}
+	return &HLLSketch{precision: precision, sparse: make(map[uint32]uint8)}, nil
+}
+
+// Precision returns the precision of the sketch.
+func (h *HLLSketch) Precision() int {
+	return h.precision
+}
+
+// Add adds the given value to the sketch.
+func (h *HLLSketch) Add(value []byte) {
+	h.AddHash(xxhash.Sum64(value))
+}
+
+// AddHash adds a value to the sketch, given its 64-bit hash. The hashes must
+// be uniformly distributed.
+func (h *HLLSketch) AddHash(hash uint64) {
+	if h.dense != nil {
+		idx, rho := split(hash, h.precision)
+		h.setDense(idx, rho)
+		return
+	}
+	idx, rho := split(hash, sparsePrecision)
+	h.setSparse(uint32(idx), rho)
+}
+
+// split returns the register index and value for the given hash at the given
+// precision: the top p bits, and the number of leading zeros plus one of the
+// rest.
+func split(hash uint64, p int) (int, uint8) {
+	idx := int(hash >> (64 - p))
+	w := hash << p
+	rho := bits.LeadingZeros64(w) + 1
+	if max := maxRho(p); rho > max {
+		rho = max
+	}
+	return idx, uint8(rho)
+}
+
+// maxRho returns the largest register value at the given precision.
+func maxRho(p int) int {
+	return 64 - p + 1
+}
+
+func (h *HLLSketch) setDense(idx int, rho uint8) {
+	if rho > h.dense[idx] {
+		h.dense[idx] = rho
+	}
+}
+
+func (h *HLLSketch) setSparse(idx uint32, rho uint8) {
+	if rho > h.sparse[idx] {
+		h.sparse[idx] = rho
+	}
+	// The sparse representation is kept while its serialized form, at
+	// about four bytes per register, is smaller than the dense one.
+	if 4*len(h.sparse) > 1<<h.precision {
+		h.toDense()
+	}
+}
+
+// toDense converts the sparse registers to the dense representation.
+func (h *HLLSketch) toDense() {
+	h.dense = make([]uint8, 1<<h.precision)
+	for idx, rho := range h.sparse {
+		h.setDense(h.downgrade(idx, rho))
+	}
+	h.sparse = nil
+}
+
+// downgrade converts a sparse register to the dense register it falls into.
+// The low bits of the sparse index, which are dropped from the dense index,
+// are the leading bits of the dense register value.
+func (h *HLLSketch) downgrade(idx uint32, rho uint8) (int, uint8) {
+	shift := sparsePrecision - h.precision
+	low := idx & (1<<shift - 1)
+	if low != 0 {
+		return int(idx >> shift), uint8(bits.LeadingZeros32(low) - (32 - shift) + 1)
+	}
+	return int(idx >> shift), uint8(shift) + rho
+}
+
+// Merge merges the other sketch into this one. Both sketches must have the
+// same precision.
+func (h *HLLSketch) Merge(other *HLLSketch) error {
+	if h.precision != other.precision {
+		return errors.Errorf("cannot merge HyperLogLog sketches of precision %v and %v", h.precision, other.precision)
+	}
+	switch {
+	case other.dense != nil:
+		if h.dense == nil {
+			h.toDense()
+		}
+		for idx, rho := range other.dense {
+			h.setDense(idx, rho)
+		}
+	case h.dense != nil:
+		for idx, rho := range other.sparse {
+			h.setDense(h.downgrade(idx, rho))
+		}
+	default:
+		for idx, rho := range other.sparse {
+			h.setSparse(idx, rho)
+			if h.dense != nil {
+				// The sketch became dense part way, so the remaining
+				// registers are added by the case above.
+				return h.Merge(other)
+			}
+		}
+	}
+	return nil
+}
+
+// Estimate returns the estimated number of distinct values added to the
+// sketch.
+func (h *HLLSketch) Estimate() int64 {
+	if h.dense == nil {
+		// Linear counting is accurate while few of the sparse registers
+		// are set, which holds until the sketch becomes dense.
+		m := float64(int64(1) << sparsePrecision)
+		return int64(math.Round(m * math.Log(m/(m-float64(len(h.sparse))))))
+	}
+
+	q := 64 - h.precision
+	hist := make([]int, q+2)
+	for _, rho := range h.dense {
+		hist[rho]++
+	}
+	m := float64(len(h.dense))
+	z := m * hllTau(1-float64(hist[q+1])/m)
+	for k := q; k >= 1; k-- {
+		z = 0.5 * (z + float64(hist[k]))
+	}
+	z += m * hllSigma(float64(hist[0])/m)
+	return int64(math.Round(m * m / (2 * math.Ln2 * z)))
+}
+
+// hllSigma and hllTau are the series used by the improved estimator in
+// "New cardinality estimation algorithms for HyperLogLog sketches" by Ertl.
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if z == prev {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= (1 - x) * (1 - x) * y
+		if z == prev {
+			return z / 3
+		}
+	}
+}
+
+// MarshalBinary returns the serialized sketch.
+//
+// The format is a version byte, the precision, and the representation,
+// followed by either the number of sparse registers and their index and value
+// pairs in increasing order of index as delta encoded varints, or the dense
+// register values.
+func (h *HLLSketch) MarshalBinary() ([]byte, error) {
+	if h.dense != nil {
+		data := make([]byte, 0, 3+len(h.dense))
+		data = append(data, hllVersion, byte(h.precision), hllDense)
+		return append(data, h.dense...), nil
+	}
+
+	regs := make([]uint32, 0, len(h.sparse))
+	for idx := range h.sparse {
+		regs = append(regs, idx)
+	}
+	sort.Slice(regs, func(i, j int) bool { return regs[i] < regs[j] })
+
+	data := make([]byte, 0, 3+binary.MaxVarintLen32*(1+len(regs)))
+	data = append(data, hllVersion, byte(h.precision), hllSparse)
+	data = binary.AppendUvarint(data, uint64(len(regs)))
+	var prev uint32
+	for _, idx := range regs {
+		data = binary.AppendUvarint(data, uint64(idx-prev)<<6|uint64(h.sparse[idx]))
+		prev = idx
+	}
+	return data, nil
+}
+
+// UnmarshalBinary replaces the sketch with the one serialized in data.
+func (h *HLLSketch) UnmarshalBinary(data []byte) error {
+	if len(data) < 3 {
+		return errors.Errorf("invalid HyperLogLog sketch: %v bytes", len(data))
+	}
+	if data[0] != hllVersion {
+		return errors.Errorf("unsupported HyperLogLog sketch version %v", data[0])
+	}
+	s, err := NewHLLSketch(int(data[1]))
+	if err != nil {
+		return err
+	}
+	repr, data := data[2], data[3:]
+
+	switch repr {
+	case hllDense:
+		if len(data) != 1<<s.precision {
+			return errors.Errorf("invalid dense HyperLogLog sketch: %v registers for precision %v", len(data), s.precision)
+		}
+		for idx, rho := range data {
+			if int(rho) > maxRho(s.precision) {
+				return errors.Errorf("invalid dense HyperLogLog sketch: register %v value %v exceeds %v", idx, rho, maxRho(s.precision))
+			}
+		}
+		s.sparse = nil
+		s.dense = append([]uint8(nil), data...)
+	case hllSparse:
+		n, l := binary.Uvarint(data)
+		if l <= 0 {
+			return errors.New("invalid sparse HyperLogLog sketch: bad register count")
+		}
+		data = data[l:]
+		var idx uint32
+		for i := uint64(0); i < n; i++ {
+			v, l := binary.Uvarint(data)
+			if l <= 0 {
+				return errors.Errorf("invalid sparse HyperLogLog sketch: bad register %v of %v", i, n)
+			}
+			data = data[l:]
+			idx += uint32(v >> 6)
+			if idx >= 1<<sparsePrecision {
+				return errors.Errorf("invalid sparse HyperLogLog sketch: register index %v out of range", idx)
+			}
+			rho := uint8(v & 0x3f)
+			if rho == 0 || int(rho) > maxRho(sparsePrecision) {
+				return errors.Errorf("invalid sparse HyperLogLog sketch: register %v value %v out of range [1, %v]", idx, rho, maxRho(sparsePrecision))
+			}
+			s.sparse[idx] = rho
+		}
+		if 4*len(s.sparse) > 1<<s.precision {
+			s.toDense()
+		}
+	default:
+		return errors.Errorf("invalid HyperLogLog sketch representation %v", repr)
+	}
+	*h = *s
+	return nil
+}
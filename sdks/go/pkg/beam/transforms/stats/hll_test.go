@@ -0,0 +1,174 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func addN(h *HLLSketch, from, to int) {
+	for i := from; i < to; i++ {
+		h.Add([]byte(fmt.Sprintf("value-%d", i)))
+	}
+}
+
+func checkEstimate(t *testing.T, h *HLLSketch, want int) {
+	t.Helper()
+	// Allow for four standard errors, or one for cardinalities that are
+	// still counted in the sparse representation.
+	stdErr := 1.04 / math.Sqrt(float64(int(1)<<h.Precision()))
+	got := h.Estimate()
+	if diff := math.Abs(float64(got)-float64(want)) / math.Max(float64(want), 1); diff > 4*stdErr {
+		t.Errorf("Estimate() = %v, want %v within %.2f%%", got, want, 400*stdErr)
+	}
+}
+
+func TestHLLSketch_Estimate(t *testing.T) {
+	for _, p := range []int{MinPrecision, 10, DefaultPrecision, MaxPrecision} {
+		for _, n := range []int{0, 1, 10, 1000, 100000} {
+			t.Run(fmt.Sprintf("p=%d/n=%d", p, n), func(t *testing.T) {
+				h, err := NewHLLSketch(p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				addN(h, 0, n)
+				// Duplicates don't change the estimate.
+				addN(h, 0, n)
+				checkEstimate(t, h, n)
+			})
+		}
+	}
+}
+
+func TestHLLSketch_Merge(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b int
+	}{
+		{"sparse", 100, 200},
+		{"sparseToDense", 3000, 6000},
+		{"dense", 50000, 100000},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, _ := NewHLLSketch(14)
+			b, _ := NewHLLSketch(14)
+			all, _ := NewHLLSketch(14)
+			addN(a, 0, test.a)
+			// The sketches overlap, so the union has test.b values.
+			addN(b, test.a/2, test.b)
+			addN(all, 0, test.b)
+			if err := a.Merge(b); err != nil {
+				t.Fatalf("Merge() failed: %v", err)
+			}
+			if got, want := a.Estimate(), all.Estimate(); got != want {
+				t.Errorf("merged Estimate() = %v, want %v", got, want)
+			}
+			checkEstimate(t, a, test.b)
+		})
+	}
+}
+
+func TestHLLSketch_MergeInvalid(t *testing.T) {
+	a, _ := NewHLLSketch(10)
+	b, _ := NewHLLSketch(11)
+	if err := a.Merge(b); err == nil {
+		t.Error("Merge() of different precisions succeeded, want error")
+	}
+}
+
+func TestNewHLLSketch_Invalid(t *testing.T) {
+	for _, p := range []int{-1, 0, MinPrecision - 1, MaxPrecision + 1} {
+		if _, err := NewHLLSketch(p); err == nil {
+			t.Errorf("NewHLLSketch(%v) succeeded, want error", p)
+		}
+	}
+}
+
+func TestHLLSketch_MarshalBinary(t *testing.T) {
+	for _, n := range []int{0, 10, 1000, 10000} {
+		t.Run(fmt.Sprintf("n=%d", n), func(t *testing.T) {
+			h, _ := NewHLLSketch(12)
+			addN(h, 0, n)
+			data, err := h.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary() failed: %v", err)
+			}
+			var got HLLSketch
+			if err := got.UnmarshalBinary(data); err != nil {
+				t.Fatalf("UnmarshalBinary() failed: %v", err)
+			}
+			if got.Precision() != h.Precision() || got.Estimate() != h.Estimate() {
+				t.Errorf("UnmarshalBinary(MarshalBinary()) = (%v, %v), want (%v, %v)", got.Precision(), got.Estimate(), h.Precision(), h.Estimate())
+			}
+			// The decoded sketch keeps counting.
+			addN(&got, n, 2*n)
+			checkEstimate(t, &got, 2*n)
+		})
+	}
+}
+
+// TestHLLSketch_MarshalBinaryFormat guards the serialized format, which
+// must not change for sketches written by earlier releases to stay mergeable.
+func TestHLLSketch_MarshalBinaryFormat(t *testing.T) {
+	h, _ := NewHLLSketch(DefaultPrecision)
+	h.Add([]byte("a"))
+	h.Add([]byte("b"))
+	got, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() failed: %v", err)
+	}
+	want := []byte{hllVersion, DefaultPrecision, hllSparse, 2, 0xc2, 0xaa, 0x8a, 0xe1, 0x03, 0x81, 0x9a, 0x93, 0xe8, 0x02}
+	if !bytes.Equal(got, want) {
+		t.Errorf("MarshalBinary() = %#v, want %#v", got, want)
+	}
+}
+
+func TestHLLSketch_UnmarshalBinaryInvalid(t *testing.T) {
+	tests := [][]byte{
+		nil,
+		{hllVersion, 12},
+		{2, 12, hllDense},
+		{hllVersion, 30, hllDense},
+		{hllVersion, 4, hllDense, 0, 0},
+		{hllVersion, 4, hllSparse, 2, 0x41},
+		{hllVersion, 4, 7},
+		// Register values beyond the largest possible one.
+		append([]byte{hllVersion, 4, hllDense, 62}, make([]byte, 15)...),
+		{hllVersion, 4, hllSparse, 1, 0x3f},
+		{hllVersion, 4, hllSparse, 1, 0x40},
+	}
+	for _, data := range tests {
+		var h HLLSketch
+		if err := h.UnmarshalBinary(data); err == nil {
+			t.Errorf("UnmarshalBinary(%v) succeeded, want error", data)
+		}
+	}
+}
+
+func TestHLLSketch_UnmarshalBinaryMaxRegister(t *testing.T) {
+	data := append([]byte{hllVersion, 4, hllDense, byte(maxRho(4))}, make([]byte, 15)...)
+	var h HLLSketch
+	if err := h.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary(%v) failed: %v", data, err)
+	}
+	if got := h.Estimate(); got <= 0 {
+		t.Errorf("Estimate() = %v, want positive", got)
+	}
+}
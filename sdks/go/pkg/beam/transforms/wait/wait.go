@@ -0,0 +1,129 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package wait contains transformations for sequencing the processing of a
+// PCollection after the completion of other PCollections.
+package wait
+
+import (
+	"fmt"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/typex"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+)
+
+func init() {
+	register.Function1x2(signalKeyFn)
+	register.Function2x2(signalKVKeyFn)
+	register.Function2x2(signalGBKKeyFn)
+	register.Function2x1(signalDoneFn)
+	register.Function3x0(waitFn)
+	register.Function4x0(waitKVFn)
+	register.Emitter1[beam.T]()
+	register.Emitter2[beam.X, beam.Y]()
+	register.Iter1[bool]()
+	register.Iter1[beam.Y]()
+}
+
+// On returns a PCollection with the contents of main, where the elements of
+// each window are held back until every signal PCollection is done with that
+// window, that is until the watermark of each signal has passed the end of
+// the window. The signals may be of any type, including KV and grouped
+// PCollections. This is useful to sequence side effects, such as writing to a
+// table only after it has been created:
+//
+//	created := beam.ParDo(s, createTableFn, beam.Impulse(s))
+//	ready := wait.On(s, rows, created)
+//	beam.ParDo0(s, insertFn, ready)
+//
+// The windows of the main elements are mapped to the signal windows as for
+// side inputs, so the signals must not use merging windows, and must not use
+// a non-global windowing if main is globally windowed. A globally windowed
+// unbounded signal is never done, so main is held back forever. Elements of
+// the signals are discarded.
+//
+// Main must not be grouped, since a grouped PCollection can't be the main
+// input of a ParDo that emits it unchanged: wait on the PCollection before
+// grouping it instead. Signals grouped by a CoGroupByKey of several
+// PCollections are not supported either.
+func On(s beam.Scope, main beam.PCollection, signals ...beam.PCollection) beam.PCollection {
+	s = s.Scope("wait.On")
+
+	if typex.IsCoGBK(main.Type()) {
+		panic(fmt.Sprintf("wait.On: main input of type %v is grouped; wait on it before grouping it instead", main.Type()))
+	}
+	for i, signal := range signals {
+		if t := signal.Type(); typex.IsCoGBK(t) && len(t.Components()) > 2 {
+			panic(fmt.Sprintf("wait.On: signal %d of type %v is grouped by a CoGroupByKey of several PCollections", i, t))
+		}
+	}
+
+	fn := any(waitFn)
+	if typex.IsKV(main.Type()) {
+		fn = waitKVFn
+	}
+	for i, signal := range signals {
+		done := signalDone(s.Scope(fmt.Sprintf("signal%d", i)), signal)
+		main = beam.ParDo(s, fn, main, beam.SideInput{Input: done})
+	}
+	return main
+}
+
+// signalDone returns a PCollection with one element per window of the signal,
+// emitted once the signal is done with the window. Grouping with the default
+// trigger produces the per-window result only once the watermark passes the
+// end of the window, and keeps the side input small.
+func signalDone(s beam.Scope, signal beam.PCollection) beam.PCollection {
+	var keyed beam.PCollection
+	switch {
+	case typex.IsKV(signal.Type()):
+		keyed = beam.ParDo(s, signalKVKeyFn, signal)
+	case typex.IsCoGBK(signal.Type()):
+		keyed = beam.ParDo(s, signalGBKKeyFn, signal)
+	default:
+		keyed = beam.ParDo(s, signalKeyFn, signal)
+	}
+	return beam.ParDo(s, signalDoneFn, beam.GroupByKey(s, keyed))
+}
+
+func signalKeyFn(_ beam.T) (int, bool) {
+	return 0, true
+}
+
+func signalKVKeyFn(_ beam.X, _ beam.Y) (int, bool) {
+	return 0, true
+}
+
+// signalGBKKeyFn keys a grouped signal, which is the output of a
+// GroupByKey. Signals grouped by a CoGroupByKey of several PCollections are
+// not supported.
+func signalGBKKeyFn(_ beam.X, _ func(*beam.Y) bool) (int, bool) {
+	return 0, true
+}
+
+func signalDoneFn(_ int, _ func(*bool) bool) bool {
+	return true
+}
+
+// waitFn passes through the main input. The signal side input is never read:
+// the DoFn is only invoked once the runner considers it ready for the window.
+func waitFn(elm beam.T, _ func(*bool) bool, emit func(beam.T)) {
+	emit(elm)
+}
+
+func waitKVFn(k beam.X, v beam.Y, _ func(*bool) bool, emit func(beam.X, beam.Y)) {
+	emit(k, v)
+}
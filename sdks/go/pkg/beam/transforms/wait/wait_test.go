@@ -0,0 +1,111 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wait
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/mtime"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/graph/window"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+)
+
+func init() {
+	register.Function1x2(timestampFn)
+	register.Function1x2(splitFn)
+}
+
+func TestMain(m *testing.M) {
+	ptest.Main(m)
+}
+
+func timestampFn(n int) (beam.EventTime, int) {
+	return mtime.FromMilliseconds(int64(n) * 1000), n
+}
+
+func splitFn(s string) (string, int) {
+	return s, len(s)
+}
+
+func TestOn(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	main := beam.Create(s, 1, 2, 3)
+	signal1 := beam.Create(s, "a", "b")
+	signal2 := beam.Create(s, 1.5)
+	passert.Equals(s, On(s, main, signal1, signal2), 1, 2, 3)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestOn_KV(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	main := beam.ParDo(s, splitFn, beam.Create(s, "a", "bb"))
+	signal := beam.Create(s, 1)
+	out := On(s, main, signal)
+	passert.Equals(s, beam.DropKey(s, out), 1, 2)
+	passert.Equals(s, beam.DropValue(s, out), "a", "bb")
+	ptest.RunAndValidate(t, p)
+}
+
+func TestOn_KVSignal(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	main := beam.Create(s, 1, 2, 3)
+	signal := beam.ParDo(s, splitFn, beam.Create(s, "a", "bb"))
+	grouped := beam.GroupByKey(s, signal)
+	passert.Equals(s, On(s, main, signal, grouped), 1, 2, 3)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestOn_Windowed(t *testing.T) {
+	p, s := beam.NewPipelineWithRoot()
+	wfn := window.NewFixedWindows(5 * time.Second)
+	main := beam.WindowInto(s, wfn, beam.ParDo(s, timestampFn, beam.Create(s, 1, 6, 11)))
+	signal := beam.WindowInto(s, wfn, beam.ParDo(s, timestampFn, beam.Create(s, 2, 7)))
+	out := On(s, main, signal)
+	passert.Equals(s, beam.WindowInto(s, window.NewGlobalWindows(), out), 1, 6, 11)
+	ptest.RunAndValidate(t, p)
+}
+
+func TestOn_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(s beam.Scope) (beam.PCollection, beam.PCollection)
+	}{
+		{"grouped main", func(s beam.Scope) (beam.PCollection, beam.PCollection) {
+			kvs := beam.ParDo(s, splitFn, beam.Create(s, "a", "bb"))
+			return beam.GroupByKey(s, kvs), beam.Create(s, 1)
+		}},
+		{"co-grouped signal", func(s beam.Scope) (beam.PCollection, beam.PCollection) {
+			kvs := beam.ParDo(s, splitFn, beam.Create(s, "a", "bb"))
+			return beam.Create(s, 1), beam.CoGroupByKey(s, kvs, kvs)
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("On() succeeded, want panic")
+				}
+			}()
+			_, s := beam.NewPipelineWithRoot()
+			main, signal := test.build(s)
+			On(s, main, signal)
+		})
+	}
+}
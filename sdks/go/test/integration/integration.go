@@ -288,6 +288,9 @@ var dataflowFilters = []string{
 	"TestJDBCIO_PostgresReadWrite",
 	"TestDebeziumIO_BasicRead",
 	"TestMongoDBIO.*",
+	"TestDatastoreIO.*",
+	// The Dataflow runner can't reach the in-process Bigtable emulator.
+	"TestBigtableIO.*",
 	// TODO(BEAM-11576): TestFlattenDup failing on this runner.
 	"TestFlattenDup",
 	// The Dataflow runner does not support the TestStream primitive
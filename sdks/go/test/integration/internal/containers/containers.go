@@ -35,6 +35,12 @@ func WithEnv(env map[string]string) ContainerOptionFn {
 	}
 }
 
+func WithCmd(cmd []string) ContainerOptionFn {
+	return func(option *testcontainers.ContainerRequest) {
+		option.Cmd = cmd
+	}
+}
+
 func WithHostname(hostname string) ContainerOptionFn {
 	return func(option *testcontainers.ContainerRequest) {
 		option.Hostname = hostname
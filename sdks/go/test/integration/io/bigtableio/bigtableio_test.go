@@ -0,0 +1,134 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtableio
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/bigtableio"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/runners/dataflow"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/runners/flink"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/runners/samza"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/runners/spark"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+	"github.com/apache/beam/sdks/v2/go/test/integration"
+)
+
+const timestamp = 1000
+
+func init() {
+	beam.RegisterType(reflect.TypeOf((*entry)(nil)).Elem())
+}
+
+type entry struct {
+	Key   string `bigtable:"__key__"`
+	Name  string `bigtable:"info:name"`
+	Count int64  `bigtable:"stats:count"`
+}
+
+func TestBigtableIO_Read(t *testing.T) {
+	integration.CheckFilters(t)
+
+	entries := []entry{
+		{Key: "a", Name: "alpha", Count: 1},
+		{Key: "b1", Name: "beta", Count: 2},
+		{Key: "b2", Name: "beta", Count: 3},
+		{Key: "c", Name: "gamma", Count: 4},
+	}
+
+	tests := []struct {
+		name string
+		opts []bigtableio.ReadOptionFn
+		want []any
+	}{
+		{
+			name: "all rows",
+			want: []any{entries[0], entries[1], entries[2], entries[3]},
+		},
+		{
+			name: "row ranges",
+			opts: []bigtableio.ReadOptionFn{bigtableio.WithRowRange("b2", ""), bigtableio.WithRowRange("", "b")},
+			want: []any{entries[0], entries[2], entries[3]},
+		},
+		{
+			name: "overlapping row ranges",
+			opts: []bigtableio.ReadOptionFn{bigtableio.WithRowRange("a", "b2"), bigtableio.WithRowRange("b", "c")},
+			want: []any{entries[0], entries[1], entries[2]},
+		},
+		{
+			name: "prefix",
+			opts: []bigtableio.ReadOptionFn{bigtableio.WithPrefix("b")},
+			want: []any{entries[1], entries[2]},
+		},
+		{
+			name: "filter",
+			opts: []bigtableio.ReadOptionFn{bigtableio.WithFilter(bigtableio.RowKeyFilter("a|c"))},
+			want: []any{entries[0], entries[3]},
+		},
+		{
+			name: "filtered column",
+			opts: []bigtableio.ReadOptionFn{
+				bigtableio.WithPrefix("c"),
+				bigtableio.WithFilter(bigtableio.FamilyFilter("info")),
+			},
+			want: []any{entry{Key: "c", Name: "gamma"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setUpEmulator(t, entries)
+
+			p, s := beam.NewPipelineWithRoot()
+			got := bigtableio.Read(s, project, instance, table, reflect.TypeOf(entry{}), tt.opts...)
+			passert.Equals(s, got, tt.want...)
+			ptest.RunAndValidate(t, p)
+		})
+	}
+}
+
+func TestBigtableIO_ReadRow(t *testing.T) {
+	integration.CheckFilters(t)
+
+	setUpEmulator(t, []entry{{Key: "a", Name: "alpha", Count: 1}})
+
+	p, s := beam.NewPipelineWithRoot()
+	got := bigtableio.Read(s, project, instance, table, reflect.TypeOf(bigtableio.Row{}))
+	passert.Equals(s, got, bigtableio.Row{
+		Key: "a",
+		Families: []bigtableio.Family{
+			{
+				Name:  "info",
+				Cells: []bigtableio.Cell{{Column: "name", Timestamp: timestamp, Value: []byte("alpha")}},
+			},
+			{
+				Name:  "stats",
+				Cells: []bigtableio.Cell{{Column: "count", Timestamp: timestamp, Value: []byte{0, 0, 0, 0, 0, 0, 0, 1}}},
+			},
+		},
+	})
+	ptest.RunAndValidate(t, p)
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	beam.Init()
+
+	ptest.MainRet(m)
+}
@@ -0,0 +1,84 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigtableio
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"cloud.google.com/go/bigtable"
+	"cloud.google.com/go/bigtable/bttest"
+)
+
+const (
+	project  = "test-project"
+	instance = "test-instance"
+	table    = "test-table"
+)
+
+// setUpEmulator starts an in-memory Bigtable emulator, points Bigtable
+// clients created by the test process, including those of pipelines run in
+// loopback mode, at it and creates a table holding the provided entries.
+func setUpEmulator(t *testing.T, entries []entry) {
+	t.Helper()
+
+	srv, err := bttest.NewServer("localhost:0")
+	if err != nil {
+		t.Fatalf("error starting Bigtable emulator: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	t.Setenv("BIGTABLE_EMULATOR_HOST", srv.Addr)
+
+	ctx := context.Background()
+	admin, err := bigtable.NewAdminClient(ctx, project, instance)
+	if err != nil {
+		t.Fatalf("error creating Bigtable admin client: %v", err)
+	}
+	defer admin.Close()
+
+	if err := admin.CreateTable(ctx, table); err != nil {
+		t.Fatalf("error creating table: %v", err)
+	}
+	for _, family := range []string{"info", "stats"} {
+		if err := admin.CreateColumnFamily(ctx, table, family); err != nil {
+			t.Fatalf("error creating column family: %v", err)
+		}
+	}
+
+	client, err := bigtable.NewClient(ctx, project, instance)
+	if err != nil {
+		t.Fatalf("error creating Bigtable client: %v", err)
+	}
+	defer client.Close()
+
+	tbl := client.Open(table)
+	for _, e := range entries {
+		if err := tbl.Apply(ctx, e.Key, entryMutation(e)); err != nil {
+			t.Fatalf("error writing entry: %v", err)
+		}
+	}
+}
+
+func entryMutation(e entry) *bigtable.Mutation {
+	count := make([]byte, 8)
+	binary.BigEndian.PutUint64(count, uint64(e.Count))
+
+	mut := bigtable.NewMutation()
+	mut.Set("info", "name", timestamp, []byte(e.Name))
+	mut.Set("stats", "count", timestamp, count)
+	return mut
+}
@@ -0,0 +1,151 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastoreio
+
+import (
+	"context"
+	"flag"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/datastore"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/core/runtime"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/datastoreio"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/register"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/runners/dataflow"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/runners/flink"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/runners/samza"
+	_ "github.com/apache/beam/sdks/v2/go/pkg/beam/runners/spark"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+	"github.com/apache/beam/sdks/v2/go/test/integration"
+	"github.com/google/go-cmp/cmp"
+)
+
+const kind = "Entity"
+
+var entityKey string
+
+func init() {
+	entityKey = runtime.RegisterType(reflect.TypeOf((*entity)(nil)).Elem())
+	register.Function1x2(keyEntity)
+	register.Function1x1(encodedKey)
+}
+
+type entity struct {
+	Name  string
+	Count int64
+}
+
+func keyEntity(e entity) (string, entity) {
+	return encodedKey(e), e
+}
+
+func encodedKey(e entity) string {
+	return datastore.NameKey(kind, e.Name, nil).Encode()
+}
+
+func TestDatastoreIO_Write(t *testing.T) {
+	integration.CheckFilters(t)
+
+	ctx := context.Background()
+	setUpEmulator(ctx, t)
+	client := newClient(ctx, t)
+
+	want := []entity{{Name: "a", Count: 1}, {Name: "b", Count: 2}, {Name: "c", Count: 3}}
+	putEntities(ctx, t, client, []entity{{Name: "a", Count: 0}})
+
+	p, s := beam.NewPipelineWithRoot()
+	col := beam.ParDo(s, keyEntity, beam.CreateList(s, want))
+	datastoreio.Write(s, project, col, datastoreio.WithRampUp(false))
+	ptest.RunAndValidate(t, p)
+
+	if got := getEntities(ctx, t, client); !cmp.Equal(got, want) {
+		t.Errorf("getEntities() = %v, want %v", got, want)
+	}
+}
+
+func TestDatastoreIO_Delete(t *testing.T) {
+	integration.CheckFilters(t)
+
+	ctx := context.Background()
+	setUpEmulator(ctx, t)
+	client := newClient(ctx, t)
+
+	putEntities(ctx, t, client, []entity{{Name: "a", Count: 1}, {Name: "b", Count: 2}, {Name: "c", Count: 3}})
+
+	p, s := beam.NewPipelineWithRoot()
+	keys := beam.ParDo(s, encodedKey, beam.Create(s, entity{Name: "a"}, entity{Name: "c"}))
+	datastoreio.Delete(s, project, keys, datastoreio.WithRampUp(false))
+	ptest.RunAndValidate(t, p)
+
+	if got, want := getEntities(ctx, t, client), []entity{{Name: "b", Count: 2}}; !cmp.Equal(got, want) {
+		t.Errorf("getEntities() = %v, want %v", got, want)
+	}
+}
+
+func TestDatastoreIO_ReadQuery(t *testing.T) {
+	integration.CheckFilters(t)
+
+	ctx := context.Background()
+	setUpEmulator(ctx, t)
+	client := newClient(ctx, t)
+
+	putEntities(ctx, t, client, []entity{
+		{Name: "a", Count: 1},
+		{Name: "b", Count: 2},
+		{Name: "c", Count: 2},
+		{Name: "d", Count: 3},
+	})
+
+	tests := []struct {
+		name string
+		gql  string
+		want []any
+	}{
+		{
+			name: "split equality query",
+			gql:  "SELECT * FROM Entity WHERE Count = 2",
+			want: []any{entity{Name: "b", Count: 2}, entity{Name: "c", Count: 2}},
+		},
+		{
+			name: "unsplittable query",
+			gql:  "SELECT * FROM Entity WHERE Count > 1 ORDER BY Count DESC, Name LIMIT 2",
+			want: []any{entity{Name: "d", Count: 3}, entity{Name: "b", Count: 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := datastoreio.ParseGQL(tt.gql)
+			if err != nil {
+				t.Fatalf("ParseGQL() failed: %v", err)
+			}
+
+			p, s := beam.NewPipelineWithRoot()
+			got := datastoreio.ReadQuery(s, project, q, 4, reflect.TypeOf(entity{}), entityKey)
+			passert.Equals(s, got, tt.want...)
+			ptest.RunAndValidate(t, p)
+		})
+	}
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	beam.Init()
+
+	ptest.MainRet(m)
+}
@@ -0,0 +1,98 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datastoreio
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"cloud.google.com/go/datastore"
+	"github.com/apache/beam/sdks/v2/go/test/integration/internal/containers"
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	emulatorImage = "gcr.io/google.com/cloudsdktool/google-cloud-cli:emulators"
+	emulatorPort  = "8081/tcp"
+	maxRetries    = 5
+	project       = "test-project"
+)
+
+// setUpEmulator starts the Datastore emulator and points Datastore clients
+// created by the test process, including those of pipelines run in loopback
+// mode, at it.
+func setUpEmulator(ctx context.Context, t *testing.T) {
+	t.Helper()
+
+	container := containers.NewContainer(
+		ctx,
+		t,
+		emulatorImage,
+		maxRetries,
+		containers.WithCmd([]string{
+			"gcloud", "beta", "emulators", "datastore", "start",
+			"--project=" + project, "--host-port=0.0.0.0:8081", "--no-store-on-disk", "--consistency=1.0",
+		}),
+		containers.WithPorts([]string{emulatorPort}),
+		containers.WithWaitStrategy(wait.ForLog("Dev App Server is now running")),
+	)
+
+	hostIP, err := container.Host(ctx)
+	if err != nil {
+		t.Fatalf("error getting emulator host: %v", err)
+	}
+	port := containers.Port(ctx, t, container, nat.Port(emulatorPort))
+	t.Setenv("DATASTORE_EMULATOR_HOST", fmt.Sprintf("%s:%s", hostIP, port))
+}
+
+func newClient(ctx context.Context, t *testing.T) *datastore.Client {
+	t.Helper()
+
+	client, err := datastore.NewClient(ctx, project)
+	if err != nil {
+		t.Fatalf("error creating Datastore client: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := client.Close(); err != nil {
+			t.Errorf("error closing Datastore client: %v", err)
+		}
+	})
+	return client
+}
+
+func putEntities(ctx context.Context, t *testing.T, client *datastore.Client, entities []entity) {
+	t.Helper()
+
+	keys := make([]*datastore.Key, len(entities))
+	for i, e := range entities {
+		keys[i] = datastore.NameKey(kind, e.Name, nil)
+	}
+	if _, err := client.PutMulti(ctx, keys, entities); err != nil {
+		t.Fatalf("error putting entities: %v", err)
+	}
+}
+
+func getEntities(ctx context.Context, t *testing.T, client *datastore.Client) []entity {
+	t.Helper()
+
+	var entities []entity
+	if _, err := client.GetAll(ctx, datastore.NewQuery(kind).Order("Name"), &entities); err != nil {
+		t.Fatalf("error getting entities: %v", err)
+	}
+	return entities
+}
@@ -0,0 +1,73 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerio
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/spannerio"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+	"github.com/apache/beam/sdks/v2/go/test/integration"
+)
+
+func TestSpannerIO_ReadChangeStream(t *testing.T) {
+	integration.CheckFilters(t)
+
+	p := beam.NewPipeline()
+	s := p.Root()
+
+	db := "projects/test-project/instances/test-instance/databases/test-database"
+	ctx := context.Background()
+
+	client := setUpDatabase(ctx, t, db, []string{
+		`CREATE TABLE Test (
+			One STRING(20),
+			Two INT64,
+		) PRIMARY KEY (Two)`,
+		`CREATE CHANGE STREAM TestStream FOR Test`,
+	})
+
+	start := time.Now()
+
+	for _, m := range []spannerio.TestDto{{One: "one", Two: 1}, {One: "two", Two: 2}, {One: "three", Two: 3}} {
+		mutation, err := spanner.InsertStruct("Test", m)
+		if err != nil {
+			t.Fatalf("Unable to create spanner mutation: %v", err)
+		}
+
+		// Apply each mutation in its own transaction, so that each is a separate data change record.
+		if _, err := client.Apply(ctx, []*spanner.Mutation{mutation}); err != nil {
+			t.Fatalf("Unable to apply spanner mutations: %v", err)
+		}
+	}
+
+	end := time.Now()
+
+	records := spannerio.ReadChangeStream(s, db, "TestStream",
+		spannerio.WithStartTimestamp(start),
+		spannerio.WithEndTimestamp(end),
+		spannerio.WithHeartbeatInterval(time.Second),
+	)
+
+	passert.Count(s, records, "Should have 3 data change records", 3)
+
+	ptest.RunAndValidate(t, p)
+}
@@ -222,3 +222,30 @@ func parseDatabaseName(t *testing.T, db string) (project, instance, database str
 	}
 	return matches[1], matches[2], matches[3]
 }
+
+// setUpDatabase starts a spanner emulator container with a database of the given schema, and returns a client of it.
+func setUpDatabase(ctx context.Context, t *testing.T, db string, ddls []string) *spanner.Client {
+	t.Helper()
+
+	endpoint := setUpTestContainer(ctx, t)
+
+	t.Setenv("SPANNER_EMULATOR_HOST", endpoint)
+
+	client := NewClient(ctx, t, endpoint, db)
+	instanceAdminClient := NewInstanceAdminClient(ctx, t, endpoint)
+	adminClient := NewAdminClient(ctx, t, endpoint)
+
+	CreateInstance(ctx, t, instanceAdminClient, db)
+	t.Cleanup(func() {
+		DeleteInstance(ctx, t, instanceAdminClient, db)
+	})
+
+	CreateDatabase(ctx, t, adminClient, db)
+	t.Cleanup(func() {
+		DropDatabase(ctx, t, adminClient, db)
+	})
+
+	CreateTable(ctx, t, adminClient, db, ddls)
+
+	return client
+}
@@ -0,0 +1,89 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerio
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/io/spannerio"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/passert"
+	"github.com/apache/beam/sdks/v2/go/pkg/beam/testing/ptest"
+	"github.com/apache/beam/sdks/v2/go/test/integration"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestSpannerIO_WriteMutationGroups(t *testing.T) {
+	integration.CheckFilters(t)
+
+	p := beam.NewPipeline()
+	s := p.Root()
+
+	db := "projects/test-project/instances/test-instance/databases/test-database"
+	ctx := context.Background()
+
+	client := setUpDatabase(ctx, t, db, []string{`CREATE TABLE Test (
+			One STRING(20),
+			Two INT64,
+		) PRIMARY KEY (Two)`})
+
+	insert := func(one string, two int64) *spannerpb.Mutation {
+		return &spannerpb.Mutation{
+			Operation: &spannerpb.Mutation_Insert{
+				Insert: &spannerpb.Mutation_Write{
+					Table:   "Test",
+					Columns: []string{"Two", "One"},
+					Values: []*structpb.ListValue{{
+						Values: []*structpb.Value{
+							structpb.NewStringValue(strconv.FormatInt(two, 10)),
+							structpb.NewStringValue(one),
+						},
+					}},
+				},
+			},
+		}
+	}
+
+	groups := beam.CreateList(s, []*spannerio.MutationGroup{
+		spannerio.NewMutationGroup(insert("one", 1), insert("two", 2)),
+		spannerio.NewMutationGroup(insert("three", 3)),
+		spannerio.NewMutationGroup(insert("four", 4), insert("five", 5)),
+	})
+
+	written, failed := spannerio.WriteMutationGroups(s, db, groups, spannerio.UseBatchSize(2))
+
+	passert.Count(s, written, "Should have written 3 mutation groups", 3)
+	passert.Empty(s, failed)
+
+	ptest.RunAndValidate(t, p)
+
+	row, err := client.Single().ReadRow(ctx, "Test", spanner.Key{int64(5)}, []string{"One"})
+	if err != nil {
+		t.Fatalf("Unable to read written row: %v", err)
+	}
+
+	var one string
+	if err := row.Column(0, &one); err != nil {
+		t.Fatalf("Unable to decode written row: %v", err)
+	}
+	if one != "five" {
+		t.Errorf("Got row with One = %q, want %q", one, "five")
+	}
+}